@@ -1,12 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/reflock"
+	"github.com/dadav/helm-schema/pkg/schema"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/dadav/helm-schema/pkg/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -30,6 +38,65 @@ func configureLogging() {
 
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
 	log.SetLevel(logLevel)
+
+	// configureLogging runs again for commands which regenerate the schemas (e.g. migrate)
+	hooks := make(log.LevelHooks)
+	if viper.GetBool("annotate-github") {
+		hooks.Add(logging.NewGitHubHook(os.Stdout))
+	}
+	if path := viper.GetString("diagnostics-json"); path != "" {
+		// the schemas of --dry-run are printed to stdout
+		if path == "-" && viper.GetBool("dry-run") {
+			log.Errorf("--diagnostics-json - writes to stdout and can't be used with --dry-run, which prints the schemas there")
+			os.Exit(1)
+		}
+		out, err := openDiagnostics(path)
+		if err != nil {
+			log.Errorf("Failed to open the diagnostics file %s: %s", path, err)
+			os.Exit(1)
+		}
+		hooks.Add(logging.NewJSONLinesHook(out))
+	}
+	log.StandardLogger().ReplaceHooks(hooks)
+}
+
+// diagnosticsFile is the file of --diagnostics-json, it is kept open when configureLogging runs again
+var diagnosticsFile *os.File
+
+// openDiagnostics returns the writer of the diagnostics, - is stdout
+func openDiagnostics(path string) (io.Writer, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	if diagnosticsFile != nil && diagnosticsFile.Name() == path {
+		return diagnosticsFile, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	diagnosticsFile = file
+	return file, nil
+}
+
+// loadConfigFile reads the config file given by --config or the .helm-schema.yaml
+// file in the chart search root (if it exists). Flags take precedence over its values.
+func loadConfigFile() error {
+	if configFile := viper.GetString("config"); configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.SetConfigName(".helm-schema")
+		viper.SetConfigType("yaml")
+		viper.AddConfigPath(viper.GetString("chart-search-root"))
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	return nil
 }
 
 func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
@@ -50,10 +117,20 @@ func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Comma
 		StringP("chart-search-root", "c", ".", "directory to search recursively within for charts")
 	cmd.PersistentFlags().
 		BoolP("dry-run", "d", false, "don't actually create files just print to stdout passed")
+	cmd.PersistentFlags().
+		Bool("check", false, "don't write the schemas, but fail if they differ from the existing schema files (e.g. in CI), with --provenance the schemas without x-generated-by are skipped")
+	cmd.PersistentFlags().
+		Bool("provenance", false, "embed x-generated-by in the root of the schemas: the tool, its version, a hash of the options and the digest of the values files")
 	cmd.PersistentFlags().
 		BoolP("append-newline", "a", false, "append newline to generated jsonschema at the end of the file")
 	cmd.PersistentFlags().
 		BoolP("keep-full-comment", "s", false, "keep the whole leading comment (default: cut at empty line)")
+	cmd.PersistentFlags().
+		String("leading-comment-pattern", "", "regular expression of the leading part of a comment, which is cut unless --keep-full-comment is set (default: everything up to the last empty line)")
+	cmd.PersistentFlags().
+		Bool("enum-from-comments", false, "turn the values listed in the comment of a key (e.g. '# one of: debug, info') into its enum")
+	cmd.PersistentFlags().
+		String("enum-comment-pattern", "", "regular expression of the allowed values in a comment for --enum-from-comments, its first group are the comma separated values (default: lines starting with 'one of:')")
 	cmd.PersistentFlags().
 		BoolP("uncomment", "u", false, "consider yaml which is commented out")
 	cmd.PersistentFlags().
@@ -64,21 +141,123 @@ func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Comma
 		BoolP("no-dependencies", "n", false, "don't analyze dependencies")
 	cmd.PersistentFlags().
 		BoolP("add-schema-reference", "r", false, "add reference to schema in values.yaml if not found")
+	cmd.PersistentFlags().
+		Bool("fix", false, "close the @schema blocks of the values files which are not closed before their key")
 	cmd.PersistentFlags().StringP("log-level", "l", "info", logLevelUsage)
+	cmd.PersistentFlags().
+		String("config", "", "path to a config file (default: .helm-schema.yaml in the chart search root, if present)")
 	cmd.PersistentFlags().
 		StringSliceP("value-files", "f", []string{"values.yaml"}, "filenames to check for chart values")
 	cmd.PersistentFlags().
-		StringP("output-file", "o", "values.schema.json", "jsonschema file path relative to each chart directory to which jsonschema will be written")
+		StringP("output-file", "o", schema.DefaultOutputFile, "jsonschema file path relative to each chart directory to which jsonschema will be written, or a go template (available: .ChartDir, .ChartName, .ChartVersion, .ValuesFile)")
 	cmd.PersistentFlags().
-		StringSliceP("skip-auto-generation", "k", []string{}, "comma separated list of fields to skip from being created by default (possible: title, description, required, default, additionalProperties)")
+		StringSliceP("skip-auto-generation", "k", []string{}, "comma separated list of fields to skip from being created by default (possible: title, description, required, default, additionalProperties, format, items, examples), prefix a field with objects., arrays. or scalars. to only skip it for values of this kind")
 	cmd.PersistentFlags().
 		StringSliceP("dependencies-filter", "i", []string{}, "only generate schema for specified dependencies (comma-separated list of dependency names)")
+	cmd.PersistentFlags().
+		StringSlice("library-prefixes", []string{}, "import the value contracts (values.schema.partial.json) of library charts under these keys instead of the root, as <library>=<dotted key path> (comma-separated, a library can be listed several times)")
 	cmd.PersistentFlags().
 		BoolP("dont-add-global", "g", false, "dont auto add global property")
 	cmd.PersistentFlags().
 		BoolP("skip-dependencies-schema-validation", "m", false, "skip schema validation for dependencies by setting additionalProperties to true and removing from required")
 	cmd.PersistentFlags().
 		BoolP("allow-circular-dependencies", "w", false, "allow circular dependencies between charts (will log a warning instead of failing)")
+	cmd.PersistentFlags().
+		Bool("add-min-properties", false, "add minProperties: 1 to required maps which are not empty in the values file")
+	cmd.PersistentFlags().
+		Bool("detect-content-media-types", false, "add contentMediaType to block scalars (| or >) which contain json, yaml or pem data")
+	cmd.PersistentFlags().
+		Bool("preserve-definitions", false, "keep $defs and definitions of the existing schema file which are not generated but still referenced")
+	cmd.PersistentFlags().
+		String("check-readme", "", "compare the schema with the helm-docs values table of this file in each chart directory (e.g. README.md) and report differences")
+	cmd.PersistentFlags().
+		String("schema-registry", "", "url of the schema registry which resolves registry://<chart>@<version> references and receives published schemas")
+	cmd.PersistentFlags().
+		String("git-cache-dir", "", "directory the repositories of git+<url>@<revision>/<path> references are cached in (default: helm-schema/git in the user cache directory)")
+	cmd.PersistentFlags().
+		StringSlice("allowed-ref-schemes", urlpolicy.DefaultAllowedSchemes, "schemes the repositories of git+ references may use")
+	cmd.PersistentFlags().
+		StringSlice("allowed-ref-hosts", []string{}, "hosts referenced schemas may be downloaded from, with wildcards (*.example.com) or as networks (10.0.0.0/8) (default: all)")
+	cmd.PersistentFlags().
+		StringSlice("denied-ref-hosts", []string{}, "hosts referenced schemas must not be downloaded from, like --allowed-ref-hosts")
+	cmd.PersistentFlags().
+		Bool("allow-link-local-refs", false, "allow downloading referenced schemas from link-local addresses and cloud metadata endpoints")
+	cmd.PersistentFlags().
+		Bool("draft-2020-12", false, "also write the schema as draft 2020-12 next to the draft-07 schema for helm (e.g. values.schema.2020-12.json)")
+	cmd.PersistentFlags().
+		String("shared-definitions", "", "write the objects which are identical in the schemas of several charts as definitions to this file (relative to the chart search root, e.g. common.schema.json)")
+	cmd.PersistentFlags().
+		String("shared-definitions-mode", schema.SharedDefinitionsInline, "how the schemas use the shared definitions: inline (copy the used definitions into each schema) or ref (reference the shared file, helm can't resolve references to other files of packaged charts)")
+	cmd.PersistentFlags().
+		String("ref-mode", schema.RefModeInlineFiles, "which $refs are inlined: inline-files (file, git and registry refs), inline-all (also urls) or keep-all (none)")
+	cmd.PersistentFlags().
+		String("lock-file", "", "record the digests of downloaded referenced schemas in this file (relative to the chart search root, e.g. helm-schema.lock) and verify them in later runs")
+	cmd.PersistentFlags().
+		String("lock-drift", reflock.DriftWarn, "how schemas which changed since they were locked are handled: warn, fail or update (record the new digests)")
+	cmd.PersistentFlags().
+		Int64("max-ref-size", util.DefaultMaxDownloadSize, "maximum size in bytes of downloaded referenced schemas")
+	cmd.PersistentFlags().
+		String("metrics-addr", "", "serve the cache hits, downloads and latencies of the referenced schemas at this address (e.g. :9090) while generating, in the text format of Prometheus at /metrics and as JSON at /metrics.json")
+	cmd.PersistentFlags().
+		Duration("timeout", 0, "stop generating the schemas after this duration, e.g. when downloading referenced schemas hangs (0 disables the timeout)")
+	cmd.PersistentFlags().
+		Bool("self-check", false, "validate the values file of each chart against its generated schema and fail if it doesn't validate")
+	cmd.PersistentFlags().
+		String("require-descriptions", "", "report the properties without description: all, annotated (the properties with a @schema annotation) or top-level")
+	cmd.PersistentFlags().
+		String("require-descriptions-severity", schema.SeverityWarning, "severity of the properties without description: warning or error (fails the generation)")
+	cmd.PersistentFlags().
+		Bool("report-type-widening", false, "warn about inferred types users likely need to be wider, e.g. versions inferred as numbers, empty strings which are unset with null and null values")
+	cmd.PersistentFlags().
+		Bool("fail-on-warn", false, "report the warnings of all categories (see --warnings-as-errors) as errors, which fail the generation")
+	cmd.PersistentFlags().
+		StringSlice("warnings-as-errors", []string{}, fmt.Sprintf("report the warnings of these categories as errors, which fail the generation (comma-separated list of %s)", strings.Join(schema.WarningCategories, ", ")))
+	cmd.PersistentFlags().
+		String("ref-cycles", schema.RefCyclesError, "how cycles of internal $refs without a property or item in between (e.g. allOf of merged definitions) are handled: error (report the chain of $refs) or expand (inline the $refs up to --ref-cycle-depth)")
+	cmd.PersistentFlags().
+		Int("ref-cycle-depth", 3, "how often the $refs of a cycle are inlined with --ref-cycles expand, the innermost schemas accept any value")
+	cmd.PersistentFlags().
+		Int("max-schema-size", 1<<20, "warn about schemas larger than this many bytes, helm stores the chart including its schema in the release, which is limited to 1 MiB (0 disables the check)")
+	cmd.PersistentFlags().
+		Bool("helm-compat-check", false, "report the constructs of the schema which helm ignores (e.g. keywords next to $ref in draft-07) and fail on the ones it rejects")
+	cmd.PersistentFlags().
+		Bool("helm-set-coercions", false, "allow the strings helm's --set parsing coerces into booleans and numbers (e.g. \"3\" for integers) and null for all values, so installs with --set-string or --set key=null don't fail the validation")
+	cmd.PersistentFlags().
+		Bool("allow-missing-values", false, "generate a minimal schema for charts without a values file or with an empty one (e.g. library or umbrella charts) instead of failing")
+	cmd.PersistentFlags().
+		Bool("relaxed-annotation-indentation", false, "expand tabs in the indentation of @schema blocks to two spaces and remove the indentation all their lines have in common instead of reporting the tabs")
+	cmd.PersistentFlags().
+		Bool("anchor-pattern-properties", false, "anchor the patterns of patternProperties which neither start with ^ nor end with $, so they must match the whole key")
+	cmd.PersistentFlags().
+		Bool("render-chart-defaults", false, "resolve templates of the chart metadata in defaults (e.g. {{ .Chart.AppVersion }}) like the templates of the chart would")
+	cmd.PersistentFlags().
+		Bool("report-pattern-matches", false, "log the keys which are not added to the properties because they match patternProperties and add x-matched-pattern to their parents")
+	cmd.PersistentFlags().
+		Bool("infer-constraints", false, "add numeric constraints to keys without annotations based on their names (e.g. 1-65535 for ports)")
+	cmd.PersistentFlags().
+		String("validation-profile", schema.ValidationProfileHelmPragmatic, fmt.Sprintf("rules the annotations are checked with, one of (%s)", strings.Join(schema.ValidationProfileNames(), ", ")))
+	cmd.PersistentFlags().
+		Bool("keep-custom-formats", false, "keep non-standard formats (semver, cron, k8s-name, k8s-quantity, duration-go) instead of converting them to patterns")
+	cmd.PersistentFlags().
+		Bool("normalize-descriptions", false, "trim descriptions and collapse repeated whitespace and empty lines")
+	cmd.PersistentFlags().
+		Bool("strip-markdown", false, "convert markdown in descriptions to plain text")
+	cmd.PersistentFlags().
+		Int("max-description-length", 0, "truncate descriptions longer than this many characters (0 disables truncation)")
+	cmd.PersistentFlags().
+		Bool("link-docs", false, "append a link to the documentation of a value (x-docs-url or a describedby, help or about link) to its description")
+	cmd.PersistentFlags().
+		String("title-style", schema.TitleStyleKey, "style of the titles generated from the keys, one of (key, human)")
+	cmd.PersistentFlags().
+		Bool("title-include-path", false, "include the path of the parent keys in generated titles")
+	cmd.PersistentFlags().
+		String("title-template", "", "go template for generated titles (available: .Key, .Path, .Parent, .Title)")
+	cmd.PersistentFlags().
+		StringArray("override", []string{}, "patch the generated schemas at a path with a schema, e.g. 'ingress.host={\"format\":\"hostname\"}' (can be repeated)")
+	cmd.PersistentFlags().
+		String("diagnostics-json", "", "also write warnings and errors as JSON lines (one object per finding, with the chart, file and line) to this file, - for stdout (not with --dry-run)")
+	cmd.PersistentFlags().
+		Bool("annotate-github", false, "also print warnings and errors as GitHub Actions workflow commands, annotating the lines of the values files")
 
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("HELM_SCHEMA")
@@ -87,3 +266,294 @@ func newCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Comma
 
 	return cmd, err
 }
+
+func newGraphCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:           "graph",
+		Short:         "print a graph of the charts, their dependencies and referenced schemas",
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("format", "dot", "output format of the graph, one of (dot, json)")
+
+	err := viper.BindPFlag("graph-format", cmd.Flags().Lookup("format"))
+
+	return cmd, err
+}
+
+func newExportCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("export (%s)", strings.Join(schema.ExportFormats, "|")),
+		Short: "export the schemas of the charts as go files (e.g. to bundle them in operators) or gzip-compressed",
+		Example: `  helm-schema export go --package schemas --output-dir internal/schemas
+  helm-schema export gzip --output-dir dist`,
+		Args:          cobra.ExactArgs(1),
+		ValidArgs:     schema.ExportFormats,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("output-dir", ".", "directory the exported files are written to (<chart>_schema.go or <chart>.schema.json.gz)")
+	cmd.Flags().String("package", "schemas", "package name of the go files")
+
+	if err := viper.BindPFlag("export-output-dir", cmd.Flags().Lookup("output-dir")); err != nil {
+		return cmd, err
+	}
+	err := viper.BindPFlag("export-package", cmd.Flags().Lookup("package"))
+
+	return cmd, err
+}
+
+func newPublishCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:           "publish",
+		Short:         "publish the schemas of the charts to the schema registry (PUT <registry>/schemas/<chart>/<version>.json)",
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	return cmd, nil
+}
+
+func newCompatCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "compat",
+		Short: "check that the schemas of the charts accept the values of their released versions (e.g. before releasing a new version)",
+		Example: `  helm-schema compat --repo https://charts.example.com --versions 3
+  helm-schema compat --repo oci://ghcr.io/org/charts`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("repo", "", "url of the chart repository (http or https, with an index.yaml) or OCI registry (oci://<registry>/<path>) the charts are released to")
+	cmd.Flags().Int("versions", 3, "number of released versions to check, the newest ones which are not newer than the chart")
+	cmd.Flags().String("username", "", "username for the chart repository")
+	cmd.Flags().String("password", "", "password for the chart repository (or HELM_SCHEMA_COMPAT_PASSWORD)")
+
+	if err := cmd.MarkFlagRequired("repo"); err != nil {
+		return cmd, err
+	}
+	for _, name := range []string{"repo", "versions", "username", "password"} {
+		if err := viper.BindPFlag("compat-"+name, cmd.Flags().Lookup(name)); err != nil {
+			return cmd, err
+		}
+	}
+
+	return cmd, nil
+}
+
+func newFixturesCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "fixtures",
+		Short: "write invalid values files (one per constraint of the schema) for tests checking that helm rejects them",
+		Example: `  helm-schema fixtures
+  helm-schema fixtures --output-dir ci/invalid`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("output-dir", "tests/invalid-values", "directory the fixtures are written to, relative to the chart directory")
+
+	err := viper.BindPFlag("fixtures-output-dir", cmd.Flags().Lookup("output-dir"))
+
+	return cmd, err
+}
+
+func newImportSchemaCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:           "import-schema",
+		Short:         "write the subschemas of keys from an existing jsonschema as @schema annotations into the values files",
+		Example:       `  helm-schema import-schema --from upstream.schema.json --keys ingress,resources`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("from", "", "jsonschema file to import the subschemas from")
+	cmd.Flags().StringSlice("keys", []string{}, "comma separated list of the dotted key paths to import (e.g. ingress,resources.limits)")
+
+	if err := cmd.MarkFlagRequired("from"); err != nil {
+		return cmd, err
+	}
+	if err := cmd.MarkFlagRequired("keys"); err != nil {
+		return cmd, err
+	}
+	if err := viper.BindPFlag("import-schema-from", cmd.Flags().Lookup("from")); err != nil {
+		return cmd, err
+	}
+	err := viper.BindPFlag("import-schema-keys", cmd.Flags().Lookup("keys"))
+
+	return cmd, err
+}
+
+func newMigrateCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "rename keys in the values files and regenerate the schemas, which still accept the old names as deprecated keys",
+		Example: `  helm-schema migrate --rename replicas=replicaCount
+  helm-schema migrate --rename image.tag=image.version,ingress.hostname=ingress.host`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().StringSlice("rename", []string{}, "comma separated list of the renames of the form <old path>=<new path> (e.g. image.tag=image.version)")
+
+	if err := cmd.MarkFlagRequired("rename"); err != nil {
+		return cmd, err
+	}
+	err := viper.BindPFlag("migrate-rename", cmd.Flags().Lookup("rename"))
+
+	return cmd, err
+}
+
+func newVendorCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "vendor",
+		Short: "download the schemas of the url, git and registry references of the charts into the chart directories and reference the vendored copies",
+		Example: `  helm-schema vendor
+  helm-schema vendor --dir schemas/external --dry-run`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("dir", schema.VendorDir, "directory relative to each chart directory the schemas are vendored to")
+
+	err := viper.BindPFlag("vendor-dir", cmd.Flags().Lookup("dir"))
+
+	return cmd, err
+}
+
+func newUpgradeValuesCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "upgrade-values",
+		Short: "upgrade a values file written for a previous version of a chart and report the values the new schema doesn't accept",
+		Example: `  helm-schema upgrade-values --values my-values.yaml --old-schema old.schema.json --new-schema values.schema.json
+  helm-schema upgrade-values --values my-values.yaml --old-schema old.schema.json --new-schema values.schema.json --output my-values.yaml`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("values", "", "values file to upgrade (e.g. the overrides of a release)")
+	cmd.Flags().String("old-schema", "", "schema of the version of the chart the values file was written for")
+	cmd.Flags().String("new-schema", "", "schema of the version of the chart to upgrade to")
+	cmd.Flags().String("output", "", "file the upgraded values are written to (printed if empty)")
+
+	for _, name := range []string{"values", "old-schema", "new-schema"} {
+		if err := cmd.MarkFlagRequired(name); err != nil {
+			return cmd, err
+		}
+	}
+	for _, name := range []string{"values", "old-schema", "new-schema", "output"} {
+		if err := viper.BindPFlag("upgrade-values-"+name, cmd.Flags().Lookup(name)); err != nil {
+			return cmd, err
+		}
+	}
+
+	return cmd, nil
+}
+
+func newAnnotationCatalogueCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "annotation-catalogue",
+		Short: "print a jsonschema of the @schema annotations, which documents every keyword with its type (e.g. for editors and language servers)",
+		Example: `  helm-schema annotation-catalogue
+  helm-schema annotation-catalogue --output .vscode/helm-schema-annotation.schema.json`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().String("output", "", "file the catalogue is written to (printed if empty)")
+
+	err := viper.BindPFlag("annotation-catalogue-output", cmd.Flags().Lookup("output"))
+
+	return cmd, err
+}
+
+func newBenchCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "measure the schema generation of a synthetic chart (e.g. to catch performance regressions)",
+		Example: `  helm-schema bench --keys 10000 --depth 5 --refs 500 --max-duration 5s
+  helm-schema bench --keys 1000 --output-dir /tmp/synthetic`,
+		Args:          cobra.NoArgs,
+		RunE:          run,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().Int("keys", 10000, "number of leaf keys of the synthetic values file")
+	cmd.Flags().Int("depth", 2, "number of maps between the root and the leaf keys")
+	cmd.Flags().Int("refs", 0, "number of leaf keys which are annotated with a $ref to --ref-url")
+	cmd.Flags().String("ref-url", schema.DefaultSyntheticRefURL, "url of the schema referenced by the synthetic values")
+	cmd.Flags().Int("iterations", 3, "number of times the schema is generated")
+	cmd.Flags().String("output-dir", "", "directory to write the synthetic chart to (a temporary directory is used and removed if empty)")
+	cmd.Flags().Duration("max-duration", 0, "fail if the average generation takes longer (0 disables the check)")
+
+	for _, name := range []string{"keys", "depth", "refs", "ref-url", "iterations", "output-dir", "max-duration"} {
+		if err := viper.BindPFlag("bench-"+name, cmd.Flags().Lookup(name)); err != nil {
+			return cmd, err
+		}
+	}
+
+	return cmd, nil
+}
+
+func newCheckValuesCommand(run func(cmd *cobra.Command, args []string) error) (*cobra.Command, error) {
+	// the command parses its flags itself, their shorthands are used by the flags of the generation (e.g. -s and -f)
+	flags := pflag.NewFlagSet("check-values", pflag.ContinueOnError)
+	flags.StringP("schema", "s", schema.DefaultOutputFile, "jsonschema file the values are checked against")
+	flags.StringSliceP("values", "f", []string{}, "values files to check, merged in order like helm merges several --values flags and coalesced with the values.yaml next to the schema (can be repeated)")
+	flags.StringP("output", "o", "pretty", "output format of the errors, one of (pretty, json)")
+
+	cmd := &cobra.Command{
+		Use:   "check-values",
+		Short: "check values files (e.g. the overrides of a release) against a schema before installing the chart, no chart required",
+		Example: `  helm-schema check-values -s values.schema.json -f my-overrides.yaml
+  helm-schema check-values -s values.schema.json -f values.yaml -f my-overrides.yaml --output json`,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.Parse(args); err != nil {
+				if errors.Is(err, pflag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+			return run(cmd, flags.Args())
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	usage := func() {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n\nUsage:\n  %s [flags]\n\nExamples:\n%s\n\nFlags:\n%s",
+			cmd.Short, cmd.CommandPath(), cmd.Example, flags.FlagUsages())
+	}
+	flags.Usage = usage
+	cmd.SetHelpFunc(func(*cobra.Command, []string) { usage() })
+
+	for _, name := range []string{"schema", "values", "output"} {
+		if err := viper.BindPFlag("check-values-"+name, flags.Lookup(name)); err != nil {
+			return cmd, err
+		}
+	}
+
+	return cmd, nil
+}
@@ -1,18 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dadav/helm-schema/pkg/chart"
 	"github.com/dadav/helm-schema/pkg/chart/searching"
+	"github.com/dadav/helm-schema/pkg/chartrepo"
+	"github.com/dadav/helm-schema/pkg/gitref"
+	"github.com/dadav/helm-schema/pkg/reflock"
+	"github.com/dadav/helm-schema/pkg/refmetrics"
+	"github.com/dadav/helm-schema/pkg/registry"
 	"github.com/dadav/helm-schema/pkg/schema"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"github.com/dadav/helm-schema/pkg/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -35,54 +52,192 @@ func getDependencyNames(dependencies []*chart.Dependency, dependenciesFilterMap
 	return depNames
 }
 
-func exec(cmd *cobra.Command, _ []string) error {
-	configureLogging()
+// compareReadme compares the schema of the result with the helm-docs values table in the readme.
+// The values of dependencies don't need to be documented in the readme of the parent chart.
+func compareReadme(result *schema.Result, readmePath string, dependenciesFilterMap map[string]bool) ([]schema.ReadmeMismatch, error) {
+	content, err := os.ReadFile(readmePath)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := schema.ParseHelmDocsTable(content)
+	if err != nil {
+		return nil, err
+	}
+	return result.Schema.CompareReadme(rows, getDependencyNames(result.Chart.Dependencies, dependenciesFilterMap)...), nil
+}
+
+// newRegistryClient returns the client of the configured schema registry or nil
+func newRegistryClient() (*registry.Client, error) {
+	registryURL := viper.GetString("schema-registry")
+	if registryURL == "" {
+		return nil, nil
+	}
+	return registry.NewClient(registryURL, viper.GetString("schema-registry-token"))
+}
+
+// newGenerationPolicy returns the policy of the schema generation, its keys are
+// the flags and the keys of the config file
+func newGenerationPolicy() (schema.GenerationPolicy, error) {
+	var policy schema.GenerationPolicy
+	if err := viper.Unmarshal(&policy); err != nil {
+		return policy, err
+	}
+	return policy, policy.Validate()
+}
+
+// newURLPolicy returns the policy for the urls referenced schemas are downloaded from
+func newURLPolicy() (*urlpolicy.Policy, error) {
+	policy := &urlpolicy.Policy{
+		AllowedSchemes: viper.GetStringSlice("allowed-ref-schemes"),
+		AllowedHosts:   viper.GetStringSlice("allowed-ref-hosts"),
+		DeniedHosts:    viper.GetStringSlice("denied-ref-hosts"),
+		AllowLinkLocal: viper.GetBool("allow-link-local-refs"),
+	}
+	return policy, policy.Validate()
+}
 
-	var skipAutoGeneration, valueFileNames []string
+// newRefLock returns the lock of the referenced schemas or nil, if no lock file is configured.
+// A relative path of the lock file is relative to the chart search root.
+func newRefLock() (*reflock.Lock, error) {
+	lockFile := viper.GetString("lock-file")
+	if lockFile == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(lockFile) {
+		lockFile = filepath.Join(viper.GetString("chart-search-root"), lockFile)
+	}
+	return reflock.Load(lockFile, viper.GetString("lock-drift"))
+}
+
+// logRefMetrics logs the cache hits, the downloads and the latencies of the referenced schemas
+func logRefMetrics(metrics *refmetrics.Metrics) {
+	snapshot := metrics.Snapshot()
+	for _, source := range slices.Sorted(maps.Keys(snapshot.Sources)) {
+		stats := snapshot.Sources[source]
+		log.Debugf("Referenced schemas (%s): %d cache hits, %d downloads, %d bytes downloaded", source, stats.Hits, stats.Misses, stats.BytesDownloaded)
+	}
+	for _, host := range slices.Sorted(maps.Keys(snapshot.Hosts)) {
+		stats := snapshot.Hosts[host]
+		log.Debugf("Downloads from %s: %d (%d failed), %.3fs in total, %.3fs at most", host, stats.Downloads, stats.Errors, stats.TotalSeconds, stats.MaxSeconds)
+	}
+}
+
+// serveRefMetrics serves the metrics of the referenced schemas at addr while the schemas are
+// generated: in the text format of Prometheus at /metrics and as JSON at /metrics.json. The
+// returned function stops the server.
+func serveRefMetrics(addr string, metrics *refmetrics.Metrics) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not serve the metrics at %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/metrics.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, metrics.String())
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Serving the metrics failed: %v", err)
+		}
+	}()
+	log.Infof("Serving the metrics of the referenced schemas at http://%s/metrics", listener.Addr())
+	return func() { _ = server.Close() }, nil
+}
 
+// generateResults searches for charts below the chart search root and generates
+// the schema of each chart. The returned temp directory contains the extracted
+// chart archives and must be removed by the caller.
+func generateResults(dependenciesFilterMap map[string]bool) ([]*schema.Result, string, error) {
 	chartSearchRoot := viper.GetString("chart-search-root")
 	dryRun := viper.GetBool("dry-run")
-	noDeps := viper.GetBool("no-dependencies")
-	addSchemaReference := viper.GetBool("add-schema-reference")
-	keepFullComment := viper.GetBool("keep-full-comment")
-	helmDocsCompatibilityMode := viper.GetBool("helm-docs-compatibility-mode")
-	uncomment := viper.GetBool("uncomment")
 	outFile := viper.GetString("output-file")
-	dontRemoveHelmDocsPrefix := viper.GetBool("dont-strip-helm-docs-prefix")
-	appendNewline := viper.GetBool("append-newline")
-	dependenciesFilter := viper.GetStringSlice("dependencies-filter")
-	dependenciesFilterMap := make(map[string]bool)
-	dontAddGlobal := viper.GetBool("dont-add-global")
-	skipDepsSchemaValidation := viper.GetBool("skip-dependencies-schema-validation")
-	allowCircularDeps := viper.GetBool("allow-circular-dependencies")
-	for _, dep := range dependenciesFilter {
-		dependenciesFilterMap[dep] = true
-	}
-	if err := viper.UnmarshalKey("value-files", &valueFileNames); err != nil {
-		return err
+	workersCount := runtime.NumCPU() * 2
+
+	generationPolicy, err := newGenerationPolicy()
+	if err != nil {
+		return nil, "", err
 	}
-	if err := viper.UnmarshalKey("skip-auto-generation", &skipAutoGeneration); err != nil {
-		return err
+
+	// viper lowercases all keys, so the schemas of the presets are strings
+	for name, preset := range viper.GetStringMapString("presets") {
+		if err := schema.RegisterPreset(name, []byte(preset)); err != nil {
+			return nil, "", err
+		}
 	}
-	workersCount := runtime.NumCPU() * 2
 
-	skipConfig, err := schema.NewSkipAutoGenerationConfig(skipAutoGeneration)
+	policy, err := newURLPolicy()
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+	client, err := newRegistryClient()
+	if err != nil {
+		return nil, "", err
+	}
+	metrics := refmetrics.New()
+	if addr := viper.GetString("metrics-addr"); addr != "" {
+		stopMetrics, err := serveRefMetrics(addr, metrics)
+		if err != nil {
+			return nil, "", err
+		}
+		defer stopMetrics()
+	}
+	if client != nil {
+		client.SetPolicy(policy)
+		client.SetMaxSize(viper.GetInt64("max-ref-size"))
+		client.SetMetrics(metrics)
+	}
+	schema.UseRegistry(client)
+	fetcher := gitref.NewFetcher(viper.GetString("git-cache-dir"))
+	fetcher.SetPolicy(policy)
+	fetcher.SetMaxSize(viper.GetInt64("max-ref-size"))
+	fetcher.SetMetrics(metrics)
+	schema.UseGitFetcher(fetcher)
+	urlFetcher := urlref.NewFetcher()
+	urlFetcher.SetPolicy(policy)
+	urlFetcher.SetMaxSize(viper.GetInt64("max-ref-size"))
+	urlFetcher.SetMetrics(metrics)
+	schema.UseURLFetcher(urlFetcher)
+	lock, err := newRefLock()
+	if err != nil {
+		return nil, "", err
 	}
+	schema.UseRefLock(lock)
 
-	queue := make(chan string)
+	// the generation stops on an interrupt or when the timeout is exceeded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	queue := make(chan chart.Chart)
 	resultsChan := make(chan schema.Result)
 	results := []*schema.Result{}
 	errs := make(chan error)
 	done := make(chan struct{})
 
-	tempDir := searching.SearchArchivesOpenTemp(chartSearchRoot, errs)
-	if tempDir != "" {
-		defer os.RemoveAll(tempDir)
-	}
+	tempDir, archiveOrigins := searching.ExtractArchives(chartSearchRoot, errs)
 
-	go searching.SearchFiles(chartSearchRoot, chartSearchRoot, "Chart.yaml", dependenciesFilterMap, queue, errs)
+	charts, err := searching.SearchCharts(ctx, chartSearchRoot, dependenciesFilterMap)
+	if err != nil {
+		return nil, tempDir, fmt.Errorf("could not load the charts below %s: %w", chartSearchRoot, err)
+	}
+	searching.ArchiveDirs(charts, archiveOrigins)
+	go func() {
+		defer close(queue)
+		for _, c := range charts {
+			select {
+			case <-ctx.Done():
+				return
+			case queue <- c:
+			}
+		}
+	}()
 
 	wg := sync.WaitGroup{}
 	go func() {
@@ -95,20 +250,7 @@ func exec(cmd *cobra.Command, _ []string) error {
 
 		go func() {
 			defer wg.Done()
-			schema.Worker(
-				dryRun,
-				uncomment,
-				addSchemaReference,
-				keepFullComment,
-				helmDocsCompatibilityMode,
-				dontRemoveHelmDocsPrefix,
-				dontAddGlobal,
-				valueFileNames,
-				skipConfig,
-				outFile,
-				queue,
-				resultsChan,
-			)
+			schema.Worker(ctx, dryRun, generationPolicy, outFile, queue, resultsChan)
 		}()
 	}
 
@@ -125,260 +267,1295 @@ loop:
 		}
 	}
 
-	if !noDeps {
-		results, err = schema.TopoSort(results, allowCircularDeps)
-		if err != nil {
-			if _, ok := err.(*schema.CircularError); ok {
-				log.Errorf("Error while sorting results: %s", err)
-				return err
-			} else {
-				log.Warnf("Could not sort results: %s", err)
-			}
+	if err := ctx.Err(); err != nil {
+		return nil, tempDir, fmt.Errorf("generating the schemas was stopped: %w", err)
+	}
+	logRefMetrics(metrics)
+	if lock != nil && !dryRun && !viper.GetBool("check") {
+		if err := lock.Save(); err != nil {
+			return nil, tempDir, fmt.Errorf("could not write the lock file: %w", err)
 		}
 	}
+	return results, tempDir, nil
+}
 
-	conditionsToPatch := make(map[string][]string)
-	if !noDeps {
-		for _, result := range results {
-			if len(result.Errors) > 0 {
-				continue
-			}
-			for _, dep := range result.Chart.Dependencies {
-				if len(dependenciesFilterMap) > 0 && !dependenciesFilterMap[dep.Name] {
-					continue
-				}
+// schemaWriter is called with the final schema of each chart and the path of its schema file
+type schemaWriter func(result *schema.Result, outPath string) error
 
-				if dep.Condition != "" {
-					conditionKeys := strings.Split(dep.Condition, ".")
-					conditionsToPatch[conditionKeys[0]] = conditionKeys[1:]
-				}
-			}
-		}
+func exec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
 	}
+	configureLogging()
 
-	chartNameToResult := make(map[string]*schema.Result)
-	foundErrors := false
+	dryRun := viper.GetBool("dry-run")
+	check := viper.GetBool("check")
+	appendNewline := viper.GetBool("append-newline")
+	draft202012 := viper.GetBool("draft-2020-12")
+	provenance := viper.GetBool("provenance")
+	flagsHash := ""
+	if provenance {
+		var err error
+		if flagsHash, err = provenanceFlagsHash(); err != nil {
+			return err
+		}
+	}
+	if check {
+		// the check mode must not modify any files
+		viper.Set("add-schema-reference", false)
+	}
+	if (check || dryRun) && viper.GetBool("fix") {
+		// neither the check mode nor dry runs modify the values files
+		log.Info("Ignoring --fix, the values files are not modified in this mode")
+		viper.Set("fix", false)
+	}
+	sharedDefinitions := viper.GetString("shared-definitions")
+	sharedDefinitionsMode := viper.GetString("shared-definitions-mode")
+	if !slices.Contains(schema.SharedDefinitionsModes, sharedDefinitionsMode) {
+		return fmt.Errorf("unsupported --shared-definitions-mode %s (possible: %s)", sharedDefinitionsMode, strings.Join(schema.SharedDefinitionsModes, ", "))
+	}
+	writtenFiles := make(map[string]string)
 
-	for _, result := range results {
-		if len(result.Errors) > 0 {
-			foundErrors = true
-			if result.Chart != nil {
-				log.Errorf(
-					"Found %d errors while processing the chart %s (%s)",
-					len(result.Errors),
-					result.Chart.Name,
-					result.ChartPath,
-				)
-			} else {
-				log.Errorf("Found %d errors while processing the chart %s", len(result.Errors), result.ChartPath)
-			}
-			for _, err := range result.Errors {
-				log.Error(err)
+	write := func(result *schema.Result, outPath string) error {
+		if provenance {
+			valuesDigest, err := result.ValuesDigest()
+			if err != nil {
+				return err
 			}
-			continue
+			result.Schema.SetProvenance(schema.Provenance{
+				Tool:         "helm-schema",
+				Version:      version,
+				FlagsHash:    flagsHash,
+				ValuesDigest: valuesDigest,
+			})
 		}
 
-		log.Debugf("Processing result for chart: %s (%s)", result.Chart.Name, result.ChartPath)
-		if !noDeps {
-			chartNameToResult[result.Chart.Name] = result
-			log.Debugf("Stored chart %s in chartNameToResult", result.Chart.Name)
-
-			if patch, ok := conditionsToPatch[result.Chart.Name]; ok {
-				schemaToPatch := &result.Schema
-				lastIndex := len(patch) - 1
-				for i, key := range patch {
-					if alreadyPresentSchema, ok := schemaToPatch.Properties[key]; !ok {
-						log.Debugf(
-							"Patching conditional field \"%s\" into schema of chart %s",
-							key,
-							result.Chart.Name,
-						)
-						if i == lastIndex {
-							schemaToPatch.Properties[key] = &schema.Schema{
-								Type:        []string{"boolean"},
-								Title:       key,
-								Description: "Conditional property used in parent chart",
-							}
-						} else {
-							schemaToPatch.Properties[key] = &schema.Schema{Type: []string{"object"}, Title: key}
-							schemaToPatch = schemaToPatch.Properties[key]
-						}
-					} else {
-						schemaToPatch = alreadyPresentSchema
-					}
-				}
-			}
+		schemas := map[string]*schema.Schema{outPath: &result.Schema}
+		outPaths := []string{outPath}
+		if draft202012 {
+			// both drafts are translated from the same generated schema
+			draftPath := schema.Draft202012OutputPath(outPath)
+			schemas[draftPath] = result.Schema.ToDraft202012()
+			outPaths = append(outPaths, draftPath)
+		}
 
-			for _, dep := range result.Chart.Dependencies {
-				if len(dependenciesFilterMap) > 0 && !dependenciesFilterMap[dep.Name] {
-					continue
+		for _, path := range outPaths {
+			if check {
+				if err := checkSchemaFile(path, schemas[path], appendNewline, provenance); err != nil {
+					return fmt.Errorf("chart %s: %w", result.Chart.Name, err)
 				}
-
-				if dep.Name != "" {
-					// Determine the property name to use (alias or name)
-					propName := dep.Name
-					if dep.Alias != "" {
-						propName = dep.Alias
-					}
-
-					// Check if the property already exists with custom schema annotations
-					// HasData indicates that the property has custom schema data from annotations (like $ref)
-					if existingProp, exists := result.Schema.Properties[propName]; exists && existingProp.HasData {
-						log.Debugf("Property %s in chart %s has custom schema annotations, skipping dependency schema merge", propName, result.Chart.Name)
-						continue
-					}
-
-					if dependencyResult, ok := chartNameToResult[dep.Name]; ok {
-						log.Debugf(
-							"Found chart of dependency %s (%s)",
-							dependencyResult.Chart.Name,
-							dependencyResult.ChartPath,
-						)
-
-						// Merge $defs from dependency into parent chart's root-level $defs
-						if dependencyResult.Schema.Defs != nil && len(dependencyResult.Schema.Defs) > 0 {
-							if result.Schema.Defs == nil {
-								result.Schema.Defs = make(map[string]*schema.Schema)
-							}
-							for defName, defSchema := range dependencyResult.Schema.Defs {
-								// Check for conflicts and warn if a definition already exists
-								if existingDef, exists := result.Schema.Defs[defName]; exists {
-									log.Warnf("Definition %s from dependency %s conflicts with existing definition in parent chart %s, keeping parent's definition", defName, dep.Name, result.Chart.Name)
-									_ = existingDef // avoid unused variable warning
-								} else {
-									log.Debugf("Merging $defs entry %s from dependency %s into parent chart %s", defName, dep.Name, result.Chart.Name)
-									result.Schema.Defs[defName] = defSchema
-								}
-							}
-						}
-
-						// Also merge definitions (JSON Schema Draft-04/06/07 style)
-						if dependencyResult.Schema.Definitions != nil && len(dependencyResult.Schema.Definitions) > 0 {
-							if result.Schema.Definitions == nil {
-								result.Schema.Definitions = make(map[string]*schema.Schema)
-							}
-							for defName, defSchema := range dependencyResult.Schema.Definitions {
-								// Check for conflicts and warn if a definition already exists
-								if existingDef, exists := result.Schema.Definitions[defName]; exists {
-									log.Warnf("Definition %s from dependency %s conflicts with existing definition in parent chart %s, keeping parent's definition", defName, dep.Name, result.Chart.Name)
-									_ = existingDef // avoid unused variable warning
-								} else {
-									log.Debugf("Merging definitions entry %s from dependency %s into parent chart %s", defName, dep.Name, result.Chart.Name)
-									result.Schema.Definitions[defName] = defSchema
-								}
-							}
-						}
-
-						// Check if this is a library chart
-						if dependencyResult.Chart.Type == "library" {
-							// For library charts, merge properties directly into parent schema
-							log.Debugf("Merging library chart %s properties into parent chart %s at top level", dep.Name, result.Chart.Name)
-							for propName, propSchema := range dependencyResult.Schema.Properties {
-								// Skip the global property as it's already in the parent
-								if propName == "global" {
-									continue
-								}
-								// Only add if the property doesn't already exist in parent
-								if _, exists := result.Schema.Properties[propName]; !exists {
-									result.Schema.Properties[propName] = propSchema
-								} else {
-									log.Warnf("Property %s from library chart %s already exists in parent chart %s, skipping", propName, dep.Name, result.Chart.Name)
-								}
-							}
-						} else {
-							// For non-library charts, nest under dependency name
-							// Copy the entire dependency schema, not just properties
-							depSchema := dependencyResult.Schema
-
-							// Override top-level fields for nesting
-							depSchema.Title = dep.Name
-							if dependencyResult.Chart.Description != "" {
-								depSchema.Description = dependencyResult.Chart.Description
-							}
-							// Ensure type is object for nesting
-							if len(depSchema.Type) == 0 {
-								depSchema.Type = []string{"object"}
-							}
-
-							depSchema.DisableRequiredProperties()
-
-							if dep.Alias != "" {
-								result.Schema.Properties[dep.Alias] = &depSchema
-							} else {
-								result.Schema.Properties[dep.Name] = &depSchema
-							}
-						}
-
-					} else {
-						log.Warnf("Dependency (%s->%s) specified but no schema found. If you want to create jsonschemas for external dependencies, you need to run helm dep up", result.Chart.Name, dep.Name)
-					}
+				continue
+			}
+			if dryRun {
+				if path == outPath {
+					log.Infof("Printing jsonschema for %s chart (%s)", result.Chart.Name, result.ChartPath)
 				} else {
-					log.Warnf("Dependency without name found (checkout %s).", result.ChartPath)
+					log.Infof("Printing draft 2020-12 jsonschema for %s chart (%s)", result.Chart.Name, result.ChartPath)
+				}
+				if err := writeSchema(os.Stdout, schemas[path], true); err != nil {
+					return err
 				}
+				continue
 			}
-		}
 
-		// Handle skip-dependencies-schema-validation flag
-		if skipDepsSchemaValidation && !noDeps {
-			// Collect dependency names using helper function
-			depNames := getDependencyNames(result.Chart.Dependencies, dependenciesFilterMap)
-
-			// Remove dependency names from required properties
-			oldRequired := result.Schema.Required.Strings
-			var newRequired []string
-			for _, n := range oldRequired {
-				if !slices.Contains(depNames, n) {
-					newRequired = append(newRequired, n)
-				}
+			if otherChart, ok := writtenFiles[path]; ok {
+				return fmt.Errorf("the schemas of the charts %s and %s would both be written to %s", otherChart, result.Chart.Name, path)
 			}
-			result.Schema.Required.Strings = newRequired
+			writtenFiles[path] = result.Chart.Name
 
-			// Set additionalProperties to true for dependency schemas
-			for _, depName := range depNames {
-				if prop, ok := result.Schema.Properties[depName]; ok {
-					log.Debugf("Setting additionalProperties to true for dependency %s in chart %s", depName, result.Chart.Name)
-					prop.AdditionalProperties = true
-				}
+			if err := writeSchemaFile(path, schemas[path], appendNewline); err != nil {
+				return err
 			}
 		}
+		return nil
+	}
 
-		jsonStr, err := result.Schema.ToJson()
+	if sharedDefinitions == "" {
+		return generateSchemas(write)
+	}
+
+	// the shared objects are only known once all schemas are generated
+	type pendingSchema struct {
+		result  *schema.Result
+		outPath string
+	}
+	var pending []pendingSchema
+	generateErr := generateSchemas(func(result *schema.Result, outPath string) error {
+		pending = append(pending, pendingSchema{result: result, outPath: outPath})
+		return nil
+	})
+
+	commonPath := sharedDefinitions
+	if !filepath.IsAbs(commonPath) {
+		commonPath = filepath.Join(viper.GetString("chart-search-root"), commonPath)
+	}
+	commonPath, err := filepath.Abs(commonPath)
+	if err != nil {
+		return err
+	}
+	schemas := make([]*schema.Schema, 0, len(pending))
+	commonRefs := make([]string, 0, len(pending))
+	for _, p := range pending {
+		outPath, err := filepath.Abs(p.outPath)
+		if err != nil {
+			return err
+		}
+		ref, err := filepath.Rel(filepath.Dir(outPath), commonPath)
 		if err != nil {
+			return err
+		}
+		schemas = append(schemas, &p.result.Schema)
+		commonRefs = append(commonRefs, filepath.ToSlash(ref))
+	}
+	common, err := schema.ShareDefinitions(schemas, commonRefs, sharedDefinitionsMode)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Found %d objects shared by several charts", len(common.Definitions))
+
+	if check {
+		if err := checkSchemaFile(commonPath, common, appendNewline, false); err != nil {
+			return err
+		}
+	} else if dryRun {
+		log.Infof("Printing shared definitions (%s)", commonPath)
+		if err := writeSchema(os.Stdout, common, true); err != nil {
+			return err
+		}
+	} else if err := writeSchemaFile(commonPath, common, appendNewline); err != nil {
+		return err
+	}
+
+	foundErrors := false
+	for _, p := range pending {
+		if err := write(p.result, p.outPath); err != nil {
 			log.Error(err)
-			continue
+			foundErrors = true
+		}
+	}
+	if generateErr != nil {
+		return generateErr
+	}
+	if foundErrors {
+		return errors.New("some errors were found")
+	}
+	return nil
+}
+
+// writeSchemaFile writes the schema to the file at path, the directory is created if needed
+func writeSchemaFile(path string, s *schema.Schema, newline bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeSchema(f, s, newline); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkSchemaFile checks that the schema file at path is the same as the generated schema.
+// If skipUngenerated is set, files without provenance (e.g. hand-written schemas) are skipped.
+func checkSchemaFile(path string, s *schema.Schema, newline, skipUngenerated bool) error {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("the schema %s doesn't exist", path)
+		}
+		return err
+	}
+	if skipUngenerated {
+		loaded, err := schema.Load(bytes.NewReader(existing))
+		if err != nil {
+			return fmt.Errorf("could not parse the schema %s: %w", path, err)
+		}
+		if provenance, err := loaded.GetProvenance(); err != nil || provenance == nil {
+			log.Infof("Skipping the schema %s, it wasn't generated by helm-schema", path)
+			return nil
 		}
+	}
+
+	var generated bytes.Buffer
+	if err := writeSchema(&generated, s, newline); err != nil {
+		return err
+	}
+	if !bytes.Equal(existing, generated.Bytes()) {
+		return fmt.Errorf("the schema %s is not up to date, run helm-schema to generate it", path)
+	}
+	log.Debugf("The schema %s is up to date", path)
+	return nil
+}
+
+// provenanceIgnoredSettings don't change the generated schemas (or are secret), so they're
+// not part of the flags hash of the provenance
+var provenanceIgnoredSettings = []string{
+	"add-schema-reference",
+	"annotate-github",
+	"chart-search-root",
+	"check",
+	"config",
+	"diagnostics-json",
+	"dry-run",
+	"fix",
+	"log-level",
+	"schema-registry-token",
+	"timeout",
+}
+
+// provenanceFlagsHash returns the hash of the flags and the config file, which is embedded in
+// the provenance of the schemas
+func provenanceFlagsHash() (string, error) {
+	settings := viper.AllSettings()
+	for _, key := range provenanceIgnoredSettings {
+		delete(settings, key)
+	}
+	return schema.SettingsHash(settings)
+}
 
-		if appendNewline {
-			jsonStr = append(jsonStr, '\n')
+// writeSchema streams the indented json of the schema to w, so large schemas
+// don't have to be kept in memory as a whole
+func writeSchema(w io.Writer, s *schema.Schema, newline bool) error {
+	if err := s.WriteJSON(w); err != nil {
+		return err
+	}
+	if newline {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if dryRun {
-			log.Infof("Printing jsonschema for %s chart (%s)", result.Chart.Name, result.ChartPath)
-			if appendNewline {
-				fmt.Printf("%s", jsonStr)
+// generateSchemas generates the schemas of all charts, merges the schemas of their
+// dependencies and passes the final schemas to write (see schemaPipeline)
+func generateSchemas(write schemaWriter) error {
+	opts, err := readGenerationOptions()
+	if err != nil {
+		return err
+	}
+
+	results, tempDir, err := generateResults(opts.dependenciesFilter)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !opts.noDeps {
+		results, err = schema.TopoSort(results, opts.allowCircularDeps)
+		if err != nil {
+			if _, ok := err.(*schema.CircularError); ok {
+				log.Errorf("Error while sorting results: %s", err)
+				return err
 			} else {
-				fmt.Printf("%s\n", jsonStr)
-			}
-		} else {
-			chartBasePath := filepath.Dir(result.ChartPath)
-			if err := os.WriteFile(filepath.Join(chartBasePath, outFile), jsonStr, 0o644); err != nil {
-				errs <- err
-				continue
+				log.Warnf("Could not sort results: %s", err)
 			}
 		}
 	}
+
+	pipeline := newSchemaPipeline(opts, write, results)
+	foundErrors := false
+	for _, result := range results {
+		if !pipeline.process(result) {
+			foundErrors = true
+		}
+	}
+	for i, override := range opts.overrides {
+		if !pipeline.appliedOverrides[i] {
+			log.Errorf("The override of %s matches no key in the schemas of the charts", override.Path)
+			foundErrors = true
+		}
+	}
 	if foundErrors {
 		return errors.New("some errors were found")
 	}
 	return nil
 }
 
-func main() {
-	command, err := newCommand(exec)
+// reportWarning logs the warning, as error if its category is promoted to errors by the policy.
+// Warnings without a location are reported for the schema of the chart. It returns if it's an error.
+func reportWarning(chartLog *log.Entry, policy schema.WarningPolicy, chartName string, warning schema.Warning) bool {
+	message := warning.String()
+	if warning.Line == 0 {
+		message = fmt.Sprintf("The schema of chart %s: %s", chartName, warning)
+	}
+	if policy.IsError(warning.Category) {
+		chartLog.Error(message)
+		return true
+	}
+	chartLog.Warn(message)
+	return false
+}
+
+func graphExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	// the graph command must not modify any files
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	dependenciesFilterMap := make(map[string]bool)
+	for _, dep := range viper.GetStringSlice("dependencies-filter") {
+		dependenciesFilterMap[dep] = true
+	}
+
+	results, tempDir, err := generateResults(dependenciesFilterMap)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		for _, err := range result.Errors {
+			log.Warnf("Error while processing the chart %s: %s", result.ChartPath, err)
+		}
+		for _, warning := range result.Warnings {
+			log.Warn(warning)
+		}
+	}
+
+	graph := schema.BuildGraph(results)
+
+	switch format := viper.GetString("graph-format"); format {
+	case "dot":
+		fmt.Printf("%s", graph.ToDot())
+	case "json":
+		jsonStr, err := graph.ToJson()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", jsonStr)
+	default:
+		return fmt.Errorf("unsupported graph format: %s", format)
+	}
+
+	return nil
+}
+
+func exportExec(cmd *cobra.Command, args []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	format := args[0]
+	if !slices.Contains(schema.ExportFormats, format) {
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	// the export command must only create the exported files
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	dryRun := viper.GetBool("dry-run")
+	outputDir := viper.GetString("export-output-dir")
+	packageName := viper.GetString("export-package")
+	writtenFiles := make(map[string]string)
+
+	return generateSchemas(func(result *schema.Result, _ string) error {
+		if result.Secondary {
+			log.Debugf("Not exporting the schema of %s, only the first values file of chart %s is exported", result.ValuesPath, result.Chart.Name)
+			return nil
+		}
+
+		jsonStr, err := result.Schema.ToJson()
+		if err != nil {
+			return err
+		}
+
+		var path string
+		var content []byte
+		switch format {
+		case schema.ExportFormatGo:
+			path = filepath.Join(outputDir, result.Chart.Name+"_schema.go")
+			content, err = schema.GoFile(jsonStr, packageName, result.Chart.Name)
+		case schema.ExportFormatGzip:
+			path = filepath.Join(outputDir, result.Chart.Name+".schema.json.gz")
+			content, err = schema.Compress(jsonStr)
+		}
+		if err != nil {
+			return fmt.Errorf("could not export the schema of chart %s: %w", result.Chart.Name, err)
+		}
+
+		if dryRun {
+			log.Infof("Printing exported jsonschema for %s chart (%s)", result.Chart.Name, result.ChartPath)
+			_, err := os.Stdout.Write(content)
+			return err
+		}
+
+		if otherChart, ok := writtenFiles[path]; ok {
+			return fmt.Errorf("the schemas of the charts %s and %s would both be exported to %s", otherChart, result.Chart.Name, path)
+		}
+		writtenFiles[path] = result.Chart.Name
+
+		if err := os.MkdirAll(outputDir, 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, content, 0o644)
+	})
+}
+
+func publishExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	client, err := newRegistryClient()
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return errors.New("no schema registry configured (see --schema-registry)")
+	}
+
+	// the publish command must not modify any files
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	dryRun := viper.GetBool("dry-run")
+
+	return generateSchemas(func(result *schema.Result, _ string) error {
+		if result.Secondary {
+			log.Debugf("Not publishing the schema of %s, only the first values file of chart %s is published", result.ValuesPath, result.Chart.Name)
+			return nil
+		}
+
+		if result.Chart.Version == "" {
+			return fmt.Errorf("could not publish the schema of chart %s: the chart has no version", result.Chart.Name)
+		}
+
+		schemaURL := client.SchemaURL(result.Chart.Name, result.Chart.Version)
+		if dryRun {
+			log.Infof("Would publish the schema of chart %s to %s", result.Chart.Name, schemaURL)
+			return nil
+		}
+
+		jsonStr, err := result.Schema.ToJson()
+		if err != nil {
+			return err
+		}
+		if err := client.Push(result.Chart.Name, result.Chart.Version, jsonStr); err != nil {
+			return err
+		}
+		log.Infof("Published the schema of chart %s to %s", result.Chart.Name, schemaURL)
+		return nil
+	})
+}
+
+func compatExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	// the compat command must not modify any files
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	client, err := chartrepo.NewClient(viper.GetString("compat-repo"), viper.GetString("compat-username"), viper.GetString("compat-password"))
+	if err != nil {
+		return err
+	}
+	policy, err := newURLPolicy()
+	if err != nil {
+		return err
+	}
+	client.SetPolicy(policy)
+	generationPolicy, err := newGenerationPolicy()
+	if err != nil {
+		return err
+	}
+	generator, err := schema.NewGenerator(generationPolicy)
+	if err != nil {
+		return err
+	}
+	count := viper.GetInt("compat-versions")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return generateSchemas(func(result *schema.Result, outPath string) error {
+		if result.Secondary {
+			return nil
+		}
+
+		versions, err := client.Versions(ctx, result.Chart.Name)
+		if err != nil {
+			return err
+		}
+		previousVersions, err := chartrepo.PreviousVersions(versions, result.Chart.Version, count)
+		if err != nil {
+			return fmt.Errorf("could not check the compatibility of chart %s: %w", result.Chart.Name, err)
+		}
+		if len(previousVersions) == 0 {
+			log.Infof("Chart %s has no released versions to check the compatibility with", result.Chart.Name)
+			return nil
+		}
+
+		breaking := []string{}
+		for _, version := range previousVersions {
+			issues, err := checkReleaseCompatibility(ctx, client, generator, result, outPath, version)
+			if err != nil {
+				return fmt.Errorf("could not check the compatibility of chart %s with version %s: %w", result.Chart.Name, version, err)
+			}
+			for _, issue := range issues {
+				log.Errorf("Chart %s %s breaks values of version %s: %s", result.Chart.Name, result.Chart.Version, version, issue)
+			}
+			if len(issues) > 0 {
+				breaking = append(breaking, version)
+				continue
+			}
+			log.Infof("The schema of chart %s %s accepts the values of version %s", result.Chart.Name, result.Chart.Version, version)
+		}
+		if len(breaking) > 0 {
+			return fmt.Errorf("the schema of chart %s %s is not compatible with the versions %s", result.Chart.Name, result.Chart.Version, strings.Join(breaking, ", "))
+		}
+		return nil
+	})
+}
+
+// checkReleaseCompatibility compares the schema of the result with the schema of the released
+// version of the chart. The released schema is generated, if the chart doesn't contain one.
+// The default values of the released version must be valid for the new schema as well.
+func checkReleaseCompatibility(
+	ctx context.Context,
+	client *chartrepo.Client,
+	generator *schema.Generator,
+	result *schema.Result,
+	outPath, version string,
+) ([]string, error) {
+	archive, err := client.Pull(ctx, result.Chart.Name, version)
+	if err != nil {
+		return nil, err
+	}
+	tempDir, err := os.MkdirTemp("", "helm-schema-compat-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+	chartDir, err := chartrepo.Extract(archive, tempDir)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := schema.ReadSchemaFile(filepath.Join(chartDir, schema.DefaultOutputFile))
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		log.Debugf("Version %s of chart %s contains no schema, generating it", version, result.Chart.Name)
+		released := generator.GenerateContext(ctx, filepath.Join(chartDir, "Chart.yaml"))
+		if len(released.Errors) > 0 {
+			return nil, fmt.Errorf("could not generate the schema: %w", errors.Join(released.Errors...))
+		}
+		previous = &released.Schema
+	}
+
+	issues := []string{}
+	for _, issue := range result.Schema.CheckCompatibility(previous) {
+		issues = append(issues, issue.String())
+	}
+
+	values, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := result.Schema.ValidateValues(values, outPath); err != nil {
+			issues = append(issues, fmt.Sprintf("the default values don't validate: %s", err))
+		}
+	}
+
+	return issues, nil
+}
+
+func fixturesExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	// the fixtures command must only create the fixtures
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	dryRun := viper.GetBool("dry-run")
+	outputDir := viper.GetString("fixtures-output-dir")
+
+	return generateSchemas(func(result *schema.Result, outPath string) error {
+		if result.Secondary {
+			return nil
+		}
+		if result.ValuesPath == "" {
+			log.Debugf("Not writing fixtures for chart %s, it has no values file", result.Chart.Name)
+			return nil
+		}
+
+		defaults, err := os.ReadFile(result.ValuesPath)
+		if err != nil {
+			return err
+		}
+		fixtures, err := result.Schema.NegativeFixtures(defaults, outPath)
+		if err != nil {
+			return fmt.Errorf("could not generate the fixtures of chart %s: %w", result.Chart.Name, err)
+		}
+		if len(fixtures) == 0 {
+			log.Infof("The schema of chart %s has no constraints to write fixtures for", result.Chart.Name)
+			return nil
+		}
+
+		dir := outputDir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(result.ChartPath), dir)
+		}
+		if !dryRun {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+		}
+
+		for _, fixture := range fixtures {
+			content, err := fixture.YAML()
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(dir, schema.FixtureFileName(fixture))
+			if dryRun {
+				log.Infof("Printing fixture %s for %s chart (%s)", fixture.Name, result.Chart.Name, path)
+				if _, err := os.Stdout.Write(content); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := os.WriteFile(path, content, 0o644); err != nil {
+				return err
+			}
+		}
+		log.Infof("Wrote %d fixtures for chart %s to %s", len(fixtures), result.Chart.Name, dir)
+		return nil
+	})
+}
+
+func importSchemaExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	from := viper.GetString("import-schema-from")
+	imported, err := schema.ReadSchemaFile(from)
+	if err != nil {
+		return err
+	}
+	if imported == nil {
+		return fmt.Errorf("could not find the schema %s", from)
+	}
+
+	keys := viper.GetStringSlice("import-schema-keys")
+	annotations := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if strings.Contains(key, "[]") {
+			return fmt.Errorf("cannot import %s, keys within lists are not supported", key)
+		}
+		annotation, err := imported.ImportAnnotation(key)
+		if err != nil {
+			return fmt.Errorf("could not import %s from %s: %w", key, from, err)
+		}
+		annotations[key] = annotation
+	}
+
+	// only the imported annotations must be written
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	dryRun := viper.GetBool("dry-run")
+	dependenciesFilterMap := make(map[string]bool)
+	for _, dep := range viper.GetStringSlice("dependencies-filter") {
+		dependenciesFilterMap[dep] = true
+	}
+
+	results, tempDir, err := generateResults(dependenciesFilterMap)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	foundErrors := false
+	for _, result := range results {
+		// the values files of extracted chart archives can't be edited
+		if result.ValuesPath == "" || (tempDir != "" && strings.HasPrefix(result.ValuesPath, tempDir)) {
+			continue
+		}
+
+		editor, err := util.ReadYamlEditor(result.ValuesPath)
+		if err != nil {
+			log.Errorf("Could not read the values file %s: %s", result.ValuesPath, err)
+			foundErrors = true
+			continue
+		}
+
+		for _, key := range keys {
+			path := strings.Split(key, ".")
+			comment, err := editor.HeadComment(path)
+			if err != nil {
+				log.Warnf("Skipping key %s of chart %s: %s", key, result.Chart.Name, err)
+				continue
+			}
+			comment, err = schema.AnnotatedComment(comment, annotations[key])
+			if err == nil {
+				err = editor.SetHeadComment(path, comment)
+			}
+			if err != nil {
+				log.Errorf("Could not import key %s into chart %s: %s", key, result.Chart.Name, err)
+				foundErrors = true
+			}
+		}
+
+		if dryRun {
+			content, err := editor.Bytes()
+			if err != nil {
+				return err
+			}
+			log.Infof("Printing values with imported annotations for %s chart (%s)", result.Chart.Name, result.ValuesPath)
+			fmt.Printf("%s", content)
+			continue
+		}
+		if err := editor.WriteFile(result.ValuesPath); err != nil {
+			return err
+		}
+	}
+
+	if foundErrors {
+		return errors.New("some errors were found")
+	}
+	return nil
+}
+
+func migrateExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	var renames []schema.Rename
+	for _, value := range viper.GetStringSlice("migrate-rename") {
+		rename, err := schema.ParseRename(value)
+		if err != nil {
+			return err
+		}
+		renames = append(renames, rename)
+	}
+
+	dryRun := viper.GetBool("dry-run")
+	dependenciesFilterMap := make(map[string]bool)
+	for _, dep := range viper.GetStringSlice("dependencies-filter") {
+		dependenciesFilterMap[dep] = true
+	}
+
+	results, tempDir, err := generateResults(dependenciesFilterMap)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	foundErrors := false
+	migrated := make(map[string]bool)
+	for _, result := range results {
+		valuesFiles := result.ValuesFiles
+		if len(valuesFiles) == 0 && result.ValuesPath != "" {
+			valuesFiles = []string{result.ValuesPath}
+		}
+
+		// the old names are added to the annotation of the first values file with the key
+		renamed := make(map[string]bool)
+		for _, valuesPath := range valuesFiles {
+			// the values files of extracted chart archives can't be edited
+			if migrated[valuesPath] || (tempDir != "" && strings.HasPrefix(valuesPath, tempDir)) {
+				continue
+			}
+			migrated[valuesPath] = true
+
+			editor, err := util.ReadYamlEditor(valuesPath)
+			if err != nil {
+				log.Errorf("Could not read the values file %s: %s", valuesPath, err)
+				foundErrors = true
+				continue
+			}
+
+			changed := false
+			for _, rename := range renames {
+				path := rename.Path()
+				if _, _, err := editor.Lookup(path...); err != nil {
+					continue
+				}
+				if err := editor.RenameKey(path, rename.NewName()); err != nil {
+					log.Errorf("Could not rename key %s of chart %s: %s", rename.From, result.Chart.Name, err)
+					foundErrors = true
+					continue
+				}
+				changed = true
+				if renamed[rename.From] {
+					continue
+				}
+				renamed[rename.From] = true
+
+				comment, err := editor.HeadComment(path)
+				if err == nil {
+					comment, err = schema.RenamedComment(comment, rename.OldName(), rename.NewName())
+				}
+				if err == nil {
+					err = editor.SetHeadComment(path, comment)
+				}
+				if err != nil {
+					log.Errorf("Could not add the old name of key %s to chart %s: %s", rename.From, result.Chart.Name, err)
+					foundErrors = true
+				}
+			}
+			if !changed {
+				continue
+			}
+
+			if dryRun {
+				content, err := editor.Bytes()
+				if err != nil {
+					return err
+				}
+				log.Infof("Printing migrated values for %s chart (%s)", result.Chart.Name, valuesPath)
+				fmt.Printf("%s", content)
+				continue
+			}
+			if err := editor.WriteFile(valuesPath); err != nil {
+				return err
+			}
+			log.Infof("Migrated the values file %s of chart %s", valuesPath, result.Chart.Name)
+		}
+
+		if len(valuesFiles) > 0 && !result.Secondary {
+			for _, rename := range renames {
+				if !renamed[rename.From] {
+					log.Debugf("Chart %s has no key %s", result.Chart.Name, rename.From)
+				}
+			}
+		}
+	}
+
+	if foundErrors {
+		return errors.New("some errors were found")
+	}
+	if dryRun {
+		return nil
+	}
+
+	// the schemas are generated from the migrated values files
+	return exec(cmd, nil)
+}
+
+func vendorExec(_ *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	dryRun := viper.GetBool("dry-run")
+	vendorDir := viper.GetString("vendor-dir")
+	dependenciesFilterMap := make(map[string]bool)
+	for _, dep := range viper.GetStringSlice("dependencies-filter") {
+		dependenciesFilterMap[dep] = true
+	}
+
+	results, tempDir, err := generateResults(dependenciesFilterMap)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	foundErrors := false
+	vendored := make(map[string]bool)
+	for _, result := range results {
+		chartDir := filepath.Dir(result.ChartPath)
+		// the values files of extracted chart archives can't be edited
+		if result.Chart == nil || (tempDir != "" && strings.HasPrefix(chartDir, tempDir)) {
+			continue
+		}
+		valuesFiles := result.ValuesFiles
+		if len(valuesFiles) == 0 && result.ValuesPath != "" {
+			valuesFiles = []string{result.ValuesPath}
+		}
+
+		vendor, err := schema.NewVendor(filepath.Join(chartDir, vendorDir))
+		if err != nil {
+			log.Errorf("Could not read the vendored schemas of chart %s: %s", result.Chart.Name, err)
+			foundErrors = true
+			continue
+		}
+		changedChart := false
+		for _, valuesPath := range valuesFiles {
+			if vendored[valuesPath] {
+				continue
+			}
+			vendored[valuesPath] = true
+
+			content, err := os.ReadFile(valuesPath)
+			if err != nil {
+				log.Errorf("Could not read the values file %s: %s", valuesPath, err)
+				foundErrors = true
+				continue
+			}
+			refs, err := schema.CollectValuesRefs(content)
+			if err != nil {
+				log.Errorf("Could not parse the values file %s: %s", valuesPath, err)
+				foundErrors = true
+				continue
+			}
+
+			rewrites := make(map[string]string)
+			for _, ref := range refs {
+				ref, _, _ = strings.Cut(ref, "#")
+				if _, ok := rewrites[ref]; ok || !schema.IsExternalRef(ref) {
+					continue
+				}
+				name, err := vendor.Add(ctx, ref)
+				if err != nil {
+					log.Errorf("Could not vendor %s of chart %s: %s", ref, result.Chart.Name, err)
+					foundErrors = true
+					continue
+				}
+				rel, err := filepath.Rel(filepath.Dir(valuesPath), filepath.Join(vendor.Dir(), name))
+				if err != nil {
+					return err
+				}
+				rewrites[ref] = filepath.ToSlash(rel)
+				log.Infof("Vendored %s of chart %s to %s", ref, result.Chart.Name, filepath.Join(vendor.Dir(), name))
+			}
+			if len(rewrites) == 0 {
+				continue
+			}
+			changedChart = true
+
+			content = schema.RewriteAnnotationRefs(content, rewrites)
+			if dryRun {
+				log.Infof("Printing vendored values for %s chart (%s)", result.Chart.Name, valuesPath)
+				fmt.Printf("%s", content)
+				continue
+			}
+			if err := os.WriteFile(valuesPath, content, 0o644); err != nil {
+				return err
+			}
+		}
+
+		if changedChart && !dryRun {
+			if err := vendor.Write(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if foundErrors {
+		return errors.New("some errors were found")
+	}
+	return nil
+}
+
+func upgradeValuesExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	schemas := make(map[string]*schema.Schema, 2)
+	for _, name := range []string{"old-schema", "new-schema"} {
+		path := viper.GetString("upgrade-values-" + name)
+		s, err := schema.ReadSchemaFile(path)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			return fmt.Errorf("could not find the schema %s", path)
+		}
+		schemas[name] = s
+	}
+
+	valuesPath := viper.GetString("upgrade-values-values")
+	editor, err := util.ReadYamlEditor(valuesPath)
+	if err != nil {
+		return fmt.Errorf("could not read the values file %s: %w", valuesPath, err)
+	}
+
+	invalid := 0
+	for _, issue := range schemas["new-schema"].UpgradeValues(schemas["old-schema"], editor) {
+		if issue.Converted {
+			log.Infof("Upgraded %s", issue)
+			continue
+		}
+		log.Errorf("Could not upgrade %s", issue)
+		invalid++
+	}
+
+	if output := viper.GetString("upgrade-values-output"); output != "" {
+		if err := editor.WriteFile(output); err != nil {
+			return err
+		}
+	} else {
+		content, err := editor.Bytes()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", content)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d values of %s are not valid for the new schema and have to be changed by hand", invalid, valuesPath)
+	}
+	return nil
+}
+
+func checkValuesExec(_ *cobra.Command, args []string) error {
+	configureLogging()
+
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %s, the values files are passed with -f", strings.Join(args, " "))
+	}
+	output := viper.GetString("check-values-output")
+	if output != "pretty" && output != "json" {
+		return fmt.Errorf("unsupported output format: %s", output)
+	}
+
+	schemaPath := viper.GetString("check-values-schema")
+	s, err := schema.ReadSchemaFile(schemaPath)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("could not find the schema %s", schemaPath)
+	}
+
+	valuesFiles := viper.GetStringSlice("check-values-values")
+	findings, err := s.CheckValues(schemaPath, valuesFiles)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case "json":
+		jsonStr, err := schema.NewValuesReport(findings).ToJson()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", jsonStr)
+	default:
+		for _, finding := range findings {
+			fmt.Println(finding)
+		}
+		if len(findings) == 0 {
+			fmt.Printf("The values of %s are valid\n", strings.Join(valuesFiles, ", "))
+		}
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("%d values of %s are not valid against %s", len(findings), strings.Join(valuesFiles, ", "), schemaPath)
+	}
+	return nil
+}
+
+func annotationCatalogueExec(_ *cobra.Command, _ []string) error {
+	configureLogging()
+
+	catalogue := schema.AnnotationCatalogue()
+	if output := viper.GetString("annotation-catalogue-output"); output != "" {
+		return writeSchemaFile(output, catalogue, true)
+	}
+	return writeSchema(os.Stdout, catalogue, true)
+}
+
+func benchExec(cmd *cobra.Command, _ []string) error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	configureLogging()
+
+	opts := schema.SyntheticOptions{
+		Keys:   viper.GetInt("bench-keys"),
+		Depth:  viper.GetInt("bench-depth"),
+		Refs:   viper.GetInt("bench-refs"),
+		RefURL: viper.GetString("bench-ref-url"),
+	}
+	iterations := viper.GetInt("bench-iterations")
+	maxDuration := viper.GetDuration("bench-max-duration")
+	if opts.Keys < 1 || iterations < 1 {
+		return errors.New("--keys and --iterations must be at least 1")
+	}
+
+	chartDir := viper.GetString("bench-output-dir")
+	if chartDir == "" {
+		tempDir, err := os.MkdirTemp("", "helm-schema-bench-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		chartDir = tempDir
+	} else if err := os.MkdirAll(chartDir, 0o755); err != nil {
+		return err
+	}
+
+	chartFile := "apiVersion: v2\nname: synthetic\nversion: 0.1.0\n"
+	if err := os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartFile), 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(chartDir, "values.yaml"), schema.SyntheticValues(opts), 0o644); err != nil {
+		return err
+	}
+
+	// only the synthetic chart is generated and its values file must not be modified
+	viper.Set("chart-search-root", chartDir)
+	viper.Set("add-schema-reference", false)
+	viper.Set("fix", false)
+
+	durations := make([]time.Duration, 0, iterations)
+	for i := 0; i < iterations; i++ {
+		start := time.Now()
+		results, tempDir, err := generateResults(map[string]bool{})
+		if tempDir != "" {
+			os.RemoveAll(tempDir)
+		}
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			if len(result.Errors) > 0 {
+				return fmt.Errorf("could not generate the schema of the synthetic chart: %w", errors.Join(result.Errors...))
+			}
+			if err := result.Schema.WriteJSON(io.Discard); err != nil {
+				return err
+			}
+		}
+		durations = append(durations, time.Since(start))
+		log.Debugf("Iteration %d took %s", i+1, durations[i])
+	}
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	average := total / time.Duration(iterations)
+	fmt.Printf("keys=%d depth=%d refs=%d iterations=%d min=%s avg=%s max=%s\n",
+		opts.Keys, opts.Depth, opts.Refs, iterations, slices.Min(durations), average, slices.Max(durations))
+
+	if maxDuration > 0 && average > maxDuration {
+		return fmt.Errorf("the average generation took %s, which exceeds the maximum of %s", average, maxDuration)
+	}
+	return nil
+}
+
+func main() {
+	command, err := newCommand(exec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+
+	graphCommand, err := newGraphCommand(graphExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(graphCommand)
+
+	exportCommand, err := newExportCommand(exportExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(exportCommand)
+
+	publishCommand, err := newPublishCommand(publishExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(publishCommand)
+
+	compatCommand, err := newCompatCommand(compatExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(compatCommand)
+
+	fixturesCommand, err := newFixturesCommand(fixturesExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(fixturesCommand)
+
+	importSchemaCommand, err := newImportSchemaCommand(importSchemaExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(importSchemaCommand)
+
+	migrateCommand, err := newMigrateCommand(migrateExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(migrateCommand)
+
+	vendorCommand, err := newVendorCommand(vendorExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(vendorCommand)
+
+	upgradeValuesCommand, err := newUpgradeValuesCommand(upgradeValuesExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(upgradeValuesCommand)
+
+	checkValuesCommand, err := newCheckValuesCommand(checkValuesExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(checkValuesCommand)
+
+	annotationCatalogueCommand, err := newAnnotationCatalogueCommand(annotationCatalogueExec)
+	if err != nil {
+		log.Errorf("Failed to create the CLI commander: %s", err)
+		os.Exit(1)
+	}
+	command.AddCommand(annotationCatalogueCommand)
+
+	benchCommand, err := newBenchCommand(benchExec)
 	if err != nil {
 		log.Errorf("Failed to create the CLI commander: %s", err)
 		os.Exit(1)
 	}
+	command.AddCommand(benchCommand)
 
 	if err := command.Execute(); err != nil {
 		log.Errorf("Execution error: %s", err)
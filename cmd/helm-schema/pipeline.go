@@ -0,0 +1,778 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/schema"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// generationOptions are the flags of the commands, which generate schemas (see generateSchemas)
+type generationOptions struct {
+	noDeps                    bool
+	outFile                   string
+	dependenciesFilter        map[string]bool
+	skipDepsSchemaValidation  bool
+	allowCircularDeps         bool
+	keepCustomFormats         bool
+	addMinProperties          bool
+	detectContentMediaTypes   bool
+	preserveDefinitions       bool
+	reportPatternMatches      bool
+	renderChartDefaults       bool
+	checkReadme               string
+	selfCheck                 bool
+	helmCompatCheck           bool
+	reportTypeWidening        bool
+	helmSetCoercions          bool
+	maxSchemaSize             int
+	refCycles                 string
+	refCycleDepth             int
+	libraryPrefixes           map[string][]string
+	warningPolicy             schema.WarningPolicy
+	validationProfile         schema.ValidationProfile
+	descriptionPolicy         schema.DescriptionPolicy
+	descriptionSanitizeConfig schema.DescriptionSanitizeConfig
+	titleConfig               *schema.TitleConfig
+	constraintRules           []schema.ConstraintRule
+	pathRules                 []schema.PathRule
+	overrides                 []schema.Override
+}
+
+// readGenerationOptions reads and validates the options of the generation
+func readGenerationOptions() (*generationOptions, error) {
+	opts := &generationOptions{
+		noDeps:                   viper.GetBool("no-dependencies"),
+		outFile:                  viper.GetString("output-file"),
+		dependenciesFilter:       make(map[string]bool),
+		skipDepsSchemaValidation: viper.GetBool("skip-dependencies-schema-validation"),
+		allowCircularDeps:        viper.GetBool("allow-circular-dependencies"),
+		keepCustomFormats:        viper.GetBool("keep-custom-formats"),
+		addMinProperties:         viper.GetBool("add-min-properties"),
+		detectContentMediaTypes:  viper.GetBool("detect-content-media-types"),
+		preserveDefinitions:      viper.GetBool("preserve-definitions"),
+		reportPatternMatches:     viper.GetBool("report-pattern-matches"),
+		renderChartDefaults:      viper.GetBool("render-chart-defaults"),
+		checkReadme:              viper.GetString("check-readme"),
+		selfCheck:                viper.GetBool("self-check"),
+		helmCompatCheck:          viper.GetBool("helm-compat-check"),
+		reportTypeWidening:       viper.GetBool("report-type-widening"),
+		helmSetCoercions:         viper.GetBool("helm-set-coercions"),
+		maxSchemaSize:            viper.GetInt("max-schema-size"),
+		refCycles:                viper.GetString("ref-cycles"),
+		refCycleDepth:            viper.GetInt("ref-cycle-depth"),
+		libraryPrefixes:          make(map[string][]string),
+		warningPolicy: schema.WarningPolicy{
+			FailOnWarn: viper.GetBool("fail-on-warn"),
+			Errors:     viper.GetStringSlice("warnings-as-errors"),
+		},
+		descriptionPolicy: schema.DescriptionPolicy{
+			Scope:    viper.GetString("require-descriptions"),
+			Severity: viper.GetString("require-descriptions-severity"),
+		},
+		descriptionSanitizeConfig: schema.DescriptionSanitizeConfig{
+			Normalize:     viper.GetBool("normalize-descriptions"),
+			StripMarkdown: viper.GetBool("strip-markdown"),
+			MaxLength:     viper.GetInt("max-description-length"),
+			LinkDocs:      viper.GetBool("link-docs"),
+		},
+	}
+	for _, dep := range viper.GetStringSlice("dependencies-filter") {
+		opts.dependenciesFilter[dep] = true
+	}
+	if !slices.Contains(schema.RefCyclesModes, opts.refCycles) {
+		return nil, fmt.Errorf("unsupported --ref-cycles %s (possible: %s)", opts.refCycles, strings.Join(schema.RefCyclesModes, ", "))
+	}
+	for _, value := range viper.GetStringSlice("library-prefixes") {
+		prefix, err := schema.ParseLibraryPrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		opts.libraryPrefixes[prefix.Library] = append(opts.libraryPrefixes[prefix.Library], prefix.Prefix)
+	}
+	if err := opts.warningPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	var err error
+	opts.validationProfile, err = schema.GetValidationProfile(viper.GetString("validation-profile"))
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.descriptionPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	opts.titleConfig, err = schema.NewTitleConfig(
+		viper.GetString("title-style"),
+		viper.GetBool("title-include-path"),
+		viper.GetString("title-template"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if viper.GetBool("infer-constraints") {
+		var customRules []schema.ConstraintRule
+		if err := viper.UnmarshalKey("constraint-rules", &customRules); err != nil {
+			return nil, err
+		}
+		opts.constraintRules, err = schema.NewConstraintRules(customRules, viper.GetStringSlice("disable-constraint-rules"))
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range opts.constraintRules {
+			log.Debugf("Using constraint rule %s", rule)
+		}
+	}
+	var customPathRules []schema.PathRule
+	if err := viper.UnmarshalKey("path-rules", &customPathRules); err != nil {
+		return nil, err
+	}
+	opts.pathRules, err = schema.NewPathRules(customPathRules)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range viper.GetStringSlice("override") {
+		override, err := schema.ParseOverride(value)
+		if err != nil {
+			return nil, err
+		}
+		opts.overrides = append(opts.overrides, override)
+	}
+	return opts, nil
+}
+
+// schemaPipeline processes the results of the charts in the order of their dependencies
+// and passes the final schemas to write
+type schemaPipeline struct {
+	*generationOptions
+	write schemaWriter
+
+	// chartNameToResult are the processed results, which are merged into their parents
+	chartNameToResult map[string]*schema.Result
+	// conditionsToPatch are the keys of the conditions of the dependencies by chart
+	conditionsToPatch map[string][]string
+	// appliedOverrides are set for the overrides, which matched a key of any chart
+	appliedOverrides []bool
+}
+
+func newSchemaPipeline(opts *generationOptions, write schemaWriter, results []*schema.Result) *schemaPipeline {
+	p := &schemaPipeline{
+		generationOptions: opts,
+		write:             write,
+		chartNameToResult: make(map[string]*schema.Result),
+		conditionsToPatch: make(map[string][]string),
+		appliedOverrides:  make([]bool, len(opts.overrides)),
+	}
+	if opts.noDeps {
+		return p
+	}
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			continue
+		}
+		for _, dep := range result.Chart.Dependencies {
+			if len(opts.dependenciesFilter) > 0 && !opts.dependenciesFilter[dep.Name] {
+				continue
+			}
+
+			if dep.Condition != "" {
+				conditionKeys := strings.Split(dep.Condition, ".")
+				p.conditionsToPatch[conditionKeys[0]] = conditionKeys[1:]
+			}
+		}
+	}
+	return p
+}
+
+// chartRun is the processing of the result of a chart
+type chartRun struct {
+	result *schema.Result
+	// log attaches the chart to the diagnostics of --diagnostics-json
+	log *log.Entry
+	// failed is set once an error is reported
+	failed bool
+}
+
+func (r *chartRun) errorf(format string, args ...any) {
+	r.log.Errorf(format, args...)
+	r.failed = true
+}
+
+// warn reports the warning, the run fails if its category is promoted to errors by the policy
+func (r *chartRun) warn(policy schema.WarningPolicy, warning schema.Warning) {
+	if reportWarning(r.log, policy, r.result.Chart.Name, warning) {
+		r.failed = true
+	}
+}
+
+// process runs all steps for the result of a chart, until one of them stops it.
+// It returns false if any errors were reported.
+func (p *schemaPipeline) process(result *schema.Result) bool {
+	run := &chartRun{result: result, log: log.NewEntry(log.StandardLogger())}
+	if result.Chart != nil {
+		run.log = logging.WithChart(result.Chart.Name)
+	}
+
+	if len(result.Errors) > 0 {
+		if result.Chart != nil {
+			run.log.Errorf("Found %d errors while processing the chart %s (%s)", len(result.Errors), result.Chart.Name, result.ChartPath)
+		} else {
+			run.log.Errorf("Found %d errors while processing the chart %s", len(result.Errors), result.ChartPath)
+		}
+		for _, err := range result.Errors {
+			run.log.Error(err)
+		}
+		return false
+	}
+
+	run.log.Debugf("Processing result for chart: %s (%s)", result.Chart.Name, result.ChartPath)
+
+	steps := []func(*chartRun) bool{
+		p.lint,
+		p.mergeDependencies,
+		p.transform,
+		p.applyOverrides,
+		p.finalize,
+	}
+	for _, step := range steps {
+		if !step(run) {
+			run.failed = true
+			break
+		}
+	}
+	return !run.failed
+}
+
+// lint validates the annotations and lints the schema, before the dependencies are merged,
+// so their properties are linted with their own charts
+func (p *schemaPipeline) lint(run *chartRun) bool {
+	result := run.result
+	for _, warning := range result.Warnings {
+		run.warn(p.warningPolicy, warning)
+	}
+
+	validationProfile := p.validationProfile
+	if name, ok := result.Chart.Annotations[schema.ValidationProfileAnnotation]; ok {
+		var err error
+		validationProfile, err = schema.GetValidationProfile(name)
+		if err != nil {
+			run.errorf("Invalid annotation %s of chart %s: %s", schema.ValidationProfileAnnotation, result.Chart.Name, err)
+			return false
+		}
+	}
+	if errs := result.Schema.ValidateAnnotations(validationProfile); len(errs) > 0 {
+		run.log.Errorf("Found %d invalid annotations in %s (validation profile %s)", len(errs), result.ValuesPath, validationProfile.Name)
+		for _, err := range errs {
+			var annotationErr schema.AnnotationError
+			if errors.As(err, &annotationErr) {
+				if file, line := result.Locate(annotationErr.Path()); line > 0 {
+					run.log.Errorf("%s:%d: %s", file, line, err)
+					continue
+				}
+			}
+			run.log.Error(err)
+		}
+		return false
+	}
+
+	for _, issue := range result.Schema.LintDescriptions(p.descriptionPolicy) {
+		logIssue := run.log.Warnf
+		if issue.Severity == schema.SeverityError {
+			logIssue = run.errorf
+		}
+		if file, line := result.Locate(issue.Path); line > 0 {
+			logIssue("%s:%d: %s", file, line, issue)
+		} else {
+			logIssue("The schema of chart %s: %s", result.Chart.Name, issue)
+		}
+	}
+
+	// promoting the type widening warnings to errors enables them as well
+	if p.reportTypeWidening || slices.Contains(p.warningPolicy.Errors, schema.WarningTypeWidening) {
+		for _, issue := range result.Schema.LintTypeWidening() {
+			run.warn(p.warningPolicy, issue.Warning(result.Locate(issue.Path)))
+		}
+	}
+	return true
+}
+
+// mergeDependencies merges the schemas of the dependencies, which were processed before
+// their parents, into the schema of the chart
+func (p *schemaPipeline) mergeDependencies(run *chartRun) bool {
+	result := run.result
+
+	// dependencies are merged with their rendered defaults
+	if p.renderChartDefaults {
+		result.Schema.RenderChartDefaults(result.Chart)
+	}
+
+	if p.noDeps {
+		return true
+	}
+
+	// parents use the schema of the first values file of their dependencies
+	if !result.Secondary {
+		p.chartNameToResult[result.Chart.Name] = result
+		run.log.Debugf("Stored chart %s in chartNameToResult", result.Chart.Name)
+	}
+
+	if patch, ok := p.conditionsToPatch[result.Chart.Name]; ok {
+		patchCondition(run, patch)
+	}
+
+	for _, dep := range result.Chart.Dependencies {
+		if len(p.dependenciesFilter) > 0 && !p.dependenciesFilter[dep.Name] {
+			continue
+		}
+		if dep.Name == "" {
+			run.log.Warnf("Dependency without name found (checkout %s).", result.ChartPath)
+			continue
+		}
+
+		// Determine the property name to use (alias or name)
+		propName := dep.Name
+		if dep.Alias != "" {
+			propName = dep.Alias
+		}
+
+		// Check if the property already exists with custom schema annotations
+		// HasData indicates that the property has custom schema data from annotations (like $ref)
+		if existingProp, exists := result.Schema.Properties[propName]; exists && existingProp.HasData {
+			run.log.Debugf("Property %s in chart %s has custom schema annotations, skipping dependency schema merge", propName, result.Chart.Name)
+			continue
+		}
+
+		dependencyResult, ok := p.chartNameToResult[dep.Name]
+		if !ok {
+			run.log.Warnf("Dependency (%s->%s) specified but no schema found. If you want to create jsonschemas for external dependencies, you need to run helm dep up", result.Chart.Name, dep.Name)
+			continue
+		}
+		run.log.Debugf(
+			"Found chart of dependency %s (%s)",
+			dependencyResult.Chart.Name,
+			dependencyResult.ChartPath,
+		)
+
+		// Merge $defs and definitions (JSON Schema Draft-04/06/07 style) from the dependency
+		// into the root-level ones of the parent chart
+		result.Schema.Defs = p.mergeDefinitions(run, dep, dependencyResult, "$defs", result.Schema.Defs, dependencyResult.Schema.Defs)
+		result.Schema.Definitions = p.mergeDefinitions(run, dep, dependencyResult, "definitions", result.Schema.Definitions, dependencyResult.Schema.Definitions)
+
+		if dependencyResult.Chart.Type == "library" {
+			p.mergeLibraryChart(run, dep, dependencyResult)
+		} else {
+			nestDependency(run, dep, propName, dependencyResult)
+		}
+	}
+
+	if p.skipDepsSchemaValidation {
+		p.relaxDependencies(run)
+	}
+	return true
+}
+
+// patchCondition adds the key of the condition of the chart in its parent (e.g. subchart.enabled),
+// unless the schema already contains it
+func patchCondition(run *chartRun, patch []string) {
+	schemaToPatch := &run.result.Schema
+	lastIndex := len(patch) - 1
+	for i, key := range patch {
+		if alreadyPresentSchema, ok := schemaToPatch.Properties[key]; !ok {
+			run.log.Debugf(
+				"Patching conditional field \"%s\" into schema of chart %s",
+				key,
+				run.result.Chart.Name,
+			)
+			if i == lastIndex {
+				schemaToPatch.Properties[key] = &schema.Schema{
+					Type:        []string{"boolean"},
+					Title:       key,
+					Description: "Conditional property used in parent chart",
+				}
+			} else {
+				schemaToPatch.Properties[key] = &schema.Schema{Type: []string{"object"}, Title: key}
+				schemaToPatch = schemaToPatch.Properties[key]
+			}
+		} else {
+			schemaToPatch = alreadyPresentSchema
+		}
+	}
+}
+
+// mergeDefinitions adds the definitions of the dependency (keyword is $defs or definitions) to
+// the ones of the parent and returns them. The definitions of the parent take precedence.
+func (p *schemaPipeline) mergeDefinitions(run *chartRun, dep *chart.Dependency, dependencyResult *schema.Result, keyword string, defs, dependencyDefs map[string]*schema.Schema) map[string]*schema.Schema {
+	if len(dependencyDefs) == 0 {
+		return defs
+	}
+	if defs == nil {
+		defs = make(map[string]*schema.Schema)
+	}
+	for defName, defSchema := range dependencyDefs {
+		if _, exists := defs[defName]; exists {
+			run.warn(p.warningPolicy, schema.Warning{
+				Category: schema.WarningDefinitionConflict,
+				Message:  fmt.Sprintf("the definition %s from dependency %s conflicts with the existing definition, keeping the parent's definition", defName, dep.Name),
+			})
+			continue
+		}
+		run.log.Debugf("Merging %s entry %s from dependency %s into parent chart %s", keyword, defName, dep.Name, run.result.Chart.Name)
+		def := defSchema.Clone()
+		def.RebaseFileRefs(dependencyResult.RefContext(), run.result.RefContext(), run.log)
+		defs[defName] = def
+	}
+	return defs
+}
+
+// mergeLibraryChart merges the properties of the library chart into the top level of the parent,
+// or its value contract at the library prefixes, if the library chart has one
+func (p *schemaPipeline) mergeLibraryChart(run *chartRun, dep *chart.Dependency, dependencyResult *schema.Result) {
+	result := run.result
+	partial, err := schema.ReadPartialSchema(dependencyResult.ChartDir())
+	if err != nil {
+		run.errorf("Could not read the value contract of library chart %s: %s", dep.Name, err)
+		return
+	}
+	if partial != nil {
+		// The value contract replaces the generated properties of the library chart
+		prefixes, ok := p.libraryPrefixes[dep.Name]
+		if !ok {
+			prefixes = []string{""}
+		}
+		// file references of the contract are relative to the library chart
+		contract := partial.Clone()
+		contract.RebaseFileRefs(dependencyResult.ChartRefContext(), result.RefContext(), run.log)
+		for _, prefix := range prefixes {
+			run.log.Debugf("Importing the value contract of library chart %s into parent chart %s at %q", dep.Name, result.Chart.Name, prefix)
+			kept, err := result.Schema.ImportPartial(contract, prefix)
+			if err != nil {
+				run.errorf("Could not import the value contract of library chart %s: %s", dep.Name, err)
+				continue
+			}
+			for _, name := range kept {
+				run.log.Warnf("%s from the value contract of library chart %s already exists in parent chart %s, skipping", name, dep.Name, result.Chart.Name)
+			}
+		}
+		return
+	}
+
+	run.log.Debugf("Merging library chart %s properties into parent chart %s at top level", dep.Name, result.Chart.Name)
+	for propName, propSchema := range dependencyResult.Schema.Properties {
+		// Skip the global property as it's already in the parent
+		if propName == "global" {
+			continue
+		}
+		// Only add if the property doesn't already exist in parent
+		if _, exists := result.Schema.Properties[propName]; !exists {
+			librarySchema := propSchema.Clone()
+			librarySchema.RebaseFileRefs(dependencyResult.RefContext(), result.RefContext(), run.log)
+			result.Schema.Properties[propName] = librarySchema
+		} else {
+			run.log.Warnf("Property %s from library chart %s already exists in parent chart %s, skipping", propName, dep.Name, result.Chart.Name)
+		}
+	}
+}
+
+// nestDependency adds the whole schema of the dependency as the property propName of the parent
+func nestDependency(run *chartRun, dep *chart.Dependency, propName string, dependencyResult *schema.Result) {
+	depSchema := *dependencyResult.Schema.Clone()
+
+	// Override top-level fields for nesting
+	depSchema.Title = dep.Name
+	if dependencyResult.Chart.Description != "" {
+		depSchema.Description = dependencyResult.Chart.Description
+	}
+	// Ensure type is object for nesting
+	if len(depSchema.Type) == 0 {
+		depSchema.Type = []string{"object"}
+	}
+
+	depSchema.DisableRequiredProperties()
+
+	// internal references of the dependency are relative to its own root
+	depSchema.RebaseInternalRefs(schema.PropertyPointer(propName))
+	// kept file references of the dependency are relative to its own directory
+	depSchema.RebaseFileRefs(dependencyResult.RefContext(), run.result.RefContext(), run.log)
+
+	run.result.Schema.Properties[propName] = &depSchema
+}
+
+// relaxDependencies makes the dependencies optional and allows additional properties in them
+// (see --skip-dependencies-schema-validation)
+func (p *schemaPipeline) relaxDependencies(run *chartRun) {
+	result := run.result
+	depNames := getDependencyNames(result.Chart.Dependencies, p.dependenciesFilter)
+
+	var required []string
+	for _, n := range result.Schema.Required.Strings {
+		if !slices.Contains(depNames, n) {
+			required = append(required, n)
+		}
+	}
+	result.Schema.Required.Strings = required
+
+	for _, depName := range depNames {
+		if prop, ok := result.Schema.Properties[depName]; ok {
+			run.log.Debugf("Setting additionalProperties to true for dependency %s in chart %s", depName, result.Chart.Name)
+			prop.AdditionalProperties = true
+		}
+	}
+}
+
+// transform applies the rules, conversions and inferences of the options to the merged schema
+func (p *schemaPipeline) transform(run *chartRun) bool {
+	result := run.result
+
+	// before the custom formats are converted, so rules can use them
+	if err := result.Schema.ApplyPathRules(p.pathRules); err != nil {
+		run.errorf("Could not apply the path rules to the schema of chart %s: %s", result.Chart.Name, err)
+		return false
+	}
+
+	if !p.keepCustomFormats {
+		result.Schema.ConvertCustomFormats()
+	}
+
+	if p.addMinProperties {
+		result.Schema.AddMinProperties()
+	}
+
+	if p.detectContentMediaTypes {
+		result.Schema.DetectContentMediaTypes()
+	}
+
+	result.Schema.InferConstraints(p.constraintRules)
+
+	if p.reportPatternMatches || slices.Contains(p.warningPolicy.Errors, schema.WarningPatternMatch) {
+		for _, match := range result.Schema.PatternMatches() {
+			run.warn(p.warningPolicy, schema.Warning{
+				Category: schema.WarningPatternMatch,
+				Message:  fmt.Sprintf("the key %s is not added to the properties, because it matches the patternProperties pattern %s", match.Key, match.Pattern),
+			})
+		}
+		if p.reportPatternMatches {
+			result.Schema.AddPatternMatchTraces()
+		}
+	}
+
+	result.Schema.SanitizeDescriptions(p.descriptionSanitizeConfig)
+
+	if err := result.Schema.TransformTitles(p.titleConfig); err != nil {
+		run.errorf("Could not transform the titles of chart %s: %s", result.Chart.Name, err)
+		return false
+	}
+	return true
+}
+
+// applyOverrides applies the overrides, which match a key of the schema
+func (p *schemaPipeline) applyOverrides(run *chartRun) bool {
+	result := run.result
+	failed := false
+	for i, override := range p.overrides {
+		if _, err := result.Schema.Query(override.Path); err != nil {
+			run.log.Debugf("Not applying the override of %s to chart %s: %s", override.Path, result.Chart.Name, err)
+			continue
+		}
+		if err := result.Schema.ApplyOverride(override); err != nil {
+			run.errorf("Could not override the schema of chart %s: %s", result.Chart.Name, err)
+			failed = true
+			continue
+		}
+		p.appliedOverrides[i] = true
+	}
+	if failed {
+		return false
+	}
+
+	// after the overrides, so the overridden keys are tolerant as well
+	if p.helmSetCoercions {
+		result.Schema.AllowHelmSetCoercions()
+	}
+	return true
+}
+
+// finalize completes the schema with the existing schema file, checks it and passes it to write
+func (p *schemaPipeline) finalize(run *chartRun) bool {
+	result := run.result
+	outPath, err := schema.OutputPath(result, p.outFile)
+	if err != nil {
+		run.errorf("Could not determine the schema file of chart %s: %s", result.Chart.Name, err)
+		return false
+	}
+
+	if p.preserveDefinitions && !p.preserveExistingDefinitions(run, outPath) {
+		return false
+	}
+
+	if !p.checkRefs(run) {
+		return false
+	}
+
+	if !p.check(run, outPath) {
+		return false
+	}
+
+	if err := p.write(result, outPath); err != nil {
+		run.errorf("Could not write the schema of chart %s: %s", result.Chart.Name, err)
+	}
+	return true
+}
+
+// preserveExistingDefinitions keeps the definitions of the existing schema file, which are still referenced
+func (p *schemaPipeline) preserveExistingDefinitions(run *chartRun, outPath string) bool {
+	result := run.result
+	existingSchema, err := schema.ReadSchemaFile(outPath)
+	if err != nil {
+		run.errorf("Could not read the existing schema of chart %s: %s", result.Chart.Name, err)
+		return false
+	}
+	conflicts, dropped := result.Schema.PreserveDefinitions(existingSchema)
+	for _, conflict := range conflicts {
+		run.warn(p.warningPolicy, schema.Warning{
+			Category: schema.WarningDefinitionConflict,
+			Message:  fmt.Sprintf("the definition %s differs from the one in %s, keeping the generated definition", conflict, outPath),
+		})
+	}
+	for _, stale := range dropped {
+		run.log.Infof("Dropping definition %s of %s, which isn't referenced by chart %s anymore", stale, outPath, result.Chart.Name)
+	}
+	return true
+}
+
+// checkRefs checks the references into the schema itself and handles their cycles. They can
+// only be checked once the whole schema (including dependencies and preserved definitions) exists.
+func (p *schemaPipeline) checkRefs(run *chartRun) bool {
+	result := run.result
+	if err := result.Schema.ValidateInternalRefs(); err != nil {
+		run.errorf("Found invalid references in the schema of chart %s: %s", result.Chart.Name, err)
+		return false
+	}
+
+	// merged definitions and dependencies can reference each other, which hangs validators expanding the references
+	if p.refCycles == schema.RefCyclesExpand {
+		cycles, err := result.Schema.BreakRefCycles(p.refCycleDepth)
+		if err != nil {
+			run.errorf("Could not expand the $ref cycles in the schema of chart %s: %s", result.Chart.Name, err)
+			return false
+		}
+		for _, cycle := range cycles {
+			run.log.Infof("Expanded the $ref cycle %s to a depth of %d", cycle, p.refCycleDepth)
+		}
+	} else if cycles := result.Schema.RefCycles(); len(cycles) > 0 {
+		for _, cycle := range cycles {
+			run.errorf("Found a $ref cycle in the schema of chart %s: %s", result.Chart.Name, cycle)
+		}
+		return false
+	}
+	return true
+}
+
+// check runs the checks of the final schema against the readme, the values and helm
+func (p *schemaPipeline) check(run *chartRun, outPath string) bool {
+	result := run.result
+
+	if p.checkReadme != "" {
+		readmePath := filepath.Join(filepath.Dir(result.ChartPath), p.checkReadme)
+		mismatches, err := compareReadme(result, readmePath, p.dependenciesFilter)
+		if err != nil {
+			run.errorf("Could not compare the schema of chart %s with %s: %s", result.Chart.Name, readmePath, err)
+		}
+		for _, mismatch := range mismatches {
+			run.errorf("%s: %s", readmePath, mismatch)
+		}
+	}
+
+	// the values files set the deprecated keys of the chart and its dependencies
+	if result.ValuesPath != "" {
+		documents, err := result.ValuesDocuments()
+		if err != nil {
+			run.errorf("Could not read the values of chart %s: %s", result.Chart.Name, err)
+			return false
+		}
+		for _, values := range documents {
+			issues, err := result.Schema.LintDeprecatedUsage(values)
+			if err != nil {
+				run.errorf("Could not check the deprecated keys used by the values of chart %s: %s", result.Chart.Name, err)
+				break
+			}
+			for _, issue := range issues {
+				run.warn(p.warningPolicy, issue.Warning(result.Locate(issue.Path)))
+			}
+		}
+	}
+
+	// catches annotations which are too strict for the defaults (e.g. a missing null type)
+	if p.selfCheck && result.ValuesPath != "" {
+		selfCheckValues(run, outPath)
+	}
+
+	// promoting the helm compatibility warnings to errors enables them as well
+	if p.helmCompatCheck || slices.Contains(p.warningPolicy.Errors, schema.WarningHelmCompat) {
+		for _, issue := range result.Schema.CheckHelmCompatibility() {
+			if issue.Rejected {
+				run.errorf("The schema of chart %s: %s", result.Chart.Name, issue)
+			} else {
+				run.warn(p.warningPolicy, schema.Warning{Category: schema.WarningHelmCompat, Message: issue.String()})
+			}
+		}
+	}
+
+	// the published schema still depends on these, e.g. urls with --ref-mode inline-files.
+	// They are expected in most setups, so they are only reported if they are promoted to errors.
+	if refs := result.Schema.UnresolvedRefs(); p.warningPolicy.IsError(schema.WarningUnresolvedRef) {
+		for _, ref := range refs {
+			run.warn(p.warningPolicy, schema.Warning{Category: schema.WarningUnresolvedRef, Message: "the unresolved $ref " + ref + " is kept"})
+		}
+	} else if len(refs) > 0 {
+		run.log.Infof("The schema of chart %s keeps %d unresolved $refs:", result.Chart.Name, len(refs))
+		for _, ref := range refs {
+			run.log.Infof("  %s", ref)
+		}
+	}
+
+	// helm stores the chart (including the schema) in the release, which is limited to 1 MiB
+	if p.maxSchemaSize > 0 {
+		jsonStr, err := result.Schema.ToJson()
+		if err != nil {
+			run.errorf("Could not encode the schema of chart %s: %s", result.Chart.Name, err)
+			return false
+		}
+		if len(jsonStr) > p.maxSchemaSize {
+			run.warn(p.warningPolicy, schema.Warning{
+				Category: schema.WarningOversizedSchema,
+				Message:  fmt.Sprintf("the size of %d bytes exceeds the maximum of %d bytes", len(jsonStr), p.maxSchemaSize),
+			})
+		}
+	}
+	return true
+}
+
+// selfCheckValues validates the values of the chart against its schema (see --self-check)
+func selfCheckValues(run *chartRun, outPath string) {
+	result := run.result
+	documents, err := result.ValuesDocuments()
+	if err == nil {
+		for _, values := range documents {
+			if err = result.Schema.ValidateValues(values, outPath); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		return
+	}
+	run.errorf("The values of chart %s (%s) don't validate against its schema", result.Chart.Name, result.ValuesPath)
+	for _, valueErr := range schema.ValueErrors(err) {
+		if file, line := result.Locate(valueErr.Path); line > 0 {
+			run.log.Errorf("%s:%d: %s", file, line, valueErr)
+		} else {
+			run.log.Error(valueErr)
+		}
+	}
+}
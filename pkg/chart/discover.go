@@ -0,0 +1,86 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the name of the file which marks the directory of a chart
+const FileName = "Chart.yaml"
+
+// Chart is a chart found by DiscoverCharts
+type Chart struct {
+	// Path is the path of the Chart.yaml
+	Path string
+	// File is the parsed Chart.yaml
+	File ChartFile
+	// ArchiveDir is the directory of a chart, which was extracted from an archive into a temp
+	// directory, as if the archive was unpacked where it is (e.g. charts/common for
+	// charts/common-1.0.0.tgz). It is empty for other charts.
+	ArchiveDir string
+}
+
+// Dir returns the directory of the chart, where its values file is searched
+func (c Chart) Dir() string {
+	return filepath.Dir(c.Path)
+}
+
+// LoadChart reads and parses the Chart.yaml at path
+func LoadChart(path string) (Chart, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Chart{}, err
+	}
+	defer file.Close()
+
+	chartFile, err := ReadChart(file)
+	if err != nil {
+		return Chart{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return Chart{Path: path, File: chartFile}, nil
+}
+
+// DiscoverCharts returns all charts below root (including root itself and the charts of
+// dependencies), which are found by their Chart.yaml. So charts are found regardless of the
+// name of their values file or whether they have one at all. The charts are ordered by their
+// path, parents come before their dependencies. Charts which can't be loaded are reported
+// in the returned error, the other charts are returned anyway.
+func DiscoverCharts(root string) ([]Chart, error) {
+	return DiscoverChartsContext(context.Background(), root)
+}
+
+// DiscoverChartsContext is DiscoverCharts, which stops searching when the context is done
+func DiscoverChartsContext(ctx context.Context, root string) ([]Chart, error) {
+	charts := []Chart{}
+	errs := []error{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if entry.IsDir() || entry.Name() != FileName {
+			return nil
+		}
+
+		chart, err := LoadChart(path)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		charts = append(charts, chart)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	return charts, errors.Join(errs...)
+}
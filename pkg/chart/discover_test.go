@@ -0,0 +1,71 @@
+package chart
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeChart(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverCharts(t *testing.T) {
+	root := t.TempDir()
+	writeChart(t, root, "apiVersion: v2\nname: parent\nversion: 1.0.0\n")
+	writeChart(t, filepath.Join(root, "charts", "library"), "apiVersion: v2\nname: library\nversion: 0.1.0\ntype: library\n")
+	if err := os.WriteFile(filepath.Join(root, "values-prod.yaml"), []byte("key: value\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	charts, err := DiscoverCharts(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(charts) != 2 {
+		t.Fatalf("Expected to find 2 charts, but got %d", len(charts))
+	}
+	if charts[0].File.Name != "parent" || charts[0].Dir() != root {
+		t.Errorf("Expected the parent chart in %s first, but got %s in %s", root, charts[0].File.Name, charts[0].Dir())
+	}
+	if charts[1].File.Name != "library" || charts[1].File.Type != "library" {
+		t.Errorf("Expected the library chart, but got %s (%s)", charts[1].File.Name, charts[1].File.Type)
+	}
+}
+
+func TestDiscoverChartsInvalidChart(t *testing.T) {
+	root := t.TempDir()
+	writeChart(t, root, "apiVersion: v2\nname: parent\nversion: 1.0.0\n")
+	writeChart(t, filepath.Join(root, "charts", "broken"), "name: [broken\n")
+
+	charts, err := DiscoverCharts(root)
+	if err == nil {
+		t.Fatal("Expected an error for the invalid chart")
+	}
+	if len(charts) != 1 || charts[0].File.Name != "parent" {
+		t.Errorf("Expected the valid chart to be returned, but got %v", charts)
+	}
+}
+
+func TestDiscoverChartsContextCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeChart(t, root, "apiVersion: v2\nname: parent\nversion: 1.0.0\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	charts, err := DiscoverChartsContext(ctx, root)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Was expecting context.Canceled, but got %v", err)
+	}
+	if len(charts) != 0 {
+		t.Errorf("Was expecting no charts, but got %v", charts)
+	}
+}
@@ -3,26 +3,28 @@ package searching
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"github.com/dadav/helm-schema/pkg/chart"
-	"gopkg.in/yaml.v3"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-func extractTGZ(src, dest string) error {
+// extractTGZ extracts the archive src into dest and returns the top-level entries of the archive
+// (e.g. the directory of a packaged chart)
+func extractTGZ(src, dest string) ([]string, error) {
 	file, err := os.Open(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
 	// Open gzip reader
 	gzr, err := gzip.NewReader(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer gzr.Close()
 
@@ -30,105 +32,103 @@ func extractTGZ(src, dest string) error {
 	tr := tar.NewReader(gzr)
 
 	// Extract files
+	topLevel := []string{}
+	seen := map[string]bool{}
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Resolve and sanitize file path
 		cleanName := filepath.Clean(header.Name)
 		// Prevent absolute paths
 		if filepath.IsAbs(cleanName) {
-			return fmt.Errorf("tar entry has absolute path: %s", cleanName)
+			return nil, fmt.Errorf("tar entry has absolute path: %s", cleanName)
 		}
 		// Prevent path traversal outside dest
 		target := filepath.Join(dest, cleanName)
 		rel, err := filepath.Rel(dest, target)
 		if err != nil {
-			return fmt.Errorf("failed to get relative path: %v", err)
+			return nil, fmt.Errorf("failed to get relative path: %v", err)
 		}
 		if strings.HasPrefix(rel, "..") || rel == ".." {
-			return fmt.Errorf("tar entry attempts to write outside destination: %s", cleanName)
+			return nil, fmt.Errorf("tar entry attempts to write outside destination: %s", cleanName)
+		}
+
+		if top, _, _ := strings.Cut(filepath.ToSlash(cleanName), "/"); !seen[top] {
+			seen[top] = true
+			topLevel = append(topLevel, top)
 		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
 			// Create directory if not exists
 			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
+				return nil, err
 			}
 		case tar.TypeReg:
 			// Ensure directory exists
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return err
+				return nil, err
 			}
 
 			// Create file
 			outFile, err := os.Create(target)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			// Copy file content
 			if _, err := io.Copy(outFile, tr); err != nil {
 				outFile.Close()
-				return err
+				return nil, err
 			}
 			if err := outFile.Close(); err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
-	return nil
+	return topLevel, nil
 }
 
-func SearchFiles(chartSearchRoot, startPath, fileName string, dependenciesFilter map[string]bool, queue chan<- string, errs chan<- error) {
-	defer close(queue)
-	err := filepath.Walk(startPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			errs <- err
-			return nil
-		}
-
-		if !info.IsDir() && info.Name() == fileName {
-			if filepath.Dir(path) == chartSearchRoot {
-				queue <- path
-				return nil
-			}
-
-			if len(dependenciesFilter) > 0 {
-				chartData, err := os.ReadFile(path)
-				if err != nil {
-					errs <- fmt.Errorf("failed to read Chart.yaml at %s: %w", path, err)
-					return nil
-				}
-
-				var chartFile chart.ChartFile
-				if err := yaml.Unmarshal(chartData, &chartFile); err != nil {
-					errs <- fmt.Errorf("failed to parse Chart.yaml at %s: %w", path, err)
-					return nil
-				}
+// SearchCharts returns the chart at chartSearchRoot and the charts below it. If dependenciesFilter
+// isn't empty, only the charts with these names are returned besides the chart at chartSearchRoot.
+func SearchCharts(ctx context.Context, chartSearchRoot string, dependenciesFilter map[string]bool) ([]chart.Chart, error) {
+	charts, err := chart.DiscoverChartsContext(ctx, chartSearchRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(dependenciesFilter) == 0 {
+		return charts, nil
+	}
 
-				if dependenciesFilter[chartFile.Name] {
-					queue <- path
-				}
-			} else {
-				queue <- path
-			}
+	filtered := []chart.Chart{}
+	for _, c := range charts {
+		if c.Dir() == filepath.Clean(chartSearchRoot) || dependenciesFilter[c.File.Name] {
+			filtered = append(filtered, c)
 		}
-
-		return nil
-	})
-	if err != nil {
-		errs <- err
 	}
+	return filtered, nil
 }
 
+// SearchArchivesOpenTemp extracts the chart archives below startPath (e.g. charts/common-1.0.0.tgz)
+// into a temp directory next to the first archive, so their charts are found by SearchCharts.
+// The caller removes the returned directory, which is empty if there are no archives.
 func SearchArchivesOpenTemp(startPath string, errs chan<- error) string {
+	tempDir, _ := ExtractArchives(startPath, errs)
+	return tempDir
+}
+
+// ExtractArchives is SearchArchivesOpenTemp, which also returns where the extracted charts are
+// located in the search root: the directories in the temp directory are mapped to the directories
+// the archives would be unpacked to (e.g. charts/common). The extracted files are deleted with the
+// temp directory, so paths which are kept in a schema (e.g. file references) must use the latter.
+func ExtractArchives(startPath string, errs chan<- error) (string, map[string]string) {
 	tempDir := ""
+	origins := map[string]string{}
 	err := filepath.Walk(startPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			errs <- err
@@ -144,17 +144,34 @@ func SearchArchivesOpenTemp(startPath string, errs chan<- error) string {
 					return nil
 				}
 			}
-			err = extractTGZ(path, tempDir)
+			topLevel, err := extractTGZ(path, tempDir)
 			if err != nil {
 				errs <- err
 				return nil
 			}
-
+			for _, top := range topLevel {
+				origins[filepath.Join(tempDir, top)] = filepath.Join(filepath.Dir(path), top)
+			}
 		}
 		return nil
 	})
 	if err != nil {
 		errs <- err
 	}
-	return tempDir
+	return tempDir, origins
+}
+
+// ArchiveDirs sets the ArchiveDir of the charts, which were extracted by ExtractArchives,
+// from its origins. Charts nested in an extracted chart are located relative to it.
+func ArchiveDirs(charts []chart.Chart, origins map[string]string) {
+	for i := range charts {
+		for extracted, origin := range origins {
+			rel, err := filepath.Rel(extracted, charts[i].Dir())
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			charts[i].ArchiveDir = filepath.Join(origin, rel)
+			break
+		}
+	}
 }
@@ -0,0 +1,83 @@
+package searching
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeArchive(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchivesArchiveDirs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "Chart.yaml"), []byte("apiVersion: v2\nname: parent\nversion: 1.0.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "charts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeArchive(t, filepath.Join(root, "charts", "dep-1.0.0.tgz"), map[string]string{
+		"dep/Chart.yaml":            "apiVersion: v2\nname: dep\nversion: 1.0.0\n",
+		"dep/charts/sub/Chart.yaml": "apiVersion: v2\nname: sub\nversion: 1.0.0\n",
+		"dep/values.yaml":           "port: 80\n",
+	})
+
+	errs := make(chan error, 10)
+	tempDir, origins := ExtractArchives(root, errs)
+	if tempDir == "" {
+		t.Fatal("expected the archive to be extracted")
+	}
+	defer os.RemoveAll(tempDir)
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	charts, err := SearchCharts(context.Background(), root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ArchiveDirs(charts, origins)
+
+	archiveDirs := map[string]string{}
+	for _, c := range charts {
+		archiveDirs[c.File.Name] = c.ArchiveDir
+	}
+	expected := map[string]string{
+		"parent": "",
+		"dep":    filepath.Join(root, "charts", "dep"),
+		"sub":    filepath.Join(root, "charts", "dep", "charts", "sub"),
+	}
+	for name, dir := range expected {
+		if archiveDirs[name] != dir {
+			t.Errorf("expected the archive dir of %s to be %q, got %q", name, dir, archiveDirs[name])
+		}
+	}
+}
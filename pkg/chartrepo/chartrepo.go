@@ -0,0 +1,402 @@
+// Package chartrepo downloads the released versions of charts from chart repositories
+// (a http server with an index.yaml) and OCI registries (oci://<registry>/<path>).
+package chartrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/dadav/helm-schema/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// OCIPrefix is the prefix of the urls of OCI registries
+const OCIPrefix = "oci://"
+
+// DefaultMaxArchiveSize is the default maximum size of downloaded chart archives
+const DefaultMaxArchiveSize = 50 << 20
+
+// maxTokenSize limits the size of the token responses of OCI registries
+const maxTokenSize = 1 << 20
+
+// helmChartLayer is the media type of the layer of OCI artifacts, which contains the chart archive
+const helmChartLayer = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+// Client lists and pulls the versions of charts of a repository.
+// It is not safe to change its settings while pulling.
+type Client struct {
+	repoURL            *url.URL
+	oci                bool
+	username, password string
+	httpClient         *http.Client
+	maxSize            int64
+
+	// index is the parsed index.yaml of a chart repository
+	index *repoIndex
+	// tokens are the bearer tokens of the OCI registry by scope
+	tokens map[string]string
+}
+
+// repoIndex is the index.yaml of a chart repository
+type repoIndex struct {
+	Entries map[string][]struct {
+		Version string   `yaml:"version"`
+		URLs    []string `yaml:"urls"`
+	} `yaml:"entries"`
+}
+
+// NewClient returns a client for the chart repository or OCI registry at repoURL
+// (http, https or oci). If a username is given, the requests are authenticated with it.
+func NewClient(repoURL, username, password string) (*Client, error) {
+	oci := strings.HasPrefix(repoURL, OCIPrefix)
+	if oci {
+		repoURL = "https://" + strings.TrimPrefix(repoURL, OCIPrefix)
+	}
+	u, err := url.Parse(strings.TrimSuffix(repoURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart repository url %s: %w", repoURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid chart repository url %s: must be a http, https or oci url", repoURL)
+	}
+
+	return &Client{
+		repoURL:    u,
+		oci:        oci,
+		username:   username,
+		password:   password,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+		maxSize:    DefaultMaxArchiveSize,
+		tokens:     make(map[string]string),
+	}, nil
+}
+
+// SetPolicy refuses redirects to urls the policy doesn't allow and connections to addresses it
+// doesn't allow. The url of the repository itself is configured explicitly, so it is not checked.
+func (c *Client) SetPolicy(policy *urlpolicy.Policy) {
+	c.httpClient.CheckRedirect = policy.CheckRedirect
+	c.httpClient.Transport = policy.Transport(c.repoURL.Hostname())
+}
+
+// SetMaxSize sets the maximum size in bytes of downloaded archives and indexes
+// (DefaultMaxArchiveSize if size is not positive)
+func (c *Client) SetMaxSize(size int64) {
+	if size <= 0 {
+		size = DefaultMaxArchiveSize
+	}
+	c.maxSize = size
+}
+
+// Versions returns all released versions of the chart
+func (c *Client) Versions(ctx context.Context, chart string) ([]string, error) {
+	if c.oci {
+		var tags struct {
+			Tags []string `json:"tags"`
+		}
+		data, err := c.get(ctx, c.ociURL(chart, "tags", "list"), chart, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &tags); err != nil {
+			return nil, fmt.Errorf("could not parse the tags of chart %s: %w", chart, err)
+		}
+		versions := make([]string, 0, len(tags.Tags))
+		for _, tag := range tags.Tags {
+			// OCI tags can't contain +, helm replaces it with _
+			versions = append(versions, strings.ReplaceAll(tag, "_", "+"))
+		}
+		return versions, nil
+	}
+
+	index, err := c.loadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	for _, entry := range index.Entries[chart] {
+		versions = append(versions, entry.Version)
+	}
+	return versions, nil
+}
+
+// Pull returns the archive (.tgz) of the chart in the given version
+func (c *Client) Pull(ctx context.Context, chart, version string) ([]byte, error) {
+	if c.oci {
+		return c.pullOCI(ctx, chart, version)
+	}
+
+	index, err := c.loadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range index.Entries[chart] {
+		if entry.Version != version || len(entry.URLs) == 0 {
+			continue
+		}
+		// the urls can be relative to the repository
+		archiveURL, err := c.repoURL.JoinPath("index.yaml").Parse(entry.URLs[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid url %s of chart %s in version %s: %w", entry.URLs[0], chart, version, err)
+		}
+		return c.get(ctx, archiveURL.String(), chart, "")
+	}
+	return nil, fmt.Errorf("chart %s in version %s not found in %s", chart, version, c.repoURL.Redacted())
+}
+
+func (c *Client) loadIndex(ctx context.Context) (*repoIndex, error) {
+	if c.index != nil {
+		return c.index, nil
+	}
+	data, err := c.get(ctx, c.repoURL.JoinPath("index.yaml").String(), "", "")
+	if err != nil {
+		return nil, err
+	}
+	var index repoIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("could not parse the index of %s: %w", c.repoURL.Redacted(), err)
+	}
+	c.index = &index
+	return c.index, nil
+}
+
+func (c *Client) pullOCI(ctx context.Context, chart, version string) ([]byte, error) {
+	tag := strings.ReplaceAll(version, "+", "_")
+	data, err := c.get(ctx, c.ociURL(chart, "manifests", tag), chart, "application/vnd.oci.image.manifest.v1+json")
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse the manifest of chart %s in version %s: %w", chart, version, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != helmChartLayer {
+			continue
+		}
+		algorithm, digest, ok := strings.Cut(layer.Digest, ":")
+		if !ok || algorithm != "sha256" {
+			return nil, fmt.Errorf("unsupported digest %s of chart %s in version %s", layer.Digest, chart, version)
+		}
+		archive, err := c.get(ctx, c.ociURL(chart, "blobs", layer.Digest), chart, "")
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(archive)
+		if hex.EncodeToString(sum[:]) != digest {
+			return nil, fmt.Errorf("the archive of chart %s in version %s doesn't match its digest %s", chart, version, layer.Digest)
+		}
+		return archive, nil
+	}
+	return nil, fmt.Errorf("the OCI artifact of chart %s in version %s contains no chart", chart, version)
+}
+
+// ociURL returns the url of the registry api for the repository of the chart
+func (c *Client) ociURL(chart string, elem ...string) string {
+	u := *c.repoURL
+	u.Path = "/v2" + path.Join("/", c.repoURL.Path, chart)
+	return u.JoinPath(elem...).String()
+}
+
+// get downloads the url. Requests to OCI registries are retried with a bearer token,
+// if the registry asks for one.
+func (c *Client) get(ctx context.Context, rawURL, chart, accept string) ([]byte, error) {
+	credentials := util.Credentials{}
+	if !c.oci {
+		credentials = util.Credentials{Username: c.username, Password: c.password}
+	}
+	data, _, err := util.Download(ctx, c.httpClient, rawURL, accept, credentials, c.maxSize)
+
+	var statusErr *util.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized && c.oci {
+		token, tokenErr := c.token(ctx, statusErr.Header.Get("WWW-Authenticate"))
+		if tokenErr != nil {
+			return nil, tokenErr
+		}
+		data, _, err = util.Download(ctx, c.httpClient, rawURL, accept, util.Credentials{Token: token}, c.maxSize)
+	}
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound && chart != "" {
+		return nil, fmt.Errorf("chart %s not found (%s)", chart, rawURL)
+	}
+	return data, err
+}
+
+// token requests a bearer token like the challenge of the registry asks for it, e.g.
+// Bearer realm="https://auth.example.com/token",service="registry",scope="repository:charts/app:pull"
+func (c *Client) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication %q of the OCI registry %s", scheme, c.repoURL.Host)
+	}
+	values := parseChallenge(params)
+	if token, ok := c.tokens[values["scope"]]; ok {
+		return token, nil
+	}
+
+	realm, err := url.Parse(values["realm"])
+	if err != nil || (realm.Scheme != "https" && realm.Scheme != "http") {
+		return "", fmt.Errorf("invalid token realm %q of the OCI registry %s", values["realm"], c.repoURL.Host)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	data, _, err := util.Download(ctx, c.httpClient, realm.String(), "", util.Credentials{Username: c.username, Password: c.password}, maxTokenSize)
+	if err != nil {
+		return "", fmt.Errorf("could not get a token for the OCI registry %s: %w", c.repoURL.Host, err)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", fmt.Errorf("could not parse the token of the OCI registry %s: %w", c.repoURL.Host, err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("the OCI registry %s returned no token", c.repoURL.Host)
+	}
+	c.tokens[values["scope"]] = token
+	return token, nil
+}
+
+// parseChallenge parses the comma separated key="value" pairs of a WWW-Authenticate header
+func parseChallenge(params string) map[string]string {
+	values := map[string]string{}
+	for params != "" {
+		key, rest, ok := strings.Cut(strings.TrimLeft(params, ", "), "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = value
+		params = rest
+	}
+	return values
+}
+
+// PreviousVersions returns the n highest versions, which are not newer than the current one
+// (newest first). Pre-releases and versions which aren't semantic versions are ignored.
+func PreviousVersions(versions []string, current string, n int) ([]string, error) {
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart version %s: %w", current, err)
+	}
+
+	released := []*semver.Version{}
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil || v.Prerelease() != "" || v.GreaterThan(currentVersion) {
+			continue
+		}
+		if !slices.ContainsFunc(released, v.Equal) {
+			released = append(released, v)
+		}
+	}
+	slices.SortFunc(released, func(a, b *semver.Version) int { return b.Compare(a) })
+
+	previous := []string{}
+	for _, v := range released[:min(n, len(released))] {
+		previous = append(previous, v.Original())
+	}
+	return previous, nil
+}
+
+// maxExtractedSize limits the size of the extracted files of an archive
+const maxExtractedSize = 256 << 20
+
+// Extract extracts the chart archive (.tgz) into dir and returns the directory of the chart
+// (the directory of the archive containing the Chart.yaml)
+func Extract(archive []byte, dir string) (string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return "", fmt.Errorf("invalid chart archive: %w", err)
+	}
+	defer gz.Close()
+
+	chartDir := ""
+	remaining := int64(maxExtractedSize)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid chart archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return "", fmt.Errorf("the chart archive contains the file %s outside of its directory", header.Name)
+		}
+		if remaining -= header.Size; remaining < 0 {
+			return "", fmt.Errorf("the files of the chart archive have more than %d bytes", maxExtractedSize)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", err
+		}
+		file, err := os.Create(target)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(file, io.LimitReader(tr, header.Size)); err != nil {
+			file.Close()
+			return "", err
+		}
+		if err := file.Close(); err != nil {
+			return "", err
+		}
+
+		// the files of the chart are in a directory named like the chart, dependencies below it
+		if first, rest, ok := strings.Cut(name, "/"); ok && rest == "Chart.yaml" {
+			chartDir = filepath.Join(dir, first)
+		}
+	}
+
+	if chartDir == "" {
+		return "", errors.New("the chart archive contains no Chart.yaml")
+	}
+	return chartDir, nil
+}
@@ -0,0 +1,228 @@
+package chartrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testArchive returns a chart archive with the files (names relative to the archive)
+func testArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestClientHTTPRepository(t *testing.T) {
+	archive := testArchive(t, map[string]string{"app/Chart.yaml": "name: app\nversion: 1.0.0\n"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/charts/index.yaml":
+			_, _ = w.Write([]byte(`entries:
+  app:
+    - version: 1.1.0
+      urls: [packages/app-1.1.0.tgz]
+    - version: 1.0.0
+      urls: [packages/app-1.0.0.tgz]
+`))
+		case "/charts/packages/app-1.0.0.tgz":
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/charts/", "user", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := client.Versions(context.Background(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(versions, []string{"1.1.0", "1.0.0"}) {
+		t.Errorf("Was expecting the versions 1.1.0 and 1.0.0, but got %v", versions)
+	}
+
+	pulled, err := client.Pull(context.Background(), "app", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pulled, archive) {
+		t.Errorf("Was expecting the archive of version 1.0.0")
+	}
+
+	if _, err := client.Pull(context.Background(), "app", "2.0.0"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Was expecting an error for a missing version, but got %v", err)
+	}
+	// the index lists the version, but the archive is missing
+	if _, err := client.Pull(context.Background(), "app", "1.1.0"); err == nil {
+		t.Errorf("Was expecting an error for a missing archive")
+	}
+
+	client.SetMaxSize(10)
+	if _, err := client.Pull(context.Background(), "app", "1.0.0"); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("Was expecting an error for a too large archive, but got %v", err)
+	}
+}
+
+func TestClientOCIRegistry(t *testing.T) {
+	archive := testArchive(t, map[string]string{"app/Chart.yaml": "name: app\nversion: 1.0.0+build.1\n"})
+	sum := sha256.Sum256(archive)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	tokens := 0
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			if r.URL.Query().Get("scope") != "repository:org/charts/app:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token": "registry-token"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:org/charts/app:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/charts/app/tags/list":
+			_, _ = w.Write([]byte(`{"name": "org/charts/app", "tags": ["1.0.0_build.1", "latest"]}`))
+		case "/v2/org/charts/app/manifests/1.0.0_build.1":
+			_, _ = fmt.Fprintf(w, `{"layers": [{"mediaType": "application/vnd.cncf.helm.chart.provenance.v1.prov", "digest": "sha256:00"}, {"mediaType": "%s", "digest": "%s"}]}`, helmChartLayer, digest)
+		case "/v2/org/charts/app/blobs/" + digest:
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("oci://"+strings.TrimPrefix(server.URL, "https://")+"/org/charts", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.httpClient.Transport = server.Client().Transport
+
+	versions, err := client.Versions(context.Background(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(versions, []string{"1.0.0+build.1", "latest"}) {
+		t.Errorf("Was expecting the tags as versions, but got %v", versions)
+	}
+
+	pulled, err := client.Pull(context.Background(), "app", "1.0.0+build.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pulled, archive) {
+		t.Errorf("Was expecting the archive of the chart layer")
+	}
+	if tokens != 1 {
+		t.Errorf("Was expecting the token to be requested once, but it was requested %d times", tokens)
+	}
+}
+
+func TestNewClientInvalidURL(t *testing.T) {
+	for _, repoURL := range []string{"ftp://charts.example.com", "charts.example.com", "oci://"} {
+		if _, err := NewClient(repoURL, "", ""); err == nil {
+			t.Errorf("Was expecting an error for %s", repoURL)
+		}
+	}
+}
+
+func TestPreviousVersions(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.1.0", "2.0.0", "1.3.0-rc.1", "latest", "v1.2.0", "1.2.1"}
+
+	previous, err := PreviousVersions(versions, "1.2.1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(previous, []string{"1.2.1", "1.2.0", "1.1.0"}) {
+		t.Errorf("Was expecting the versions 1.2.1, 1.2.0 and 1.1.0, but got %v", previous)
+	}
+
+	previous, err = PreviousVersions(versions, "0.1.0", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous) != 0 {
+		t.Errorf("Was expecting no versions, but got %v", previous)
+	}
+
+	if _, err := PreviousVersions(versions, "next", 3); err == nil {
+		t.Errorf("Was expecting an error for an invalid chart version")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	archive := testArchive(t, map[string]string{
+		"app/Chart.yaml":               "name: app\n",
+		"app/values.yaml":              "key: value\n",
+		"app/charts/dep/Chart.yaml":    "name: dep\n",
+		"app/charts/dep/values.yaml":   "other: value\n",
+		"app/templates/deployment.yml": "kind: Deployment\n",
+	})
+
+	chartDir, err := Extract(archive, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chartDir != filepath.Join(dir, "app") {
+		t.Errorf("Was expecting the chart directory %s, but got %s", filepath.Join(dir, "app"), chartDir)
+	}
+	values, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+	if err != nil || string(values) != "key: value\n" {
+		t.Errorf("Was expecting the values file to be extracted, but got %q (%v)", values, err)
+	}
+
+	if _, err := Extract(testArchive(t, map[string]string{"../evil/Chart.yaml": "name: evil\n"}), dir); err == nil {
+		t.Errorf("Was expecting an error for a file outside of the directory")
+	}
+	if _, err := Extract(testArchive(t, map[string]string{"app/values.yaml": ""}), t.TempDir()); err == nil {
+		t.Errorf("Was expecting an error for an archive without Chart.yaml")
+	}
+	if _, err := Extract([]byte("not an archive"), dir); err == nil {
+		t.Errorf("Was expecting an error for an invalid archive")
+	}
+}
@@ -0,0 +1,269 @@
+// Package gitref resolves references to schemas in git repositories at a revision,
+// e.g. git+https://github.com/org/repo.git@v1.2.3/schemas/common.json. The repositories
+// are fetched shallowly without the files, which aren't referenced, with the git command line tool
+// and cached on disk.
+package gitref
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dadav/helm-schema/pkg/refmetrics"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+// RefPrefix is the prefix of references to schemas in git repositories,
+// e.g. git+https://github.com/org/repo.git@v1.2.3/schemas/common.json#/$defs/image
+const RefPrefix = "git+"
+
+// fetchedMarker is created in the git directory of a checkout, once the revision was fetched
+const fetchedMarker = "helm-schema-fetched"
+
+// Ref is a parsed git reference
+type Ref struct {
+	// Repository is the url of the repository (without the git+ prefix)
+	Repository string
+	// Revision is the tag, branch or commit
+	Revision string
+	// Path is the path of the schema file in the repository
+	Path string
+}
+
+func (r Ref) String() string {
+	return RefPrefix + r.Repository + "@" + r.Revision + "/" + r.Path
+}
+
+// IsRef checks if the reference (without json pointer) points to a git repository
+func IsRef(ref string) bool {
+	return strings.HasPrefix(ref, RefPrefix) && strings.Contains(ref, "://")
+}
+
+// ParseRef parses a reference (without json pointer) of the form
+// git+<repository url>@<revision>/<path>. The revision can't contain slashes.
+func ParseRef(ref string) (Ref, error) {
+	if !IsRef(ref) {
+		return Ref{}, fmt.Errorf("%s is not a git reference", ref)
+	}
+
+	repository := strings.TrimPrefix(ref, RefPrefix)
+	// the last @ separates the revision, the url can contain one as well (e.g. ssh://git@host/repo.git)
+	at := strings.LastIndex(repository, "@")
+	if at < 0 || at < strings.Index(repository, "://") {
+		return Ref{}, errors.New("git references must look like git+<repository url>@<revision>/<path>")
+	}
+	revision, filePath, _ := strings.Cut(repository[at+1:], "/")
+	repository = repository[:at]
+	if _, hostAndPath, _ := strings.Cut(repository, "://"); !strings.Contains(hostAndPath, "/") {
+		return Ref{}, fmt.Errorf("invalid git reference %s: the revision is missing", ref)
+	}
+
+	filePath = path.Clean(filePath)
+	if revision == "" || filePath == "." || !filepath.IsLocal(filepath.FromSlash(filePath)) {
+		return Ref{}, fmt.Errorf("invalid git reference %s: the revision and a relative path in the repository are required", ref)
+	}
+	if strings.HasPrefix(revision, "-") {
+		return Ref{}, fmt.Errorf("invalid revision %s of the git reference %s", revision, ref)
+	}
+	return Ref{Repository: repository, Revision: revision, Path: filePath}, nil
+}
+
+// Fetcher fetches the referenced files. Each file of a revision of a repository is only fetched once
+// and kept in the cache directory, so revisions should be tags or commits. Branches are not updated
+// until the cache directory is removed. It is safe to use a fetcher concurrently.
+type Fetcher struct {
+	cacheDir string
+	policy   *urlpolicy.Policy
+	maxSize  int64
+	metrics  *refmetrics.Metrics
+
+	mu sync.Mutex
+}
+
+// NewFetcher returns a fetcher which caches the repositories in cacheDir.
+// If cacheDir is empty, helm-schema/git in the user cache directory is used.
+// The repositories are restricted by the zero urlpolicy.Policy, see SetPolicy.
+func NewFetcher(cacheDir string) *Fetcher {
+	return &Fetcher{cacheDir: cacheDir, policy: &urlpolicy.Policy{}, maxSize: util.DefaultMaxDownloadSize}
+}
+
+// SetPolicy sets the policy the urls of the repositories are checked with.
+// It must not be called while fetching.
+func (f *Fetcher) SetPolicy(policy *urlpolicy.Policy) {
+	f.policy = policy
+}
+
+// SetMaxSize sets the maximum size in bytes of fetched files (util.DefaultMaxDownloadSize if
+// size is not positive). It must not be called while fetching.
+func (f *Fetcher) SetMaxSize(size int64) {
+	if size <= 0 {
+		size = util.DefaultMaxDownloadSize
+	}
+	f.maxSize = size
+}
+
+// SetMetrics sets the metrics the cached revisions and the fetches are counted in (none if nil).
+// It must not be called while fetching.
+func (f *Fetcher) SetMetrics(metrics *refmetrics.Metrics) {
+	f.metrics = metrics
+}
+
+// Fetch returns the content of the referenced schema file
+func (f *Fetcher) Fetch(ref Ref) ([]byte, error) {
+	return f.FetchContext(context.Background(), ref)
+}
+
+// FetchContext returns the content of the referenced schema file,
+// git is stopped when the context is done
+func (f *Fetcher) FetchContext(ctx context.Context, ref Ref) ([]byte, error) {
+	if err := f.policy.Check(ctx, ref.Repository); err != nil {
+		return nil, fmt.Errorf("could not fetch %s: %w", ref, err)
+	}
+	start := time.Now()
+	checkout, fetched, err := f.checkout(ctx, ref)
+	if err != nil {
+		f.metrics.Download(refmetrics.SourceGit, ref.Repository, 0, time.Since(start), err)
+		return nil, fmt.Errorf("could not fetch %s: %w", ref, err)
+	}
+
+	file := filepath.Join(checkout, filepath.FromSlash(ref.Path))
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > f.maxSize {
+		return nil, fmt.Errorf("the schema %s has %d bytes, more than the limit of %d bytes", ref, info.Size(), f.maxSize)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := util.CheckDownloadedSchema(ref.String(), content); err != nil {
+		return nil, err
+	}
+	// the size of the schema, git doesn't report the size of the fetched revision
+	if fetched {
+		f.metrics.Download(refmetrics.SourceGit, ref.Repository, len(content), time.Since(start), nil)
+	} else {
+		f.metrics.Hit(refmetrics.SourceGit)
+	}
+	return content, nil
+}
+
+// checkout returns the directory the revision of the repository is checked out in and
+// whether the revision was fetched (instead of being cached already)
+func (f *Fetcher) checkout(ctx context.Context, ref Ref) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cacheDir := f.cacheDir
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", false, err
+		}
+		cacheDir = filepath.Join(userCacheDir, "helm-schema", "git")
+	}
+
+	key := sha256.Sum256([]byte(ref.Repository + "@" + ref.Revision + "/" + ref.Path))
+	dir := filepath.Join(cacheDir, hex.EncodeToString(key[:16]))
+	if _, err := os.Stat(filepath.Join(dir, ".git", fetchedMarker)); err == nil {
+		return dir, false, nil
+	}
+
+	// a previous fetch might have been interrupted
+	if err := os.RemoveAll(dir); err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", false, err
+	}
+	if err := f.fetch(ctx, dir, ref); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", false, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".git", fetchedMarker), nil, 0o644); err != nil {
+		return "", false, err
+	}
+	return dir, true, nil
+}
+
+// fetch fetches the revision of the repository into dir without the history and the content of
+// the files (a shallow partial clone) and checks out only the referenced file. The file isn't
+// downloaded, if it is larger than the limit, so large repositories only cost their tree.
+func (f *Fetcher) fetch(ctx context.Context, dir string, ref Ref) error {
+	// the targets of redirects can't be checked against the policy, so they are refused,
+	// also for the files git fetches lazily while checking out
+	noRedirects := []string{"-c", "http.followRedirects=false"}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "--", "origin", ref.Repository},
+		{"config", "remote.origin.promisor", "true"},
+		{"config", "remote.origin.partialclonefilter", "blob:none"},
+		append(noRedirects, "fetch", "--quiet", "--depth", "1", "--filter=blob:none", "--no-tags", "--", "origin", ref.Revision),
+	} {
+		if _, err := f.git(ctx, dir, args...); err != nil {
+			return err
+		}
+	}
+
+	// e.g. 100644 blob 0187f3b09d66cef7911083ad49f890f5a6589589       8	schemas/common.json
+	entry, err := f.git(ctx, dir, "ls-tree", "-l", "--full-tree", "FETCH_HEAD", "--", ref.Path)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(entry)
+	if len(fields) < 4 || fields[1] != "blob" {
+		return fmt.Errorf("the file %s doesn't exist in revision %s", ref.Path, ref.Revision)
+	}
+	if size, err := strconv.ParseInt(fields[3], 10, 64); err != nil || size > f.maxSize {
+		return fmt.Errorf("the file %s has %s bytes, more than the limit of %d bytes", ref.Path, fields[3], f.maxSize)
+	}
+
+	if _, err := f.git(ctx, dir, "config", "core.sparseCheckout", "true"); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git", "info", "sparse-checkout"), []byte(sparsePattern(ref.Path)+"\n"), 0o644); err != nil {
+		return err
+	}
+	_, err = f.git(ctx, dir, append(noRedirects, "checkout", "--quiet", "--detach", "FETCH_HEAD")...)
+	return err
+}
+
+// sparsePattern returns the sparse checkout pattern, which only matches the file at the path
+func sparsePattern(filePath string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "!", `\!`, "#", `\#`).Replace(filePath)
+	return "/" + escaped
+}
+
+// git runs the git command in dir and returns its output
+func (f *Fetcher) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		// never ask for credentials, helm-schema doesn't run interactively
+		"GIT_TERMINAL_PROMPT=0",
+		// git must not use other protocols than the policy allows, e.g. for submodules
+		"GIT_ALLOW_PROTOCOL="+strings.Join(f.policy.Schemes(), ":"),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
@@ -0,0 +1,219 @@
+package gitref
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+)
+
+// largeFileSize is the size of the large file of the test repository
+const largeFileSize = 4 << 20
+
+// newTestRepository creates a repository with a schema file and a large file, which is tagged
+// with v1.0.0 and changed afterwards, and returns its file url
+func newTestRepository(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %s", args, err, output)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, "schemas"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "schemas", "common.json"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "--quiet")
+	// partial clones are only served if filters are allowed
+	run("config", "uploadpack.allowFilter", "true")
+	write(`{"type": "integer"}`)
+	if err := os.WriteFile(filepath.Join(dir, "schemas", "large.json"), bytes.Repeat([]byte(" "), largeFileSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".")
+	run("commit", "--quiet", "-m", "v1")
+	run("tag", "v1.0.0")
+	write(`{"type": "string"}`)
+	run("commit", "--quiet", "-am", "v2")
+
+	return "file://" + filepath.ToSlash(dir)
+}
+
+func TestParseRef(t *testing.T) {
+	tests := map[string]Ref{
+		"git+https://github.com/org/repo.git@v1.2.3/schemas/common.json": {Repository: "https://github.com/org/repo.git", Revision: "v1.2.3", Path: "schemas/common.json"},
+		"git+ssh://git@github.com/org/repo.git@abc123/common.json":       {Repository: "ssh://git@github.com/org/repo.git", Revision: "abc123", Path: "common.json"},
+	}
+	for ref, expected := range tests {
+		parsed, err := ParseRef(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != expected {
+			t.Errorf("Was expecting %v for %s, but got %v", expected, ref, parsed)
+		}
+		if parsed.String() != ref {
+			t.Errorf("Was expecting %s, but got %s", ref, parsed.String())
+		}
+	}
+
+	for _, ref := range []string{
+		"https://github.com/org/repo.git@v1/common.json",
+		"git+https://github.com/org/repo.git/common.json",
+		"git+https://github.com/org/repo.git@v1",
+		"git+https://github.com/org/repo.git@/common.json",
+		"git+https://github.com/org/repo.git@v1/../common.json",
+		"git+https://github.com/org/repo.git@--upload-pack=x/common.json",
+		"git+ssh://git@github.com/org/repo.git",
+	} {
+		if _, err := ParseRef(ref); err == nil {
+			t.Errorf("Was expecting an error for %s", ref)
+		}
+	}
+}
+
+func TestFetcherFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repository := newTestRepository(t)
+	cacheDir := t.TempDir()
+	policy := &urlpolicy.Policy{AllowedSchemes: []string{"file"}}
+	fetcher := NewFetcher(cacheDir)
+	fetcher.SetPolicy(policy)
+
+	ref, err := ParseRef("git+" + repository + "@v1.0.0/schemas/common.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := fetcher.Fetch(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `{"type": "integer"}` {
+		t.Errorf("Was expecting the schema of v1.0.0, but got %s", content)
+	}
+
+	// the checkout is reused from the cache directory
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Was expecting one cached checkout, but got %v (%v)", entries, err)
+	}
+	// the large file isn't referenced, so it isn't fetched
+	if size := dirSize(t, filepath.Join(cacheDir, entries[0].Name())); size > largeFileSize/4 {
+		t.Errorf("Was expecting the checkout to contain only the referenced file, but it has %d bytes", size)
+	}
+	if err := os.WriteFile(filepath.Join(cacheDir, entries[0].Name(), "schemas", "common.json"), []byte(`{"type": "boolean"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cached := NewFetcher(cacheDir)
+	cached.SetPolicy(policy)
+	content, err = cached.Fetch(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `{"type": "boolean"}` {
+		t.Errorf("Was expecting the cached schema, but got %s", content)
+	}
+
+	cached.SetMaxSize(8)
+	if _, err := cached.Fetch(ref); err == nil {
+		t.Error("Was expecting an error for a schema larger than the limit")
+	}
+
+	ref.Revision = "v9.9.9"
+	if _, err := fetcher.Fetch(ref); err == nil {
+		t.Error("Was expecting an error for an unknown revision")
+	}
+
+	// files larger than the limit aren't downloaded
+	large, err := ParseRef("git+" + repository + "@v1.0.0/schemas/large.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher.SetMaxSize(largeFileSize / 2)
+	if _, err := fetcher.Fetch(large); err == nil || !strings.Contains(err.Error(), "more than the limit") {
+		t.Errorf("Was expecting an error for a file larger than the limit, but got %v", err)
+	}
+	if entries, err := os.ReadDir(cacheDir); err != nil || len(entries) != 1 {
+		t.Errorf("Was expecting no checkout of the large file, but got %v (%v)", entries, err)
+	}
+
+	missing, err := ParseRef("git+" + repository + "@v1.0.0/schemas/missing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetcher.Fetch(missing); err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Errorf("Was expecting an error for a missing file, but got %v", err)
+	}
+}
+
+// dirSize returns the size of the files below dir
+func dirSize(t *testing.T, dir string) int64 {
+	t.Helper()
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return size
+}
+
+func TestFetcherPolicy(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repository := newTestRepository(t)
+	cacheDir := t.TempDir()
+
+	// file urls are not allowed by default
+	ref, err := ParseRef("git+" + repository + "@v1.0.0/schemas/common.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFetcher(cacheDir).Fetch(ref); err == nil {
+		t.Error("Was expecting an error for a file url")
+	}
+
+	ref, err = ParseRef("git+http://169.254.169.254/org/repo.git@v1.0.0/schemas/common.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFetcher(cacheDir).Fetch(ref); err == nil {
+		t.Error("Was expecting an error for the metadata address")
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Was expecting nothing to be fetched, but got %v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// locationPattern matches the location prefix of messages about a line of a file, e.g. values.yaml:12: ...
+var locationPattern = regexp.MustCompile(`(?s)^([^\s:]+):(\d+): (.*)$`)
+
+// GitHubHook is a logrus hook, which writes warnings and errors as GitHub Actions workflow
+// commands (::warning and ::error), so they are shown as annotations of the workflow run
+// and of the changed lines of pull requests. Messages starting with <file>:<line>: are
+// annotated at that line.
+type GitHubHook struct {
+	Out io.Writer
+}
+
+// NewGitHubHook returns a hook writing the workflow commands to out (e.g. os.Stdout)
+func NewGitHubHook(out io.Writer) *GitHubHook {
+	return &GitHubHook{Out: out}
+}
+
+func (h *GitHubHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+}
+
+func (h *GitHubHook) Fire(entry *logrus.Entry) error {
+	command := "error"
+	if entry.Level == logrus.WarnLevel {
+		command = "warning"
+	}
+	_, err := fmt.Fprintln(h.Out, GitHubCommand(command, entry.Message))
+	return err
+}
+
+// GitHubCommand formats the message as workflow command, e.g. ::error file=values.yaml,line=12::message
+func GitHubCommand(command, message string) string {
+	var properties string
+	if match := locationPattern.FindStringSubmatch(message); match != nil {
+		properties = fmt.Sprintf(" file=%s,line=%s", escapeGitHubProperty(match[1]), match[2])
+		message = match[3]
+	}
+	return fmt.Sprintf("::%s%s::%s", command, properties, escapeGitHubData(message))
+}
+
+func escapeGitHubData(data string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(data)
+}
+
+func escapeGitHubProperty(property string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeGitHubData(property))
+}
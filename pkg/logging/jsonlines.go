@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// chartKey is the key of the chart in the context of log entries
+type chartKey struct{}
+
+// ChartContext returns a context for log entries about the chart (see logrus.WithContext). The chart
+// isn't printed by the text formatter, but added to the diagnostics of the JSONLinesHook.
+func ChartContext(chart string) context.Context {
+	return context.WithValue(context.Background(), chartKey{}, chart)
+}
+
+// WithChart returns a log entry of the standard logger for messages about the chart (see ChartContext)
+func WithChart(chart string) *logrus.Entry {
+	return logrus.WithContext(ChartContext(chart))
+}
+
+// ForChart returns a logger for messages about the chart: logrus loggers log the entries of
+// WithChart and slog loggers get a chart attribute. Other loggers are returned as they are.
+func ForChart(logger Logger, chart string) Logger {
+	switch l := logger.(type) {
+	case interface {
+		WithContext(ctx context.Context) *logrus.Entry
+	}:
+		return l.WithContext(ChartContext(chart))
+	case slogLogger:
+		return slogLogger{logger: l.logger.With("chart", chart)}
+	}
+	return logger
+}
+
+// Diagnostic is a warning or error written by the JSONLinesHook
+type Diagnostic struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Chart   string    `json:"chart,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Message string    `json:"message"`
+}
+
+// JSONLinesHook is a logrus hook, which writes warnings and errors as JSON objects (one per line),
+// so log aggregation systems can index them. The chart is taken from entries of WithChart and
+// messages starting with <file>:<line>: are split into the file, the line and the message.
+type JSONLinesHook struct {
+	Out io.Writer
+
+	mu sync.Mutex
+}
+
+// NewJSONLinesHook returns a hook writing the diagnostics to out (e.g. a file or os.Stdout)
+func NewJSONLinesHook(out io.Writer) *JSONLinesHook {
+	return &JSONLinesHook{Out: out}
+}
+
+func (h *JSONLinesHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel, logrus.WarnLevel}
+}
+
+func (h *JSONLinesHook) Fire(entry *logrus.Entry) error {
+	diagnostic := Diagnostic{Time: entry.Time, Level: entry.Level.String(), Message: entry.Message}
+	if entry.Context != nil {
+		diagnostic.Chart, _ = entry.Context.Value(chartKey{}).(string)
+	}
+	if match := locationPattern.FindStringSubmatch(entry.Message); match != nil {
+		diagnostic.File = match[1]
+		diagnostic.Line, _ = strconv.Atoi(match[2])
+		diagnostic.Message = match[3]
+	}
+
+	line, err := json.Marshal(diagnostic)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.Out.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,122 @@
+// Package logging defines the logger the schema generation reports to, so programs using
+// helm-schema as a library can integrate it with their own logging. Adapters for logrus
+// and log/slog are included.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger receives the log messages
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+	// Fatalf logs the message and stops the program
+	Fatalf(format string, args ...any)
+}
+
+// NewLogrus returns a logger which logs to the logrus logger (e.g. logrus.StandardLogger())
+func NewLogrus(logger logrus.FieldLogger) Logger {
+	return logger
+}
+
+// slogLogger logs to a slog.Logger
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlog returns a logger which logs to the slog logger (e.g. slog.Default()).
+// Fatal messages are logged as errors, before the program exits with status 1.
+func NewSlog(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+func (l slogLogger) log(level slog.Level, format string, args ...any) {
+	if l.logger.Enabled(context.Background(), level) {
+		l.logger.Log(context.Background(), level, fmt.Sprintf(format, args...))
+	}
+}
+
+func (l slogLogger) Debugf(format string, args ...any) { l.log(slog.LevelDebug, format, args...) }
+func (l slogLogger) Infof(format string, args ...any)  { l.log(slog.LevelInfo, format, args...) }
+func (l slogLogger) Warnf(format string, args ...any)  { l.log(slog.LevelWarn, format, args...) }
+func (l slogLogger) Errorf(format string, args ...any) { l.log(slog.LevelError, format, args...) }
+
+func (l slogLogger) Fatalf(format string, args ...any) {
+	l.log(slog.LevelError, format, args...)
+	os.Exit(1)
+}
+
+// Level is the level of a recorded message
+type Level string
+
+// Levels of the recorded messages
+const (
+	LevelDebug Level = "debug"
+	LevelInfo  Level = "info"
+	LevelWarn  Level = "warning"
+	LevelError Level = "error"
+	LevelFatal Level = "fatal"
+)
+
+// Entry is a recorded message
+type Entry struct {
+	Level   Level
+	Message string
+}
+
+// Recorder is a logger which keeps the messages, e.g. to check the warnings in tests.
+// Fatal messages are recorded and panic afterwards, so they can be recovered.
+// It is safe to use a recorder concurrently.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewRecorder returns an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) record(level Level, format string, args ...any) string {
+	message := fmt.Sprintf(format, args...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, Entry{Level: level, Message: message})
+	return message
+}
+
+func (r *Recorder) Debugf(format string, args ...any) { r.record(LevelDebug, format, args...) }
+func (r *Recorder) Infof(format string, args ...any)  { r.record(LevelInfo, format, args...) }
+func (r *Recorder) Warnf(format string, args ...any)  { r.record(LevelWarn, format, args...) }
+func (r *Recorder) Errorf(format string, args ...any) { r.record(LevelError, format, args...) }
+
+func (r *Recorder) Fatalf(format string, args ...any) {
+	panic(r.record(LevelFatal, format, args...))
+}
+
+// Entries returns all recorded messages
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
+
+// Messages returns the recorded messages of the level
+func (r *Recorder) Messages(level Level) []string {
+	messages := []string{}
+	for _, entry := range r.Entries() {
+		if entry.Level == level {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Debugf("hidden %d", 1)
+	logger.Warnf("key %s is deprecated", "foo")
+
+	output := buf.String()
+	if strings.Contains(output, "hidden") {
+		t.Errorf("Was expecting no debug messages, but got %s", output)
+	}
+	if !strings.Contains(output, "level=WARN") || !strings.Contains(output, `msg="key foo is deprecated"`) {
+		t.Errorf("Was expecting the warning, but got %s", output)
+	}
+}
+
+func TestLogrus(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&buf)
+	NewLogrus(l).Errorf("could not read %s", "values.yaml")
+
+	if !strings.Contains(buf.String(), `level=error msg="could not read values.yaml"`) {
+		t.Errorf("Was expecting the error, but got %s", buf.String())
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Warnf("first %d", 1)
+	recorder.Debugf("debug")
+	recorder.Warnf("second")
+
+	warnings := recorder.Messages(LevelWarn)
+	if len(warnings) != 2 || warnings[0] != "first 1" || warnings[1] != "second" {
+		t.Errorf("Was expecting two warnings, but got %v", warnings)
+	}
+	if len(recorder.Entries()) != 3 {
+		t.Errorf("Was expecting three entries, but got %v", recorder.Entries())
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != "fatal" {
+			t.Errorf("Was expecting a panic with the fatal message, but got %v", recovered)
+		}
+	}()
+	recorder.Fatalf("fatal")
+}
+
+func TestGitHubCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		message  string
+		expected string
+	}{
+		{"error", "charts/app/values.yaml:12: invalid annotation of key image", "::error file=charts/app/values.yaml,line=12::invalid annotation of key image"},
+		{"warning", "could not read 100% of\nthe files", "::warning::could not read 100%25 of%0Athe files"},
+		{"error", "Error: values.yaml:3: x", "::error::Error: values.yaml:3: x"},
+		{"error", "values.yaml:3: invalid\nannotation", "::error file=values.yaml,line=3::invalid%0Aannotation"},
+	}
+
+	for _, test := range tests {
+		if got := GitHubCommand(test.command, test.message); got != test.expected {
+			t.Errorf("Was expecting %q, but got %q", test.expected, got)
+		}
+	}
+}
+
+func TestGitHubHook(t *testing.T) {
+	var buf, out bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&out)
+	l.AddHook(NewGitHubHook(&buf))
+
+	l.Info("generated the schema")
+	l.Warnf("values.yaml:%d: ignoring @schema annotation", 4)
+	l.Error("could not write the schema")
+
+	expected := "::warning file=values.yaml,line=4::ignoring @schema annotation\n::error::could not write the schema\n"
+	if buf.String() != expected {
+		t.Errorf("Was expecting %q, but got %q", expected, buf.String())
+	}
+}
+
+func TestJSONLinesHook(t *testing.T) {
+	var buf, out bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&out)
+	l.AddHook(NewJSONLinesHook(&buf))
+
+	l.Info("generated the schema")
+	l.WithContext(ChartContext("app")).Warnf("values.yaml:%d: ignoring @schema annotation", 4)
+	l.Error("could not write the schema")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Was expecting 2 diagnostics, but got %q", buf.String())
+	}
+	var diagnostic Diagnostic
+	if err := json.Unmarshal([]byte(lines[0]), &diagnostic); err != nil {
+		t.Fatal(err)
+	}
+	if diagnostic.Level != "warning" || diagnostic.Chart != "app" || diagnostic.File != "values.yaml" || diagnostic.Line != 4 || diagnostic.Message != "ignoring @schema annotation" {
+		t.Errorf("Was expecting the located warning of the chart, but got %+v", diagnostic)
+	}
+	if strings.Contains(out.String(), "app") {
+		t.Errorf("Was expecting the chart not to be printed, but got %s", out.String())
+	}
+	if !strings.Contains(lines[1], `"message":"could not write the schema"`) || strings.Contains(lines[1], `"chart"`) {
+		t.Errorf("Was expecting the error without chart, but got %s", lines[1])
+	}
+}
+
+func TestForChart(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&bytes.Buffer{})
+	l.AddHook(NewJSONLinesHook(&buf))
+	ForChart(NewLogrus(l), "app").Warnf("ignoring @schema annotation")
+	if !strings.Contains(buf.String(), `"chart":"app"`) {
+		t.Errorf("Was expecting the diagnostic of the chart, but got %s", buf.String())
+	}
+
+	var out bytes.Buffer
+	ForChart(NewSlog(slog.New(slog.NewTextHandler(&out, nil))), "app").Warnf("ignoring @schema annotation")
+	if !strings.Contains(out.String(), "chart=app") {
+		t.Errorf("Was expecting the chart attribute, but got %s", out.String())
+	}
+
+	recorder := NewRecorder()
+	if ForChart(recorder, "app") != Logger(recorder) {
+		t.Error("Was expecting other loggers to be returned as they are")
+	}
+}
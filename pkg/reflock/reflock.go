@@ -0,0 +1,169 @@
+// Package reflock records the digests of downloaded referenced schemas (urls, git and registry
+// references) in a lock file like helm-schema.lock. Later runs verify the downloaded schemas
+// against the recorded digests, so schemas which changed upstream don't go unnoticed.
+package reflock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileName is the conventional name of the lock file
+const DefaultFileName = "helm-schema.lock"
+
+const (
+	// DriftWarn reports changed schemas and keeps their recorded digests (default)
+	DriftWarn = "warn"
+	// DriftFail reports changed schemas as errors
+	DriftFail = "fail"
+	// DriftUpdate records the digests of changed schemas and removes the entries of
+	// references which weren't used
+	DriftUpdate = "update"
+)
+
+// DriftModes returns the names of all modes of handling changed schemas
+func DriftModes() []string {
+	return []string{DriftWarn, DriftFail, DriftUpdate}
+}
+
+// Entry is the recorded digest of a referenced schema
+type Entry struct {
+	// Ref is the reference without json pointer, e.g. https://example.com/common.json
+	Ref string `yaml:"ref"`
+	// Digest is the sha256 digest of the downloaded schema, e.g. sha256:2c26b4...
+	Digest string `yaml:"digest"`
+	// Fetched is the time the digest was recorded
+	Fetched time.Time `yaml:"fetched"`
+}
+
+// file is the content of the lock file
+type file struct {
+	Refs []Entry `yaml:"refs"`
+}
+
+// DriftError is returned for schemas whose digest differs from the recorded one
+type DriftError struct {
+	Entry  Entry
+	Digest string
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("the schema %s changed since it was locked on %s (locked %s, downloaded %s)",
+		e.Entry.Ref, e.Entry.Fetched.Format(time.DateOnly), e.Entry.Digest, e.Digest)
+}
+
+// Lock holds the entries of a lock file. It is safe to use a lock concurrently.
+type Lock struct {
+	path  string
+	drift string
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	used    map[string]bool
+	changed bool
+}
+
+// Load reads the lock file at the path, which doesn't need to exist yet. Changed schemas are
+// handled according to the drift mode (one of DriftModes).
+func Load(path, drift string) (*Lock, error) {
+	if !slices.Contains(DriftModes(), drift) {
+		return nil, fmt.Errorf("invalid drift mode %s, one of (%s, %s, %s)", drift, DriftWarn, DriftFail, DriftUpdate)
+	}
+	l := &Lock{
+		path:    path,
+		drift:   drift,
+		now:     time.Now,
+		entries: make(map[string]Entry),
+		used:    make(map[string]bool),
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("could not parse the lock file %s: %w", path, err)
+	}
+	for _, entry := range f.Refs {
+		l.entries[entry.Ref] = entry
+	}
+	return l, nil
+}
+
+// Digest returns the digest of the content, as it's recorded in the lock file
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// FailOnDrift checks if changed schemas are errors (DriftFail)
+func (l *Lock) FailOnDrift() bool {
+	return l.drift == DriftFail
+}
+
+// Verify records the digest of the downloaded schema of the reference (without json pointer),
+// if it isn't locked yet. It returns a *DriftError if the schema changed, unless changed
+// schemas are updated (DriftUpdate).
+func (l *Lock) Verify(ref string, content []byte) error {
+	digest := Digest(content)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used[ref] = true
+	entry, ok := l.entries[ref]
+	if ok && entry.Digest == digest {
+		return nil
+	}
+	if ok && l.drift != DriftUpdate {
+		return &DriftError{Entry: entry, Digest: digest}
+	}
+	l.entries[ref] = Entry{Ref: ref, Digest: digest, Fetched: l.now().UTC().Truncate(time.Second)}
+	l.changed = true
+	return nil
+}
+
+// Entries returns the entries of the lock file sorted by reference. With DriftUpdate the
+// entries of references which weren't verified are left out.
+func (l *Lock) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []Entry
+	for _, ref := range slices.Sorted(maps.Keys(l.entries)) {
+		if l.drift == DriftUpdate && !l.used[ref] {
+			continue
+		}
+		entries = append(entries, l.entries[ref])
+	}
+	return entries
+}
+
+// Save writes the lock file, if entries were added or changed (or removed with DriftUpdate)
+func (l *Lock) Save() error {
+	entries := l.Entries()
+	l.mu.Lock()
+	changed := l.changed || len(entries) != len(l.entries)
+	l.mu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	content, err := yaml.Marshal(file{Refs: entries})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, content, 0o644)
+}
@@ -0,0 +1,100 @@
+package reflock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	fetched := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	load := func(drift string) *Lock {
+		t.Helper()
+		l, err := Load(path, drift)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.now = func() time.Time { return fetched }
+		return l
+	}
+
+	l := load(DriftWarn)
+	if err := l.Verify("https://example.com/common.json", []byte(`{"type": "string"}`)); err != nil {
+		t.Fatalf("Was expecting the new reference to be locked, but got %v", err)
+	}
+	if err := l.Verify("https://example.com/other.json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = load(DriftWarn)
+	entries := l.Entries()
+	if len(entries) != 2 || entries[0].Ref != "https://example.com/common.json" || !entries[0].Fetched.Equal(fetched) {
+		t.Fatalf("Was expecting the saved entries, but got %v", entries)
+	}
+	if entries[0].Digest != Digest([]byte(`{"type": "string"}`)) {
+		t.Errorf("Was expecting the digest of the schema, but got %s", entries[0].Digest)
+	}
+	if err := l.Verify("https://example.com/common.json", []byte(`{"type": "string"}`)); err != nil {
+		t.Errorf("Was expecting the unchanged schema to verify, but got %v", err)
+	}
+	var drift *DriftError
+	if err := l.Verify("https://example.com/common.json", []byte(`{"type": "integer"}`)); !errors.As(err, &drift) {
+		t.Fatalf("Was expecting a drift error, but got %v", err)
+	}
+	if drift.Entry.Digest != entries[0].Digest || drift.Digest != Digest([]byte(`{"type": "integer"}`)) {
+		t.Errorf("Was expecting the locked and the downloaded digest, but got %v", drift)
+	}
+	if l.FailOnDrift() {
+		t.Error("Was expecting drift to be a warning")
+	}
+
+	// nothing changed, so the file isn't written
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Was expecting the unchanged lock not to be saved, but got %v", err)
+	}
+
+	// update records the changed schema and drops the unused references
+	l = load(DriftWarn)
+	if err := l.Verify("https://example.com/common.json", []byte(`{"type": "string"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	l = load(DriftUpdate)
+	if err := l.Verify("https://example.com/common.json", []byte(`{"type": "integer"}`)); err != nil {
+		t.Errorf("Was expecting the changed schema to be updated, but got %v", err)
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	entries = load(DriftFail).Entries()
+	if len(entries) != 1 || entries[0].Digest != Digest([]byte(`{"type": "integer"}`)) {
+		t.Errorf("Was expecting the updated entry, but got %v", entries)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	if _, err := Load(path, "ignore"); err == nil {
+		t.Error("Was expecting an error for the invalid drift mode")
+	}
+	if err := os.WriteFile(path, []byte("refs: {"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, DriftWarn); err == nil {
+		t.Error("Was expecting an error for the invalid lock file")
+	}
+}
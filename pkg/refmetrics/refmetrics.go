@@ -0,0 +1,180 @@
+// Package refmetrics counts the cache hits and misses, the downloaded bytes and the latency of the
+// downloads of referenced schemas (urls, git and registry references), so long running processes
+// generating schemas can be monitored. The metrics are an expvar.Var and can be served in the text
+// format of Prometheus.
+package refmetrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources of the referenced schemas
+const (
+	SourceURL      = "url"
+	SourceGit      = "git"
+	SourceRegistry = "registry"
+)
+
+// SourceStats are the counters of a source
+type SourceStats struct {
+	// Hits counts the schemas served from the cache
+	Hits uint64 `json:"hits"`
+	// Misses counts the schemas which were downloaded
+	Misses uint64 `json:"misses"`
+	// BytesDownloaded is the size of the downloaded schemas
+	BytesDownloaded uint64 `json:"bytesDownloaded"`
+}
+
+// HostStats are the latencies of the downloads from a host
+type HostStats struct {
+	// Downloads counts the downloads, including the failed ones
+	Downloads uint64 `json:"downloads"`
+	// Errors counts the failed downloads
+	Errors uint64 `json:"errors"`
+	// TotalSeconds is the sum of the latencies of the downloads
+	TotalSeconds float64 `json:"totalSeconds"`
+	// MaxSeconds is the highest latency of a download
+	MaxSeconds float64 `json:"maxSeconds"`
+}
+
+// Snapshot is a copy of the metrics
+type Snapshot struct {
+	Sources map[string]SourceStats `json:"sources"`
+	Hosts   map[string]HostStats   `json:"hosts"`
+}
+
+// Metrics are the counters of the referenced schemas. It is safe to use metrics concurrently.
+// The methods of nil metrics don't count anything, so fetchers can record unconditionally.
+type Metrics struct {
+	mu      sync.Mutex
+	sources map[string]SourceStats
+	hosts   map[string]HostStats
+}
+
+// New returns metrics without any counts
+func New() *Metrics {
+	return &Metrics{
+		sources: make(map[string]SourceStats),
+		hosts:   make(map[string]HostStats),
+	}
+}
+
+// Hit counts a schema of the source served from the cache
+func (m *Metrics) Hit(source string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.sources[source]
+	stats.Hits++
+	m.sources[source] = stats
+}
+
+// Download counts a download of a schema of the source from the host of the url, which took the
+// latency. Failed downloads (err is not nil) only count for the latency of the host.
+func (m *Metrics) Download(source, rawURL string, size int, latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	host := Host(rawURL)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.sources[source]
+	stats.Misses++
+	if err == nil {
+		stats.BytesDownloaded += uint64(size)
+	}
+	m.sources[source] = stats
+
+	hostStats := m.hosts[host]
+	hostStats.Downloads++
+	if err != nil {
+		hostStats.Errors++
+	}
+	hostStats.TotalSeconds += latency.Seconds()
+	hostStats.MaxSeconds = max(hostStats.MaxSeconds, latency.Seconds())
+	m.hosts[host] = hostStats
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *Metrics) Snapshot() Snapshot {
+	if m == nil {
+		return Snapshot{Sources: map[string]SourceStats{}, Hosts: map[string]HostStats{}}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Snapshot{Sources: maps.Clone(m.sources), Hosts: maps.Clone(m.hosts)}
+}
+
+// String returns the metrics as JSON, so they can be published with expvar.Publish
+func (m *Metrics) String() string {
+	content, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(content)
+}
+
+// ServeHTTP serves the metrics in the text format of Prometheus
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = fmt.Fprint(w, m.Prometheus())
+}
+
+// Prometheus returns the metrics in the text format of Prometheus
+func (m *Metrics) Prometheus() string {
+	snapshot := m.Snapshot()
+	var b strings.Builder
+
+	sources := slices.Sorted(maps.Keys(snapshot.Sources))
+	for _, metric := range []struct {
+		name, help string
+		value      func(SourceStats) uint64
+	}{
+		{"helm_schema_ref_cache_hits_total", "Referenced schemas served from the cache.", func(s SourceStats) uint64 { return s.Hits }},
+		{"helm_schema_ref_cache_misses_total", "Referenced schemas which were downloaded.", func(s SourceStats) uint64 { return s.Misses }},
+		{"helm_schema_ref_downloaded_bytes_total", "Size of the downloaded referenced schemas.", func(s SourceStats) uint64 { return s.BytesDownloaded }},
+	} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name)
+		for _, source := range sources {
+			fmt.Fprintf(&b, "%s{source=\"%s\"} %d\n", metric.name, escapeLabel(source), metric.value(snapshot.Sources[source]))
+		}
+	}
+
+	hosts := slices.Sorted(maps.Keys(snapshot.Hosts))
+	b.WriteString("# HELP helm_schema_ref_download_duration_seconds Latency of the downloads of referenced schemas.\n")
+	b.WriteString("# TYPE helm_schema_ref_download_duration_seconds summary\n")
+	for _, host := range hosts {
+		stats := snapshot.Hosts[host]
+		fmt.Fprintf(&b, "helm_schema_ref_download_duration_seconds_sum{host=\"%s\"} %g\n", escapeLabel(host), stats.TotalSeconds)
+		fmt.Fprintf(&b, "helm_schema_ref_download_duration_seconds_count{host=\"%s\"} %d\n", escapeLabel(host), stats.Downloads)
+	}
+	b.WriteString("# HELP helm_schema_ref_download_errors_total Failed downloads of referenced schemas.\n")
+	b.WriteString("# TYPE helm_schema_ref_download_errors_total counter\n")
+	for _, host := range hosts {
+		fmt.Fprintf(&b, "helm_schema_ref_download_errors_total{host=\"%s\"} %d\n", escapeLabel(host), snapshot.Hosts[host].Errors)
+	}
+	return b.String()
+}
+
+// Host returns the host of the url the latency is counted for, the url itself if it has no host
+func Host(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawURL
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,81 @@
+package refmetrics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Hit(SourceURL)
+		}()
+	}
+	wg.Wait()
+	m.Download(SourceURL, "https://example.com/common.json", 100, 2*time.Second, nil)
+	m.Download(SourceURL, "https://example.com/other.json", 0, time.Second, errors.New("not found"))
+	m.Download(SourceGit, "ssh://git@git.example.com/schemas.git", 50, 3*time.Second, nil)
+
+	snapshot := m.Snapshot()
+	if stats := snapshot.Sources[SourceURL]; stats != (SourceStats{Hits: 10, Misses: 2, BytesDownloaded: 100}) {
+		t.Errorf("Was expecting the counters of the urls, but got %+v", stats)
+	}
+	if stats := snapshot.Hosts["example.com"]; stats != (HostStats{Downloads: 2, Errors: 1, TotalSeconds: 3, MaxSeconds: 2}) {
+		t.Errorf("Was expecting the latencies of example.com, but got %+v", stats)
+	}
+	if _, ok := snapshot.Hosts["git.example.com"]; !ok {
+		t.Errorf("Was expecting the host of the repository, but got %+v", snapshot.Hosts)
+	}
+
+	var decoded Snapshot
+	if err := json.Unmarshal([]byte(m.String()), &decoded); err != nil || decoded.Sources[SourceGit].BytesDownloaded != 50 {
+		t.Errorf("Was expecting the metrics as JSON, but got %s (%v)", m.String(), err)
+	}
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE helm_schema_ref_cache_hits_total counter",
+		`helm_schema_ref_cache_hits_total{source="url"} 10`,
+		`helm_schema_ref_cache_misses_total{source="git"} 1`,
+		`helm_schema_ref_downloaded_bytes_total{source="url"} 100`,
+		`helm_schema_ref_download_duration_seconds_sum{host="example.com"} 3`,
+		`helm_schema_ref_download_duration_seconds_count{host="example.com"} 2`,
+		`helm_schema_ref_download_errors_total{host="example.com"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Was expecting %q in the prometheus metrics, but got %s", line, body)
+		}
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.Hit(SourceURL)
+	m.Download(SourceURL, "https://example.com/common.json", 100, time.Second, nil)
+	if snapshot := m.Snapshot(); len(snapshot.Sources) != 0 || len(snapshot.Hosts) != 0 {
+		t.Errorf("Was expecting no metrics, but got %+v", snapshot)
+	}
+}
+
+func TestHost(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"https://example.com:8443/common.json":  "example.com:8443",
+		"ssh://git@git.example.com/schemas.git": "git.example.com",
+		"schemas/common.json":                   "schemas/common.json",
+	} {
+		if host := Host(rawURL); host != expected {
+			t.Errorf("Was expecting %q as the host of %q, but got %q", expected, rawURL, host)
+		}
+	}
+}
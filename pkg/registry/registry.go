@@ -0,0 +1,187 @@
+// Package registry implements a client for schema registries. A schema registry is
+// a http server which serves the schemas of charts at /schemas/<chart>/<version>.json
+// and accepts new schemas via PUT requests to the same path.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dadav/helm-schema/pkg/refmetrics"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+// RefPrefix is the prefix of references to schemas in a registry,
+// e.g. registry://common@1.2.3#/$defs/image
+const RefPrefix = "registry://"
+
+// Client pulls schemas from and pushes schemas to a schema registry.
+// Pulled schemas are cached, it is safe to use a client concurrently.
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+	maxSize    int64
+	metrics    *refmetrics.Metrics
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// NewClient returns a client for the registry at baseURL (http or https).
+// If a token is given, it is sent as bearer token with every request.
+func NewClient(baseURL, token string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema registry url %s: %w", baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid schema registry url %s: must be a http or https url", baseURL)
+	}
+
+	return &Client{
+		baseURL:    u,
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxSize:    util.DefaultMaxDownloadSize,
+		cache:      make(map[string][]byte),
+	}, nil
+}
+
+// SetPolicy refuses redirects of the registry to urls the policy doesn't allow and connections
+// to addresses it doesn't allow. The url of the registry itself is configured explicitly, so it
+// is not checked. It must not be called while pulling or pushing.
+func (c *Client) SetPolicy(policy *urlpolicy.Policy) {
+	c.httpClient.CheckRedirect = policy.CheckRedirect
+	c.httpClient.Transport = policy.Transport(c.baseURL.Hostname())
+}
+
+// SetMaxSize sets the maximum size in bytes of pulled schemas (util.DefaultMaxDownloadSize if
+// size is not positive). It must not be called while pulling.
+func (c *Client) SetMaxSize(size int64) {
+	if size <= 0 {
+		size = util.DefaultMaxDownloadSize
+	}
+	c.maxSize = size
+}
+
+// SetMetrics sets the metrics the cache hits and the pulls are counted in (none if nil).
+// It must not be called while pulling.
+func (c *Client) SetMetrics(metrics *refmetrics.Metrics) {
+	c.metrics = metrics
+}
+
+// SchemaURL returns the url of the schema of the chart in the given version
+func (c *Client) SchemaURL(chart, version string) string {
+	return c.baseURL.JoinPath("schemas", chart, version+".json").String()
+}
+
+// Pull returns the schema of the chart in the given version
+func (c *Client) Pull(chart, version string) ([]byte, error) {
+	return c.PullContext(context.Background(), chart, version)
+}
+
+// PullContext returns the schema of the chart in the given version,
+// the request is aborted when the context is done
+func (c *Client) PullContext(ctx context.Context, chart, version string) ([]byte, error) {
+	schemaURL := c.SchemaURL(chart, version)
+
+	c.mu.Lock()
+	cached, ok := c.cache[schemaURL]
+	c.mu.Unlock()
+	if ok {
+		c.metrics.Hit(refmetrics.SourceRegistry)
+		return cached, nil
+	}
+
+	start := time.Now()
+	data, err := c.pull(ctx, chart, version, schemaURL)
+	c.metrics.Download(refmetrics.SourceRegistry, schemaURL, len(data), time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.cache[schemaURL] = data
+	c.mu.Unlock()
+
+	return data, nil
+}
+
+// pull downloads the schema of the chart in the given version from the url and checks it
+func (c *Client) pull(ctx context.Context, chart, version, schemaURL string) ([]byte, error) {
+	data, header, err := util.Download(ctx, c.httpClient, schemaURL, "", util.Credentials{Token: c.token}, c.maxSize)
+	var statusErr *util.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("schema of chart %s in version %s not found (%s)", chart, version, schemaURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mediaType == "text/html" {
+		return nil, fmt.Errorf("downloaded a HTML error page from %s instead of a schema", schemaURL)
+	}
+	if err := util.CheckDownloadedSchema(schemaURL, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Push publishes the schema of the chart in the given version
+func (c *Client) Push(chart, version string, schema []byte) error {
+	schemaURL := c.SchemaURL(chart, version)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, schemaURL, bytes.NewReader(schema))
+	if err != nil {
+		return err
+	}
+	util.Credentials{Token: c.token}.Apply(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("could not push %s: %s", schemaURL, resp.Status)
+	}
+
+	c.mu.Lock()
+	c.cache[schemaURL] = schema
+	c.mu.Unlock()
+
+	return nil
+}
+
+// IsRef checks if the reference (without json pointer) points to a schema registry
+func IsRef(ref string) bool {
+	return strings.HasPrefix(ref, RefPrefix)
+}
+
+// ParseRef returns the chart and version of a reference (without json pointer),
+// e.g. common and 1.2.3 for registry://common@1.2.3
+func ParseRef(ref string) (chart, version string, err error) {
+	if !IsRef(ref) {
+		return "", "", fmt.Errorf("%s is not a schema registry reference", ref)
+	}
+	chart, version, ok := strings.Cut(strings.TrimPrefix(ref, RefPrefix), "@")
+	if !ok || chart == "" || version == "" {
+		return "", "", errors.New("schema registry references must look like registry://<chart>@<version>")
+	}
+	if strings.ContainsAny(chart, "/@") || strings.ContainsAny(version, "/@") {
+		return "", "", fmt.Errorf("invalid schema registry reference %s", ref)
+	}
+	return chart, version, nil
+}
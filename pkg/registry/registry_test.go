@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) (*httptest.Server, map[string]string, *int) {
+	t.Helper()
+
+	schemas := map[string]string{"/schemas/common/1.2.3.json": `{"type": "object"}`}
+	pulls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			pulls++
+			schema, ok := schemas[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(schema))
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			schemas[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, schemas, &pulls
+}
+
+func TestClientPull(t *testing.T) {
+	server, _, pulls := newTestRegistry(t)
+
+	client, err := NewClient(server.URL+"/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		schema, err := client.Pull("common", "1.2.3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(schema) != `{"type": "object"}` {
+			t.Errorf("Was expecting the schema of common, but got %s", schema)
+		}
+	}
+	if *pulls != 1 {
+		t.Errorf("Was expecting the schema to be pulled once, but it was pulled %d times", *pulls)
+	}
+
+	if _, err := client.Pull("common", "2.0.0"); err == nil {
+		t.Error("Was expecting an error for a missing schema")
+	}
+
+	unauthorized, err := NewClient(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unauthorized.Pull("common", "1.2.3"); err == nil {
+		t.Error("Was expecting an error without token")
+	}
+}
+
+func TestClientPullContextCancelled(t *testing.T) {
+	server, _, pulls := newTestRegistry(t)
+
+	client, err := NewClient(server.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.PullContext(ctx, "common", "1.2.3"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Was expecting context.Canceled, but got %v", err)
+	}
+	if *pulls != 0 {
+		t.Errorf("Was expecting no pull, but the schema was pulled %d times", *pulls)
+	}
+}
+
+func TestClientPullInvalidSchemas(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/login/1.0.0.json":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html><body>Sign in</body></html>"))
+		case "/schemas/large/1.0.0.json":
+			_, _ = w.Write([]byte(`{"description": "` + strings.Repeat("a", 100) + `"}`))
+		case "/schemas/broken/1.0.0.json":
+			_, _ = w.Write([]byte(`{"type": `))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMaxSize(64)
+
+	for chart, expected := range map[string]string{
+		"login":  "HTML error page",
+		"large":  "limit of 64 bytes",
+		"broken": "invalid JSON",
+	} {
+		_, err := client.Pull(chart, "1.0.0")
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Was expecting an error about %s for %s, but got %v", expected, chart, err)
+		}
+	}
+}
+
+func TestClientPush(t *testing.T) {
+	server, schemas, _ := newTestRegistry(t)
+
+	client, err := NewClient(server.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Push("my-chart", "0.1.0", []byte(`{"type": "string"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if schemas["/schemas/my-chart/0.1.0.json"] != `{"type": "string"}` {
+		t.Errorf("Was expecting the schema to be published, but got %v", schemas)
+	}
+}
+
+func TestNewClientInvalidURL(t *testing.T) {
+	for _, baseURL := range []string{"", "registry.example.com", "ftp://registry.example.com", "https://"} {
+		if _, err := NewClient(baseURL, ""); err == nil {
+			t.Errorf("Was expecting an error for %q", baseURL)
+		}
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	chart, version, err := ParseRef("registry://common@1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chart != "common" || version != "1.2.3" {
+		t.Errorf("Was expecting common and 1.2.3, but got %s and %s", chart, version)
+	}
+
+	for _, ref := range []string{"common@1.2.3", "registry://common", "registry://@1.2.3", "registry://common@", "registry://a/b@1.0.0", "registry://a@1@2"} {
+		if _, _, err := ParseRef(ref); err == nil {
+			t.Errorf("Was expecting an error for %s", ref)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// inheritAnchorAnnotations adds the @schema annotation of keys with anchored values (key: &anchor)
+// to the comments of the keys which use these values via aliases (other: *anchor), so shared
+// values keep their constraints at every usage. If the aliasing key has an annotation itself,
+// its keywords take precedence over the ones of the anchor.
+func inheritAnchorAnnotations(root *yaml.Node, gen *generation) {
+	anchorKeys := make(map[*yaml.Node]*yaml.Node)
+	walkMappings(root, func(keyNode, valueNode *yaml.Node) {
+		if valueNode.Anchor != "" {
+			anchorKeys[valueNode] = keyNode
+		}
+	})
+	if len(anchorKeys) == 0 {
+		return
+	}
+
+	walkMappings(root, func(keyNode, valueNode *yaml.Node) {
+		if valueNode.Kind != yaml.AliasNode {
+			return
+		}
+		anchorKey, ok := anchorKeys[valueNode.Alias]
+		if !ok {
+			return
+		}
+		anchorAnnotation, ok := annotationLines(anchorKey.HeadComment, gen.RelaxedAnnotationIndentation)
+		if !ok || len(anchorAnnotation) == 0 {
+			return
+		}
+		ownAnnotation, ok := annotationLines(keyNode.HeadComment, gen.RelaxedAnnotationIndentation)
+		if !ok {
+			return
+		}
+
+		annotation := anchorAnnotation
+		if len(ownAnnotation) > 0 {
+			merged, err := mergeAnnotations(anchorAnnotation, ownAnnotation)
+			if err != nil {
+				// the annotation is reported when the key is processed
+				return
+			}
+			annotation = merged
+		}
+
+		gen.log().Debugf("Key %s inherits the annotation of %s (&%s)", keyNode.Value, anchorKey.Value, valueNode.Alias.Anchor)
+		keyNode.HeadComment = replaceAnnotation(keyNode.HeadComment, annotation)
+	})
+}
+
+// walkMappings calls fn with the key and value nodes of all mappings below node
+func walkMappings(node *yaml.Node, fn func(keyNode, valueNode *yaml.Node)) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			fn(node.Content[i], node.Content[i+1])
+		}
+	}
+	for _, child := range node.Content {
+		walkMappings(child, fn)
+	}
+}
+
+// isAnnotationMarker checks if the comment line starts or ends a @schema block (not a @schema.root block)
+func isAnnotationMarker(line string) bool {
+	return strings.TrimSpace(line) == SchemaPrefix
+}
+
+// annotationLines returns the yaml lines of the @schema blocks of the comment, relaxed dedents them
+// (see GenerationPolicy.RelaxedAnnotationIndentation). The second return value is false, if a block isn't closed.
+func annotationLines(comment string, relaxed bool) ([]string, bool) {
+	lines := []string{}
+	inside := false
+	for _, line := range strings.Split(comment, "\n") {
+		if isAnnotationMarker(line) {
+			inside = !inside
+			continue
+		}
+		if inside {
+			content := strings.TrimPrefix(line, CommentPrefix)
+			lines = append(lines, strings.TrimPrefix(content, " "))
+		}
+	}
+	if relaxed {
+		lines = dedentAnnotation(lines)
+	}
+	return lines, !inside
+}
+
+// mergeAnnotations merges the top-level keywords of both annotations, the ones of override win
+func mergeAnnotations(base, override []string) ([]string, error) {
+	var baseNode, overrideNode yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(base, "\n")), &baseNode); err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal([]byte(strings.Join(override, "\n")), &overrideNode); err != nil {
+		return nil, err
+	}
+	if len(baseNode.Content) != 1 || baseNode.Content[0].Kind != yaml.MappingNode ||
+		len(overrideNode.Content) != 1 || overrideNode.Content[0].Kind != yaml.MappingNode {
+		return override, nil
+	}
+
+	merged := baseNode.Content[0]
+	for i := 0; i+1 < len(overrideNode.Content[0].Content); i += 2 {
+		key, value := overrideNode.Content[0].Content[i], overrideNode.Content[0].Content[i+1]
+		replaced := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j+1] = value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"), nil
+}
+
+// replaceAnnotation replaces the @schema blocks of the comment with the given annotation.
+// The annotation is added where the first block was, or else at the beginning of the
+// last paragraph of the comment, which is the part used unless --keep-full-comment is set.
+func replaceAnnotation(comment string, annotation []string) string {
+	block := []string{SchemaPrefix}
+	for _, line := range annotation {
+		if line == "" {
+			block = append(block, CommentPrefix)
+			continue
+		}
+		block = append(block, CommentPrefix+" "+line)
+	}
+	block = append(block, SchemaPrefix)
+
+	if comment == "" {
+		return strings.Join(block, "\n")
+	}
+
+	lines := []string{}
+	insertAt := -1
+	inside := false
+	for _, line := range strings.Split(comment, "\n") {
+		if isAnnotationMarker(line) {
+			if insertAt < 0 {
+				insertAt = len(lines)
+			}
+			inside = !inside
+			continue
+		}
+		if !inside {
+			lines = append(lines, line)
+		}
+	}
+	if insertAt < 0 {
+		insertAt = 0
+		for i, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				insertAt = i + 1
+			}
+		}
+	}
+
+	return strings.Join(append(lines[:insertAt:insertAt], append(block, lines[insertAt:]...)...), "\n")
+}
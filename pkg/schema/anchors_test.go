@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAliasInheritsAnchorAnnotation(t *testing.T) {
+	yamlContent := `# @schema
+# type: object
+# description: Shared resources
+# additionalProperties: false
+# @schema
+resources: &resources
+  cpu: 100m
+
+# @schema
+# type: integer
+# minimum: 1
+# @schema
+replicas: &replicas 1
+
+# -- No annotation
+plain: &plain foo
+
+worker:
+  resources: *resources
+  # An unrelated comment
+
+  # @schema
+  # description: Replicas of the worker
+  # maximum: 10
+  # @schema
+  replicas: *replicas
+  # -- Also plain
+  plain: *plain
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+	worker := schema.Properties["worker"]
+
+	resources := worker.Properties["resources"]
+	assert.Equal(t, StringOrArrayOfString{"object"}, resources.Type)
+	assert.Equal(t, "Shared resources", resources.Description)
+	assert.Equal(t, false, resources.AdditionalProperties)
+	assert.Contains(t, resources.Properties, "cpu")
+
+	replicas := worker.Properties["replicas"]
+	assert.Equal(t, StringOrArrayOfString{"integer"}, replicas.Type)
+	assert.Equal(t, "Replicas of the worker", replicas.Description)
+	assert.Equal(t, 1, *replicas.Minimum)
+	assert.Equal(t, 10, *replicas.Maximum)
+	assert.Nil(t, schema.Properties["replicas"].Maximum, "the anchor must keep its own annotation")
+
+	plain := worker.Properties["plain"]
+	assert.Equal(t, StringOrArrayOfString{"string"}, plain.Type)
+	assert.Equal(t, "Also plain", plain.Description)
+}
+
+func TestReplaceAnnotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		comment    string
+		annotation []string
+		expected   string
+	}{
+		{
+			name:       "empty comment",
+			annotation: []string{"type: string"},
+			expected:   "# @schema\n# type: string\n# @schema",
+		},
+		{
+			name:       "before the last paragraph",
+			comment:    "# unrelated\n\n# -- description",
+			annotation: []string{"type: string", "", "pattern: a"},
+			expected:   "# unrelated\n\n# @schema\n# type: string\n#\n# pattern: a\n# @schema\n# -- description",
+		},
+		{
+			name:       "replaces existing blocks",
+			comment:    "# -- description\n# @schema\n# type: integer\n# @schema\n# more",
+			annotation: []string{"type: string"},
+			expected:   "# -- description\n# @schema\n# type: string\n# @schema\n# more",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, replaceAnnotation(tt.comment, tt.annotation))
+		})
+	}
+}
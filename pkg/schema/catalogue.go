@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+)
+
+// annotationDocs documents the keywords of @schema annotations, every yaml field of Schema needs one
+var annotationDocs = map[string]string{
+	"$anchor":              "Names the schema, so it can be referenced with #name (draft 2020-12, helm ignores it)",
+	"$comment":             "A comment for the maintainers of the schema, which is not shown to users",
+	"$defs":                "Definitions which can be referenced with $ref: \"#/$defs/<name>\"",
+	"$dynamicRef":          "A dynamic reference of draft 2020-12, which is passed through (helm ignores it)",
+	"$id":                  "The URI of the schema, references within it are resolved against it",
+	"$ref":                 "Reference to a schema (a relative file, file://, git+, registry:// or http(s) URI, or a JSON pointer into the generated schema), which describes the value",
+	"$schema":              "The URI of the jsonschema draft of the schema",
+	"additionalProperties": "Allows additional keys in maps (true), forbids them (false) or describes them with a schema. Defaults to false if the map is not empty",
+	"allOf":                "Schemas which must all apply",
+	"anyOf":                "Schemas of which at least one must apply",
+	"computed":             "Marks a value which is computed by the chart and must not be set by users. Adds readOnly and the x-computed annotation and the key is never required",
+	"const":                "The only allowed value",
+	"contentEncoding":      "The encoding of the content of a string, e.g. base64",
+	"contentMediaType":     "The media type of the content of a string, e.g. application/json",
+	"contentSchema":        "The schema of the content of a string, requires contentMediaType",
+	"default":              "The default value, shown first by IDEs. Defaults to the value of the key",
+	"definitions":          "Definitions which can be referenced with $ref: \"#/definitions/<name>\" (draft-07)",
+	"deprecated":           "Marks the value as deprecated",
+	"description":          "The description of the value. Defaults to the comment above or below the @schema block",
+	"else":                 "Schema which must apply if the schema of if doesn't",
+	"enum":                 "The allowed values",
+	"examples":             "Examples of the value for users",
+	"exclusiveMaximum":     "Exclusive maximum of a number, can't be used with maximum",
+	"exclusiveMinimum":     "Exclusive minimum of a number, can't be used with minimum",
+	"forbidden":            "Keys of the object which must not be set (e.g. removed keys)",
+	"format":               "Semantic format of a string, e.g. hostname, uri, date-time or one of the custom formats (semver, cron, k8s-name, k8s-quantity, duration-go)",
+	"if":                   "Schema which decides if then or else applies",
+	"items":                "Schema of the items of an array",
+	"links":                "Links of the value, e.g. to its documentation (rel: describedby)",
+	"maxItems":             "Maximum length of an array",
+	"maxLength":            "Maximum length of a string",
+	"maxProperties":        "Maximum number of properties of an object",
+	"maximum":              "Maximum of a number, can't be used with exclusiveMaximum",
+	"mergeProperties":      "Generates the keys of the value which are missing in the annotated properties instead of ignoring them",
+	"minItems":             "Minimum length of an array",
+	"minLength":            "Minimum length of a string",
+	"minProperties":        "Minimum number of properties of an object",
+	"minimum":              "Minimum of a number, can't be used with exclusiveMinimum",
+	"multipleOf":           "The number must be a multiple of this number",
+	"not":                  "Schema which must not apply",
+	"nullable":             "Allows null in addition to the declared or inferred type, enum, const or $ref",
+	"oneOf":                "Schemas of which exactly one must apply",
+	"pattern":              "Regular expression the string must match",
+	"patternProperties":    "Schemas of the keys matching the regular expressions",
+	"pinned":               "Pins the value to its default, sets const to the value of the key and adds readOnly",
+	"preset":               "Uses a predefined schema for a common structure (image, ingress, service or one of the config file), other annotations take precedence",
+	"properties":           "Schemas of the keys of an object",
+	"propertyNames":        "Schema which all keys of an object must match, e.g. a pattern",
+	"readOnly":             "Marks the value as managed by the chart, users should not set it",
+	"renamedFrom":          "Old names of the key, which are still accepted as deprecated keys",
+	"required":             "Whether the key is required (true or false) or the required keys of an object",
+	"then":                 "Schema which must apply if the schema of if applies",
+	"title":                "The title of the value. Defaults to the key",
+	"type":                 "The type of the value, several types are allowed as a list (e.g. [string, integer])",
+	"uniqueItems":          "The items of the array must be unique",
+	"uniqueKeys":           "Keys of the objects in an array, whose values must be unique across the items",
+	"variants":             "Shorthand for anyOf which hoists the shared title and description and rejects redundant variants",
+	"writeOnly":            "Marks the value as secret, it's not shown back to users",
+}
+
+// AnnotationCatalogue returns a jsonschema of the @schema annotations, which describes every keyword
+// the parser understands with its type and documentation. Editors (e.g. the YAML extension of
+// VS Code) and language servers can use it to complete and check annotations.
+func AnnotationCatalogue() *Schema {
+	catalogue := &Schema{
+		Schema:               "http://json-schema.org/draft-07/schema#",
+		Title:                "helm-schema annotation",
+		Description:          "The content of a @schema block in a values file",
+		Type:                 StringOrArrayOfString{"object"},
+		Properties:           annotationKeywords(reflect.TypeOf(Schema{})),
+		PatternProperties:    map[string]*Schema{"^" + CustomAnnotationPrefix: {Description: "Custom annotation, which is added to the schema unchanged"}},
+		AdditionalProperties: new(bool),
+	}
+	for name, keyword := range catalogue.Properties {
+		keyword.Description = annotationDocs[name]
+	}
+	// the catalogue doesn't require any keyword
+	_ = catalogue.Walk(func(_ string, s *Schema) error {
+		s.omitRequired = true
+		return nil
+	})
+	return catalogue
+}
+
+// annotationKeywords returns the schemas of the yaml fields of the struct type
+func annotationKeywords(t reflect.Type) map[string]*Schema {
+	keywords := make(map[string]*Schema)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		keywords[name] = keywordSchema(field.Type)
+	}
+	return keywords
+}
+
+// keywordSchema returns the schema of the values of a keyword with the go type,
+// schemas of annotations reference the catalogue itself
+func keywordSchema(t reflect.Type) *Schema {
+	annotation := func() *Schema { return &Schema{Ref: "#"} }
+	stringList := func() *Schema {
+		return &Schema{Type: StringOrArrayOfString{"array"}, Items: &Schema{Type: StringOrArrayOfString{"string"}}}
+	}
+
+	switch t {
+	case reflect.TypeOf(StringOrArrayOfString{}):
+		types := []interface{}{"object", "array", "string", "number", "integer", "boolean", "null"}
+		return &Schema{AnyOf: []*Schema{
+			{Type: StringOrArrayOfString{"string"}, Enum: types},
+			{Type: StringOrArrayOfString{"array"}, Items: &Schema{Enum: types}},
+		}}
+	case reflect.TypeOf(BoolOrArrayOfString{}):
+		return &Schema{AnyOf: []*Schema{{Type: StringOrArrayOfString{"boolean"}}, stringList()}}
+	case reflect.TypeOf((*SchemaOrBool)(nil)).Elem():
+		return &Schema{AnyOf: []*Schema{{Type: StringOrArrayOfString{"boolean"}}, annotation()}}
+	case reflect.TypeOf(&Schema{}):
+		return annotation()
+	case reflect.TypeOf(map[string]*Schema{}):
+		return &Schema{Type: StringOrArrayOfString{"object"}, AdditionalProperties: *annotation()}
+	case reflect.TypeOf([]*Schema{}):
+		return &Schema{Type: StringOrArrayOfString{"array"}, Items: annotation()}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: StringOrArrayOfString{"string"}}
+	case reflect.Bool:
+		return &Schema{Type: StringOrArrayOfString{"boolean"}}
+	case reflect.Int:
+		return &Schema{Type: StringOrArrayOfString{"integer"}}
+	case reflect.Pointer:
+		return keywordSchema(t.Elem())
+	case reflect.Struct:
+		return &Schema{Type: StringOrArrayOfString{"object"}, Properties: annotationKeywords(t)}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return stringList()
+		}
+		return &Schema{Type: StringOrArrayOfString{"array"}, Items: keywordSchema(t.Elem())}
+	}
+	// any value, e.g. default or const
+	return &Schema{}
+}
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationCatalogueDocumentsAllKeywords(t *testing.T) {
+	catalogue := AnnotationCatalogue()
+	for name, keyword := range catalogue.Properties {
+		assert.NotEmpty(t, keyword.Description, "keyword %s is not documented", name)
+	}
+	for name := range annotationDocs {
+		assert.Contains(t, catalogue.Properties, name, "documented keyword %s is not parsed", name)
+	}
+}
+
+func TestAnnotationCatalogue(t *testing.T) {
+	catalogue := AnnotationCatalogue()
+	assert.Equal(t, "#", catalogue.Properties["items"].Ref)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, catalogue.Properties["minimum"].Type)
+	assert.Equal(t, "#", catalogue.Properties["properties"].AdditionalProperties.(Schema).Ref)
+	assert.Contains(t, catalogue.Properties["links"].Items.Properties, "href")
+
+	tests := []struct {
+		annotation string
+		valid      bool
+	}{
+		{"type: [string, integer]\nminimum: 1\nrequired: true\nx-docs-url: https://example.org\n", true},
+		{"properties:\n  port:\n    type: integer\n    maximum: 65535\nrequired: [port]\n", true},
+		{"additionalProperties:\n  type: [string, number]\n", true},
+		{"typ: string\n", false},
+		{"minimum: one\n", false},
+		{"type: text\n", false},
+		{"properties:\n  port:\n    maximum: high\n", false},
+	}
+	for _, test := range tests {
+		err := catalogue.ValidateValues([]byte(test.annotation), "annotation.schema.json")
+		if test.valid {
+			assert.NoError(t, err, test.annotation)
+		} else {
+			assert.Error(t, err, test.annotation)
+		}
+	}
+}
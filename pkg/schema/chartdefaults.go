@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+)
+
+// chartTemplateData is passed to the templates of defaults, like the .Chart object of helm
+type chartTemplateData struct {
+	Chart *chart.ChartFile
+}
+
+// RenderChartDefaults resolves templates in string defaults which only use the chart metadata,
+// like the templates of the chart would (e.g. "{{ .Chart.AppVersion }}"). Strings within default
+// objects and arrays are resolved as well. Defaults which can't be rendered, e.g. because they use
+// other objects like .Release or functions of helm, are kept unchanged.
+func (s *Schema) RenderChartDefaults(chartFile *chart.ChartFile) {
+	if chartFile == nil {
+		return
+	}
+	data := chartTemplateData{Chart: chartFile}
+
+	_ = s.Walk(func(path string, v *Schema) error {
+		if v.Default != nil {
+			v.Default = renderChartTemplates(pointerOrRoot(path), v.Default, data)
+		}
+		return nil
+	})
+}
+
+// renderChartTemplates renders all strings of the value which contain a template
+func renderChartTemplates(path string, value interface{}, data chartTemplateData) interface{} {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v
+		}
+		tmpl, err := template.New("default").Option("missingkey=error").Parse(v)
+		if err != nil {
+			logger.Debugf("Keeping the default %q of %s: %v", v, path, err)
+			return v
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			logger.Debugf("Keeping the default %q of %s: %v", v, path, err)
+			return v
+		}
+		return out.String()
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = renderChartTemplates(path, item, data)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = renderChartTemplates(path, item, data)
+		}
+	}
+	return value
+}
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderChartDefaults(t *testing.T) {
+	s := &Schema{
+		Properties: map[string]*Schema{
+			"tag":     {Default: "{{ .Chart.AppVersion }}"},
+			"image":   {Default: "nginx:{{ .Chart.AppVersion }}-{{ .Chart.Name }}"},
+			"release": {Default: "{{ .Release.Name }}"},
+			"include": {Default: `{{ include "chart.name" . }}`},
+			"missing": {Default: "{{ .Chart.Missing }}"},
+			"plain":   {Default: "nginx"},
+			"number":  {Default: 1},
+			"labels": {Default: map[string]interface{}{
+				"version": "{{ .Chart.Version }}",
+				"list":    []interface{}{"{{ .Chart.Name }}", 2},
+			}},
+		},
+	}
+
+	s.RenderChartDefaults(&chart.ChartFile{Name: "app", Version: "1.2.3", AppVersion: "4.5.6"})
+
+	assert.Equal(t, "4.5.6", s.Properties["tag"].Default)
+	assert.Equal(t, "nginx:4.5.6-app", s.Properties["image"].Default)
+	assert.Equal(t, "{{ .Release.Name }}", s.Properties["release"].Default)
+	assert.Equal(t, `{{ include "chart.name" . }}`, s.Properties["include"].Default)
+	assert.Equal(t, "{{ .Chart.Missing }}", s.Properties["missing"].Default)
+	assert.Equal(t, "nginx", s.Properties["plain"].Default)
+	assert.Equal(t, 1, s.Properties["number"].Default)
+	assert.Equal(t, map[string]interface{}{
+		"version": "1.2.3",
+		"list":    []interface{}{"app", 2},
+	}, s.Properties["labels"].Default)
+}
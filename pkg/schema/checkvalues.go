@@ -0,0 +1,200 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValuesFinding is a value of the checked values files, which the schema doesn't accept
+type ValuesFinding struct {
+	// File and Line locate the value in the last values file setting it, Line is 0 if it's unknown
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Path is the dotted path of the value (items of lists by their index), empty for the root
+	Path string `json:"path"`
+	// Message describes why the value isn't accepted
+	Message string `json:"message"`
+}
+
+func (f ValuesFinding) String() string {
+	message := f.Message
+	if f.Path != "" {
+		message = f.Path + ": " + message
+	}
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", f.File, f.Line, message)
+	}
+	return message
+}
+
+// CheckValues validates the values files against the schema without a chart, e.g. the overrides of
+// a release before it's installed. The files are merged in order like helm merges several --values
+// flags and coalesced with the defaults of the chart like helm does on install: the values.yaml next
+// to the schema or, if there is none, the defaults of the schema. Keys set to null remove the
+// default. Relative $refs of the schema are resolved from the schema path.
+func (s *Schema) CheckValues(schemaPath string, valuesFiles []string) ([]ValuesFinding, error) {
+	if len(valuesFiles) == 0 {
+		return nil, errors.New("no values files to check")
+	}
+
+	validate, err := s.valuesValidator(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultsPath, defaults, err := s.chartDefaults(schemaPath, valuesFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// the values files are located like the ones of a chart with the merge strategy
+	result := &Result{ValuesPath: valuesFiles[0], ValuesFiles: valuesFiles, ValuesStrategy: ValuesStrategyMerge}
+	documents, err := result.ValuesDocuments()
+	if err != nil {
+		return nil, err
+	}
+	if defaultsPath != "" {
+		// the defaults may be the values which aren't accepted
+		result.ValuesFiles = append([]string{defaultsPath}, valuesFiles...)
+	}
+
+	var findings []ValuesFinding
+	for _, values := range documents {
+		var parsed interface{}
+		if err := yaml.Unmarshal(values, &parsed); err != nil {
+			return nil, fmt.Errorf("could not parse the values: %w", err)
+		}
+		for _, valueErr := range ValueErrors(validate(coalesceValues(parsed, defaults))) {
+			file, line := result.Locate(valueErr.Path)
+			findings = append(findings, ValuesFinding{
+				File:    file,
+				Line:    line,
+				Path:    strings.Join(valueErr.Path, "."),
+				Message: valueErr.Message,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// chartDefaults returns the values the checked values are coalesced with: the values.yaml next to
+// the schema or the defaults of the schema. The path is empty unless the values.yaml is used and
+// isn't one of the checked files already.
+func (s *Schema) chartDefaults(schemaPath string, valuesFiles []string) (string, interface{}, error) {
+	defaultsPath := filepath.Join(filepath.Dir(schemaPath), "values.yaml")
+	content, err := os.ReadFile(defaultsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", s.defaultValues(), nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var defaults interface{}
+	if err := yaml.Unmarshal(content, &defaults); err != nil {
+		return "", nil, fmt.Errorf("could not parse the values of the chart %s: %w", defaultsPath, err)
+	}
+	for _, valuesFile := range valuesFiles {
+		if sameFile(valuesFile, defaultsPath) {
+			return "", defaults, nil
+		}
+	}
+	return defaultsPath, defaults, nil
+}
+
+// defaultValues returns the values made of the defaults of the schema and its properties,
+// nil if there are none
+func (s *Schema) defaultValues() interface{} {
+	if s == nil {
+		return nil
+	}
+	if s.Default != nil {
+		return s.Default
+	}
+
+	values := map[string]interface{}{}
+	for name, property := range s.Properties {
+		if value := property.defaultValues(); value != nil {
+			values[name] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// coalesceValues returns values completed by defaults like helm coalesces the values of a release
+// with the ones of its chart: maps are merged recursively, missing keys are taken from defaults
+// and keys set to null are removed if defaults has them.
+func coalesceValues(values, defaults interface{}) interface{} {
+	if values == nil {
+		return defaults
+	}
+	valuesMap, ok := values.(map[string]interface{})
+	if !ok {
+		return values
+	}
+	defaultsMap, ok := defaults.(map[string]interface{})
+	if !ok {
+		return values
+	}
+
+	coalesced := make(map[string]interface{}, len(valuesMap))
+	for key, value := range valuesMap {
+		coalesced[key] = value
+	}
+	for key, defaultValue := range defaultsMap {
+		value, ok := coalesced[key]
+		switch {
+		case !ok:
+			coalesced[key] = defaultValue
+		case value == nil:
+			delete(coalesced, key)
+		default:
+			coalesced[key] = coalesceValues(value, defaultValue)
+		}
+	}
+	return coalesced
+}
+
+// sameFile returns true if both paths name the same file
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// ValuesReport is the result of CheckValues in a machine readable form
+type ValuesReport struct {
+	// Valid is true if the schema accepts all values
+	Valid bool `json:"valid"`
+	// Errors are the values the schema doesn't accept
+	Errors []ValuesFinding `json:"errors"`
+}
+
+// NewValuesReport returns the report of the findings of CheckValues
+func NewValuesReport(findings []ValuesFinding) ValuesReport {
+	if findings == nil {
+		findings = []ValuesFinding{}
+	}
+	return ValuesReport{Valid: len(findings) == 0, Errors: findings}
+}
+
+// ToJson converts the report to indented json
+func (r ValuesReport) ToJson() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
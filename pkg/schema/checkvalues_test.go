@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCheckValues(t *testing.T) {
+	dir := t.TempDir()
+	s := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"image": {
+				Type:       StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{"tag": {Type: StringOrArrayOfString{"string"}}},
+			},
+			"replicas": {Type: StringOrArrayOfString{"integer"}, Minimum: new(int)},
+		},
+	}
+	*s.Properties["replicas"].Minimum = 1
+	schemaPath := filepath.Join(dir, "values.schema.json")
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	defaults := write("values.yaml", "image:\n  tag: latest\nreplicas: 1\n")
+	overrides := write("overrides.yaml", "replicas: 0\nimage:\n  tag: 1\n")
+
+	findings, err := s.CheckValues(schemaPath, []string{defaults})
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+
+	// the files are merged, the errors are located in the file setting the value
+	findings, err = s.CheckValues(schemaPath, []string{defaults, overrides})
+	assert.NoError(t, err)
+	type location struct {
+		file string
+		line int
+		path string
+	}
+	var locations []location
+	for _, finding := range findings {
+		assert.NotEmpty(t, finding.Message)
+		locations = append(locations, location{finding.File, finding.Line, finding.Path})
+	}
+	assert.ElementsMatch(t, []location{{overrides, 1, "replicas"}, {overrides, 3, "image.tag"}}, locations)
+
+	_, err = s.CheckValues(schemaPath, nil)
+	assert.Error(t, err)
+}
+
+func TestCheckValuesCoalescesDefaults(t *testing.T) {
+	dir := t.TempDir()
+	write := func(dir, name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	values := "image:\n  repository: nginx\n  tag: latest\nreplicas: 1\n"
+	valuesPath := write(dir, "values.yaml", values)
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	s := YamlToSchema(valuesPath, &node, false, false, false, true, &SkipAutoGenerationConfig{}, nil, nil)
+	assert.ElementsMatch(t, []string{"image", "replicas"}, s.Required.Strings)
+	assert.Equal(t, new(bool), s.AdditionalProperties)
+	jsonStr, err := s.ToJson()
+	assert.NoError(t, err)
+	schemaPath := write(dir, "values.schema.json", string(jsonStr))
+
+	// the overrides alone lack the required keys, the values.yaml of the chart fills them in
+	overrides := write(dir, "overrides.yaml", "image:\n  tag: \"1.25\"\n")
+	findings, err := s.CheckValues(schemaPath, []string{overrides})
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+
+	invalid := write(dir, "invalid.yaml", "image:\n  tag: \"1.25\"\nreplicas: many\n")
+	findings, err = s.CheckValues(schemaPath, []string{invalid})
+	assert.NoError(t, err)
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, ValuesFinding{File: invalid, Line: 3, Path: "replicas"},
+			ValuesFinding{File: findings[0].File, Line: findings[0].Line, Path: findings[0].Path})
+	}
+
+	// null removes the default of the chart like helm does
+	removed := write(dir, "removed.yaml", "image:\n  tag: null\n")
+	findings, err = s.CheckValues(schemaPath, []string{removed})
+	assert.NoError(t, err)
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, "image", findings[0].Path)
+		assert.Contains(t, findings[0].Message, "tag")
+	}
+
+	// without a values.yaml next to the schema the defaults of the schema are used
+	findings, err = s.CheckValues(filepath.Join(t.TempDir(), "values.schema.json"), []string{overrides})
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestCoalesceValues(t *testing.T) {
+	defaults := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "latest"},
+		"replicas": 1,
+	}
+	values := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": nil},
+		"replicas": nil,
+		"extra":    nil,
+	}
+	assert.Equal(t, map[string]interface{}{
+		"image": map[string]interface{}{"repository": "nginx"},
+		"extra": nil,
+	}, coalesceValues(values, defaults))
+	assert.Equal(t, defaults, coalesceValues(nil, defaults))
+	assert.Equal(t, "value", coalesceValues("value", defaults))
+}
+
+func TestValuesFindingString(t *testing.T) {
+	assert.Equal(t, "overrides.yaml:3: image.tag: got number, want string",
+		ValuesFinding{File: "overrides.yaml", Line: 3, Path: "image.tag", Message: "got number, want string"}.String())
+	assert.Equal(t, "missing property 'image'", ValuesFinding{Message: "missing property 'image'"}.String())
+}
+
+func TestValuesReportJson(t *testing.T) {
+	jsonStr, err := NewValuesReport(nil).ToJson()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"valid": true, "errors": []}`, string(jsonStr))
+
+	jsonStr, err = NewValuesReport([]ValuesFinding{{File: "values.yaml", Line: 2, Path: "replicas", Message: "too small"}}).ToJson()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"valid": false, "errors": [{"file": "values.yaml", "line": 2, "path": "replicas", "message": "too small"}]}`, string(jsonStr))
+}
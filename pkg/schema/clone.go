@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the schema. The copy doesn't share any pointers, maps
+// or slices with the original, so both can be modified independently.
+func (s *Schema) Clone() *Schema {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+
+	c.Type = slices.Clone(s.Type)
+	c.Required.Strings = slices.Clone(s.Required.Strings)
+	c.RenamedFrom = slices.Clone(s.RenamedFrom)
+	c.UniqueKeys = slices.Clone(s.UniqueKeys)
+	c.Forbidden = slices.Clone(s.Forbidden)
+	c.Links = slices.Clone(s.Links)
+
+	c.Default = cloneValue(s.Default)
+	c.Const = cloneValue(s.Const)
+	c.AdditionalProperties = cloneValue(s.AdditionalProperties)
+	c.Examples = cloneSlice(s.Examples)
+	c.Enum = cloneSlice(s.Enum)
+	if s.CustomAnnotations != nil {
+		c.CustomAnnotations = cloneMap(s.CustomAnnotations)
+	}
+	c.matchedPatterns = maps.Clone(s.matchedPatterns)
+	c.Extras = maps.Clone(s.Extras)
+
+	c.Properties = cloneSchemaMap(s.Properties)
+	c.PatternProperties = cloneSchemaMap(s.PatternProperties)
+	c.Defs = cloneSchemaMap(s.Defs)
+	c.Definitions = cloneSchemaMap(s.Definitions)
+
+	c.AllOf = cloneSchemaSlice(s.AllOf)
+	c.AnyOf = cloneSchemaSlice(s.AnyOf)
+	c.OneOf = cloneSchemaSlice(s.OneOf)
+	c.Variants = cloneSchemaSlice(s.Variants)
+
+	c.Items = s.Items.Clone()
+	c.If = s.If.Clone()
+	c.Then = s.Then.Clone()
+	c.Else = s.Else.Clone()
+	c.Not = s.Not.Clone()
+	c.ContentSchema = s.ContentSchema.Clone()
+	c.PropertyNames = s.PropertyNames.Clone()
+
+	for _, field := range []**int{
+		&c.Minimum, &c.Maximum, &c.ExclusiveMinimum, &c.ExclusiveMaximum, &c.MultipleOf,
+		&c.MinLength, &c.MaxLength, &c.MinItems, &c.MaxItems, &c.MinProperties, &c.MaxProperties,
+	} {
+		if *field != nil {
+			value := **field
+			*field = &value
+		}
+	}
+
+	return &c
+}
+
+func cloneSchemaMap(m map[string]*Schema) map[string]*Schema {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]*Schema, len(m))
+	for k, v := range m {
+		c[k] = v.Clone()
+	}
+	return c
+}
+
+func cloneSchemaSlice(s []*Schema) []*Schema {
+	if s == nil {
+		return nil
+	}
+	c := make([]*Schema, len(s))
+	for i, v := range s {
+		c[i] = v.Clone()
+	}
+	return c
+}
+
+func cloneMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = cloneValue(v)
+	}
+	return c
+}
+
+func cloneSlice(s []interface{}) []interface{} {
+	if s == nil {
+		return nil
+	}
+	c := make([]interface{}, len(s))
+	for i, v := range s {
+		c[i] = cloneValue(v)
+	}
+	return c
+}
+
+// cloneValue deep copies the values which can be found in interface typed fields
+// (decoded yaml or json values and schemas)
+func cloneValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return cloneMap(value)
+	case map[interface{}]interface{}:
+		c := make(map[interface{}]interface{}, len(value))
+		for k, v := range value {
+			c[k] = cloneValue(v)
+		}
+		return c
+	case []interface{}:
+		return cloneSlice(value)
+	case []string:
+		return slices.Clone(value)
+	case Schema:
+		return *value.Clone()
+	case *Schema:
+		return value.Clone()
+	}
+	return v
+}
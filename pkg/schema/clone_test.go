@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+	minLength := 1
+	original := &Schema{
+		Type:                 StringOrArrayOfString{"object"},
+		Required:             NewBoolOrArrayOfString([]string{"foo"}, false),
+		Default:              map[string]interface{}{"foo": []interface{}{"bar"}},
+		AdditionalProperties: Schema{Title: "additional"},
+		Enum:                 []interface{}{map[string]interface{}{"a": 1}},
+		CustomAnnotations:    map[string]interface{}{"x-list": []interface{}{"a"}},
+		Properties: map[string]*Schema{
+			"foo": {Title: "foo", MinLength: &minLength},
+		},
+		Items: &Schema{AnyOf: []*Schema{{Title: "item"}}},
+		Defs:  map[string]*Schema{"def": {Title: "def"}},
+	}
+
+	clone := original.Clone()
+	assert.Equal(t, original, clone)
+
+	clone.Type[0] = "string"
+	clone.Required.Strings[0] = "bar"
+	clone.Default.(map[string]interface{})["foo"].([]interface{})[0] = "baz"
+	clone.AdditionalProperties = Schema{Title: "changed"}
+	clone.Enum[0].(map[string]interface{})["a"] = 2
+	clone.CustomAnnotations["x-list"].([]interface{})[0] = "b"
+	clone.Properties["foo"].Title = "changed"
+	*clone.Properties["foo"].MinLength = 5
+	clone.Items.AnyOf[0].Title = "changed"
+	clone.Defs["def"].Title = "changed"
+
+	assert.Equal(t, StringOrArrayOfString{"object"}, original.Type)
+	assert.Equal(t, []string{"foo"}, original.Required.Strings)
+	assert.Equal(t, "bar", original.Default.(map[string]interface{})["foo"].([]interface{})[0])
+	assert.Equal(t, "additional", original.AdditionalProperties.(Schema).Title)
+	assert.Equal(t, 1, original.Enum[0].(map[string]interface{})["a"])
+	assert.Equal(t, "a", original.CustomAnnotations["x-list"].([]interface{})[0])
+	assert.Equal(t, "foo", original.Properties["foo"].Title)
+	assert.Equal(t, 1, *original.Properties["foo"].MinLength)
+	assert.Equal(t, "item", original.Items.AnyOf[0].Title)
+	assert.Equal(t, "def", original.Defs["def"].Title)
+}
+
+func TestCloneNil(t *testing.T) {
+	var s *Schema
+	assert.Nil(t, s.Clone())
+	assert.Nil(t, (&Schema{}).Clone().Properties)
+}
@@ -0,0 +1,282 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// CompatIssue is a change of a schema, which rejects values the previous schema accepted
+type CompatIssue struct {
+	// Path is the JSON pointer of the subschema in the previous schema ("" for the root)
+	Path string
+	// Message describes the change
+	Message string
+}
+
+func (i CompatIssue) String() string {
+	return fmt.Sprintf("%s: %s", pointerOrRoot(i.Path), i.Message)
+}
+
+// CheckCompatibility compares the schema with the schema of a previous version and reports the
+// changes, which make values invalid that were valid before (e.g. a new required property or a
+// removed enum value). Upgrading a release with such values fails, so these changes break users.
+// The check is structural: subschemas which can't be compared (e.g. changed references or
+// combinations) are reported as well, if they differ.
+func (s *Schema) CheckCompatibility(previous *Schema) []CompatIssue {
+	c := compatChecker{previousRoot: previous, root: s, visited: map[[2]*Schema]bool{}}
+	c.compare("", previous, s)
+	return c.issues
+}
+
+// compatChecker collects the issues while walking both schemas in parallel
+type compatChecker struct {
+	previousRoot, root *Schema
+	visited            map[[2]*Schema]bool
+	issues             []CompatIssue
+}
+
+func (c *compatChecker) report(path, format string, args ...interface{}) {
+	c.issues = append(c.issues, CompatIssue{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *compatChecker) compare(path string, previous, current *Schema) {
+	if previous == nil || current == nil {
+		// no constraints before or now
+		return
+	}
+
+	if isInternalRef(previous.Ref) {
+		previous = c.previousRoot.followRef(previous)
+	}
+	if isInternalRef(current.Ref) {
+		current = c.root.followRef(current)
+	}
+	if c.visited[[2]*Schema{previous, current}] {
+		return
+	}
+	c.visited[[2]*Schema{previous, current}] = true
+
+	if previous.Ref != current.Ref {
+		c.report(path, "the reference changed from %q to %q, the referenced schemas are not compared", previous.Ref, current.Ref)
+	}
+
+	c.compareTypes(path, previous, current)
+	c.compareValues(path, previous, current)
+	c.compareBounds(path, previous, current)
+
+	if current.Pattern != "" && current.Pattern != previous.Pattern {
+		c.report(path, "the pattern changed from %q to %q", previous.Pattern, current.Pattern)
+	}
+	if current.Format != "" && current.Format != previous.Format {
+		c.report(path, "the format changed from %q to %q", previous.Format, current.Format)
+	}
+	if current.UniqueItems && !previous.UniqueItems {
+		c.report(path, "the items must be unique now")
+	}
+	if current.MultipleOf != nil && (previous.MultipleOf == nil || *current.MultipleOf%*previous.MultipleOf != 0) {
+		c.report(path, "the values must be a multiple of %d now", *current.MultipleOf)
+	}
+
+	c.compareObjects(path, previous, current)
+	c.compare(path+"/items", previous.Items, current.Items)
+
+	for _, sub := range []struct {
+		keyword           string
+		previous, current []*Schema
+	}{{"allOf", previous.AllOf, current.AllOf}, {"anyOf", previous.AnyOf, current.AnyOf}, {"oneOf", previous.OneOf, current.OneOf}} {
+		if len(sub.current) > 0 && !equalSchemas(sub.previous, sub.current) {
+			c.report(path, "%s changed, the combined schemas are not compared", sub.keyword)
+		}
+	}
+	for _, sub := range []struct {
+		keyword           string
+		previous, current *Schema
+	}{{"not", previous.Not, current.Not}, {"if", previous.If, current.If}, {"then", previous.Then, current.Then}, {"else", previous.Else, current.Else}} {
+		if sub.current != nil && (sub.previous == nil || !sub.previous.Equals(sub.current)) {
+			c.report(path, "%s changed, the conditional schemas are not compared", sub.keyword)
+		}
+	}
+}
+
+// compareTypes reports the types which are not allowed anymore
+func (c *compatChecker) compareTypes(path string, previous, current *Schema) {
+	if len(current.Type) == 0 {
+		return
+	}
+	if len(previous.Type) == 0 {
+		c.report(path, "the type is restricted to %s now", strings.Join(current.Type, ", "))
+		return
+	}
+	for _, t := range previous.Type {
+		if slices.Contains(current.Type, t) || (t == "integer" && slices.Contains(current.Type, "number")) {
+			continue
+		}
+		c.report(path, "the type %s is not allowed anymore", t)
+	}
+}
+
+// compareValues reports the enum values and constants which are not allowed anymore
+func (c *compatChecker) compareValues(path string, previous, current *Schema) {
+	if len(current.Enum) > 0 {
+		if len(previous.Enum) == 0 && previous.Const == nil {
+			c.report(path, "the values are restricted to the enum %s now", jsonString(current.Enum))
+		}
+		for _, value := range previous.Enum {
+			if !containsValue(current.Enum, value) {
+				c.report(path, "the enum value %s was removed", jsonString(value))
+			}
+		}
+		if previous.Const != nil && !containsValue(current.Enum, previous.Const) {
+			c.report(path, "the value %s is not in the enum anymore", jsonString(previous.Const))
+		}
+	}
+	if current.Const != nil && (previous.Const == nil || !reflect.DeepEqual(jsonValue(previous.Const), jsonValue(current.Const))) {
+		c.report(path, "the value must be %s now", jsonString(current.Const))
+	}
+}
+
+// compareBounds reports the numeric, length and size limits which got stricter
+func (c *compatChecker) compareBounds(path string, previous, current *Schema) {
+	lower := []struct {
+		keyword           string
+		previous, current *int
+	}{
+		{"minimum", previous.Minimum, current.Minimum},
+		{"exclusiveMinimum", previous.ExclusiveMinimum, current.ExclusiveMinimum},
+		{"minLength", previous.MinLength, current.MinLength},
+		{"minItems", previous.MinItems, current.MinItems},
+		{"minProperties", previous.MinProperties, current.MinProperties},
+	}
+	for _, bound := range lower {
+		switch {
+		case bound.current == nil:
+		case bound.previous == nil:
+			c.report(path, "%s %d was added", bound.keyword, *bound.current)
+		case *bound.current > *bound.previous:
+			c.report(path, "%s was raised from %d to %d", bound.keyword, *bound.previous, *bound.current)
+		}
+	}
+
+	upper := []struct {
+		keyword           string
+		previous, current *int
+	}{
+		{"maximum", previous.Maximum, current.Maximum},
+		{"exclusiveMaximum", previous.ExclusiveMaximum, current.ExclusiveMaximum},
+		{"maxLength", previous.MaxLength, current.MaxLength},
+		{"maxItems", previous.MaxItems, current.MaxItems},
+		{"maxProperties", previous.MaxProperties, current.MaxProperties},
+	}
+	for _, bound := range upper {
+		switch {
+		case bound.current == nil:
+		case bound.previous == nil:
+			c.report(path, "%s %d was added", bound.keyword, *bound.current)
+		case *bound.current < *bound.previous:
+			c.report(path, "%s was lowered from %d to %d", bound.keyword, *bound.previous, *bound.current)
+		}
+	}
+}
+
+// compareObjects reports new required properties and properties which are not allowed anymore,
+// the properties of both schemas are compared recursively
+func (c *compatChecker) compareObjects(path string, previous, current *Schema) {
+	for _, name := range current.Required.Strings {
+		if !slices.Contains(previous.Required.Strings, name) {
+			c.report(path, "the property %s is required now", name)
+		}
+	}
+
+	previousClosed := isClosed(previous.AdditionalProperties)
+	if isClosed(current.AdditionalProperties) {
+		if !previousClosed {
+			c.report(path, "additionalProperties is false now, keys which are not properties are rejected")
+		} else {
+			for _, name := range sortedSchemaKeys(previous.Properties) {
+				if _, ok := current.Properties[name]; !ok && !matchesPatternProperty(current, name) {
+					c.report(path, "the property %s was removed", name)
+				}
+			}
+		}
+	}
+
+	for _, name := range sortedSchemaKeys(previous.Properties) {
+		c.compare(path+PropertyPointer(name), previous.Properties[name], current.Properties[name])
+	}
+
+	if previousAdditional, ok := previous.AdditionalProperties.(Schema); ok {
+		if currentAdditional, ok := current.AdditionalProperties.(Schema); ok {
+			c.compare(path+"/additionalProperties", &previousAdditional, &currentAdditional)
+		}
+	}
+}
+
+// isClosed checks if additionalProperties is false
+func isClosed(additionalProperties SchemaOrBool) bool {
+	switch allowed := additionalProperties.(type) {
+	case bool:
+		return !allowed
+	case *bool:
+		return allowed != nil && !*allowed
+	}
+	return false
+}
+
+// matchesPatternProperty checks if the key matches one of the patternProperties of the schema
+func matchesPatternProperty(s *Schema, key string) bool {
+	for pattern := range s.PatternProperties {
+		if matched, err := regexp.MatchString(pattern, key); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// equalSchemas checks if both lists contain the same schemas (ignoring the descriptive fields)
+func equalSchemas(a, b []*Schema) bool {
+	return slices.EqualFunc(a, b, func(x, y *Schema) bool { return x.Equals(y) })
+}
+
+// containsValue checks if the json representation of the value is in the values
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(jsonValue(v), jsonValue(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonValue returns the value like it's written to the schema, so e.g. 1 and 1.0 are equal
+func jsonValue(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return value
+	}
+	return generic
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func sortedSchemaKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// compatTestSchema returns an object schema with the properties
+func compatTestSchema(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{
+		Type:       StringOrArrayOfString{"object"},
+		Properties: properties,
+		Required:   BoolOrArrayOfString{Strings: required},
+	}
+}
+
+func compatMessages(issues []CompatIssue) []string {
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+	return messages
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous *Schema
+		current  *Schema
+		expected []string
+	}{
+		{
+			name:     "identical",
+			previous: compatTestSchema(map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}, Minimum: intPtr(1)}}, "port"),
+			current:  compatTestSchema(map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}, Minimum: intPtr(1)}}, "port"),
+		},
+		{
+			name:     "relaxed",
+			previous: compatTestSchema(map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}, Minimum: intPtr(1024), Enum: []interface{}{1024, 8080}}}, "port"),
+			current:  compatTestSchema(map[string]*Schema{"port": {Type: StringOrArrayOfString{"number"}, Minimum: intPtr(1), Enum: []interface{}{1024, 8080, 9090}}}),
+		},
+		{
+			name:     "required",
+			previous: compatTestSchema(map[string]*Schema{"host": {Type: StringOrArrayOfString{"string"}}}),
+			current:  compatTestSchema(map[string]*Schema{"host": {Type: StringOrArrayOfString{"string"}}}, "host"),
+			expected: []string{"#: the property host is required now"},
+		},
+		{
+			name:     "enum",
+			previous: compatTestSchema(map[string]*Schema{"level": {Enum: []interface{}{"debug", "info"}}, "mode": {Type: StringOrArrayOfString{"string"}}}),
+			current:  compatTestSchema(map[string]*Schema{"level": {Enum: []interface{}{"info"}}, "mode": {Type: StringOrArrayOfString{"string"}, Enum: []interface{}{"a"}}}),
+			expected: []string{`#/properties/level: the enum value "debug" was removed`, `#/properties/mode: the values are restricted to the enum ["a"] now`},
+		},
+		{
+			name:     "types",
+			previous: compatTestSchema(map[string]*Schema{"replicas": {Type: StringOrArrayOfString{"integer", "string"}}, "any": {}}),
+			current:  compatTestSchema(map[string]*Schema{"replicas": {Type: StringOrArrayOfString{"integer"}}, "any": {Type: StringOrArrayOfString{"string"}}}),
+			expected: []string{"#/properties/any: the type is restricted to string now", "#/properties/replicas: the type string is not allowed anymore"},
+		},
+		{
+			name:     "bounds",
+			previous: compatTestSchema(map[string]*Schema{"port": {Minimum: intPtr(1)}, "name": {MaxLength: intPtr(63)}}),
+			current:  compatTestSchema(map[string]*Schema{"port": {Minimum: intPtr(1024)}, "name": {MaxLength: intPtr(53), MinLength: intPtr(1)}}),
+			expected: []string{"#/properties/name: minLength 1 was added", "#/properties/name: maxLength was lowered from 63 to 53", "#/properties/port: minimum was raised from 1 to 1024"},
+		},
+		{
+			name:     "pattern",
+			previous: compatTestSchema(map[string]*Schema{"tag": {Pattern: "^v"}}),
+			current:  compatTestSchema(map[string]*Schema{"tag": {Pattern: "^v[0-9]"}}),
+			expected: []string{`#/properties/tag: the pattern changed from "^v" to "^v[0-9]"`},
+		},
+		{
+			name:     "closed",
+			previous: compatTestSchema(map[string]*Schema{"a": {}, "b": {}}),
+			current: func() *Schema {
+				s := compatTestSchema(map[string]*Schema{"a": {}})
+				s.AdditionalProperties = false
+				return s
+			}(),
+			expected: []string{"#: additionalProperties is false now, keys which are not properties are rejected"},
+		},
+		{
+			name: "removed",
+			previous: func() *Schema {
+				s := compatTestSchema(map[string]*Schema{"a": {}, "b": {}, "x-extra": {}})
+				s.AdditionalProperties = false
+				return s
+			}(),
+			current: func() *Schema {
+				s := compatTestSchema(map[string]*Schema{"a": {}})
+				s.AdditionalProperties = new(bool)
+				s.PatternProperties = map[string]*Schema{"^x-": {}}
+				return s
+			}(),
+			expected: []string{"#: the property b was removed"},
+		},
+		{
+			name: "references",
+			previous: func() *Schema {
+				s := compatTestSchema(map[string]*Schema{"port": {Ref: "#/definitions/port"}})
+				s.Definitions = map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}, Maximum: intPtr(65535)}}
+				return s
+			}(),
+			current: func() *Schema {
+				s := compatTestSchema(map[string]*Schema{"port": {Ref: "#/definitions/port"}})
+				s.Definitions = map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}, Maximum: intPtr(9999)}}
+				return s
+			}(),
+			expected: []string{"#/properties/port: maximum was lowered from 65535 to 9999"},
+		},
+		{
+			name:     "items",
+			previous: compatTestSchema(map[string]*Schema{"hosts": {Type: StringOrArrayOfString{"array"}, Items: &Schema{Type: StringOrArrayOfString{"string"}}}}),
+			current:  compatTestSchema(map[string]*Schema{"hosts": {Type: StringOrArrayOfString{"array"}, UniqueItems: true, Items: &Schema{Type: StringOrArrayOfString{"string"}, Format: "hostname"}}}),
+			expected: []string{"#/properties/hosts: the items must be unique now", `#/properties/hosts/items: the format changed from "" to "hostname"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.ElementsMatch(t, test.expected, compatMessages(test.current.CheckCompatibility(test.previous)))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LintDeprecatedUsage reports the keys of the values, which are deprecated and set to another
+// value than their default. These are e.g. deprecated keys of dependencies, which the parent
+// chart still sets. Deprecated keys of the chart itself only are issues, if other values files
+// of the chart override them. Items of lists are addressed by their index.
+func (s *Schema) LintDeprecatedUsage(values []byte) ([]LintIssue, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(values, &document); err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	// items are linted against each variant, but their keys are only reported once
+	reported := make(map[string]bool)
+	var lint func(v *Schema, value interface{}, path []string)
+	lint = func(v *Schema, value interface{}, path []string) {
+		if v == nil {
+			return
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(value))
+			for key := range value {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				property, ok := v.Properties[key]
+				if !ok || property == nil {
+					continue
+				}
+				propertyPath := append(slices.Clone(path), key)
+				if property.Deprecated && !matchesDefaults(property, value[key]) {
+					if reported[dottedPath(propertyPath)] {
+						continue
+					}
+					reported[dottedPath(propertyPath)] = true
+					issues = append(issues, LintIssue{
+						Rule:     WarningDeprecatedUsage,
+						Severity: SeverityWarning,
+						Path:     propertyPath,
+						Message:  "the key is deprecated, but set to another value than its default",
+					})
+					continue
+				}
+				lint(property, value[key], propertyPath)
+			}
+		case []interface{}:
+			if v.Items == nil {
+				return
+			}
+			for i, item := range value {
+				itemPath := append(slices.Clone(path), strconv.Itoa(i))
+				lint(v.Items, item, itemPath)
+				for _, variant := range v.Items.AnyOf {
+					lint(variant, item, itemPath)
+				}
+			}
+		}
+	}
+	lint(s, document, nil)
+	return issues, nil
+}
+
+// matchesDefaults checks if the value is the default of the schema. Objects usually have no
+// default, so their keys are compared with the defaults of their properties.
+func matchesDefaults(s *Schema, value interface{}) bool {
+	if s.Default != nil {
+		// the defaults of annotations without type are kept as text
+		if text, ok := s.Default.(string); ok {
+			switch value.(type) {
+			case bool, int, float64:
+				if fmt.Sprint(value) == text {
+					return true
+				}
+			}
+		}
+		return sameJSON(s.Default, value)
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok || len(s.Properties) == 0 {
+		return false
+	}
+	for key, sub := range values {
+		property, ok := s.Properties[key]
+		if !ok || property == nil || !matchesDefaults(property, sub) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameJSON checks if both values are equal in json, e.g. the integer 1 of a values file
+// and the number 1 of a schema file
+func sameJSON(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
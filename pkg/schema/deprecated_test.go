@@ -0,0 +1,43 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintDeprecatedUsage(t *testing.T) {
+	s := selfCheckSchema(t, `# @schema
+# deprecated: true
+# @schema
+legacyMode: false
+# @schema
+# deprecated: true
+# @schema
+oldImage:
+  tag: "1.0"
+ports:
+  - name: http
+    # @schema
+    # deprecated: true
+    # @schema
+    protocol: TCP
+`)
+
+	// the defaults of the chart itself are no issues
+	issues, err := s.LintDeprecatedUsage([]byte("legacyMode: false\noldImage:\n  tag: \"1.0\"\nports:\n  - name: http\n    protocol: TCP\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+
+	issues, err = s.LintDeprecatedUsage([]byte("legacyMode: true\noldImage:\n  tag: \"2.0\"\nports:\n  - name: http\n  - name: dns\n    protocol: UDP\n"))
+	assert.NoError(t, err)
+	paths := [][]string{}
+	for _, issue := range issues {
+		assert.Equal(t, WarningDeprecatedUsage, issue.Rule)
+		paths = append(paths, issue.Path)
+	}
+	assert.Equal(t, [][]string{{"legacyMode"}, {"oldImage"}, {"ports", "1", "protocol"}}, paths)
+
+	_, err = s.LintDeprecatedUsage([]byte("key: [unclosed\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DescriptionSanitizeConfig defines how the descriptions of a schema are cleaned up
+type DescriptionSanitizeConfig struct {
+	// Normalize trims every line and collapses repeated whitespace and empty lines
+	Normalize bool
+	// StripMarkdown converts markdown to plain text
+	StripMarkdown bool
+	// MaxLength truncates longer descriptions (in characters) and appends an ellipsis, 0 disables it
+	MaxLength int
+	// LinkDocs appends a link to the documentation of the value (see DocsURL) to the description,
+	// after it's truncated. With StripMarkdown the url is appended as plain text.
+	LinkDocs bool
+}
+
+// Enabled returns true if any sanitization is configured
+func (c DescriptionSanitizeConfig) Enabled() bool {
+	return c.Normalize || c.StripMarkdown || c.MaxLength > 0 || c.LinkDocs
+}
+
+const descriptionEllipsis = "…"
+
+var (
+	markdownCodeFenceRegex  = regexp.MustCompile("(?m)^\\s*(```|~~~).*$\\n?")
+	markdownImageRegex      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLinkRegex       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownHeadingRegex    = regexp.MustCompile(`(?m)^\s*#{1,6}\s+`)
+	markdownBlockquoteRegex = regexp.MustCompile(`(?m)^\s*>\s?`)
+	markdownBoldRegex       = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	markdownItalicRegex     = regexp.MustCompile(`\B\*(\S(?:[^*]*?\S)?)\*\B|\b_(\S(?:[^_]*?\S)?)_\b`)
+	markdownCodeRegex       = regexp.MustCompile("`([^`]*)`")
+	htmlTagRegex            = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	repeatedSpacesRegex     = regexp.MustCompile(`[ \t]+`)
+	repeatedNewlinesRegex   = regexp.MustCompile(`\n{3,}`)
+)
+
+// SanitizeDescription cleans up the given description as configured
+func SanitizeDescription(description string, config DescriptionSanitizeConfig) string {
+	if config.StripMarkdown {
+		description = stripMarkdown(description)
+	}
+
+	if config.Normalize {
+		description = normalizeWhitespace(description)
+	}
+
+	if config.MaxLength > 0 {
+		description = truncateDescription(description, config.MaxLength)
+	}
+
+	return description
+}
+
+// stripMarkdown converts the most common markdown elements to plain text
+func stripMarkdown(text string) string {
+	text = markdownCodeFenceRegex.ReplaceAllString(text, "")
+	text = markdownImageRegex.ReplaceAllString(text, "$1")
+	text = markdownLinkRegex.ReplaceAllString(text, "$1")
+	text = markdownHeadingRegex.ReplaceAllString(text, "")
+	text = markdownBlockquoteRegex.ReplaceAllString(text, "")
+	text = markdownBoldRegex.ReplaceAllString(text, "$2")
+	text = markdownItalicRegex.ReplaceAllString(text, "$1$2")
+	text = markdownCodeRegex.ReplaceAllString(text, "$1")
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	return text
+}
+
+// normalizeWhitespace trims all lines, collapses repeated spaces and
+// reduces multiple empty lines to a single one
+func normalizeWhitespace(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = repeatedSpacesRegex.ReplaceAllString(strings.TrimSpace(line), " ")
+	}
+	text = strings.Join(lines, "\n")
+	text = repeatedNewlinesRegex.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// truncateDescription cuts the text to maxLength characters (including the ellipsis),
+// preferably at a word boundary
+func truncateDescription(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+
+	cut := string(runes[:maxLength-1])
+	if idx := strings.LastIndexAny(cut, " \n\t"); idx > len(cut)/2 {
+		cut = cut[:idx]
+	}
+
+	return strings.TrimRight(cut, " \n\t.,;:") + descriptionEllipsis
+}
+
+// SanitizeDescriptions sanitizes the descriptions of the schema and all its subschemas
+func (s *Schema) SanitizeDescriptions(config DescriptionSanitizeConfig) {
+	if !config.Enabled() {
+		return
+	}
+
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if v.Description != "" {
+			v.Description = SanitizeDescription(v.Description, config)
+		}
+		if docsURL := v.DocsURL(); config.LinkDocs && docsURL != "" {
+			link := docsLinkText(docsURL, config.StripMarkdown)
+			if v.Description == "" {
+				v.Description = link
+			} else if !strings.Contains(v.Description, docsURL) {
+				v.Description += "\n\n" + link
+			}
+		}
+		return nil
+	})
+}
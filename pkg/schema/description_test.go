@@ -0,0 +1,124 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		config      DescriptionSanitizeConfig
+		expected    string
+	}{
+		{
+			name:        "disabled",
+			description: "  some   **bold**  text  ",
+			config:      DescriptionSanitizeConfig{},
+			expected:    "  some   **bold**  text  ",
+		},
+		{
+			name:        "normalize",
+			description: "  first   line  \n\n\n\n\tsecond\t line \n",
+			config:      DescriptionSanitizeConfig{Normalize: true},
+			expected:    "first line\n\nsecond line",
+		},
+		{
+			name:        "strip markdown",
+			description: "## Image\nSee [the docs](https://example.org) for **all** _options_ of `image.tag`.\n> Note: <b>important</b>",
+			config:      DescriptionSanitizeConfig{StripMarkdown: true},
+			expected:    "Image\nSee the docs for all options of image.tag.\nNote: important",
+		},
+		{
+			name:        "strip markdown keeps identifiers",
+			description: "use snake_case_names or 2*3*4",
+			config:      DescriptionSanitizeConfig{StripMarkdown: true},
+			expected:    "use snake_case_names or 2*3*4",
+		},
+		{
+			name:        "strip code fences",
+			description: "Example:\n```yaml\nfoo: bar\n```\n",
+			config:      DescriptionSanitizeConfig{StripMarkdown: true},
+			expected:    "Example:\nfoo: bar\n",
+		},
+		{
+			name:        "max length",
+			description: "The number of replicas of the deployment",
+			config:      DescriptionSanitizeConfig{MaxLength: 20},
+			expected:    "The number of…",
+		},
+		{
+			name:        "max length not reached",
+			description: "Replicas",
+			config:      DescriptionSanitizeConfig{MaxLength: 8},
+			expected:    "Replicas",
+		},
+		{
+			name:        "max length counts characters",
+			description: "äöüäöüäöü",
+			config:      DescriptionSanitizeConfig{MaxLength: 5},
+			expected:    "äöüä…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SanitizeDescription(tt.description, tt.config))
+		})
+	}
+}
+
+func TestSanitizeDescriptions(t *testing.T) {
+	schema := &Schema{
+		Description: " root ",
+		Properties: map[string]*Schema{
+			"foo": {
+				Description: "**foo**  ",
+				Items:       &Schema{Description: "`item`"},
+			},
+		},
+		AdditionalProperties: Schema{Description: "_additional_"},
+	}
+
+	schema.SanitizeDescriptions(DescriptionSanitizeConfig{Normalize: true, StripMarkdown: true})
+
+	assert.Equal(t, "root", schema.Description)
+	assert.Equal(t, "foo", schema.Properties["foo"].Description)
+	assert.Equal(t, "item", schema.Properties["foo"].Items.Description)
+	assert.Equal(t, "additional", schema.AdditionalProperties.(Schema).Description)
+}
+
+func TestLinkDocs(t *testing.T) {
+	schema := &Schema{
+		Properties: map[string]*Schema{
+			"image": {
+				Description:       "The image",
+				CustomAnnotations: map[string]interface{}{DocsURLAnnotation: "https://example.org/docs/image"},
+			},
+			"ingress": {
+				Links: []Link{
+					{Rel: "self", Href: "https://example.org"},
+					{Rel: "describedby", Href: "https://example.org/docs/ingress"},
+				},
+			},
+			"linked": {
+				Description:       "See https://example.org/docs/linked",
+				CustomAnnotations: map[string]interface{}{DocsURLAnnotation: "https://example.org/docs/linked"},
+			},
+			"plain": {Description: "Plain"},
+		},
+	}
+
+	schema.SanitizeDescriptions(DescriptionSanitizeConfig{LinkDocs: true})
+
+	assert.Equal(t, "The image\n\n[Documentation](https://example.org/docs/image)", schema.Properties["image"].Description)
+	assert.Equal(t, "[Documentation](https://example.org/docs/ingress)", schema.Properties["ingress"].Description)
+	assert.Equal(t, "See https://example.org/docs/linked", schema.Properties["linked"].Description)
+	assert.Equal(t, "Plain", schema.Properties["plain"].Description)
+
+	plain := &Schema{Description: "**The** image", CustomAnnotations: map[string]interface{}{DocsURLAnnotation: "https://example.org/docs"}}
+	plain.SanitizeDescriptions(DescriptionSanitizeConfig{LinkDocs: true, StripMarkdown: true})
+	assert.Equal(t, "The image\n\nDocumentation: https://example.org/docs", plain.Description)
+}
@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"slices"
+	"strings"
+)
+
+// The $schema uris of the drafts schemas are written in
+const (
+	Draft07URI     = "http://json-schema.org/draft-07/schema#"
+	Draft202012URI = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// Draft202012Suffix is added to the name of the schema file for the draft 2020-12 schema
+const Draft202012Suffix = ".2020-12"
+
+// Draft202012OutputPath returns the path of the draft 2020-12 schema, which is written next to
+// the draft-07 schema at outPath (e.g. values.schema.2020-12.json for values.schema.json)
+func Draft202012OutputPath(outPath string) string {
+	if base, ok := strings.CutSuffix(outPath, ".json"); ok {
+		return base + Draft202012Suffix + ".json"
+	}
+	return outPath + Draft202012Suffix
+}
+
+// ToDraft202012 returns a copy of the draft-07 schema translated to draft 2020-12, for editors
+// and validators which expect the newer draft. The definitions are moved to $defs and the
+// references to them are updated. Definitions which differ from a $defs entry with the same
+// name are kept, references to them still resolve as json pointers.
+func (s *Schema) ToDraft202012() *Schema {
+	c := s.Clone()
+	if c.Schema == Draft07URI {
+		c.Schema = Draft202012URI
+	}
+
+	moved := map[string]bool{}
+	_ = c.Walk(func(path string, v *Schema) error {
+		for name, definition := range v.Definitions {
+			if existing, ok := v.Defs[name]; ok && !existing.EqualsOpt(definition, EqualsFull) {
+				continue
+			}
+			if v.Defs == nil {
+				v.Defs = make(map[string]*Schema)
+			}
+			v.Defs[name] = definition
+			delete(v.Definitions, name)
+			moved[path+"/definitions/"+escapePointerSegment(name)] = true
+		}
+		if len(v.Definitions) == 0 {
+			v.Definitions = nil
+		}
+		return nil
+	})
+
+	_ = c.Walk(func(_ string, v *Schema) error {
+		if isInternalRef(v.Ref) {
+			v.Ref = "#" + movePointer(strings.TrimPrefix(v.Ref, "#"), moved)
+		}
+		return nil
+	})
+
+	return c
+}
+
+// movePointer replaces the definitions keyword with $defs in the json pointer, if the
+// definition it points to (or into) was moved
+func movePointer(pointer string, moved map[string]bool) string {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+
+	// only keywords are replaced, not properties which are called definitions
+	isKeyword := true
+	for i, segment := range segments {
+		if !isKeyword {
+			isKeyword = true
+			continue
+		}
+		if segment == "definitions" && i+1 < len(segments) && moved["/"+strings.Join(segments[:i+2], "/")] {
+			segments[i] = "$defs"
+		}
+		isKeyword = !slices.Contains([]string{"properties", "patternProperties", "$defs", "definitions", "allOf", "anyOf", "oneOf"}, segment)
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
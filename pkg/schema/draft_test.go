@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraft202012OutputPath(t *testing.T) {
+	assert.Equal(t, "chart/values.schema.2020-12.json", Draft202012OutputPath("chart/values.schema.json"))
+	assert.Equal(t, "chart/schema.2020-12", Draft202012OutputPath("chart/schema"))
+}
+
+func TestToDraft202012(t *testing.T) {
+	s := &Schema{
+		Schema: Draft07URI,
+		Type:   StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"port":  {Ref: "#/definitions/port"},
+			"image": {Ref: "#/definitions/image/properties/tag"},
+			"name":  {Ref: "#/$defs/name"},
+			"conflict": {
+				Ref: "#/definitions/name",
+			},
+			"definitions": {
+				Type:       StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{"port": {Type: StringOrArrayOfString{"string"}}},
+			},
+			"alias": {Ref: "#/properties/definitions/properties/port"},
+		},
+		Defs: map[string]*Schema{
+			"name": {Type: StringOrArrayOfString{"string"}},
+		},
+		Definitions: map[string]*Schema{
+			"port": {Type: StringOrArrayOfString{"integer"}},
+			"image": {
+				Type:       StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{"tag": {Type: StringOrArrayOfString{"string"}}},
+			},
+			"name": {Type: StringOrArrayOfString{"integer"}},
+		},
+	}
+
+	translated := s.ToDraft202012()
+
+	assert.Equal(t, Draft202012URI, translated.Schema)
+	assert.Equal(t, "#/$defs/port", translated.Properties["port"].Ref)
+	assert.Equal(t, "#/$defs/image/properties/tag", translated.Properties["image"].Ref)
+	assert.Equal(t, "#/$defs/name", translated.Properties["name"].Ref)
+	assert.Contains(t, translated.Defs, "port")
+	assert.Contains(t, translated.Defs, "image")
+	// the definition which differs from the $defs entry is kept
+	assert.Equal(t, "#/definitions/name", translated.Properties["conflict"].Ref)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, translated.Definitions["name"].Type)
+	assert.Len(t, translated.Definitions, 1)
+	// properties called definitions are not renamed
+	assert.Equal(t, "#/properties/definitions/properties/port", translated.Properties["alias"].Ref)
+
+	// the draft-07 schema is not changed
+	assert.Equal(t, Draft07URI, s.Schema)
+	assert.Equal(t, "#/definitions/port", s.Properties["port"].Ref)
+	assert.Len(t, s.Definitions, 3)
+
+	assert.NoError(t, translated.ValidateInternalRefs())
+	assert.NoError(t, translated.ValidateValues([]byte("port: 80\nimage: latest\nname: app\n"), "values.schema.2020-12.json"))
+	assert.Error(t, translated.ValidateValues([]byte("port: eighty\n"), "values.schema.2020-12.json"))
+}
@@ -0,0 +1,316 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// jsonWriter is implemented by bytes.Buffer and bufio.Writer. Both remember write
+// errors, so the encoder doesn't check them and the caller checks them at the end.
+type jsonWriter interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
+// schemaField describes a field of Schema, which is part of the json schema
+type schemaField struct {
+	index     int
+	name      string
+	omitEmpty bool
+	// addr is set if the field type only implements json.Marshaler with a pointer receiver
+	addr bool
+}
+
+// jsonEntry is a key of a json object with the value to encode
+type jsonEntry struct {
+	key   string
+	value interface{}
+}
+
+var marshalerType = reflect.TypeFor[json.Marshaler]()
+
+// schemaFields returns the fields of Schema, which are encoded like encoding/json would do it
+var schemaFields = sync.OnceValue(func() []schemaField {
+	t := reflect.TypeFor[Schema]()
+	fields := []schemaField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" || field.Name == "CustomAnnotations" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, schemaField{
+			index:     i,
+			name:      name,
+			omitEmpty: slices.Contains(strings.Split(options, ","), "omitempty"),
+			addr:      !field.Type.Implements(marshalerType) && reflect.PointerTo(field.Type).Implements(marshalerType),
+		})
+	}
+	return fields
+})
+
+// schemaEncoder writes schemas as json without marshalling them into generic maps first.
+// The output is the same as the one of json.Marshal (or json.MarshalIndent) with sorted keys.
+type schemaEncoder struct {
+	w       jsonWriter
+	indent  string
+	scratch bytes.Buffer
+}
+
+// MarshalJSON custom marshal method for Schema. It inlines the CustomAnnotations fields
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := &schemaEncoder{w: &buf}
+	if err := enc.encodeSchema(s, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteJSON writes the indented json of the schema to w, without building it in memory first
+func (s *Schema) WriteJSON(w io.Writer) error {
+	buffered := bufio.NewWriter(w)
+	enc := &schemaEncoder{w: buffered, indent: "  "}
+	if err := enc.encodeSchema(s, 0); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// entries returns the keys of the json object of the schema in sorted order
+func (s *Schema) entries() []jsonEntry {
+	v := reflect.ValueOf(s).Elem()
+	fields := schemaFields()
+	entries := make([]jsonEntry, 0, len(fields)+len(s.CustomAnnotations))
+
+	for _, field := range fields {
+		fv := v.Field(field.index)
+		if field.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		switch field.name {
+		case "required":
+			// Remove "required" if the schema type is not object, or if a loaded schema had none
+			if s.Type.canDropRequired() || (s.omitRequired && len(s.Required.Strings) == 0) {
+				continue
+			}
+		case "additionalProperties":
+			// a schema value doesn't implement json.Marshaler
+			if subSchema, ok := s.AdditionalProperties.(Schema); ok {
+				entries = append(entries, jsonEntry{field.name, &subSchema})
+				continue
+			}
+		}
+		if field.addr {
+			fv = fv.Addr()
+		}
+		entries = append(entries, jsonEntry{field.name, fv.Interface()})
+	}
+
+	// Explicitly include const field when it was set to null
+	// This handles the case where const: null in YAML should appear as "const": null in JSON
+	if s.constWasSet && s.Const == nil {
+		entries = append(entries, jsonEntry{"const", nil})
+	}
+
+	// inline the CustomAnnotations fields, which take precedence over the other fields
+	for key, value := range s.CustomAnnotations {
+		if i := slices.IndexFunc(entries, func(e jsonEntry) bool { return e.key == key }); i >= 0 {
+			entries[i].value = value
+			continue
+		}
+		entries = append(entries, jsonEntry{key, value})
+	}
+
+	// the extras of loaded schemas are written as they were read, unless the field of the
+	// keyword was set since
+	for key, value := range s.Extras {
+		if slices.ContainsFunc(entries, func(e jsonEntry) bool { return e.key == key }) {
+			continue
+		}
+		entries = append(entries, jsonEntry{key, value})
+	}
+
+	slices.SortFunc(entries, func(a, b jsonEntry) int { return strings.Compare(a.key, b.key) })
+	return entries
+}
+
+func (e *schemaEncoder) encodeSchema(s *Schema, depth int) error {
+	if s == nil {
+		e.w.WriteString("null")
+		return nil
+	}
+
+	entries := s.entries()
+	if len(entries) == 0 {
+		e.w.WriteString("{}")
+		return nil
+	}
+
+	e.w.WriteByte('{')
+	for i, entry := range entries {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		e.writeKey(entry.key)
+		if err := e.encodeValue(entry.value, depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.w.WriteByte('}')
+	return nil
+}
+
+func (e *schemaEncoder) encodeValue(value interface{}, depth int) error {
+	switch v := value.(type) {
+	case *Schema:
+		return e.encodeSchema(v, depth)
+	case map[string]*Schema:
+		return e.encodeSchemaMap(v, depth)
+	case []*Schema:
+		return e.encodeSchemaSlice(v, depth)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if e.indent == "" || len(data) < 2 || (data[0] != '{' && data[0] != '[') {
+		e.w.Write(data)
+		return nil
+	}
+	e.scratch.Reset()
+	if err := json.Indent(&e.scratch, data, strings.Repeat(e.indent, depth), e.indent); err != nil {
+		return err
+	}
+	e.w.Write(e.scratch.Bytes())
+	return nil
+}
+
+func (e *schemaEncoder) encodeSchemaMap(schemas map[string]*Schema, depth int) error {
+	if schemas == nil {
+		e.w.WriteString("null")
+		return nil
+	}
+	if len(schemas) == 0 {
+		e.w.WriteString("{}")
+		return nil
+	}
+
+	keys := make([]string, 0, len(schemas))
+	for key := range schemas {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	e.w.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		e.writeKey(key)
+		if err := e.encodeSchema(schemas[key], depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.w.WriteByte('}')
+	return nil
+}
+
+func (e *schemaEncoder) encodeSchemaSlice(schemas []*Schema, depth int) error {
+	if schemas == nil {
+		e.w.WriteString("null")
+		return nil
+	}
+	if len(schemas) == 0 {
+		e.w.WriteString("[]")
+		return nil
+	}
+
+	e.w.WriteByte('[')
+	for i, s := range schemas {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		if err := e.encodeSchema(s, depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.w.WriteByte(']')
+	return nil
+}
+
+// newline starts a new line with the indentation of depth, if the output is indented
+func (e *schemaEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.w.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		e.w.WriteString(e.indent)
+	}
+}
+
+// writeKey writes the quoted key of an object entry, followed by the colon
+func (e *schemaEncoder) writeKey(key string) {
+	if isPlainString(key) {
+		e.w.WriteByte('"')
+		e.w.WriteString(key)
+		e.w.WriteByte('"')
+	} else {
+		quoted, _ := json.Marshal(key)
+		e.w.Write(quoted)
+	}
+	e.w.WriteByte(':')
+	if e.indent != "" {
+		e.w.WriteByte(' ')
+	}
+}
+
+// isPlainString checks if the string can be quoted without escaping any characters
+func isPlainString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyValue matches the omitempty rules of encoding/json
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteJSON(t *testing.T) {
+	minimum := 1
+	schema := &Schema{
+		Type:     StringOrArrayOfString{"object"},
+		Required: NewBoolOrArrayOfString([]string{"a"}, false),
+		Properties: map[string]*Schema{
+			"b": {
+				Type:        StringOrArrayOfString{"string"},
+				Required:    NewBoolOrArrayOfString([]string{}, false),
+				Description: "<b> & co",
+				Default:     map[string]interface{}{"z": 1, "a": []interface{}{}},
+			},
+			"a": {
+				Type:     StringOrArrayOfString{"integer", "null"},
+				Minimum:  &minimum,
+				Examples: []interface{}{1, 2},
+			},
+			"c": {Const: nil, constWasSet: true},
+		},
+		AdditionalProperties: Schema{Type: StringOrArrayOfString{"string"}, Title: "value"},
+		AllOf:                []*Schema{{Ref: "#/$defs/x"}, {}},
+		Variants:             []*Schema{{Title: "ignored"}},
+		HasData:              true,
+		CustomAnnotations:    map[string]interface{}{"x-b": true, "x-a": map[string]interface{}{"k": "v"}},
+	}
+
+	data, err := schema.ToJson()
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "additionalProperties": {
+    "title": "value",
+    "type": "string"
+  },
+  "allOf": [
+    {
+      "$ref": "#/$defs/x",
+      "required": []
+    },
+    {
+      "required": []
+    }
+  ],
+  "properties": {
+    "a": {
+      "examples": [
+        1,
+        2
+      ],
+      "minimum": 1,
+      "required": [],
+      "type": [
+        "integer",
+        "null"
+      ]
+    },
+    "b": {
+      "default": {
+        "a": [],
+        "z": 1
+      },
+      "description": "\u003cb\u003e \u0026 co",
+      "type": "string"
+    },
+    "c": {
+      "const": null,
+      "required": []
+    }
+  },
+  "required": [
+    "a"
+  ],
+  "type": "object",
+  "x-a": {
+    "k": "v"
+  },
+  "x-b": true
+}`, string(data))
+}
+
+func TestWriteJSONMatchesEncodingJSON(t *testing.T) {
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(largeValues(20)), &node))
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, false, config, nil, nil)
+
+	compact, err := schema.MarshalJSON()
+	assert.NoError(t, err)
+	var generic interface{}
+	assert.NoError(t, json.Unmarshal(compact, &generic))
+
+	expected, err := json.MarshalIndent(generic, "", "  ")
+	assert.NoError(t, err)
+	indented, err := schema.ToJson()
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(indented))
+
+	expectedCompact, err := json.Marshal(generic)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expectedCompact), string(compact))
+}
+
+// largeValues returns a values file with n annotated sections of nested keys
+func largeValues(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `# @schema
+# type: object
+# x-section: section%d
+# @schema
+# -- Section %d
+section%d:
+  enabled: true
+  # @schema
+  # enum: [a, b, c]
+  # @schema
+  mode: a
+  replicas: %d
+  image:
+    repository: "example.com/app<%d>"
+    tag: latest
+  ports:
+    - name: http
+      port: 80
+  annotations: {}
+`, i, i, i, i, i)
+	}
+	return b.String()
+}
+
+func benchmarkSchema(b *testing.B) *Schema {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(largeValues(1000)), &node); err != nil {
+		b.Fatal(err)
+	}
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return YamlToSchema("", &node, false, false, false, false, config, nil, nil)
+}
+
+func BenchmarkToJson(b *testing.B) {
+	schema := benchmarkSchema(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := schema.ToJson(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	schema := benchmarkSchema(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(schema); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// EqualsOptions defines which fields are considered when comparing schemas
+type EqualsOptions struct {
+	// IgnoreDescriptive ignores the fields which only describe a value (title, description and examples)
+	IgnoreDescriptive bool
+	// IgnoreCustomAnnotations ignores all custom annotations (x-*)
+	IgnoreCustomAnnotations bool
+}
+
+// Predefined field sets for EqualsOpt
+var (
+	// EqualsFull compares all fields
+	EqualsFull = EqualsOptions{}
+	// EqualsStructural only compares the fields which affect the validation
+	EqualsStructural = EqualsOptions{IgnoreDescriptive: true, IgnoreCustomAnnotations: true}
+	// EqualsIgnoreCustomAnnotations compares all fields except custom annotations
+	EqualsIgnoreCustomAnnotations = EqualsOptions{IgnoreCustomAnnotations: true}
+)
+
+// descriptiveKeywords are ignored if EqualsOptions.IgnoreDescriptive is set
+var descriptiveKeywords = []string{"title", "description", "examples"}
+
+// Keywords containing subschemas
+var (
+	schemaKeywords      = []string{"items", "if", "then", "else", "not", "additionalProperties", "contentSchema", "propertyNames"}
+	schemaMapKeywords   = []string{"properties", "patternProperties", "$defs", "definitions"}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "oneOf"}
+)
+
+// Equals checks if both schemas are equal, ignoring the descriptive fields
+// (title, description and examples)
+func (s *Schema) Equals(other *Schema) bool {
+	return s.EqualsOpt(other, EqualsOptions{IgnoreDescriptive: true})
+}
+
+// EqualsOpt checks if both schemas are equal, considering only the fields selected by opts.
+// Both schemas are compared as they would be written to the json file.
+func (s *Schema) EqualsOpt(other *Schema, opts EqualsOptions) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+
+	a, err := comparableSchema(s, opts)
+	if err != nil {
+		return false
+	}
+	b, err := comparableSchema(other, opts)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// comparableSchema converts the schema to its generic json representation
+// and removes all fields ignored by opts
+func comparableSchema(s *Schema, opts EqualsOptions) (interface{}, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+
+	stripIgnoredFields(generic, opts)
+	return generic, nil
+}
+
+// stripIgnoredFields removes the ignored fields from the generic json schema and its subschemas
+func stripIgnoredFields(generic interface{}, opts EqualsOptions) {
+	schema, ok := generic.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for key := range schema {
+		if opts.IgnoreCustomAnnotations && strings.HasPrefix(key, CustomAnnotationPrefix) {
+			delete(schema, key)
+		}
+	}
+	if opts.IgnoreDescriptive {
+		for _, key := range descriptiveKeywords {
+			delete(schema, key)
+		}
+	}
+
+	for _, key := range schemaKeywords {
+		stripIgnoredFields(schema[key], opts)
+	}
+	for _, key := range schemaMapKeywords {
+		if subSchemas, ok := schema[key].(map[string]interface{}); ok {
+			for _, subSchema := range subSchemas {
+				stripIgnoredFields(subSchema, opts)
+			}
+		}
+	}
+	for _, key := range schemaArrayKeywords {
+		if subSchemas, ok := schema[key].([]interface{}); ok {
+			for _, subSchema := range subSchemas {
+				stripIgnoredFields(subSchema, opts)
+			}
+		}
+	}
+}
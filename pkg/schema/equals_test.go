@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqualsOpt(t *testing.T) {
+	base := func() *Schema {
+		return &Schema{
+			Type:  StringOrArrayOfString{"object"},
+			Title: "root",
+			Properties: map[string]*Schema{
+				"title": {
+					Type:        StringOrArrayOfString{"string"},
+					Title:       "title",
+					Description: "The title",
+				},
+			},
+			Items: &Schema{
+				AnyOf: []*Schema{{Type: StringOrArrayOfString{"string"}, Description: "item"}},
+			},
+			CustomAnnotations: map[string]interface{}{"x-foo": "bar"},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(s *Schema)
+		full       bool
+		structural bool
+		ignoreX    bool
+		equals     bool
+	}{
+		{
+			name:       "identical",
+			modify:     func(s *Schema) {},
+			full:       true,
+			structural: true,
+			ignoreX:    true,
+			equals:     true,
+		},
+		{
+			name:       "nested description",
+			modify:     func(s *Schema) { s.Properties["title"].Description = "changed" },
+			full:       false,
+			structural: true,
+			ignoreX:    false,
+			equals:     true,
+		},
+		{
+			name:       "item description",
+			modify:     func(s *Schema) { s.Items.AnyOf[0].Description = "changed" },
+			full:       false,
+			structural: true,
+			ignoreX:    false,
+			equals:     true,
+		},
+		{
+			name:       "custom annotation",
+			modify:     func(s *Schema) { s.CustomAnnotations["x-foo"] = "baz" },
+			full:       false,
+			structural: true,
+			ignoreX:    true,
+			equals:     false,
+		},
+		{
+			name:       "type",
+			modify:     func(s *Schema) { s.Properties["title"].Type = StringOrArrayOfString{"integer"} },
+			full:       false,
+			structural: false,
+			ignoreX:    false,
+			equals:     false,
+		},
+		{
+			name:       "property named like a descriptive keyword",
+			modify:     func(s *Schema) { delete(s.Properties, "title") },
+			full:       false,
+			structural: false,
+			ignoreX:    false,
+			equals:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := base()
+			tt.modify(other)
+
+			assert.Equal(t, tt.full, base().EqualsOpt(other, EqualsFull))
+			assert.Equal(t, tt.structural, base().EqualsOpt(other, EqualsStructural))
+			assert.Equal(t, tt.ignoreX, base().EqualsOpt(other, EqualsIgnoreCustomAnnotations))
+			assert.Equal(t, tt.equals, base().Equals(other))
+		})
+	}
+}
+
+func TestEqualsNil(t *testing.T) {
+	var s *Schema
+	assert.True(t, s.Equals(nil))
+	assert.False(t, s.Equals(&Schema{}))
+	assert.False(t, (&Schema{}).Equals(nil))
+}
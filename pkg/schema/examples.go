@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExamplePrefix marks a line of the comment of a key as example of its value, e.g. # @example 8080.
+// Each line adds one example, which is parsed like a yaml value.
+const ExamplePrefix = "# @example"
+
+// exampleFromLine returns the example of the comment line, if it is marked with ExamplePrefix
+func exampleFromLine(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, ExamplePrefix)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// withoutExamples removes the example lines of the comment lines
+func withoutExamples(lines []string) []string {
+	return slices.DeleteFunc(lines, func(line string) bool {
+		_, ok := exampleFromLine(line)
+		return ok
+	})
+}
+
+// addCommentExamples adds the examples of the comment to the examples of the schema.
+// They are added once the type is known, so they can be typed accordingly.
+// They are dropped if the examples are skipped.
+func (s *Schema) addCommentExamples(skip SkipAutoGenerationFields) {
+	if skip.Examples {
+		s.commentExamples = nil
+		return
+	}
+	for _, raw := range s.commentExamples {
+		s.Examples = append(s.Examples, typedExample(raw, s.Type))
+	}
+	s.commentExamples = nil
+}
+
+// typedExample parses the example like a yaml value. If the type doesn't allow the parsed value,
+// but strings, the example is kept as it is (e.g. 1.20 as image tag or true as string flag).
+func typedExample(raw string, types StringOrArrayOfString) interface{} {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+		return raw
+	}
+
+	switch value.(type) {
+	case string:
+		return value
+	case time.Time:
+		// timestamps are strings in json
+		return raw
+	}
+
+	valueType := jsonTypeOf(value)
+	allowed := types.IsEmpty() || slices.Contains(types, valueType) || (valueType == "integer" && slices.Contains(types, "number"))
+	if !allowed && slices.Contains(types, "string") {
+		return raw
+	}
+	return value
+}
+
+// jsonTypeOf returns the json schema type of the parsed yaml value
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "string"
+}
@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Formats of the export command
+const (
+	// ExportFormatGo is a go file with the schema as a string constant
+	ExportFormatGo = "go"
+	// ExportFormatGzip is the gzip-compressed schema
+	ExportFormatGzip = "gzip"
+)
+
+// ExportFormats are all supported export formats
+var ExportFormats = []string{ExportFormatGo, ExportFormatGzip}
+
+// Compress returns the gzip-compressed schema. The output only depends on
+// the schema (no timestamp or file name in the header), so it can be committed.
+func Compress(jsonStr []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(jsonStr); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GoConstName returns the name of the constant containing the schema of the chart,
+// e.g. MyChartSchema for my-chart
+func GoConstName(chartName string) string {
+	var name strings.Builder
+	for _, part := range strings.FieldsFunc(chartName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(part)
+		name.WriteRune(unicode.ToUpper(runes[0]))
+		name.WriteString(string(runes[1:]))
+	}
+	if name.Len() == 0 || !unicode.IsLetter([]rune(name.String())[0]) {
+		return "Chart" + name.String() + "Schema"
+	}
+	return name.String() + "Schema"
+}
+
+// GoFile returns the source of a go file in the given package, which contains
+// the schema of the chart as an exported string constant (see GoConstName)
+func GoFile(jsonStr []byte, packageName, chartName string) ([]byte, error) {
+	if !token.IsIdentifier(packageName) {
+		return nil, fmt.Errorf("invalid package name %q", packageName)
+	}
+
+	literal := "`" + string(jsonStr) + "`"
+	if bytes.ContainsAny(jsonStr, "`\r") {
+		literal = strconv.Quote(string(jsonStr))
+	}
+
+	constName := GoConstName(chartName)
+	src := fmt.Sprintf(`// Code generated by helm-schema. DO NOT EDIT.
+
+package %s
+
+// %s is the jsonschema of the values of the chart %s
+const %s = %s
+`, packageName, constName, chartName, constName, literal)
+
+	return format.Source([]byte(src))
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	jsonStr := []byte(`{"type": "object"}`)
+
+	compressed, err := Compress(jsonStr)
+	assert.NoError(t, err)
+
+	again, err := Compress(jsonStr)
+	assert.NoError(t, err)
+	assert.Equal(t, compressed, again, "compression must be deterministic")
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	assert.NoError(t, err)
+	decompressed, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonStr, decompressed)
+}
+
+func TestGoConstName(t *testing.T) {
+	tests := map[string]string{
+		"my-chart":  "MyChartSchema",
+		"nginx":     "NginxSchema",
+		"cert_mgr2": "CertMgr2Schema",
+		"3scale":    "Chart3scaleSchema",
+		"---":       "ChartSchema",
+	}
+	for chartName, expected := range tests {
+		assert.Equal(t, expected, GoConstName(chartName), chartName)
+	}
+}
+
+func TestGoFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		jsonStr string
+	}{
+		{name: "raw string", jsonStr: "{\n  \"description\": \"a \\\"quoted\\\" line\\nbreak\"\n}"},
+		{name: "backtick", jsonStr: "{\n  \"description\": \"use `helm install`\"\n}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := GoFile([]byte(test.jsonStr), "schemas", "my-chart")
+			assert.NoError(t, err)
+
+			file, err := parser.ParseFile(token.NewFileSet(), "my-chart_schema.go", src, parser.ParseComments)
+			assert.NoError(t, err)
+			assert.Equal(t, "schemas", file.Name.Name)
+
+			spec := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+			assert.Equal(t, "MyChartSchema", spec.Names[0].Name)
+			value, err := strconv.Unquote(spec.Values[0].(*ast.BasicLit).Value)
+			assert.NoError(t, err)
+			assert.Equal(t, test.jsonStr, value)
+		})
+	}
+
+	_, err := GoFile([]byte("{}"), "my-schemas", "my-chart")
+	assert.Error(t, err)
+}
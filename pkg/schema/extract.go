@@ -0,0 +1,551 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/gitref"
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/registry"
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+// errRefNotLoaded is returned by the loaders of documents, which are referenced by url
+// (e.g. https://...) and resolved by the validator instead
+var errRefNotLoaded = errors.New("the document is not loaded")
+
+// refDocument is a schema document subschemas are extracted from
+type refDocument struct {
+	// uri is the uri the document was loaded from
+	uri string
+	// name prefixes the names of the copied targets which aren't definitions, it's empty for
+	// the referenced document
+	name    string
+	content interface{}
+}
+
+// refLocation is a subschema of a document
+type refLocation struct {
+	doc     *refDocument
+	pointer string
+}
+
+// refExtractor extracts subschemas of an external schema document. The references of an
+// extracted subschema point into its document or into other documents, so their targets are
+// copied (transitively) to the definitions, which are merged into the generated schema:
+// definitions keep their names, other targets (e.g. #/properties/foo) are named after their
+// pointer (e.g. properties-foo) and their document (e.g. port-properties-foo for a target of
+// port.json). The references are rewritten to the copied definitions.
+//
+// References are resolved relative to the base uri of the subschema containing them (RFC 3986),
+// which is the uri of its document or the $id of the subschema or of one of its parents. So
+// chains of files, git and registry references work and so do the $ids of modular schemas.
+// Fragments are json pointers or anchors ($anchor, or $id: "#name" of older drafts).
+// Documents which are referenced by url (e.g. https://...) are not loaded, unless a loaded
+// document has this url as $id. Their references are made absolute instead.
+type refExtractor struct {
+	root *refDocument
+	// keyword is the keyword of the definitions the copied targets, which aren't definitions, are referenced with
+	keyword string
+	// defs contains the copied definitions
+	defs map[string]interface{}
+	// names maps the uris (with pointer) of the copied targets to their definition names
+	names map[string]string
+	// resources contains the subschemas by their uri (the uri their document was loaded from or their $id)
+	resources map[string]refLocation
+	// anchors contains the subschemas by their uri with the anchor as fragment
+	anchors map[string]refLocation
+	// load returns the content of the document at the uri (without fragment) or errRefNotLoaded
+	load func(uri string) ([]byte, error)
+}
+
+func newRefExtractor(document []byte, uri string, load func(uri string) ([]byte, error)) (*refExtractor, error) {
+	e := &refExtractor{
+		keyword:   "$defs",
+		defs:      map[string]interface{}{},
+		names:     map[string]string{},
+		resources: map[string]refLocation{},
+		anchors:   map[string]refLocation{},
+		load:      load,
+	}
+	root, err := e.addDocument(document, uri, "")
+	if err != nil {
+		return nil, err
+	}
+	e.root = root
+
+	// documents of older drafts only use definitions
+	if content, ok := root.content.(map[string]interface{}); ok {
+		_, hasDefs := content["$defs"]
+		_, hasDefinitions := content["definitions"]
+		if hasDefinitions && !hasDefs {
+			e.keyword = "definitions"
+		}
+	}
+	return e, nil
+}
+
+// addDocument parses the document loaded from the uri and adds its resources and anchors
+func (e *refExtractor) addDocument(document []byte, uri, name string) (*refDocument, error) {
+	doc := &refDocument{uri: uri, name: name}
+	if err := json.Unmarshal(document, &doc.content); err != nil {
+		return nil, err
+	}
+	e.resources[uri] = refLocation{doc: doc}
+	if err := e.index(doc, doc.content, "", uri, false); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// index adds the subschemas with $id or $anchor of the value at the pointer of the document
+func (e *refExtractor) index(doc *refDocument, value interface{}, pointer, base string, data bool) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if !data {
+			var err error
+			var anchors []string
+			base, anchors, err = schemaBase(base, v)
+			if err != nil {
+				return err
+			}
+			location := refLocation{doc: doc, pointer: pointer}
+			if _, ok := v["$id"].(string); ok {
+				if _, exists := e.resources[base]; !exists {
+					e.resources[base] = location
+				}
+			}
+			for _, anchor := range anchors {
+				e.anchors[base+"#"+anchor] = location
+			}
+		}
+		// sorted, so the names of the copied definitions don't depend on the order of the map
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			sub := v[key]
+			subData := data || isDataKeyword(key)
+			if schemas, ok := sub.(map[string]interface{}); ok && !data && isSchemaMapKeyword(key) {
+				for _, name := range slices.Sorted(maps.Keys(schemas)) {
+					schema := schemas[name]
+					if err := e.index(doc, schema, pointer+"/"+key+"/"+escapePointerSegment(name), base, false); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := e.index(doc, sub, pointer+"/"+escapePointerSegment(key), base, subData); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := e.index(doc, item, fmt.Sprintf("%s/%d", pointer, i), base, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaBase returns the base uri of the subschema, which is changed by its $id, and its anchors
+func schemaBase(base string, schema map[string]interface{}) (string, []string, error) {
+	var anchors []string
+	if anchor, ok := schema["$anchor"].(string); ok && anchor != "" {
+		anchors = append(anchors, anchor)
+	}
+	id, ok := schema["$id"].(string)
+	if !ok || id == "" {
+		return base, anchors, nil
+	}
+	resolved, err := resolveRefURI(base, id)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid $id %s: %w", id, err)
+	}
+	uri, fragment, _ := strings.Cut(resolved, "#")
+	// older drafts declare anchors with $id: "#name"
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		anchors = append(anchors, fragment)
+	}
+	return uri, anchors, nil
+}
+
+// baseAt returns the base uri the subschema at the pointer of the document is located in, which
+// is changed by the $ids of its parents. The $id of the subschema itself is not resolved, so it
+// is resolved exactly once by the caller (e.g. rewrite).
+func baseAt(doc *refDocument, pointer string) (string, error) {
+	base := doc.uri
+	current := doc.content
+	segments := []string{}
+	if pointer != "" && pointer != "/" {
+		segments = strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	}
+	for _, segment := range segments {
+		if schema, ok := current.(map[string]interface{}); ok {
+			var err error
+			if base, _, err = schemaBase(base, schema); err != nil {
+				return "", err
+			}
+		}
+		next, err := lookupJSONPointer(current, "/"+segment)
+		if err != nil {
+			return "", err
+		}
+		current = next
+	}
+	return base, nil
+}
+
+// copyDefinitions copies all definitions of the root of the referenced document, so they can
+// be used by the generated schema like the ones which are referenced
+func (e *refExtractor) copyDefinitions() error {
+	content, ok := e.root.content.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for _, keyword := range []string{"$defs", "definitions"} {
+		defs, _ := content[keyword].(map[string]interface{})
+		for _, name := range slices.Sorted(maps.Keys(defs)) {
+			if _, err := e.copy(e.root, "/"+keyword+"/"+escapePointerSegment(name), name); err != nil {
+				return fmt.Errorf("invalid definition %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// pointer returns the json pointer of the fragment (a json pointer or an anchor) within the referenced document
+func (e *refExtractor) pointer(fragment string) (string, error) {
+	if fragment == "" || strings.HasPrefix(fragment, "/") {
+		return fragment, nil
+	}
+	base := e.root.uri
+	if content, ok := e.root.content.(map[string]interface{}); ok {
+		var err error
+		if base, _, err = schemaBase(base, content); err != nil {
+			return "", err
+		}
+	}
+	location, ok := e.anchors[base+"#"+fragment]
+	if !ok || location.doc != e.root {
+		return "", fmt.Errorf("could not find the anchor %s", fragment)
+	}
+	return location.pointer, nil
+}
+
+// extract returns the subschema at the pointer of the referenced document with rewritten references
+func (e *refExtractor) extract(pointer string) (interface{}, error) {
+	target, err := e.target(e.root, pointer)
+	if err != nil {
+		return nil, err
+	}
+	base, err := baseAt(e.root, pointer)
+	if err != nil {
+		return nil, err
+	}
+	return e.rewrite(e.root, base, target, false)
+}
+
+// ref returns the rewritten reference to the pointer of the document
+func (e *refExtractor) ref(doc *refDocument, pointer string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	// references into definitions keep the path within the definition
+	if len(segments) >= 2 && (segments[0] == "$defs" || segments[0] == "definitions") {
+		name, err := e.copy(doc, "/"+segments[0]+"/"+segments[1], unescapePointerSegment(segments[1]))
+		if err != nil {
+			return "", err
+		}
+		return "#/" + segments[0] + "/" + strings.Join(append([]string{escapePointerSegment(name)}, segments[2:]...), "/"), nil
+	}
+
+	var names []string
+	if doc.name != "" {
+		names = append(names, doc.name)
+	}
+	if pointer != "" && pointer != "/" {
+		for _, segment := range segments {
+			names = append(names, unescapePointerSegment(segment))
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"root"}
+	}
+	name, err := e.copy(doc, pointer, strings.Join(names, "-"))
+	if err != nil {
+		return "", err
+	}
+	return "#/" + e.keyword + "/" + escapePointerSegment(name), nil
+}
+
+// resolveRef returns the rewritten reference of a subschema of the document with the base uri.
+// Only local documents may reference local files, otherwise a downloaded schema could copy any
+// json file of the machine into the generated schema.
+func (e *refExtractor) resolveRef(doc *refDocument, base, ref string) (string, error) {
+	target, err := resolveRefURI(base, ref)
+	if err != nil {
+		return "", err
+	}
+	if util.IsFileURL(target) && !util.IsFileURL(doc.uri) {
+		return "", fmt.Errorf("the local file %s can't be referenced by %s, which is not a local file", target, doc.uri)
+	}
+	uri, fragment, _ := strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+
+	resource, ok := e.resources[uri]
+	if !ok {
+		content, err := e.load(uri)
+		if errors.Is(err, errRefNotLoaded) {
+			return target, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err = e.addDocument(content, uri, documentName(uri)); err != nil {
+			return "", fmt.Errorf("could not parse %s: %w", uri, err)
+		}
+		resource = e.resources[uri]
+	}
+
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		anchor, ok := e.anchors[uri+"#"+fragment]
+		if !ok {
+			return "", fmt.Errorf("could not find the anchor %s in %s", fragment, uri)
+		}
+		return e.ref(anchor.doc, anchor.pointer)
+	}
+	return e.ref(resource.doc, resource.pointer+fragment)
+}
+
+// copy copies the target of the pointer to the definitions and returns its name, which is
+// the given name or, if another target has this name already, the name with a suffix
+func (e *refExtractor) copy(doc *refDocument, pointer, name string) (string, error) {
+	key := doc.uri + "#" + pointer
+	if existing, ok := e.names[key]; ok {
+		return existing, nil
+	}
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := e.defs[unique]; !ok {
+			break
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	// the name is reserved before the target is rewritten, so cyclic references end here
+	e.names[key] = unique
+	e.defs[unique] = nil
+
+	target, err := e.target(doc, pointer)
+	if err != nil {
+		return "", err
+	}
+	base, err := baseAt(doc, pointer)
+	if err != nil {
+		return "", err
+	}
+	rewritten, err := e.rewrite(doc, base, target, false)
+	if err != nil {
+		return "", err
+	}
+	e.defs[unique] = rewritten
+	return unique, nil
+}
+
+// target returns a copy of the value at the pointer of the document. The definitions of the
+// root are removed, because the used ones are copied to the definitions of the generated schema.
+func (e *refExtractor) target(doc *refDocument, pointer string) (interface{}, error) {
+	target, err := lookupJSONPointer(doc.content, pointer)
+	if err != nil {
+		return nil, err
+	}
+	target = deepCopyJSON(target)
+	if content, ok := target.(map[string]interface{}); ok && (pointer == "" || pointer == "/") {
+		for _, keyword := range []string{"$defs", "definitions", "$schema"} {
+			delete(content, keyword)
+		}
+	}
+	return target, nil
+}
+
+// rewrite rewrites the references of the schema, which is located in the base uri (see baseAt).
+// The $ids are removed, because they would change the base uri of the rewritten references in
+// the generated schema, the anchors are kept. The values of data keywords (e.g. default) are kept as they are.
+func (e *refExtractor) rewrite(doc *refDocument, base string, value interface{}, data bool) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if !data {
+			var err error
+			if base, _, err = schemaBase(base, v); err != nil {
+				return nil, err
+			}
+			delete(v, "$id")
+			if ref, ok := v["$ref"].(string); ok {
+				rewritten, err := e.resolveRef(doc, base, ref)
+				if err != nil {
+					return nil, fmt.Errorf("could not resolve $ref %s: %w", ref, err)
+				}
+				v["$ref"] = rewritten
+			}
+		}
+		// sorted, so the names of the copied definitions don't depend on the order of the map
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			sub := v[key]
+			subData := data || isDataKeyword(key)
+			if schemas, ok := sub.(map[string]interface{}); ok && !data && isSchemaMapKeyword(key) {
+				for _, name := range slices.Sorted(maps.Keys(schemas)) {
+					schema := schemas[name]
+					rewritten, err := e.rewrite(doc, base, schema, false)
+					if err != nil {
+						return nil, err
+					}
+					schemas[name] = rewritten
+				}
+				continue
+			}
+			rewritten, err := e.rewrite(doc, base, sub, subData)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rewritten
+		}
+	case []interface{}:
+		for i, item := range v {
+			rewritten, err := e.rewrite(doc, base, item, data)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rewritten
+		}
+	}
+	return value, nil
+}
+
+// isDataKeyword checks if the values of the keyword are data instead of schemas
+func isDataKeyword(keyword string) bool {
+	switch keyword {
+	case "default", "const", "enum", "examples":
+		return true
+	}
+	return false
+}
+
+// isSchemaMapKeyword checks if the keys of the keyword are names (e.g. of a property named default)
+func isSchemaMapKeyword(keyword string) bool {
+	switch keyword {
+	case "properties", "patternProperties", "$defs", "definitions", "dependentSchemas":
+		return true
+	}
+	return false
+}
+
+// resolveRefURI resolves the reference relative to the base uri of the document containing it
+// (RFC 3986). Git references are resolved within their repository and revision, relative
+// references can't be resolved against schema registry references.
+func resolveRefURI(base, ref string) (string, error) {
+	if gitref.IsRef(ref) || registry.IsRef(ref) {
+		return ref, nil
+	}
+	// like in the annotations, backslashes can be used as separator of relative files
+	if !strings.Contains(ref, "://") {
+		ref = strings.ReplaceAll(ref, `\`, "/")
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if refURL.IsAbs() {
+		return ref, nil
+	}
+
+	switch {
+	case gitref.IsRef(base):
+		parsed, err := gitref.ParseRef(strings.TrimSuffix(base, "#"))
+		if err != nil {
+			return "", err
+		}
+		if refURL.Path != "" {
+			refPath := path.Join(path.Dir(parsed.Path), refURL.Path)
+			if strings.HasPrefix(refURL.Path, "/") {
+				refPath = path.Clean(strings.TrimPrefix(refURL.Path, "/"))
+			}
+			if !filepath.IsLocal(filepath.FromSlash(refPath)) {
+				return "", fmt.Errorf("%s is outside of the repository of %s", ref, base)
+			}
+			parsed.Path = refPath
+		}
+		resolved := parsed.String()
+		if refURL.Fragment != "" || strings.HasSuffix(ref, "#") {
+			resolved += "#" + refURL.Fragment
+		}
+		return resolved, nil
+	case registry.IsRef(base):
+		if refURL.Path != "" {
+			return "", fmt.Errorf("the relative reference %s can't be resolved against the schema registry reference %s", ref, base)
+		}
+		return strings.TrimSuffix(base, "#") + "#" + refURL.Fragment, nil
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+// refDocumentLoader returns the loader of the documents of file, git or schema registry references,
+// documents of other urls are not loaded (errRefNotLoaded). The downloads are canceled with ctx.
+func refDocumentLoader(ctx context.Context, log logging.Logger) func(uri string) ([]byte, error) {
+	return func(uri string) ([]byte, error) {
+		switch {
+		case util.IsFileURL(uri):
+			p, err := util.FileURLToPath(uri)
+			if err != nil {
+				return nil, err
+			}
+			return os.ReadFile(p)
+		case gitref.IsRef(uri):
+			return fetchGitRef(ctx, uri, log)
+		case registry.IsRef(uri):
+			return pullRegistryRef(ctx, uri, log)
+		}
+		return nil, errRefNotLoaded
+	}
+}
+
+// documentName returns the name of the document at the uri used in the names of its copied
+// targets, e.g. port for types/port.json
+func documentName(uri string) string {
+	if registry.IsRef(uri) {
+		chart, _, _ := registry.ParseRef(uri)
+		return chart
+	}
+	name := path.Base(uri)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// deepCopyJSON copies the maps and lists of a parsed json value
+func deepCopyJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, sub := range v {
+			copied[key] = deepCopyJSON(sub)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyJSON(item)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,284 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRefExtractor(t *testing.T) {
+	document := []byte(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$defs": {"port": {"type": "integer", "default": {"$ref": "#/kept"}}},
+  "properties": {
+    "common": {"type": "object", "properties": {"default": {"$ref": "#/$defs/port"}}},
+    "service": {
+      "type": "object",
+      "properties": {
+        "ports": {"type": "array", "items": {"$ref": "#/$defs/port/not"}},
+        "labels": {"$ref": "#/properties/common"},
+        "parent": {"$ref": "#/properties/service"}
+      }
+    }
+  }
+}`)
+
+	extractor, err := newRefExtractor(document, "file:///schemas/common.json", refDocumentLoader(context.Background(), logger))
+	assert.NoError(t, err)
+	target, err := extractor.extract("/properties/service")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ports":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/$defs/port/not"}},
+			"labels": map[string]interface{}{"$ref": "#/$defs/properties-common"},
+			"parent": map[string]interface{}{"$ref": "#/$defs/properties-service"},
+		},
+	}, target)
+	assert.ElementsMatch(t, []string{"port", "properties-common", "properties-service"}, slices.Collect(maps.Keys(extractor.defs)))
+	// the data of defaults isn't a reference
+	assert.Equal(t, map[string]interface{}{"$ref": "#/kept"}, extractor.defs["port"].(map[string]interface{})["default"])
+	assert.Equal(t,
+		map[string]interface{}{"$ref": "#/$defs/port"},
+		extractor.defs["properties-common"].(map[string]interface{})["properties"].(map[string]interface{})["default"],
+	)
+
+	ref, err := extractor.ref(extractor.root, "/definitions/missing")
+	assert.Error(t, err, ref)
+
+	// the definitions of the root are copied instead of inlined
+	target, err = extractor.extract("")
+	assert.NoError(t, err)
+	assert.NotContains(t, target, "$defs")
+	assert.NotContains(t, target, "$schema")
+}
+
+func TestRefExtractorDocuments(t *testing.T) {
+	documents := map[string]string{
+		"file:///schemas/types/port.json": `{"$defs": {"port": {"type": "integer", "maximum": 65535}}, "type": "object", "properties": {"port": {"$ref": "#/$defs/port"}}}`,
+		"file:///schemas/types/name.json": `{"type": "string", "allOf": [{"$ref": "../common.json#/properties/service"}]}`,
+	}
+	load := func(uri string) ([]byte, error) {
+		if content, ok := documents[uri]; ok {
+			return []byte(content), nil
+		}
+		if util.IsFileURL(uri) {
+			return nil, errors.New("not found")
+		}
+		return nil, errRefNotLoaded
+	}
+
+	extractor, err := newRefExtractor([]byte(`{
+  "properties": {
+    "service": {
+      "properties": {
+        "port": {"$ref": "./types/port.json#/$defs/port"},
+        "listener": {"$ref": "types/port.json"},
+        "name": {"$ref": "types\\name.json"},
+        "labels": {"$ref": "https://example.com/labels.json#/$defs/labels"}
+      }
+    }
+  }
+}`), "file:///schemas/common.json", load)
+	assert.NoError(t, err)
+
+	target, err := extractor.extract("/properties/service")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"port":     map[string]interface{}{"$ref": "#/$defs/port-2"},
+		"listener": map[string]interface{}{"$ref": "#/$defs/port"},
+		"name":     map[string]interface{}{"$ref": "#/$defs/name"},
+		"labels":   map[string]interface{}{"$ref": "https://example.com/labels.json#/$defs/labels"},
+	}, target.(map[string]interface{})["properties"])
+
+	// the listener is the whole port.json, whose definition is shared with the port. The
+	// properties are rewritten in sorted order, so the listener is copied first.
+	assert.Equal(t, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"port": map[string]interface{}{"$ref": "#/$defs/port-2"}},
+	}, extractor.defs["port"])
+	// name.json references the service of common.json again
+	assert.Equal(t, []interface{}{map[string]interface{}{"$ref": "#/$defs/properties-service"}}, extractor.defs["name"].(map[string]interface{})["allOf"])
+	assert.Contains(t, extractor.defs, "properties-service")
+
+	_, err = extractor.resolveRef(extractor.root, extractor.root.uri, "missing.json")
+	assert.EqualError(t, err, "not found")
+}
+
+func TestResolveRefURI(t *testing.T) {
+	tests := []struct {
+		base        string
+		ref         string
+		expected    string
+		expectedErr bool
+	}{
+		{base: "file:///schemas/common.json", ref: "types/port.json#/$defs/port", expected: "file:///schemas/types/port.json#/$defs/port"},
+		{base: "file:///schemas/common.json", ref: `..\shared\port.json`, expected: "file:///shared/port.json"},
+		{base: "file:///schemas/common.json", ref: "https://example.com/port.json", expected: "https://example.com/port.json"},
+		{base: "https://example.com/schemas/common.json", ref: "types/port.json", expected: "https://example.com/schemas/types/port.json"},
+		{base: "git+https://github.com/org/schemas.git@v1.2.3/common/schema.json", ref: "../types/port.json#/$defs/port", expected: "git+https://github.com/org/schemas.git@v1.2.3/types/port.json#/$defs/port"},
+		{base: "git+https://github.com/org/schemas.git@v1.2.3/common/schema.json", ref: "/port.json", expected: "git+https://github.com/org/schemas.git@v1.2.3/port.json"},
+		{base: "git+https://github.com/org/schemas.git@v1.2.3/common/schema.json", ref: "../../port.json", expectedErr: true},
+		{base: "registry://common@1.2.3", ref: "registry://other@1.0.0#/$defs/port", expected: "registry://other@1.0.0#/$defs/port"},
+		{base: "registry://common@1.2.3", ref: "port.json", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			resolved, err := resolveRefURI(tt.base, tt.ref)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, resolved)
+		})
+	}
+}
+
+func TestNestedFileRefs(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "schemas", "types"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "schemas", "common.json"), []byte(`{
+  "properties": {"service": {"type": "object", "properties": {"port": {"$ref": "./types/port.json"}}}}
+}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "schemas", "types", "port.json"), []byte(`{"type": "integer", "maximum": 65535}`), 0o644))
+
+	valuesPath := filepath.Join(dir, "values.yaml")
+	values := `# @schema
+# $ref: schemas/common.json#/properties/service
+# @schema
+service:
+  port: 80
+`
+	assert.NoError(t, os.WriteFile(valuesPath, []byte(values), 0o644))
+
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	s := YamlToSchema(valuesPath, &node, false, false, false, true, &SkipAutoGenerationConfig{}, nil, nil)
+
+	assert.Equal(t, "#/$defs/port", s.Properties["service"].Properties["port"].Ref)
+	assert.Equal(t, 65535, *s.Defs["port"].Maximum)
+	assert.NoError(t, s.ValidateInternalRefs())
+	assert.Error(t, s.ValidateValues([]byte("service:\n  port: 70000\n"), filepath.Join(dir, "values.schema.json")))
+}
+
+func TestRefExtractorIDsAndAnchors(t *testing.T) {
+	extractor, err := newRefExtractor([]byte(`{
+  "$id": "https://example.com/schemas/api.json",
+  "$defs": {
+    "port": {"$id": "types/port.json", "type": "integer", "maximum": 65535},
+    "name": {"$anchor": "name", "type": "string"},
+    "legacy": {"$id": "#legacy", "type": "boolean"},
+    "tls": {
+      "$id": "https://example.com/schemas/tls/tls.json",
+      "type": "object",
+      "properties": {"cert": {"$ref": "#/$defs/pem"}, "key": {"$ref": "key.json"}},
+      "$defs": {"pem": {"type": "string", "pattern": "^-----BEGIN"}}
+    }
+  },
+  "properties": {
+    "service": {
+      "properties": {
+        "port": {"$ref": "types/port.json"},
+        "name": {"$ref": "#name"},
+        "legacy": {"$ref": "api.json#legacy"},
+        "tls": {"$ref": "tls/tls.json"},
+        "labels": {"$ref": "labels.json"}
+      }
+    }
+  }
+}`), "file:///local/api.json", refDocumentLoader(context.Background(), logger))
+	assert.NoError(t, err)
+
+	target, err := extractor.extract("/properties/service")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"port":   map[string]interface{}{"$ref": "#/$defs/port"},
+		"name":   map[string]interface{}{"$ref": "#/$defs/name"},
+		"legacy": map[string]interface{}{"$ref": "#/$defs/legacy"},
+		"tls":    map[string]interface{}{"$ref": "#/$defs/tls"},
+		// documents of urls are not loaded, unless a loaded document has the url as $id
+		"labels": map[string]interface{}{"$ref": "https://example.com/schemas/labels.json"},
+	}, target.(map[string]interface{})["properties"])
+
+	// the refs of the nested resource are resolved against its $id, the $ids are removed
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"cert": map[string]interface{}{"$ref": "#/$defs/tls/$defs/pem"},
+			"key":  map[string]interface{}{"$ref": "https://example.com/schemas/tls/key.json"},
+		},
+		"$defs": map[string]interface{}{"pem": map[string]interface{}{"type": "string", "pattern": "^-----BEGIN"}},
+	}, extractor.defs["tls"])
+	assert.Equal(t, map[string]interface{}{"$anchor": "name", "type": "string"}, extractor.defs["name"])
+
+	pointer, err := extractor.pointer("name")
+	assert.NoError(t, err)
+	assert.Equal(t, "/$defs/name", pointer)
+	_, err = extractor.pointer("missing")
+	assert.Error(t, err)
+	_, err = extractor.resolveRef(extractor.root, "https://example.com/schemas/api.json", "#missing")
+	assert.Error(t, err)
+}
+
+func TestRefExtractorNestedRelativeIDs(t *testing.T) {
+	extractor, err := newRefExtractor([]byte(`{
+  "$id": "https://example.com/schemas/api.json",
+  "$defs": {
+    "tls": {
+      "$id": "tls/tls.json",
+      "$defs": {
+        "cert": {"$id": "certs/cert.json", "properties": {"pem": {"$ref": "pem.json"}, "key": {"$ref": "../key.json"}}}
+      }
+    }
+  },
+  "properties": {"cert": {"$ref": "tls/certs/cert.json"}}
+}`), "file:///local/api.json", refDocumentLoader(context.Background(), logger))
+	assert.NoError(t, err)
+
+	// each $id is resolved once against the base of its parent
+	expected := map[string]interface{}{
+		"pem": map[string]interface{}{"$ref": "https://example.com/schemas/tls/certs/pem.json"},
+		"key": map[string]interface{}{"$ref": "https://example.com/schemas/tls/key.json"},
+	}
+	target, err := extractor.extract("/$defs/tls/$defs/cert")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, target.(map[string]interface{})["properties"])
+
+	target, err = extractor.extract("/properties/cert")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"$ref": "#/$defs/tls/$defs/cert"}, target)
+	assert.Equal(t, expected, extractor.defs["tls"].(map[string]interface{})["$defs"].(map[string]interface{})["cert"].(map[string]interface{})["properties"])
+}
+
+func TestRefExtractorRefusesLocalFilesOfRemoteDocuments(t *testing.T) {
+	load := func(uri string) ([]byte, error) {
+		if util.IsFileURL(uri) {
+			t.Errorf("Was not expecting %s to be loaded", uri)
+		}
+		return nil, errRefNotLoaded
+	}
+
+	for _, uri := range []string{"https://example.com/schemas/api.json", "git+https://github.com/org/schemas.git@v1.2.3/api.json"} {
+		extractor, err := newRefExtractor([]byte(`{
+  "properties": {
+    "absolute": {"$ref": "file:///home/user/.docker/config.json"},
+    "relative": {"$id": "file:///home/user/", "properties": {"config": {"$ref": ".docker/config.json"}}}
+  }
+}`), uri, load)
+		assert.NoError(t, err)
+		_, err = extractor.extract("/properties/absolute")
+		assert.ErrorContains(t, err, "not a local file")
+		_, err = extractor.extract("/properties/relative")
+		assert.ErrorContains(t, err, "not a local file")
+	}
+}
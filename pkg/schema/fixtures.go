@@ -0,0 +1,270 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unexpectedProperty is the key the fixtures add to objects without additionalProperties
+const unexpectedProperty = "unexpectedProperty"
+
+// Fixture is a values file, which violates one constraint of the schema. It's meant to be
+// passed to helm install -f in the test suite of the chart, which must fail.
+type Fixture struct {
+	// Name identifies the fixture, it's the dotted key path and the violated keyword (e.g. image.tag.type)
+	Name string
+	// Description explains the violated constraint
+	Description string
+	// Values are merged onto the default values like helm does, null removes a key
+	Values map[string]interface{}
+}
+
+// YAML returns the values file of the fixture, starting with a comment with its description
+func (f Fixture) YAML() ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Must be rejected by the schema: %s\n", f.Description)
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(f.Values); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// wrongTypeValues are the values used to violate the type, the first one of a type which isn't allowed is used
+var wrongTypeValues = []struct {
+	typ   string
+	value interface{}
+}{
+	{"string", "wrong-type"},
+	{"integer", 1},
+	{"boolean", true},
+	{"array", []interface{}{}},
+	{"object", map[string]interface{}{}},
+}
+
+// NegativeFixtures returns a fixture for each constraint of the schema which can be violated
+// on its own: wrong types, values outside of the enum, numbers, lengths and item counts outside of
+// the bounds, missing required keys and unexpected keys. The fixtures only touch keys of objects,
+// which are in the default values (yaml), and are sorted by their names.
+// Every fixture is validated against the schema merged onto the default values, the ones which
+// are still valid (e.g. because of combined schemas) are dropped. Relative references are
+// resolved from schemaPath like by ValidateValues.
+func (s *Schema) NegativeFixtures(defaults []byte, schemaPath string) ([]Fixture, error) {
+	var values interface{}
+	if err := yaml.Unmarshal(defaults, &values); err != nil {
+		return nil, fmt.Errorf("could not parse the values: %w", err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	validate, err := s.valuesValidator(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(values); err != nil {
+		return nil, fmt.Errorf("the default values don't validate, the fixtures would be meaningless: %w", err)
+	}
+
+	g := fixtureGenerator{root: s, visited: map[*Schema]bool{}}
+	if m, ok := values.(map[string]interface{}); ok {
+		g.object(nil, s, m)
+	}
+
+	fixtures := make([]Fixture, 0, len(g.fixtures))
+	names := map[string]bool{}
+	for _, fixture := range g.fixtures {
+		if err := validate(mergeFixtureValues(values, fixture.Values)); err == nil {
+			logger.Debugf("Dropping the fixture %s, the values are still valid", fixture.Name)
+			continue
+		}
+		fixture.Name = uniqueName(fixture.Name, names)
+		names[fixture.Name] = true
+		fixtures = append(fixtures, fixture)
+	}
+	slices.SortStableFunc(fixtures, func(a, b Fixture) int { return strings.Compare(a.Name, b.Name) })
+	return fixtures, nil
+}
+
+// fixtureGenerator collects the fixtures while following the default values through the schema
+type fixtureGenerator struct {
+	root     *Schema
+	visited  map[*Schema]bool
+	fixtures []Fixture
+}
+
+func (g *fixtureGenerator) add(keys []string, keyword string, value interface{}, format string, args ...interface{}) {
+	path := strings.Join(keys, ".")
+	name := keyword
+	description := fmt.Sprintf(format, args...)
+	if path != "" {
+		name = path + "." + keyword
+		description = path + ": " + description
+	}
+	g.fixtures = append(g.fixtures, Fixture{
+		Name:        name,
+		Description: description,
+		Values:      nestValue(keys, value),
+	})
+}
+
+// object adds the fixtures of the object at the keys and its properties, values are its default values
+func (g *fixtureGenerator) object(keys []string, s *Schema, values map[string]interface{}) {
+	s = g.root.followRef(s)
+	// references can be recursive
+	if g.visited[s] {
+		return
+	}
+	g.visited[s] = true
+	defer delete(g.visited, s)
+
+	for _, name := range s.Required.Strings {
+		if value, ok := values[name]; ok && value != nil {
+			g.add(append(slices.Clone(keys), name), "required", nil, "the required key is missing")
+		}
+	}
+
+	if isClosed(s.AdditionalProperties) && !matchesPatternProperty(s, unexpectedProperty) {
+		if _, ok := s.Properties[unexpectedProperty]; !ok {
+			g.add(keys, "additionalProperties", map[string]interface{}{unexpectedProperty: "unexpected"}, "the key %s is not allowed", unexpectedProperty)
+		}
+	}
+
+	for _, name := range sortedSchemaKeys(s.Properties) {
+		propertyKeys := append(slices.Clone(keys), name)
+		property := g.root.followRef(s.Properties[name])
+		g.value(propertyKeys, property, values[name])
+		if m, ok := values[name].(map[string]interface{}); ok {
+			g.object(propertyKeys, property, m)
+		}
+	}
+}
+
+// value adds the fixtures violating the constraints of the value at the keys, value is its default value
+func (g *fixtureGenerator) value(keys []string, s *Schema, value interface{}) {
+	allows := func(typ string) bool {
+		return len(s.Type) == 0 || slices.Contains(s.Type, typ) || (typ == "integer" && slices.Contains(s.Type, "number"))
+	}
+
+	if len(s.Type) > 0 {
+		for _, wrong := range wrongTypeValues {
+			if !allows(wrong.typ) {
+				g.add(keys, "type", wrong.value, "the type must be %s", strings.Join(s.Type, ", "))
+				break
+			}
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		switch {
+		case allows("string"):
+			g.add(keys, "enum", "not-in-enum", "the value must be one of %s", jsonString(s.Enum))
+		case allows("integer"):
+			g.add(keys, "enum", maxEnumInteger(s.Enum)+1, "the value must be one of %s", jsonString(s.Enum))
+		}
+	}
+
+	if allows("integer") {
+		if s.Minimum != nil {
+			g.add(keys, "minimum", *s.Minimum-1, "the value must be at least %d", *s.Minimum)
+		}
+		if s.ExclusiveMinimum != nil {
+			g.add(keys, "exclusiveMinimum", *s.ExclusiveMinimum, "the value must be greater than %d", *s.ExclusiveMinimum)
+		}
+		if s.Maximum != nil {
+			g.add(keys, "maximum", *s.Maximum+1, "the value must be at most %d", *s.Maximum)
+		}
+		if s.ExclusiveMaximum != nil {
+			g.add(keys, "exclusiveMaximum", *s.ExclusiveMaximum, "the value must be less than %d", *s.ExclusiveMaximum)
+		}
+	}
+
+	if allows("string") {
+		if s.MinLength != nil && *s.MinLength > 0 {
+			g.add(keys, "minLength", strings.Repeat("a", *s.MinLength-1), "the value must have at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil {
+			g.add(keys, "maxLength", strings.Repeat("a", *s.MaxLength+1), "the value must have at most %d characters", *s.MaxLength)
+		}
+	}
+
+	// the remaining items are taken from the default value, so only their number is wrong
+	if items, ok := value.([]interface{}); ok && s.MinItems != nil && *s.MinItems > 0 && len(items) >= *s.MinItems-1 {
+		g.add(keys, "minItems", slices.Clone(items[:*s.MinItems-1]), "the list must have at least %d item(s)", *s.MinItems)
+	}
+}
+
+// maxEnumInteger returns the largest integer of the enum values (0 if there is none)
+func maxEnumInteger(values []interface{}) int {
+	maximum := 0
+	for _, value := range values {
+		if i, ok := value.(int); ok && i > maximum {
+			maximum = i
+		}
+		if f, ok := value.(float64); ok && int(f) > maximum {
+			maximum = int(f)
+		}
+	}
+	return maximum
+}
+
+// nestValue returns the maps nesting the value at the keys
+func nestValue(keys []string, value interface{}) map[string]interface{} {
+	if len(keys) == 0 {
+		if m, ok := value.(map[string]interface{}); ok {
+			return m
+		}
+		return map[string]interface{}{}
+	}
+	nested := map[string]interface{}{keys[len(keys)-1]: value}
+	for i := len(keys) - 2; i >= 0; i-- {
+		nested = map[string]interface{}{keys[i]: nested}
+	}
+	return nested
+}
+
+// mergeFixtureValues merges the values of a fixture onto the default values like helm does:
+// maps are merged recursively, null removes the key and other values replace the default.
+// The default values are not modified.
+func mergeFixtureValues(defaults interface{}, values map[string]interface{}) interface{} {
+	base, ok := defaults.(map[string]interface{})
+	if !ok {
+		return values
+	}
+
+	merged := make(map[string]interface{}, len(base))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range values {
+		switch v := value.(type) {
+		case nil:
+			delete(merged, key)
+		case map[string]interface{}:
+			if _, ok := merged[key].(map[string]interface{}); ok && len(v) > 0 {
+				merged[key] = mergeFixtureValues(merged[key], v)
+			} else {
+				merged[key] = v
+			}
+		default:
+			merged[key] = v
+		}
+	}
+	return merged
+}
+
+var fixtureFileNameReplacer = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// FixtureFileName returns the name of the values file of the fixture, e.g. image.tag.type.yaml
+func FixtureFileName(fixture Fixture) string {
+	return fixtureFileNameReplacer.ReplaceAllString(fixture.Name, "_") + ".yaml"
+}
@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fixtureNames(fixtures []Fixture) []string {
+	names := make([]string, 0, len(fixtures))
+	for _, fixture := range fixtures {
+		names = append(names, fixture.Name)
+	}
+	return names
+}
+
+func TestNegativeFixtures(t *testing.T) {
+	values := `# @schema
+# enum: [debug, info]
+# @schema
+level: debug
+# @schema
+# minimum: 1
+# maximum: 65535
+# @schema
+port: 80
+# @schema
+# additionalProperties: false
+# @schema
+image:
+  # @schema
+  # minLength: 1
+  # maxLength: 3
+  # required: true
+  # @schema
+  tag: abc
+  # @schema
+  # minItems: 2
+  # @schema
+  hosts: [a, b]
+# @schema
+# type: [string, "null"]
+# @schema
+nameOverride:
+`
+	s := selfCheckSchema(t, values)
+
+	fixtures, err := s.NegativeFixtures([]byte(values), "values.schema.json")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"additionalProperties",
+		"image.additionalProperties",
+		"image.hosts.minItems",
+		"image.tag.maxLength",
+		"image.tag.minLength",
+		"image.tag.required",
+		"level.enum",
+		"nameOverride.type",
+		"port.maximum",
+		"port.minimum",
+	}, fixtureNames(fixtures))
+
+	for _, fixture := range fixtures {
+		switch fixture.Name {
+		case "image.tag.required":
+			content, err := fixture.YAML()
+			assert.NoError(t, err)
+			assert.Equal(t, "# Must be rejected by the schema: image.tag: the required key is missing\nimage:\n  tag: null\n", string(content))
+		case "image.hosts.minItems":
+			assert.Equal(t, map[string]interface{}{"image": map[string]interface{}{"hosts": []interface{}{"a"}}}, fixture.Values)
+		case "image.additionalProperties":
+			assert.Equal(t, map[string]interface{}{"image": map[string]interface{}{unexpectedProperty: "unexpected"}}, fixture.Values)
+		case "port.maximum":
+			assert.Equal(t, map[string]interface{}{"port": 65536}, fixture.Values)
+			assert.Equal(t, "port: the value must be at most 65535", fixture.Description)
+		}
+	}
+	assert.Equal(t, "image.tag.required.yaml", FixtureFileName(fixtures[5]))
+}
+
+func TestNegativeFixturesInvalidDefaults(t *testing.T) {
+	s := selfCheckSchema(t, "port: 80\n")
+	_, err := s.NegativeFixtures([]byte("port: eighty\n"), "values.schema.json")
+	assert.ErrorContains(t, err, "the default values don't validate")
+
+	_, err = s.NegativeFixtures([]byte("port: [\n"), "values.schema.json")
+	assert.ErrorContains(t, err, "could not parse the values")
+}
+
+func TestMergeFixtureValues(t *testing.T) {
+	defaults := map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "latest"},
+		"replicas": 1,
+	}
+
+	merged := mergeFixtureValues(defaults, map[string]interface{}{
+		"image":    map[string]interface{}{"tag": nil},
+		"replicas": "one",
+	})
+	assert.Equal(t, map[string]interface{}{
+		"image":    map[string]interface{}{"repository": "nginx"},
+		"replicas": "one",
+	}, merged)
+
+	// the defaults are not modified
+	assert.Equal(t, "latest", defaults["image"].(map[string]interface{})["tag"])
+}
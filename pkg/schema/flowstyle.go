@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isFlowMapping checks if the node is a mapping in flow style (resources: {limits: {cpu: 100m}})
+func isFlowMapping(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && node.Style&yaml.FlowStyle != 0
+}
+
+// flowAnnotation is the annotation of a key of a flow-style mapping, which is moved from the
+// properties annotated on the key of the mapping
+type flowAnnotation struct {
+	// yaml is the yaml of the annotation
+	yaml []byte
+	// commentLines are the lines of the comment the lines of yaml are located at
+	commentLines []int
+}
+
+// commentFlowAnnotation returns the annotation of the comment of a key
+func commentFlowAnnotation(comment string, gen *generation) (flowAnnotation, error) {
+	scanned, err := scanComment(comment)
+	if err != nil {
+		return flowAnnotation{}, err
+	}
+	rawYaml, err := scanned.yaml(gen.RelaxedAnnotationIndentation)
+	if err != nil {
+		return flowAnnotation{}, err
+	}
+	return flowAnnotation{yaml: rawYaml, commentLines: scanned.commentLines}, nil
+}
+
+// annotateFlowMapping moves the properties of the annotation of the key of a flow-style mapping
+// to the keys of the mapping, because the keys of flow-style mappings can't have comments. The
+// keys are then generated like keys with their own annotation (see generation.flowAnnotations),
+// the moved properties are removed from the schema of the parent. The yaml nodes aren't changed.
+// It returns if any property was moved.
+func (s *Schema) annotateFlowMapping(annotation flowAnnotation, valueNode *yaml.Node, gen *generation) (bool, error) {
+	if len(s.Properties) == 0 || !isFlowMapping(valueNode) {
+		return false, nil
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(annotation.yaml, &node); err != nil {
+		return false, err
+	}
+	if len(node.Content) == 0 {
+		return false, nil
+	}
+	properties := mappingValue(node.Content[0], "properties")
+	if properties == nil || properties.Kind != yaml.MappingNode {
+		return false, nil
+	}
+
+	moved := false
+	for i := 0; i < len(valueNode.Content); i += 2 {
+		keyNode := valueNode.Content[i]
+		property := mappingValue(properties, keyNode.Value)
+		if property == nil {
+			continue
+		}
+		rawProperty, err := yaml.Marshal(property)
+		if err != nil {
+			return false, err
+		}
+
+		// the lines of the marshaled property are located at the line of its key
+		line := annotation.commentLines[min(max(property.Line-1, 0), len(annotation.commentLines)-1)]
+		lines := make([]int, strings.Count(strings.TrimRight(string(rawProperty), "\n"), "\n")+1)
+		for j := range lines {
+			lines[j] = line
+		}
+		if gen.flowAnnotations == nil {
+			gen.flowAnnotations = make(map[*yaml.Node]flowAnnotation)
+		}
+		gen.flowAnnotations[keyNode] = flowAnnotation{yaml: rawProperty, commentLines: lines}
+
+		delete(s.Properties, keyNode.Value)
+		moved = true
+	}
+
+	if !moved {
+		return false, nil
+	}
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	// the key is generated like a key without annotation, if properties were its only annotation
+	rest := *s
+	rest.commentExamples = nil
+	s.HasData = !rest.EqualsOpt(&Schema{}, EqualsFull)
+	return true, nil
+}
+
+// mappingValue returns the value of the key of the mapping node, nil if it isn't set
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
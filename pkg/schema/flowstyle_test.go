@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFlowStyleMappings(t *testing.T) {
+	values := `# @schema
+# properties:
+#   limits:
+#     description: the limits of the container
+#     properties:
+#       cpu:
+#         type: [string, integer]
+#         description: the cpu limit
+#   requests:
+#     type: object
+# @schema
+# the resources of the container
+resources: {limits: {cpu: 100m, memory: 128Mi}, requests: {cpu: 50m}}
+# @schema
+# properties:
+#   port:
+#     minimum: 1
+#     nullable: true
+#   extra:
+#     type: string
+# @schema
+service: {port: 80, type: ClusterIP}
+labels: {app: nginx}
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	skipConfig, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+
+	// the keys are annotated once, even if the values are generated again
+	for i := 0; i < 2; i++ {
+		s := YamlToSchema("values.yaml", &node, false, false, false, true, skipConfig, nil, nil)
+
+		// properties were the only annotation, so the key is generated like a key without annotation
+		resources := s.Properties["resources"]
+		assert.False(t, resources.HasData)
+		assert.Equal(t, StringOrArrayOfString{"object"}, resources.Type)
+		assert.Equal(t, "the resources of the container", resources.Description)
+		assert.Contains(t, s.Required.Strings, "resources")
+
+		limits := resources.Properties["limits"]
+		assert.Equal(t, "the limits of the container", limits.Description)
+		assert.Equal(t, StringOrArrayOfString{"string", "integer"}, limits.Properties["cpu"].Type)
+		assert.Equal(t, "the cpu limit", limits.Properties["cpu"].Description)
+		// keys without annotation are generated as usual
+		assert.Equal(t, StringOrArrayOfString{"string"}, limits.Properties["memory"].Type)
+		assert.Equal(t, StringOrArrayOfString{"object"}, resources.Properties["requests"].Type)
+		assert.Equal(t, StringOrArrayOfString{"string"}, resources.Properties["requests"].Properties["cpu"].Type)
+
+		// annotated properties which aren't in the values are kept
+		service := s.Properties["service"]
+		assert.Equal(t, StringOrArrayOfString{"object"}, service.Type)
+		assert.Equal(t, 1, *service.Properties["port"].Minimum)
+		assert.Equal(t, StringOrArrayOfString{"integer", "null"}, service.Properties["port"].Type)
+		assert.Equal(t, StringOrArrayOfString{"string"}, service.Properties["extra"].Type)
+		assert.Equal(t, StringOrArrayOfString{"string"}, service.Properties["type"].Type)
+
+		assert.Equal(t, StringOrArrayOfString{"string"}, s.Properties["labels"].Properties["app"].Type)
+	}
+
+	// the comments of the values are left as they are
+	var comments []string
+	walkMappings(&node, func(keyNode, _ *yaml.Node) {
+		if keyNode.HeadComment != "" {
+			comments = append(comments, keyNode.Value)
+		}
+	})
+	assert.Equal(t, []string{"resources", "service"}, comments)
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// expandForbidden rejects the forbidden keys (`forbidden: [oldKey]`) of the schema and its
+// subschemas with a not schema, which fails if any of the keys is set. An existing not is
+// kept by moving both into allOf.
+func (s *Schema) expandForbidden() {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if len(v.Forbidden) == 0 {
+			return nil
+		}
+
+		notSchema := &Schema{Description: fmt.Sprintf("Forbidden keys: %s", strings.Join(v.Forbidden, ", "))}
+		if len(v.Forbidden) == 1 {
+			notSchema.Required.Strings = []string{v.Forbidden[0]}
+		} else {
+			for _, key := range v.Forbidden {
+				notSchema.AnyOf = append(notSchema.AnyOf, &Schema{Required: BoolOrArrayOfString{Strings: []string{key}}})
+			}
+		}
+
+		if v.Not != nil {
+			v.AllOf = append(v.AllOf, &Schema{Not: v.Not}, &Schema{Not: notSchema})
+			v.Not = nil
+		} else {
+			v.Not = notSchema
+		}
+		return nil
+	})
+}
+
+func (s Schema) validateForbidden(profile ValidationProfile) error {
+	if len(s.Forbidden) == 0 {
+		return nil
+	}
+
+	if err := s.validateConstraintTypes(profile, "forbidden", "object"); err != nil {
+		return err
+	}
+
+	for i, key := range s.Forbidden {
+		if key == "" {
+			return errors.New("forbidden cannot contain empty keys")
+		}
+		if slices.Contains(s.Forbidden[:i], key) {
+			return fmt.Errorf("forbidden contains %s twice", key)
+		}
+		if slices.Contains(s.Required.Strings, key) {
+			return fmt.Errorf("%s cannot be both forbidden and required", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForbidden(t *testing.T) {
+	s := selfCheckSchema(t, `# @schema.root
+# forbidden: [legacy]
+# additionalProperties: true
+# @schema.root
+# @schema
+# forbidden: [tag]
+# additionalProperties: true
+# @schema
+image:
+  repository: nginx
+  version: "1.0"
+# @schema
+# forbidden: [host, port]
+# additionalProperties: true
+# not:
+#   required: [url]
+# @schema
+database:
+  address: db:5432
+`)
+
+	image := s.Properties["image"]
+	assert.Equal(t, []string{"tag"}, image.Not.Required.Strings)
+	assert.NoError(t, image.Validate())
+	assert.Equal(t, []string{"legacy"}, s.Not.Required.Strings)
+
+	database := s.Properties["database"]
+	assert.Nil(t, database.Not)
+	assert.Len(t, database.AllOf, 2)
+	assert.Equal(t, []string{"url"}, database.AllOf[0].Not.Required.Strings)
+	assert.Len(t, database.AllOf[1].Not.AnyOf, 2)
+
+	valid := `image:
+  repository: nginx
+  version: "1.0"
+database:
+  address: db:5432
+`
+	assert.NoError(t, s.ValidateValues([]byte(valid), "values.schema.json"))
+	assert.NoError(t, s.ValidateValues([]byte(valid+"other: true\n"), "values.schema.json"))
+
+	for _, values := range []string{
+		valid + "legacy: true\n",
+		strings.Replace(valid, "image:\n", "image:\n  tag: latest\n", 1),
+		strings.Replace(valid, "database:\n", "database:\n  port: 5432\n", 1),
+		strings.Replace(valid, "database:\n", "database:\n  url: db\n", 1),
+	} {
+		assert.Error(t, s.ValidateValues([]byte(values), "values.schema.json"), values)
+	}
+
+	// required keys are disabled for dependencies, the forbidden keys stay forbidden
+	s.DisableRequiredProperties()
+	assert.Error(t, s.ValidateValues([]byte("image:\n  tag: latest\n"), "values.schema.json"))
+	assert.NoError(t, s.ValidateValues([]byte("image: {}\n"), "values.schema.json"))
+}
+
+func TestValidateForbidden(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		valid  bool
+	}{
+		{name: "object", schema: Schema{Type: StringOrArrayOfString{"object"}, Forbidden: []string{"old"}}, valid: true},
+		{name: "string", schema: Schema{Type: StringOrArrayOfString{"string"}, Forbidden: []string{"old"}}, valid: false},
+		{name: "empty key", schema: Schema{Type: StringOrArrayOfString{"object"}, Forbidden: []string{""}}, valid: false},
+		{name: "duplicate key", schema: Schema{Type: StringOrArrayOfString{"object"}, Forbidden: []string{"old", "old"}}, valid: false},
+		{
+			name:   "required key",
+			schema: Schema{Type: StringOrArrayOfString{"object"}, Forbidden: []string{"old"}, Required: BoolOrArrayOfString{Strings: []string{"old"}}},
+			valid:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package schema
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+// Generator creates the schemas of charts and caches them between calls.
+// A cached schema is reused as long as the chart file, the values file and all
+// files referenced via $ref are unchanged. This is useful for long running
+// processes (e.g. watch or server modes), which regenerate the same charts over and over.
+type Generator struct {
+	gen *generation
+
+	mu    sync.Mutex
+	cache map[string]*generatorCacheEntry
+}
+
+// generatorCacheEntry is a generated result and the files it was generated from
+type generatorCacheEntry struct {
+	result Result
+	files  []string
+	digest [sha256.Size]byte
+}
+
+// NewGenerator returns a generator, which generates the schemas with the policy
+func NewGenerator(policy GenerationPolicy) (*Generator, error) {
+	gen, err := policy.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{
+		gen:   gen,
+		cache: make(map[string]*generatorCacheEntry),
+	}, nil
+}
+
+// Generate returns the result for the chart at chartPath (the path to its Chart.yaml).
+// The schema of the result is a copy, so it can be modified without affecting the cache.
+// For charts with the separate-schemas strategy, it's the schema of the first values file.
+func (g *Generator) Generate(chartPath string) Result {
+	return g.GenerateContext(context.Background(), chartPath)
+}
+
+// GenerateContext is Generate, which stops generating the schema when the context is done.
+// The errors of the result contain the error of the context then.
+func (g *Generator) GenerateContext(ctx context.Context, chartPath string) Result {
+	key := filepath.Clean(chartPath)
+
+	g.mu.Lock()
+	entry, ok := g.cache[key]
+	g.mu.Unlock()
+
+	if ok && fileDigest(entry.files) == entry.digest {
+		return cloneResult(entry.result)
+	}
+
+	result := generateResult(ctx, chartPath, DefaultOutputFile, g.gen)
+
+	// results with errors are not cached, they are likely to be fixed soon
+	if len(result.Errors) > 0 {
+		g.Invalidate(chartPath)
+		return result
+	}
+
+	files := resultFiles(result)
+	if result.ValuesPath == "" {
+		// the minimal schema of a chart without values must be replaced once a values file is created
+		for _, name := range g.gen.ValueFiles {
+			files = append(files, filepath.Join(filepath.Dir(key), name))
+		}
+	}
+	g.mu.Lock()
+	g.cache[key] = &generatorCacheEntry{
+		result: cloneResult(result),
+		files:  files,
+		digest: fileDigest(files),
+	}
+	g.mu.Unlock()
+
+	return result
+}
+
+// Invalidate removes the cached result of the chart at chartPath
+func (g *Generator) Invalidate(chartPath string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.cache, filepath.Clean(chartPath))
+}
+
+// InvalidateFile removes the cached results of all charts which were generated from
+// the given file (chart file, values file or referenced schema)
+func (g *Generator) InvalidateFile(path string) {
+	path = filepath.Clean(path)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, entry := range g.cache {
+		for _, file := range entry.files {
+			if file == path {
+				delete(g.cache, key)
+				break
+			}
+		}
+	}
+}
+
+// InvalidateAll clears the whole cache
+func (g *Generator) InvalidateAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cache = make(map[string]*generatorCacheEntry)
+}
+
+// cloneResult returns a copy of the result with a deep copy of its schema
+func cloneResult(result Result) Result {
+	result.Schema = *result.Schema.Clone()
+	result.Warnings = slices.Clone(result.Warnings)
+	return result
+}
+
+// resultFiles returns all local files, the result was generated from
+func resultFiles(result Result) []string {
+	files := []string{filepath.Clean(result.ChartPath)}
+	if result.ValuesPath != "" {
+		files = append(files, filepath.Clean(result.ValuesPath))
+	}
+	for _, valuesPath := range result.ValuesFiles {
+		if valuesPath != result.ValuesPath {
+			files = append(files, filepath.Clean(valuesPath))
+		}
+	}
+
+	for _, ref := range result.Refs {
+		if target, ok := localRefFile(result.ValuesPath, ref); ok {
+			files = append(files, target)
+		}
+	}
+	// the files referenced by referenced schemas
+	for _, file := range result.RefFiles {
+		if !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// localRefFile returns the path of the local file referenced by ref (a relative path or file:// URL)
+func localRefFile(valuesPath, ref string) (string, bool) {
+	target := strings.Split(ref, "#")[0]
+	if target == "" {
+		return "", false
+	}
+
+	if util.IsFileURL(target) {
+		path, err := util.FileURLToPath(target)
+		if err != nil {
+			return "", false
+		}
+		return filepath.Clean(path), true
+	}
+	if strings.Contains(target, "://") {
+		return "", false
+	}
+
+	if !util.IsAbsolutePath(target) {
+		target = filepath.Join(filepath.Dir(valuesPath), filepath.FromSlash(strings.ReplaceAll(target, `\`, "/")))
+	}
+	return filepath.Clean(target), true
+}
+
+// fileDigest calculates a digest over the content of all given files.
+// Missing files are part of the digest as well, so creating them changes it.
+func fileDigest(files []string) [sha256.Size]byte {
+	hash := sha256.New()
+
+	for _, path := range files {
+		hash.Write([]byte(path))
+		hash.Write([]byte{0})
+
+		file, err := os.Open(path)
+		if err != nil {
+			hash.Write([]byte{0})
+			continue
+		}
+		hash.Write([]byte{1})
+		_, _ = io.Copy(hash, file)
+		file.Close()
+	}
+
+	var digest [sha256.Size]byte
+	copy(digest[:], hash.Sum(nil))
+	return digest
+}
@@ -0,0 +1,204 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerator(t *testing.T) {
+	tmpDir := t.TempDir()
+	chartPath := filepath.Join(tmpDir, "Chart.yaml")
+	valuesPath := filepath.Join(tmpDir, "values.yaml")
+	refPath := filepath.Join(tmpDir, "ref.json")
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	writeFile(chartPath, "apiVersion: v2\nname: test-chart\nversion: 1.0.0\n")
+	writeFile(refPath, `{"port": {"type": "integer"}}`)
+	writeFile(valuesPath, `# @schema
+# $ref: ref.json#/port
+# @schema
+port: 80
+`)
+
+	generator, err := NewGenerator(GenerationPolicy{})
+	assert.NoError(t, err)
+
+	// cacheEntry returns the current cache entry of the chart
+	cacheEntry := func() *generatorCacheEntry {
+		return generator.cache[filepath.Clean(chartPath)]
+	}
+
+	first := generator.Generate(chartPath)
+	assert.Empty(t, first.Errors)
+	assert.Contains(t, first.Schema.Properties, "port")
+	firstEntry := cacheEntry()
+
+	second := generator.Generate(chartPath)
+	assert.Same(t, firstEntry, cacheEntry(), "expected the cached result")
+	assert.Equal(t, first.Schema, second.Schema)
+
+	// changing the values file regenerates the schema
+	writeFile(valuesPath, "port: 80\nhost: example.org\n")
+	third := generator.Generate(chartPath)
+	assert.NotSame(t, firstEntry, cacheEntry(), "expected a regenerated result")
+	assert.Contains(t, third.Schema.Properties, "host")
+
+	// explicit invalidation
+	thirdEntry := cacheEntry()
+	generator.Invalidate(chartPath)
+	generator.Generate(chartPath)
+	assert.NotSame(t, thirdEntry, cacheEntry(), "expected a regenerated result")
+
+	fourthEntry := cacheEntry()
+	generator.InvalidateFile(valuesPath)
+	generator.Generate(chartPath)
+	assert.NotSame(t, fourthEntry, cacheEntry(), "expected a regenerated result")
+
+	fifthEntry := cacheEntry()
+	generator.InvalidateAll()
+	generator.Generate(chartPath)
+	assert.NotSame(t, fifthEntry, cacheEntry(), "expected a regenerated result")
+}
+
+func TestGeneratorResultsAreCopies(t *testing.T) {
+	tmpDir := t.TempDir()
+	chartPath := filepath.Join(tmpDir, "Chart.yaml")
+
+	assert.NoError(t, os.WriteFile(chartPath, []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("port: 80\n"), 0o644))
+
+	generator, err := NewGenerator(GenerationPolicy{})
+	assert.NoError(t, err)
+
+	first := generator.Generate(chartPath)
+	first.Schema.Properties["port"].Title = "modified"
+	delete(first.Schema.Properties, "global")
+
+	second := generator.Generate(chartPath)
+	assert.Equal(t, "port", second.Schema.Properties["port"].Title)
+	assert.Contains(t, second.Schema.Properties, "global")
+}
+
+func TestGeneratorRefChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	chartPath := filepath.Join(tmpDir, "Chart.yaml")
+	refPath := filepath.Join(tmpDir, "ref.json")
+
+	assert.NoError(t, os.WriteFile(chartPath, []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.WriteFile(refPath, []byte(`{"type": "integer"}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte(`# @schema
+# $ref: ref.json
+# @schema
+port: 80
+`), 0o644))
+
+	generator, err := NewGenerator(GenerationPolicy{})
+	assert.NoError(t, err)
+
+	first := generator.Generate(chartPath)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, first.Schema.Properties["port"].Type)
+
+	// changing a referenced file regenerates the schema
+	assert.NoError(t, os.WriteFile(refPath, []byte(`{"type": "string"}`), 0o644))
+	second := generator.Generate(chartPath)
+	assert.Equal(t, StringOrArrayOfString{"string"}, second.Schema.Properties["port"].Type)
+}
+
+func TestGeneratorNestedRefChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	chartPath := filepath.Join(tmpDir, "Chart.yaml")
+	portPath := filepath.Join(tmpDir, "types", "port.json")
+
+	assert.NoError(t, os.WriteFile(chartPath, []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(portPath), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "common.json"), []byte(`{
+  "properties": {"service": {"type": "object", "properties": {"port": {"$ref": "./types/port.json"}}}}
+}`), 0o644))
+	assert.NoError(t, os.WriteFile(portPath, []byte(`{"type": "integer"}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte(`# @schema
+# $ref: common.json#/properties/service
+# @schema
+service:
+  port: 80
+`), 0o644))
+
+	generator, err := NewGenerator(GenerationPolicy{})
+	assert.NoError(t, err)
+
+	first := generator.Generate(chartPath)
+	assert.Empty(t, first.Errors)
+	assert.Contains(t, first.RefFiles, filepath.Clean(portPath))
+	assert.Equal(t, StringOrArrayOfString{"integer"}, first.Schema.Defs["port"].Type)
+
+	// changing a file referenced by a referenced file regenerates the schema
+	assert.NoError(t, os.WriteFile(portPath, []byte(`{"type": "string"}`), 0o644))
+	second := generator.Generate(chartPath)
+	assert.Equal(t, StringOrArrayOfString{"string"}, second.Schema.Defs["port"].Type)
+}
+
+func TestGeneratorMissingValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	chartPath := filepath.Join(tmpDir, "Chart.yaml")
+	assert.NoError(t, os.WriteFile(chartPath, []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+
+	generator, err := NewGenerator(GenerationPolicy{AllowMissingValues: true})
+	assert.NoError(t, err)
+
+	first := generator.Generate(chartPath)
+	assert.Empty(t, first.Errors)
+	assert.NotContains(t, first.Schema.Properties, "port")
+
+	// creating the values file replaces the minimal schema
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("port: 80\n"), 0o644))
+	second := generator.Generate(chartPath)
+	assert.Empty(t, second.Errors)
+	assert.Contains(t, second.Schema.Properties, "port")
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	chartPath := filepath.Join(tmpDir, "Chart.yaml")
+	valuesPath := filepath.Join(tmpDir, "values.yaml")
+
+	assert.NoError(t, os.WriteFile(chartPath, []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "ref.json"), []byte(`{"port": {"type": "integer"}}`), 0o644))
+	assert.NoError(t, os.WriteFile(valuesPath, []byte(`# @schema
+# $ref: ref.json#/missing
+# @schema
+port: 80
+`), 0o644))
+
+	// the errors are returned instead of stopping the program
+	generator, err := NewGenerator(GenerationPolicy{})
+	assert.NoError(t, err)
+	result := generator.Generate(chartPath)
+	if assert.Len(t, result.Errors, 1) {
+		// the errors contain the chart, the values file and the key
+		assert.ErrorContains(t, result.Errors[0], "could not generate the schema of chart test-chart")
+		assert.ErrorContains(t, result.Errors[0], "values.yaml:4: error while resolving the $refs of key port")
+		assert.ErrorContains(t, result.Errors[0], "could not resolve $ref ref.json#/missing")
+	}
+
+	assert.NoError(t, os.WriteFile(valuesPath, []byte(`# @schema
+# type: [string
+# @schema
+host: example.org
+`), 0o644))
+	result = generator.Generate(chartPath)
+	if assert.Len(t, result.Errors, 1) {
+		assert.ErrorContains(t, result.Errors[0], "values.yaml:2: error while parsing comment of key host")
+	}
+
+	assert.NoError(t, os.WriteFile(valuesPath, []byte("host: [example.org\n"), 0o644))
+	result = generator.Generate(chartPath)
+	if assert.Len(t, result.Errors, 1) {
+		assert.ErrorContains(t, result.Errors[0], "could not read the values file "+valuesPath+" of chart test-chart")
+	}
+}
@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/gitref"
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/registry"
+	"github.com/dadav/helm-schema/pkg/urlref"
+)
+
+// gitFetcher resolves the git+ references
+var gitFetcher = gitref.NewFetcher("")
+
+// UseGitFetcher sets the fetcher which resolves git+<url>@<revision>/<path> references,
+// by default the repositories are cached in the user cache directory.
+// It is not safe to change the fetcher while schemas are generated.
+func UseGitFetcher(fetcher *gitref.Fetcher) {
+	gitFetcher = fetcher
+}
+
+// fetchGitRef returns the schema referenced by the git reference (without json pointer)
+func fetchGitRef(ctx context.Context, ref string, log logging.Logger) ([]byte, error) {
+	parsed, err := gitref.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	content, err := gitFetcher.FetchContext(ctx, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockRef(ref, content, log); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// prefetchRefs pulls the schemas of the registry and git references (and of the url references
+// with RefModeInlineAll) with the context, so slow downloads can be cancelled. They are cached,
+// so resolving the references uses them.
+func prefetchRefs(ctx context.Context, refMode string, refs []string, log logging.Logger) error {
+	if refMode == RefModeKeepAll {
+		return nil
+	}
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		target := strings.Split(ref, "#")[0]
+		var err error
+		switch {
+		case registry.IsRef(target):
+			_, err = pullRegistryRef(ctx, target, log)
+		case gitref.IsRef(target):
+			_, err = fetchGitRef(ctx, target, log)
+		case urlref.IsRef(target) && refMode == RefModeInlineAll:
+			_, err = fetchURLRef(ctx, target, log)
+		}
+		if err != nil {
+			return fmt.Errorf("could not resolve $ref %s: %w", ref, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/gitref"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGitRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repository := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(repository, "common.json"), []byte(`{
+  "definitions": {"port": {"type": "integer", "minimum": 1}},
+  "properties": {"host": {"type": "string", "format": "hostname"}}
+}`), 0o644))
+	for _, args := range [][]string{{"init", "--quiet"}, {"add", "."}, {"commit", "--quiet", "-m", "schemas"}, {"tag", "v1.0.0"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repository
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+
+	fetcher := gitref.NewFetcher(t.TempDir())
+	fetcher.SetPolicy(&urlpolicy.Policy{AllowedSchemes: []string{"file"}})
+	UseGitFetcher(fetcher)
+	defer UseGitFetcher(gitref.NewFetcher(""))
+
+	ref := "git+file://" + filepath.ToSlash(repository) + "@v1.0.0/common.json"
+	yamlContent := `# @schema
+# $ref: ` + ref + `#/definitions/port
+# @schema
+port: 80
+# @schema
+# $ref: ` + ref + `#/properties/host
+# @schema
+host: example.com
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+	skipConfig, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+
+	s := YamlToSchema("values.yaml", &node, false, false, false, true, skipConfig, nil, nil)
+
+	assert.Equal(t, "#/definitions/port", s.Properties["port"].Ref)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, s.Definitions["port"].Type)
+	assert.Equal(t, "hostname", s.Properties["host"].Format)
+	assert.NoError(t, s.ValidateInternalRefs())
+}
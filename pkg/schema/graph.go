@@ -0,0 +1,231 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of edges in the chart graph
+const (
+	GraphEdgeDependency = "dependency"
+	GraphEdgeRef        = "ref"
+)
+
+// Kinds of nodes in the chart graph
+const (
+	GraphNodeChart = "chart"
+	GraphNodeFile  = "file"
+	GraphNodeURL   = "url"
+)
+
+// GraphNode is a chart or an external schema referenced by a chart
+type GraphNode struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// GraphEdge connects a chart with one of its dependencies or referenced schemas
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph describes how the charts and their schemas are coupled
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// CollectRefs returns all $ref values used in the annotations of the given values yaml
+func CollectRefs(node *yaml.Node) []string {
+	return collectRefs(node, &generation{})
+}
+
+// collectRefs is CollectRefs with the policy of the generation
+func collectRefs(node *yaml.Node, gen *generation) []string {
+	var refs []string
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i < len(n.Content); i += 2 {
+				comment := n.Content[i].HeadComment
+				rootSchema, remainingComment, err := getRootSchemaFromComment(comment, gen)
+				if err == nil {
+					collectSchemaRefs(&rootSchema, &refs)
+					comment = remainingComment
+				}
+				keySchema, _, err := getSchemaFromComment(comment, gen)
+				if err == nil {
+					collectSchemaRefs(&keySchema, &refs)
+				}
+			}
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return refs
+}
+
+// CollectValuesRefs returns all $ref values used in the annotations of all documents of the values file content
+func CollectValuesRefs(content []byte) ([]string, error) {
+	var refs []string
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, CollectRefs(&node)...)
+	}
+}
+
+// collectSchemaRefs adds all non-internal $ref values of the schema and its subschemas to refs
+func collectSchemaRefs(s *Schema, refs *[]string) {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if v.Ref != "" && !strings.HasPrefix(v.Ref, "#") && !slices.Contains(*refs, v.Ref) {
+			*refs = append(*refs, v.Ref)
+		}
+		return nil
+	})
+}
+
+// BuildGraph creates the graph of the charts, their dependencies and referenced schemas
+func BuildGraph(results []*Result) *Graph {
+	graph := &Graph{}
+	nodes := make(map[string]GraphNode)
+	chartDirs := make(map[string]string)
+
+	for _, result := range results {
+		if result.Chart == nil {
+			continue
+		}
+		nodes[result.Chart.Name] = GraphNode{
+			ID:      result.Chart.Name,
+			Kind:    GraphNodeChart,
+			Version: result.Chart.Version,
+			Path:    result.ChartPath,
+		}
+		chartDirs[filepath.Clean(filepath.Dir(result.ChartPath))] = result.Chart.Name
+	}
+
+	for _, result := range results {
+		if result.Chart == nil {
+			continue
+		}
+
+		for _, dep := range result.Chart.Dependencies {
+			// the dependencies are the same for all values files of a chart
+			if dep.Name == "" || result.Secondary {
+				continue
+			}
+			if _, ok := nodes[dep.Name]; !ok {
+				nodes[dep.Name] = GraphNode{ID: dep.Name, Kind: GraphNodeChart, Version: dep.Version}
+			}
+			graph.Edges = append(graph.Edges, GraphEdge{From: result.Chart.Name, To: dep.Name, Kind: GraphEdgeDependency})
+		}
+
+		for _, ref := range result.Refs {
+			target := strings.Split(ref, "#")[0]
+			if target == "" {
+				continue
+			}
+
+			var node GraphNode
+			if path, ok := localRefFile(result.ValuesPath, ref); !ok {
+				node = GraphNode{ID: target, Kind: GraphNodeURL}
+			} else {
+				node = GraphNode{ID: path, Kind: GraphNodeFile, Path: path}
+
+				// files within another chart couple the charts directly
+				for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+					if chartName, ok := chartDirs[dir]; ok {
+						if chartName != result.Chart.Name {
+							node = nodes[chartName]
+						}
+						break
+					}
+					if dir == filepath.Dir(dir) {
+						break
+					}
+				}
+			}
+
+			if _, ok := nodes[node.ID]; !ok {
+				nodes[node.ID] = node
+			}
+			edge := GraphEdge{From: result.Chart.Name, To: node.ID, Kind: GraphEdgeRef}
+			if !slices.Contains(graph.Edges, edge) {
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph
+}
+
+// ToJson converts the graph to json
+func (g *Graph) ToJson() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// ToDot converts the graph to the graphviz dot format
+func (g *Graph) ToDot() []byte {
+	var sb strings.Builder
+
+	sb.WriteString("digraph charts {\n")
+	for _, node := range g.Nodes {
+		shape := "box"
+		label := node.ID
+		switch node.Kind {
+		case GraphNodeFile:
+			shape = "note"
+		case GraphNodeURL:
+			shape = "ellipse"
+		}
+		if node.Version != "" {
+			label = fmt.Sprintf("%s (%s)", node.ID, node.Version)
+		}
+		fmt.Fprintf(&sb, "  %q [label=%q, shape=%s];\n", node.ID, label, shape)
+	}
+	for _, edge := range g.Edges {
+		style := "solid"
+		if edge.Kind == GraphEdgeRef {
+			style = "dashed"
+		}
+		fmt.Fprintf(&sb, "  %q -> %q [style=%s];\n", edge.From, edge.To, style)
+	}
+	sb.WriteString("}\n")
+
+	return []byte(sb.String())
+}
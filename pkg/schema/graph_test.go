@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCollectRefs(t *testing.T) {
+	yamlContent := `# @schema.root
+# $ref: root.json
+# @schema.root
+# @schema
+# $ref: foo.json#/foo
+# @schema
+foo: bar
+bar:
+  # @schema
+  # anyOf:
+  #   - $ref: https://example.org/schema.json
+  #   - $ref: "#/$defs/internal"
+  # @schema
+  baz: qux
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatalf("Failed to parse YAML: %v", err)
+	}
+
+	refs := CollectRefs(&node)
+	assert.Equal(t, []string{"root.json", "foo.json#/foo", "https://example.org/schema.json"}, refs)
+}
+
+func TestCollectValuesRefs(t *testing.T) {
+	refs, err := CollectValuesRefs([]byte(`# @schema
+# $ref: foo.json
+# @schema
+foo: bar
+---
+# @schema
+# $ref: https://example.org/schema.json#/bar
+# @schema
+bar: baz
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo.json", "https://example.org/schema.json#/bar"}, refs)
+
+	_, err = CollectValuesRefs([]byte("foo: [bar"))
+	assert.Error(t, err)
+}
+
+func TestBuildGraph(t *testing.T) {
+	parentDir := filepath.Join("charts", "parent")
+	childDir := filepath.Join(parentDir, "charts", "child")
+
+	results := []*Result{
+		{
+			ChartPath:  filepath.Join(parentDir, "Chart.yaml"),
+			ValuesPath: filepath.Join(parentDir, "values.yaml"),
+			Chart: &chart.ChartFile{
+				Name:         "parent",
+				Version:      "1.0.0",
+				Dependencies: []*chart.Dependency{{Name: "child"}, {Name: "external"}},
+			},
+			Refs: []string{
+				"charts/child/types.json#/$defs/port",
+				"common.json",
+				"https://example.org/schema.json#/foo",
+			},
+		},
+		{
+			ChartPath:  filepath.Join(childDir, "Chart.yaml"),
+			ValuesPath: filepath.Join(childDir, "values.yaml"),
+			Chart:      &chart.ChartFile{Name: "child", Version: "0.1.0"},
+		},
+		{
+			ChartPath: "broken/Chart.yaml",
+		},
+	}
+
+	graph := BuildGraph(results)
+
+	assert.Equal(t, []GraphNode{
+		{ID: filepath.Join(parentDir, "common.json"), Kind: GraphNodeFile, Path: filepath.Join(parentDir, "common.json")},
+		{ID: "child", Kind: GraphNodeChart, Version: "0.1.0", Path: filepath.Join(childDir, "Chart.yaml")},
+		{ID: "external", Kind: GraphNodeChart},
+		{ID: "https://example.org/schema.json", Kind: GraphNodeURL},
+		{ID: "parent", Kind: GraphNodeChart, Version: "1.0.0", Path: filepath.Join(parentDir, "Chart.yaml")},
+	}, graph.Nodes)
+
+	assert.Equal(t, []GraphEdge{
+		{From: "parent", To: filepath.Join(parentDir, "common.json"), Kind: GraphEdgeRef},
+		{From: "parent", To: "child", Kind: GraphEdgeDependency},
+		{From: "parent", To: "child", Kind: GraphEdgeRef},
+		{From: "parent", To: "external", Kind: GraphEdgeDependency},
+		{From: "parent", To: "https://example.org/schema.json", Kind: GraphEdgeRef},
+	}, graph.Edges)
+
+	dot := string(graph.ToDot())
+	assert.True(t, strings.HasPrefix(dot, "digraph charts {"))
+	assert.Contains(t, dot, `"parent" -> "child" [style=dashed];`)
+	assert.Contains(t, dot, `"parent" [label="parent (1.0.0)", shape=box];`)
+}
@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// helmFormats are the formats xeipuuv/gojsonschema, the validator of helm, checks. Other formats are ignored.
+var helmFormats = []string{
+	"date-time", "date", "time", "email", "idn-email", "hostname", "ipv4", "ipv6", "uuid",
+	"uri", "uri-reference", "iri", "iri-reference", "uri-template", "json-pointer", "relative-json-pointer", "regex",
+}
+
+// refAnnotations are the keywords next to a $ref, which are fine to be ignored by draft-07
+var refAnnotations = []string{
+	"$ref", "$schema", "$id", "$comment", "$defs", "definitions",
+	"title", "description", "default", "examples", "deprecated", "readOnly", "writeOnly",
+}
+
+// HelmIssue is a construct of a schema, which helm ignores or rejects
+type HelmIssue struct {
+	// Path is the JSON pointer of the subschema ("" for the root)
+	Path string
+	// Message describes the issue
+	Message string
+	// Rejected is set if helm fails to install the chart, otherwise helm ignores the construct
+	Rejected bool
+}
+
+func (i HelmIssue) String() string {
+	return fmt.Sprintf("%s: %s", pointerOrRoot(i.Path), i.Message)
+}
+
+// CheckHelmCompatibility reports the constructs of the schema, which don't behave like the
+// author probably expects on helm install. Helm compiles values.schema.json as draft-07 with
+// xeipuuv/gojsonschema, so
+// keywords next to a $ref, unknown formats and the content keywords are not enforced.
+// Helm adds the global values to the values of every chart, so a closed root schema without
+// a global property rejects them.
+func (s *Schema) CheckHelmCompatibility() []HelmIssue {
+	issues := []HelmIssue{}
+
+	if err := s.compileLikeHelm(); err != nil {
+		issues = append(issues, HelmIssue{Message: fmt.Sprintf("helm can't compile the schema: %s", err), Rejected: true})
+	}
+
+	if closed, ok := s.AdditionalProperties.(bool); ok && !closed {
+		if _, ok := s.Properties["global"]; !ok {
+			issues = append(issues, HelmIssue{Message: "additionalProperties is false, but there is no global property, helm rejects the global values it adds", Rejected: true})
+		}
+	}
+
+	_ = s.Walk(func(path string, v *Schema) error {
+		if v.Ref != "" {
+			if ignored := ignoredRefSiblings(v); len(ignored) > 0 {
+				issues = append(issues, HelmIssue{Path: path, Message: fmt.Sprintf("draft-07 ignores the keywords next to $ref, helm doesn't enforce %s", strings.Join(ignored, ", "))})
+			}
+		}
+		if v.Format != "" && !slices.Contains(helmFormats, v.Format) {
+			issues = append(issues, HelmIssue{Path: path, Message: fmt.Sprintf("helm doesn't know the format %s and ignores it", v.Format)})
+		}
+		if v.Anchor != "" || v.DynamicRef != "" {
+			issues = append(issues, HelmIssue{Path: path, Message: "$anchor and $dynamicRef are keywords of newer drafts, helm (draft-07) ignores them, so references to the anchor don't resolve"})
+		}
+		if v.ContentSchema != nil || v.ContentMediaType != "" || v.ContentEncoding != "" {
+			issues = append(issues, HelmIssue{Path: path, Message: "contentMediaType, contentEncoding and contentSchema are annotations in draft-07, helm doesn't validate the embedded content"})
+		}
+		return nil
+	})
+
+	return issues
+}
+
+// compileLikeHelm compiles the schema as draft-07 like helm does. Helm compiles it with
+// xeipuuv/gojsonschema, so this only approximates which schemas helm rejects.
+func (s *Schema) compileLikeHelm() error {
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		return err
+	}
+	schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(jsonStr))
+	if err != nil {
+		return err
+	}
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft7)
+	c.UseLoader(emptyLoader{})
+	if err := c.AddResource("file:///values.schema.json", schemaDoc); err != nil {
+		return err
+	}
+	_, err = c.Compile("file:///values.schema.json")
+	return err
+}
+
+// emptyLoader loads every referenced document as empty schema, the referenced documents
+// are not downloaded to check the compatibility of the schema itself
+type emptyLoader struct{}
+
+func (emptyLoader) Load(string) (any, error) {
+	return map[string]any{}, nil
+}
+
+// ignoredRefSiblings returns the validation keywords next to the $ref of the schema (sorted)
+func ignoredRefSiblings(s *Schema) []string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil
+	}
+
+	ignored := []string{}
+	for keyword, value := range keywords {
+		if slices.Contains(refAnnotations, keyword) || strings.HasPrefix(keyword, "x-") {
+			continue
+		}
+		// e.g. required: [] is added to every object
+		if trimmed := string(bytes.TrimSpace(value)); trimmed == "[]" || trimmed == "{}" {
+			continue
+		}
+		ignored = append(ignored, keyword)
+	}
+	sort.Strings(ignored)
+	return ignored
+}
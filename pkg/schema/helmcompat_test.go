@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHelmCompatibility(t *testing.T) {
+	s := &Schema{
+		Schema:               Draft07URI,
+		Type:                 StringOrArrayOfString{"object"},
+		AdditionalProperties: false,
+		Properties: map[string]*Schema{
+			"port": {Ref: "#/$defs/port", Description: "The port", Minimum: intPtr(1024), Required: BoolOrArrayOfString{Strings: []string{}}},
+			"name": {Type: StringOrArrayOfString{"string"}, Format: "k8s-name"},
+			"host": {Type: StringOrArrayOfString{"string"}, Format: "hostname"},
+			"config": {
+				Type:             StringOrArrayOfString{"string"},
+				ContentMediaType: "application/json",
+				ContentSchema:    &Schema{Type: StringOrArrayOfString{"object"}},
+			},
+		},
+		Defs: map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}}},
+	}
+
+	issues := s.CheckHelmCompatibility()
+	messages := map[string]HelmIssue{}
+	for _, issue := range issues {
+		messages[issue.Path] = issue
+	}
+
+	assert.Len(t, issues, 4)
+	assert.True(t, messages[""].Rejected)
+	assert.Contains(t, messages[""].Message, "global")
+	assert.Contains(t, messages["/properties/port"].Message, "helm doesn't enforce minimum")
+	assert.False(t, messages["/properties/port"].Rejected)
+	assert.Contains(t, messages["/properties/name"].Message, "k8s-name")
+	assert.Contains(t, messages["/properties/config"].String(), "#/properties/config: contentMediaType")
+
+	s.Properties["global"] = &Schema{Type: StringOrArrayOfString{"object"}}
+	s.Properties["port"].Minimum = nil
+	delete(s.Properties, "name")
+	delete(s.Properties, "config")
+	assert.Empty(t, s.CheckHelmCompatibility())
+}
+
+func TestCheckHelmCompatibilityRejectsInvalidPatterns(t *testing.T) {
+	s := &Schema{
+		Schema: Draft07URI,
+		Type:   StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			// lookaheads are not supported by the regular expressions of go
+			"password": {Type: StringOrArrayOfString{"string"}, Pattern: "^(?=.*[0-9]).{8,}$"},
+			"remote":   {Ref: "https://example.com/schema.json"},
+		},
+	}
+
+	issues := s.CheckHelmCompatibility()
+	assert.Len(t, issues, 1)
+	assert.True(t, issues[0].Rejected)
+	assert.Contains(t, issues[0].Message, "helm can't compile the schema")
+}
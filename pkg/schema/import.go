@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportAnnotation returns the subschema of the key at the dotted path (see Query) as the
+// content of a @schema annotation, e.g. to adopt the constraints of an upstream schema for
+// some keys of the values file. Internal references are inlined, because the definitions of
+// the imported schema don't exist in the generated one. If the parent schema requires the key,
+// the annotation is marked as required.
+func (s *Schema) ImportAnnotation(path string) (string, error) {
+	subSchema, err := s.Query(path)
+	if err != nil {
+		return "", err
+	}
+
+	imported := subSchema.Clone()
+	if err := s.inlineRefs(imported, map[string]bool{}); err != nil {
+		return "", fmt.Errorf("could not import %s: %w", path, err)
+	}
+
+	data, err := json.Marshal(imported)
+	if err != nil {
+		return "", err
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return "", err
+	}
+	removeEmptyRequired(generic)
+
+	if s.requiresKey(path) {
+		generic["required"] = true
+	}
+
+	var out strings.Builder
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(generic); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// AnnotatedComment prepends the annotation as @schema block to the comment of a key.
+// Both are expected without the leading "# " of the comment lines. Keys can only have
+// one @schema block, so an error is returned if the comment already contains one.
+func AnnotatedComment(comment, annotation string) (string, error) {
+	marker := strings.TrimPrefix(SchemaPrefix, "# ")
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.TrimSpace(line) == marker {
+			return "", fmt.Errorf("the comment already contains a %s annotation", marker)
+		}
+	}
+
+	annotated := marker + "\n" + annotation + "\n" + marker
+	if comment != "" {
+		annotated += "\n" + comment
+	}
+	return annotated, nil
+}
+
+// inlineRefs replaces the internal references of target with the referenced subschemas of s.
+// Keywords next to a reference are kept by moving the referenced schema into allOf.
+func (s *Schema) inlineRefs(target *Schema, inlining map[string]bool) error {
+	return target.Walk(func(_ string, v *Schema) error {
+		if !isInternalRef(v.Ref) {
+			return nil
+		}
+		ref := v.Ref
+		if inlining[ref] {
+			return fmt.Errorf("cannot inline the recursive reference %s", ref)
+		}
+
+		referenced, err := s.queryPointer(strings.TrimPrefix(ref, "#"), false)
+		if err != nil {
+			return fmt.Errorf("could not resolve $ref %s: %w", ref, err)
+		}
+		referenced = referenced.Clone()
+
+		inlining[ref] = true
+		err = s.inlineRefs(referenced, inlining)
+		delete(inlining, ref)
+		if err != nil {
+			return err
+		}
+
+		siblings := *v
+		siblings.Ref = ""
+		// nothing but the reference is written
+		if len(siblings.entries()) == 0 {
+			*v = *referenced
+		} else {
+			v.Ref = ""
+			v.AllOf = append(v.AllOf, referenced)
+		}
+		return nil
+	})
+}
+
+// requiresKey checks if the parent schema of the dotted path requires the key
+func (s *Schema) requiresKey(path string) bool {
+	parentPath, key := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parentPath, key = path[:i], path[i+1:]
+	}
+	if strings.HasSuffix(key, "[]") {
+		return false
+	}
+
+	parent, err := s.Query(parentPath)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(s.followRef(parent).Required.Strings, key)
+}
+
+// removeEmptyRequired removes the empty required arrays, which are added to every
+// subschema when it's marshalled, from the generic json schema
+func removeEmptyRequired(generic interface{}) {
+	schema, ok := generic.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if required, ok := schema["required"].([]interface{}); ok && len(required) == 0 {
+		delete(schema, "required")
+	}
+
+	for _, key := range schemaKeywords {
+		removeEmptyRequired(schema[key])
+	}
+	for _, key := range schemaMapKeywords {
+		if subSchemas, ok := schema[key].(map[string]interface{}); ok {
+			for _, subSchema := range subSchemas {
+				removeEmptyRequired(subSchema)
+			}
+		}
+	}
+	for _, key := range schemaArrayKeywords {
+		if subSchemas, ok := schema[key].([]interface{}); ok {
+			for _, subSchema := range subSchemas {
+				removeEmptyRequired(subSchema)
+			}
+		}
+	}
+}
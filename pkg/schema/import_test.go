@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const importSchemaInput = `{
+  "type": "object",
+  "required": ["ingress"],
+  "$defs": {
+    "port": {"type": "integer", "minimum": 1},
+    "node": {"type": "object", "properties": {"child": {"$ref": "#/$defs/node"}}}
+  },
+  "properties": {
+    "ingress": {
+      "type": "object",
+      "properties": {
+        "host": {"type": "string"},
+        "port": {"$ref": "#/$defs/port"},
+        "tlsPort": {"$ref": "#/$defs/port", "description": "The tls port"}
+      }
+    },
+    "tree": {"$ref": "#/$defs/node"},
+    "resources": {"type": "object"}
+  }
+}`
+
+func TestImportAnnotation(t *testing.T) {
+	var s Schema
+	assert.NoError(t, json.Unmarshal([]byte(importSchemaInput), &s))
+
+	annotation, err := s.ImportAnnotation("ingress")
+	assert.NoError(t, err)
+	assert.Equal(t, `properties:
+  host:
+    type: string
+  port:
+    minimum: 1
+    type: integer
+  tlsPort:
+    allOf:
+      - minimum: 1
+        type: integer
+    description: The tls port
+required: true
+type: object`, annotation)
+
+	annotation, err = s.ImportAnnotation("resources")
+	assert.NoError(t, err)
+	assert.Equal(t, "type: object", annotation)
+
+	annotation, err = s.ImportAnnotation("ingress.port")
+	assert.NoError(t, err)
+	assert.Equal(t, "minimum: 1\ntype: integer", annotation)
+
+	_, err = s.ImportAnnotation("tree")
+	assert.ErrorContains(t, err, "recursive reference")
+
+	_, err = s.ImportAnnotation("missing")
+	assert.Error(t, err)
+}
+
+func TestAnnotatedComment(t *testing.T) {
+	comment, err := AnnotatedComment("", "type: object")
+	assert.NoError(t, err)
+	assert.Equal(t, "@schema\ntype: object\n@schema", comment)
+
+	comment, err = AnnotatedComment("-- The ingress", "type: object")
+	assert.NoError(t, err)
+	assert.Equal(t, "@schema\ntype: object\n@schema\n-- The ingress", comment)
+
+	_, err = AnnotatedComment("@schema\ntype: string\n@schema", "type: object")
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AnnotationLineError is an error in a line of a @schema block
+type AnnotationLineError struct {
+	// Line is the line of the comment containing the error, starting with 1
+	Line int
+	// Message describes the error and how to fix it
+	Message string
+}
+
+func (e *AnnotationLineError) Error() string {
+	return e.Message
+}
+
+// annotationErrorLine returns the line of the values file the error of the comment of the key in
+// keyLine refers to, keyLine if the error isn't located in the comment
+func annotationErrorLine(err error, comment string, keyLine int) int {
+	var annotationErr *AnnotationLineError
+	if !errors.As(err, &annotationErr) {
+		return keyLine
+	}
+	// the comment ends right above the key
+	return keyLine - strings.Count(comment, "\n") - 2 + annotationErr.Line
+}
+
+// yamlSyntaxError matches the syntax errors of yaml, e.g. "yaml: line 3: did not find expected key"
+var yamlSyntaxError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// annotationYaml prepares the lines of the @schema blocks of a comment for parsing, commentLines
+// are the lines of the comment they were taken from. Tabs in the indentation are reported, unless
+// relaxed is set (see GenerationPolicy.RelaxedAnnotationIndentation). Lines which are indented less
+// than the first line are always reported, because yaml silently ignores them and everything after them.
+func annotationYaml(lines []string, commentLines []int, relaxed bool) ([]byte, error) {
+	if relaxed {
+		lines = dedentAnnotation(lines)
+	}
+
+	first := -1
+	for i, line := range lines {
+		content := strings.TrimLeft(line, " \t")
+		if content == "" || strings.HasPrefix(content, "#") {
+			continue
+		}
+		indentation := line[:len(line)-len(content)]
+		if strings.Contains(indentation, "\t") {
+			return nil, &AnnotationLineError{
+				Line:    commentLines[i],
+				Message: "the line is indented with a tab, yaml only allows spaces (replace the tabs with spaces or use --relaxed-annotation-indentation)",
+			}
+		}
+		if first < 0 {
+			first = len(indentation)
+		} else if len(indentation) < first {
+			return nil, &AnnotationLineError{
+				Line:    commentLines[i],
+				Message: "the line is indented less than the first line of the annotation, which ends it (indent the top-level keys of the annotation the same)",
+			}
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// dedentAnnotation expands the tabs in the indentation of the lines to two spaces and removes
+// the indentation all lines have in common
+func dedentAnnotation(lines []string) []string {
+	dedented := make([]string, len(lines))
+	common := -1
+	for i, line := range lines {
+		content := strings.TrimLeft(line, " \t")
+		indentation := strings.ReplaceAll(line[:len(line)-len(content)], "\t", "  ")
+		if content == "" {
+			continue
+		}
+		dedented[i] = indentation + content
+		if common < 0 || len(indentation) < common {
+			common = len(indentation)
+		}
+	}
+	for i, line := range dedented {
+		if line != "" {
+			dedented[i] = line[common:]
+		}
+	}
+	return dedented
+}
+
+// annotationSyntaxError locates the syntax error of yaml in the comment and suggests a fix, other
+// errors are returned unchanged. yaml reports indentation errors at the start of the enclosing
+// block, so the first inconsistently indented line is reported instead, if there is one.
+func annotationSyntaxError(err error, rawYaml []byte, commentLines []int) error {
+	match := yamlSyntaxError.FindStringSubmatch(err.Error())
+	if match == nil || len(commentLines) == 0 {
+		return err
+	}
+	if i := inconsistentIndentation(strings.Split(string(rawYaml), "\n")); i >= 0 {
+		return &AnnotationLineError{
+			Line:    commentLines[i],
+			Message: match[2] + ", the line is indented inconsistently (indent nested keys more than their parent and siblings the same, with spaces)",
+		}
+	}
+	line, _ := strconv.Atoi(match[1])
+	// errors at the end of the annotation refer to the line after it
+	line = min(max(line, 1), len(commentLines))
+	return &AnnotationLineError{
+		Line:    commentLines[line-1],
+		Message: match[2] + " (check the indentation of the annotation, nested keys must be indented more than their parent with spaces)",
+	}
+}
+
+// inconsistentIndentation returns the index of the first line, which is indented more than the
+// line above although that doesn't start a nested block, or which is indented less without
+// returning to the indentation of a parent. It returns -1 if the indentation is consistent.
+func inconsistentIndentation(lines []string) int {
+	var levels []int
+	opens := false
+	// the content of block scalars (| or >) is indented more than their key
+	blockScalar := -1
+	for i, line := range lines {
+		content := strings.TrimLeft(line, " ")
+		trimmed := strings.TrimSpace(content)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indentation := len(line) - len(content)
+		if blockScalar >= 0 && indentation > blockScalar {
+			continue
+		}
+		blockScalar = -1
+
+		switch {
+		case len(levels) == 0 || indentation > levels[len(levels)-1]:
+			if len(levels) > 0 && !opens {
+				return i
+			}
+			levels = append(levels, indentation)
+		case indentation < levels[len(levels)-1]:
+			for len(levels) > 0 && levels[len(levels)-1] > indentation {
+				levels = levels[:len(levels)-1]
+			}
+			if len(levels) == 0 || levels[len(levels)-1] != indentation {
+				return i
+			}
+		}
+
+		opens = strings.HasSuffix(trimmed, ":")
+		// the keys of a mapping in a list item are aligned with its first key
+		if item := strings.TrimPrefix(content, "- "); item != content {
+			levels = append(levels, indentation+len(content)-len(strings.TrimLeft(item, " ")))
+		}
+		// the indicator of a block scalar may be followed by its chomping and indentation (e.g. |-)
+		if indicator := strings.TrimRight(trimmed, "+-0123456789"); strings.HasSuffix(indicator, "|") || strings.HasSuffix(indicator, ">") {
+			blockScalar = indentation
+		}
+	}
+	return -1
+}
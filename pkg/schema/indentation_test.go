@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationTabs(t *testing.T) {
+	comment := "# the port\n# @schema\n# type: integer\n# not:\n#\tconst: 0\n# @schema"
+	_, _, err := GetSchemaFromComment(comment)
+	var annotationErr *AnnotationLineError
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Equal(t, 5, annotationErr.Line)
+	assert.Contains(t, err.Error(), "tab")
+	// the comment ends in the line above the key
+	assert.Equal(t, 6, annotationErrorLine(err, comment, 8))
+
+	s, _, err := getSchemaFromComment(comment, testGeneration(t, GenerationPolicy{RelaxedAnnotationIndentation: true}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.Not.Const)
+}
+
+func TestAnnotationIndentation(t *testing.T) {
+	// yaml ignores everything after a line which is indented less than the first one
+	_, _, err := GetSchemaFromComment("# @schema\n#   type: object\n# minProperties: 1\n# @schema")
+	var annotationErr *AnnotationLineError
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Equal(t, 3, annotationErr.Line)
+
+	_, _, err = GetSchemaFromComment("# @schema\n# type: object\n# properties:\n#   port:\n#     type: integer\n#    minimum: 1\n# @schema")
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Contains(t, err.Error(), "indented inconsistently")
+	assert.Equal(t, 6, annotationErr.Line)
+
+	_, _, err = GetSchemaFromComment("# @schema\n# anyOf:\n# - type: string\n# - type: integer\n#   minimum: 1\n#    maximum: 2\n# @schema")
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Equal(t, 6, annotationErr.Line)
+
+	// consistently indented blocks are fine
+	s, _, err := GetSchemaFromComment("# @schema\n#   type: object\n#   minProperties: 1\n# @schema")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *s.MinProperties)
+
+	_, _, err = GetRootSchemaFromComment("# @schema.root\n# title: values\n#\tdescription: all values\n# @schema.root")
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Equal(t, 3, annotationErr.Line)
+
+	// errors of other keys are located at the key
+	assert.Equal(t, 8, annotationErrorLine(errors.New("unknown preset"), "# @schema\n# @schema", 8))
+}
+
+func TestDedentAnnotation(t *testing.T) {
+	assert.Equal(t,
+		[]string{"type: object", "", "properties:", "  port:", "    type: integer"},
+		dedentAnnotation([]string{"  type: object", " ", "  properties:", "  \tport:", "  \t  type: integer"}),
+	)
+}
@@ -0,0 +1,215 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ConstraintRule adds numeric constraints to the keys matching one of its patterns.
+// It is only applied to keys without annotations whose type is one of Types.
+type ConstraintRule struct {
+	// Name identifies the rule, a rule with the name of a builtin rule replaces it
+	Name string
+	// Keys are glob patterns (see path.Match) matched against the names of the keys
+	Keys []string
+	// Types are the types the rule applies to (integer or number)
+	Types []string
+	// Minimum is the inclusive minimum
+	Minimum *int
+	// Maximum is the inclusive maximum
+	Maximum *int
+}
+
+// Names of the builtin constraint rules
+const (
+	ConstraintRulePort       = "port"
+	ConstraintRuleReplicas   = "replicas"
+	ConstraintRulePercentage = "percentage"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// DefaultConstraintRules returns the builtin constraint rules. The patterns respect camelCase,
+// so e.g. httpPort is matched by the port rule, but reportInterval isn't.
+func DefaultConstraintRules() []ConstraintRule {
+	return []ConstraintRule{
+		{
+			Name:    ConstraintRulePort,
+			Keys:    []string{"port", "*Port", "*_port"},
+			Types:   []string{"integer"},
+			Minimum: intPtr(1),
+			Maximum: intPtr(65535),
+		},
+		{
+			Name:    ConstraintRuleReplicas,
+			Keys:    []string{"replicas", "replicaCount", "*Replicas", "*_replicas"},
+			Types:   []string{"integer"},
+			Minimum: intPtr(0),
+		},
+		{
+			Name:    ConstraintRulePercentage,
+			Keys:    []string{"percentage", "*Percentage", "*_percentage", "percent", "*Percent", "*_percent"},
+			Types:   []string{"integer", "number"},
+			Minimum: intPtr(0),
+			Maximum: intPtr(100),
+		},
+	}
+}
+
+// NewConstraintRules returns the builtin rules extended by the custom rules.
+// Custom rules replace builtin rules with the same name, disabled rules are removed.
+func NewConstraintRules(custom []ConstraintRule, disabled []string) ([]ConstraintRule, error) {
+	rules := DefaultConstraintRules()
+
+	for _, rule := range custom {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		index := slices.IndexFunc(rules, func(r ConstraintRule) bool { return r.Name == rule.Name })
+		if index >= 0 {
+			rules[index] = rule
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+
+	for _, name := range disabled {
+		index := slices.IndexFunc(rules, func(r ConstraintRule) bool { return r.Name == name })
+		if index < 0 {
+			return nil, fmt.Errorf("unknown constraint rule %s", name)
+		}
+		rules = slices.Delete(rules, index, index+1)
+	}
+
+	return rules, nil
+}
+
+func (r ConstraintRule) validate() error {
+	if r.Name == "" {
+		return errors.New("constraint rule without name")
+	}
+	if len(r.Keys) == 0 {
+		return fmt.Errorf("constraint rule %s has no keys", r.Name)
+	}
+	for _, key := range r.Keys {
+		if _, err := path.Match(key, ""); err != nil {
+			return fmt.Errorf("invalid key pattern %s of constraint rule %s: %w", key, r.Name, err)
+		}
+	}
+	if len(r.Types) == 0 {
+		return fmt.Errorf("constraint rule %s has no types", r.Name)
+	}
+	for _, t := range r.Types {
+		if t != "integer" && t != "number" {
+			return fmt.Errorf("constraint rule %s can only be used for integer and number types, got %s", r.Name, t)
+		}
+	}
+	if r.Minimum == nil && r.Maximum == nil {
+		return fmt.Errorf("constraint rule %s has neither a minimum nor a maximum", r.Name)
+	}
+	if r.Minimum != nil && r.Maximum != nil && *r.Minimum > *r.Maximum {
+		return fmt.Errorf("minimum (%d) of constraint rule %s is greater than its maximum (%d)", *r.Minimum, r.Name, *r.Maximum)
+	}
+	return nil
+}
+
+// matches checks if the rule applies to the key with the given schema. The type is taken from the
+// default value of the key, so keys whose type isn't generated (skip-auto-generation) or allows
+// other values too (e.g. null) are matched by their value. Rules whose bounds the default value
+// violates don't apply, so the default stays valid.
+func (r ConstraintRule) matches(key string, s *Schema) bool {
+	if s.Default != nil {
+		value, ok := numericDefault(s)
+		if !ok || !r.accepts(value) {
+			return false
+		}
+	} else if !slices.ContainsFunc(r.Types, func(t string) bool { return s.Type.Matches(t) }) {
+		return false
+	}
+	for _, pattern := range r.Keys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// numericDefault returns the default value of the schema if it's a number. Without type the default
+// is the raw value of the values file, which is parsed.
+func numericDefault(s *Schema) (float64, bool) {
+	value := s.Default
+	if raw, ok := value.(string); ok && s.Type.IsEmpty() {
+		value = castNodeValueByType(raw, StringOrArrayOfString{"integer", "number"})
+	}
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// accepts checks if the value is of one of the types of the rule and within its bounds
+func (r ConstraintRule) accepts(value float64) bool {
+	if !slices.Contains(r.Types, "number") && value != math.Trunc(value) {
+		return false
+	}
+	if r.Minimum != nil && value < float64(*r.Minimum) {
+		return false
+	}
+	return r.Maximum == nil || value <= float64(*r.Maximum)
+}
+
+// InferConstraints recursively adds the constraints of the first matching rule to all keys
+// which are not annotated. Constraints which are already set are kept.
+func (s *Schema) InferConstraints(rules []ConstraintRule) {
+	if len(rules) == 0 {
+		return
+	}
+
+	_ = s.Walk(func(_ string, v *Schema) error {
+		for key, property := range v.Properties {
+			if property == nil || property.HasData {
+				continue
+			}
+
+			for _, rule := range rules {
+				if !rule.matches(key, property) {
+					continue
+				}
+				if rule.Minimum != nil && property.Minimum == nil && property.ExclusiveMinimum == nil {
+					property.Minimum = intPtr(*rule.Minimum)
+				}
+				if rule.Maximum != nil && property.Maximum == nil && property.ExclusiveMaximum == nil {
+					property.Maximum = intPtr(*rule.Maximum)
+				}
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// String returns a short description of the rule, e.g. "port (port, *Port): 1..65535"
+func (r ConstraintRule) String() string {
+	bounds := ""
+	if r.Minimum != nil {
+		bounds += fmt.Sprint(*r.Minimum)
+	}
+	bounds += ".."
+	if r.Maximum != nil {
+		bounds += fmt.Sprint(*r.Maximum)
+	}
+	return fmt.Sprintf("%s (%s): %s", r.Name, strings.Join(r.Keys, ", "), bounds)
+}
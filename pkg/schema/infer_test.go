@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInferConstraints(t *testing.T) {
+	yamlContent := `port: 80
+httpPort: 8080
+metrics_port: 9090
+reportInterval: 10
+# @schema
+# type: integer
+# @schema
+adminPort: 8443
+portName: http
+replicaCount: 1
+maxReplicas: 3
+targetCPUUtilizationPercentage: 80.5
+service:
+  nodePort: 30080
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+	schema.Properties["httpPort"].Maximum = intPtr(9000)
+	schema.InferConstraints(DefaultConstraintRules())
+
+	tests := []struct {
+		key     []string
+		minimum *int
+		maximum *int
+	}{
+		{key: []string{"port"}, minimum: intPtr(1), maximum: intPtr(65535)},
+		{key: []string{"httpPort"}, minimum: intPtr(1), maximum: intPtr(9000)},
+		{key: []string{"metrics_port"}, minimum: intPtr(1), maximum: intPtr(65535)},
+		{key: []string{"reportInterval"}},
+		{key: []string{"adminPort"}},
+		{key: []string{"portName"}},
+		{key: []string{"replicaCount"}, minimum: intPtr(0)},
+		{key: []string{"maxReplicas"}, minimum: intPtr(0)},
+		{key: []string{"targetCPUUtilizationPercentage"}, minimum: intPtr(0), maximum: intPtr(100)},
+		{key: []string{"service", "nodePort"}, minimum: intPtr(1), maximum: intPtr(65535)},
+	}
+	for _, tt := range tests {
+		property := schema
+		for _, key := range tt.key {
+			property = property.Properties[key]
+		}
+		assert.Equal(t, tt.minimum, property.Minimum, tt.key)
+		assert.Equal(t, tt.maximum, property.Maximum, tt.key)
+	}
+}
+
+func TestInferConstraintsFromDefaults(t *testing.T) {
+	yamlContent := `port: 80
+adminPort: "8443"
+replicaCount: -1
+`
+	generate := func(skip []string) *Schema {
+		t.Helper()
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+			t.Fatal(err)
+		}
+		config, err := NewSkipAutoGenerationConfig(skip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+		schema.InferConstraints(DefaultConstraintRules())
+		return schema
+	}
+
+	// the type of the key isn't generated, but its default is a port
+	schema := generate([]string{"type"})
+	assert.Empty(t, schema.Properties["port"].Type)
+	assert.Equal(t, intPtr(1), schema.Properties["port"].Minimum)
+	assert.Equal(t, intPtr(65535), schema.Properties["port"].Maximum)
+
+	schema = generate([]string{})
+	assert.Equal(t, intPtr(65535), schema.Properties["port"].Maximum)
+	// strings are not numbers, even if they look like one
+	assert.Nil(t, schema.Properties["adminPort"].Minimum)
+	// the default would be invalid
+	assert.Nil(t, schema.Properties["replicaCount"].Minimum)
+}
+
+func TestNewConstraintRules(t *testing.T) {
+	timeout := ConstraintRule{Name: "timeout", Keys: []string{"*Timeout"}, Types: []string{"integer"}, Minimum: intPtr(1)}
+	port := ConstraintRule{Name: ConstraintRulePort, Keys: []string{"*Port"}, Types: []string{"integer"}, Minimum: intPtr(1024), Maximum: intPtr(65535)}
+
+	rules, err := NewConstraintRules([]ConstraintRule{timeout, port}, []string{ConstraintRulePercentage})
+	assert.NoError(t, err)
+
+	names := []string{}
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	assert.Equal(t, []string{ConstraintRulePort, ConstraintRuleReplicas, "timeout"}, names)
+	assert.Equal(t, 1024, *rules[0].Minimum)
+	assert.Equal(t, "port (*Port): 1024..65535", rules[0].String())
+
+	invalid := []ConstraintRule{
+		{Keys: []string{"foo"}, Types: []string{"integer"}, Minimum: intPtr(1)},
+		{Name: "foo", Types: []string{"integer"}, Minimum: intPtr(1)},
+		{Name: "foo", Keys: []string{"["}, Types: []string{"integer"}, Minimum: intPtr(1)},
+		{Name: "foo", Keys: []string{"foo"}, Types: []string{"string"}, Minimum: intPtr(1)},
+		{Name: "foo", Keys: []string{"foo"}, Types: []string{"integer"}},
+		{Name: "foo", Keys: []string{"foo"}, Types: []string{"integer"}, Minimum: intPtr(2), Maximum: intPtr(1)},
+	}
+	for _, rule := range invalid {
+		_, err := NewConstraintRules([]ConstraintRule{rule}, nil)
+		assert.Error(t, err, rule)
+	}
+
+	_, err = NewConstraintRules(nil, []string{"unknown"})
+	assert.Error(t, err)
+}
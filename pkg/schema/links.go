@@ -0,0 +1,76 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// DocsURLAnnotation contains the url of the detailed documentation of a value
+const DocsURLAnnotation = CustomAnnotationPrefix + "docs-url"
+
+// docsLinkRels are the relations of links, which point to the documentation of a value
+var docsLinkRels = []string{"describedby", "help", "about"}
+
+// Link is a link description object of the links keyword (JSON hyper-schema)
+type Link struct {
+	Rel   string `yaml:"rel,omitempty"   json:"rel,omitempty"`
+	Href  string `yaml:"href"            json:"href"`
+	Title string `yaml:"title,omitempty" json:"title,omitempty"`
+}
+
+// DocsURL returns the url of the documentation of the value, the x-docs-url annotation or
+// the first link with a documentation relation (describedby, help or about)
+func (s *Schema) DocsURL() string {
+	if docsURL, ok := s.CustomAnnotations[DocsURLAnnotation].(string); ok && docsURL != "" {
+		return docsURL
+	}
+	for _, link := range s.Links {
+		if slices.Contains(docsLinkRels, link.Rel) {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func (s Schema) validateLinks() error {
+	if value, ok := s.CustomAnnotations[DocsURLAnnotation]; ok {
+		docsURL, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string, got %T", DocsURLAnnotation, value)
+		}
+		if err := validateLinkURL(docsURL); err != nil {
+			return fmt.Errorf("invalid %s: %w", DocsURLAnnotation, err)
+		}
+	}
+
+	for _, link := range s.Links {
+		if link.Href == "" {
+			return errors.New("links need an href")
+		}
+		if err := validateLinkURL(link.Href); err != nil {
+			return fmt.Errorf("invalid href of link: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateLinkURL checks that the url can be parsed. Relative urls are allowed,
+// they are resolved from the location of the schema.
+func validateLinkURL(link string) error {
+	if link == "" {
+		return errors.New("empty url")
+	}
+	_, err := url.Parse(link)
+	return err
+}
+
+// docsLinkText returns the text which links the description to the documentation
+func docsLinkText(docsURL string, plain bool) string {
+	if plain {
+		return "Documentation: " + docsURL
+	}
+	return fmt.Sprintf("[Documentation](%s)", docsURL)
+}
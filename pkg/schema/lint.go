@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severities of the issues of the lint rules
+const (
+	// SeverityWarning reports the issues as warnings
+	SeverityWarning = "warning"
+	// SeverityError reports the issues as errors, which fail the generation
+	SeverityError = "error"
+)
+
+// RuleRequireDescription is the lint rule requiring descriptions of properties (see DescriptionPolicy)
+const RuleRequireDescription = "require-description"
+
+// Scopes of the properties which must have a description
+const (
+	// DescriptionScopeAll requires descriptions of all properties
+	DescriptionScopeAll = "all"
+	// DescriptionScopeAnnotated requires descriptions of the properties with a @schema annotation
+	DescriptionScopeAnnotated = "annotated"
+	// DescriptionScopeTopLevel requires descriptions of the properties of the root
+	DescriptionScopeTopLevel = "top-level"
+)
+
+// LintIssue is a violation of a lint rule
+type LintIssue struct {
+	// Rule is the name of the violated rule, e.g. RuleRequireDescription
+	Rule string
+	// Severity is SeverityWarning or SeverityError
+	Severity string
+	// Path contains the keys of the property, the items of lists are addressed by []
+	Path []string
+	// Message describes the issue
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", dottedPath(i.Path), i.Message, i.Rule)
+}
+
+// DescriptionPolicy requires properties to have a non-empty description
+type DescriptionPolicy struct {
+	// Scope is one of the description scopes, the rule is disabled if it's empty
+	Scope string
+	// Severity of the issues (SeverityWarning if empty)
+	Severity string
+}
+
+// Validate checks the scope and the severity of the policy
+func (p DescriptionPolicy) Validate() error {
+	if p.Scope != "" && !slices.Contains([]string{DescriptionScopeAll, DescriptionScopeAnnotated, DescriptionScopeTopLevel}, p.Scope) {
+		return fmt.Errorf("invalid description scope %s, one of (%s, %s, %s)", p.Scope, DescriptionScopeAll, DescriptionScopeAnnotated, DescriptionScopeTopLevel)
+	}
+	if p.Severity != "" && p.Severity != SeverityWarning && p.Severity != SeverityError {
+		return fmt.Errorf("invalid severity %s, one of (%s, %s)", p.Severity, SeverityWarning, SeverityError)
+	}
+	return nil
+}
+
+// LintDescriptions reports the properties of the scope of the policy, which have no description.
+// Properties referencing a definition with a description are described as well. The global
+// property helm adds and the properties of referenced schemas are not checked.
+func (s *Schema) LintDescriptions(policy DescriptionPolicy) []LintIssue {
+	if policy.Scope == "" {
+		return nil
+	}
+	severity := policy.Severity
+	if severity == "" {
+		severity = SeverityWarning
+	}
+
+	var issues []LintIssue
+	// the properties of several variants (e.g. of anyOf) with the same path are reported once
+	reported := make(map[string]bool)
+	var lint func(v *Schema, path []string)
+	lint = func(v *Schema, path []string) {
+		if v == nil {
+			return
+		}
+		names := make([]string, 0, len(v.Properties))
+		for name := range v.Properties {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if len(path) == 0 && name == "global" {
+				continue
+			}
+			property := v.Properties[name]
+			propertyPath := append(slices.Clone(path), name)
+			key := dottedPath(propertyPath)
+			if !reported[key] && (policy.Scope != DescriptionScopeAnnotated || property.HasData) && !s.isDescribed(property) {
+				reported[key] = true
+				issues = append(issues, LintIssue{
+					Rule:     RuleRequireDescription,
+					Severity: severity,
+					Path:     propertyPath,
+					Message:  "the property has no description",
+				})
+			}
+			if policy.Scope != DescriptionScopeTopLevel {
+				lint(property, propertyPath)
+			}
+		}
+		if policy.Scope != DescriptionScopeTopLevel {
+			lint(v.Items, append(slices.Clone(path), itemsSegment))
+		}
+		for _, variants := range [][]*Schema{v.AnyOf, v.OneOf, v.AllOf} {
+			for _, variant := range variants {
+				lint(variant, path)
+			}
+		}
+	}
+	lint(s, nil)
+	return issues
+}
+
+// isDescribed checks if the schema or the definition it references has a description
+func (s *Schema) isDescribed(v *Schema) bool {
+	seen := make(map[string]bool)
+	for v != nil {
+		if strings.TrimSpace(v.Description) != "" {
+			return true
+		}
+		if !isInternalRef(v.Ref) || seen[v.Ref] {
+			return false
+		}
+		seen[v.Ref] = true
+		target, err := s.queryPointer(strings.TrimPrefix(v.Ref, "#"), false)
+		if err != nil {
+			return false
+		}
+		v = target
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLintDescriptions(t *testing.T) {
+	values := `# -- the image
+image:
+  # @schema
+  # type: string
+  # @schema
+  tag: latest
+  pullPolicy: IfNotPresent
+# @schema
+# $ref: "#/$defs/port"
+# @schema
+port: 80
+# @schema
+# type: integer
+# @schema
+replicas: 1
+hosts:
+  - name: example.com
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	s, err := GenerationPolicy{HelmDocsCompatibilityMode: true}.ToSchema("values.yaml", &node)
+	assert.NoError(t, err)
+	s.Defs = map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}, Description: "the port"}}
+
+	paths := func(issues []LintIssue) []string {
+		var paths []string
+		for _, issue := range issues {
+			paths = append(paths, issue.String())
+		}
+		return paths
+	}
+
+	assert.Empty(t, s.LintDescriptions(DescriptionPolicy{}))
+	assert.Equal(t, []string{
+		"hosts: the property has no description (require-description)",
+		"hosts[].name: the property has no description (require-description)",
+		"image.pullPolicy: the property has no description (require-description)",
+		"image.tag: the property has no description (require-description)",
+		"replicas: the property has no description (require-description)",
+	}, paths(s.LintDescriptions(DescriptionPolicy{Scope: DescriptionScopeAll})))
+	assert.Equal(t, []string{
+		"image.tag: the property has no description (require-description)",
+		"replicas: the property has no description (require-description)",
+	}, paths(s.LintDescriptions(DescriptionPolicy{Scope: DescriptionScopeAnnotated})))
+
+	issues := s.LintDescriptions(DescriptionPolicy{Scope: DescriptionScopeTopLevel, Severity: SeverityError})
+	assert.Equal(t, []string{
+		"hosts: the property has no description (require-description)",
+		"replicas: the property has no description (require-description)",
+	}, paths(issues))
+	assert.Equal(t, SeverityError, issues[0].Severity)
+	assert.Equal(t, []string{"hosts"}, issues[0].Path)
+
+	assert.NoError(t, DescriptionPolicy{Scope: DescriptionScopeAll, Severity: SeverityWarning}.Validate())
+	assert.Error(t, DescriptionPolicy{Scope: "some"}.Validate())
+	assert.Error(t, DescriptionPolicy{Scope: DescriptionScopeAll, Severity: "info"}.Validate())
+}
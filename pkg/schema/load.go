@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RawKeywords are keywords of a schema with their json values
+type RawKeywords map[string]json.RawMessage
+
+// schemaFieldsByName maps the json keywords to the fields of Schema
+var schemaFieldsByName = sync.OnceValue(func() map[string]schemaField {
+	fields := make(map[string]schemaField)
+	for _, field := range schemaFields() {
+		fields[field.name] = field
+	}
+	return fields
+})
+
+// Load reads a json schema without losing keywords. Keywords helm-schema doesn't know, and values
+// which can't be represented by the fields of Schema (e.g. "minimum": 0.5 or "items": true), are
+// kept in Extras, so they are written again. The other keywords are written like generated ones
+// (e.g. "type": ["string"] as "type": "string").
+func Load(r io.Reader) (*Schema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UnmarshalJSON reads the keywords into the fields of the schema and the custom annotations (x-*).
+// Keywords without field and values their field can't represent are kept in Extras.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return err
+	}
+
+	loaded := Schema{omitRequired: true}
+	v := reflect.ValueOf(&loaded).Elem()
+	fields := schemaFieldsByName()
+	for keyword, value := range keywords {
+		if strings.HasPrefix(keyword, CustomAnnotationPrefix) {
+			var annotation interface{}
+			if err := json.Unmarshal(value, &annotation); err != nil {
+				return err
+			}
+			if loaded.CustomAnnotations == nil {
+				loaded.CustomAnnotations = make(map[string]interface{})
+			}
+			loaded.CustomAnnotations[keyword] = annotation
+			continue
+		}
+
+		switch keyword {
+		case "required":
+			loaded.omitRequired = false
+		case "const":
+			loaded.constWasSet = true
+		}
+
+		field, ok := fields[keyword]
+		if !ok {
+			loaded.setExtra(keyword, value)
+			continue
+		}
+		target := reflect.New(v.Field(field.index).Type())
+		if keyword == "additionalProperties" {
+			additional, ok := loadSchemaOrBool(value)
+			if !ok {
+				loaded.setExtra(keyword, value)
+				continue
+			}
+			target = reflect.ValueOf(new(interface{}))
+			target.Elem().Set(reflect.ValueOf(additional))
+		} else if err := json.Unmarshal(value, target.Interface()); err != nil {
+			loaded.setExtra(keyword, value)
+			continue
+		}
+		v.Field(field.index).Set(target.Elem())
+	}
+
+	*s = loaded
+	return nil
+}
+
+// loadSchemaOrBool reads the value of additionalProperties, a boolean or a schema
+func loadSchemaOrBool(value json.RawMessage) (SchemaOrBool, bool) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, true
+	}
+	var s Schema
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, true
+	}
+	return nil, false
+}
+
+// setExtra keeps the raw value of the keyword, which is written unless the field of the keyword is set
+func (s *Schema) setExtra(keyword string, value json.RawMessage) {
+	if s.Extras == nil {
+		s.Extras = make(RawKeywords)
+	}
+	s.Extras[keyword] = value
+}
@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	original := `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "x-root": {"owner": "team"},
+  "propertyNames": {"pattern": "^[a-z]+$"},
+  "dependencies": {"tls": ["cert"]},
+  "properties": {
+    "ratio": {"type": "number", "minimum": 0.5, "multipleOf": 0.25},
+    "names": {"type": ["array"], "items": true, "contains": {"const": "app"}, "uniqueItems": false},
+    "mode": {"const": null, "default": 1.0},
+    "labels": {"type": "object", "additionalProperties": {"type": "string", "minLength": 1}},
+    "tuple": {"type": "array", "items": [{"type": "string"}, {"type": "integer"}]}
+  },
+  "required": ["ratio"]
+}`
+
+	s, err := Load(strings.NewReader(original))
+	assert.NoError(t, err)
+
+	// the fields are read, as far as they can represent the keywords
+	assert.Equal(t, StringOrArrayOfString{"object"}, s.Type)
+	assert.Equal(t, []string{"ratio"}, s.Required.Strings)
+	assert.Equal(t, StringOrArrayOfString{"array"}, s.Properties["names"].Type)
+	assert.Nil(t, s.Properties["ratio"].Minimum)
+	assert.Equal(t, map[string]interface{}{"owner": "team"}, s.CustomAnnotations["x-root"])
+	labels, ok := s.Properties["labels"].AdditionalProperties.(Schema)
+	assert.True(t, ok)
+	assert.Equal(t, 1, *labels.MinLength)
+	assert.Equal(t, "^[a-z]+$", s.PropertyNames.Pattern)
+	assert.Contains(t, s.Properties["ratio"].Extras, "minimum")
+	// only unknown keywords and values the fields can't represent are extras
+	assert.Equal(t, []string{"dependencies"}, slices.Sorted(maps.Keys(s.Extras)))
+	assert.Equal(t, []string{"contains", "items"}, slices.Sorted(maps.Keys(s.Properties["names"].Extras)))
+
+	// the known keywords are written like generated ones
+	written := strings.NewReplacer(`["array"]`, `"array"`, `, "uniqueItems": false`, "", "1.0", "1").Replace(original)
+	var buf bytes.Buffer
+	assert.NoError(t, s.WriteJSON(&buf))
+	assert.True(t, equalJSON([]byte(written), buf.Bytes()), buf.String())
+
+	jsonStr, err := s.Clone().ToJson()
+	assert.NoError(t, err)
+	assert.True(t, equalJSON([]byte(written), jsonStr), string(jsonStr))
+
+	// changes of the fields are written
+	s.Properties["names"].Type = StringOrArrayOfString{"array", "null"}
+	s.Properties["names"].Items = &Schema{Type: StringOrArrayOfString{"string"}}
+	jsonStr, err = s.ToJson()
+	assert.NoError(t, err)
+	var changed struct {
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	assert.NoError(t, json.Unmarshal(jsonStr, &changed))
+	assert.Equal(t, []interface{}{"array", "null"}, changed.Properties["names"]["type"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, changed.Properties["names"]["items"])
+
+	// generated schemas of objects still list their (empty) required keys
+	generated, err := (&Schema{Type: StringOrArrayOfString{"object"}}).ToJson()
+	assert.NoError(t, err)
+	assert.Contains(t, string(generated), `"required"`)
+
+	_, err = Load(strings.NewReader(`{"type": `))
+	assert.Error(t, err)
+}
+
+// equalJSON checks if both json documents have the same content, numbers are compared by their text
+func equalJSON(a, b []byte) bool {
+	decode := func(data []byte) (interface{}, error) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var value interface{}
+		err := decoder.Decode(&value)
+		return value, err
+	}
+	aValue, aErr := decode(a)
+	bValue, bErr := decode(b)
+	return aErr == nil && bErr == nil && reflect.DeepEqual(aValue, bValue)
+}
@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// ValueError is a value, which doesn't validate against the schema
+type ValueError struct {
+	// Path contains the keys of the value, items of lists are addressed by their index.
+	// It's empty for the root and for errors without a location.
+	Path []string
+	// Message describes the error
+	Message string
+}
+
+func (e ValueError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	return strings.Join(e.Path, ".") + ": " + e.Message
+}
+
+func (e ValueError) Error() string {
+	return e.String()
+}
+
+// ValueErrors splits an error of ValidateValues into the errors of the single values,
+// so they can be reported at their location (see Locate)
+func ValueErrors(err error) []ValueError {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var valueErrors []ValueError
+		for _, err := range joined.Unwrap() {
+			valueErrors = append(valueErrors, ValueErrors(err)...)
+		}
+		return valueErrors
+	}
+
+	// checks the validator can't do (e.g. unique keys) report their location themselves
+	if valueErr, ok := err.(ValueError); ok {
+		return []ValueError{valueErr}
+	}
+
+	// errors which only wrap a validation error (e.g. of embedded content) have their own message
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []ValueError{{Message: err.Error()}}
+	}
+	var valueErrors []ValueError
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			message := e.Error()
+			if _, rest, found := strings.Cut(message, "': "); found && strings.HasPrefix(message, "at '") {
+				message = rest
+			}
+			valueErrors = append(valueErrors, ValueError{Path: slices.Clone(e.InstanceLocation), Message: message})
+			return
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(validationErr)
+	return valueErrors
+}
+
+// Path returns the keys of the annotated key of the error, e.g. [hosts [] host] for hosts[].host
+func (e AnnotationError) Path() []string {
+	if e.Key == "" {
+		return nil
+	}
+	return pathSegments(e.Key)
+}
+
+// Locate returns the values file and the line of the key at the path (see ValueLine).
+// The values files are searched in reverse order, because later files override earlier ones.
+// The line is 0 if the key isn't found.
+func (r *Result) Locate(path []string) (string, int) {
+	files := r.ValuesFiles
+	if len(files) == 0 && r.ValuesPath != "" {
+		files = []string{r.ValuesPath}
+	}
+	for _, file := range slices.Backward(files) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			continue
+		}
+		if line := ValueLine(&node, path); line > 0 {
+			return file, line
+		}
+	}
+	return "", 0
+}
+
+// ValueLine returns the line of the key at the path in the yaml node, or of the deepest key of the
+// path which exists. Items of lists are addressed by their index, [] matches any item.
+// It returns 0 if the first key doesn't exist.
+func ValueLine(node *yaml.Node, path []string) int {
+	for node != nil && (node.Kind == yaml.DocumentNode || node.Kind == yaml.AliasNode) {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		} else if len(node.Content) > 0 {
+			node = node.Content[0]
+		} else {
+			return 0
+		}
+	}
+	if node == nil || len(path) == 0 {
+		return 0
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != path[0] {
+				continue
+			}
+			if line := ValueLine(node.Content[i+1], path[1:]); line > 0 {
+				return line
+			}
+			return node.Content[i].Line
+		}
+	case yaml.SequenceNode:
+		if path[0] == itemsSegment {
+			for _, item := range node.Content {
+				if line := ValueLine(item, path[1:]); line > 0 {
+					return line
+				}
+			}
+			return 0
+		}
+		index, err := strconv.Atoi(path[0])
+		if err != nil || index < 0 || index >= len(node.Content) {
+			return 0
+		}
+		if line := ValueLine(node.Content[index], path[1:]); line > 0 {
+			return line
+		}
+		return node.Content[index].Line
+	}
+	return 0
+}
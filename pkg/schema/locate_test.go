@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValueErrors(t *testing.T) {
+	s := selfCheckSchema(t, "replicas: 1\nimage:\n  tag: v1\n")
+	err := s.ValidateValues([]byte("replicas: two\nimage:\n  tag: 1\n"), "values.schema.json")
+
+	assert.ElementsMatch(t, []ValueError{
+		{Path: []string{"replicas"}, Message: "got string, want integer"},
+		{Path: []string{"image", "tag"}, Message: "got number, want string"},
+	}, ValueErrors(err))
+	assert.Equal(t, "image.tag: got number, want string", ValueError{Path: []string{"image", "tag"}, Message: "got number, want string"}.String())
+	assert.Nil(t, ValueErrors(nil))
+}
+
+func TestValueLine(t *testing.T) {
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(`replicas: 1
+image:
+  tag: v1
+hosts:
+  - name: a
+  - name: b
+    port: 80
+`), &node))
+
+	tests := []struct {
+		path     []string
+		expected int
+	}{
+		{[]string{"replicas"}, 1},
+		{[]string{"image", "tag"}, 3},
+		{[]string{"image", "missing"}, 2},
+		{[]string{"hosts", "1", "port"}, 7},
+		{[]string{"hosts", "[]", "port"}, 7},
+		{[]string{"hosts", "5"}, 4},
+		{[]string{"missing"}, 0},
+		{nil, 0},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, ValueLine(&node, test.path), test.path)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "values.yaml")
+	prod := filepath.Join(dir, "values-prod.yaml")
+	assert.NoError(t, os.WriteFile(base, []byte("replicas: 1\nimage:\n  tag: v1\n"), 0o644))
+	assert.NoError(t, os.WriteFile(prod, []byte("image:\n  tag: v2\n"), 0o644))
+
+	result := &Result{ValuesPath: base, ValuesFiles: []string{base, prod}}
+	file, line := result.Locate([]string{"image", "tag"})
+	assert.Equal(t, prod, file)
+	assert.Equal(t, 2, line)
+
+	file, line = result.Locate(AnnotationError{Key: "replicas"}.Path())
+	assert.Equal(t, base, file)
+	assert.Equal(t, 1, line)
+
+	_, line = result.Locate([]string{"missing"})
+	assert.Equal(t, 0, line)
+}
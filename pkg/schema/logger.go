@@ -0,0 +1,18 @@
+package schema
+
+import (
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// logger receives the log messages of the generations without their own logger and of the
+// functions outside of a generation (e.g. RebaseFileRefs)
+var logger = logging.NewLogrus(logrus.StandardLogger())
+
+// SetLogger sets the default logger, by default the standard logger of logrus is used. It is
+// used by the generations without GenerationPolicy.Logger and by the functions outside of a
+// generation. It is not safe to change the default logger while schemas are generated, set
+// GenerationPolicy.Logger to use another logger for a generation.
+func SetLogger(l logging.Logger) {
+	logger = l
+}
@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLogger(t *testing.T) {
+	recorder := logging.NewRecorder()
+	defer SetLogger(logger)
+	SetLogger(recorder)
+
+	tmpDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "Chart.yaml"), []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("# @schema\n# type: string\n# @schema\n\nkey: {nested: value}\n"), 0o644))
+
+	result := generateResult(context.Background(), filepath.Join(tmpDir, "Chart.yaml"), DefaultOutputFile, testGeneration(t, GenerationPolicy{DontAddGlobal: true}))
+	assert.Empty(t, result.Errors)
+
+	warnings := recorder.Messages(logging.LevelWarn)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "flow-style mapping key")
+
+	// the orphan annotation is a warning of the result, so it can be promoted to an error
+	assert.Len(t, result.Warnings, 1)
+	assert.Equal(t, WarningOrphanAnnotation, result.Warnings[0].Category)
+	assert.Equal(t, filepath.Join(tmpDir, "values.yaml"), result.Warnings[0].File)
+	assert.Contains(t, result.Warnings[0].Message, "ignoring @schema annotation")
+}
+
+func TestGenerationPolicyLogger(t *testing.T) {
+	defaultRecorder := logging.NewRecorder()
+	defer SetLogger(logger)
+	SetLogger(defaultRecorder)
+
+	tmpDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "Chart.yaml"), []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("# @schema\n# type: string\n# @schema\n\nkey: {nested: value}\n"), 0o644))
+
+	// the messages of a generation with its own logger don't reach the default logger
+	recorders := []*logging.Recorder{logging.NewRecorder(), logging.NewRecorder()}
+	done := make(chan Result, len(recorders))
+	for _, recorder := range recorders {
+		gen := testGeneration(t, GenerationPolicy{DontAddGlobal: true, Logger: recorder})
+		go func() {
+			done <- generateResult(context.Background(), filepath.Join(tmpDir, "Chart.yaml"), DefaultOutputFile, gen)
+		}()
+	}
+	for range recorders {
+		assert.Empty(t, (<-done).Errors)
+	}
+
+	for _, recorder := range recorders {
+		warnings := recorder.Messages(logging.LevelWarn)
+		assert.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "flow-style mapping key")
+	}
+	assert.Empty(t, defaultRecorder.Entries())
+}
@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Media types detected by DetectContentMediaTypes
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeYAML = "application/yaml"
+	MediaTypePEM  = "application/x-pem-file"
+)
+
+// isBlockScalar checks if the node is a literal (|) or folded (>) block scalar
+func isBlockScalar(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0
+}
+
+// DetectContentMediaTypes recursively adds contentMediaType to the strings which are block scalars
+// (| or >) in the values file and contain json, yaml or pem data, so editors can treat them as
+// embedded files. Other strings (e.g. quoted single line strings) stay plain strings.
+// Annotated media types are kept.
+func (s *Schema) DetectContentMediaTypes() {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if v.blockScalarValue == "" || v.ContentMediaType != "" || !v.Type.allowsConstraintsOf("string") {
+			return nil
+		}
+		v.ContentMediaType = detectMediaType(v.blockScalarValue)
+		return nil
+	})
+}
+
+// detectMediaType returns the media type of the content or an empty string if it's plain text
+func detectMediaType(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "-----BEGIN ") && strings.Contains(trimmed, "-----END ") {
+		return MediaTypePEM
+	}
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return MediaTypeJSON
+	}
+
+	// plain text is a valid yaml string as well, so only maps and lists count as yaml
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil || len(document.Content) != 1 {
+		return ""
+	}
+	if kind := document.Content[0].Kind; kind == yaml.MappingNode || kind == yaml.SequenceNode {
+		return MediaTypeYAML
+	}
+	return ""
+}
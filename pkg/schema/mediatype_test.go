@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDetectContentMediaTypes(t *testing.T) {
+	yamlContent := `json: |
+  {"key": "value"}
+yaml: |
+  server:
+    port: 80
+pem: |
+  -----BEGIN CERTIFICATE-----
+  MIIBszCCAVmgAwIBAgIUX
+  -----END CERTIFICATE-----
+text: |
+  Just some text
+  over two lines
+folded: >
+  - a
+  - b
+quoted: "{\"key\": \"value\"}"
+# @schema
+# type: string
+# contentMediaType: text/x-shellscript
+# @schema
+annotated: |
+  {"key": "value"}
+list:
+  - |
+    key: value
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	schema.DetectContentMediaTypes()
+
+	expected := map[string]string{
+		"json":      MediaTypeJSON,
+		"yaml":      MediaTypeYAML,
+		"pem":       MediaTypePEM,
+		"text":      "",
+		"folded":    MediaTypeYAML,
+		"quoted":    "",
+		"annotated": "text/x-shellscript",
+	}
+	for key, mediaType := range expected {
+		assert.Equal(t, mediaType, schema.Properties[key].ContentMediaType, key)
+		assert.Equal(t, StringOrArrayOfString{"string"}, schema.Properties[key].Type, key)
+	}
+	assert.Equal(t, MediaTypeYAML, schema.Properties["list"].Items.AnyOf[0].ContentMediaType)
+}
+
+func TestContentMediaTypesAreOptional(t *testing.T) {
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte("json: |\n  {\"key\": \"value\"}\n"), &node))
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	data, err := schema.ToJson()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "contentMediaType")
+}
@@ -0,0 +1,43 @@
+package schema
+
+import "slices"
+
+// canInferNullableType checks if the type of a nullable value can be inferred from its value.
+// Values with a $ref, an enum, a const or subschemas get null added to those instead.
+func (s *Schema) canInferNullableType() bool {
+	return s.Type.IsEmpty() && s.Ref == "" && s.Enum == nil && s.Const == nil && !s.constWasSet &&
+		len(s.AnyOf) == 0 && len(s.OneOf) == 0 && len(s.AllOf) == 0
+}
+
+// makeNullable allows null in addition to the values the schema allows (nullable: true).
+// Null is added to the type and the enum. A const becomes an enum of the const and null,
+// a $ref or allOf is wrapped into an anyOf with a null schema, because draft-07 ignores the
+// keywords next to a $ref. A schema without any of these allows null already.
+func (s *Schema) makeNullable() {
+	if !s.Type.IsEmpty() && !slices.Contains(s.Type, "null") {
+		s.Type = append(s.Type, "null")
+	}
+
+	if s.Enum != nil && !slices.Contains(s.Enum, nil) {
+		s.Enum = append(s.Enum, nil)
+	}
+	if s.Const != nil {
+		s.Enum = []interface{}{s.Const, nil}
+		s.Const = nil
+		s.constWasSet = false
+	}
+
+	nullSchema := &Schema{Type: StringOrArrayOfString{"null"}}
+	switch {
+	case s.Ref != "":
+		s.AnyOf = append(s.AnyOf, &Schema{Ref: s.Ref}, nullSchema)
+		s.Ref = ""
+	case len(s.AllOf) > 0:
+		s.AnyOf = append(s.AnyOf, &Schema{AllOf: s.AllOf}, nullSchema)
+		s.AllOf = nil
+	case len(s.AnyOf) > 0:
+		s.AnyOf = append(s.AnyOf, nullSchema)
+	case len(s.OneOf) > 0:
+		s.OneOf = append(s.OneOf, nullSchema)
+	}
+}
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the options of YamlToSchemaWithOptions. The zero value generates a
+// draft-07 schema like helm-schema without any flags does.
+type Options struct {
+	// ValuesPath is the path of the values file, relative references are resolved from it
+	ValuesPath string
+	// Policy controls the generation of the schema
+	Policy GenerationPolicy
+	// Draft is the $schema uri of the draft of the schema, Draft07URI (default) or Draft202012URI
+	Draft string
+}
+
+// YamlToSchemaWithOptions generates the schema of the parsed values file. Unlike YamlToSchema,
+// new options are added to Options, so the callers don't break.
+func YamlToSchemaWithOptions(node *yaml.Node, opts Options) (*Schema, error) {
+	if opts.Draft != "" && opts.Draft != Draft07URI && opts.Draft != Draft202012URI {
+		return nil, fmt.Errorf("unsupported draft %s (supported: %s, %s)", opts.Draft, Draft07URI, Draft202012URI)
+	}
+
+	gen, err := opts.Policy.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := yamlToSchema(opts.ValuesPath, node, gen, gen.skip, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Draft == Draft202012URI {
+		s = s.ToDraft202012()
+	}
+	return s, nil
+}
@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYamlToSchemaWithOptions(t *testing.T) {
+	values := `# @schema
+# $ref: "#/definitions/port"
+# definitions:
+#   port:
+#     type: integer
+# @schema
+port: 80
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+
+	skipConfig, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	expected := YamlToSchema("values.yaml", &node, false, false, false, false, skipConfig, nil, nil)
+
+	s, err := YamlToSchemaWithOptions(&node, Options{ValuesPath: "values.yaml"})
+	assert.NoError(t, err)
+	assert.True(t, expected.EqualsOpt(s, EqualsFull))
+	assert.Equal(t, Draft07URI, s.Schema)
+
+	s, err = YamlToSchemaWithOptions(&node, Options{ValuesPath: "values.yaml", Draft: Draft202012URI})
+	assert.NoError(t, err)
+	assert.True(t, expected.ToDraft202012().EqualsOpt(s, EqualsFull))
+	assert.Equal(t, Draft202012URI, s.Schema)
+
+	s, err = YamlToSchemaWithOptions(&node, Options{Policy: GenerationPolicy{DontAddGlobal: true}})
+	assert.NoError(t, err)
+	assert.NotContains(t, s.Properties, "global")
+
+	_, err = YamlToSchemaWithOptions(&node, Options{Draft: "https://json-schema.org/draft/2019-09/schema"})
+	assert.ErrorContains(t, err, "unsupported draft")
+	_, err = YamlToSchemaWithOptions(&node, Options{Policy: GenerationPolicy{SkipAutoGeneration: []string{"color"}}})
+	assert.Error(t, err)
+}
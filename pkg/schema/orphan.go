@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OrphanAnnotation describes a @schema block which isn't attached to any key,
+// e.g. because the key was removed or renamed
+type OrphanAnnotation struct {
+	Line   int
+	Column int
+	Reason string
+}
+
+// hasSchemaAnnotation checks if the comment contains a @schema marker line.
+// @schema.root markers are not considered.
+func hasSchemaAnnotation(comment string) bool {
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.TrimSpace(line) == strings.TrimSpace(SchemaPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindOrphanAnnotations walks the yaml tree and returns all @schema blocks which
+// will be ignored during the schema generation. This covers blocks at the end of a
+// mapping or document (foot comments), blocks attached to sequence items and blocks
+// which are separated from their key by an empty line (unless keepFullComment is set).
+// The content is the yaml the tree was parsed from, the blocks are reported with the
+// line of their @schema marker in it (or the line of their node, if it's nil).
+func FindOrphanAnnotations(node *yaml.Node, content []byte, keepFullComment bool) []OrphanAnnotation {
+	var leadingComment *regexp.Regexp
+	if !keepFullComment {
+		leadingComment = regexp.MustCompile(DefaultLeadingCommentPattern)
+	}
+	return findOrphanAnnotations(node, content, leadingComment)
+}
+
+// findOrphanAnnotations is FindOrphanAnnotations with the pattern of the leading part
+// of the comments, which is cut (nil if the full comments are kept)
+func findOrphanAnnotations(node *yaml.Node, content []byte, leadingComment *regexp.Regexp) []OrphanAnnotation {
+	var orphans []OrphanAnnotation
+	lines := strings.Split(string(content), "\n")
+
+	var walk func(n *yaml.Node, parent *yaml.Node)
+	walk = func(n *yaml.Node, parent *yaml.Node) {
+		if n == nil {
+			return
+		}
+
+		if hasSchemaAnnotation(n.FootComment) {
+			// the foot comment follows the node and its children, maybe after empty lines
+			orphans = append(orphans, OrphanAnnotation{
+				Line:   commentLine(lines, n.FootComment, lastLine(n)+1, n.Line),
+				Column: n.Column,
+				Reason: "annotation block is not directly followed by a key",
+			})
+		}
+
+		if hasSchemaAnnotation(n.LineComment) {
+			orphans = append(orphans, OrphanAnnotation{
+				Line:   n.Line,
+				Column: n.Column,
+				Reason: "annotation block is placed behind a value",
+			})
+		}
+
+		if hasSchemaAnnotation(n.HeadComment) {
+			// the head comment of a key ends right above it, the one of a document is its first comment
+			from := n.Line - strings.Count(n.HeadComment, "\n") - 1
+			if n.Kind == yaml.DocumentNode {
+				from = 1
+			}
+			line := commentLine(lines, n.HeadComment, from, n.Line)
+
+			switch {
+			case n.Kind == yaml.DocumentNode:
+				orphans = append(orphans, OrphanAnnotation{
+					Line:   line,
+					Column: n.Column,
+					Reason: "annotation block is separated from the first key by an empty line",
+				})
+			case parent != nil && parent.Kind == yaml.SequenceNode:
+				orphans = append(orphans, OrphanAnnotation{
+					Line:   line,
+					Column: n.Column,
+					Reason: "annotation block is attached to a sequence item instead of a key",
+				})
+			case leadingComment != nil &&
+				hasSchemaAnnotation(strings.Join(leadingComment.FindAllString(n.HeadComment, -1), "")):
+				orphans = append(orphans, OrphanAnnotation{
+					Line:   line,
+					Column: n.Column,
+					Reason: "annotation block is separated from its key by an empty line",
+				})
+			}
+		}
+
+		for _, child := range n.Content {
+			walk(child, n)
+		}
+	}
+
+	walk(node, nil)
+	return orphans
+}
+
+// commentLine returns the line (starting with 1) of the first @schema marker of the comment in the
+// lines of the yaml, searching from the line from. If the comment isn't found, it returns fallback.
+func commentLine(lines []string, comment string, from, fallback int) int {
+	commentLines := strings.Split(comment, "\n")
+	marker := slices.IndexFunc(commentLines, func(line string) bool {
+		return strings.TrimSpace(line) == strings.TrimSpace(SchemaPrefix)
+	})
+	for start := max(from, 1) - 1; start+len(commentLines) <= len(lines); start++ {
+		if slices.EqualFunc(lines[start:start+len(commentLines)], commentLines, func(a, b string) bool {
+			return strings.TrimSpace(a) == strings.TrimSpace(b)
+		}) {
+			return start + 1 + marker
+		}
+	}
+	return fallback
+}
+
+// lastLine returns the last line of the node and its children, which starts a node
+func lastLine(n *yaml.Node) int {
+	line := n.Line
+	for _, child := range n.Content {
+		line = max(line, lastLine(child))
+	}
+	return line
+}
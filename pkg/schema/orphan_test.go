@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFindOrphanAnnotations(t *testing.T) {
+	tests := []struct {
+		name            string
+		yamlContent     string
+		keepFullComment bool
+		expectedLines   []int
+	}{
+		{
+			name: "attached annotation",
+			yamlContent: `# @schema
+# type: string
+# @schema
+foo: bar
+`,
+		},
+		{
+			name: "annotation at end of file",
+			yamlContent: `foo: bar
+
+# @schema
+# type: string
+# @schema
+`,
+			expectedLines: []int{3},
+		},
+		{
+			name: "annotation attached to a sequence item",
+			yamlContent: `foo:
+  # @schema
+  # type: string
+  # @schema
+  - bar
+`,
+			expectedLines: []int{2},
+		},
+		{
+			name: "annotation separated by empty line",
+			yamlContent: `foo: bar
+
+# @schema
+# type: string
+# @schema
+
+# description
+baz: bar
+`,
+			expectedLines: []int{3},
+		},
+		{
+			name: "annotation separated by empty line with full comment",
+			yamlContent: `foo: bar
+
+# @schema
+# type: string
+# @schema
+
+# description
+baz: bar
+`,
+			keepFullComment: true,
+		},
+		{
+			name: "annotation below a key",
+			yamlContent: `foo: bar
+# @schema
+# type: string
+# @schema
+
+baz: bar
+`,
+			expectedLines: []int{2},
+		},
+		{
+			name: "annotation separated from the first key",
+			yamlContent: `# @schema
+# type: string
+# @schema
+
+foo: bar
+`,
+			expectedLines: []int{1},
+		},
+		{
+			name: "orphan after an identical attached annotation",
+			yamlContent: `# @schema
+# type: string
+# @schema
+foo:
+  # @schema
+  # type: string
+  # @schema
+  bar: baz
+
+  # @schema
+  # type: string
+  # @schema
+`,
+			expectedLines: []int{10},
+		},
+		{
+			name: "root annotation is ignored",
+			yamlContent: `# @schema.root
+# title: foo
+# @schema.root
+foo: bar
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.yamlContent), &node); err != nil {
+				t.Fatalf("Failed to parse YAML: %v", err)
+			}
+
+			orphans := FindOrphanAnnotations(&node, []byte(tt.yamlContent), tt.keepFullComment)
+			if len(orphans) != len(tt.expectedLines) {
+				t.Fatalf("Expected %d orphans, got %d: %+v", len(tt.expectedLines), len(orphans), orphans)
+			}
+			for i, orphan := range orphans {
+				if orphan.Line != tt.expectedLines[i] {
+					t.Errorf("Expected orphan at line %d, got %d", tt.expectedLines[i], orphan.Line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultOutputFile is the output file of the schemas, which helm validates the values against
+const DefaultOutputFile = "values.schema.json"
+
+// OutputPathData is passed to the output file template
+type OutputPathData struct {
+	// ChartDir is the directory of the chart
+	ChartDir string
+	// ChartName is the name of the chart
+	ChartName string
+	// ChartVersion is the version of the chart
+	ChartVersion string
+	// ValuesFile is the name of the values file, e.g. values.yaml (empty if the chart has none)
+	ValuesFile string
+}
+
+// IsOutputTemplate checks if the output file contains a template
+func IsOutputTemplate(outFile string) bool {
+	return strings.Contains(outFile, "{{")
+}
+
+// OutputPath returns the path the schema of the result is written to.
+// A plain outFile is relative to the chart directory. A template
+// (e.g. "schemas/{{ .ChartName }}-{{ .ChartVersion }}.json") is rendered and used as is.
+// The schemas of the other values files of a chart with the separate-schemas strategy
+// are written next to outFile and named after their values file (see SeparateSchemaName).
+func OutputPath(result *Result, outFile string) (string, error) {
+	chartDir := filepath.Dir(result.ChartPath)
+
+	if !IsOutputTemplate(outFile) {
+		if result.Secondary {
+			return filepath.Join(chartDir, filepath.Dir(outFile), SeparateSchemaName(result.ValuesPath)), nil
+		}
+		return filepath.Join(chartDir, outFile), nil
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(outFile)
+	if err != nil {
+		return "", fmt.Errorf("invalid output file template: %w", err)
+	}
+
+	data := OutputPathData{ChartDir: chartDir}
+	if result.ValuesPath != "" {
+		data.ValuesFile = filepath.Base(result.ValuesPath)
+	}
+	if result.Chart != nil {
+		data.ChartName = result.Chart.Name
+		data.ChartVersion = result.Chart.Version
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("could not render output file template: %w", err)
+	}
+
+	path := strings.TrimSpace(buf.String())
+	if path == "" {
+		return "", fmt.Errorf("output file template %q rendered an empty path", outFile)
+	}
+	return filepath.Clean(path), nil
+}
+
+// schemaReference returns the path of the schema of the result (see OutputPath) relative to the
+// values file at valuesPath, which is referenced by the modeline of the yaml language server.
+func schemaReference(result *Result, outFile, valuesPath string) (string, error) {
+	outPath, err := OutputPath(result, outFile)
+	if err != nil {
+		return "", err
+	}
+	outPath, err = filepath.Abs(outPath)
+	if err != nil {
+		return "", err
+	}
+	valuesDir, err := filepath.Abs(filepath.Dir(valuesPath))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(valuesDir, outPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
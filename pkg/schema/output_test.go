@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputPath(t *testing.T) {
+	result := &Result{
+		ChartPath:  filepath.Join("charts", "app", "Chart.yaml"),
+		ValuesPath: filepath.Join("charts", "app", "values.yaml"),
+		Chart:      &chart.ChartFile{Name: "app", Version: "1.2.3"},
+	}
+
+	tests := []struct {
+		outFile     string
+		expected    string
+		expectedErr bool
+	}{
+		{outFile: "values.schema.json", expected: filepath.Join("charts", "app", "values.schema.json")},
+		{outFile: "schemas/values.schema.json", expected: filepath.Join("charts", "app", "schemas", "values.schema.json")},
+		{outFile: "{{ .ChartDir }}/schemas/values.schema.json", expected: filepath.Join("charts", "app", "schemas", "values.schema.json")},
+		{outFile: "schemas/{{ .ChartName }}-{{ .ChartVersion }}.json", expected: filepath.Join("schemas", "app-1.2.3.json")},
+		{outFile: "{{ .ChartDir }}/{{ .ValuesFile }}.schema.json", expected: filepath.Join("charts", "app", "values.yaml.schema.json")},
+		{outFile: "{{ .ChartName", expectedErr: true},
+		{outFile: "{{ .Unknown }}.json", expectedErr: true},
+		{outFile: "{{ if false }}x{{ end }}", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.outFile, func(t *testing.T) {
+			path, err := OutputPath(result, tt.outFile)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, path)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override patches the subschema at a path of a generated schema
+type Override struct {
+	// Path is a dotted path of keys or a JSON pointer (see Query)
+	Path string
+	// Patch is the schema (json or yaml) with the fields to set
+	Patch []byte
+}
+
+func (o Override) String() string {
+	return fmt.Sprintf("%s=%s", o.Path, o.Patch)
+}
+
+// ParseOverride parses an override of the form <path>=<schema>, e.g. ingress.host={"format":"hostname"}
+func ParseOverride(override string) (Override, error) {
+	path, patch, ok := strings.Cut(override, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" || strings.TrimSpace(patch) == "" {
+		return Override{}, fmt.Errorf("invalid override %q, expected <path>=<schema>", override)
+	}
+
+	var s Schema
+	if err := yaml.Unmarshal([]byte(patch), &s); err != nil {
+		return Override{}, fmt.Errorf("invalid schema in override of %s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return Override{}, fmt.Errorf("invalid schema in override of %s: %w", path, err)
+	}
+
+	return Override{Path: path, Patch: []byte(patch)}, nil
+}
+
+// ApplyOverride sets the fields of the patch in the subschema at the path of the override,
+// all other fields of the subschema are kept
+func (s *Schema) ApplyOverride(o Override) error {
+	target, writeBack, err := s.queryAddressable(o.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := patchSchema(target, o.Patch); err != nil {
+		return fmt.Errorf("could not apply the override of %s: %w", o.Path, err)
+	}
+	if err := target.Validate(); err != nil {
+		return fmt.Errorf("the override of %s results in an invalid schema: %w", o.Path, err)
+	}
+	writeBack()
+
+	// the patched subschema must be part of the schema, otherwise the override is lost
+	if patched, err := s.Query(o.Path); err != nil || !patched.Equals(target) {
+		return fmt.Errorf("could not apply the override of %s, the subschema can't be changed in place", o.Path)
+	}
+	return nil
+}
+
+// patchSchema sets the fields of the patch (a yaml or json schema) in the schema, all other fields are kept
+func patchSchema(s *Schema, patch []byte) error {
+	// the custom annotations are replaced by unmarshaling, unless the patch sets them they're kept
+	customAnnotations := s.CustomAnnotations
+	if err := yaml.Unmarshal(patch, s); err != nil {
+		return err
+	}
+	for key, value := range customAnnotations {
+		if _, ok := s.CustomAnnotations[key]; !ok {
+			s.CustomAnnotations[key] = value
+		}
+	}
+	return nil
+}
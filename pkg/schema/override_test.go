@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOverride(t *testing.T) {
+	override, err := ParseOverride(`ingress.host={"format":"hostname"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "ingress.host", override.Path)
+	assert.Equal(t, `{"format":"hostname"}`, string(override.Patch))
+
+	// the schema may contain = and be yaml
+	override, err = ParseOverride("image.tag=pattern: ^v=")
+	assert.NoError(t, err)
+	assert.Equal(t, "image.tag", override.Path)
+
+	for _, invalid := range []string{"ingress.host", "={}", "ingress.host=", `ingress.host={"type": "strin"}`, "ingress.host={"} {
+		_, err := ParseOverride(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestApplyOverride(t *testing.T) {
+	s := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"ingress": {
+				Type: StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{
+					"host": {
+						Type:              StringOrArrayOfString{"string"},
+						Description:       "the host",
+						CustomAnnotations: map[string]interface{}{"x-order": 1},
+					},
+				},
+			},
+		},
+	}
+
+	override, err := ParseOverride(`ingress.host={"format": "hostname", "maxLength": 253, "x-example": "example.com"}`)
+	assert.NoError(t, err)
+	assert.NoError(t, s.ApplyOverride(override))
+
+	host := s.Properties["ingress"].Properties["host"]
+	assert.Equal(t, "hostname", host.Format)
+	assert.Equal(t, 253, *host.MaxLength)
+	assert.Equal(t, "the host", host.Description)
+	assert.Equal(t, StringOrArrayOfString{"string"}, host.Type)
+	assert.Equal(t, map[string]interface{}{"x-order": 1, "x-example": "example.com"}, host.CustomAnnotations)
+
+	override, err = ParseOverride(`ingress.port={"minimum": 1}`)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, s.ApplyOverride(override), "could not find ingress.port")
+
+	// the patch is valid, but not together with the existing fields
+	override, err = ParseOverride(`ingress.host={"minimum": 1}`)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, s.ApplyOverride(override), "results in an invalid schema")
+}
+
+func TestApplyOverrideBelowAdditionalProperties(t *testing.T) {
+	s := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"env": {
+				Type:                 StringOrArrayOfString{"object"},
+				AdditionalProperties: Schema{Type: StringOrArrayOfString{"string"}},
+			},
+			"labels": {Ref: "#/$defs/labels"},
+		},
+		Defs: map[string]*Schema{
+			"labels": {
+				Type: StringOrArrayOfString{"object"},
+				AdditionalProperties: Schema{
+					Type:                 StringOrArrayOfString{"object"},
+					AdditionalProperties: Schema{Type: StringOrArrayOfString{"string"}},
+				},
+			},
+		},
+	}
+
+	override, err := ParseOverride(`/properties/env/additionalProperties={"minLength": 3}`)
+	assert.NoError(t, err)
+	assert.NoError(t, s.ApplyOverride(override))
+	env := s.Properties["env"].AdditionalProperties.(Schema)
+	assert.Equal(t, 3, *env.MinLength)
+	assert.Equal(t, StringOrArrayOfString{"string"}, env.Type)
+
+	// nested additionalProperties behind a reference
+	override, err = ParseOverride(`/properties/labels/additionalProperties/additionalProperties={"maxLength": 63}`)
+	assert.NoError(t, err)
+	assert.NoError(t, s.ApplyOverride(override))
+	labels := s.Defs["labels"].AdditionalProperties.(Schema).AdditionalProperties.(Schema)
+	assert.Equal(t, 63, *labels.MaxLength)
+
+	// an invalid patch leaves the schema unchanged
+	override, err = ParseOverride(`/properties/env/additionalProperties={"minimum": 1}`)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, s.ApplyOverride(override), "results in an invalid schema")
+	assert.Nil(t, s.Properties["env"].AdditionalProperties.(Schema).Minimum)
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PartialSchemaFile is the value contract a library chart ships next to its Chart.yaml. It describes
+// the values its named templates expect and is imported into the schemas of the charts using it.
+const PartialSchemaFile = "values.schema.partial.json"
+
+// LibraryPrefix is a key path the value contract of a library chart is imported under, e.g. common=app.common
+type LibraryPrefix struct {
+	// Library is the name of the library chart
+	Library string
+	// Prefix is the dotted path of the key, empty for the root of the values
+	Prefix string
+}
+
+func (p LibraryPrefix) String() string {
+	return fmt.Sprintf("%s=%s", p.Library, p.Prefix)
+}
+
+// ParseLibraryPrefix parses a prefix of the form <library>=<dotted key path>, an empty path is the root
+func ParseLibraryPrefix(prefix string) (LibraryPrefix, error) {
+	library, path, ok := strings.Cut(prefix, "=")
+	p := LibraryPrefix{Library: strings.TrimSpace(library), Prefix: strings.TrimSpace(path)}
+	if !ok || p.Library == "" {
+		return LibraryPrefix{}, fmt.Errorf("invalid library prefix %q, expected <library>=<key path>", prefix)
+	}
+	if strings.Contains(p.Prefix, "[]") {
+		return LibraryPrefix{}, fmt.Errorf("invalid library prefix %q, keys within lists are not supported", prefix)
+	}
+	if p.Prefix != "" && slices.Contains(strings.Split(p.Prefix, "."), "") {
+		return LibraryPrefix{}, fmt.Errorf("invalid library prefix %q, the path contains an empty key", prefix)
+	}
+	return p, nil
+}
+
+// ReadPartialSchema reads the value contract of the library chart in the directory, nil if it has none
+func ReadPartialSchema(chartDir string) (*Schema, error) {
+	return ReadSchemaFile(filepath.Join(chartDir, PartialSchemaFile))
+}
+
+// ImportPartial imports the value contract of a library chart under the dotted key path (the root if
+// empty), missing keys of the path are added as objects. The properties and patternProperties of the
+// contract replace the generated ones, unless these were annotated. Its required keys are added and
+// its definitions are merged into the root, existing definitions are kept. It returns the dotted
+// paths of the annotated properties and the names of the definitions which were kept.
+func (s *Schema) ImportPartial(partial *Schema, prefix string) ([]string, error) {
+	contract := partial.Clone()
+	target := s
+	pointer := ""
+	var path []string
+	if prefix != "" {
+		path = strings.Split(prefix, ".")
+	}
+	for i, key := range path {
+		if target.Properties == nil {
+			target.Properties = make(map[string]*Schema)
+		}
+		next, ok := target.Properties[key]
+		if !ok {
+			next = &Schema{Type: StringOrArrayOfString{"object"}, Title: key}
+			target.Properties[key] = next
+		}
+		if !next.Type.IsEmpty() && !next.Type.allowsConstraintsOf("object") {
+			return nil, fmt.Errorf("cannot import the value contract under %s, %s is not an object", prefix, dottedPath(path[:i+1]))
+		}
+		target = next
+		pointer += PropertyPointer(key)
+	}
+
+	// internal references of the contract are relative to its own root
+	contract.RebaseInternalRefs(pointer)
+
+	var kept []string
+	for _, definitions := range []struct {
+		from map[string]*Schema
+		to   *map[string]*Schema
+	}{{contract.Defs, &s.Defs}, {contract.Definitions, &s.Definitions}} {
+		for _, name := range sortedSchemaKeys(definitions.from) {
+			if existing, ok := (*definitions.to)[name]; ok {
+				if !existing.EqualsOpt(definitions.from[name], EqualsFull) {
+					kept = append(kept, name)
+				}
+				continue
+			}
+			if *definitions.to == nil {
+				*definitions.to = make(map[string]*Schema)
+			}
+			(*definitions.to)[name] = definitions.from[name]
+		}
+	}
+
+	for _, properties := range []struct {
+		from      map[string]*Schema
+		to        *map[string]*Schema
+		annotated bool
+	}{{contract.Properties, &target.Properties, false}, {contract.PatternProperties, &target.PatternProperties, true}} {
+		for _, name := range sortedSchemaKeys(properties.from) {
+			// patternProperties are always annotated
+			if existing, ok := (*properties.to)[name]; ok && (properties.annotated || existing.HasData) {
+				kept = append(kept, dottedPath(append(slices.Clone(path), name)))
+				continue
+			}
+			if *properties.to == nil {
+				*properties.to = make(map[string]*Schema)
+			}
+			(*properties.to)[name] = properties.from[name]
+		}
+	}
+
+	for _, name := range contract.Required.Strings {
+		if !slices.Contains(target.Required.Strings, name) {
+			target.Required.Strings = append(target.Required.Strings, name)
+		}
+	}
+	return kept, nil
+}
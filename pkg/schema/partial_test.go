@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLibraryPrefix(t *testing.T) {
+	prefix, err := ParseLibraryPrefix("common=app.common")
+	assert.NoError(t, err)
+	assert.Equal(t, LibraryPrefix{Library: "common", Prefix: "app.common"}, prefix)
+	assert.Equal(t, "common=app.common", prefix.String())
+
+	prefix, err = ParseLibraryPrefix("common=")
+	assert.NoError(t, err)
+	assert.Equal(t, LibraryPrefix{Library: "common"}, prefix)
+
+	for _, invalid := range []string{"common", "=app", "common=app..name", "common=app.", "common=containers[].env"} {
+		_, err := ParseLibraryPrefix(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestReadPartialSchema(t *testing.T) {
+	dir := t.TempDir()
+	partial, err := ReadPartialSchema(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, partial)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, PartialSchemaFile), []byte(`{"type": "object", "properties": {"nameOverride": {"type": "string"}}}`), 0o644))
+	partial, err = ReadPartialSchema(dir)
+	assert.NoError(t, err)
+	assert.Contains(t, partial.Properties, "nameOverride")
+}
+
+func testContract() *Schema {
+	return &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"nameOverride":     {Type: StringOrArrayOfString{"string"}},
+			"fullnameOverride": {Ref: "#/properties/nameOverride"},
+			"labels":           {Ref: "#/$defs/labels"},
+		},
+		PatternProperties: map[string]*Schema{"^x-": {}},
+		Required:          BoolOrArrayOfString{Strings: []string{"labels"}},
+		Defs:              map[string]*Schema{"labels": {Type: StringOrArrayOfString{"object"}}},
+	}
+}
+
+func TestImportPartialAtRoot(t *testing.T) {
+	s := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"nameOverride": {Type: StringOrArrayOfString{"string"}, Description: "annotated", HasData: true},
+			"labels":       {Type: StringOrArrayOfString{"object"}},
+		},
+		Required: BoolOrArrayOfString{Strings: []string{"nameOverride"}},
+		Defs:     map[string]*Schema{"labels": {Type: StringOrArrayOfString{"array"}}},
+	}
+	kept, err := s.ImportPartial(testContract(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"labels", "nameOverride"}, kept)
+
+	// annotated properties and existing definitions take precedence over the contract
+	assert.Equal(t, "annotated", s.Properties["nameOverride"].Description)
+	assert.Equal(t, StringOrArrayOfString{"array"}, s.Defs["labels"].Type)
+	// generated properties are replaced
+	assert.Equal(t, "#/$defs/labels", s.Properties["labels"].Ref)
+	assert.Equal(t, "#/properties/nameOverride", s.Properties["fullnameOverride"].Ref)
+	assert.Contains(t, s.PatternProperties, "^x-")
+	assert.Equal(t, []string{"nameOverride", "labels"}, s.Required.Strings)
+}
+
+func TestImportPartialUnderPrefix(t *testing.T) {
+	s := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"app": {Type: StringOrArrayOfString{"object"}, Properties: map[string]*Schema{"image": {Type: StringOrArrayOfString{"string"}}}},
+		},
+	}
+	contract := testContract()
+	kept, err := s.ImportPartial(contract, "app.common")
+	assert.NoError(t, err)
+	assert.Empty(t, kept)
+	// the contract itself is not changed
+	assert.Equal(t, "#/properties/nameOverride", contract.Properties["fullnameOverride"].Ref)
+
+	app := s.Properties["app"]
+	assert.Contains(t, app.Properties, "image")
+	common := app.Properties["common"]
+	assert.Equal(t, StringOrArrayOfString{"object"}, common.Type)
+	assert.Equal(t, "#/properties/app/properties/common/properties/nameOverride", common.Properties["fullnameOverride"].Ref)
+	// definitions are merged into the root and references to them are kept
+	assert.Equal(t, "#/$defs/labels", common.Properties["labels"].Ref)
+	assert.Contains(t, s.Defs, "labels")
+	assert.Equal(t, []string{"labels"}, common.Required.Strings)
+	assert.NoError(t, s.ValidateInternalRefs())
+
+	s.Properties["replicas"] = &Schema{Type: StringOrArrayOfString{"integer"}}
+	_, err = s.ImportPartial(contract, "replicas.common")
+	assert.ErrorContains(t, err, "replicas is not an object")
+}
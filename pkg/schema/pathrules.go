@@ -0,0 +1,287 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PathRule sets schema attributes on the keys whose path matches one of its patterns,
+// e.g. writeOnly: true for all keys named password
+type PathRule struct {
+	// Paths are glob patterns (see path.Match) matched against the dotted paths of the keys.
+	// A pattern segment matches one key, ** matches any number of keys (e.g. **.password).
+	// The items of arrays are addressed with [] like in Query (e.g. hosts[].name).
+	Paths []string
+	// Schema contains the attributes to set, attributes the key already has are kept
+	Schema map[string]interface{}
+}
+
+// NewPathRules validates the rules. The keywords of their schemas are matched case-insensitively,
+// because config files are read case-insensitively (e.g. writeonly is writeOnly).
+func NewPathRules(rules []PathRule) ([]PathRule, error) {
+	for i, rule := range rules {
+		if rule.Schema != nil {
+			rule.Schema = canonicalKeywords(rule.Schema).(map[string]interface{})
+		}
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+	}
+	return rules, nil
+}
+
+// keywordNames maps the lowercase keywords of the schema to their names
+var keywordNames = func() map[string]string {
+	keywords := map[string]string{}
+	t := reflect.TypeOf(Schema{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keywords[strings.ToLower(name)] = name
+		}
+	}
+	return keywords
+}()
+
+// canonicalKeywords renames the keywords of the schema (and its subschemas) to their names.
+// The names of properties and definitions and the values of data keywords are kept.
+func canonicalKeywords(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		canonical := make(map[string]interface{}, len(v))
+		for key, sub := range v {
+			if name, ok := keywordNames[strings.ToLower(key)]; ok {
+				key = name
+			}
+			switch key {
+			case "default", "const", "enum", "examples":
+			case "properties", "patternProperties", "$defs", "definitions":
+				if names, ok := sub.(map[string]interface{}); ok {
+					subSchemas := make(map[string]interface{}, len(names))
+					for name, subSchema := range names {
+						subSchemas[name] = canonicalKeywords(subSchema)
+					}
+					sub = subSchemas
+				}
+			default:
+				sub = canonicalKeywords(sub)
+			}
+			canonical[key] = sub
+		}
+		return canonical
+	case []interface{}:
+		canonical := make([]interface{}, len(v))
+		for i, item := range v {
+			canonical[i] = canonicalKeywords(item)
+		}
+		return canonical
+	}
+	return value
+}
+
+func (r PathRule) validate() error {
+	if len(r.Paths) == 0 {
+		return errors.New("path rule without paths")
+	}
+	for _, pattern := range r.Paths {
+		for _, segment := range pathSegments(pattern) {
+			if segment == "" {
+				return fmt.Errorf("invalid path pattern %s of path rule, it contains an empty key", pattern)
+			}
+			if _, err := path.Match(segment, ""); err != nil && segment != itemsSegment {
+				return fmt.Errorf("invalid path pattern %s of path rule: %w", pattern, err)
+			}
+		}
+	}
+	if len(r.Schema) == 0 {
+		return fmt.Errorf("path rule %s sets no attributes", r)
+	}
+
+	patch, err := yaml.Marshal(r.Schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema of path rule %s: %w", r, err)
+	}
+	var s Schema
+	if err := yaml.Unmarshal(patch, &s); err != nil {
+		return fmt.Errorf("invalid schema of path rule %s: %w", r, err)
+	}
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("invalid schema of path rule %s: %w", r, err)
+	}
+	return nil
+}
+
+// itemsSegment is the segment of the items of an array in the paths of the path rules
+const itemsSegment = "[]"
+
+// pathSegments splits a dotted path into its keys, the items of arrays ([]) are separate segments
+func pathSegments(keyPath string) []string {
+	var segments []string
+	for _, key := range strings.Split(keyPath, ".") {
+		items := 0
+		for strings.HasSuffix(key, itemsSegment) && key != itemsSegment {
+			key = strings.TrimSuffix(key, itemsSegment)
+			items++
+		}
+		segments = append(segments, key)
+		for ; items > 0; items-- {
+			segments = append(segments, itemsSegment)
+		}
+	}
+	return segments
+}
+
+// dottedPath joins the keys of the segments to a dotted path, the inverse of pathSegments
+func dottedPath(segments []string) string {
+	var keyPath strings.Builder
+	for i, segment := range segments {
+		if i > 0 && segment != itemsSegment {
+			keyPath.WriteString(".")
+		}
+		keyPath.WriteString(segment)
+	}
+	return keyPath.String()
+}
+
+// matches checks if one of the patterns of the rule matches the dotted path
+func (r PathRule) matches(keyPath string) bool {
+	keys := pathSegments(keyPath)
+	for _, pattern := range r.Paths {
+		if matchPathSegments(pathSegments(pattern), keys) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathSegments matches the keys against the segments of a pattern, ** matches any number of
+// keys and items, other patterns only match keys
+func matchPathSegments(patterns, keys []string) bool {
+	if len(patterns) == 0 {
+		return len(keys) == 0
+	}
+	if patterns[0] == "**" {
+		for i := 0; i <= len(keys); i++ {
+			if matchPathSegments(patterns[1:], keys[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(keys) == 0 {
+		return false
+	}
+	if (patterns[0] == itemsSegment) != (keys[0] == itemsSegment) {
+		return false
+	}
+	if matched, _ := path.Match(patterns[0], keys[0]); !matched && patterns[0] != itemsSegment {
+		return false
+	}
+	return matchPathSegments(patterns[1:], keys[1:])
+}
+
+// ApplyPathRules sets the attributes of the matching rules on all keys (properties of objects, also
+// within allOf, anyOf and oneOf, and items of arrays, references are not followed). Attributes a key
+// already has (e.g. from an annotation) are kept, attributes with the zero value (e.g. writeOnly: false)
+// count as not set. The first matching rule wins if several set the same attribute.
+func (s *Schema) ApplyPathRules(rules []PathRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	return s.applyPathRules("", rules)
+}
+
+func (s *Schema) applyPathRules(keyPath string, rules []PathRule) error {
+	for _, name := range sortedSchemaKeys(s.Properties) {
+		property := s.Properties[name]
+		if property == nil {
+			continue
+		}
+		propertyPath := name
+		if keyPath != "" {
+			propertyPath = keyPath + "." + name
+		}
+		if err := property.applyMatchingPathRules(propertyPath, rules); err != nil {
+			return err
+		}
+		if err := property.applyPathRules(propertyPath, rules); err != nil {
+			return err
+		}
+	}
+
+	// the combined schemas describe the same value, their properties have the same paths
+	for _, combined := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, sub := range combined {
+			if sub == nil {
+				continue
+			}
+			if err := sub.applyPathRules(keyPath, rules); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.Items != nil && keyPath != "" {
+		itemsPath := keyPath + "[]"
+		if err := s.Items.applyMatchingPathRules(itemsPath, rules); err != nil {
+			return err
+		}
+		return s.Items.applyPathRules(itemsPath, rules)
+	}
+	return nil
+}
+
+// applyMatchingPathRules sets the attributes of the rules matching the key path, which are not set yet
+func (s *Schema) applyMatchingPathRules(keyPath string, rules []PathRule) error {
+	var existing map[string]interface{}
+	attributes := map[string]interface{}{}
+	for _, rule := range rules {
+		if !rule.matches(keyPath) {
+			continue
+		}
+		if existing == nil {
+			data, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+		}
+		for key, value := range rule.Schema {
+			if _, ok := existing[key]; ok {
+				continue
+			}
+			if _, ok := attributes[key]; !ok {
+				attributes[key] = value
+			}
+		}
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	patch, err := yaml.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+	if err := patchSchema(s, patch); err != nil {
+		return fmt.Errorf("could not apply the path rules to %s: %w", keyPath, err)
+	}
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("the path rules result in an invalid schema of %s: %w", keyPath, err)
+	}
+	return nil
+}
+
+// String returns the patterns of the rule, e.g. "**.password, **.token"
+func (r PathRule) String() string {
+	return strings.Join(r.Paths, ", ")
+}
@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPathRules(t *testing.T) {
+	s := selfCheckSchema(t, `auth:
+  password: ""
+  existingSecret: ""
+  # @schema
+  # format: uuid
+  # @schema
+  token: ""
+users:
+  - name: a
+    password: b
+password: ""
+`)
+
+	rules, err := NewPathRules([]PathRule{
+		{Paths: []string{"**.password", "**.token"}, Schema: map[string]interface{}{"writeonly": true, "x-sensitive": true}},
+		{Paths: []string{"auth.*"}, Schema: map[string]interface{}{"writeOnly": false, "minLength": 1, "format": "hostname"}},
+		{Paths: []string{"**.existingSecret"}, Schema: map[string]interface{}{"format": FormatK8sName}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, s.ApplyPathRules(rules))
+
+	auth := s.Properties["auth"].Properties
+	assert.True(t, auth["password"].WriteOnly)
+	assert.Equal(t, true, auth["password"].CustomAnnotations["x-sensitive"])
+	assert.Equal(t, "hostname", auth["password"].Format)
+	assert.Equal(t, 1, *auth["password"].MinLength)
+	assert.True(t, auth["token"].WriteOnly)
+	// set by the annotation
+	assert.Equal(t, "uuid", auth["token"].Format)
+	// the first matching rule wins
+	assert.Equal(t, "hostname", auth["existingSecret"].Format)
+	assert.False(t, auth["existingSecret"].WriteOnly)
+
+	// ** matches no key as well
+	assert.True(t, s.Properties["password"].WriteOnly)
+	// the items are variants of anyOf
+	assert.True(t, s.Properties["users"].Items.AnyOf[0].Properties["password"].WriteOnly)
+	assert.False(t, s.Properties["users"].Items.AnyOf[0].Properties["name"].WriteOnly)
+}
+
+func TestPathRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"**.password", "password", true},
+		{"**.password", "a.b.password", true},
+		{"**.password", "users[].password", true},
+		{"*.password", "users[].password", false},
+		{"users[].*", "users[].password", true},
+		{"users.*", "users[].password", false},
+		{"image.*", "image.tag", true},
+		{"image.*", "image.tag.digest", false},
+		{"image.**", "image.tag.digest", true},
+		{"*Secret", "existingSecret", true},
+		{"*Secret", "auth.existingSecret", false},
+	}
+
+	for _, test := range tests {
+		rule := PathRule{Paths: []string{test.pattern}}
+		assert.Equal(t, test.matches, rule.matches(test.path), "%s %s", test.pattern, test.path)
+	}
+}
+
+func TestNewPathRules(t *testing.T) {
+	rules, err := NewPathRules([]PathRule{{
+		Paths: []string{"**.config"},
+		Schema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"logLevel": map[string]interface{}{"enum": []interface{}{"Debug"}, "maxlength": 5}},
+		},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"logLevel": map[string]interface{}{"enum": []interface{}{"Debug"}, "maxLength": 5}},
+	}, rules[0].Schema)
+
+	for _, invalid := range []PathRule{
+		{Schema: map[string]interface{}{"writeOnly": true}},
+		{Paths: []string{"**.password"}},
+		{Paths: []string{"a..b"}, Schema: map[string]interface{}{"writeOnly": true}},
+		{Paths: []string{"[a"}, Schema: map[string]interface{}{"writeOnly": true}},
+		{Paths: []string{"**.password"}, Schema: map[string]interface{}{"type": "strin"}},
+	} {
+		_, err := NewPathRules([]PathRule{invalid})
+		assert.Error(t, err, invalid.String())
+	}
+}
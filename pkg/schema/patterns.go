@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// anchorPatternProperties anchors the unanchored patterns of the patternProperties of the
+// schema and its subschemas, if enabled by GenerationPolicy.AnchorPatternProperties
+func (s *Schema) anchorPatternProperties(gen *generation) {
+	if !gen.AnchorPatternProperties {
+		return
+	}
+
+	_ = s.Walk(func(_ string, v *Schema) error {
+		for pattern, subSchema := range v.PatternProperties {
+			if strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") {
+				continue
+			}
+			anchored := "^(?:" + pattern + ")$"
+			if _, ok := v.PatternProperties[anchored]; ok {
+				gen.log().Warnf("Not anchoring the pattern %s of patternProperties, because %s already exists", pattern, anchored)
+				continue
+			}
+			gen.log().Debugf("Anchoring the pattern %s of patternProperties as %s", pattern, anchored)
+			delete(v.PatternProperties, pattern)
+			v.PatternProperties[anchored] = subSchema
+		}
+		return nil
+	})
+}
+
+// validatePatternProperties checks that the patterns of the patternProperties are valid regular expressions
+func (s Schema) validatePatternProperties() error {
+	patterns := make([]string, 0, len(s.PatternProperties))
+	for pattern := range s.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	slices.Sort(patterns)
+
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q in patternProperties: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// PatternMatch is a key of the values which isn't added to the properties of its
+// parent, because it matches a pattern of the patternProperties
+type PatternMatch struct {
+	// Key is the path of the key, e.g. env.EXTRA_VAR or hosts[].x-custom
+	Key     string
+	Pattern string
+}
+
+func (m PatternMatch) String() string {
+	return fmt.Sprintf("%s matches the pattern %s", m.Key, m.Pattern)
+}
+
+// PatternMatches returns the keys of the values which were not added to the properties,
+// because they match a pattern of the patternProperties, sorted by their path
+func (s *Schema) PatternMatches() []PatternMatch {
+	var matches []PatternMatch
+	s.collectPatternMatches("", &matches)
+	slices.SortFunc(matches, func(a, b PatternMatch) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return matches
+}
+
+func (s *Schema) collectPatternMatches(prefix string, matches *[]PatternMatch) {
+	if s == nil {
+		return
+	}
+
+	for key, pattern := range s.matchedPatterns {
+		*matches = append(*matches, PatternMatch{Key: joinKeyPath(prefix, key), Pattern: pattern})
+	}
+	for name, property := range s.Properties {
+		property.collectPatternMatches(joinKeyPath(prefix, name), matches)
+	}
+	if s.Items != nil {
+		s.Items.collectPatternMatches(prefix+"[]", matches)
+		for _, item := range s.Items.AnyOf {
+			item.collectPatternMatches(prefix+"[]", matches)
+		}
+	}
+}
+
+// AddPatternMatchTraces adds the x-matched-pattern annotation to all schemas with keys
+// which were not added to the properties because they match a pattern of the patternProperties.
+// It maps each of these keys to the matching pattern.
+func (s *Schema) AddPatternMatchTraces() {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if len(v.matchedPatterns) == 0 {
+			return nil
+		}
+		trace := make(map[string]interface{}, len(v.matchedPatterns))
+		for key, pattern := range v.matchedPatterns {
+			trace[key] = pattern
+		}
+		v.setCustomAnnotationIfMissing(MatchedPatternAnnotation, trace)
+		return nil
+	})
+}
+
+func joinKeyPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
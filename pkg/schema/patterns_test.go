@@ -0,0 +1,161 @@
+package schema
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPatternMatches(t *testing.T) {
+	yamlContent := `# @schema
+# type: object
+# patternProperties:
+#   "^[A-Z_]+$":
+#     type: string
+#   "^FOO":
+#     type: string
+# @schema
+env:
+  FOO: bar
+  BAR: baz
+  lower: baz
+# @schema
+# type: object
+# mergeProperties: true
+# patternProperties:
+#   "^x-":
+#     type: string
+# properties:
+#   name:
+#     type: string
+# @schema
+server:
+  name: foo
+  x-custom: bar
+  port: 80
+plain:
+  FOO: bar
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	assert.Equal(t, []PatternMatch{
+		{Key: "env.BAR", Pattern: "^[A-Z_]+$"},
+		{Key: "env.FOO", Pattern: "^FOO"},
+		{Key: "server.x-custom", Pattern: "^x-"},
+	}, schema.PatternMatches())
+
+	_, ok := schema.Properties["env"].CustomAnnotations[MatchedPatternAnnotation]
+	assert.False(t, ok, "traces must only be added by AddPatternMatchTraces")
+
+	schema.AddPatternMatchTraces()
+	assert.Equal(t, map[string]interface{}{"BAR": "^[A-Z_]+$", "FOO": "^FOO"}, schema.Properties["env"].CustomAnnotations[MatchedPatternAnnotation])
+	assert.Equal(t, map[string]interface{}{"x-custom": "^x-"}, schema.Properties["server"].CustomAnnotations[MatchedPatternAnnotation])
+	assert.NotContains(t, schema.Properties["plain"].CustomAnnotations, MatchedPatternAnnotation)
+}
+
+func TestValidatePatternProperties(t *testing.T) {
+	s := Schema{
+		Type:              StringOrArrayOfString{"object"},
+		PatternProperties: map[string]*Schema{"^[A-Z_]+$": {}, "[a-z": {}},
+	}
+	err := s.Validate()
+	assert.ErrorContains(t, err, `invalid pattern "[a-z" in patternProperties`)
+
+	delete(s.PatternProperties, "[a-z")
+	assert.NoError(t, s.Validate())
+}
+
+func TestMatchInvalidPatternProperties(t *testing.T) {
+	annotated := &Schema{
+		Properties:        map[string]*Schema{},
+		PatternProperties: map[string]*Schema{"[a-z": {}},
+	}
+	generated := &Schema{Properties: map[string]*Schema{"FOO": {}}}
+
+	// invalid patterns are usually reported by the validation, the others are returned
+	err := mergeGeneratedProperties(annotated, generated)
+	assert.ErrorContains(t, err, `invalid pattern "[a-z" in patternProperties`)
+}
+
+func TestAnchorPatternProperties(t *testing.T) {
+	comment := `# @schema
+# type: object
+# patternProperties:
+#   "[A-Z_]+":
+#     type: string
+#   "^x-":
+#     type: string
+#   "_port$":
+#     type: integer
+# properties:
+#   nested:
+#     type: object
+#     patternProperties:
+#       "foo|bar":
+#         type: string
+# @schema`
+
+	s, _, err := GetSchemaFromComment(comment)
+	assert.NoError(t, err)
+	assert.Contains(t, s.PatternProperties, "[A-Z_]+")
+
+	s, _, err = getSchemaFromComment(comment, testGeneration(t, GenerationPolicy{AnchorPatternProperties: true}))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"^(?:[A-Z_]+)$", "^x-", "_port$"}, slices.Collect(maps.Keys(s.PatternProperties)))
+	assert.ElementsMatch(t, []string{"^(?:foo|bar)$"}, slices.Collect(maps.Keys(s.Properties["nested"].PatternProperties)))
+}
+
+func TestFreeFormMaps(t *testing.T) {
+	values := `# @schema
+# additionalProperties:
+#   type: [string, number]
+# propertyNames:
+#   pattern: ^[A-Z_][A-Z0-9_]*$
+# @schema
+extraEnv:
+  LOG_LEVEL: debug
+`
+	s := selfCheckSchema(t, values)
+	extraEnv := s.Properties["extraEnv"]
+	additional, ok := extraEnv.AdditionalProperties.(Schema)
+	assert.True(t, ok)
+	assert.Equal(t, StringOrArrayOfString{"string", "number"}, additional.Type)
+	assert.Equal(t, "^[A-Z_][A-Z0-9_]*$", extraEnv.PropertyNames.Pattern)
+
+	// the schemas are visited like all other subschemas
+	var paths []string
+	assert.NoError(t, extraEnv.Walk(func(path string, _ *Schema) error {
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.Contains(t, paths, "/additionalProperties")
+	assert.Contains(t, paths, "/propertyNames")
+
+	for _, test := range []struct {
+		values string
+		valid  bool
+	}{
+		{"extraEnv:\n  WORKERS: 4\n  LOG_LEVEL: info\n", true},
+		{"extraEnv:\n  DEBUG: true\n", false},
+		{"extraEnv:\n  workers: 4\n", false},
+	} {
+		err := s.ValidateValues([]byte(test.values), "values.schema.json")
+		if test.valid {
+			assert.NoError(t, err, test.values)
+		} else {
+			assert.Error(t, err, test.values)
+		}
+	}
+
+	// the keys of an object are strings
+	invalid := Schema{Type: StringOrArrayOfString{"object"}, PropertyNames: &Schema{Type: StringOrArrayOfString{"integer"}}}
+	assert.Error(t, invalid.Validate())
+}
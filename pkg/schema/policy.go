@@ -0,0 +1,231 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLeadingCommentPattern matches everything up to the last empty line of a comment,
+// which is not part of the description and the annotations of the key below it
+const DefaultLeadingCommentPattern = `(?s)(?m)(?:.*\n{2,})+`
+
+// DefaultEnumCommentPattern matches lines like "one of: debug, info, warn, error" in the
+// description of a key, the allowed values are the first group
+const DefaultEnumCommentPattern = `(?im)^\s*one of:\s*(.+?)\s*\.?\s*$`
+
+// DefaultValueFiles are the values files of a chart, if no others are configured
+var DefaultValueFiles = []string{"values.yaml"}
+
+// SkipPathOverride skips the auto generation of the fields for the keys matching the path
+// pattern (see SkipAutoGenerationConfig.AddPathOverride)
+type SkipPathOverride struct {
+	Path   string   `yaml:"path" mapstructure:"path"`
+	Fields []string `yaml:"fields" mapstructure:"fields"`
+}
+
+// GenerationPolicy controls how schemas are generated from values files. The keys are the
+// ones of the config file and the flags, so the policy can be read from both. The zero value
+// generates the schemas like helm-schema without any flags does.
+type GenerationPolicy struct {
+	// Uncomment considers yaml which is commented out
+	Uncomment bool `yaml:"uncomment" mapstructure:"uncomment"`
+	// AddSchemaReference adds a reference to the schema to the values files
+	AddSchemaReference bool `yaml:"add-schema-reference" mapstructure:"add-schema-reference"`
+	// Fix closes the trivially unclosed @schema blocks of the values files (see CloseAnnotationBlocks)
+	Fix bool `yaml:"fix" mapstructure:"fix"`
+	// KeepFullComment keeps the whole leading comment of the keys
+	KeepFullComment bool `yaml:"keep-full-comment" mapstructure:"keep-full-comment"`
+	// LeadingCommentPattern matches the part of a comment which is cut, unless KeepFullComment
+	// is set (DefaultLeadingCommentPattern if empty)
+	LeadingCommentPattern string `yaml:"leading-comment-pattern" mapstructure:"leading-comment-pattern"`
+	// HelmDocsCompatibilityMode parses and uses the helm-docs comments
+	HelmDocsCompatibilityMode bool `yaml:"helm-docs-compatibility-mode" mapstructure:"helm-docs-compatibility-mode"`
+	// DontStripHelmDocsPrefix keeps the helm-docs prefix (--) in the descriptions
+	DontStripHelmDocsPrefix bool `yaml:"dont-strip-helm-docs-prefix" mapstructure:"dont-strip-helm-docs-prefix"`
+	// DontAddGlobal doesn't add the global property to the root schema
+	DontAddGlobal bool `yaml:"dont-add-global" mapstructure:"dont-add-global"`
+	// ValueFiles are the names of the values files of a chart, the first existing one
+	// is used (DefaultValueFiles if empty)
+	ValueFiles []string `yaml:"value-files" mapstructure:"value-files"`
+	// SkipAutoGeneration are the fields which are not generated (see NewSkipAutoGenerationConfig)
+	SkipAutoGeneration []string `yaml:"skip-auto-generation" mapstructure:"skip-auto-generation"`
+	// SkipAutoGenerationPaths are the fields which are not generated for some keys
+	SkipAutoGenerationPaths []SkipPathOverride `yaml:"skip-auto-generation-paths" mapstructure:"skip-auto-generation-paths"`
+	// RefMode controls which external references are inlined (RefModeInlineFiles if empty)
+	RefMode string `yaml:"ref-mode" mapstructure:"ref-mode"`
+	// EnumFromComments turns the values listed in the description of a key (e.g. "one of: a, b")
+	// into its enum, unless the key has an annotated enum, const, type or $ref
+	EnumFromComments bool `yaml:"enum-from-comments" mapstructure:"enum-from-comments"`
+	// EnumCommentPattern matches the list of values in a description, its first group are the
+	// comma separated values (DefaultEnumCommentPattern if empty)
+	EnumCommentPattern string `yaml:"enum-comment-pattern" mapstructure:"enum-comment-pattern"`
+	// RelaxedAnnotationIndentation expands tabs in the indentation of @schema blocks to two spaces
+	// and dedents the lines by their common indentation instead of reporting the tabs
+	RelaxedAnnotationIndentation bool `yaml:"relaxed-annotation-indentation" mapstructure:"relaxed-annotation-indentation"`
+	// AllowMissingValues generates a minimal schema (an object with the global property) for charts
+	// without a values file or with an empty one, e.g. library charts or umbrella charts which only
+	// have dependencies. Otherwise a missing values file is an error.
+	AllowMissingValues bool `yaml:"allow-missing-values" mapstructure:"allow-missing-values"`
+	// ValuesFiles configures the values files of the charts, the first config matching the name
+	// of a chart is used, charts without a matching config use the ValueFiles
+	ValuesFiles []ValuesFilesConfig `yaml:"chart-values-files" mapstructure:"chart-values-files"`
+	// AnchorPatternProperties anchors the patterns of patternProperties which neither start with ^
+	// nor end with $, so they must match the whole key (e.g. FOO becomes ^(?:FOO)$)
+	AnchorPatternProperties bool `yaml:"anchor-pattern-properties" mapstructure:"anchor-pattern-properties"`
+	// Logger receives the log messages of the generation (the logger of SetLogger if nil), so
+	// concurrent generations can log to their own loggers
+	Logger logging.Logger `yaml:"-" mapstructure:"-"`
+}
+
+// generation is the checked policy, which the schemas are generated with
+type generation struct {
+	GenerationPolicy
+
+	// leadingComment is the compiled LeadingCommentPattern, nil if KeepFullComment is set
+	leadingComment *regexp.Regexp
+	// enumComment is the compiled EnumCommentPattern, nil unless EnumFromComments is set
+	enumComment *regexp.Regexp
+	skip        *SkipAutoGenerationConfig
+	// ctx cancels the downloads of referenced schemas, nil for context.Background
+	ctx context.Context
+	// flowAnnotations are the annotations of the keys of flow-style mappings of the values
+	// document (see annotateFlowMapping)
+	flowAnnotations map[*yaml.Node]flowAnnotation
+	// refFiles collects the local files loaded while resolving the references of the chart,
+	// nil if they aren't collected (see Result.RefFiles)
+	refFiles map[string]bool
+}
+
+// log returns the logger of the generation
+func (g *generation) log() logging.Logger {
+	if g.Logger == nil {
+		return logger
+	}
+	return g.Logger
+}
+
+// recordRefFile adds the local file loaded while resolving a reference to the refFiles
+func (g *generation) recordRefFile(path string) {
+	if g.refFiles != nil {
+		g.refFiles[filepath.Clean(path)] = true
+	}
+}
+
+// context returns the context of the generation, which cancels the downloads of referenced schemas
+func (g *generation) context() context.Context {
+	if g.ctx == nil {
+		return context.Background()
+	}
+	return g.ctx
+}
+
+// Validate checks the pattern and the fields of the policy
+func (p GenerationPolicy) Validate() error {
+	_, err := p.compile()
+	return err
+}
+
+// ToSchema generates the schema of the parsed values file at valuesPath
+func (p GenerationPolicy) ToSchema(valuesPath string, node *yaml.Node) (*Schema, error) {
+	return YamlToSchemaWithOptions(node, Options{ValuesPath: valuesPath, Policy: p})
+}
+
+// compile checks the policy and prepares it for the generation
+func (p GenerationPolicy) compile() (*generation, error) {
+	gen := &generation{GenerationPolicy: p}
+
+	if len(gen.ValueFiles) == 0 {
+		gen.ValueFiles = DefaultValueFiles
+	}
+
+	pattern := p.LeadingCommentPattern
+	if pattern == "" {
+		pattern = DefaultLeadingCommentPattern
+	}
+	leadingComment, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leading comment pattern %s: %w", pattern, err)
+	}
+	if !p.KeepFullComment {
+		gen.leadingComment = leadingComment
+	}
+
+	enumPattern := p.EnumCommentPattern
+	if enumPattern == "" {
+		enumPattern = DefaultEnumCommentPattern
+	}
+	enumComment, err := regexp.Compile(enumPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enum comment pattern %s: %w", enumPattern, err)
+	}
+	if enumComment.NumSubexp() < 1 {
+		return nil, fmt.Errorf("invalid enum comment pattern %s: the values must be captured by a group", enumPattern)
+	}
+	if p.EnumFromComments {
+		gen.enumComment = enumComment
+	}
+
+	if gen.RefMode == "" {
+		gen.RefMode = RefModeInlineFiles
+	}
+	if !slices.Contains(RefModes(), gen.RefMode) {
+		return nil, fmt.Errorf("invalid ref mode %s, one of (%s)", gen.RefMode, strings.Join(RefModes(), ", "))
+	}
+
+	if gen.ValuesFiles, err = validateValuesFiles(p.ValuesFiles); err != nil {
+		return nil, err
+	}
+
+	gen.skip, err = NewSkipAutoGenerationConfig(p.SkipAutoGeneration)
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range p.SkipAutoGenerationPaths {
+		if err := gen.skip.AddPathOverride(override.Path, override.Fields); err != nil {
+			return nil, err
+		}
+	}
+
+	return gen, nil
+}
+
+// cutLeadingComment removes the leading part of the comment, unless the full comment is kept
+func (g *generation) cutLeadingComment(comment string) string {
+	if g.leadingComment == nil {
+		return comment
+	}
+	return g.leadingComment.ReplaceAllString(comment, "")
+}
+
+// enumFromComment returns the values listed in the description (see EnumCommentPattern),
+// cast to the type of the value of the key. It returns nil if the description lists none.
+func (g *generation) enumFromComment(description string, valueNode *yaml.Node) []interface{} {
+	if g.enumComment == nil {
+		return nil
+	}
+	match := g.enumComment.FindStringSubmatch(description)
+	if match == nil {
+		return nil
+	}
+
+	valueType, _ := typeFromNode(valueNode)
+	var values []interface{}
+	for _, item := range strings.Split(match[1], ",") {
+		item = strings.Trim(strings.TrimSpace(item), "`\"'")
+		if item == "" {
+			continue
+		}
+		value := castNodeValueByType(item, StringOrArrayOfString(valueType))
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
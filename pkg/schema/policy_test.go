@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// testGeneration returns the checked policy for the tests
+func testGeneration(t *testing.T, policy GenerationPolicy) *generation {
+	t.Helper()
+	gen, err := policy.compile()
+	assert.NoError(t, err)
+	return gen
+}
+
+func TestGenerationPolicyValidate(t *testing.T) {
+	assert.NoError(t, GenerationPolicy{}.Validate())
+	assert.NoError(t, GenerationPolicy{
+		SkipAutoGeneration:      []string{"title", "arrays.items"},
+		SkipAutoGenerationPaths: []SkipPathOverride{{Path: "image.*", Fields: []string{"required"}}},
+		LeadingCommentPattern:   `(?s).*\n# -+\n`,
+	}.Validate())
+
+	assert.ErrorContains(t, GenerationPolicy{LeadingCommentPattern: "("}.Validate(), "invalid leading comment pattern")
+	assert.ErrorContains(t, GenerationPolicy{SkipAutoGeneration: []string{"color"}}.Validate(), "unsupported field names")
+	assert.Error(t, GenerationPolicy{SkipAutoGenerationPaths: []SkipPathOverride{{Path: "image", Fields: []string{"color"}}}}.Validate())
+}
+
+func TestGenerationPolicyFromConfig(t *testing.T) {
+	config := `
+keep-full-comment: true
+dont-add-global: true
+value-files: [values.yaml, values.yml]
+skip-auto-generation: [title]
+skip-auto-generation-paths:
+  - path: image
+    fields: [required]
+`
+	var policy GenerationPolicy
+	assert.NoError(t, yaml.Unmarshal([]byte(config), &policy))
+	assert.Equal(t, GenerationPolicy{
+		KeepFullComment:         true,
+		DontAddGlobal:           true,
+		ValueFiles:              []string{"values.yaml", "values.yml"},
+		SkipAutoGeneration:      []string{"title"},
+		SkipAutoGenerationPaths: []SkipPathOverride{{Path: "image", Fields: []string{"required"}}},
+	}, policy)
+}
+
+func TestGenerationPolicyToSchema(t *testing.T) {
+	values := `# Section: images
+# ----
+# the image
+image:
+  # the tag
+  tag: latest
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+
+	generate := func(policy GenerationPolicy) *Schema {
+		t.Helper()
+		s, err := policy.ToSchema("values.yaml", &node)
+		assert.NoError(t, err)
+		return s
+	}
+
+	s := generate(GenerationPolicy{})
+	assert.Contains(t, s.Properties, "global")
+	assert.Equal(t, "Section: images\n----\nthe image", s.Properties["image"].Description)
+	assert.Equal(t, "image", s.Properties["image"].Title)
+
+	s = generate(GenerationPolicy{
+		DontAddGlobal:           true,
+		LeadingCommentPattern:   `(?s).*\n# -+\n`,
+		SkipAutoGeneration:      []string{"title"},
+		SkipAutoGenerationPaths: []SkipPathOverride{{Path: "image.*", Fields: []string{"required"}}},
+	})
+	assert.NotContains(t, s.Properties, "global")
+	assert.Equal(t, "the image", s.Properties["image"].Description)
+	assert.Empty(t, s.Properties["image"].Title)
+	assert.Equal(t, "the tag", s.Properties["image"].Properties["tag"].Description)
+	assert.Empty(t, s.Properties["image"].Required.Strings)
+
+	_, err := GenerationPolicy{LeadingCommentPattern: "("}.ToSchema("values.yaml", &node)
+	assert.Error(t, err)
+}
+
+func TestWorkerInvalidPolicy(t *testing.T) {
+	c := valuesFilesChart(t, map[string]string{"values.yaml": "key: value\n"})
+
+	queue := make(chan chart.Chart, 1)
+	results := make(chan Result, 1)
+	queue <- c
+	close(queue)
+	Worker(context.Background(), false, GenerationPolicy{SkipAutoGeneration: []string{"color"}}, "", queue, results)
+
+	result := <-results
+	assert.Equal(t, c.Path, result.ChartPath)
+	assert.Len(t, result.Errors, 1)
+	assert.ErrorContains(t, result.Errors[0], "unsupported field names")
+}
+
+func TestGenerationPolicyEnumFromComments(t *testing.T) {
+	values := `# the log level
+# one of: debug, info, warn, error
+logLevel: info
+# one of: 1, 2, 3
+replicas: 1
+# @schema
+# enum: [a, b]
+# @schema
+# one of: c, d
+annotated: a
+# one of: ` + "`http`, `https`" + `.
+scheme: http
+# one of: debug, info
+fallbackLevel: warn
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+
+	s, err := GenerationPolicy{}.ToSchema("values.yaml", &node)
+	assert.NoError(t, err)
+	assert.Nil(t, s.Properties["logLevel"].Enum)
+
+	s, err = GenerationPolicy{EnumFromComments: true}.ToSchema("values.yaml", &node)
+	assert.NoError(t, err)
+	logLevel := s.Properties["logLevel"]
+	assert.Equal(t, []interface{}{"debug", "info", "warn", "error"}, logLevel.Enum)
+	// the key isn't annotated by its description
+	assert.Equal(t, StringOrArrayOfString{"string"}, logLevel.Type)
+	assert.Contains(t, s.Required.Strings, "logLevel")
+	assert.Equal(t, "the log level\none of: debug, info, warn, error", logLevel.Description)
+	assert.Equal(t, []interface{}{1, 2, 3}, s.Properties["replicas"].Enum)
+	assert.Equal(t, []interface{}{"a", "b"}, s.Properties["annotated"].Enum)
+	assert.Equal(t, []interface{}{"http", "https"}, s.Properties["scheme"].Enum)
+	// an enum rejecting the default would reject the values of the chart
+	assert.Nil(t, s.Properties["fallbackLevel"].Enum)
+
+	s, err = GenerationPolicy{EnumFromComments: true, EnumCommentPattern: `(?m)^allowed: (.+)$`}.ToSchema("values.yaml", &node)
+	assert.NoError(t, err)
+	assert.Nil(t, s.Properties["logLevel"].Enum)
+
+	assert.ErrorContains(t, GenerationPolicy{EnumCommentPattern: "allowed: .+"}.Validate(), "must be captured by a group")
+	assert.ErrorContains(t, GenerationPolicy{EnumCommentPattern: "("}.Validate(), "invalid enum comment pattern")
+}
@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefinitionRef names a definition of an existing schema file, e.g. one which has the same
+// name as a generated definition but a different content
+type DefinitionRef struct {
+	// Keyword is either $defs or definitions
+	Keyword string
+	// Name is the name of the definition
+	Name string
+}
+
+func (c DefinitionRef) String() string {
+	return fmt.Sprintf("#/%s/%s", c.Keyword, escapePointerSegment(c.Name))
+}
+
+// ReadSchemaFile reads the jsonschema at the given path.
+// If the file doesn't exist, nil is returned without an error.
+func ReadSchemaFile(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	s, err := Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse existing schema %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// PreserveDefinitions copies the $defs and definitions of the existing schema which
+// are not generated into the schema, e.g. helper definitions which were added by hand
+// and are referenced by annotations. Only definitions which are still referenced by the
+// schema (directly or by another preserved definition) are copied, the others are stale
+// and returned as dropped. If a definition exists in both schemas with different content,
+// the generated one is kept and a conflict is returned.
+func (s *Schema) PreserveDefinitions(existing *Schema) ([]DefinitionRef, []DefinitionRef) {
+	if existing == nil {
+		return nil, nil
+	}
+
+	targets := map[string]struct {
+		defs *map[string]*Schema
+		old  map[string]*Schema
+	}{
+		"$defs":       {&s.Defs, existing.Defs},
+		"definitions": {&s.Definitions, existing.Definitions},
+	}
+
+	// the definitions referenced by the preserved ones are preserved as well
+	preserved := map[DefinitionRef]bool{}
+	queue := referencedDefinitions(s)
+	for len(queue) > 0 {
+		def := queue[0]
+		queue = queue[1:]
+
+		target := targets[def.Keyword]
+		old, ok := target.old[def.Name]
+		if !ok || preserved[def] {
+			continue
+		}
+		if _, generated := (*target.defs)[def.Name]; generated {
+			continue
+		}
+		if *target.defs == nil {
+			*target.defs = make(map[string]*Schema)
+		}
+		preserved[def] = true
+		(*target.defs)[def.Name] = old.Clone()
+		queue = append(queue, referencedDefinitions(old)...)
+	}
+
+	var conflicts, dropped []DefinitionRef
+	for _, keyword := range []string{"$defs", "definitions"} {
+		target := targets[keyword]
+		names := make([]string, 0, len(target.old))
+		for name := range target.old {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			def := DefinitionRef{Keyword: keyword, Name: name}
+			if generated, ok := (*target.defs)[name]; ok && !preserved[def] {
+				if !generated.EqualsOpt(target.old[name], EqualsFull) {
+					conflicts = append(conflicts, def)
+				}
+				continue
+			}
+			if !preserved[def] {
+				dropped = append(dropped, def)
+			}
+		}
+	}
+
+	return conflicts, dropped
+}
+
+// referencedDefinitions returns the definitions the internal references of the schema point to
+func referencedDefinitions(s *Schema) []DefinitionRef {
+	var defs []DefinitionRef
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if !isDefinitionRef(v.Ref) {
+			return nil
+		}
+		keyword, rest, _ := strings.Cut(strings.TrimPrefix(v.Ref, "#/"), "/")
+		name, _, _ := strings.Cut(rest, "/")
+		defs = append(defs, DefinitionRef{Keyword: keyword, Name: unescapePointerSegment(name)})
+		return nil
+	})
+	return defs
+}
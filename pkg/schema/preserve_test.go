@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSchemaFile(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := ReadSchemaFile(filepath.Join(dir, "missing.json"))
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	path := filepath.Join(dir, "values.schema.json")
+	if err := os.WriteFile(path, []byte(`{"$defs": {"port": {"type": "integer"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err = ReadSchemaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, StringOrArrayOfString{"integer"}, s.Defs["port"].Type)
+
+	// custom annotations are kept in all subschemas
+	custom := `{"x-root": 1, "properties": {"a": {"x-renamed-to": "b"}}, "allOf": [{"then": {"x-deprecation-warning": "a"}}]}`
+	if err := os.WriteFile(path, []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err = ReadSchemaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(1), s.CustomAnnotations["x-root"])
+	assert.Equal(t, "b", s.Properties["a"].CustomAnnotations[RenamedToAnnotation])
+	assert.Equal(t, "a", s.AllOf[0].Then.CustomAnnotations[DeprecationWarningAnnotation])
+
+	if err := os.WriteFile(path, []byte(`{`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadSchemaFile(path)
+	assert.Error(t, err)
+}
+
+func TestPreserveDefinitions(t *testing.T) {
+	generated := &Schema{
+		Properties: map[string]*Schema{
+			"resources": {Ref: "#/$defs/resource"},
+			"enabled":   {Ref: "#/definitions/legacy/properties/enabled"},
+		},
+		Defs: map[string]*Schema{
+			"image": {Type: StringOrArrayOfString{"string"}},
+			"port":  {Type: StringOrArrayOfString{"integer"}},
+		},
+	}
+	existing := &Schema{
+		Defs: map[string]*Schema{
+			"image":    {Type: StringOrArrayOfString{"string"}},
+			"port":     {Type: StringOrArrayOfString{"string"}},
+			"resource": {Type: StringOrArrayOfString{"object"}, Properties: map[string]*Schema{"limits": {Ref: "#/$defs/quantity"}}},
+			"quantity": {Type: StringOrArrayOfString{"string"}},
+			"stale":    {Type: StringOrArrayOfString{"string"}},
+		},
+		Definitions: map[string]*Schema{
+			"legacy": {Type: StringOrArrayOfString{"object"}, Properties: map[string]*Schema{"enabled": {Type: StringOrArrayOfString{"boolean"}}}},
+			"old":    {Type: StringOrArrayOfString{"boolean"}},
+		},
+	}
+
+	conflicts, dropped := generated.PreserveDefinitions(existing)
+
+	assert.Equal(t, []DefinitionRef{{Keyword: "$defs", Name: "port"}}, conflicts)
+	assert.Equal(t, "#/$defs/port", conflicts[0].String())
+	assert.Equal(t, StringOrArrayOfString{"integer"}, generated.Defs["port"].Type)
+	assert.Contains(t, generated.Defs, "resource")
+	assert.Contains(t, generated.Definitions, "legacy")
+
+	// definitions referenced by preserved definitions are kept, unreferenced ones are pruned
+	assert.Contains(t, generated.Defs, "quantity")
+	assert.NotContains(t, generated.Defs, "stale")
+	assert.NotContains(t, generated.Definitions, "old")
+	assert.Equal(t, []DefinitionRef{{Keyword: "$defs", Name: "stale"}, {Keyword: "definitions", Name: "old"}}, dropped)
+
+	// preserved definitions are copies
+	generated.Defs["resource"].Type = StringOrArrayOfString{"array"}
+	assert.Equal(t, StringOrArrayOfString{"object"}, existing.Defs["resource"].Type)
+
+	conflicts, dropped = generated.PreserveDefinitions(nil)
+	assert.Empty(t, conflicts)
+	assert.Empty(t, dropped)
+}
@@ -0,0 +1,264 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the builtin presets
+const (
+	// PresetImage is the preset for the common image block of charts
+	// (registry, repository, tag, digest and pullPolicy)
+	PresetImage = "image"
+	// PresetIngress is the preset for the ingress block created by helm create
+	// (enabled, className, annotations, hosts with paths and tls)
+	PresetIngress = "ingress"
+	// PresetService is the preset for the service block created by helm create
+	// (type, port, targetPort, nodePort and annotations)
+	PresetService = "service"
+)
+
+// imagePreset allows either a tag or a digest. Empty strings are treated like
+// unset values, because charts usually default the tag to the appVersion.
+const imagePreset = `
+type: object
+properties:
+  registry:
+    type: string
+    title: registry
+    description: The registry of the image, e.g. docker.io
+  repository:
+    type: string
+    title: repository
+    description: The repository of the image
+  tag:
+    type: string
+    title: tag
+    description: The tag of the image, can't be used together with a digest
+  digest:
+    type: string
+    title: digest
+    description: The digest of the image, can't be used together with a tag
+    pattern: ^(sha256:[a-f0-9]{64})?$
+  pullPolicy:
+    title: pullPolicy
+    description: The pull policy of the image
+    enum: [Always, IfNotPresent, Never]
+required: [repository]
+oneOf:
+  - properties:
+      digest:
+        maxLength: 0
+  - required: [digest]
+    properties:
+      digest:
+        minLength: 1
+      tag:
+        maxLength: 0
+`
+
+const ingressPreset = `
+type: object
+properties:
+  enabled:
+    type: boolean
+    title: enabled
+    description: Create an ingress for the service
+  className:
+    type: string
+    title: className
+    description: The ingress class of the ingress
+  annotations:
+    type: object
+    title: annotations
+    description: Annotations of the ingress
+    additionalProperties:
+      type: string
+  hosts:
+    type: array
+    title: hosts
+    description: The hosts and paths routed to the service
+    items:
+      type: object
+      properties:
+        host:
+          type: string
+          title: host
+        paths:
+          type: array
+          title: paths
+          items:
+            type: object
+            properties:
+              path:
+                type: string
+                title: path
+                pattern: ^/
+              pathType:
+                title: pathType
+                enum: [Exact, Prefix, ImplementationSpecific]
+            required: [path]
+      required: [host]
+  tls:
+    type: array
+    title: tls
+    description: The tls configuration of the hosts
+    items:
+      type: object
+      properties:
+        secretName:
+          type: string
+          title: secretName
+        hosts:
+          type: array
+          title: hosts
+          items:
+            type: string
+`
+
+const servicePreset = `
+type: object
+properties:
+  type:
+    title: type
+    description: The type of the service
+    enum: [ClusterIP, NodePort, LoadBalancer, ExternalName]
+  port:
+    type: integer
+    title: port
+    description: The port of the service
+    minimum: 1
+    maximum: 65535
+  targetPort:
+    title: targetPort
+    description: The port (number or name) of the pods the service forwards to
+    anyOf:
+      - type: integer
+        minimum: 1
+        maximum: 65535
+      - type: string
+        pattern: ^[a-z0-9]([-a-z0-9]*[a-z0-9])?$
+        maxLength: 15
+  nodePort:
+    type: [integer, "null"]
+    title: nodePort
+    description: The node port of NodePort and LoadBalancer services (default range of kubernetes)
+    minimum: 30000
+    maximum: 32767
+  annotations:
+    type: object
+    title: annotations
+    description: Annotations of the service
+    additionalProperties:
+      type: string
+required: [type, port]
+`
+
+// presets contains the schemas of all presets by their name.
+// It must only be modified by RegisterPreset before the schemas are generated.
+var presets = map[string]string{
+	PresetImage:   imagePreset,
+	PresetIngress: ingressPreset,
+	PresetService: servicePreset,
+}
+
+// RegisterPreset adds a preset or replaces a builtin one (e.g. to use another
+// nodePort range). The preset is a yaml schema, like in the annotations.
+// Names are case-insensitive. It is not safe to register presets while schemas are generated.
+func RegisterPreset(name string, preset []byte) error {
+	name = strings.ToLower(name)
+	if name == "" {
+		return errors.New("preset without name")
+	}
+
+	var s Schema
+	if err := yaml.Unmarshal(preset, &s); err != nil {
+		return fmt.Errorf("invalid preset %s: %w", name, err)
+	}
+	if s.Preset != "" {
+		return fmt.Errorf("invalid preset %s: presets can't use other presets", name)
+	}
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("invalid preset %s: %w", name, err)
+	}
+
+	presets[name] = string(preset)
+	return nil
+}
+
+// applyPreset sets the schema of the preset referenced in the raw annotation (preset: <name>).
+// The annotation itself must be unmarshaled afterwards, so its fields take precedence.
+// The properties of the preset, which the annotation doesn't replace, are kept to be merged
+// over the generated properties (see mergePresetProperties).
+func (s *Schema) applyPreset(rawSchema []byte) error {
+	var annotation struct {
+		Preset     string               `yaml:"preset"`
+		Properties map[string]yaml.Node `yaml:"properties"`
+		Required   *yaml.Node           `yaml:"required"`
+	}
+	if err := yaml.Unmarshal(rawSchema, &annotation); err != nil || annotation.Preset == "" {
+		// invalid annotations are reported when the whole annotation is unmarshaled
+		return nil
+	}
+
+	preset, ok := presets[strings.ToLower(annotation.Preset)]
+	if !ok {
+		names := make([]string, 0, len(presets))
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown preset %s (possible: %s)", annotation.Preset, strings.Join(names, ", "))
+	}
+
+	if err := yaml.Unmarshal([]byte(preset), s); err != nil {
+		return err
+	}
+
+	var presetSchema struct {
+		Properties map[string]yaml.Node `yaml:"properties"`
+	}
+	if err := yaml.Unmarshal([]byte(preset), &presetSchema); err != nil {
+		return err
+	}
+	s.presetProperties = make(map[string]*yaml.Node, len(presetSchema.Properties))
+	for name, property := range presetSchema.Properties {
+		if _, ok := annotation.Properties[name]; !ok {
+			s.presetProperties[name] = &property
+		}
+	}
+	s.presetRequired = annotation.Required == nil && len(s.Required.Strings) > 0
+	return nil
+}
+
+// mergePresetProperties merges the properties of the preset over the ones generated from the
+// values (node), so e.g. their defaults are kept. The keys the preset requires are not required,
+// if the auto-generation of required is skipped for them.
+func (s *Schema) mergePresetProperties(generated *Schema, node *yaml.Node, skip *SkipAutoGenerationConfig) error {
+	for name, presetNode := range s.presetProperties {
+		property, ok := generated.Properties[name]
+		if !ok || property == nil {
+			continue
+		}
+		if err := presetNode.Decode(property); err != nil {
+			return fmt.Errorf("invalid preset %s: %w", s.Preset, err)
+		}
+		s.Properties[name] = property
+	}
+
+	if s.presetRequired {
+		s.Required.Strings = slices.DeleteFunc(s.Required.Strings, func(name string) bool {
+			var kind yaml.Kind
+			if value := mappingValue(node, name); value != nil {
+				kind = value.Kind
+			}
+			return skip.ForKey(name).ForKind(kind).Required
+		})
+	}
+	return nil
+}
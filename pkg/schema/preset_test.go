@@ -0,0 +1,265 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestImagePreset(t *testing.T) {
+	yamlContent := `# @schema
+# preset: image
+# description: The main image
+# @schema
+image:
+  repository: nginx
+  tag: ""
+  pullPolicy: IfNotPresent
+  pullSecrets: []
+`
+	image := presetSchemaFromValues(t, yamlContent, "image")
+
+	assert.Equal(t, "The main image", image.Description)
+	assert.Contains(t, image.Properties, "digest")
+	assert.Contains(t, image.Properties, "pullSecrets")
+	assert.Equal(t, []string{"repository", "pullSecrets"}, image.Required.Strings)
+
+	compiled := compilePresetSchema(t, image)
+
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name  string
+		value map[string]interface{}
+		valid bool
+	}{
+		{name: "tag", value: map[string]interface{}{"repository": "nginx", "tag": "1.25", "pullSecrets": []interface{}{}}, valid: true},
+		{name: "digest", value: map[string]interface{}{"repository": "nginx", "digest": digest, "pullSecrets": []interface{}{}}, valid: true},
+		{name: "digest and empty tag", value: map[string]interface{}{"repository": "nginx", "tag": "", "digest": digest, "pullSecrets": []interface{}{}}, valid: true},
+		{name: "neither", value: map[string]interface{}{"repository": "nginx", "tag": "", "digest": "", "pullSecrets": []interface{}{}}, valid: true},
+		{name: "tag and digest", value: map[string]interface{}{"repository": "nginx", "tag": "1.25", "digest": digest, "pullSecrets": []interface{}{}}},
+		{name: "invalid digest", value: map[string]interface{}{"repository": "nginx", "digest": "latest", "pullSecrets": []interface{}{}}},
+		{name: "invalid pullPolicy", value: map[string]interface{}{"repository": "nginx", "pullPolicy": "Sometimes", "pullSecrets": []interface{}{}}},
+		{name: "missing repository", value: map[string]interface{}{"tag": "1.25", "pullSecrets": []interface{}{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := compiled.Validate(tt.value)
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
+
+func TestPresetGeneratedKeys(t *testing.T) {
+	yamlContent := `# @schema
+# preset: image
+# patternProperties:
+#   "^x-":
+#     type: string
+# @schema
+image:
+  repository: nginx
+  zone: eu
+  pullSecrets: []
+  annotations: {}
+  x-team: platform
+`
+	image := presetSchemaFromValues(t, yamlContent, "image")
+
+	// the keys of the values file follow the ones of the preset in their order
+	assert.Equal(t, []string{"repository", "zone", "pullSecrets", "annotations"}, image.Required.Strings)
+	assert.Contains(t, image.Properties, "zone")
+	assert.NotContains(t, image.Properties, "x-team")
+	assert.Contains(t, image.PatternProperties, "^x-")
+}
+
+func TestPresetMergedOverGeneratedKeys(t *testing.T) {
+	yamlContent := `# @schema
+# preset: service
+# @schema
+service:
+  type: ClusterIP
+  port: 80
+`
+	service := presetSchemaFromValues(t, yamlContent, "service")
+
+	// the preset is merged over the generated keys, so their defaults are kept
+	assert.Equal(t, "ClusterIP", service.Properties["type"].Default)
+	assert.Equal(t, []interface{}{"ClusterIP", "NodePort", "LoadBalancer", "ExternalName"}, service.Properties["type"].Enum)
+	assert.Equal(t, 80, service.Properties["port"].Default)
+	assert.Equal(t, "The port of the service", service.Properties["port"].Description)
+	assert.Equal(t, []string{"type", "port"}, service.Required.Strings)
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewSkipAutoGenerationConfig([]string{"required"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service = YamlToSchema("", &node, false, false, false, true, config, nil, nil).Properties["service"]
+	assert.Empty(t, service.Required.Strings)
+
+	// required keys of the annotation are kept
+	yamlContent = strings.Replace(yamlContent, "# preset: service\n", "# preset: service\n# required: [port]\n", 1)
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+	service = YamlToSchema("", &node, false, false, false, true, config, nil, nil).Properties["service"]
+	assert.Equal(t, []string{"port"}, service.Required.Strings)
+}
+
+// presetSchemaFromValues generates the schema of the values and returns the schema of the given key
+func presetSchemaFromValues(t *testing.T, yamlContent, key string) *Schema {
+	t.Helper()
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return YamlToSchema("", &node, false, false, false, true, config, nil, nil).Properties[key]
+}
+
+// compilePresetSchema compiles the schema, so values can be validated against it
+func compilePresetSchema(t *testing.T, s *Schema) *jsonschema.Schema {
+	t.Helper()
+
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(jsonStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("preset.json", doc); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := c.Compile("preset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
+
+func TestIngressPreset(t *testing.T) {
+	ingress := presetSchemaFromValues(t, `# @schema
+# preset: ingress
+# @schema
+ingress:
+  enabled: false
+  className: ""
+  annotations: {}
+  hosts:
+    - host: chart-example.local
+      paths:
+        - path: /
+          pathType: ImplementationSpecific
+  tls: []
+`, "ingress")
+	compiled := compilePresetSchema(t, ingress)
+
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "defaults", value: `{"enabled": false, "className": "", "annotations": {}, "hosts": [{"host": "chart-example.local", "paths": [{"path": "/", "pathType": "Prefix"}]}], "tls": []}`, valid: true},
+		{name: "tls", value: `{"enabled": true, "tls": [{"secretName": "tls", "hosts": ["chart-example.local"]}]}`, valid: true},
+		{name: "relative path", value: `{"hosts": [{"host": "example.com", "paths": [{"path": "api"}]}]}`},
+		{name: "invalid pathType", value: `{"hosts": [{"host": "example.com", "paths": [{"path": "/", "pathType": "Regex"}]}]}`},
+		{name: "missing host", value: `{"hosts": [{"paths": []}]}`},
+		{name: "annotation is not a string", value: `{"annotations": {"nginx.ingress.kubernetes.io/ssl-redirect": true}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := jsonschema.UnmarshalJSON(strings.NewReader(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = compiled.Validate(value)
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
+
+func TestServicePreset(t *testing.T) {
+	service := presetSchemaFromValues(t, `# @schema
+# preset: service
+# @schema
+service:
+  type: ClusterIP
+  port: 80
+`, "service")
+	compiled := compilePresetSchema(t, service)
+
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "defaults", value: `{"type": "ClusterIP", "port": 80}`, valid: true},
+		{name: "node port", value: `{"type": "NodePort", "port": 80, "nodePort": 30080, "targetPort": "http"}`, valid: true},
+		{name: "null node port", value: `{"type": "LoadBalancer", "port": 443, "nodePort": null, "targetPort": 8443}`, valid: true},
+		{name: "invalid type", value: `{"type": "Headless", "port": 80}`},
+		{name: "port out of range", value: `{"type": "ClusterIP", "port": 70000}`},
+		{name: "node port out of range", value: `{"type": "NodePort", "port": 80, "nodePort": 8080}`},
+		{name: "invalid port name", value: `{"type": "ClusterIP", "port": 80, "targetPort": "Not_A_Name"}`},
+		{name: "missing port", value: `{"type": "ClusterIP"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := jsonschema.UnmarshalJSON(strings.NewReader(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = compiled.Validate(value)
+			assert.Equal(t, tt.valid, err == nil, err)
+		})
+	}
+}
+
+func TestRegisterPreset(t *testing.T) {
+	builtin := presets[PresetService]
+	defer func() {
+		presets[PresetService] = builtin
+		delete(presets, "port")
+	}()
+
+	assert.NoError(t, RegisterPreset("Port", []byte("type: integer\nminimum: 1\nmaximum: 65535")))
+	assert.NoError(t, RegisterPreset(PresetService, []byte(`
+type: object
+properties:
+  nodePort:
+    type: integer
+    minimum: 31000
+    maximum: 31999
+`)))
+
+	s, _, err := GetSchemaFromComment("# @schema\n# preset: port\n# @schema")
+	assert.NoError(t, err)
+	assert.Equal(t, 65535, *s.Maximum)
+
+	s, _, err = GetSchemaFromComment("# @schema\n# preset: service\n# @schema")
+	assert.NoError(t, err)
+	assert.Equal(t, 31000, *s.Properties["nodePort"].Minimum)
+
+	assert.Error(t, RegisterPreset("", []byte("type: string")))
+	assert.Error(t, RegisterPreset("invalid", []byte("type: foo")))
+	assert.Error(t, RegisterPreset("nested", []byte("preset: image")))
+}
+
+func TestUnknownPreset(t *testing.T) {
+	_, _, err := GetSchemaFromComment("# @schema\n# preset: foo\n# @schema")
+	assert.ErrorContains(t, err, "unknown preset foo (possible: image, ingress, service)")
+}
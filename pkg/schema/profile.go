@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ValidationRule is a rule for annotations which can be turned off by validation profiles.
+// Rules which would produce invalid schemas (e.g. unknown types or minLength > maxLength)
+// are always checked.
+type ValidationRule string
+
+const (
+	// RuleEnumWithType rejects enum or const together with type
+	RuleEnumWithType ValidationRule = "enum-type"
+	// RuleFormatWithPattern rejects format together with pattern
+	RuleFormatWithPattern ValidationRule = "format-pattern"
+	// RuleConstraintTypes rejects keywords which don't apply to the type (e.g. minimum for strings)
+	RuleConstraintTypes ValidationRule = "constraint-types"
+	// RuleKnownFormats rejects formats which are not supported
+	RuleKnownFormats ValidationRule = "known-formats"
+	// RuleUnionConstraints rejects keywords which only apply to some types of a union,
+	// e.g. minimum for [integer, string]. The null type is ignored.
+	RuleUnionConstraints ValidationRule = "union-constraints"
+)
+
+// Names of the validation profiles
+const (
+	// ValidationProfileSpecStrict checks all rules
+	ValidationProfileSpecStrict = "spec-strict"
+	// ValidationProfileHelmPragmatic checks all rules, but allows constraints on type unions (default)
+	ValidationProfileHelmPragmatic = "helm-pragmatic"
+	// ValidationProfileLegacy only checks the rules which would produce invalid schemas
+	ValidationProfileLegacy = "legacy"
+)
+
+// ValidationProfileAnnotation is the annotation of the Chart.yaml which overrides
+// the validation profile (--validation-profile) for the chart
+const ValidationProfileAnnotation = "helm-schema/validation-profile"
+
+// ValidationProfile is a named set of validation rules
+type ValidationProfile struct {
+	Name  string
+	Rules []ValidationRule
+}
+
+var validationProfiles = []ValidationProfile{
+	{
+		Name:  ValidationProfileSpecStrict,
+		Rules: []ValidationRule{RuleEnumWithType, RuleFormatWithPattern, RuleConstraintTypes, RuleKnownFormats, RuleUnionConstraints},
+	},
+	{
+		Name:  ValidationProfileHelmPragmatic,
+		Rules: []ValidationRule{RuleEnumWithType, RuleFormatWithPattern, RuleConstraintTypes, RuleKnownFormats},
+	},
+	{
+		Name: ValidationProfileLegacy,
+	},
+}
+
+// structuralValidationProfile is used while the schema is generated. The rules of the
+// selected profile are checked afterwards by ValidateAnnotations, because the profile
+// can differ between charts.
+var structuralValidationProfile = ValidationProfile{Name: ValidationProfileLegacy}
+
+// ValidationProfileNames returns the names of all validation profiles
+func ValidationProfileNames() []string {
+	names := make([]string, 0, len(validationProfiles))
+	for _, profile := range validationProfiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// GetValidationProfile returns the validation profile with the given name
+func GetValidationProfile(name string) (ValidationProfile, error) {
+	for _, profile := range validationProfiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return ValidationProfile{}, fmt.Errorf("unknown validation profile %s (possible: %s)", name, strings.Join(ValidationProfileNames(), ", "))
+}
+
+// DefaultValidationProfile returns the profile used by Validate
+func DefaultValidationProfile() ValidationProfile {
+	profile, _ := GetValidationProfile(ValidationProfileHelmPragmatic)
+	return profile
+}
+
+// Has checks if the rule is part of the profile
+func (p ValidationProfile) Has(rule ValidationRule) bool {
+	return slices.Contains(p.Rules, rule)
+}
+
+// AnnotationError is an invalid annotation found by ValidateAnnotations
+type AnnotationError struct {
+	// Key is the path of the annotated key, e.g. image.tag or hosts[].host
+	Key string
+	Err error
+}
+
+func (e AnnotationError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("invalid root annotation: %v", e.Err)
+	}
+	return fmt.Sprintf("invalid annotation of key %s: %v", e.Key, e.Err)
+}
+
+func (e AnnotationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateAnnotations checks the annotated keys (and the root schema) of a generated
+// schema with the rules of the profile. Subschemas of annotations are checked like
+// in Validate, the generated parts of the schema are skipped.
+func (s *Schema) ValidateAnnotations(profile ValidationProfile) []error {
+	var errs []error
+	if err := s.ValidateProfile(profile); err != nil {
+		errs = append(errs, AnnotationError{Err: err})
+	}
+	s.validateAnnotatedProperties("", profile, &errs)
+	return errs
+}
+
+func (s *Schema) validateAnnotatedProperties(prefix string, profile ValidationProfile, errs *[]error) {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		property := s.Properties[name]
+		if property == nil {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		if property.HasData {
+			if err := property.ValidateProfile(profile); err != nil {
+				*errs = append(*errs, AnnotationError{Key: key, Err: err})
+			}
+		}
+		property.validateAnnotatedProperties(key, profile, errs)
+		// the items of generated lists are composed of the schemas of each item
+		if property.Items != nil {
+			property.Items.validateAnnotatedProperties(key+"[]", profile, errs)
+			for _, item := range property.Items.AnyOf {
+				if item != nil {
+					item.validateAnnotatedProperties(key+"[]", profile, errs)
+				}
+			}
+		}
+	}
+}
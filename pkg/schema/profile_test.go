@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidationProfiles(t *testing.T) {
+	minimum := 1
+	tests := []struct {
+		name   string
+		schema Schema
+		// valid lists the profiles the schema is valid in
+		valid []string
+	}{
+		{
+			name:   "enum with type",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, Enum: []any{"a", "b"}},
+			valid:  []string{ValidationProfileLegacy},
+		},
+		{
+			name:   "format with pattern",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, Format: "email", Pattern: "@example.com$"},
+			valid:  []string{ValidationProfileLegacy},
+		},
+		{
+			name:   "unknown format",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, Format: "my-format"},
+			valid:  []string{ValidationProfileLegacy},
+		},
+		{
+			name:   "constraint of another type",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, Minimum: &minimum},
+			valid:  []string{ValidationProfileLegacy},
+		},
+		{
+			name:   "constraint on union",
+			schema: Schema{Type: StringOrArrayOfString{"integer", "string"}, Minimum: &minimum},
+			valid:  []string{ValidationProfileHelmPragmatic, ValidationProfileLegacy},
+		},
+		{
+			name:   "constraint on nullable type",
+			schema: Schema{Type: StringOrArrayOfString{"integer", "null"}, Minimum: &minimum},
+			valid:  []string{ValidationProfileSpecStrict, ValidationProfileHelmPragmatic, ValidationProfileLegacy},
+		},
+		{
+			name:   "constraint on nested union",
+			schema: Schema{AnyOf: []*Schema{{Type: StringOrArrayOfString{"integer", "string"}, MinLength: &minimum}}},
+			valid:  []string{ValidationProfileHelmPragmatic, ValidationProfileLegacy},
+		},
+		{
+			name:   "invalid type",
+			schema: Schema{Type: StringOrArrayOfString{"text"}},
+		},
+		{
+			name:   "invalid range",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, MinLength: intPtr(2), MaxLength: intPtr(1)},
+		},
+		{
+			name:   "content schema",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, ContentMediaType: MediaTypeYAML, ContentSchema: &Schema{Type: StringOrArrayOfString{"object"}}},
+			valid:  []string{ValidationProfileSpecStrict, ValidationProfileHelmPragmatic, ValidationProfileLegacy},
+		},
+		{
+			name:   "content schema without media type",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, ContentSchema: &Schema{Type: StringOrArrayOfString{"object"}}},
+		},
+		{
+			name:   "invalid content schema",
+			schema: Schema{Type: StringOrArrayOfString{"string"}, ContentMediaType: MediaTypeYAML, ContentSchema: &Schema{Type: StringOrArrayOfString{"text"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range ValidationProfileNames() {
+				profile, err := GetValidationProfile(name)
+				assert.NoError(t, err)
+
+				err = test.schema.ValidateProfile(profile)
+				if slices.Contains(test.valid, name) {
+					assert.NoError(t, err, name)
+				} else {
+					assert.Error(t, err, name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetValidationProfile(t *testing.T) {
+	assert.Equal(t, ValidationProfileHelmPragmatic, DefaultValidationProfile().Name)
+
+	_, err := GetValidationProfile("strict")
+	assert.ErrorContains(t, err, "possible: spec-strict, helm-pragmatic, legacy")
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	yamlContent := `# @schema
+# type: string
+# enum: [a, b]
+# @schema
+mode: a
+generated: 1
+nested:
+  # @schema
+  # type: [integer, string]
+  # minimum: 1
+  # @schema
+  port: 1
+list:
+  - # @schema
+    # type: string
+    # format: email
+    # pattern: "@example.com$"
+    # @schema
+    mail: a@example.com
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+	skipConfig, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+
+	// the rules of the profiles are not checked while the schema is generated
+	s := YamlToSchema("values.yaml", &node, false, false, false, true, skipConfig, nil, nil)
+
+	keys := func(errs []error) []string {
+		var keys []string
+		for _, err := range errs {
+			var annotationErr AnnotationError
+			if assert.True(t, errors.As(err, &annotationErr)) {
+				keys = append(keys, annotationErr.Key)
+			}
+		}
+		return keys
+	}
+
+	strict, _ := GetValidationProfile(ValidationProfileSpecStrict)
+	assert.Equal(t, []string{"list[].mail", "mode", "nested.port"}, keys(s.ValidateAnnotations(strict)))
+
+	assert.Equal(t, []string{"list[].mail", "mode"}, keys(s.ValidateAnnotations(DefaultValidationProfile())))
+
+	legacy, _ := GetValidationProfile(ValidationProfileLegacy)
+	assert.Empty(t, s.ValidateAnnotations(legacy))
+}
@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ProvenanceAnnotation records at the root of a schema how it was generated (see Provenance)
+const ProvenanceAnnotation = CustomAnnotationPrefix + "generated-by"
+
+// Provenance describes how a schema was generated, so tools can tell generated schemas from
+// hand-written ones and detect schemas which were edited or generated from other values
+type Provenance struct {
+	// Tool is the name of the generator, e.g. helm-schema
+	Tool string `json:"tool"`
+	// Version is the version of the generator
+	Version string `json:"version"`
+	// FlagsHash is the digest of the options the schema was generated with (see SettingsHash)
+	FlagsHash string `json:"flagsHash"`
+	// ValuesDigest is the sha256 digest of the values files the schema was generated from,
+	// empty if the chart has none
+	ValuesDigest string `json:"valuesDigest,omitempty"`
+}
+
+// SetProvenance embeds the provenance in the root of the schema
+func (s *Schema) SetProvenance(provenance Provenance) {
+	if s.CustomAnnotations == nil {
+		s.CustomAnnotations = make(map[string]interface{})
+	}
+	annotation := map[string]interface{}{
+		"tool":      provenance.Tool,
+		"version":   provenance.Version,
+		"flagsHash": provenance.FlagsHash,
+	}
+	if provenance.ValuesDigest != "" {
+		annotation["valuesDigest"] = provenance.ValuesDigest
+	}
+	s.CustomAnnotations[ProvenanceAnnotation] = annotation
+}
+
+// GetProvenance returns the provenance embedded in the root of the schema, nil if the schema
+// has none (e.g. because it wasn't generated)
+func (s *Schema) GetProvenance() (*Provenance, error) {
+	annotation, ok := s.CustomAnnotations[ProvenanceAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(annotation)
+	if err != nil {
+		return nil, err
+	}
+	var provenance Provenance
+	if err := json.Unmarshal(data, &provenance); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ProvenanceAnnotation, err)
+	}
+	if provenance.Tool == "" {
+		return nil, fmt.Errorf("invalid %s: the tool is missing", ProvenanceAnnotation)
+	}
+	return &provenance, nil
+}
+
+// SettingsHash returns the sha256 digest of the settings, e.g. the flags and the config file.
+// The keys are sorted, so the digest only changes if a setting does.
+func SettingsHash(settings map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		value, err := json.Marshal(settings[key])
+		if err != nil {
+			return "", fmt.Errorf("could not hash the setting %s: %w", key, err)
+		}
+		fmt.Fprintf(hash, "%s=%s\n", key, value)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ValuesDigest returns the sha256 digest of the content of the values files of the result
+// in their order, empty if the chart has none
+func (r Result) ValuesDigest() (string, error) {
+	files := r.ValuesFiles
+	if len(files) == 0 && r.ValuesPath != "" {
+		files = []string{r.ValuesPath}
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+
+	hash := sha256.New()
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
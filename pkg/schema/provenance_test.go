@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	s := NewSchema("object")
+	provenance, err := s.GetProvenance()
+	assert.NoError(t, err)
+	assert.Nil(t, provenance)
+
+	expected := Provenance{Tool: "helm-schema", Version: "0.19.0", FlagsHash: "sha256:abc", ValuesDigest: "sha256:def"}
+	s.SetProvenance(expected)
+	jsonStr, err := s.ToJson()
+	assert.NoError(t, err)
+	assert.Contains(t, string(jsonStr), `"x-generated-by"`)
+
+	// the provenance is read from the written schema
+	loaded, err := Load(bytes.NewReader(jsonStr))
+	assert.NoError(t, err)
+	provenance, err = loaded.GetProvenance()
+	assert.NoError(t, err)
+	assert.Equal(t, &expected, provenance)
+
+	loaded.CustomAnnotations[ProvenanceAnnotation] = "helm-schema"
+	_, err = loaded.GetProvenance()
+	assert.Error(t, err)
+}
+
+func TestSettingsHash(t *testing.T) {
+	hash, err := SettingsHash(map[string]interface{}{"keep-full-comment": true, "skip-auto-generation": []string{"title"}})
+	assert.NoError(t, err)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, hash)
+
+	same, err := SettingsHash(map[string]interface{}{"skip-auto-generation": []string{"title"}, "keep-full-comment": true})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, same)
+
+	other, err := SettingsHash(map[string]interface{}{"keep-full-comment": false, "skip-auto-generation": []string{"title"}})
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestValuesDigest(t *testing.T) {
+	dir := t.TempDir()
+	valuesPath := filepath.Join(dir, "values.yaml")
+	assert.NoError(t, os.WriteFile(valuesPath, []byte("replicas: 1\n"), 0o644))
+
+	digest, err := Result{ValuesPath: valuesPath}.ValuesDigest()
+	assert.NoError(t, err)
+	// sha256 of "replicas: 1\n"
+	assert.Equal(t, "sha256:64c510504df97ba5ad76d9dab24c7550b4b53a6d31adc47a75e6efbe8851ae8c", digest)
+
+	overridesPath := filepath.Join(dir, "values-prod.yaml")
+	assert.NoError(t, os.WriteFile(overridesPath, []byte("replicas: 3\n"), 0o644))
+	merged, err := Result{ValuesPath: valuesPath, ValuesFiles: []string{valuesPath, overridesPath}}.ValuesDigest()
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest, merged)
+
+	digest, err = Result{}.ValuesDigest()
+	assert.NoError(t, err)
+	assert.Empty(t, digest)
+}
@@ -0,0 +1,245 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query returns the subschema at the given path. The path is either a JSON pointer
+// (e.g. "#/properties/image/properties/tag" or "/$defs/port") or a dotted path of keys
+// (e.g. "image.tag"). In dotted paths "[]" addresses the items of an array
+// (e.g. "imagePullSecrets[].name") and "$defs.<name>" or "definitions.<name>" a definition.
+// Internal references ($ref: "#/...") are followed while traversing.
+func (s *Schema) Query(path string) (*Schema, error) {
+	if s == nil {
+		return nil, fmt.Errorf("cannot query %q of an empty schema", path)
+	}
+
+	if path == "" || path == "#" {
+		return s, nil
+	}
+
+	target, _, err := s.queryAddressable(path)
+	return target, err
+}
+
+// queryAddressable returns the subschema at the given path like Query. The schemas of
+// additionalProperties are stored as values, so below them the subschema is a copy.
+// The returned function stores the changed copies back into their parents, it must be
+// called after the subschema has been changed.
+func (s *Schema) queryAddressable(path string) (*Schema, func(), error) {
+	if strings.HasPrefix(path, "#") || strings.HasPrefix(path, "/") {
+		target, writeBacks, err := s.resolvePointer(strings.TrimPrefix(path, "#"), true)
+		return target, commitWriteBacks(writeBacks), err
+	}
+	target, err := s.queryDotted(path)
+	return target, func() {}, err
+}
+
+// commitWriteBacks returns a function which stores the copies back, the innermost first
+func commitWriteBacks(writeBacks []func()) func() {
+	return func() {
+		for i := len(writeBacks) - 1; i >= 0; i-- {
+			writeBacks[i]()
+		}
+	}
+}
+
+// queryPointer resolves a JSON pointer, optionally following internal references on the way
+func (s *Schema) queryPointer(pointer string, followRefs bool) (*Schema, error) {
+	target, _, err := s.resolvePointer(pointer, followRefs)
+	return target, err
+}
+
+// resolvePointer resolves a JSON pointer like queryPointer and returns the write-backs of the
+// copies of additionalProperties schemas the returned schema belongs to (see queryAddressable)
+func (s *Schema) resolvePointer(pointer string, followRefs bool) (*Schema, []func(), error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, nil, fmt.Errorf("invalid json pointer: %s", pointer)
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(pointer[1:], "/") {
+		segment = strings.ReplaceAll(segment, "~1", "/")
+		segment = strings.ReplaceAll(segment, "~0", "~")
+		segments = append(segments, segment)
+	}
+
+	current := s
+	// the schemas below a pointer are shared, only the copies since the last pointer need a write-back
+	var writeBacks []func()
+	for i := 0; i < len(segments); i++ {
+		keyword := segments[i]
+		if followRefs {
+			if target, targetWriteBacks := s.resolveRef(current); target != current {
+				current, writeBacks = target, targetWriteBacks
+			}
+		}
+
+		var next *Schema
+		switch keyword {
+		case "properties", "patternProperties", "$defs", "definitions":
+			if i+1 >= len(segments) {
+				return nil, nil, fmt.Errorf("missing name after %s in %s", keyword, pointer)
+			}
+			i++
+			next = schemaMap(current, keyword)[segments[i]]
+		case "allOf", "anyOf", "oneOf":
+			if i+1 >= len(segments) {
+				return nil, nil, fmt.Errorf("missing index after %s in %s", keyword, pointer)
+			}
+			i++
+			schemas := schemaArray(current, keyword)
+			index, err := strconv.Atoi(segments[i])
+			if err != nil || index < 0 || index >= len(schemas) {
+				return nil, nil, fmt.Errorf("invalid index %s of %s in %s", segments[i], keyword, pointer)
+			}
+			next = schemas[index]
+		case "items":
+			next = current.Items
+		case "if":
+			next = current.If
+		case "then":
+			next = current.Then
+		case "else":
+			next = current.Else
+		case "not":
+			next = current.Not
+		case "contentSchema":
+			next = current.ContentSchema
+		case "propertyNames":
+			next = current.PropertyNames
+		case "additionalProperties":
+			if subSchema, ok := current.AdditionalProperties.(Schema); ok {
+				parent, copied := current, &subSchema
+				writeBacks = append(writeBacks, func() { parent.AdditionalProperties = *copied })
+				current = copied
+				continue
+			}
+		default:
+			return nil, nil, fmt.Errorf("unsupported keyword %s in %s", keyword, pointer)
+		}
+
+		if next == nil {
+			return nil, nil, fmt.Errorf("could not find %s", "/"+strings.Join(segments[:i+1], "/"))
+		}
+		current = next
+		writeBacks = nil
+	}
+
+	return current, writeBacks, nil
+}
+
+// queryDotted resolves a dotted path of keys
+func (s *Schema) queryDotted(path string) (*Schema, error) {
+	segments := strings.Split(path, ".")
+
+	current := s
+	for i := 0; i < len(segments); i++ {
+		segment := segments[i]
+
+		if (segment == "$defs" || segment == "definitions") && i+1 < len(segments) {
+			i++
+			next := schemaMap(s.followRef(current), segment)[segments[i]]
+			if next == nil {
+				return nil, fmt.Errorf("could not find %s", strings.Join(segments[:i+1], "."))
+			}
+			current = next
+			continue
+		}
+
+		isArray := strings.HasSuffix(segment, "[]")
+		key := strings.TrimSuffix(segment, "[]")
+
+		if key != "" {
+			next := s.findProperty(current, key, map[*Schema]bool{})
+			if next == nil {
+				return nil, fmt.Errorf("could not find %s", strings.Join(segments[:i+1], "."))
+			}
+			current = next
+		}
+
+		if isArray {
+			current = s.followRef(current)
+			if current.Items == nil {
+				return nil, fmt.Errorf("%s is not an array", strings.Join(segments[:i+1], "."))
+			}
+			current = current.Items
+		}
+	}
+
+	return current, nil
+}
+
+// findProperty returns the property of the schema with the given name.
+// Properties of composed schemas (allOf, anyOf, oneOf) are considered as well.
+func (s *Schema) findProperty(current *Schema, name string, visited map[*Schema]bool) *Schema {
+	current = s.followRef(current)
+	if visited[current] {
+		return nil
+	}
+	visited[current] = true
+
+	if v, ok := current.Properties[name]; ok {
+		return v
+	}
+
+	for _, schemas := range [][]*Schema{current.AllOf, current.AnyOf, current.OneOf} {
+		for _, v := range schemas {
+			if found := s.findProperty(v, name, visited); found != nil {
+				return found
+			}
+		}
+	}
+
+	return nil
+}
+
+// followRef resolves internal references of current against s
+func (s *Schema) followRef(current *Schema) *Schema {
+	target, _ := s.resolveRef(current)
+	return target
+}
+
+// resolveRef resolves internal references of current against s like followRef and returns
+// the write-backs of the resolved schema (see queryAddressable)
+func (s *Schema) resolveRef(current *Schema) (*Schema, []func()) {
+	var writeBacks []func()
+	seen := map[string]bool{}
+	for current.Ref != "" && strings.HasPrefix(current.Ref, "#/") && !seen[current.Ref] {
+		seen[current.Ref] = true
+		target, targetWriteBacks, err := s.resolvePointer(strings.TrimPrefix(current.Ref, "#"), false)
+		if err != nil {
+			break
+		}
+		current, writeBacks = target, targetWriteBacks
+	}
+	return current, writeBacks
+}
+
+func schemaMap(s *Schema, keyword string) map[string]*Schema {
+	switch keyword {
+	case "properties":
+		return s.Properties
+	case "patternProperties":
+		return s.PatternProperties
+	case "$defs":
+		return s.Defs
+	case "definitions":
+		return s.Definitions
+	}
+	return nil
+}
+
+func schemaArray(s *Schema, keyword string) []*Schema {
+	switch keyword {
+	case "allOf":
+		return s.AllOf
+	case "anyOf":
+		return s.AnyOf
+	case "oneOf":
+		return s.OneOf
+	}
+	return nil
+}
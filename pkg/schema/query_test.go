@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	port := &Schema{Type: StringOrArrayOfString{"integer"}, Title: "port"}
+	tag := &Schema{Type: StringOrArrayOfString{"string"}, Title: "tag"}
+	secretName := &Schema{Type: StringOrArrayOfString{"string"}, Title: "name"}
+	loop := &Schema{}
+	loop.AnyOf = []*Schema{{Ref: "#/$defs/loop"}}
+
+	root := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"image": {
+				Properties: map[string]*Schema{"tag": tag, "a/b": tag},
+			},
+			"service": {Ref: "#/$defs/service"},
+			"imagePullSecrets": {
+				Items: &Schema{
+					AnyOf: []*Schema{
+						{Type: StringOrArrayOfString{"string"}},
+						{Properties: map[string]*Schema{"name": secretName}},
+					},
+				},
+			},
+			"loop": {Ref: "#/$defs/loop"},
+		},
+		Defs: map[string]*Schema{
+			"service": {Properties: map[string]*Schema{"port": port}},
+			"loop":    loop,
+		},
+	}
+
+	tests := []struct {
+		path        string
+		expected    *Schema
+		expectedErr bool
+	}{
+		{path: "", expected: root},
+		{path: "#", expected: root},
+		{path: "image.tag", expected: tag},
+		{path: "#/properties/image/properties/tag", expected: tag},
+		{path: "/properties/image/properties/a~1b", expected: tag},
+		{path: "service.port", expected: port},
+		{path: "#/properties/service/properties/port", expected: port},
+		{path: "$defs.service.port", expected: port},
+		{path: "#/$defs/service/properties/port", expected: port},
+		{path: "imagePullSecrets[].name", expected: secretName},
+		{path: "#/properties/imagePullSecrets/items/anyOf/1/properties/name", expected: secretName},
+		{path: "image.doesnotexist", expectedErr: true},
+		{path: "image[]", expectedErr: true},
+		{path: "#/properties/imagePullSecrets/items/anyOf/2", expectedErr: true},
+		{path: "#/properties", expectedErr: true},
+		{path: "#/foo", expectedErr: true},
+		{path: "loop.foo", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := root.Query(tt.path)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Same(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadmeRow is a row of a values table generated by helm-docs
+type ReadmeRow struct {
+	// Line is the line of the row in the readme
+	Line        int
+	Key         string
+	Type        string
+	Default     string
+	Description string
+}
+
+// ReadmeMismatch is a difference between the schema and the values table of the readme
+type ReadmeMismatch struct {
+	Key string
+	// Field is one of key, type, default or description
+	Field  string
+	Schema string
+	Readme string
+	Line   int
+}
+
+func (m ReadmeMismatch) String() string {
+	switch {
+	case m.Field == "key" && m.Line == 0:
+		return fmt.Sprintf("%s is missing in the readme", m.Key)
+	case m.Field == "key":
+		return fmt.Sprintf("%s (line %d) is missing in the schema", m.Key, m.Line)
+	}
+	return fmt.Sprintf("%s of %s (line %d) differs: schema has %q, readme has %q", m.Field, m.Key, m.Line, m.Schema, m.Readme)
+}
+
+var (
+	readmeHTMLTagMatcher = regexp.MustCompile(`</?(pre|code)[^>]*>`)
+	readmeBreakMatcher   = regexp.MustCompile(`<br\s*/?>`)
+)
+
+// ParseHelmDocsTable returns the rows of all tables with a Key and a Description column
+func ParseHelmDocsTable(content []byte) ([]ReadmeRow, error) {
+	var rows []ReadmeRow
+	var columns map[string]int
+	var pending string
+	pendingLine := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		// helm-docs renders defaults over multiple lines (e.g. <pre>), so rows are joined
+		// until they end with a pipe
+		if pending != "" {
+			pending += "\n" + strings.TrimRight(scanner.Text(), " \t")
+			if !strings.HasSuffix(line, "|") {
+				continue
+			}
+			line = strings.TrimSpace(pending)
+			pending = ""
+		} else if columns != nil && strings.HasPrefix(line, "|") && !strings.HasSuffix(line, "|") {
+			pending = line
+			pendingLine = lineNumber
+			continue
+		} else {
+			pendingLine = lineNumber
+		}
+
+		if !strings.HasPrefix(line, "|") {
+			columns = nil
+			continue
+		}
+
+		cells := splitTableRow(line)
+		if columns == nil {
+			columns = tableColumns(cells)
+			continue
+		}
+		if isTableSeparator(cells) {
+			continue
+		}
+
+		cell := func(name string) string {
+			if index, ok := columns[name]; ok && index < len(cells) {
+				return cells[index]
+			}
+			return ""
+		}
+		rows = append(rows, ReadmeRow{
+			Line:        pendingLine,
+			Key:         strings.Trim(cell("key"), "`"),
+			Type:        cell("type"),
+			Default:     cleanReadmeDefault(cell("default")),
+			Description: cell("description"),
+		})
+	}
+
+	return rows, scanner.Err()
+}
+
+// splitTableRow splits a markdown table row into its cells, escaped pipes are kept
+func splitTableRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// tableColumns returns the indices of the helm-docs columns or nil if the header isn't a values table
+func tableColumns(header []string) map[string]int {
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(name)] = i
+	}
+	_, hasKey := columns["key"]
+	_, hasDescription := columns["description"]
+	if !hasKey || !hasDescription {
+		return nil
+	}
+	return columns
+}
+
+func isTableSeparator(cells []string) bool {
+	for _, cell := range cells {
+		if strings.Trim(cell, ":-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanReadmeDefault removes the markup helm-docs adds around default values
+func cleanReadmeDefault(value string) string {
+	value = readmeHTMLTagMatcher.ReplaceAllString(value, "")
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "`") && strings.HasSuffix(value, "`") && len(value) > 1 {
+		value = value[1 : len(value)-1]
+	}
+	return strings.TrimSpace(value)
+}
+
+// CompareReadme compares the schema with the rows of the helm-docs values table.
+// Keys missing on either side, differing types, defaults and descriptions are returned.
+// Top level keys which are ignored (e.g. the values of dependencies) may be missing in the readme.
+func (s *Schema) CompareReadme(rows []ReadmeRow, ignoredKeys ...string) []ReadmeMismatch {
+	var mismatches []ReadmeMismatch
+	documented := make(map[string]bool)
+
+	for _, row := range rows {
+		documented[row.Key] = true
+		// lists are documented, if their items are (e.g. hosts[0].host)
+		if key, _, ok := strings.Cut(row.Key, "["); ok {
+			documented[key] = true
+		}
+
+		property := lookupReadmeKey(s, row.Key)
+		if property == nil {
+			mismatches = append(mismatches, ReadmeMismatch{Key: row.Key, Field: "key", Line: row.Line})
+			continue
+		}
+
+		if schemaType, err := helmDocsTypeToSchemaType(row.Type); err == nil && !property.Type.IsEmpty() && !property.Type.Matches(schemaType) {
+			mismatches = append(mismatches, ReadmeMismatch{Key: row.Key, Field: "type", Schema: strings.Join(property.Type, ", "), Readme: row.Type, Line: row.Line})
+		}
+
+		if property.Default != nil && row.Default != "" && !readmeDefaultEquals(property.Default, row.Default) {
+			schemaDefault, _ := json.Marshal(property.Default)
+			mismatches = append(mismatches, ReadmeMismatch{Key: row.Key, Field: "default", Schema: string(schemaDefault), Readme: row.Default, Line: row.Line})
+		}
+
+		if normalizeReadmeText(property.Description) != normalizeReadmeText(row.Description) {
+			mismatches = append(mismatches, ReadmeMismatch{Key: row.Key, Field: "description", Schema: property.Description, Readme: row.Description, Line: row.Line})
+		}
+	}
+
+	for _, key := range readmeLeafKeys(s, "") {
+		topLevelKey, _, _ := strings.Cut(key, ".")
+		if !documented[key] && !slices.Contains(ignoredKeys, topLevelKey) {
+			mismatches = append(mismatches, ReadmeMismatch{Key: key, Field: "key"})
+		}
+	}
+
+	return mismatches
+}
+
+// lookupReadmeKey returns the property of a helm-docs key (e.g. image.tag, hosts[0].host or annotations."a.b")
+func lookupReadmeKey(s *Schema, key string) *Schema {
+	current := s
+	for _, segment := range splitReadmeKey(key) {
+		name, indices, _ := strings.Cut(segment, "[")
+		if name != "" {
+			// the items of generated lists are composed of the schemas of each item
+			current = s.findProperty(current, strings.Trim(name, `"`), map[*Schema]bool{})
+			if current == nil {
+				return nil
+			}
+		}
+		for indices != "" {
+			if current.Items == nil {
+				return nil
+			}
+			current = current.Items
+			_, indices, _ = strings.Cut(indices, "[")
+		}
+	}
+	return current
+}
+
+// splitReadmeKey splits a helm-docs key at the dots which are not quoted
+func splitReadmeKey(key string) []string {
+	var segments []string
+	var segment strings.Builder
+	quoted := false
+	for _, r := range key {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			segment.WriteRune(r)
+		case r == '.' && !quoted:
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteRune(r)
+		}
+	}
+	return append(segments, segment.String())
+}
+
+// readmeLeafKeys returns the keys helm-docs lists for the schema (properties without subproperties)
+func readmeLeafKeys(s *Schema, prefix string) []string {
+	var keys []string
+	for name, property := range s.Properties {
+		if prefix == "" && name == "global" {
+			continue
+		}
+		key := name
+		if strings.Contains(name, ".") {
+			key = strconv.Quote(name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if len(property.Properties) > 0 {
+			keys = append(keys, readmeLeafKeys(property, key)...)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readmeDefaultEquals checks if the default of the readme (json, yaml or
+// a custom text of @default) represents the default of the schema
+func readmeDefaultEquals(schemaDefault interface{}, readmeDefault string) bool {
+	// helm-docs trims block scalars
+	if text, ok := schemaDefault.(string); ok && strings.TrimSpace(text) == readmeDefault {
+		return true
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(readmeDefault), &parsed); err != nil {
+		return false
+	}
+
+	normalize := func(v interface{}) interface{} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var normalized interface{}
+		_ = json.Unmarshal(data, &normalized)
+		return normalized
+	}
+	return reflect.DeepEqual(normalize(schemaDefault), normalize(parsed))
+}
+
+// normalizeReadmeText makes descriptions of the schema and the readme comparable
+func normalizeReadmeText(text string) string {
+	text = readmeBreakMatcher.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(text), " ")
+}
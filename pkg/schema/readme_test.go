@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const helmDocsReadme = `# test
+
+## Values
+
+| Key | Type | Default | Description |
+|-----|------|---------|-------------|
+| hosts | list | <pre lang="json">
+[
+  {
+    "host": "example.com"
+  }
+]
+</pre> | The hosts |
+| hosts[0].host | string | ` + "`\"example.com\"`" + ` |  |
+| image.repository | string | ` + "`\"nginx\"`" + ` | The repository |
+| image.tag | string | ` + "`\"1.0\"`" + ` | The tag \| or digest |
+| podAnnotations."a.b" | string | ` + "`\"c\"`" + ` | Dotted key |
+| replicas | int | ` + "`2`" + ` | The number of replicas |
+| removed | string | ` + "`\"\"`" + ` | Not in the values anymore |
+
+## Other
+
+| Name | Email |
+|------|-------|
+| foo | foo@example.com |
+`
+
+func TestParseHelmDocsTable(t *testing.T) {
+	rows, err := ParseHelmDocsTable([]byte(helmDocsReadme))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 7)
+
+	assert.Equal(t, ReadmeRow{Line: 7, Key: "hosts", Type: "list", Default: "[\n  {\n    \"host\": \"example.com\"\n  }\n]", Description: "The hosts"}, rows[0])
+	assert.Equal(t, ReadmeRow{Line: 14, Key: "hosts[0].host", Type: "string", Default: `"example.com"`, Description: ""}, rows[1])
+	assert.Equal(t, "The tag | or digest", rows[3].Description)
+	assert.Equal(t, "removed", rows[6].Key)
+}
+
+func TestCompareReadme(t *testing.T) {
+	yamlContent := `# -- The hosts
+hosts:
+  - host: example.com
+image:
+  # -- The repository
+  repository: nginx
+  # -- The tag | or digest
+  tag: "1.1"
+podAnnotations:
+  # -- Dotted key
+  a.b: c
+# -- The number of replicas to run
+replicas: 2
+# -- Not documented
+undocumented: true
+# -- Values of a dependency
+postgresql:
+  enabled: true
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, true, false, false, config, nil, nil)
+
+	rows, err := ParseHelmDocsTable([]byte(helmDocsReadme))
+	assert.NoError(t, err)
+
+	var messages []string
+	for _, mismatch := range schema.CompareReadme(rows, "postgresql") {
+		messages = append(messages, mismatch.String())
+	}
+	assert.Equal(t, []string{
+		`default of image.tag (line 16) differs: schema has "\"1.1\"", readme has "\"1.0\""`,
+		`description of replicas (line 18) differs: schema has "The number of replicas to run", readme has "The number of replicas"`,
+		`removed (line 19) is missing in the schema`,
+		`undocumented is missing in the readme`,
+	}, messages)
+}
+
+func TestReadmeDefaultEquals(t *testing.T) {
+	assert.True(t, readmeDefaultEquals("nginx", `"nginx"`))
+	assert.True(t, readmeDefaultEquals("the chart version", "the chart version"))
+	assert.True(t, readmeDefaultEquals("app: x\n", "app: x"))
+	assert.True(t, readmeDefaultEquals(int64(1), "1"))
+	assert.True(t, readmeDefaultEquals(true, "true"))
+	assert.True(t, readmeDefaultEquals([]interface{}{"a"}, `["a"]`))
+	assert.False(t, readmeDefaultEquals("1", "1"+"0"))
+	assert.False(t, readmeDefaultEquals(1, `"1"`))
+}
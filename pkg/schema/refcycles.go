@@ -0,0 +1,265 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// RefCyclesError reports cycles of internal references as errors
+	RefCyclesError = "error"
+	// RefCyclesExpand inlines the references of cycles up to a depth (see BreakRefCycles)
+	RefCyclesExpand = "expand"
+)
+
+// RefCyclesModes are all modes to handle cycles of internal references
+var RefCyclesModes = []string{RefCyclesError, RefCyclesExpand}
+
+// RefSite is a schema with an internal $ref
+type RefSite struct {
+	// Path is the JSON pointer of the schema
+	Path string
+	// Ref is its $ref
+	Ref string
+}
+
+// RefCycle is a chain of internal references, the $ref of each schema leads to (a parent of) the
+// next one and the $ref of the last one leads back to the first one
+type RefCycle []RefSite
+
+func (c RefCycle) String() string {
+	steps := make([]string, 0, len(c))
+	for _, site := range c {
+		steps = append(steps, fmt.Sprintf("%s ($ref %s)", pointerOrRoot(site.Path), site.Ref))
+	}
+	return strings.Join(steps, " -> ")
+}
+
+// RefCycles returns a cycle of internal references for each group of references which lead to
+// each other without validating a property or item in between (e.g. a definition whose allOf
+// references itself). Merging definitions and the schemas of dependencies can create these
+// cycles, which hang validators. Recursive schemas (e.g. a tree node referencing itself in the
+// items of its children) are no cycles, because each reference validates a nested value.
+func (s *Schema) RefCycles() []RefCycle {
+	var cycles []RefCycle
+	for _, group := range s.refGroups() {
+		cycles = append(cycles, group.cycle)
+	}
+	return cycles
+}
+
+// refGroup are references which lead to each other, with a cycle through them
+type refGroup struct {
+	cycle RefCycle
+	sites []RefSite
+}
+
+func (s *Schema) refGroups() []refGroup {
+	var sites []RefSite
+	_ = s.Walk(func(path string, v *Schema) error {
+		if isInternalRef(v.Ref) {
+			sites = append(sites, RefSite{Path: path, Ref: v.Ref})
+		}
+		return nil
+	})
+
+	// the references of a schema lead to the references in the target, which apply to the same value
+	next := make([][]int, len(sites))
+	for i, site := range sites {
+		target := strings.TrimPrefix(site.Ref, "#")
+		for j, other := range sites {
+			if rest, ok := strings.CutPrefix(other.Path, target); ok && (rest == "" || strings.HasPrefix(rest, "/")) && appliesInPlace(rest) {
+				next[i] = append(next[i], j)
+			}
+		}
+	}
+
+	var groups []refGroup
+	for _, component := range stronglyConnected(next) {
+		start := component[0]
+		if len(component) == 1 && !slices.Contains(next[start], start) {
+			continue
+		}
+		var group refGroup
+		for _, i := range pathBack(next, component, start) {
+			group.cycle = append(group.cycle, sites[i])
+		}
+		for _, i := range component {
+			group.sites = append(group.sites, sites[i])
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// appliesInPlace checks if the subschema at the relative pointer applies to the same value as its
+// parent, i.e. the pointer only passes keywords like allOf or not, which don't descend into the
+// properties or items of the value
+func appliesInPlace(pointer string) bool {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i := 0; i < len(segments); i++ {
+		switch segments[i] {
+		case "", "not", "if", "then", "else":
+		case "allOf", "anyOf", "oneOf":
+			// the index of the subschema
+			i++
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// BreakRefCycles replaces the last reference of each cycle with the schema it references,
+// in which the references of the cycle are expanded the same way, until depth schemas
+// are inlined. The references left at this depth are dropped, so the innermost schemas
+// accept any value. It returns the broken cycles.
+func (s *Schema) BreakRefCycles(depth int) ([]RefCycle, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("the depth of expanded $ref cycles must not be negative, got %d", depth)
+	}
+
+	var broken []RefCycle
+	// every pass replaces a reference of a cycle by a schema without references of the cycle
+	for groups := s.refGroups(); len(groups) > 0; groups = s.refGroups() {
+		// all references leading to each other are expanded, not only the ones of the cycle
+		refs := map[string]bool{}
+		for _, site := range groups[0].sites {
+			refs[site.Ref] = true
+		}
+
+		cycle := groups[0].cycle
+		last := cycle[len(cycle)-1]
+		err := s.Walk(func(path string, v *Schema) error {
+			if path != last.Path {
+				return nil
+			}
+			expanded, err := s.expandRef(v, refs, depth)
+			if err != nil {
+				return err
+			}
+			*v = *expanded
+			return SkipSchema
+		})
+		if err != nil {
+			return broken, err
+		}
+		broken = append(broken, cycle)
+	}
+	return broken, nil
+}
+
+// expandRef returns the schema with the target of its $ref inlined. The references to the
+// given targets in the inlined schema are expanded again, until the depth is reached.
+func (s *Schema) expandRef(site *Schema, refs map[string]bool, depth int) (*Schema, error) {
+	expanded := site.Clone()
+	expanded.Ref = ""
+	if depth == 0 {
+		return expanded, nil
+	}
+
+	target, err := s.queryPointer(strings.TrimPrefix(site.Ref, "#"), false)
+	if err != nil {
+		return nil, fmt.Errorf("could not expand $ref %s: %w", site.Ref, err)
+	}
+	inlined := target.Clone()
+	err = inlined.Walk(func(_ string, v *Schema) error {
+		if !refs[v.Ref] {
+			return nil
+		}
+		nested, err := s.expandRef(v, refs, depth-1)
+		if err != nil {
+			return err
+		}
+		*v = *nested
+		return SkipSchema
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// keywords next to the $ref apply as well
+	if expanded.EqualsOpt(&Schema{}, EqualsFull) {
+		return inlined, nil
+	}
+	expanded.AllOf = append(expanded.AllOf, inlined)
+	return expanded, nil
+}
+
+// stronglyConnected returns the groups of nodes which lead to each other (Tarjan's algorithm),
+// the nodes of each group are sorted
+func stronglyConnected(next [][]int) [][]int {
+	index := make([]int, len(next))
+	lowLink := make([]int, len(next))
+	onStack := make([]bool, len(next))
+	var stack []int
+	var components [][]int
+	counter := 0
+
+	var visit func(v int)
+	visit = func(v int) {
+		counter++
+		index[v], lowLink[v] = counter, counter
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range next[v] {
+			if index[w] == 0 {
+				visit(w)
+				lowLink[v] = min(lowLink[v], lowLink[w])
+			} else if onStack[w] {
+				lowLink[v] = min(lowLink[v], index[w])
+			}
+		}
+
+		if lowLink[v] == index[v] {
+			var component []int
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			slices.Sort(component)
+			components = append(components, component)
+		}
+	}
+
+	for v := range next {
+		if index[v] == 0 {
+			visit(v)
+		}
+	}
+	slices.SortFunc(components, func(a, b []int) int { return a[0] - b[0] })
+	return components
+}
+
+// pathBack returns the shortest path from start through the nodes of the component back to start
+func pathBack(next [][]int, component []int, start int) []int {
+	previous := map[int]int{}
+	queue := []int{start}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range next[v] {
+			if w == start {
+				path := []int{v}
+				for v != start {
+					v = previous[v]
+					path = append(path, v)
+				}
+				slices.Reverse(path)
+				return path
+			}
+			if _, seen := previous[w]; !seen && slices.Contains(component, w) {
+				previous[w] = v
+				queue = append(queue, w)
+			}
+		}
+	}
+	return []int{start}
+}
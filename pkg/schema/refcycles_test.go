@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mutualRefSchema() *Schema {
+	return &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"tree": {Ref: "#/$defs/a"},
+			"name": {Ref: "#/$defs/leaf"},
+		},
+		Defs: map[string]*Schema{
+			"a":    {Type: StringOrArrayOfString{"object"}, AllOf: []*Schema{{Ref: "#/$defs/b"}}},
+			"b":    {Type: StringOrArrayOfString{"object"}, AllOf: []*Schema{{Ref: "#/$defs/a"}}},
+			"leaf": {Type: StringOrArrayOfString{"string"}},
+		},
+	}
+}
+
+func TestRefCycles(t *testing.T) {
+	cycles := mutualRefSchema().RefCycles()
+	assert.Len(t, cycles, 1)
+	assert.Equal(t, "#/$defs/a/allOf/0 ($ref #/$defs/b) -> #/$defs/b/allOf/0 ($ref #/$defs/a)", cycles[0].String())
+
+	// references into the definitions of a target don't lead to them
+	s := &Schema{
+		Properties: map[string]*Schema{"root": {Ref: "#/properties/config"}},
+	}
+	s.Properties["config"] = &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Defs: map[string]*Schema{"self": {Ref: "#/properties/config"}},
+	}
+	assert.Empty(t, s.RefCycles())
+
+	assert.Empty(t, (&Schema{Properties: map[string]*Schema{"name": {Ref: "#/$defs/leaf"}}}).RefCycles())
+
+	self := &Schema{Defs: map[string]*Schema{"loop": {Not: &Schema{Ref: "#/$defs/loop"}}}}
+	cycles = self.RefCycles()
+	assert.Len(t, cycles, 1)
+	assert.Equal(t, "#/$defs/loop/not ($ref #/$defs/loop)", cycles[0].String())
+}
+
+func TestBreakRefCycles(t *testing.T) {
+	s := mutualRefSchema()
+	broken, err := s.BreakRefCycles(1)
+	assert.NoError(t, err)
+	assert.Len(t, broken, 1)
+	assert.Empty(t, s.RefCycles())
+
+	// the last reference of the cycle is inlined, its reference back is dropped
+	inlined := s.Defs["b"].AllOf[0]
+	assert.Equal(t, StringOrArrayOfString{"object"}, inlined.Type)
+	assert.Empty(t, inlined.AllOf[0].Ref)
+	assert.Empty(t, inlined.AllOf[0].Type)
+	assert.Equal(t, "#/$defs/b", s.Defs["a"].AllOf[0].Ref)
+	assert.Equal(t, "#/$defs/a", s.Properties["tree"].Ref)
+
+	_, err = mutualRefSchema().BreakRefCycles(-1)
+	assert.Error(t, err)
+}
+
+func TestRecursiveRefIsNoCycle(t *testing.T) {
+	// every validator handles recursive definitions, each reference validates a nested value
+	s := &Schema{
+		Properties: map[string]*Schema{"tree": {Ref: "#/$defs/node"}},
+		Defs: map[string]*Schema{
+			"node": {
+				Type: StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{
+					"children": {Type: StringOrArrayOfString{"array"}, Items: &Schema{Ref: "#/$defs/node"}},
+				},
+				AdditionalProperties: Schema{Ref: "#/$defs/node"},
+			},
+		},
+	}
+	assert.Empty(t, s.RefCycles())
+
+	broken, err := s.BreakRefCycles(2)
+	assert.NoError(t, err)
+	assert.Empty(t, broken)
+	assert.Equal(t, "#/$defs/node", s.Defs["node"].Properties["children"].Items.Ref)
+}
@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/reflock"
+)
+
+// refLock verifies the downloaded referenced schemas, it is nil if no lock file is used
+var refLock *reflock.Lock
+
+// reportedDrift contains the references whose drift was reported as warning already
+var reportedDrift sync.Map
+
+// UseRefLock sets the lock, which records and verifies the digests of the schemas of url, git
+// and registry references. It is not safe to change the lock while schemas are generated.
+func UseRefLock(lock *reflock.Lock) {
+	refLock = lock
+	reportedDrift.Clear()
+}
+
+// lockRef verifies the downloaded schema of the reference (without json pointer) with the lock.
+// Changed schemas are errors with reflock.DriftFail, otherwise they are reported once as warning.
+func lockRef(ref string, content []byte, log logging.Logger) error {
+	if refLock == nil {
+		return nil
+	}
+	err := refLock.Verify(ref, content)
+	var drift *reflock.DriftError
+	if errors.As(err, &drift) && !refLock.FailOnDrift() {
+		if _, reported := reportedDrift.LoadOrStore(ref, true); !reported {
+			log.Warnf("%s", err)
+		}
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package schema
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/reflock"
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefLock(t *testing.T) {
+	content := `{"type": "string"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+	defer UseURLFetcher(urlref.NewFetcher())
+	defer UseRefLock(nil)
+
+	path := filepath.Join(t.TempDir(), reflock.DefaultFileName)
+	ref := server.URL + "/common.json"
+	fetch := func(drift string) error {
+		t.Helper()
+		lock, err := reflock.Load(path, drift)
+		assert.NoError(t, err)
+		UseRefLock(lock)
+		// a new fetcher, so the schema is downloaded again
+		UseURLFetcher(urlref.NewFetcher())
+		_, err = fetchURLRef(context.Background(), ref, logger)
+		assert.NoError(t, lock.Save())
+		return err
+	}
+
+	assert.NoError(t, fetch(reflock.DriftFail))
+	content = `{"type": "integer"}`
+	// changed schemas are only reported with warn
+	assert.NoError(t, fetch(reflock.DriftWarn))
+	assert.ErrorContains(t, fetch(reflock.DriftFail), "changed since it was locked")
+	assert.NoError(t, fetch(reflock.DriftUpdate))
+	assert.NoError(t, fetch(reflock.DriftFail))
+}
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"context"
+	"slices"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+const (
+	// RefModeInlineFiles inlines the schemas of file, git and registry references,
+	// references to urls are kept (default)
+	RefModeInlineFiles = "inline-files"
+	// RefModeInlineAll inlines the schemas of all references, including the ones of urls
+	RefModeInlineAll = "inline-all"
+	// RefModeKeepAll keeps all references as they are written in the annotations
+	RefModeKeepAll = "keep-all"
+)
+
+// RefModes returns the names of all modes of the external references
+func RefModes() []string {
+	return []string{RefModeInlineFiles, RefModeInlineAll, RefModeKeepAll}
+}
+
+// urlFetcher downloads the url references with RefModeInlineAll
+var urlFetcher = urlref.NewFetcher()
+
+// UseURLFetcher sets the fetcher which downloads the schemas of url references with
+// RefModeInlineAll. It is not safe to change the fetcher while schemas are generated.
+func UseURLFetcher(fetcher *urlref.Fetcher) {
+	urlFetcher = fetcher
+}
+
+// fetchURLRef returns the schema referenced by the url (without json pointer)
+func fetchURLRef(ctx context.Context, ref string, log logging.Logger) ([]byte, error) {
+	content, err := urlFetcher.FetchContext(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockRef(ref, content, log); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// refLoader returns the loader of the documents referenced by referenced documents (see refExtractor),
+// the downloads are canceled with the context of the generation
+func (g *generation) refLoader() func(uri string) ([]byte, error) {
+	load := refDocumentLoader(g.context(), g.log())
+	return func(uri string) ([]byte, error) {
+		if g.RefMode == RefModeInlineAll && urlref.IsRef(uri) {
+			return fetchURLRef(g.context(), uri, g.log())
+		}
+		if util.IsFileURL(uri) {
+			if p, err := util.FileURLToPath(uri); err == nil {
+				g.recordRefFile(p)
+			}
+		}
+		return load(uri)
+	}
+}
+
+// UnresolvedRefs returns the external references of the schema, which are not inlined,
+// e.g. references to urls. The published schema still depends on them.
+func (s *Schema) UnresolvedRefs() []string {
+	var refs []string
+	collectSchemaRefs(s, &refs)
+	slices.Sort(refs)
+	return refs
+}
@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRefModes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"definitions": {"port": {"type": "integer", "minimum": 1}}}`))
+	}))
+	defer server.Close()
+	UseURLFetcher(urlref.NewFetcher())
+	defer UseURLFetcher(urlref.NewFetcher())
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "host.json"), []byte(`{"type": "string", "format": "hostname"}`), 0o644))
+	valuesPath := filepath.Join(dir, "values.yaml")
+	values := `# @schema
+# $ref: ` + server.URL + `/common.json#/definitions/port
+# @schema
+port: 80
+# @schema
+# $ref: host.json
+# @schema
+host: example.com
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+
+	generate := func(mode string) *Schema {
+		t.Helper()
+		s, err := GenerationPolicy{RefMode: mode}.ToSchema(valuesPath, &node)
+		assert.NoError(t, err)
+		return s
+	}
+
+	s := generate("")
+	assert.Equal(t, server.URL+"/common.json#/definitions/port", s.Properties["port"].Ref)
+	assert.Equal(t, "hostname", s.Properties["host"].Format)
+	assert.Equal(t, []string{server.URL + "/common.json#/definitions/port"}, s.UnresolvedRefs())
+
+	s = generate(RefModeInlineAll)
+	assert.Equal(t, "#/definitions/port", s.Properties["port"].Ref)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, s.Definitions["port"].Type)
+	assert.Equal(t, "hostname", s.Properties["host"].Format)
+	assert.Empty(t, s.UnresolvedRefs())
+	assert.NoError(t, s.ValidateInternalRefs())
+
+	s = generate(RefModeKeepAll)
+	assert.Equal(t, server.URL+"/common.json#/definitions/port", s.Properties["port"].Ref)
+	assert.Equal(t, "host.json", s.Properties["host"].Ref)
+	assert.Empty(t, s.Properties["host"].Format)
+	assert.Equal(t, []string{"host.json", server.URL + "/common.json#/definitions/port"}, s.UnresolvedRefs())
+
+	assert.ErrorContains(t, GenerationPolicy{RefMode: "inline-some"}.Validate(), "invalid ref mode")
+}
+
+func TestRefLoaderContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type": "string"}`))
+	}))
+	defer server.Close()
+	UseURLFetcher(urlref.NewFetcher())
+	defer UseURLFetcher(urlref.NewFetcher())
+
+	// the downloads of referenced documents are canceled with the generation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	gen := &generation{GenerationPolicy: GenerationPolicy{RefMode: RefModeInlineAll}, ctx: ctx}
+	_, err := gen.refLoader()(server.URL + "/common.json")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	gen.ctx = nil
+	content, err := gen.refLoader()(server.URL + "/common.json")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "string"}`, string(content))
+}
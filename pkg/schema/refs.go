@@ -0,0 +1,205 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+// isInternalRef checks if the reference points into the schema itself (e.g. "#/properties/image")
+func isInternalRef(ref string) bool {
+	return strings.HasPrefix(ref, "#/")
+}
+
+// isDefinitionRef checks if the internal reference points to $defs or definitions.
+// Definitions are always merged into the root schema, so these references never change.
+func isDefinitionRef(ref string) bool {
+	return strings.HasPrefix(ref, "#/$defs/") || strings.HasPrefix(ref, "#/definitions/")
+}
+
+// ValidateInternalRefs checks that all internal references (e.g. "#/properties/image")
+// point to an existing subschema and don't form a cycle without any content.
+// This can only be done after the whole schema is generated.
+func (s *Schema) ValidateInternalRefs() error {
+	var errs []error
+
+	_ = s.Walk(func(path string, v *Schema) error {
+		if !isInternalRef(v.Ref) {
+			return nil
+		}
+
+		seen := map[string]bool{path: true}
+		current := v
+		for isInternalRef(current.Ref) {
+			target, err := s.queryPointer(strings.TrimPrefix(current.Ref, "#"), false)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid $ref %s at %s: %w", v.Ref, pointerOrRoot(path), err))
+				return nil
+			}
+
+			targetPath := strings.TrimPrefix(current.Ref, "#")
+			if seen[targetPath] {
+				errs = append(errs, fmt.Errorf("circular $ref %s at %s", v.Ref, pointerOrRoot(path)))
+				return nil
+			}
+			seen[targetPath] = true
+			current = target
+		}
+
+		return nil
+	})
+
+	return errors.Join(errs...)
+}
+
+// RebaseInternalRefs prefixes all internal references, except the ones pointing to
+// definitions, with the given JSON pointer. This is needed if the schema is nested
+// into another schema, e.g. the schema of a dependency into the schema of its parent.
+func (s *Schema) RebaseInternalRefs(prefix string) {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if isInternalRef(v.Ref) && !isDefinitionRef(v.Ref) {
+			v.Ref = "#" + prefix + strings.TrimPrefix(v.Ref, "#")
+		}
+		return nil
+	})
+}
+
+// RefContext is the location the relative file references of a schema are resolved from.
+// Every chart has its own, so the references of a dependency nested into the schema of its
+// parent (e.g. a chart in the charts/ folder) must be rebased to the context of the parent.
+// The context of a dependency is rebased again with the dependency, when its parent is nested.
+type RefContext struct {
+	// Dir is the directory of the values file the references were written in
+	Dir string
+}
+
+// RefContext returns the context the relative file references of the schema are resolved from,
+// the directory of the values file or of the chart, if there is none. For a chart extracted from
+// an archive, it is located relative to the ArchiveDir, because the extracted files are deleted.
+func (r Result) RefContext() RefContext {
+	dir := r.ChartDir()
+	if r.ValuesPath != "" {
+		dir = filepath.Dir(r.ValuesPath)
+	}
+	if r.ArchiveDir == "" {
+		return RefContext{Dir: dir}
+	}
+	rel, err := filepath.Rel(r.ChartDir(), dir)
+	if err != nil {
+		return RefContext{Dir: r.ArchiveDir}
+	}
+	return RefContext{Dir: filepath.Join(r.ArchiveDir, rel)}
+}
+
+// ChartRefContext returns the context of the files of the chart itself (e.g. its partial
+// schema), which is located relative to the ArchiveDir like RefContext
+func (r Result) ChartRefContext() RefContext {
+	if r.ArchiveDir != "" {
+		return RefContext{Dir: r.ArchiveDir}
+	}
+	return RefContext{Dir: r.ChartDir()}
+}
+
+// ChartDir returns the directory of the chart
+func (r Result) ChartDir() string {
+	return filepath.Dir(r.ChartPath)
+}
+
+// RebaseFileRefs rewrites the relative file references of the schema, which are kept
+// (e.g. with --ref-mode keep-all), so they point to the same files from the context to
+// instead of the context from. Other references are not changed. References which can't be
+// rebased are logged to log and kept.
+func (s *Schema) RebaseFileRefs(from, to RefContext, log logging.Logger) {
+	if filepath.Clean(from.Dir) == filepath.Clean(to.Dir) {
+		return
+	}
+
+	_ = s.Walk(func(_ string, v *Schema) error {
+		target, fragment, hasFragment := strings.Cut(v.Ref, "#")
+		if target == "" || strings.Contains(target, "://") || util.IsFileURL(target) || util.IsAbsolutePath(target) {
+			return nil
+		}
+
+		path := filepath.Join(from.Dir, filepath.FromSlash(strings.ReplaceAll(target, `\`, "/")))
+		rebased, err := filepath.Rel(to.Dir, path)
+		if err != nil {
+			log.Debugf("Could not rebase $ref %s from %s to %s: %v", v.Ref, from.Dir, to.Dir, err)
+			return nil
+		}
+
+		v.Ref = filepath.ToSlash(rebased)
+		if hasFragment {
+			v.Ref += "#" + fragment
+		}
+		return nil
+	})
+}
+
+// PropertyPointer returns the JSON pointer of the property with the given name
+func PropertyPointer(name string) string {
+	return "/properties/" + escapePointerSegment(name)
+}
+
+func pointerOrRoot(path string) string {
+	if path == "" {
+		return "#"
+	}
+	return "#" + path
+}
+
+// resolveJSONPointer returns the json value at the given pointer of the json document
+func resolveJSONPointer(document []byte, pointer string) ([]byte, error) {
+	if pointer == "" || pointer == "/" {
+		return document, nil
+	}
+
+	var current interface{}
+	if err := json.Unmarshal(document, &current); err != nil {
+		return nil, err
+	}
+	current, err := lookupJSONPointer(current, pointer)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(current)
+}
+
+// lookupJSONPointer returns the value at the given pointer of the parsed json document
+func lookupJSONPointer(document interface{}, pointer string) (interface{}, error) {
+	current := document
+	if pointer == "" || pointer == "/" {
+		return current, nil
+	}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		segment = unescapePointerSegment(segment)
+
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, fmt.Errorf("could not find %s in %s", segment, pointer)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, fmt.Errorf("invalid index %s in %s", segment, pointer)
+			}
+			current = value[index]
+		default:
+			return nil, fmt.Errorf("could not find %s in %s", segment, pointer)
+		}
+	}
+	return current, nil
+}
+
+func unescapePointerSegment(segment string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,300 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateInternalRefs(t *testing.T) {
+	tests := []struct {
+		name        string
+		schema      *Schema
+		expectedErr string
+	}{
+		{
+			name: "valid property ref",
+			schema: &Schema{Properties: map[string]*Schema{
+				"image":        {Type: StringOrArrayOfString{"object"}},
+				"sidecarImage": {Ref: "#/properties/image"},
+			}},
+		},
+		{
+			name: "valid definition ref",
+			schema: &Schema{
+				Properties: map[string]*Schema{"port": {Ref: "#/$defs/port"}},
+				Defs:       map[string]*Schema{"port": {Type: StringOrArrayOfString{"integer"}}},
+			},
+		},
+		{
+			name: "recursive ref to parent",
+			schema: &Schema{Properties: map[string]*Schema{
+				"tree": {Properties: map[string]*Schema{
+					"children": {Items: &Schema{Ref: "#/properties/tree"}},
+				}},
+			}},
+		},
+		{
+			name: "missing target",
+			schema: &Schema{Properties: map[string]*Schema{
+				"sidecarImage": {Ref: "#/properties/imgae"},
+			}},
+			expectedErr: "invalid $ref #/properties/imgae at #/properties/sidecarImage: could not find /properties/imgae",
+		},
+		{
+			name: "self reference",
+			schema: &Schema{Properties: map[string]*Schema{
+				"a": {Ref: "#/properties/a"},
+			}},
+			expectedErr: "circular $ref #/properties/a at #/properties/a",
+		},
+		{
+			name: "reference cycle",
+			schema: &Schema{Properties: map[string]*Schema{
+				"a": {Ref: "#/properties/b"},
+				"b": {Ref: "#/properties/a"},
+			}},
+			expectedErr: "circular $ref #/properties/b at #/properties/a\ncircular $ref #/properties/a at #/properties/b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.ValidateInternalRefs()
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestRebaseInternalRefs(t *testing.T) {
+	schema := &Schema{
+		Properties: map[string]*Schema{
+			"image":   {Type: StringOrArrayOfString{"object"}},
+			"sidecar": {Ref: "#/properties/image"},
+			"port":    {Ref: "#/$defs/port"},
+			"old":     {Ref: "#/definitions/old"},
+			"remote":  {Ref: "https://example.org/schema.json#/properties/foo"},
+		},
+	}
+
+	schema.RebaseInternalRefs(PropertyPointer("my/dep"))
+
+	assert.Equal(t, "#/properties/my~1dep/properties/image", schema.Properties["sidecar"].Ref)
+	assert.Equal(t, "#/$defs/port", schema.Properties["port"].Ref)
+	assert.Equal(t, "#/definitions/old", schema.Properties["old"].Ref)
+	assert.Equal(t, "https://example.org/schema.json#/properties/foo", schema.Properties["remote"].Ref)
+}
+
+func TestRebaseFileRefs(t *testing.T) {
+	schema := &Schema{
+		Properties: map[string]*Schema{
+			"port":     {Ref: "schemas/port.json"},
+			"image":    {Ref: "../common/image.json#/$defs/image"},
+			"windows":  {Ref: `schemas\port.json`},
+			"internal": {Ref: "#/properties/port"},
+			"remote":   {Ref: "https://example.org/schema.json"},
+			"absolute": {Ref: "/schemas/port.json"},
+		},
+	}
+
+	from := Result{ChartPath: filepath.Join("parent", "charts", "dep", "Chart.yaml"), ValuesPath: filepath.Join("parent", "charts", "dep", "values.yaml")}
+	to := Result{ChartPath: filepath.Join("parent", "Chart.yaml")}
+	assert.Equal(t, RefContext{Dir: filepath.Join("parent", "charts", "dep")}, from.RefContext())
+	assert.Equal(t, RefContext{Dir: "parent"}, to.RefContext())
+
+	schema.RebaseFileRefs(from.RefContext(), to.RefContext(), logger)
+
+	assert.Equal(t, "charts/dep/schemas/port.json", schema.Properties["port"].Ref)
+	assert.Equal(t, "charts/common/image.json#/$defs/image", schema.Properties["image"].Ref)
+	assert.Equal(t, "charts/dep/schemas/port.json", schema.Properties["windows"].Ref)
+	assert.Equal(t, "#/properties/port", schema.Properties["internal"].Ref)
+	assert.Equal(t, "https://example.org/schema.json", schema.Properties["remote"].Ref)
+	assert.Equal(t, "/schemas/port.json", schema.Properties["absolute"].Ref)
+}
+
+func TestRebaseFileRefsFromArchive(t *testing.T) {
+	schema := &Schema{
+		Properties: map[string]*Schema{
+			"port": {Ref: "schemas/port.json"},
+		},
+	}
+
+	// the chart of parent/charts/dep-1.0.0.tgz is extracted into a temp directory, which is
+	// deleted before the schema is written
+	from := Result{
+		ChartPath:  filepath.Join("parent", "charts", "tmp-123", "dep", "Chart.yaml"),
+		ValuesPath: filepath.Join("parent", "charts", "tmp-123", "dep", "values.yaml"),
+		ArchiveDir: filepath.Join("parent", "charts", "dep"),
+	}
+	to := Result{ChartPath: filepath.Join("parent", "Chart.yaml")}
+	assert.Equal(t, RefContext{Dir: filepath.Join("parent", "charts", "dep")}, from.RefContext())
+	assert.Equal(t, RefContext{Dir: filepath.Join("parent", "charts", "dep")}, from.ChartRefContext())
+
+	schema.RebaseFileRefs(from.RefContext(), to.RefContext(), logger)
+
+	assert.Equal(t, "charts/dep/schemas/port.json", schema.Properties["port"].Ref)
+}
+
+func TestResolveJSONPointer(t *testing.T) {
+	document := []byte(`{"foo": {"type": "string"}, "list": [{"type": "integer"}], "a/b": {"type": "boolean"}}`)
+
+	tests := []struct {
+		pointer     string
+		expected    string
+		expectedErr bool
+	}{
+		{pointer: "/foo", expected: `{"type":"string"}`},
+		{pointer: "/list/0", expected: `{"type":"integer"}`},
+		{pointer: "/a~1b", expected: `{"type":"boolean"}`},
+		{pointer: "/bar", expectedErr: true},
+		{pointer: "/list/1", expectedErr: true},
+		{pointer: "/foo/type/0", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			result, err := resolveJSONPointer(document, tt.pointer)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestExternalPropertyRef(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "external.json"), []byte(`{
+  "definitions": {"name": {"type": "string", "minLength": 1}},
+  "properties": {
+    "image": {"type": "object", "properties": {"repository": {"$ref": "#/definitions/name"}}},
+    "sidecar": {"type": "object", "properties": {"image": {"$ref": "#/properties/image"}}}
+  }
+}`), 0o644))
+	valuesPath := filepath.Join(dir, "values.yaml")
+	values := `# @schema
+# $ref: external.json#/properties/sidecar
+# @schema
+sidecar:
+  image:
+    repository: busybox
+`
+	assert.NoError(t, os.WriteFile(valuesPath, []byte(values), 0o644))
+
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	s := YamlToSchema(valuesPath, &node, false, false, false, true, &SkipAutoGenerationConfig{}, nil, nil)
+
+	assert.Equal(t, "#/definitions/properties-image", s.Properties["sidecar"].Properties["image"].Ref)
+	assert.Equal(t, "#/definitions/name", s.Definitions["properties-image"].Properties["repository"].Ref)
+	assert.Contains(t, s.Definitions, "name")
+	assert.NoError(t, s.ValidateInternalRefs())
+	assert.NoError(t, s.ValidateValues([]byte(values), filepath.Join(dir, "values.schema.json")))
+	assert.Error(t, s.ValidateValues([]byte("sidecar:\n  image:\n    repository: \"\"\n"), filepath.Join(dir, "values.schema.json")))
+}
+
+func TestValidateGeneratedInternalRefs(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      string
+		expectedErr bool
+	}{
+		{
+			name: "valid",
+			values: `image:
+  repository: nginx
+# @schema
+# $ref: "#/properties/image"
+# @schema
+sidecarImage:
+  repository: busybox
+`,
+		},
+		{
+			name: "typo",
+			values: `image:
+  repository: nginx
+# @schema
+# $ref: "#/properties/imgae"
+# @schema
+sidecarImage:
+  repository: busybox
+`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			chartPath := filepath.Join(tmpDir, "Chart.yaml")
+			assert.NoError(t, os.WriteFile(chartPath, []byte("apiVersion: v2\nname: test\nversion: 1.0.0\n"), 0o644))
+			assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte(tt.values), 0o644))
+
+			result := generateResult(context.Background(), chartPath, DefaultOutputFile, testGeneration(t, GenerationPolicy{}))
+			assert.Empty(t, result.Errors)
+
+			err := result.Schema.ValidateInternalRefs()
+			assert.Equal(t, tt.expectedErr, err != nil, err)
+		})
+	}
+}
+
+func TestKeywordPassthrough(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "external.json"), []byte(`{
+  "properties": {
+    "certificate": {
+      "$anchor": "certificate",
+      "type": "string",
+      "contentEncoding": "base64",
+      "contentMediaType": "application/x-pem-file",
+      "propertyNames": {"maxLength": 10}
+    }
+  }
+}`), 0o644))
+	valuesPath := filepath.Join(dir, "values.yaml")
+	values := `# @schema
+# $ref: external.json#certificate
+# @schema
+certificate: ""
+# @schema
+# type: object
+# $anchor: tree
+# $dynamicRef: "#node"
+# additionalProperties: true
+# @schema
+tree: {}
+`
+	assert.NoError(t, os.WriteFile(valuesPath, []byte(values), 0o644))
+
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	s := YamlToSchema(valuesPath, &node, false, false, false, true, &SkipAutoGenerationConfig{}, nil, nil)
+
+	certificate := s.Properties["certificate"]
+	assert.Equal(t, "certificate", certificate.Anchor)
+	assert.Equal(t, "base64", certificate.ContentEncoding)
+	assert.Equal(t, "application/x-pem-file", certificate.ContentMediaType)
+	assert.Equal(t, "tree", s.Properties["tree"].Anchor)
+	assert.Equal(t, "#node", s.Properties["tree"].DynamicRef)
+
+	jsonStr, err := s.ToJson()
+	assert.NoError(t, err)
+	for _, keyword := range []string{`"$anchor"`, `"$dynamicRef"`, `"contentEncoding"`, `"contentMediaType"`, `"propertyNames"`} {
+		assert.Contains(t, string(jsonStr), keyword)
+	}
+
+	assert.Error(t, (&Schema{Anchor: "1st"}).Validate())
+	assert.NoError(t, (&Schema{Anchor: "first.1"}).Validate())
+}
@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/registry"
+)
+
+// schemaRegistry resolves the registry:// references, it is nil if no registry is configured
+var schemaRegistry *registry.Client
+
+// UseRegistry sets the schema registry which resolves registry://<chart>@<version> references.
+// It is not safe to change the registry while schemas are generated.
+func UseRegistry(client *registry.Client) {
+	schemaRegistry = client
+}
+
+// pullRegistryRef returns the schema referenced by the registry reference (without json pointer)
+func pullRegistryRef(ctx context.Context, ref string, log logging.Logger) ([]byte, error) {
+	chart, version, err := registry.ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if schemaRegistry == nil {
+		return nil, errors.New("no schema registry configured (see --schema-registry)")
+	}
+	content, err := schemaRegistry.PullContext(ctx, chart, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockRef(ref, content, log); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
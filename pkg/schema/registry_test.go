@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/registry"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRegistryRefs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/common/1.2.3.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{
+  "$defs": {"port": {"type": "integer", "minimum": 1}},
+  "properties": {"host": {"type": "string", "format": "hostname"}}
+}`))
+	}))
+	defer server.Close()
+
+	client, err := registry.NewClient(server.URL, "")
+	assert.NoError(t, err)
+	UseRegistry(client)
+	defer UseRegistry(nil)
+
+	yamlContent := `# @schema
+# $ref: registry://common@1.2.3#/$defs/port
+# @schema
+port: 80
+# @schema
+# $ref: registry://common@1.2.3#/properties/host
+# @schema
+host: example.com
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+	skipConfig, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+
+	s := YamlToSchema("values.yaml", &node, false, false, false, true, skipConfig, nil, nil)
+
+	assert.Equal(t, "#/$defs/port", s.Properties["port"].Ref)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, s.Defs["port"].Type)
+	assert.Equal(t, "hostname", s.Properties["host"].Format)
+	assert.NoError(t, s.ValidateInternalRefs())
+}
+
+func TestPullRegistryRefWithoutRegistry(t *testing.T) {
+	_, err := pullRegistryRef(context.Background(), "registry://common@1.2.3", logger)
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// RenamedToAnnotation contains the new name of a key, which is only accepted for compatibility (see renamedFrom)
+const RenamedToAnnotation = CustomAnnotationPrefix + "renamed-to"
+
+// DeprecationWarningAnnotation contains the warning about a renamed key, which is added to the
+// then schema of the if/then accepting the old name
+const DeprecationWarningAnnotation = CustomAnnotationPrefix + "deprecation-warning"
+
+// Rename renames a key of the values, e.g. image.tag=image.version
+type Rename struct {
+	// From is the dotted path of the key
+	From string
+	// To is the dotted path of the new key, only the last key may differ from From
+	To string
+}
+
+func (r Rename) String() string {
+	return fmt.Sprintf("%s=%s", r.From, r.To)
+}
+
+// ParseRename parses a rename of the form <old path>=<new path>. Keys can only be renamed
+// within their map, moving them to another map is not supported.
+func ParseRename(rename string) (Rename, error) {
+	from, to, ok := strings.Cut(rename, "=")
+	r := Rename{From: strings.TrimSpace(from), To: strings.TrimSpace(to)}
+	if !ok || r.From == "" || r.To == "" {
+		return Rename{}, fmt.Errorf("invalid rename %q, expected <old path>=<new path>", rename)
+	}
+	if strings.Contains(r.From, "[]") || strings.Contains(r.To, "[]") {
+		return Rename{}, fmt.Errorf("cannot rename %s, keys within lists are not supported", r.From)
+	}
+
+	fromPath, toPath := r.Path(), strings.Split(r.To, ".")
+	if slices.Contains(fromPath, "") || slices.Contains(toPath, "") {
+		return Rename{}, fmt.Errorf("invalid rename %q, the paths contain an empty key", rename)
+	}
+	if !slices.Equal(fromPath[:len(fromPath)-1], toPath[:len(toPath)-1]) {
+		return Rename{}, fmt.Errorf("cannot rename %s to %s, keys can only be renamed within their map", r.From, r.To)
+	}
+	if r.OldName() == r.NewName() {
+		return Rename{}, fmt.Errorf("invalid rename %q, the names are equal", rename)
+	}
+	return r, nil
+}
+
+// Path returns the keys of the path of the renamed key
+func (r Rename) Path() []string {
+	return strings.Split(r.From, ".")
+}
+
+// OldName returns the name of the key before the rename
+func (r Rename) OldName() string {
+	return r.From[strings.LastIndex(r.From, ".")+1:]
+}
+
+// NewName returns the name of the key after the rename
+func (r Rename) NewName() string {
+	return r.To[strings.LastIndex(r.To, ".")+1:]
+}
+
+// RenamedComment adds the old name of a renamed key to the renamedFrom annotation in the comment
+// of the key (without the leading "# " of the comment lines). The @schema block is added if the
+// comment doesn't contain one.
+func RenamedComment(comment, oldName, newName string) (string, error) {
+	marker := strings.TrimPrefix(SchemaPrefix, "# ")
+	lines := strings.Split(comment, "\n")
+	start := slices.IndexFunc(lines, func(line string) bool { return strings.TrimSpace(line) == marker })
+	if start < 0 {
+		annotation, err := renamedFromLine([]string{oldName})
+		if err != nil {
+			return "", err
+		}
+		return AnnotatedComment(comment, annotation)
+	}
+	end := start + 1 + slices.IndexFunc(lines[start+1:], func(line string) bool { return strings.TrimSpace(line) == marker })
+	if end == start {
+		return "", fmt.Errorf("unclosed %s annotation", marker)
+	}
+
+	var annotation struct {
+		RenamedFrom []string `yaml:"renamedFrom"`
+	}
+	if err := yaml.Unmarshal([]byte(strings.Join(lines[start+1:end], "\n")), &annotation); err != nil {
+		return "", fmt.Errorf("invalid %s annotation: %w", marker, err)
+	}
+	names := slices.DeleteFunc(annotation.RenamedFrom, func(name string) bool { return name == newName })
+	if !slices.Contains(names, oldName) {
+		names = append(names, oldName)
+	}
+	line, err := renamedFromLine(names)
+	if err != nil {
+		return "", err
+	}
+
+	// the existing renamedFrom (which may be a block sequence) is replaced
+	block := []string{}
+	for i := start + 1; i < end; i++ {
+		if !strings.HasPrefix(lines[i], "renamedFrom:") {
+			block = append(block, lines[i])
+			continue
+		}
+		for i+1 < end && (strings.HasPrefix(lines[i+1], " ") || strings.HasPrefix(lines[i+1], "-")) {
+			i++
+		}
+	}
+	block = append(block, line)
+
+	result := slices.Concat(lines[:start+1], block, lines[end:])
+	return strings.Join(result, "\n"), nil
+}
+
+// renamedFromLine returns the renamedFrom annotation with the names as flow sequence
+func renamedFromLine(names []string) (string, error) {
+	sequence := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, name := range names {
+		sequence.Content = append(sequence.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name})
+	}
+	out, err := yaml.Marshal(sequence)
+	if err != nil {
+		return "", err
+	}
+	return "renamedFrom: " + strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// addRenamedKeys adds the old names (renamedFrom) of the renamed properties as deprecated properties
+// with the schema of the new ones, so values of previous versions of the chart are still accepted.
+// An if/then per old name adds a deprecation warning if it's used.
+func (s *Schema) addRenamedKeys(log logging.Logger) {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		for _, name := range sortedSchemaKeys(v.Properties) {
+			property := v.Properties[name]
+			if property == nil {
+				continue
+			}
+			for _, oldName := range property.RenamedFrom {
+				if _, ok := v.Properties[oldName]; ok {
+					log.Warnf("Not adding the old name %s of key %s, the key exists", oldName, name)
+					continue
+				}
+
+				old := property.Clone()
+				old.RenamedFrom = nil
+				old.Required.Bool = false
+				old.Default = nil
+				if old.Title == name {
+					old.Title = oldName
+				}
+				old.Deprecated = true
+				old.Description = fmt.Sprintf("Deprecated, use %s instead.", name)
+				old.setCustomAnnotationIfMissing(RenamedToAnnotation, name)
+				v.Properties[oldName] = old
+
+				v.AllOf = append(v.AllOf, &Schema{
+					If: &Schema{Required: BoolOrArrayOfString{Strings: []string{oldName}}},
+					Then: &Schema{CustomAnnotations: map[string]interface{}{
+						DeprecationWarningAnnotation: fmt.Sprintf("%s is deprecated, use %s instead", oldName, name),
+					}},
+				})
+			}
+			property.RenamedFrom = nil
+		}
+		return nil
+	})
+}
@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRename(t *testing.T) {
+	rename, err := ParseRename("image.tag=image.version")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"image", "tag"}, rename.Path())
+	assert.Equal(t, "tag", rename.OldName())
+	assert.Equal(t, "version", rename.NewName())
+
+	rename, err = ParseRename(" replicas = replicaCount ")
+	assert.NoError(t, err)
+	assert.Equal(t, Rename{From: "replicas", To: "replicaCount"}, rename)
+
+	for _, invalid := range []string{"replicas", "=replicaCount", "replicas=", "image.tag=version", "image.tag=other.tag", "a..b=a..c", "hosts[].name=hosts[].host", "a=a"} {
+		_, err := ParseRename(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRenamedComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		comment  string
+		expected string
+	}{
+		{
+			name:     "no annotation",
+			comment:  "Number of replicas",
+			expected: "@schema\nrenamedFrom: [replicas]\n@schema\nNumber of replicas",
+		},
+		{
+			name:     "no comment",
+			comment:  "",
+			expected: "@schema\nrenamedFrom: [replicas]\n@schema",
+		},
+		{
+			name:     "existing annotation",
+			comment:  "@schema\nminimum: 1\n@schema\nNumber of replicas",
+			expected: "@schema\nminimum: 1\nrenamedFrom: [replicas]\n@schema\nNumber of replicas",
+		},
+		{
+			name:     "existing old names",
+			comment:  "@schema\nrenamedFrom:\n  - replicaCount\n  - instances\nminimum: 1\n@schema",
+			expected: "@schema\nminimum: 1\nrenamedFrom: [instances, replicas]\n@schema",
+		},
+		{
+			name:     "already renamed",
+			comment:  "@schema\nrenamedFrom: [replicas]\n@schema",
+			expected: "@schema\nrenamedFrom: [replicas]\n@schema",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			comment, err := RenamedComment(test.comment, "replicas", "replicaCount")
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, comment)
+		})
+	}
+
+	_, err := RenamedComment("@schema\nminimum: 1", "replicas", "replicaCount")
+	assert.Error(t, err)
+}
+
+func TestRenamedKeys(t *testing.T) {
+	values := `# @schema
+# renamedFrom: [replicas]
+# @schema
+# Number of replicas
+replicaCount: 1
+image:
+  # @schema
+  # pattern: ^v
+  # renamedFrom: [version]
+  # @schema
+  tag: v1
+`
+	s := selfCheckSchema(t, values)
+
+	// renamedFrom alone doesn't replace the generated schema
+	replicaCount := s.Properties["replicaCount"]
+	assert.Equal(t, StringOrArrayOfString{"integer"}, replicaCount.Type)
+	assert.Contains(t, s.Required.Strings, "replicaCount")
+	assert.Nil(t, replicaCount.RenamedFrom)
+
+	replicas := s.Properties["replicas"]
+	assert.True(t, replicas.Deprecated)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, replicas.Type)
+	assert.Equal(t, "replicas", replicas.Title)
+	assert.Nil(t, replicas.Default)
+	assert.Equal(t, "replicaCount", replicas.CustomAnnotations[RenamedToAnnotation])
+	assert.NotContains(t, s.Required.Strings, "replicas")
+	assert.Equal(t, []string{"replicas"}, s.AllOf[0].If.Required.Strings)
+	assert.Equal(t, "replicas is deprecated, use replicaCount instead", s.AllOf[0].Then.CustomAnnotations[DeprecationWarningAnnotation])
+
+	version := s.Properties["image"].Properties["version"]
+	assert.True(t, version.Deprecated)
+	assert.Equal(t, "^v", version.Pattern)
+
+	assert.NoError(t, s.ValidateValues([]byte(values), "values.schema.json"))
+	assert.NoError(t, s.ValidateValues([]byte("replicaCount: 1\nreplicas: 2\nimage:\n  tag: v1\n  version: v2\n"), "values.schema.json"))
+	assert.Error(t, s.ValidateValues([]byte("replicaCount: 1\nreplicas: two\nimage:\n  tag: v1\n"), "values.schema.json"))
+	assert.Error(t, s.ValidateValues([]byte("replicaCount: 1\nimage:\n  tag: v1\n  version: x1\n"), "values.schema.json"))
+}
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,11 +13,15 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/dadav/helm-schema/pkg/gitref"
+	"github.com/dadav/helm-schema/pkg/logging"
+	"github.com/dadav/helm-schema/pkg/registry"
+	"github.com/dadav/helm-schema/pkg/urlref"
 	"github.com/dadav/helm-schema/pkg/util"
 	"github.com/norwoodj/helm-docs/pkg/helm"
 	"github.com/santhosh-tekuri/jsonschema/v6"
-	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
@@ -30,8 +35,21 @@ const (
 	// Custom annotations are extensions to the JSON Schema specification
 	// See: https://json-schema.org/blog/posts/custom-annotations-will-continue
 	CustomAnnotationPrefix = "x-"
+
+	// ComputedAnnotation is added to values marked with `computed: true`
+	ComputedAnnotation = CustomAnnotationPrefix + "computed"
+
+	// SectionAnnotation contains the helm-docs section (`@section`) of a value
+	SectionAnnotation = CustomAnnotationPrefix + "section"
+	// NotationTypeAnnotation contains the helm-docs notation type (`@notationType` or a `tpl/` type) of a value
+	NotationTypeAnnotation = CustomAnnotationPrefix + "notation-type"
+	// MatchedPatternAnnotation maps the keys which aren't added to the properties, because
+	// they match a pattern of patternProperties, to the pattern (see AddPatternMatchTraces)
+	MatchedPatternAnnotation = CustomAnnotationPrefix + "matched-pattern"
 )
 
+const computedAnnotationText = "This value is computed by the chart and should not be set"
+
 // CollectedDefs tracks definitions collected from external schemas
 // and which keyword they should use (definitions vs $defs)
 type CollectedDefs struct {
@@ -47,6 +65,7 @@ const (
 	intTag       = "!!int"
 	floatTag     = "!!float"
 	timestampTag = "!!timestamp"
+	binaryTag    = "!!binary"
 	arrayTag     = "!!seq"
 	mapTag       = "!!map"
 )
@@ -203,44 +222,33 @@ func (s *StringOrArrayOfString) Matches(typeString string) bool {
 	return false
 }
 
-// MarshalJSON custom marshal method for Schema. It inlines the CustomAnnotations fields
-func (s *Schema) MarshalJSON() ([]byte, error) {
-	// Create a map to hold all the fields
-	type Alias Schema
-	data := make(map[string]interface{})
-
-	// Marshal the Schema struct (excluding CustomAnnotations)
-	alias := (*Alias)(s)
-	aliasJSON, err := json.Marshal(alias)
-	if err != nil {
-		return nil, err
-	}
-
-	// Unmarshal the JSON back into the map
-	if err := json.Unmarshal(aliasJSON, &data); err != nil {
-		return nil, err
+// allowsConstraintsOf checks if keywords, which only apply to the given types, can be used.
+// This is the case if no type is set or if any of the given types is part of the type.
+// Other members of a type union (e.g. the null of [string, null]) are ignored,
+// because the keywords simply don't apply to them.
+func (s *StringOrArrayOfString) allowsConstraintsOf(typeStrings ...string) bool {
+	if s.IsEmpty() {
+		return true
 	}
-
-	// inline the CustomAnnotations fields
-	for key, value := range s.CustomAnnotations {
-		data[key] = value
+	for _, t := range typeStrings {
+		if s.Matches(t) {
+			return true
+		}
 	}
+	return false
+}
 
-	delete(data, "CustomAnnotations")
-
-	// Remove "required" if the schema type is not object
-	if s.Type.canDropRequired() {
-		delete(data, "required")
+// onlyAllows checks if the type doesn't allow other types than the given ones (or null)
+func (s *StringOrArrayOfString) onlyAllows(typeStrings ...string) bool {
+	if s.IsEmpty() {
+		return true
 	}
-
-	// Explicitly include const field when it was set to null
-	// This handles the case where const: null in YAML should appear as "const": null in JSON
-	if s.constWasSet && s.Const == nil {
-		data["const"] = nil
+	for _, t := range *s {
+		if t != "null" && !slices.Contains(typeStrings, t) {
+			return false
+		}
 	}
-
-	// Marshal the final map into JSON
-	return json.Marshal(data)
+	return true
 }
 
 // Schema struct contains yaml tags for reading, json for writing (creating the jsonschema)
@@ -249,6 +257,7 @@ type Schema struct {
 	Default              interface{}            `yaml:"default,omitempty"              json:"default,omitempty"`
 	Then                 *Schema                `yaml:"then,omitempty"                 json:"then,omitempty"`
 	PatternProperties    map[string]*Schema     `yaml:"patternProperties,omitempty"    json:"patternProperties,omitempty"`
+	PropertyNames        *Schema                `yaml:"propertyNames,omitempty"        json:"propertyNames,omitempty"`
 	Properties           map[string]*Schema     `yaml:"properties,omitempty"           json:"properties,omitempty"`
 	Defs                 map[string]*Schema     `yaml:"$defs,omitempty"                json:"$defs,omitempty"`
 	Definitions          map[string]*Schema     `yaml:"definitions,omitempty"          json:"definitions,omitempty"`
@@ -265,11 +274,17 @@ type Schema struct {
 	Ref                  string                 `yaml:"$ref,omitempty"                 json:"$ref,omitempty"`
 	Schema               string                 `yaml:"$schema,omitempty"              json:"$schema,omitempty"`
 	Id                   string                 `yaml:"$id,omitempty"                  json:"$id,omitempty"`
+	Anchor               string                 `yaml:"$anchor,omitempty"              json:"$anchor,omitempty"`
+	DynamicRef           string                 `yaml:"$dynamicRef,omitempty"          json:"$dynamicRef,omitempty"`
 	Format               string                 `yaml:"format,omitempty"               json:"format,omitempty"`
+	ContentEncoding      string                 `yaml:"contentEncoding,omitempty"      json:"contentEncoding,omitempty"`
+	ContentMediaType     string                 `yaml:"contentMediaType,omitempty"     json:"contentMediaType,omitempty"`
+	ContentSchema        *Schema                `yaml:"contentSchema,omitempty"        json:"contentSchema,omitempty"`
 	Description          string                 `yaml:"description,omitempty"          json:"description,omitempty"`
 	Title                string                 `yaml:"title,omitempty"                json:"title,omitempty"`
 	Type                 StringOrArrayOfString  `yaml:"type,omitempty"                 json:"type,omitempty"`
 	AnyOf                []*Schema              `yaml:"anyOf,omitempty"                json:"anyOf,omitempty"`
+	Variants             []*Schema              `yaml:"variants,omitempty"             json:"-"`
 	AllOf                []*Schema              `yaml:"allOf,omitempty"                json:"allOf,omitempty"`
 	OneOf                []*Schema              `yaml:"oneOf,omitempty"                json:"oneOf,omitempty"`
 	Not                  *Schema                `yaml:"not,omitempty"                json:"not,omitempty"`
@@ -279,6 +294,12 @@ type Schema struct {
 	Deprecated           bool                   `yaml:"deprecated,omitempty"           json:"deprecated,omitempty"`
 	ReadOnly             bool                   `yaml:"readOnly,omitempty"           json:"readOnly,omitempty"`
 	WriteOnly            bool                   `yaml:"writeOnly,omitempty"           json:"writeOnly,omitempty"`
+	Computed             bool                   `yaml:"computed,omitempty"             json:"-"`
+	Pinned               bool                   `yaml:"pinned,omitempty"               json:"-"`
+	Nullable             bool                   `yaml:"nullable,omitempty"             json:"-"`
+	Preset               string                 `yaml:"preset,omitempty"               json:"-"`
+	MergeProperties      bool                   `yaml:"mergeProperties,omitempty"      json:"-"`
+	RenamedFrom          []string               `yaml:"renamedFrom,omitempty"          json:"-"`
 	Required             BoolOrArrayOfString    `yaml:"required,omitempty"             json:"required,omitempty"`
 	CustomAnnotations    map[string]interface{} `yaml:"-"                              json:",omitempty"`
 	MinLength            *int                   `yaml:"minLength,omitempty"              json:"minLength,omitempty"`
@@ -286,7 +307,21 @@ type Schema struct {
 	MinItems             *int                   `yaml:"minItems,omitempty"              json:"minItems,omitempty"`
 	MaxItems             *int                   `yaml:"maxItems,omitempty"              json:"maxItems,omitempty"`
 	UniqueItems          bool                   `yaml:"uniqueItems,omitempty"          json:"uniqueItems,omitempty"`
+	UniqueKeys           []string               `yaml:"uniqueKeys,omitempty"           json:"-"`
+	Comment              string                 `yaml:"$comment,omitempty"             json:"$comment,omitempty"`
+	Links                []Link                 `yaml:"links,omitempty"                json:"links,omitempty"`
+	Forbidden            []string               `yaml:"forbidden,omitempty"            json:"-"`
+	MinProperties        *int                   `yaml:"minProperties,omitempty"        json:"minProperties,omitempty"`
+	MaxProperties        *int                   `yaml:"maxProperties,omitempty"        json:"maxProperties,omitempty"`
+	Extras               RawKeywords            `yaml:"-"                              json:"-"`
 	constWasSet          bool                   `yaml:"-"                              json:"-"`
+	omitRequired         bool                   `yaml:"-"                              json:"-"`
+	nonEmptyMapValue     bool                   `yaml:"-"                              json:"-"`
+	matchedPatterns      map[string]string      `yaml:"-"                              json:"-"`
+	blockScalarValue     string                 `yaml:"-"                              json:"-"`
+	commentExamples      []string               `yaml:"-"                              json:"-"`
+	presetProperties     map[string]*yaml.Node  `yaml:"-"                              json:"-"`
+	presetRequired       bool                   `yaml:"-"                              json:"-"`
 }
 
 func NewSchema(schemaType string) *Schema {
@@ -346,6 +381,20 @@ func (s *Schema) UnmarshalYAML(node *yaml.Node) error {
 			alias.constWasSet = true
 		}
 
+		// yaml doesn't call unmarshalers for null values, so the null type must be set here
+		if key == "type" && valueNode.ShortTag() == nullTag {
+			alias.Type = StringOrArrayOfString{"null"}
+		}
+
+		// additionalProperties is a boolean or a schema, the schema must be read like any other schema
+		if key == "additionalProperties" && valueNode.Kind == yaml.MappingNode {
+			var subSchema Schema
+			if err := valueNode.Decode(&subSchema); err != nil {
+				return err
+			}
+			alias.AdditionalProperties = subSchema
+		}
+
 		if slices.Contains(knownKeys, key) {
 			continue
 		}
@@ -371,65 +420,55 @@ func (s *Schema) Set() {
 	s.HasData = true
 }
 
-// DisableRequiredProperties recursively disables all required property validations throughout the schema.
-// This includes:
-// - Setting the root schema's required field to an empty array
-// - Recursively disabling required properties in all nested schemas (properties, items, etc.)
-// - Handling all conditional schemas (if/then/else)
-// - Processing all composition schemas (anyOf/oneOf/allOf)
-func (s *Schema) DisableRequiredProperties() {
-	s.Required = NewBoolOrArrayOfString([]string{}, false)
-	for _, v := range s.Properties {
-		v.DisableRequiredProperties()
+// setCustomAnnotationIfMissing sets the custom annotation, unless it was already set (e.g. by an annotation)
+func (s *Schema) setCustomAnnotationIfMissing(key string, value interface{}) {
+	if s.CustomAnnotations == nil {
+		s.CustomAnnotations = make(map[string]interface{})
 	}
-	if s.Items != nil {
-		s.Items.DisableRequiredProperties()
+	if _, ok := s.CustomAnnotations[key]; !ok {
+		s.CustomAnnotations[key] = value
 	}
+}
 
-	if s.AnyOf != nil {
-		for _, v := range s.AnyOf {
-			v.DisableRequiredProperties()
-		}
-	}
-	if s.OneOf != nil {
-		for _, v := range s.OneOf {
-			v.DisableRequiredProperties()
+// DisableRequiredProperties disables the required keys of the schema and all its subschemas
+// (see Walk). The subschemas of not are left as they are, without their required keys they
+// would reject everything (e.g. forbidden keys).
+func (s *Schema) DisableRequiredProperties() {
+	negated := make(map[*Schema]bool)
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if negated[v] {
+			return SkipSchema
 		}
-	}
-	if s.AllOf != nil {
-		for _, v := range s.AllOf {
-			v.DisableRequiredProperties()
+		v.Required = NewBoolOrArrayOfString([]string{}, false)
+		if v.Not != nil {
+			negated[v.Not] = true
 		}
-	}
-	if s.If != nil {
-		s.If.DisableRequiredProperties()
-	}
-	if s.Else != nil {
-		s.Else.DisableRequiredProperties()
-	}
-	if s.Then != nil {
-		s.Then.DisableRequiredProperties()
-	}
-	if s.Not != nil {
-		s.Not.DisableRequiredProperties()
-	}
+		return nil
+	})
+}
 
-	// Add handling for AdditionalProperties when it's a Schema
-	if s.AdditionalProperties != nil {
-		if subSchema, ok := s.AdditionalProperties.(Schema); ok {
-			subSchema.DisableRequiredProperties()
-			s.AdditionalProperties = subSchema
+// AddMinProperties sets minProperties to 1 for all required properties of the schema and its
+// subschemas whose value in the values file is a non-empty map, expressing that at least one
+// entry must be configured. Explicitly annotated minProperties are kept.
+func (s *Schema) AddMinProperties() {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		for name, property := range v.Properties {
+			if property.nonEmptyMapValue && property.MinProperties == nil && slices.Contains(v.Required.Strings, name) {
+				minProperties := 1
+				property.MinProperties = &minProperties
+			}
 		}
-	}
+		return nil
+	})
 }
 
 // ToJson converts the data to raw json
 func (s Schema) ToJson() ([]byte, error) {
-	res, err := json.MarshalIndent(&s, "", "  ")
-	if err != nil {
+	var buf bytes.Buffer
+	if err := s.WriteJSON(&buf); err != nil {
 		return nil, err
 	}
-	return res, nil
+	return buf.Bytes(), nil
 }
 
 // Supported format values according to JSON Schema specification
@@ -455,6 +494,16 @@ const (
 	FormatRegex          = "regex"
 )
 
+// Additional formats which are commonly needed in helm charts, but aren't part
+// of the JSON Schema specification
+const (
+	FormatSemver      = "semver"
+	FormatCron        = "cron"
+	FormatK8sName     = "k8s-name"
+	FormatK8sQuantity = "k8s-quantity"
+	FormatDurationGo  = "duration-go"
+)
+
 var supportedFormats = map[string]bool{
 	FormatDateTime: true, FormatTime: true, FormatDate: true,
 	FormatDuration: true, FormatEmail: true, FormatIDNEmail: true,
@@ -463,44 +512,117 @@ var supportedFormats = map[string]bool{
 	FormatURIReference: true, FormatIRI: true, FormatIRIReference: true,
 	FormatURITemplate: true, FormatJSONPointer: true,
 	FormatRelJSONPointer: true, FormatRegex: true,
+	FormatSemver: true, FormatCron: true, FormatK8sName: true,
+	FormatK8sQuantity: true, FormatDurationGo: true,
+}
+
+// customFormatPatterns maps the non-standard formats to a pattern, which can be
+// used instead of the format, because validators ignore unknown formats
+var customFormatPatterns = map[string]string{
+	FormatSemver:      `^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`,
+	FormatCron:        `^(@(annually|yearly|monthly|weekly|daily|hourly|reboot)|@every (\d+(ns|us|µs|ms|s|m|h))+|([0-9A-Za-z*?,/#LW-]+\s+){4,5}[0-9A-Za-z*?,/#LW-]+)$`,
+	FormatK8sName:     `^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`,
+	FormatK8sQuantity: `^[+-]?(\d+(\.\d*)?|\.\d+)(([KMGTPE]i)|[numkMGTPE]|([eE][+-]?\d+))?$`,
+	FormatDurationGo:  `^([-+]?((\d+(\.\d*)?|\.\d+)(ns|us|µs|ms|s|m|h))+|0)$`,
+}
+
+// k8sNameMaxLength is the maximum length of a DNS-1123 subdomain
+const k8sNameMaxLength = 253
+
+// ConvertCustomFormats recursively replaces the non-standard formats (semver, cron, ...)
+// with an equivalent pattern. This is needed, because validators like the one used
+// by helm silently ignore unknown formats.
+func (s *Schema) ConvertCustomFormats() {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if pattern, ok := customFormatPatterns[v.Format]; ok {
+			if v.Format == FormatK8sName && v.MaxLength == nil {
+				maxLength := k8sNameMaxLength
+				v.MaxLength = &maxLength
+			}
+			if v.Pattern == "" {
+				v.Pattern = pattern
+			}
+			v.Format = ""
+		}
+		return nil
+	})
 }
 
 // Validate performs comprehensive validation of the schema
+// with the rules of the default validation profile (helm-pragmatic)
 func (s Schema) Validate() error {
+	return s.ValidateProfile(DefaultValidationProfile())
+}
+
+// ValidateProfile validates the schema with the rules of the given profile
+func (s Schema) ValidateProfile(profile ValidationProfile) error {
 	// Validate schema syntax
 	if err := s.validateSchemaSyntax(); err != nil {
 		return err
 	}
 
 	// Validate type constraints
-	if err := s.validateTypeConstraints(); err != nil {
+	if err := s.validateTypeConstraints(profile); err != nil {
+		return err
+	}
+
+	// Validate computed values
+	if err := s.validateComputed(); err != nil {
+		return err
+	}
+
+	if err := s.validatePinned(); err != nil {
+		return err
+	}
+
+	if err := s.validateRenamedFrom(); err != nil {
+		return err
+	}
+
+	if err := s.validateForbidden(profile); err != nil {
+		return err
+	}
+
+	if err := s.validateLinks(); err != nil {
 		return err
 	}
 
 	// Validate numeric constraints
-	if err := s.validateNumericConstraints(); err != nil {
+	if err := s.validateNumericConstraints(profile); err != nil {
 		return err
 	}
 
 	// Validate string constraints
-	if err := s.validateStringConstraints(); err != nil {
+	if err := s.validateStringConstraints(profile); err != nil {
 		return err
 	}
 
 	// Validate array constraints
-	if err := s.validateArrayConstraints(); err != nil {
+	if err := s.validateArrayConstraints(profile); err != nil {
+		return err
+	}
+
+	// Validate object constraints
+	if err := s.validateObjectConstraints(profile); err != nil {
 		return err
 	}
 
 	// Validate nested schemas
-	if err := s.validateNestedSchemas(); err != nil {
+	if err := s.validateNestedSchemas(profile); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// anchorNameRegex matches the names of anchors ($anchor) of draft 2020-12
+var anchorNameRegex = regexp.MustCompile(`^[A-Za-z_][-A-Za-z0-9._]*$`)
+
 func (s Schema) validateSchemaSyntax() error {
+	if s.Anchor != "" && !anchorNameRegex.MatchString(s.Anchor) {
+		return fmt.Errorf("invalid $anchor %s, it must start with a letter or _ followed by letters, digits, -, _ or .", s.Anchor)
+	}
+
 	jsonStr, err := s.ToJson()
 	if err != nil {
 		return fmt.Errorf("failed to convert schema to JSON: %w", err)
@@ -514,7 +636,11 @@ func (s Schema) validateSchemaSyntax() error {
 	return s.Type.Validate()
 }
 
-func (s Schema) validateTypeConstraints() error {
+func (s Schema) validateTypeConstraints(profile ValidationProfile) error {
+	if !profile.Has(RuleEnumWithType) {
+		return nil
+	}
+
 	if s.Const != nil && !s.Type.IsEmpty() {
 		return errors.New("cannot use both 'const' and 'type' in the same schema")
 	}
@@ -526,13 +652,61 @@ func (s Schema) validateTypeConstraints() error {
 	return nil
 }
 
-func (s Schema) validateNumericConstraints() error {
+func (s Schema) validateComputed() error {
+	if !s.Computed {
+		return nil
+	}
+
+	if s.Required.Bool {
+		return errors.New("computed values cannot be required")
+	}
+
+	if s.WriteOnly {
+		return errors.New("computed values cannot be writeOnly")
+	}
+
+	return nil
+}
+
+func (s Schema) validatePinned() error {
+	if !s.Pinned {
+		return nil
+	}
+
+	if s.WriteOnly {
+		return errors.New("pinned values cannot be writeOnly")
+	}
+
+	return nil
+}
+
+func (s Schema) validateRenamedFrom() error {
+	for _, name := range s.RenamedFrom {
+		if name == "" {
+			return errors.New("renamedFrom cannot contain empty names")
+		}
+	}
+	return nil
+}
+
+// validateConstraintTypes checks if the keywords, which only apply to the given types, match the type
+func (s Schema) validateConstraintTypes(profile ValidationProfile, keywords string, typeStrings ...string) error {
+	if profile.Has(RuleConstraintTypes) && !s.Type.allowsConstraintsOf(typeStrings...) {
+		return fmt.Errorf("%s can only be used with %s types, got %v", keywords, strings.Join(typeStrings, " or "), s.Type)
+	}
+	if profile.Has(RuleUnionConstraints) && !s.Type.onlyAllows(typeStrings...) {
+		return fmt.Errorf("%s can only be used with %s types, but %v allows other types as well", keywords, strings.Join(typeStrings, " or "), s.Type)
+	}
+	return nil
+}
+
+func (s Schema) validateNumericConstraints(profile ValidationProfile) error {
 	if !s.hasNumericConstraints() {
 		return nil
 	}
 
-	if !s.Type.IsEmpty() && !s.Type.Matches("number") && !s.Type.Matches("integer") {
-		return fmt.Errorf("numeric constraints can only be used with number or integer types, got %v", s.Type)
+	if err := s.validateConstraintTypes(profile, "numeric constraints", "number", "integer"); err != nil {
+		return err
 	}
 
 	if s.MultipleOf != nil && *s.MultipleOf <= 0 {
@@ -550,27 +724,57 @@ func (s Schema) validateNumericConstraints() error {
 	return nil
 }
 
-func (s Schema) validateStringConstraints() error {
+func (s Schema) validateStringConstraints(profile ValidationProfile) error {
 	if s.Format != "" {
-		if !s.Type.IsEmpty() && !s.Type.Matches("string") {
-			return fmt.Errorf("format can only be used with string type, got %v", s.Type)
+		if err := s.validateConstraintTypes(profile, "format", "string"); err != nil {
+			return err
 		}
 
-		if !supportedFormats[s.Format] {
+		if profile.Has(RuleKnownFormats) && !supportedFormats[s.Format] {
 			return fmt.Errorf("unsupported format: %s", s.Format)
 		}
 	}
 
 	if s.Pattern != "" {
-		if !s.Type.IsEmpty() && !s.Type.Matches("string") {
-			return fmt.Errorf("pattern can only be used with string type, got %v", s.Type)
+		if err := s.validateConstraintTypes(profile, "pattern", "string"); err != nil {
+			return err
 		}
 	}
 
-	if s.Format != "" && s.Pattern != "" {
+	if s.MinLength != nil || s.MaxLength != nil {
+		if err := s.validateConstraintTypes(profile, "minLength/maxLength", "string"); err != nil {
+			return err
+		}
+	}
+
+	if profile.Has(RuleFormatWithPattern) && s.Format != "" && s.Pattern != "" {
 		return errors.New("cannot use both format and pattern in the same schema")
 	}
 
+	if s.ContentSchema != nil {
+		if err := s.validateConstraintTypes(profile, "contentSchema", "string"); err != nil {
+			return err
+		}
+		if s.ContentMediaType == "" {
+			return errors.New("contentSchema requires a contentMediaType (e.g. application/yaml)")
+		}
+		if err := s.ContentSchema.ValidateProfile(profile); err != nil {
+			return fmt.Errorf("invalid contentSchema: %w", err)
+		}
+	}
+
+	if s.PropertyNames != nil {
+		if err := s.validateConstraintTypes(profile, "propertyNames", "object"); err != nil {
+			return err
+		}
+		if !s.PropertyNames.Type.IsEmpty() && !slices.Contains(s.PropertyNames.Type, "string") {
+			return errors.New("propertyNames must allow strings, the keys of an object are strings")
+		}
+		if err := s.PropertyNames.ValidateProfile(profile); err != nil {
+			return fmt.Errorf("invalid propertyNames: %w", err)
+		}
+	}
+
 	if s.MaxLength != nil && s.MinLength != nil && *s.MinLength > *s.MaxLength {
 		return fmt.Errorf("minLength (%d) cannot be greater than maxLength (%d)", *s.MinLength, *s.MaxLength)
 	}
@@ -578,20 +782,20 @@ func (s Schema) validateStringConstraints() error {
 	return nil
 }
 
-func (s Schema) validateArrayConstraints() error {
+func (s Schema) validateArrayConstraints(profile ValidationProfile) error {
 	if s.Items != nil {
-		if !s.Type.IsEmpty() && !s.Type.Matches("array") {
-			return fmt.Errorf("items can only be used with array type, got %v", s.Type)
+		if err := s.validateConstraintTypes(profile, "items", "array"); err != nil {
+			return err
 		}
 
-		if err := s.Items.Validate(); err != nil {
+		if err := s.Items.ValidateProfile(profile); err != nil {
 			return fmt.Errorf("invalid items schema: %w", err)
 		}
 	}
 
 	if s.MinItems != nil || s.MaxItems != nil {
-		if !s.Type.IsEmpty() && !s.Type.Matches("array") {
-			return fmt.Errorf("minItems/maxItems can only be used with array type, got %v", s.Type)
+		if err := s.validateConstraintTypes(profile, "minItems/maxItems", "array"); err != nil {
+			return err
 		}
 
 		if s.MinItems != nil && s.MaxItems != nil && *s.MaxItems < *s.MinItems {
@@ -599,14 +803,40 @@ func (s Schema) validateArrayConstraints() error {
 		}
 	}
 
+	if err := s.validateUniqueKeys(profile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s Schema) validateObjectConstraints(profile ValidationProfile) error {
+	if err := s.validatePatternProperties(); err != nil {
+		return err
+	}
+
+	if s.MinProperties != nil || s.MaxProperties != nil {
+		if err := s.validateConstraintTypes(profile, "minProperties/maxProperties", "object"); err != nil {
+			return err
+		}
+
+		if s.MinProperties != nil && *s.MinProperties < 0 {
+			return errors.New("minProperties must be greater than or equal to 0")
+		}
+
+		if s.MinProperties != nil && s.MaxProperties != nil && *s.MaxProperties < *s.MinProperties {
+			return fmt.Errorf("maxProperties (%d) cannot be less than minProperties (%d)", *s.MaxProperties, *s.MinProperties)
+		}
+	}
+
 	return nil
 }
 
-func (s Schema) validateNestedSchemas() error {
+func (s Schema) validateNestedSchemas(profile ValidationProfile) error {
 	// Validate combinatorial schemas
 	for _, schemas := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
 		for _, schema := range schemas {
-			if err := schema.Validate(); err != nil {
+			if err := schema.ValidateProfile(profile); err != nil {
 				return err
 			}
 		}
@@ -615,12 +845,18 @@ func (s Schema) validateNestedSchemas() error {
 	// Validate conditional schemas
 	for _, schema := range []*Schema{s.If, s.Then, s.Else, s.Not} {
 		if schema != nil {
-			if err := schema.Validate(); err != nil {
+			if err := schema.ValidateProfile(profile); err != nil {
 				return err
 			}
 		}
 	}
 
+	if subSchema, ok := s.AdditionalProperties.(Schema); ok {
+		if err := subSchema.ValidateProfile(profile); err != nil {
+			return fmt.Errorf("invalid additionalProperties: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -630,48 +866,246 @@ func (s Schema) hasNumericConstraints() bool {
 		s.MultipleOf != nil
 }
 
-var possibleSkipFields = []string{"type", "title", "description", "required", "default", "additionalProperties"}
+var possibleSkipFields = []string{"type", "title", "description", "required", "default", "additionalProperties", "format", "items", "examples"}
+
+// Node kinds which can prefix a skipped field (e.g. arrays.title), the field is
+// then only skipped for keys whose value has this kind
+const (
+	skipKindObjects = "objects"
+	skipKindArrays  = "arrays"
+	skipKindScalars = "scalars"
+)
+
+var possibleSkipKinds = []string{skipKindObjects, skipKindArrays, skipKindScalars}
+
+// SkipAutoGenerationFields are the fields which are not auto-generated
+type SkipAutoGenerationFields struct {
+	Type, Title, Description, Required, Default, AdditionalProperties, Format, Items, Examples bool
+}
+
+// set skips the field with the given name, it returns false for unknown names
+func (f *SkipAutoGenerationFields) set(fieldName string) bool {
+	switch fieldName {
+	case "type":
+		f.Type = true
+	case "title":
+		f.Title = true
+	case "description":
+		f.Description = true
+	case "required":
+		f.Required = true
+	case "default":
+		f.Default = true
+	case "additionalProperties":
+		f.AdditionalProperties = true
+	case "format":
+		f.Format = true
+	case "items":
+		f.Items = true
+	case "examples":
+		f.Examples = true
+	default:
+		return false
+	}
+	return true
+}
+
+// merge returns the fields skipped by f or other
+func (f SkipAutoGenerationFields) merge(other SkipAutoGenerationFields) SkipAutoGenerationFields {
+	return SkipAutoGenerationFields{
+		Type:                 f.Type || other.Type,
+		Title:                f.Title || other.Title,
+		Description:          f.Description || other.Description,
+		Required:             f.Required || other.Required,
+		Default:              f.Default || other.Default,
+		AdditionalProperties: f.AdditionalProperties || other.AdditionalProperties,
+		Format:               f.Format || other.Format,
+		Items:                f.Items || other.Items,
+		Examples:             f.Examples || other.Examples,
+	}
+}
 
 type SkipAutoGenerationConfig struct {
-	Type, Title, Description, Required, Default, AdditionalProperties bool
+	Type, Title, Description, Required, Default, AdditionalProperties, Format, Items, Examples bool
+
+	// Objects, Arrays and Scalars are only skipped for keys with a value of this kind
+	Objects, Arrays, Scalars SkipAutoGenerationFields
+
+	// overrides are only applied to keys matching the path pattern
+	overrides []skipAutoGenerationOverride
+	// root is the config the path specific config was derived from
+	root *SkipAutoGenerationConfig
+	path string
+}
+
+type skipAutoGenerationOverride struct {
+	pattern string
+	config  *SkipAutoGenerationConfig
 }
 
+// NewSkipAutoGenerationConfig parses the fields to skip. A field can be prefixed with
+// the kind of value it is skipped for (objects, arrays or scalars), e.g. arrays.title.
 func NewSkipAutoGenerationConfig(flag []string) (*SkipAutoGenerationConfig, error) {
 	var config SkipAutoGenerationConfig
+	var allKinds SkipAutoGenerationFields
 
 	var invalidFlags []string
 
 	for _, fieldName := range flag {
-		if !slices.Contains(possibleSkipFields, fieldName) {
-			invalidFlags = append(invalidFlags, fieldName)
-		}
-		if fieldName == "type" {
-			config.Type = true
-		}
-		if fieldName == "title" {
-			config.Title = true
-		}
-		if fieldName == "description" {
-			config.Description = true
-		}
-		if fieldName == "required" {
-			config.Required = true
-		}
-		if fieldName == "default" {
-			config.Default = true
+		fields := &allKinds
+		if kind, name, ok := strings.Cut(fieldName, "."); ok {
+			switch kind {
+			case skipKindObjects:
+				fields = &config.Objects
+			case skipKindArrays:
+				fields = &config.Arrays
+			case skipKindScalars:
+				fields = &config.Scalars
+			default:
+				invalidFlags = append(invalidFlags, fieldName)
+				continue
+			}
+			if !fields.set(name) {
+				invalidFlags = append(invalidFlags, fieldName)
+			}
+			continue
 		}
-		if fieldName == "additionalProperties" {
-			config.AdditionalProperties = true
+		if !fields.set(fieldName) {
+			invalidFlags = append(invalidFlags, fieldName)
 		}
 	}
 
 	if len(invalidFlags) != 0 {
-		return nil, fmt.Errorf("unsupported field names '%s' for skipping auto-generation", strings.Join(invalidFlags, "', '"))
+		return nil, fmt.Errorf(
+			"unsupported field names '%s' for skipping auto-generation (possible: %s, optionally prefixed with %s)",
+			strings.Join(invalidFlags, "', '"),
+			strings.Join(possibleSkipFields, ", "),
+			strings.Join(possibleSkipKinds, ", "),
+		)
 	}
+	config.setFields(allKinds)
 
 	return &config, nil
 }
 
+// AddPathOverride skips the auto-generation of the given fields for all keys matching the pattern.
+// The pattern is a dotted key path (e.g. "env.*"), where "*" matches a single key and "**" matches
+// any number of keys. Keys within list items use the path of the list.
+// The fields are skipped in addition to the globally skipped fields.
+func (c *SkipAutoGenerationConfig) AddPathOverride(pattern string, fields []string) error {
+	config, err := NewSkipAutoGenerationConfig(fields)
+	if err != nil {
+		return fmt.Errorf("invalid skip-auto-generation override for path %s: %w", pattern, err)
+	}
+	c.overrides = append(c.overrides, skipAutoGenerationOverride{pattern: pattern, config: config})
+	return nil
+}
+
+// ForKey returns the config which applies to the given child key
+func (c *SkipAutoGenerationConfig) ForKey(key string) *SkipAutoGenerationConfig {
+	root := c
+	if c.root != nil {
+		root = c.root
+	}
+
+	path := key
+	if c.path != "" {
+		path = c.path + "." + key
+	}
+
+	if len(root.overrides) == 0 {
+		return root
+	}
+
+	config := &SkipAutoGenerationConfig{
+		Objects: root.Objects,
+		Arrays:  root.Arrays,
+		Scalars: root.Scalars,
+		root:    root,
+		path:    path,
+	}
+	config.setFields(root.fields())
+
+	for _, override := range root.overrides {
+		if !matchKeyPath(strings.Split(override.pattern, "."), strings.Split(path, ".")) {
+			continue
+		}
+		config.setFields(config.fields().merge(override.config.fields()))
+		config.Objects = config.Objects.merge(override.config.Objects)
+		config.Arrays = config.Arrays.merge(override.config.Arrays)
+		config.Scalars = config.Scalars.merge(override.config.Scalars)
+	}
+
+	return config
+}
+
+// ForKind returns the fields which are skipped for a key whose value has the given kind
+func (c *SkipAutoGenerationConfig) ForKind(kind yaml.Kind) SkipAutoGenerationFields {
+	switch kind {
+	case yaml.MappingNode:
+		return c.fields().merge(c.Objects)
+	case yaml.SequenceNode:
+		return c.fields().merge(c.Arrays)
+	case yaml.ScalarNode:
+		return c.fields().merge(c.Scalars)
+	}
+	return c.fields()
+}
+
+// fields returns the fields which are skipped for all kinds
+func (c *SkipAutoGenerationConfig) fields() SkipAutoGenerationFields {
+	return SkipAutoGenerationFields{
+		Type:                 c.Type,
+		Title:                c.Title,
+		Description:          c.Description,
+		Required:             c.Required,
+		Default:              c.Default,
+		AdditionalProperties: c.AdditionalProperties,
+		Format:               c.Format,
+		Items:                c.Items,
+		Examples:             c.Examples,
+	}
+}
+
+func (c *SkipAutoGenerationConfig) setFields(f SkipAutoGenerationFields) {
+	c.Type = f.Type
+	c.Title = f.Title
+	c.Description = f.Description
+	c.Required = f.Required
+	c.Default = f.Default
+	c.AdditionalProperties = f.AdditionalProperties
+	c.Format = f.Format
+	c.Items = f.Items
+	c.Examples = f.Examples
+}
+
+// matchKeyPath checks if the key path matches the pattern.
+// "*" matches a single key, "**" any number of keys.
+func matchKeyPath(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchKeyPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	if pattern[0] != "*" && pattern[0] != path[0] {
+		return false
+	}
+
+	return matchKeyPath(pattern[1:], path[1:])
+}
+
 func typeFromTag(tag string) ([]string, error) {
 	switch tag {
 	case nullTag:
@@ -686,6 +1120,8 @@ func typeFromTag(tag string) ([]string, error) {
 		return []string{"number"}, nil
 	case timestampTag:
 		return []string{"string"}, nil
+	case binaryTag:
+		return []string{"string"}, nil
 	case arrayTag:
 		return []string{"array"}, nil
 	case mapTag:
@@ -694,18 +1130,37 @@ func typeFromTag(tag string) ([]string, error) {
 	return []string{}, fmt.Errorf("unsupported yaml tag found: %s", tag)
 }
 
+// typeFromNode returns the type of the given node. Nodes with unsupported (e.g. custom)
+// tags fall back to the type matching their kind; the error is returned anyway,
+// so the caller can report it.
+func typeFromNode(node *yaml.Node) ([]string, error) {
+	nodeType, err := typeFromTag(node.Tag)
+	if err == nil {
+		return nodeType, nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		return []string{"object"}, err
+	case yaml.SequenceNode:
+		return []string{"array"}, err
+	}
+	return []string{"string"}, err
+}
+
 // FixRequiredProperties iterates over the properties and checks if required has a boolean value.
 // Then the property is added to the parents required property list
 func FixRequiredProperties(schema *Schema) error {
 	if schema.Properties != nil {
 		for propName, propValue := range schema.Properties {
 			FixRequiredProperties(propValue)
-			if propValue.Required.Bool && !slices.Contains(schema.Required.Strings, propName) {
+			// computed values are set by the chart, so users never have to set them
+			if propValue.Required.Bool && !propValue.Computed && !slices.Contains(schema.Required.Strings, propName) {
 				schema.Required.Strings = append(schema.Required.Strings, propName)
 			}
 		}
-		if !slices.Contains(schema.Type, "object") {
-			// If .Properties is set, type must be object
+		if !schema.Type.IsEmpty() && !slices.Contains(schema.Type, "object") {
+			// If .Properties is set, type must be object (unless the type is skipped)
 			schema.Type = []string{"object"}
 		}
 	}
@@ -726,9 +1181,14 @@ func FixRequiredProperties(schema *Schema) error {
 		FixRequiredProperties(schema.Items)
 	}
 
+	if schema.PropertyNames != nil {
+		FixRequiredProperties(schema.PropertyNames)
+	}
+
 	if schema.AdditionalProperties != nil {
 		if subSchema, ok := schema.AdditionalProperties.(Schema); ok {
 			FixRequiredProperties(&subSchema)
+			schema.AdditionalProperties = subSchema
 		}
 	}
 
@@ -754,6 +1214,10 @@ func FixRequiredProperties(schema *Schema) error {
 		FixRequiredProperties(schema.Not)
 	}
 
+	if schema.ContentSchema != nil {
+		FixRequiredProperties(schema.ContentSchema)
+	}
+
 	return nil
 }
 
@@ -762,23 +1226,33 @@ func FixRequiredProperties(schema *Schema) error {
 // and any error. Root schema annotations are useful for applying schema properties to the
 // entire values file rather than individual keys.
 func GetRootSchemaFromComment(comment string) (Schema, string, error) {
+	return getRootSchemaFromComment(comment, &generation{})
+}
+
+// getRootSchemaFromComment is GetRootSchemaFromComment with the policy of the generation
+func getRootSchemaFromComment(comment string, gen *generation) (Schema, string, error) {
 	var result Schema
 	scanner := bufio.NewScanner(strings.NewReader(comment))
 	rootSchemaLines := []string{}
+	// the lines of the comment the root schema lines were taken from
+	commentLines := []int{}
 	remainingCommentLines := []string{}
 	insideRootSchemaBlock := false
 	foundRootSchema := false
+	openedInLine := 0
 
-	for scanner.Scan() {
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
 		line := scanner.Text()
 		if strings.HasPrefix(line, SchemaRootPrefix) {
 			insideRootSchemaBlock = !insideRootSchemaBlock
 			foundRootSchema = true
+			openedInLine = lineNumber
 			continue
 		}
 		if insideRootSchemaBlock {
 			content := strings.TrimPrefix(line, CommentPrefix)
 			rootSchemaLines = append(rootSchemaLines, strings.TrimPrefix(strings.TrimPrefix(content, CommentPrefix), " "))
+			commentLines = append(commentLines, lineNumber)
 			result.Set()
 		} else {
 			remainingCommentLines = append(remainingCommentLines, line)
@@ -786,15 +1260,17 @@ func GetRootSchemaFromComment(comment string) (Schema, string, error) {
 	}
 
 	if insideRootSchemaBlock {
-		return result, "",
-			fmt.Errorf("unclosed root schema block found in comment: %s", comment)
+		return result, "", unclosedBlockError(SchemaRootPrefix, openedInLine)
 	}
 
 	if foundRootSchema {
-		err := yaml.Unmarshal([]byte(strings.Join(rootSchemaLines, "\n")), &result)
+		rawYaml, err := annotationYaml(rootSchemaLines, commentLines, gen.RelaxedAnnotationIndentation)
 		if err != nil {
 			return result, "", err
 		}
+		if err := yaml.Unmarshal(rawYaml, &result); err != nil {
+			return result, "", annotationSyntaxError(err, rawYaml, commentLines)
+		}
 	}
 
 	return result, strings.Join(remainingCommentLines, "\n"), nil
@@ -802,82 +1278,130 @@ func GetRootSchemaFromComment(comment string) (Schema, string, error) {
 
 // GetSchemaFromComment parses the annotations from the given comment
 func GetSchemaFromComment(comment string) (Schema, string, error) {
-	var result Schema
+	return getSchemaFromComment(comment, &generation{})
+}
+
+// commentAnnotation is a comment split into its @schema blocks, examples and description
+type commentAnnotation struct {
+	// schemaLines are the lines of the @schema blocks without comment prefix
+	schemaLines []string
+	// commentLines are the lines of the comment the schema lines were taken from
+	commentLines []int
+	examples     []string
+	description  []string
+}
+
+// scanComment splits the comment into its @schema blocks, examples and description
+func scanComment(comment string) (commentAnnotation, error) {
+	var result commentAnnotation
 	scanner := bufio.NewScanner(strings.NewReader(comment))
-	description := []string{}
-	rawSchema := []string{}
 	insideSchemaBlock := false
+	openedInLine := 0
 
-	for scanner.Scan() {
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
 		line := scanner.Text()
 		if strings.HasPrefix(line, SchemaPrefix) {
 			insideSchemaBlock = !insideSchemaBlock
+			openedInLine = lineNumber
+			continue
+		}
+		if example, ok := exampleFromLine(line); ok && !insideSchemaBlock {
+			if example != "" {
+				result.examples = append(result.examples, example)
+			}
 			continue
 		}
 		if insideSchemaBlock {
 			content := strings.TrimPrefix(line, CommentPrefix)
-			rawSchema = append(rawSchema, strings.TrimPrefix(strings.TrimPrefix(content, CommentPrefix), " "))
-			result.Set()
+			result.schemaLines = append(result.schemaLines, strings.TrimPrefix(strings.TrimPrefix(content, CommentPrefix), " "))
+			result.commentLines = append(result.commentLines, lineNumber)
 		} else {
-			description = append(description, strings.TrimPrefix(strings.TrimPrefix(line, CommentPrefix), " "))
+			result.description = append(result.description, strings.TrimPrefix(strings.TrimPrefix(line, CommentPrefix), " "))
 		}
 	}
 
 	if insideSchemaBlock {
-		return result, "",
-			fmt.Errorf("unclosed schema block found in comment: %s", comment)
+		return result, unclosedBlockError(SchemaPrefix, openedInLine)
+	}
+	return result, nil
+}
+
+// yaml returns the yaml of the @schema blocks, relaxed dedents them (see annotationYaml)
+func (a commentAnnotation) yaml(relaxed bool) ([]byte, error) {
+	return annotationYaml(a.schemaLines, a.commentLines, relaxed)
+}
+
+// getSchemaFromComment is GetSchemaFromComment with the policy of the generation
+func getSchemaFromComment(comment string, gen *generation) (Schema, string, error) {
+	annotation, err := scanComment(comment)
+	if err != nil {
+		return Schema{}, "", err
 	}
 
-	err := yaml.Unmarshal([]byte(strings.Join(rawSchema, "\n")), &result)
+	rawYaml, err := annotation.yaml(gen.RelaxedAnnotationIndentation)
+	if err != nil {
+		return Schema{}, "", err
+	}
+	result, err := parseAnnotation(rawYaml, annotation.commentLines, gen)
 	if err != nil {
 		return result, "", err
 	}
+	result.commentExamples = annotation.examples
 
-	return result, strings.Join(description, "\n"), nil
+	return result, strings.Join(annotation.description, "\n"), nil
 }
 
-// checkUsesDefinitions recursively checks if a schema contains any $ref to #/definitions/
-func checkUsesDefinitions(s *Schema) bool {
-	if s == nil {
-		return false
+// parseAnnotation parses the yaml of an annotation, commentLines are the lines of the comment
+// its lines were taken from
+func parseAnnotation(rawYaml []byte, commentLines []int, gen *generation) (Schema, error) {
+	var result Schema
+	if len(commentLines) > 0 {
+		result.Set()
 	}
 
-	// Check direct $ref
-	if strings.Contains(s.Ref, "#/definitions/") {
-		return true
+	if err := result.applyPreset(rawYaml); err != nil {
+		return result, err
 	}
 
-	// Check properties
-	for _, prop := range s.Properties {
-		if checkUsesDefinitions(prop) {
-			return true
-		}
+	if err := yaml.Unmarshal(rawYaml, &result); err != nil {
+		return result, annotationSyntaxError(err, rawYaml, commentLines)
 	}
 
-	// Check composition schemas
-	for _, sub := range s.AllOf {
-		if checkUsesDefinitions(sub) {
-			return true
-		}
+	if err := result.expandVariants(); err != nil {
+		return result, err
 	}
-	for _, sub := range s.AnyOf {
-		if checkUsesDefinitions(sub) {
-			return true
-		}
-	}
-	for _, sub := range s.OneOf {
-		if checkUsesDefinitions(sub) {
-			return true
-		}
-	}
-	if checkUsesDefinitions(s.Not) {
-		return true
-	}
-	if checkUsesDefinitions(s.Items) {
-		return true
+
+	result.anchorPatternProperties(gen)
+	result.applyUniqueKeys()
+	result.expandForbidden()
+
+	// renamed keys are generated like keys without annotation, if renamedFrom is the only annotation
+	if result.HasData && len(result.RenamedFrom) > 0 {
+		rest := result
+		rest.RenamedFrom = nil
+		result.HasData = !rest.EqualsOpt(&Schema{}, EqualsFull)
 	}
 
-	return false
+	// block scalars (description: |) only end with a line break if other keys follow
+	// in the annotation, so trailing line breaks are always removed
+	_ = result.Walk(func(_ string, v *Schema) error {
+		v.Description = strings.TrimRight(v.Description, "\r\n")
+		return nil
+	})
+
+	return result, nil
+}
+
+// checkUsesDefinitions checks if the schema or one of its subschemas contains a $ref to #/definitions/
+func checkUsesDefinitions(s *Schema) bool {
+	usesDefinitions := false
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if strings.Contains(v.Ref, "#/definitions/") {
+			usesDefinitions = true
+		}
+		return nil
+	})
+	return usesDefinitions
 }
 
 // YamlToSchema recursively parses a YAML node and creates a JSON Schema from it
@@ -891,6 +1415,9 @@ func checkUsesDefinitions(s *Schema) bool {
 //   - skipAutoGeneration: configuration for which fields should not be auto-generated
 //   - parentRequiredProperties: list of required properties to populate in parent
 //   - collectedDefs: map to collect $defs from referenced schemas (only used at document level)
+//
+// New options are only added to YamlToSchemaWithOptions, which should be preferred. Unlike it,
+// YamlToSchema calls Fatalf of the default logger, if the schema can't be generated.
 func YamlToSchema(
 	valuesPath string,
 	node *yaml.Node,
@@ -902,30 +1429,65 @@ func YamlToSchema(
 	parentRequiredProperties *[]string,
 	collectedDefs *map[string]*Schema,
 ) *Schema {
+	gen, err := GenerationPolicy{
+		KeepFullComment:           keepFullComment,
+		HelmDocsCompatibilityMode: helmDocsCompatibilityMode,
+		DontStripHelmDocsPrefix:   dontRemoveHelmDocsPrefix,
+		DontAddGlobal:             dontAddGlobal,
+	}.compile()
+	if err != nil {
+		// the default policy is always valid
+		panic(err)
+	}
+	schema, err := yamlToSchema(valuesPath, node, gen, skipAutoGeneration, parentRequiredProperties, collectedDefs)
+	if err != nil {
+		gen.log().Fatalf("%v", err)
+	}
+	return schema
+}
+
+// yamlToSchema is YamlToSchema with the checked policy, skipAutoGeneration is the config
+// for the node (see SkipAutoGenerationConfig.ForKey)
+func yamlToSchema(
+	valuesPath string,
+	node *yaml.Node,
+	gen *generation,
+	skipAutoGeneration *SkipAutoGenerationConfig,
+	parentRequiredProperties *[]string,
+	collectedDefs *map[string]*Schema,
+) (*Schema, error) {
 	schema := NewSchema("object")
 
 	switch node.Kind {
 	case yaml.DocumentNode:
 		if len(node.Content) != 1 {
-			log.Fatalf("Strange yaml document found:\n%v\n", node.Content[:])
+			return nil, fmt.Errorf("strange yaml document found:\n%v", node.Content[:])
 		}
 
-		schema.Schema = "http://json-schema.org/draft-07/schema#"
+		schema.Schema = Draft07URI
+
+		// the annotations of the keys of flow-style mappings belong to the document
+		documentGen := *gen
+		documentGen.flowAnnotations = make(map[*yaml.Node]flowAnnotation)
+		gen = &documentGen
+
+		// keys using aliases (key: *anchor) keep the annotation of the anchored value
+		inheritAnchorAnnotations(node, gen)
 
 		// Create a map to collect definitions from referenced schemas
 		collectedDefsMap := make(map[string]*Schema)
 
-		contentSchema := YamlToSchema(
+		contentSchema, err := yamlToSchema(
 			valuesPath,
 			node.Content[0],
-			keepFullComment,
-			helmDocsCompatibilityMode,
-			dontRemoveHelmDocsPrefix,
-			dontAddGlobal,
+			gen,
 			skipAutoGeneration,
 			&schema.Required.Strings,
 			&collectedDefsMap,
 		)
+		if err != nil {
+			return nil, err
+		}
 
 		// Copy properties from the content schema
 		schema.Properties = contentSchema.Properties
@@ -1016,7 +1578,7 @@ func YamlToSchema(
 			}
 		}
 
-		if _, ok := schema.Properties["global"]; !ok && !dontAddGlobal {
+		if _, ok := schema.Properties["global"]; !ok && !gen.DontAddGlobal {
 			// global key must be present, otherwise helm lint will fail
 			if schema.Properties == nil {
 				schema.Properties = make(map[string]*Schema)
@@ -1024,36 +1586,44 @@ func YamlToSchema(
 			schema.Properties["global"] = NewSchema(
 				"object",
 			)
-			if !skipAutoGeneration.Title {
+			globalSkipAutoGeneration := skipAutoGeneration.ForKind(yaml.MappingNode)
+			if !globalSkipAutoGeneration.Title {
 				schema.Properties["global"].Title = "global"
 			}
-			if !skipAutoGeneration.Description {
+			if !globalSkipAutoGeneration.Description {
 				schema.Properties["global"].Description = "Global values are values that can be accessed from any chart or subchart by exactly the same name."
 			}
 		}
 
 		// always disable on top level (unless root schema specifies otherwise)
-		if !skipAutoGeneration.AdditionalProperties && schema.AdditionalProperties == nil {
+		if !skipAutoGeneration.ForKind(yaml.MappingNode).AdditionalProperties && schema.AdditionalProperties == nil {
 			schema.AdditionalProperties = new(bool)
 		}
+
+		// `required: true` of properties defined by annotations must be added to the
+		// required list of their parent, which only happens for keys of the values file
+		FixRequiredProperties(schema)
+
+		// the old names of renamed keys are still accepted
+		schema.addRenamedKeys(gen.log())
 	case yaml.MappingNode:
 		// Check if the first key has root schema annotations (only for root-level mappings)
 		if len(node.Content) > 0 && parentRequiredProperties != nil {
 			firstKeyNode := node.Content[0]
 
 			comment := firstKeyNode.HeadComment
-			if !keepFullComment {
-				leadingCommentsRemover := regexp.MustCompile(`(?s)(?m)(?:.*\n{2,})+`)
-				comment = leadingCommentsRemover.ReplaceAllString(comment, "")
-			}
+			comment = gen.cutLeadingComment(comment)
 
 			// Try to extract root schema annotations
-			rootSchema, remainingComment, err := GetRootSchemaFromComment(comment)
+			rootSchema, remainingComment, err := getRootSchemaFromComment(comment, gen)
 			if err != nil {
-				log.Fatalf("Error while parsing root schema comment: %v", err)
+				return nil, fmt.Errorf("%s:%d: error while parsing root schema comment: %w", valuesPath, annotationErrorLine(err, comment, firstKeyNode.Line), err)
 			}
 
 			if rootSchema.HasData {
+				// forbidden keys of the values become a not (or allOf), which is applied below
+				rootSchema.expandForbidden()
+
 				// Apply root schema annotations to the schema being built
 				if rootSchema.Title != "" {
 					schema.Title = rootSchema.Title
@@ -1062,7 +1632,9 @@ func YamlToSchema(
 					schema.Description = rootSchema.Description
 				}
 				if rootSchema.Ref != "" {
-					handleSchemaRefs(&rootSchema, valuesPath, collectedDefs)
+					if err := handleSchemaRefs(&rootSchema, valuesPath, gen, collectedDefs); err != nil {
+						return nil, fmt.Errorf("%s:%d: error while resolving the $refs of the root schema: %w", valuesPath, firstKeyNode.Line, err)
+					}
 					schema.Ref = rootSchema.Ref
 				}
 				if len(rootSchema.Examples) > 0 {
@@ -1080,6 +1652,9 @@ func YamlToSchema(
 				if rootSchema.AdditionalProperties != nil {
 					schema.AdditionalProperties = rootSchema.AdditionalProperties
 				}
+				if rootSchema.PropertyNames != nil {
+					schema.PropertyNames = rootSchema.PropertyNames
+				}
 				if len(rootSchema.CustomAnnotations) > 0 {
 					if schema.CustomAnnotations == nil {
 						schema.CustomAnnotations = make(map[string]interface{})
@@ -1094,7 +1669,9 @@ func YamlToSchema(
 					// Process $refs in allOf
 					for _, subSchema := range schema.AllOf {
 						if subSchema.Ref != "" {
-							handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+							if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+								return nil, fmt.Errorf("%s:%d: error while resolving the $refs of the root schema: %w", valuesPath, firstKeyNode.Line, err)
+							}
 						}
 					}
 				}
@@ -1103,7 +1680,9 @@ func YamlToSchema(
 					// Process $refs in anyOf
 					for _, subSchema := range schema.AnyOf {
 						if subSchema.Ref != "" {
-							handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+							if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+								return nil, fmt.Errorf("%s:%d: error while resolving the $refs of the root schema: %w", valuesPath, firstKeyNode.Line, err)
+							}
 						}
 					}
 				}
@@ -1112,19 +1691,23 @@ func YamlToSchema(
 					// Process $refs in oneOf
 					for _, subSchema := range schema.OneOf {
 						if subSchema.Ref != "" {
-							handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+							if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+								return nil, fmt.Errorf("%s:%d: error while resolving the $refs of the root schema: %w", valuesPath, firstKeyNode.Line, err)
+							}
 						}
 					}
 				}
 				if rootSchema.Not != nil {
 					schema.Not = rootSchema.Not
 					if schema.Not.Ref != "" {
-						handleSchemaRefs(schema.Not, valuesPath, collectedDefs)
+						if err := handleSchemaRefs(schema.Not, valuesPath, gen, collectedDefs); err != nil {
+							return nil, fmt.Errorf("%s:%d: error while resolving the $refs of the root schema: %w", valuesPath, firstKeyNode.Line, err)
+						}
 					}
 				}
 
-				if err := rootSchema.Validate(); err != nil {
-					log.Fatalf("Error while validating root jsonschema: %v", err)
+				if err := rootSchema.ValidateProfile(structuralValidationProfile); err != nil {
+					return nil, fmt.Errorf("%s: error while validating root jsonschema: %w", valuesPath, err)
 				}
 
 				// Update the first key's comment to exclude the root schema annotations
@@ -1135,104 +1718,239 @@ func YamlToSchema(
 		for i := 0; i < len(node.Content); i += 2 {
 			keyNode := node.Content[i]
 			valueNode := node.Content[i+1]
+			keySkipAutoGeneration := skipAutoGeneration.ForKey(keyNode.Value)
 
 			if valueNode.Kind == yaml.AliasNode {
 				valueNode = valueNode.Alias
 			}
+			valueSkipAutoGeneration := keySkipAutoGeneration.ForKind(valueNode.Kind)
 
 			comment := keyNode.HeadComment
-			if !keepFullComment {
-				leadingCommentsRemover := regexp.MustCompile(`(?s)(?m)(?:.*\n{2,})+`)
-				comment = leadingCommentsRemover.ReplaceAllString(comment, "")
-			}
+			comment = gen.cutLeadingComment(comment)
 
-			keyNodeSchema, description, err := GetSchemaFromComment(comment)
+			keyNodeSchema, description, err := getSchemaFromComment(comment, gen)
 			if err != nil {
-				log.Fatalf("Error while parsing comment of key %s: %v", keyNode.Value, err)
+				return nil, fmt.Errorf("%s:%d: error while parsing comment of key %s: %w", valuesPath, annotationErrorLine(err, comment, keyNode.Line), keyNode.Value, err)
 			}
 
-			if helmDocsCompatibilityMode {
-				_, helmDocsValue := helm.ParseComment(strings.Split(keyNode.HeadComment, "\n"))
-				if helmDocsValue.Default != "" {
-					keyNodeSchema.Set()
-					keyNodeSchema.Default = helmDocsValue.Default
+			// The keys of flow-style mappings can't have comments, they're annotated in the properties of the key
+			annotation, isFlowKey := gen.flowAnnotations[keyNode]
+			if isFlowKey {
+				keyNodeSchema, err = parseAnnotation(annotation.yaml, annotation.commentLines, gen)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: error while parsing the annotation of key %s in the properties of its parent: %w", valuesPath, keyNode.Line, keyNode.Value, err)
+				}
+			} else if isFlowMapping(valueNode) && len(keyNodeSchema.Properties) > 0 {
+				annotation, err = commentFlowAnnotation(comment, gen)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: error while parsing comment of key %s: %w", valuesPath, annotationErrorLine(err, comment, keyNode.Line), keyNode.Value, err)
 				}
-				if helmDocsValue.Description != "" {
+			}
+			flowAnnotated, err := keyNodeSchema.annotateFlowMapping(annotation, valueNode, gen)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: error while parsing comment of key %s: %w", valuesPath, keyNode.Line, keyNode.Value, err)
+			}
+			if err := keyNodeSchema.checkNestedShorthands(comment, gen); err != nil {
+				line := keyNode.Line
+				if !isFlowKey {
+					line = annotationErrorLine(err, comment, keyNode.Line)
+				}
+				return nil, fmt.Errorf("%s:%d: error while parsing comment of key %s: %w", valuesPath, line, keyNode.Value, err)
+			}
+			if !flowAnnotated && isFlowMapping(valueNode) && !isFlowMapping(node) && len(valueNode.Content) > 0 && keyNodeSchema.Properties == nil {
+				gen.log().Warnf(
+					"%s:%d: the keys of the flow-style mapping %s can't have comments, annotate them in the properties of %s or use block style",
+					valuesPath,
+					keyNode.Line,
+					keyNode.Value,
+					keyNode.Value,
+				)
+			}
+
+			if gen.HelmDocsCompatibilityMode {
+				helmDocsValue := parseHelmDocsComment(keyNode.HeadComment)
+				// the description of the annotation takes precedence and helm-docs only
+				// parses descriptions which start with its marker (# --)
+				if helmDocsValue.Description != "" && keyNodeSchema.Description == "" && hasHelmDocsDescription(keyNode.HeadComment) {
 					keyNodeSchema.Set()
 					keyNodeSchema.Description = helmDocsValue.Description
 				}
 				if helmDocsValue.ValueType != "" {
 					helmDocsType, err := helmDocsTypeToSchemaType(helmDocsValue.ValueType)
 					if err != nil {
-						log.Warnln(err)
+						gen.log().Warnf("%s", err)
 					} else {
 						keyNodeSchema.Set()
 						keyNodeSchema.Type = StringOrArrayOfString{helmDocsType}
 					}
 				}
+				// after the type, which complex defaults are checked against
+				if helmDocsValue.Default != "" {
+					keyNodeSchema.Set()
+					keyNodeSchema.Default = helmDocsDefault(keyNode.Value, helmDocsValue.Default, keyNodeSchema.Type, valueNode, gen.log())
+				}
+				if helmDocsValue.Section != "" {
+					keyNodeSchema.Set()
+					keyNodeSchema.setCustomAnnotationIfMissing(SectionAnnotation, helmDocsValue.Section)
+				}
+				if notationType := helmDocsNotationType(helmDocsValue); notationType != "" {
+					keyNodeSchema.Set()
+					keyNodeSchema.setCustomAnnotationIfMissing(NotationTypeAnnotation, notationType)
+					// templates are always strings, whatever they render to
+					if notationType == helmDocsNotationTypeTpl && keyNodeSchema.Type.IsEmpty() {
+						keyNodeSchema.Type = StringOrArrayOfString{"string"}
+					}
+				}
 			}
 
-			if !dontRemoveHelmDocsPrefix {
-				// remove all lines containing helm-docs @tags, like @ignored, or one of those:
-				// https://github.com/norwoodj/helm-docs/blob/v1.14.2/pkg/helm/chart_info.go#L18-L24
-				helmDocsTagsRemover := regexp.MustCompile(`(?ms)(\r\n|\r|\n)?\s*@\w+(\s+--\s)?[^\n\r]*`)
-				description = helmDocsTagsRemover.ReplaceAllString(description, "")
+			if !gen.DontStripHelmDocsPrefix {
+				description = removeHelmDocsPrefix(description)
+			}
 
-				prefixRemover := regexp.MustCompile(`(?m)^--\s?`)
-				description = prefixRemover.ReplaceAllString(description, "")
+			// values listed in the description (e.g. "one of: debug, info") are used like an annotated enum
+			if keyNodeSchema.Enum == nil && keyNodeSchema.Const == nil && !keyNodeSchema.constWasSet &&
+				keyNodeSchema.Type.IsEmpty() && keyNodeSchema.Ref == "" && valueNode.Kind == yaml.ScalarNode {
+				// the key isn't annotated, so its type is still inferred and it stays required
+				if values := gen.enumFromComment(description, valueNode); values != nil {
+					valueType, _ := typeFromNode(valueNode)
+					if slices.Contains(values, castNodeValueByType(valueNode.Value, valueType)) {
+						keyNodeSchema.Enum = values
+					} else {
+						gen.log().Warnf(
+							"%s:%d: the default %s of key %s is not one of the values listed in its description, ignoring them",
+							valuesPath,
+							keyNode.Line,
+							valueNode.Value,
+							keyNode.Value,
+						)
+					}
+				}
 			}
 
+			_, additionalSchema := keyNodeSchema.AdditionalProperties.(Schema)
 			if keyNodeSchema.Ref != "" || len(keyNodeSchema.PatternProperties) > 0 ||
+				additionalSchema || keyNodeSchema.PropertyNames != nil ||
 				len(keyNodeSchema.AllOf) > 0 || len(keyNodeSchema.AnyOf) > 0 ||
 				len(keyNodeSchema.OneOf) > 0 {
-				// Handle $ref in main schema, pattern properties, and composition keywords
-				handleSchemaRefs(&keyNodeSchema, valuesPath, collectedDefs)
+				// Handle $ref in main schema, pattern properties, additional properties, property names and composition keywords
+				if err := handleSchemaRefs(&keyNodeSchema, valuesPath, gen, collectedDefs); err != nil {
+					return nil, fmt.Errorf("%s:%d: error while resolving the $refs of key %s: %w", valuesPath, keyNode.Line, keyNode.Value, err)
+				}
 			}
 
 			if keyNodeSchema.HasData {
-				if err := keyNodeSchema.Validate(); err != nil {
-					log.Fatalf(
-						"Error while validating jsonschema of key %s: %v",
+				if err := keyNodeSchema.ValidateProfile(structuralValidationProfile); err != nil {
+					return nil, fmt.Errorf(
+						"%s:%d: error while validating jsonschema of key %s: %w",
+						valuesPath,
+						keyNode.Line,
 						keyNode.Value,
 						err,
 					)
 				}
-			} else if !skipAutoGeneration.Type {
-				nodeType, err := typeFromTag(valueNode.Tag)
+			} else if !valueSkipAutoGeneration.Type {
+				nodeType, err := typeFromNode(valueNode)
+				if err != nil {
+					gen.log().Warnf("%v, using type %s for key %s", err, nodeType[0], keyNode.Value)
+				}
+				keyNodeSchema.Type = nodeType
+			}
+
+			// nullable: true adds null to the declared type, or to the inferred one if there is none
+			if keyNodeSchema.Nullable && keyNodeSchema.canInferNullableType() && !valueSkipAutoGeneration.Type && valueNode.ShortTag() != nullTag {
+				nodeType, err := typeFromNode(valueNode)
 				if err != nil {
-					log.Fatal(err)
+					gen.log().Warnf("%v, using type %s for key %s", err, nodeType[0], keyNode.Value)
 				}
 				keyNodeSchema.Type = nodeType
 			}
 
+			keyNodeSchema.addCommentExamples(valueSkipAutoGeneration)
+
+			// Pinned values are typed like their value, even if they are annotated
+			if keyNodeSchema.Pinned {
+				keyNodeSchema.ReadOnly = true
+				if keyNodeSchema.Type.IsEmpty() {
+					keyNodeSchema.Type, _ = typeFromNode(valueNode)
+				}
+			}
+
+			// Computed values are set by the chart itself, users should never set them
+			if keyNodeSchema.Computed {
+				keyNodeSchema.ReadOnly = true
+				keyNodeSchema.setCustomAnnotationIfMissing(ComputedAnnotation, computedAnnotationText)
+			}
+
 			// only validate or default if $ref is not set
 			if keyNodeSchema.Ref == "" {
 
 				// Add key to required array of parent
-				if keyNodeSchema.Required.Bool || (len(keyNodeSchema.Required.Strings) == 0 && !skipAutoGeneration.Required && !keyNodeSchema.HasData) {
+				if !keyNodeSchema.Computed && (keyNodeSchema.Required.Bool || (len(keyNodeSchema.Required.Strings) == 0 && !valueSkipAutoGeneration.Required && !keyNodeSchema.HasData)) {
 					if !slices.Contains(*parentRequiredProperties, keyNode.Value) {
 						*parentRequiredProperties = append(*parentRequiredProperties, keyNode.Value)
 					}
 				}
 
-				if !skipAutoGeneration.AdditionalProperties && valueNode.Kind == yaml.MappingNode &&
+				if !valueSkipAutoGeneration.AdditionalProperties && valueNode.Kind == yaml.MappingNode &&
 					(!keyNodeSchema.HasData || keyNodeSchema.AdditionalProperties == nil) {
 					keyNodeSchema.AdditionalProperties = new(bool)
 				}
 
 				// If no title was set, use the key value
-				if keyNodeSchema.Title == "" && !skipAutoGeneration.Title {
+				if keyNodeSchema.Title == "" && !valueSkipAutoGeneration.Title {
 					keyNodeSchema.Title = keyNode.Value
 				}
 
 				// If no description was set, use the rest of the comment as description
-				if keyNodeSchema.Description == "" && !skipAutoGeneration.Description {
+				if keyNodeSchema.Description == "" && !valueSkipAutoGeneration.Description {
 					keyNodeSchema.Description = description
 				}
 
 				// If no default value was set, use the values node value as default
-				if !skipAutoGeneration.Default && keyNodeSchema.Default == nil && valueNode.Kind == yaml.ScalarNode {
-					keyNodeSchema.Default = castNodeValueByType(valueNode.Value, keyNodeSchema.Type)
+				if !valueSkipAutoGeneration.Default && keyNodeSchema.Default == nil && valueNode.Kind == yaml.ScalarNode &&
+					!(keyNodeSchema.Nullable && valueNode.ShortTag() == nullTag) {
+					// without a declared type (e.g. with variants), the default is typed like the yaml value
+					defaultType := keyNodeSchema.Type
+					if defaultType.IsEmpty() {
+						defaultType, _ = typeFromNode(valueNode)
+					}
+					keyNodeSchema.Default = castNodeValueByType(valueNode.Value, defaultType)
+				}
+
+				// Timestamps are just strings in json, so add the matching format
+				// and make sure the default value is valid for it
+				if !valueSkipAutoGeneration.Format && valueNode.Kind == yaml.ScalarNode && valueNode.ShortTag() == timestampTag {
+					format, normalized, err := normalizeTimestamp(valueNode)
+					if err != nil {
+						gen.log().Warnf("Could not parse timestamp of key %s: %v", keyNode.Value, err)
+					} else {
+						if !keyNodeSchema.HasData && keyNodeSchema.Format == "" && keyNodeSchema.Pattern == "" {
+							keyNodeSchema.Format = format
+						}
+						if keyNodeSchema.Default == valueNode.Value {
+							keyNodeSchema.Default = normalized
+						}
+					}
+				}
+
+				// Pinned values must not be overridden, so the value of the key is the only valid one.
+				// The const determines the type, so the type is left out.
+				if keyNodeSchema.Pinned {
+					keyNodeSchema.pin(valueNode, gen.log())
+				}
+
+				// Binary values are base64 encoded strings
+				if !keyNodeSchema.HasData && valueNode.Kind == yaml.ScalarNode && valueNode.Tag == binaryTag {
+					keyNodeSchema.ContentEncoding = "base64"
+				}
+
+				// Block scalars (| or >) often contain embedded files, see DetectContentMediaTypes
+				if isBlockScalar(valueNode) {
+					keyNodeSchema.blockScalarValue = valueNode.Value
+				}
+
+				if valueNode.Kind == yaml.MappingNode && len(valueNode.Content) > 0 {
+					keyNodeSchema.nonEmptyMapValue = true
 				}
 
 				// If the value is another map and no properties are set, get them from default values
@@ -1242,66 +1960,83 @@ func YamlToSchema(
 						keyNodeSchema.Properties = make(map[string]*Schema)
 					}
 
-					generatedProperties := YamlToSchema(
+					generated, err := yamlToSchema(
 						valuesPath,
 						valueNode,
-						keepFullComment,
-						helmDocsCompatibilityMode,
-						dontRemoveHelmDocsPrefix,
-						dontAddGlobal,
-						skipAutoGeneration,
+						gen,
+						keySkipAutoGeneration,
 						&keyNodeSchema.Required.Strings,
 						collectedDefs,
-					).Properties
+					)
+					if err != nil {
+						return nil, err
+					}
+					generatedProperties := generated.Properties
 
 					// Process each property
 					for i := 0; i < len(valueNode.Content); i += 2 {
 						propKeyNode := valueNode.Content[i]
 						// propValueNode := valueNode.Content[i+1]
 
-						// Check if this specific property matches any pattern
-						skipProperty := false
-						for pattern := range keyNodeSchema.PatternProperties {
-							matched, err := regexp.MatchString(pattern, propKeyNode.Value)
-							if err != nil {
-								log.Fatalf("Invalid pattern '%s' in patternProperties: %v", pattern, err)
-							}
-							if matched {
-								skipProperty = true
-								break
-							}
+						// Only add schema for properties which don't match any pattern
+						matched, err := keyNodeSchema.matchPatternProperties(propKeyNode.Value)
+						if err != nil {
+							return nil, fmt.Errorf("%s:%d: error while generating the properties of key %s: %w", valuesPath, keyNode.Line, keyNode.Value, err)
 						}
-
-						// Only add schema for non-skipped properties
-						if !skipProperty {
+						if !matched {
 							keyNodeSchema.Properties[propKeyNode.Value] = generatedProperties[propKeyNode.Value]
 						}
 					}
-				} else if valueNode.Kind == yaml.SequenceNode && keyNodeSchema.Items == nil {
+				} else if valueNode.Kind == yaml.MappingNode && keyNodeSchema.Preset != "" {
+					// Keys which aren't part of the preset are generated as usual
+					generatedRequired := []string{}
+					generated, err := yamlToSchema(
+						valuesPath,
+						valueNode,
+						gen,
+						keySkipAutoGeneration,
+						&generatedRequired,
+						collectedDefs,
+					)
+					if err != nil {
+						return nil, err
+					}
+					generated.Required.Strings = generatedRequired
+					if err := keyNodeSchema.mergePresetProperties(generated, valueNode, keySkipAutoGeneration); err != nil {
+						return nil, fmt.Errorf("%s:%d: error while generating the properties of key %s: %w", valuesPath, keyNode.Line, keyNode.Value, err)
+					}
+					if err := mergeGeneratedProperties(&keyNodeSchema, generated); err != nil {
+						return nil, fmt.Errorf("%s:%d: error while generating the properties of key %s: %w", valuesPath, keyNode.Line, keyNode.Value, err)
+					}
+				} else if valueNode.Kind == yaml.MappingNode && (keyNodeSchema.MergeProperties || flowAnnotated) {
+					// The annotated properties take precedence, all other keys are generated as usual
+					// (the keys of flow-style mappings with annotated properties are generated from their annotations)
+					generatedRequired := []string{}
+					generated, err := yamlToSchema(
+						valuesPath,
+						valueNode,
+						gen,
+						keySkipAutoGeneration,
+						&generatedRequired,
+						collectedDefs,
+					)
+					if err != nil {
+						return nil, err
+					}
+					generated.Required.Strings = generatedRequired
+					if err := mergeGeneratedProperties(&keyNodeSchema, generated); err != nil {
+						return nil, fmt.Errorf("%s:%d: error while generating the properties of key %s: %w", valuesPath, keyNode.Line, keyNode.Value, err)
+					}
+					if keyNodeSchema.Type.IsEmpty() && !valueSkipAutoGeneration.Type {
+						keyNodeSchema.Type = StringOrArrayOfString{"object"}
+					}
+				} else if valueNode.Kind == yaml.SequenceNode && keyNodeSchema.Items == nil && !valueSkipAutoGeneration.Items {
 					// If the value is a sequence, but no items are predefined
-					seqSchema := NewSchema("")
-
-					for _, itemNode := range valueNode.Content {
-						if itemNode.Kind == yaml.ScalarNode {
-							itemNodeType, err := typeFromTag(itemNode.Tag)
-							if err != nil {
-								log.Fatal(err)
-							}
-							seqSchema.AnyOf = append(seqSchema.AnyOf, NewSchema(itemNodeType[0]))
-						} else {
-							itemRequiredProperties := []string{}
-							itemSchema := YamlToSchema(valuesPath, itemNode, keepFullComment, helmDocsCompatibilityMode, dontRemoveHelmDocsPrefix, dontAddGlobal, skipAutoGeneration, &itemRequiredProperties, collectedDefs)
-
-							itemSchema.Required.Strings = append(itemSchema.Required.Strings, itemRequiredProperties...)
-
-							if !skipAutoGeneration.AdditionalProperties && itemNode.Kind == yaml.MappingNode && (!itemSchema.HasData || itemSchema.AdditionalProperties == nil) {
-								itemSchema.AdditionalProperties = new(bool)
-							}
-
-							seqSchema.AnyOf = append(seqSchema.AnyOf, itemSchema)
-						}
+					items, err := sequenceItemsSchema(valuesPath, keyNode.Value, valueNode, gen, keySkipAutoGeneration, collectedDefs)
+					if err != nil {
+						return nil, err
 					}
-					keyNodeSchema.Items = seqSchema
+					keyNodeSchema.Items = items
 
 					// Because the `required` field isn't valid jsonschema (but just a helper boolean)
 					// we must convert them to valid requiredProperties fields
@@ -1309,6 +2044,10 @@ func YamlToSchema(
 				}
 			}
 
+			if keyNodeSchema.Nullable {
+				keyNodeSchema.makeNullable()
+			}
+
 			if schema.Properties == nil {
 				schema.Properties = make(map[string]*Schema)
 			}
@@ -1316,10 +2055,188 @@ func YamlToSchema(
 		}
 	}
 
-	return schema
+	return schema, nil
+}
+
+// sequenceItemsSchema generates the items schema of the sequence of the key from its values.
+// Sequences within the sequence (e.g. [[1, 2], [3, 4]]) get their items schemas recursively.
+func sequenceItemsSchema(
+	valuesPath, key string,
+	sequenceNode *yaml.Node,
+	gen *generation,
+	skipAutoGeneration *SkipAutoGenerationConfig,
+	collectedDefs *map[string]*Schema,
+) (*Schema, error) {
+	seqSchema := NewSchema("")
+
+	for _, itemNode := range sequenceNode.Content {
+		if itemNode.Kind == yaml.AliasNode {
+			itemNode = itemNode.Alias
+		}
+
+		switch itemNode.Kind {
+		case yaml.ScalarNode:
+			itemNodeType, err := typeFromNode(itemNode)
+			if err != nil {
+				gen.log().Warnf("%v, using type %s for an item of key %s", err, itemNodeType[0], key)
+			}
+			itemSchema := NewSchema(itemNodeType[0])
+			if itemNode.Tag == binaryTag {
+				itemSchema.ContentEncoding = "base64"
+			}
+			if isBlockScalar(itemNode) {
+				itemSchema.blockScalarValue = itemNode.Value
+			}
+			seqSchema.AnyOf = append(seqSchema.AnyOf, itemSchema)
+		case yaml.SequenceNode:
+			itemSchema := NewSchema("array")
+			if !skipAutoGeneration.ForKind(yaml.SequenceNode).Items {
+				items, err := sequenceItemsSchema(valuesPath, key, itemNode, gen, skipAutoGeneration, collectedDefs)
+				if err != nil {
+					return nil, err
+				}
+				itemSchema.Items = items
+			}
+			seqSchema.AnyOf = append(seqSchema.AnyOf, itemSchema)
+		default:
+			itemRequiredProperties := []string{}
+			itemSchema, err := yamlToSchema(valuesPath, itemNode, gen, skipAutoGeneration, &itemRequiredProperties, collectedDefs)
+			if err != nil {
+				return nil, err
+			}
+
+			itemSchema.Required.Strings = append(itemSchema.Required.Strings, itemRequiredProperties...)
+
+			if !skipAutoGeneration.ForKind(itemNode.Kind).AdditionalProperties && itemNode.Kind == yaml.MappingNode && (!itemSchema.HasData || itemSchema.AdditionalProperties == nil) {
+				itemSchema.AdditionalProperties = new(bool)
+			}
+
+			seqSchema.AnyOf = append(seqSchema.AnyOf, itemSchema)
+		}
+	}
+	seqSchema.AnyOf = uniqueSortedSchemas(seqSchema.AnyOf, gen.log())
+
+	return seqSchema, nil
+}
+
+// uniqueSortedSchemas removes the duplicates of the schemas and sorts them by their json, so
+// reordering the items of a sequence doesn't change the generated schema. Schemas of block
+// scalars with different values are kept, see DetectContentMediaTypes.
+func uniqueSortedSchemas(schemas []*Schema, log logging.Logger) []*Schema {
+	type keyedSchema struct {
+		key    string
+		schema *Schema
+	}
+
+	keyed := make([]keyedSchema, 0, len(schemas))
+	seen := make(map[string]bool)
+	for _, v := range schemas {
+		jsonStr, err := v.ToJson()
+		if err != nil {
+			log.Debugf("Could not sort the item schemas: %v", err)
+			return schemas
+		}
+		key := string(jsonStr) + "\x00" + v.blockScalarValue
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keyed = append(keyed, keyedSchema{key: key, schema: v})
+	}
+
+	slices.SortStableFunc(keyed, func(a, b keyedSchema) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	unique := make([]*Schema, len(keyed))
+	for i, v := range keyed {
+		unique[i] = v.schema
+	}
+	return unique
+}
+
+// mergeGeneratedProperties adds the generated properties which are missing in the annotated
+// schema. Properties which exist in both are merged recursively, so only the keys the annotation
+// doesn't mention are generated. Keys matching the patternProperties of the annotation are skipped.
+func mergeGeneratedProperties(annotated, generated *Schema) error {
+	added := make(map[string]bool)
+	for name, property := range generated.Properties {
+		if existing, ok := annotated.Properties[name]; ok {
+			if existing != nil && existing.Ref == "" && len(existing.Properties) > 0 && len(property.Properties) > 0 {
+				if err := mergeGeneratedProperties(existing, property); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		matched, err := annotated.matchPatternProperties(name)
+		if err != nil {
+			return err
+		}
+		if matched {
+			continue
+		}
+
+		annotated.Properties[name] = property
+		added[name] = true
+	}
+
+	// keep the order of the values file for the required generated keys
+	for _, name := range generated.Required.Strings {
+		if added[name] && !slices.Contains(annotated.Required.Strings, name) {
+			annotated.Required.Strings = append(annotated.Required.Strings, name)
+		}
+	}
+	return nil
+}
+
+// matchPatternProperties checks if the key matches a pattern of the patternProperties.
+// The first matching pattern (in sorted order) is remembered for PatternMatches.
+func (s *Schema) matchPatternProperties(key string) (bool, error) {
+	patterns := make([]string, 0, len(s.PatternProperties))
+	for pattern := range s.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	slices.Sort(patterns)
+
+	for _, pattern := range patterns {
+		matched, err := regexp.MatchString(pattern, key)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q in patternProperties: %w", pattern, err)
+		}
+		if matched {
+			if s.matchedPatterns == nil {
+				s.matchedPatterns = make(map[string]string)
+			}
+			s.matchedPatterns[key] = pattern
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// helmDocsNotationTypeTpl is the notation type of values which are rendered with tpl
+const helmDocsNotationTypeTpl = "tpl"
+
+// helmDocsNotationType returns the notation type of the value, which is either set
+// by `@notationType` or by a type with a notation prefix (e.g. `tpl/object`)
+func helmDocsNotationType(value helm.ChartValueDescription) string {
+	if value.NotationType != "" {
+		return value.NotationType
+	}
+	if notationType, _, ok := strings.Cut(value.ValueType, "/"); ok {
+		return notationType
+	}
+	return ""
 }
 
 func helmDocsTypeToSchemaType(helmDocsType string) (string, error) {
+	// the value of tpl types (e.g. tpl/object) is a template, which renders to the type
+	if strings.HasPrefix(helmDocsType, helmDocsNotationTypeTpl+"/") {
+		return "string", nil
+	}
+
 	switch helmDocsType {
 	case "int":
 		return "integer", nil
@@ -1338,6 +2255,151 @@ func helmDocsTypeToSchemaType(helmDocsType string) (string, error) {
 	return "", fmt.Errorf("cant translate helm-docs type (%s) to helm-schema type", helmDocsType)
 }
 
+// normalizeTimestamp returns the format matching the timestamp of the node (date or date-time)
+// and the timestamp formatted according to it (RFC 3339)
+func normalizeTimestamp(node *yaml.Node) (string, string, error) {
+	var t time.Time
+	if err := node.Decode(&t); err != nil {
+		return "", "", err
+	}
+
+	if dateOnlyMatcher.MatchString(node.Value) {
+		return FormatDate, t.Format(time.DateOnly), nil
+	}
+
+	return FormatDateTime, t.Format(time.RFC3339Nano), nil
+}
+
+var (
+	// lines containing helm-docs @tags, like @ignored, or one of those:
+	// https://github.com/norwoodj/helm-docs/blob/v1.14.2/pkg/helm/chart_info.go#L18-L24
+	helmDocsTagsRemover = regexp.MustCompile(`(?ms)(\r\n|\r|\n)?\s*@\w+(\s+--\s)?[^\n\r]*`)
+	// the line starting a helm-docs description (# -- or the old style # key -- )
+	// https://github.com/norwoodj/helm-docs/blob/v1.14.2/pkg/helm/chart_info.go#L18
+	helmDocsDescriptionMatcher = regexp.MustCompile(`^\s*#\s*(.*)\s+--\s*(.*)$`)
+	// the marker (--) helm-docs descriptions start with
+	helmDocsPrefixMatcher = regexp.MustCompile(`^\s*--(\s+|$)`)
+)
+
+// withoutSchemaBlocks returns the comment lines outside of @schema blocks
+func withoutSchemaBlocks(lines []string) []string {
+	var result []string
+	insideSchemaBlock := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, SchemaPrefix) {
+			insideSchemaBlock = !insideSchemaBlock
+			continue
+		}
+		if !insideSchemaBlock {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// parseHelmDocsComment parses the helm-docs annotations of the comment, the @schema blocks and examples are ignored
+func parseHelmDocsComment(comment string) helm.ChartValueDescription {
+	lines := withoutExamples(withoutSchemaBlocks(strings.Split(comment, "\n")))
+	if len(lines) == 0 {
+		return helm.ChartValueDescription{}
+	}
+	_, value := helm.ParseComment(lines)
+	return value
+}
+
+// helmDocsDefault returns the default of a helm-docs @default. Maps and lists written as json or yaml
+// (e.g. {"a": 1} or `[a, b]`) are parsed, if they match the declared type or the type of the value.
+// Everything else (e.g. "the name of the release") is kept as text.
+func helmDocsDefault(key, rawDefault string, declared StringOrArrayOfString, valueNode *yaml.Node, log logging.Logger) interface{} {
+	trimmed := strings.TrimSpace(rawDefault)
+	if len(trimmed) > 1 && strings.HasPrefix(trimmed, "`") && strings.HasSuffix(trimmed, "`") {
+		trimmed = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+	}
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return rawDefault
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(trimmed), &parsed); err != nil {
+		log.Debugf("Using the @default of key %s as text, it's not valid json or yaml: %v", key, err)
+		return rawDefault
+	}
+	// the default is written as json, which only supports string keys
+	if _, err := json.Marshal(parsed); err != nil {
+		log.Debugf("Using the @default of key %s as text: %v", key, err)
+		return rawDefault
+	}
+
+	var parsedType string
+	switch parsed.(type) {
+	case map[string]interface{}:
+		parsedType = "object"
+	case []interface{}:
+		parsedType = "array"
+	default:
+		return rawDefault
+	}
+
+	types := declared
+	if types.IsEmpty() && valueNode.ShortTag() != nullTag {
+		types, _ = typeFromNode(valueNode)
+	}
+	if !types.IsEmpty() && !types.Matches(parsedType) {
+		log.Warnf("The @default of key %s is an %s, but the type is %s, using it as text", key, parsedType, strings.Join(types, ", "))
+		return rawDefault
+	}
+	return parsed
+}
+
+// hasHelmDocsDescription checks if a line outside of the @schema blocks starts a helm-docs description
+func hasHelmDocsDescription(comment string) bool {
+	return slices.ContainsFunc(withoutSchemaBlocks(strings.Split(comment, "\n")), helmDocsDescriptionMatcher.MatchString)
+}
+
+// removeHelmDocsPrefix removes the helm-docs tags and the marker of the description.
+// The marker is only removed from the first line, so lines of the description which
+// start with dashes (e.g. markdown rules or command line flags like --set) are kept.
+func removeHelmDocsPrefix(description string) string {
+	description = helmDocsTagsRemover.ReplaceAllString(description, "")
+
+	lines := strings.Split(description, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines[i] = helmDocsPrefixMatcher.ReplaceAllString(line, "")
+		break
+	}
+	return strings.Join(lines, "\n")
+}
+
+var dateOnlyMatcher = regexp.MustCompile(`^\d{4}-\d{1,2}-\d{1,2}$`)
+
+// pin sets the const of the schema to the default of the value, or to the value itself if there
+// is no default, and removes the type. An annotated const takes precedence.
+func (s *Schema) pin(valueNode *yaml.Node, log logging.Logger) {
+	switch {
+	case s.Const != nil || s.constWasSet:
+	case valueNode.ShortTag() == nullTag && (s.Default == nil || s.Default == valueNode.Value):
+		// the generated default of null values is a string
+		s.Default = nil
+		s.Const = nil
+		s.constWasSet = true
+	case s.Default != nil:
+		s.Const = s.Default
+	case valueNode.Kind == yaml.ScalarNode:
+		s.Const = castNodeValueByType(valueNode.Value, s.Type)
+	default:
+		var value interface{}
+		if err := valueNode.Decode(&value); err != nil {
+			log.Warnf("Could not pin value on line %d: %v", valueNode.Line, err)
+			return
+		}
+		s.Const = value
+	}
+	s.Type = nil
+}
+
 // castNodeValueByType attempts to convert a raw string value into the appropriate type based on
 // the provided fieldType. It handles boolean, integer, and number conversions. If the conversion
 // fails or the type is not supported (e.g., string), it returns the original raw value.
@@ -1379,10 +2441,85 @@ func castNodeValueByType(rawValue string, fieldType StringOrArrayOfString) any {
 	return rawValue
 }
 
+// resolveExternalRef resolves the $ref of the schema to the referenced schema (byteValue), which
+// was loaded from the uri. References to definitions are converted to internal references and the
+// definitions are collected, other references are replaced by the referenced schema. The targets of
+// the references of the referenced schema are collected as definitions as well (see refExtractor).
+func resolveExternalRef(schema *Schema, byteValue []byte, uri string, refParts []string, gen *generation, collectedDefs *map[string]*Schema) error {
+	extractor, err := newRefExtractor(byteValue, uri, gen.refLoader())
+	if err == nil && collectedDefs != nil {
+		err = extractor.copyDefinitions()
+	}
+	if err != nil {
+		return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+	}
+
+	pointer := ""
+	if len(refParts) > 1 {
+		// the fragment is a json pointer or an anchor
+		if pointer, err = extractor.pointer(refParts[1]); err != nil {
+			return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+		}
+	}
+
+	// Convert external references to definitions to internal references
+	// e.g., "service-schemas.json#/definitions/baseService" -> "#/definitions/baseService"
+	// or "service-schemas.json#/$defs/baseService" -> "#/$defs/baseService"
+	if isDefinitionRef("#" + pointer) {
+		ref, err := extractor.ref(extractor.root, pointer)
+		if err != nil {
+			return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+		}
+		schema.Ref = ref
+		gen.log().Debugf("Converted external $ref to internal: %s", schema.Ref)
+	} else {
+		// No json-pointer or a pointer to something else than the definitions,
+		// which doesn't exist in the generated schema, so inline the schema
+		target, err := extractor.extract(pointer)
+		if err != nil {
+			return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+		}
+		var relSchema Schema
+		if err := remarshalJSON(target, &relSchema); err != nil {
+			return err
+		}
+		*schema = relSchema
+	}
+	schema.HasData = true
+
+	// the definitions the referenced schema needs (transitively)
+	if collectedDefs == nil || len(extractor.defs) == 0 {
+		return nil
+	}
+	if *collectedDefs == nil {
+		*collectedDefs = make(map[string]*Schema)
+	}
+	for defName, def := range extractor.defs {
+		var defSchema Schema
+		if err := remarshalJSON(def, &defSchema); err != nil {
+			return fmt.Errorf("could not resolve $ref %s: %w", strings.Join(refParts, "#"), err)
+		}
+		if existingDef, exists := (*collectedDefs)[defName]; exists && !existingDef.Equals(&defSchema) {
+			gen.log().Warnf("Definition %s is being overwritten during schema merge", defName)
+		}
+		(*collectedDefs)[defName] = &defSchema
+	}
+	return nil
+}
+
+// remarshalJSON converts a parsed json value to the type of out
+func remarshalJSON(value interface{}, out interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
 // handleSchemaRefs processes and resolves JSON Schema references ($ref) within a schema.
 // It handles both direct schema references and references within patternProperties.
 // For each reference:
-// - If it's a relative file path, it attempts to load and parse the referenced schema
+// - If it's a relative file path or a file:// URL, it attempts to load and parse the referenced schema
 // - If it includes a JSON pointer (#/path/to/schema), it extracts the specific schema section
 // - The resolved schema replaces the original reference
 // - Any $defs from the referenced schema are collected in the collectedDefs map for later merging
@@ -1390,69 +2527,62 @@ func castNodeValueByType(rawValue string, fieldType StringOrArrayOfString) any {
 // Parameters:
 //   - schema: Pointer to the Schema object containing the references to resolve
 //   - valuesPath: Path to the current values file, used for resolving relative paths
+//   - gen: The policy of the generation, e.g. its ref mode
 //   - collectedDefs: Map to collect $defs from referenced schemas (can be nil if not needed)
 //
-// The function will log.Fatal on any critical errors (file not found, invalid JSON, etc.)
-// and log.Debug for non-critical issues (e.g., non-relative paths that may be handled elsewhere)
-func handleSchemaRefs(schema *Schema, valuesPath string, collectedDefs *map[string]*Schema) {
+// The function returns critical errors (file not found, invalid JSON, etc.) and calls Debugf of the logger
+// of the generation for non-critical issues (e.g., non-relative paths that may be handled elsewhere)
+func handleSchemaRefs(schema *Schema, valuesPath string, gen *generation, collectedDefs *map[string]*Schema) error {
+	if gen.RefMode == RefModeKeepAll {
+		return nil
+	}
+
 	// Handle main schema $ref
 	if schema.Ref != "" {
 		refParts := strings.Split(schema.Ref, "#")
-		if relFilePath, err := util.IsRelativeFile(valuesPath, refParts[0]); err == nil {
-			var relSchema Schema
+		// remote schemas are usually cached already, see prefetchRefs
+		if registry.IsRef(refParts[0]) {
+			byteValue, err := pullRegistryRef(gen.context(), refParts[0], gen.log())
+			if err != nil {
+				return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+			}
+			if err := resolveExternalRef(schema, byteValue, refParts[0], refParts, gen, collectedDefs); err != nil {
+				return err
+			}
+		} else if gitref.IsRef(refParts[0]) {
+			byteValue, err := fetchGitRef(gen.context(), refParts[0], gen.log())
+			if err != nil {
+				return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+			}
+			if err := resolveExternalRef(schema, byteValue, refParts[0], refParts, gen, collectedDefs); err != nil {
+				return err
+			}
+		} else if urlref.IsRef(refParts[0]) && gen.RefMode == RefModeInlineAll {
+			byteValue, err := fetchURLRef(gen.context(), refParts[0], gen.log())
+			if err != nil {
+				return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
+			}
+			if err := resolveExternalRef(schema, byteValue, refParts[0], refParts, gen, collectedDefs); err != nil {
+				return err
+			}
+		} else if relFilePath, err := util.ResolveFileRef(valuesPath, refParts[0]); err == nil {
+			gen.recordRefFile(relFilePath)
 			file, err := os.Open(relFilePath)
 			if err == nil {
 				defer file.Close()
 				byteValue, _ := io.ReadAll(file)
-
-				// Extract $defs or definitions from the referenced schema file
-				if collectedDefs != nil {
-					var fullSchema Schema
-					err = json.Unmarshal(byteValue, &fullSchema)
-					if err == nil {
-						if *collectedDefs == nil {
-							*collectedDefs = make(map[string]*Schema)
-						}
-						// Collect from $defs (Draft-07+)
-						for defName, defSchema := range fullSchema.Defs {
-							if existingDef, exists := (*collectedDefs)[defName]; exists {
-								log.Warnf("Definition %s is being overwritten during schema merge", defName)
-								_ = existingDef // avoid unused variable warning
-							}
-							(*collectedDefs)[defName] = defSchema
-						}
-						// Also collect from definitions (Draft-04/06/07)
-						for defName, defSchema := range fullSchema.Definitions {
-							if existingDef, exists := (*collectedDefs)[defName]; exists {
-								log.Warnf("Definition %s is being overwritten during schema merge", defName)
-								_ = existingDef // avoid unused variable warning
-							}
-							(*collectedDefs)[defName] = defSchema
-						}
-					}
+				uri, err := util.PathToFileURL(relFilePath)
+				if err != nil {
+					return fmt.Errorf("could not resolve $ref %s: %w", schema.Ref, err)
 				}
-
-				// Convert external file reference to internal reference
-				// e.g., "service-schemas.json#/definitions/baseService" -> "#/definitions/baseService"
-				// or "service-schemas.json#/$defs/baseService" -> "#/$defs/baseService"
-				if len(refParts) > 1 {
-					schema.Ref = "#" + refParts[1]
-					log.Debugf("Converted external $ref to internal: %s", schema.Ref)
-				} else {
-					// No json-pointer - this shouldn't happen for $defs references
-					// but handle it by inlining the schema
-					err = json.Unmarshal(byteValue, &relSchema)
-					if err != nil {
-						log.Fatal(err)
-					}
-					*schema = relSchema
+				if err := resolveExternalRef(schema, byteValue, uri, refParts, gen, collectedDefs); err != nil {
+					return err
 				}
-				schema.HasData = true
 			} else {
-				log.Fatal(err)
+				return err
 			}
 		} else {
-			log.Debug(err)
+			gen.log().Debugf("%s", err)
 		}
 	}
 
@@ -1460,35 +2590,59 @@ func handleSchemaRefs(schema *Schema, valuesPath string, collectedDefs *map[stri
 	if schema.PatternProperties != nil {
 		for pattern, subSchema := range schema.PatternProperties {
 			if subSchema.Ref != "" {
-				handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+				if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+					return err
+				}
 				schema.PatternProperties[pattern] = subSchema // Update the original schema in the map
 			}
 		}
 	}
 
+	// Handle $ref in the schemas of additional properties and property names
+	if subSchema, ok := schema.AdditionalProperties.(Schema); ok {
+		if err := handleSchemaRefs(&subSchema, valuesPath, gen, collectedDefs); err != nil {
+			return err
+		}
+		schema.AdditionalProperties = subSchema
+	}
+	if schema.PropertyNames != nil {
+		if err := handleSchemaRefs(schema.PropertyNames, valuesPath, gen, collectedDefs); err != nil {
+			return err
+		}
+	}
+
 	// Handle $ref in composition keywords (allOf, anyOf, oneOf)
 	if len(schema.AllOf) > 0 {
 		for _, subSchema := range schema.AllOf {
 			if subSchema.Ref != "" {
-				handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+				if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	if len(schema.AnyOf) > 0 {
 		for _, subSchema := range schema.AnyOf {
 			if subSchema.Ref != "" {
-				handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+				if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	if len(schema.OneOf) > 0 {
 		for _, subSchema := range schema.OneOf {
 			if subSchema.Ref != "" {
-				handleSchemaRefs(subSchema, valuesPath, collectedDefs)
+				if err := handleSchemaRefs(subSchema, valuesPath, gen, collectedDefs); err != nil {
+					return err
+				}
 			}
 		}
 	}
 	if schema.Not != nil && schema.Not.Ref != "" {
-		handleSchemaRefs(schema.Not, valuesPath, collectedDefs)
+		if err := handleSchemaRefs(schema.Not, valuesPath, gen, collectedDefs); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -187,6 +188,200 @@ func TestValidate(t *testing.T) {
 # @schema`,
 			expectedValid: true,
 		},
+		{
+			comment: `
+# @schema
+# type: string
+# format: semver
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# format: k8s-quantity
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: integer
+# format: cron
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# minProperties: 1
+# maxProperties: 3
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# minProperties: 2
+# maxProperties: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: string
+# minProperties: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: [string, null]
+# minLength: 1
+# maxLength: 10
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [null, string]
+# pattern: ^foo
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: ["null", string]
+# format: email
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [null, integer]
+# minimum: 1
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [number, null]
+# multipleOf: 2
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [null, array]
+# minItems: 1
+# uniqueItems: true
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [object, null]
+# maxProperties: 3
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [string, integer]
+# minLength: 1
+# minimum: 1
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# type: [integer, null]
+# minLength: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: null
+# pattern: ^foo
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# type: [boolean, null]
+# maxItems: 1
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# computed: true
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# computed: true
+# required: true
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# pinned: true
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# $comment: Rendered by the deployment template
+# x-docs-url: https://example.org/docs/replicas
+# links:
+#   - rel: describedby
+#     href: https://example.org/docs/replicas
+# @schema`,
+			expectedValid: true,
+		},
+		{
+			comment: `
+# @schema
+# links:
+#   - rel: describedby
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# x-docs-url: [https://example.org]
+# @schema`,
+			expectedValid: false,
+		},
+		{
+			comment: `
+# @schema
+# pinned: true
+# writeOnly: true
+# @schema`,
+			expectedValid: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -290,3 +485,902 @@ func TestConstNullMarshaling(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertCustomFormats(t *testing.T) {
+	tests := []struct {
+		format  string
+		valid   []string
+		invalid []string
+	}{
+		{
+			format:  FormatSemver,
+			valid:   []string{"1.2.3", "v1.0.0", "1.0.0-alpha.1+build.5"},
+			invalid: []string{"1.2", "latest", "01.2.3"},
+		},
+		{
+			format:  FormatCron,
+			valid:   []string{"*/5 * * * *", "0 0 1 1 *", "@daily", "@every 1h30m"},
+			invalid: []string{"* * *", "@sometimes"},
+		},
+		{
+			format:  FormatK8sName,
+			valid:   []string{"my-app", "app.example.org", "a1"},
+			invalid: []string{"My-App", "-app", "app-", "app_name"},
+		},
+		{
+			format:  FormatK8sQuantity,
+			valid:   []string{"100m", "1", "1.5", "512Mi", "1Gi", "1e3"},
+			invalid: []string{"1GB", "abc", ""},
+		},
+		{
+			format:  FormatDurationGo,
+			valid:   []string{"1h", "1h30m", "300ms", "0", "-1.5s"},
+			invalid: []string{"1d", "one hour", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			schema := &Schema{
+				Type: StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{
+					"foo": {Type: StringOrArrayOfString{"string"}, Format: tt.format},
+				},
+			}
+			schema.ConvertCustomFormats()
+
+			prop := schema.Properties["foo"]
+			if prop.Format != "" {
+				t.Fatalf("Expected format to be removed, got %s", prop.Format)
+			}
+			re := regexp.MustCompile(prop.Pattern)
+			for _, v := range tt.valid {
+				if !re.MatchString(v) {
+					t.Errorf("Expected %q to match the %s pattern", v, tt.format)
+				}
+			}
+			for _, v := range tt.invalid {
+				if re.MatchString(v) {
+					t.Errorf("Expected %q to not match the %s pattern", v, tt.format)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipAutoGenerationPathOverrides(t *testing.T) {
+	config, err := NewSkipAutoGenerationConfig([]string{"title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.AddPathOverride("env.*", []string{"required", "additionalProperties"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.AddPathOverride("**.password", []string{"default"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.AddPathOverride("foo", []string{"doesnotexist"}); err == nil {
+		t.Error("Expected an error for an invalid field name")
+	}
+
+	env := config.ForKey("env")
+	assert.Equal(t, false, env.Required)
+	assert.Equal(t, true, env.Title)
+
+	envVar := env.ForKey("FOO")
+	assert.Equal(t, true, envVar.Required)
+	assert.Equal(t, true, envVar.AdditionalProperties)
+	assert.Equal(t, false, envVar.Default)
+	assert.Equal(t, true, envVar.Title)
+
+	assert.Equal(t, false, envVar.ForKey("nested").Required)
+
+	assert.Equal(t, true, config.ForKey("password").Default)
+	assert.Equal(t, true, config.ForKey("db").ForKey("auth").ForKey("password").Default)
+	assert.Equal(t, false, config.ForKey("db").ForKey("password2").Default)
+}
+
+func TestYamlToSchemaWithPathOverrides(t *testing.T) {
+	yamlContent := `env:
+  FOO: bar
+other: baz
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.AddPathOverride("env.*", []string{"required"}); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	assert.Equal(t, []string{"env", "other"}, schema.Required.Strings)
+	assert.Equal(t, 0, len(schema.Properties["env"].Required.Strings))
+}
+
+func TestSkipAutoGenerationNodeKinds(t *testing.T) {
+	yamlContent := `# @example [foo]
+list:
+  - foo
+  - bar
+map:
+  foo: bar
+# @example qux
+scalar: baz
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSkipAutoGenerationConfig([]string{"lists.title"}); err == nil {
+		t.Error("Expected an error for an invalid node kind")
+	}
+	if _, err := NewSkipAutoGenerationConfig([]string{"arrays.doesnotexist"}); err == nil {
+		t.Error("Expected an error for an invalid field name")
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{"arrays.title", "arrays.items", "scalars.default", "scalars.examples"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	assert.Equal(t, schema.Properties["list"].Title, "")
+	assert.Equal(t, true, schema.Properties["list"].Items == nil)
+	assert.Equal(t, schema.Properties["map"].Title, "map")
+	assert.Equal(t, schema.Properties["map"].Properties["foo"].Title, "foo")
+	assert.Equal(t, schema.Properties["map"].Properties["foo"].Default, nil)
+	assert.Equal(t, schema.Properties["scalar"].Title, "scalar")
+	assert.Equal(t, schema.Properties["scalar"].Default, nil)
+	assert.Equal(t, []interface{}{[]interface{}{"foo"}}, schema.Properties["list"].Examples)
+	assert.Equal(t, 0, len(schema.Properties["scalar"].Examples))
+
+	// unprefixed fields are skipped for all kinds
+	config, err = NewSkipAutoGenerationConfig([]string{"title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, config.ForKind(yaml.SequenceNode).Title)
+	assert.Equal(t, true, config.ForKind(yaml.ScalarNode).Title)
+
+	if err := config.AddPathOverride("list", []string{"arrays.items"}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, true, config.ForKey("list").ForKind(yaml.SequenceNode).Items)
+	assert.Equal(t, false, config.ForKey("list").ForKind(yaml.MappingNode).Items)
+	assert.Equal(t, false, config.ForKey("other").ForKind(yaml.SequenceNode).Items)
+}
+
+func TestRequiredInAnnotatedProperties(t *testing.T) {
+	yamlContent := `# @schema
+# type: object
+# properties:
+#   host:
+#     type: string
+#     required: true
+#   port:
+#     type: integer
+#   nested:
+#     type: object
+#     properties:
+#       inner:
+#         type: string
+#         required: true
+#       computed:
+#         type: string
+#         computed: true
+#         required: true
+# @schema
+ingress: {}
+# @schema
+# type: array
+# items:
+#   type: object
+#   properties:
+#     name:
+#       type: string
+#       required: true
+# @schema
+hosts: []
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	ingress := schema.Properties["ingress"]
+	assert.Equal(t, ingress.Required.Strings, []string{"host"})
+	assert.Equal(t, ingress.Properties["nested"].Required.Strings, []string{"inner"})
+	assert.Equal(t, schema.Properties["hosts"].Items.Required.Strings, []string{"name"})
+}
+
+func TestMergeProperties(t *testing.T) {
+	yamlContent := `# @schema
+# mergeProperties: true
+# patternProperties:
+#   "^x-":
+#     type: string
+# properties:
+#   port:
+#     type: integer
+#     minimum: 1
+#   tls:
+#     type: object
+#     properties:
+#       enabled:
+#         type: boolean
+# @schema
+server:
+  port: 8080
+  host: localhost
+  x-extra: foo
+  tls:
+    enabled: false
+    secretName: foo
+# @schema
+# type: object
+# properties:
+#   port:
+#     type: integer
+# @schema
+other:
+  port: 8080
+  host: localhost
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	server := schema.Properties["server"]
+	assert.Equal(t, server.Type, StringOrArrayOfString{"object"})
+	assert.Equal(t, *server.Properties["port"].Minimum, 1)
+	assert.Equal(t, server.Properties["port"].Title, "")
+	assert.Equal(t, server.Properties["host"].Default, "localhost")
+	assert.Equal(t, server.Properties["x-extra"] == nil, true)
+	assert.Equal(t, server.Required.Strings, []string{"host"})
+	assert.Equal(t, server.Properties["tls"].Properties["secretName"].Default, "foo")
+	assert.Equal(t, server.Properties["tls"].Required.Strings, []string{"secretName"})
+
+	// without mergeProperties only the annotated properties are used
+	assert.Equal(t, len(schema.Properties["other"].Properties), 1)
+}
+
+func TestDisableRequiredProperties(t *testing.T) {
+	required := func(names ...string) BoolOrArrayOfString {
+		return NewBoolOrArrayOfString(names, false)
+	}
+	schema := &Schema{
+		Required:          required("image"),
+		Properties:        map[string]*Schema{"image": {Required: required("tag")}},
+		PatternProperties: map[string]*Schema{"^x-": {Required: required("name")}},
+		Defs:              map[string]*Schema{"port": {Required: required("number")}},
+		Not:               &Schema{Required: required("legacy"), AnyOf: []*Schema{{Required: required("old")}}},
+	}
+	schema.DisableRequiredProperties()
+
+	assert.Equal(t, len(schema.Required.Strings), 0)
+	assert.Equal(t, len(schema.Properties["image"].Required.Strings), 0)
+	assert.Equal(t, len(schema.PatternProperties["^x-"].Required.Strings), 0)
+	assert.Equal(t, len(schema.Defs["port"].Required.Strings), 0)
+	// without their required keys the subschemas of not would reject everything
+	assert.Equal(t, schema.Not.Required.Strings, []string{"legacy"})
+	assert.Equal(t, schema.Not.AnyOf[0].Required.Strings, []string{"old"})
+}
+
+func TestAddMinProperties(t *testing.T) {
+	yamlContent := `nonEmpty:
+  foo: bar
+empty: {}
+# @schema
+# required: false
+# @schema
+optional:
+  foo: bar
+# @schema
+# minProperties: 2
+# @schema
+annotated:
+  foo: bar
+  bar: baz
+nested:
+  inner:
+    foo: bar
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+	schema.AddMinProperties()
+
+	minProperties := func(s *Schema) int {
+		if s.MinProperties == nil {
+			return -1
+		}
+		return *s.MinProperties
+	}
+
+	assert.Equal(t, minProperties(schema.Properties["nonEmpty"]), 1)
+	assert.Equal(t, minProperties(schema.Properties["empty"]), -1)
+	assert.Equal(t, minProperties(schema.Properties["optional"]), -1)
+	assert.Equal(t, minProperties(schema.Properties["annotated"]), 2)
+	assert.Equal(t, minProperties(schema.Properties["nested"].Properties["inner"]), 1)
+}
+
+func TestTimestampDefaults(t *testing.T) {
+	yamlContent := `dateTime: 2001-12-14t21:59:43.10-05:00
+date: 2002-12-14
+# @schema
+# type: string
+# default: "2020-01-01T00:00:00Z"
+# @schema
+annotated: 2001-12-14T21:59:43Z
+quoted: "2001-12-14"
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		skip            []string
+		key             string
+		expectedFormat  string
+		expectedDefault interface{}
+	}{
+		{key: "dateTime", expectedFormat: FormatDateTime, expectedDefault: "2001-12-14T21:59:43.1-05:00"},
+		{key: "date", expectedFormat: FormatDate, expectedDefault: "2002-12-14"},
+		{key: "annotated", expectedFormat: "", expectedDefault: "2020-01-01T00:00:00Z"},
+		{key: "quoted", expectedFormat: "", expectedDefault: "2001-12-14"},
+		{skip: []string{"format"}, key: "dateTime", expectedFormat: "", expectedDefault: "2001-12-14t21:59:43.10-05:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			config, err := NewSkipAutoGenerationConfig(tt.skip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+			prop := schema.Properties[tt.key]
+
+			assert.Equal(t, prop.Type, StringOrArrayOfString{"string"})
+			assert.Equal(t, prop.Format, tt.expectedFormat)
+			assert.Equal(t, prop.Default, tt.expectedDefault)
+		})
+	}
+}
+
+func TestUnknownTags(t *testing.T) {
+	yamlContent := `binary: !!binary R0lGODlhDAAMAIQAAP
+custom: !custom value
+customMap: !custom
+  foo: bar
+list:
+  - !!binary R0lGODlhDAAMAIQAAP
+  - !custom value
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	tests := []struct {
+		key                     string
+		expectedType            StringOrArrayOfString
+		expectedContentEncoding string
+	}{
+		{key: "binary", expectedType: StringOrArrayOfString{"string"}, expectedContentEncoding: "base64"},
+		{key: "custom", expectedType: StringOrArrayOfString{"string"}, expectedContentEncoding: ""},
+		{key: "customMap", expectedType: StringOrArrayOfString{"object"}, expectedContentEncoding: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			prop := schema.Properties[tt.key]
+			assert.Equal(t, prop.Type, tt.expectedType)
+			assert.Equal(t, prop.ContentEncoding, tt.expectedContentEncoding)
+		})
+	}
+
+	items := schema.Properties["list"].Items.AnyOf
+	assert.Equal(t, len(items), 2)
+	assert.Equal(t, items[0].Type, StringOrArrayOfString{"string"})
+	assert.Equal(t, items[0].ContentEncoding, "base64")
+	assert.Equal(t, items[1].Type, StringOrArrayOfString{"string"})
+	assert.Equal(t, items[1].ContentEncoding, "")
+}
+
+func TestSequenceItemsOrder(t *testing.T) {
+	generate := func(yamlContent string) string {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+			t.Fatal(err)
+		}
+		config, err := NewSkipAutoGenerationConfig([]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonStr, err := YamlToSchema("", &node, false, false, false, true, config, nil, nil).ToJson()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(jsonStr)
+	}
+
+	original := generate(`list:
+  - name: a
+  - 1
+  - b
+  - name: c
+  - true
+`)
+	reordered := generate(`list:
+  - true
+  - name: c
+  - b
+  - name: a
+  - 1
+  - b
+`)
+	assert.Equal(t, original, reordered)
+	assert.Equal(t, strings.Count(original, `"type": "string"`), 3)
+}
+
+func TestNestedSequences(t *testing.T) {
+	yamlContent := `matrix:
+  - [1, 2]
+  - [3, 4]
+cube:
+  - - [true]
+mixed:
+  - [a]
+  - b
+  - - key: value
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	// identical rows and items are only added once
+	rows := schema.Properties["matrix"].Items.AnyOf
+	assert.Equal(t, len(rows), 1)
+	assert.Equal(t, rows[0].Type, StringOrArrayOfString{"array"})
+	assert.Equal(t, len(rows[0].Items.AnyOf), 1)
+	assert.Equal(t, rows[0].Items.AnyOf[0].Type, StringOrArrayOfString{"integer"})
+
+	cube := schema.Properties["cube"].Items.AnyOf[0]
+	assert.Equal(t, cube.Type, StringOrArrayOfString{"array"})
+	assert.Equal(t, cube.Items.AnyOf[0].Type, StringOrArrayOfString{"array"})
+	assert.Equal(t, cube.Items.AnyOf[0].Items.AnyOf[0].Type, StringOrArrayOfString{"boolean"})
+
+	// sorted by their json
+	mixed := schema.Properties["mixed"].Items.AnyOf
+	assert.Equal(t, len(mixed), 3)
+	assert.Equal(t, mixed[1].Type, StringOrArrayOfString{"array"})
+	assert.Equal(t, mixed[1].Items.AnyOf[0].Type, StringOrArrayOfString{"string"})
+	assert.Equal(t, mixed[2].Type, StringOrArrayOfString{"string"})
+	nestedObject := mixed[0].Items.AnyOf[0]
+	assert.Equal(t, nestedObject.Type, StringOrArrayOfString{"object"})
+	assert.Equal(t, nestedObject.Properties["key"].Type, StringOrArrayOfString{"string"})
+	assert.Equal(t, nestedObject.AdditionalProperties, new(bool))
+
+	// the items of nested sequences are skipped like the ones of other sequences
+	config, err = NewSkipAutoGenerationConfig([]string{"arrays.items"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema = YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+	assert.Equal(t, schema.Properties["matrix"].Items == nil, true)
+}
+
+func TestComputedValues(t *testing.T) {
+	yamlContent := `# @schema
+# computed: true
+# @schema
+# The checksum of the config, set by the templates
+checksum: ""
+# @schema
+# computed: true
+# x-computed: Set by the operator
+# @schema
+operatorValue: ""
+replicas: 1
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	checksum := schema.Properties["checksum"]
+	assert.Equal(t, checksum.ReadOnly, true)
+	assert.Equal(t, checksum.CustomAnnotations[ComputedAnnotation], computedAnnotationText)
+	assert.Equal(t, checksum.Description, "The checksum of the config, set by the templates")
+	assert.Equal(t, schema.Properties["operatorValue"].CustomAnnotations[ComputedAnnotation], "Set by the operator")
+	assert.Equal(t, schema.Required.Strings, []string{"replicas"})
+
+	jsonStr, err := checksum.ToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(string(jsonStr), `"computed"`), false)
+}
+
+func TestPinnedValues(t *testing.T) {
+	yamlContent := `# @schema
+# pinned: true
+# @schema
+replicas: 3
+# @schema
+# pinned: true
+# type: string
+# @schema
+version: 1.2
+# @schema
+# pinned: true
+# @schema
+clusterDomain: cluster.local
+# @schema
+# pinned: true
+# @schema
+features:
+  - metrics
+  - tracing
+# @schema
+# pinned: true
+# const: other
+# @schema
+mode: default
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	replicas := schema.Properties["replicas"]
+	assert.Equal(t, replicas.Const, 3)
+	assert.Equal(t, replicas.ReadOnly, true)
+	assert.Equal(t, replicas.Type.IsEmpty(), true)
+	assert.Equal(t, schema.Properties["version"].Const, "1.2")
+	assert.Equal(t, schema.Properties["clusterDomain"].Const, "cluster.local")
+	assert.Equal(t, schema.Properties["features"].Const, []interface{}{"metrics", "tracing"})
+	assert.Equal(t, schema.Properties["mode"].Const, "other")
+
+	jsonStr, err := replicas.ToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(string(jsonStr), `"pinned"`), false)
+	assert.Equal(t, strings.Contains(string(jsonStr), `"const": 3`), true)
+}
+
+func TestNullableValues(t *testing.T) {
+	yamlContent := `# @schema
+# nullable: true
+# @schema
+port: 80
+# @schema
+# nullable: true
+# @schema
+name: ~
+# @schema
+# type: string
+# nullable: true
+# @schema
+tag: latest
+# @schema
+# enum: [a, b]
+# nullable: true
+# @schema
+mode: a
+# @schema
+# const: fixed
+# nullable: true
+# @schema
+fixed: fixed
+# @schema
+# $ref: "#/$defs/port"
+# nullable: true
+# @schema
+metricsPort: 9090
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	assert.Equal(t, schema.Properties["port"].Type, StringOrArrayOfString{"integer", "null"})
+	assert.Equal(t, schema.Properties["port"].Default, 80)
+	// the type can't be inferred from null
+	assert.Equal(t, schema.Properties["name"].Type.IsEmpty(), true)
+	assert.Equal(t, schema.Properties["name"].Default, nil)
+	assert.Equal(t, schema.Properties["tag"].Type, StringOrArrayOfString{"string", "null"})
+	assert.Equal(t, schema.Properties["mode"].Enum, []interface{}{"a", "b", nil})
+	assert.Equal(t, schema.Properties["mode"].Type.IsEmpty(), true)
+	assert.Equal(t, schema.Properties["fixed"].Const, nil)
+	assert.Equal(t, schema.Properties["fixed"].Enum, []interface{}{"fixed", nil})
+	assert.Equal(t, schema.Properties["metricsPort"].Ref, "")
+	assert.Equal(t, schema.Properties["metricsPort"].AnyOf, []*Schema{{Ref: "#/$defs/port"}, {Type: StringOrArrayOfString{"null"}}})
+	assert.Equal(t, len(schema.Required.Strings), 0)
+
+	jsonStr, err := schema.Properties["port"].ToJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, strings.Contains(string(jsonStr), `"nullable"`), false)
+}
+
+func TestCommentExamples(t *testing.T) {
+	yamlContent := `# The port
+# @example 8080
+# @example 9090
+port: 80
+# -- The image tag
+# @example 1.20
+# @example latest
+tag: ""
+# @example {team: a}
+labels:
+  app: test
+# @schema
+# type: string
+# @schema
+# @example true
+flag: "yes"
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, helmDocsCompatibilityMode := range []bool{false, true} {
+		schema := YamlToSchema("", &node, false, helmDocsCompatibilityMode, false, true, config, nil, nil)
+
+		assert.Equal(t, schema.Properties["port"].Examples, []interface{}{8080, 9090})
+		assert.Equal(t, schema.Properties["port"].Description, "The port")
+		assert.Equal(t, schema.Properties["tag"].Description, "The image tag")
+		assert.Equal(t, schema.Properties["labels"].Examples, []interface{}{map[string]interface{}{"team": "a"}})
+		assert.Equal(t, schema.Properties["labels"].Description, "")
+		assert.Equal(t, schema.Properties["flag"].Examples, []interface{}{"true"})
+
+		if !helmDocsCompatibilityMode {
+			// the tag is a string, so 1.20 is not parsed as number
+			assert.Equal(t, schema.Properties["tag"].Examples, []interface{}{"1.20", "latest"})
+			// examples don't count as annotation, the keys are still required
+			assert.Equal(t, schema.Required.Strings, []string{"port", "tag", "labels"})
+		}
+	}
+}
+
+func TestRemoveHelmDocsPrefix(t *testing.T) {
+	tests := []struct {
+		description string
+		expected    string
+	}{
+		{description: "-- The value", expected: "The value"},
+		{description: "--", expected: ""},
+		{description: "\n  -- The value", expected: "\nThe value"},
+		{description: "The value", expected: "The value"},
+		{description: "-- The value\n@default -- 1", expected: "The value"},
+		{description: "-- Options:\n---\n-- first\n-- second", expected: "Options:\n---\n-- first\n-- second"},
+		{description: "--set the value with helm", expected: "--set the value with helm"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, removeHelmDocsPrefix(test.description), test.expected)
+	}
+}
+
+func TestHelmDocsExtendedAnnotations(t *testing.T) {
+	yamlContent := `# -- (tpl/object) Extra labels, rendered with tpl
+# @section -- Labels
+extraLabels: |
+  app: {{ .Release.Name }}
+
+# @schema
+# x-section: Custom
+# @schema
+# -- Annotations
+# @notationType -- tpl
+# @section -- Labels
+podAnnotations: ""
+
+# -- The config
+# @raw
+# ` + "```yaml" + `
+# foo:
+#   bar: 1
+# ` + "```" + `
+config: {}
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, true, false, true, config, nil, nil)
+
+	extraLabels := schema.Properties["extraLabels"]
+	assert.Equal(t, extraLabels.Type, StringOrArrayOfString{"string"})
+	assert.Equal(t, extraLabels.CustomAnnotations[SectionAnnotation], "Labels")
+	assert.Equal(t, extraLabels.CustomAnnotations[NotationTypeAnnotation], "tpl")
+
+	podAnnotations := schema.Properties["podAnnotations"]
+	assert.Equal(t, podAnnotations.Type, StringOrArrayOfString{"string"})
+	assert.Equal(t, podAnnotations.CustomAnnotations[SectionAnnotation], "Custom")
+	assert.Equal(t, podAnnotations.CustomAnnotations[NotationTypeAnnotation], "tpl")
+
+	assert.Equal(t, schema.Properties["config"].Description, "The config\n```yaml\nfoo:\n  bar: 1\n```")
+}
+
+func TestHelmDocsComplexDefaults(t *testing.T) {
+	yamlContent := `# -- Node labels
+# @default -- {"kubernetes.io/os": "linux"}
+nodeSelector: {}
+
+# -- (list) Extra args
+# @default -- ` + "`[--verbose, --port=80]`" + `
+args: ~
+
+# -- The name
+# @default -- the name of the release
+name: ""
+
+# -- (string) The image
+# @default -- {"repository": "nginx"}
+image: ~
+
+# -- Broken
+# @default -- {"a": 1
+broken: {}
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := YamlToSchema("", &node, false, true, false, true, config, nil, nil)
+
+	assert.Equal(t, map[string]interface{}{"kubernetes.io/os": "linux"}, schema.Properties["nodeSelector"].Default)
+	assert.Equal(t, []interface{}{"--verbose", "--port=80"}, schema.Properties["args"].Default)
+	assert.Equal(t, "the name of the release", schema.Properties["name"].Default)
+	// the default doesn't match the type
+	assert.Equal(t, `{"repository": "nginx"}`, schema.Properties["image"].Default)
+	assert.Equal(t, `{"a": 1`, schema.Properties["broken"].Default)
+}
+
+func TestBlockScalarDescriptions(t *testing.T) {
+	yamlContent := `# @schema
+# description: |
+#   First line
+#     indented
+#
+#   Third paragraph
+# type: integer
+# @schema
+# -- Ignored comment
+literal: 1
+
+# @schema
+# description: >
+#   folded
+#   text
+#
+#   paragraph
+# @schema
+folded: 2
+
+# @schema
+# type: integer
+# @schema
+# -- Comment
+#   indented
+comment: 3
+
+# @schema
+# type: integer
+# @schema
+# Plain comment
+#   indented
+plain: 4
+`
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(yamlContent), &node); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, helmDocsCompatibilityMode := range []bool{false, true} {
+		schema := YamlToSchema("", &node, false, helmDocsCompatibilityMode, false, true, config, nil, nil)
+
+		// the annotation takes precedence, its line breaks are kept except for the trailing ones
+		assert.Equal(t, schema.Properties["literal"].Description, "First line\n  indented\n\nThird paragraph")
+		assert.Equal(t, schema.Properties["folded"].Description, "folded text\nparagraph")
+		assert.Equal(t, schema.Properties["plain"].Description, "Plain comment\n  indented")
+
+		jsonStr, err := schema.Properties["literal"].ToJson()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, strings.Contains(string(jsonStr), `"description": "First line\n  indented\n\nThird paragraph"`), true)
+	}
+
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+	assert.Equal(t, schema.Properties["comment"].Description, "Comment\n  indented")
+
+	// helm-docs joins the lines of its descriptions with a space
+	schema = YamlToSchema("", &node, false, true, false, true, config, nil, nil)
+	assert.Equal(t, schema.Properties["comment"].Description, "Comment   indented")
+}
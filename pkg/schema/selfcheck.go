@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateValues validates the values (yaml) against the schema. Helm validates with
+// xeipuuv/gojsonschema instead of santhosh-tekuri/jsonschema, so helm can still reject values
+// which are valid here and the other way round, e.g. because of formats (see CheckHelmCompatibility).
+// Relative references to other files are resolved from schemaPath, the location the schema
+// is written to. An empty values file is treated like an empty map.
+// Strings embedding json or yaml are validated against their contentSchema as well and
+// the items of arrays with unique keys (see UniqueKeysAnnotation) must not share them.
+func (s *Schema) ValidateValues(values []byte, schemaPath string) error {
+	validate, err := s.valuesValidator(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	var parsed interface{}
+	if err := yaml.Unmarshal(values, &parsed); err != nil {
+		return fmt.Errorf("could not parse the values: %w", err)
+	}
+	return validate(parsed)
+}
+
+// valuesValidator compiles the schema once and returns a function validating parsed values
+// (see ValidateValues)
+func (s *Schema) valuesValidator(schemaPath string) (func(values interface{}) error, error) {
+	jsonStr, err := s.ToJson()
+	if err != nil {
+		return nil, err
+	}
+	schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(jsonStr))
+	if err != nil {
+		return nil, err
+	}
+
+	schemaURL, err := filepath.Abs(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(schemaURL, schemaDoc); err != nil {
+		return nil, err
+	}
+	compiled, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile the schema: %w", err)
+	}
+	compile := func(pointer string) (*jsonschema.Schema, error) {
+		return c.Compile(schemaURL + "#" + pointer)
+	}
+
+	return func(values interface{}) error {
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+
+		// the validator expects the types of encoding/json (e.g. json.Number instead of int)
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		valuesDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(valuesJSON))
+		if err != nil {
+			return err
+		}
+
+		errs := []error{compiled.Validate(valuesDoc)}
+		s.validateEmbeddedContent(values, "", "", compile, &errs)
+		s.validateUniqueKeyValues(values, nil, &errs)
+		return errors.Join(errs...)
+	}, nil
+}
+
+// validateEmbeddedContent validates the strings of the values which have a contentSchema.
+// The values are followed along the properties and items of the schema, compile returns
+// the compiled subschema at a JSON pointer, so references are resolved like in the schema.
+func (s *Schema) validateEmbeddedContent(value interface{}, path, pointer string, compile func(string) (*jsonschema.Schema, error), errs *[]error) {
+	if s == nil {
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.ContentSchema == nil {
+			return
+		}
+		content, err := parseEmbeddedContent(v, s.ContentMediaType)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: could not parse the embedded %s: %w", path, s.ContentMediaType, err))
+			return
+		}
+		if content == nil {
+			logger.Debugf("Not validating the content of %s, the media type %s is not supported", path, s.ContentMediaType)
+			return
+		}
+		contentSchema, err := compile(pointer + "/contentSchema")
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: could not compile the contentSchema: %w", path, err))
+			return
+		}
+		if err := contentSchema.Validate(*content); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: the embedded %s is invalid: %w", path, s.ContentMediaType, err))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			s.Properties[key].validateEmbeddedContent(v[key], keyPath, pointer+"/properties/"+escapePointerSegment(key), compile, errs)
+		}
+	case []interface{}:
+		for i, item := range v {
+			s.Items.validateEmbeddedContent(item, fmt.Sprintf("%s[%d]", path, i), pointer+"/items", compile, errs)
+		}
+	}
+}
+
+// parseEmbeddedContent parses the json or yaml content of a string into the types expected
+// by the validator. It returns nil for other media types, which can't be validated.
+func parseEmbeddedContent(content, mediaType string) (*interface{}, error) {
+	var parsed interface{}
+	switch mediaType {
+	case MediaTypeJSON:
+		if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+			return nil, err
+		}
+	case MediaTypeYAML, "application/x-yaml", "text/yaml":
+		if err := yaml.Unmarshal([]byte(content), &parsed); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, nil
+	}
+
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func selfCheckSchema(t *testing.T, values string) *Schema {
+	t.Helper()
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(values), &node); err != nil {
+		t.Fatal(err)
+	}
+	skipConfig, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return YamlToSchema("values.yaml", &node, false, false, false, true, skipConfig, nil, nil)
+}
+
+func TestValidateValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values string
+		valid  bool
+	}{
+		{
+			name: "generated schema",
+			values: `replicas: 1
+image:
+  tag: latest
+`,
+			valid: true,
+		},
+		{
+			name: "enum without the default",
+			values: `# @schema
+# enum: [Always, Never]
+# @schema
+pullPolicy: IfNotPresent
+`,
+			valid: false,
+		},
+		{
+			name: "missing null type",
+			values: `# @schema
+# type: string
+# @schema
+nameOverride:
+`,
+			valid: false,
+		},
+		{
+			name: "null type",
+			values: `# @schema
+# type: [string, "null"]
+# @schema
+nameOverride:
+`,
+			valid: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := selfCheckSchema(t, test.values)
+			err := s.ValidateValues([]byte(test.values), "values.schema.json")
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateValuesEmptyFile(t *testing.T) {
+	s := &Schema{Type: []string{"object"}}
+	assert.NoError(t, s.ValidateValues([]byte("# only comments\n"), "values.schema.json"))
+}
+
+func TestValidateValuesRelativeRef(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "port.json"), []byte(`{"type": "integer", "minimum": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Schema{
+		Type:       []string{"object"},
+		Properties: map[string]*Schema{"port": {Ref: "port.json"}},
+	}
+	schemaPath := filepath.Join(dir, "values.schema.json")
+
+	assert.NoError(t, s.ValidateValues([]byte("port: 80\n"), schemaPath))
+	assert.Error(t, s.ValidateValues([]byte("port: 0\n"), schemaPath))
+}
+
+func TestValidateValuesContentSchema(t *testing.T) {
+	schema := `# @schema
+# contentMediaType: application/yaml
+# contentSchema:
+#   type: object
+#   properties:
+#     port:
+#       type: integer
+#   required: [port]
+# @schema
+`
+	tests := []struct {
+		name   string
+		values string
+		valid  bool
+	}{
+		{
+			name:   "valid yaml",
+			values: "config: |\n  port: 8080\n",
+			valid:  true,
+		},
+		{
+			name:   "invalid yaml content",
+			values: "config: |\n  port: http\n",
+			valid:  false,
+		},
+		{
+			name:   "missing required key",
+			values: "config: |\n  host: localhost\n",
+			valid:  false,
+		},
+		{
+			name:   "unparsable yaml",
+			values: "config: \"port: [\"\n",
+			valid:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := schema + test.values
+			s := selfCheckSchema(t, values)
+			assert.NotNil(t, s.Properties["config"].ContentSchema)
+			err := s.ValidateValues([]byte(values), "values.schema.json")
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "config:")
+			}
+		})
+	}
+}
+
+func TestValidateValuesContentSchemaJSONItems(t *testing.T) {
+	s := &Schema{
+		Type: []string{"object"},
+		Properties: map[string]*Schema{
+			"dashboards": {
+				Type: []string{"array"},
+				Items: &Schema{
+					Type:             []string{"string"},
+					ContentMediaType: MediaTypeJSON,
+					ContentSchema:    &Schema{Type: []string{"object"}, Required: BoolOrArrayOfString{Strings: []string{"title"}}},
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, s.ValidateValues([]byte("dashboards:\n  - '{\"title\": \"a\"}'\n"), "values.schema.json"))
+	err := s.ValidateValues([]byte("dashboards:\n  - '{\"title\": \"a\"}'\n  - '{}'\n"), "values.schema.json")
+	assert.ErrorContains(t, err, "dashboards[1]")
+	assert.NotContains(t, err.Error(), "dashboards[0]")
+}
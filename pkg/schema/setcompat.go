@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// helmSetPatterns are the strings helm's --set parsing coerces into the type, e.g. when the value
+// arrives as string through --set-string, a values file of a parent chart or a templated value
+var helmSetPatterns = map[string]string{
+	"boolean": `true|false`,
+	"integer": `[-+]?[0-9]+`,
+	"number":  `[-+]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)(?:[eE][-+]?[0-9]+)?`,
+}
+
+// AllowHelmSetCoercions makes the schema and its subschemas tolerant of the coercions of helm's
+// --set parsing. Booleans, integers and numbers also allow strings which look like them and null
+// is allowed for all typed values and enums, because --set key=null unsets the key. As helm's
+// coalescing removes the keys set to null, they are also removed from the required keys of their
+// parent. Enums and consts get the string form of their boolean and numeric values added.
+func (s *Schema) AllowHelmSetCoercions() {
+	_ = s.Walk(func(path string, v *Schema) error {
+		// the values themselves can't be unset
+		v.allowHelmSetCoercions(path != "")
+		return nil
+	})
+}
+
+func (s *Schema) allowHelmSetCoercions(unsettable bool) {
+	// the properties may be unset, only the keys which aren't values can stay required
+	if len(s.Properties) > 0 && len(s.Required.Strings) > 0 {
+		s.Required.Strings = slices.DeleteFunc(slices.Clone(s.Required.Strings), func(key string) bool {
+			_, ok := s.Properties[key]
+			return ok
+		})
+	}
+
+	if !s.Type.IsEmpty() {
+		patterns := []string{}
+		for _, t := range s.Type {
+			if pattern, ok := helmSetPatterns[t]; ok {
+				patterns = append(patterns, pattern)
+			}
+		}
+
+		// an existing pattern only applies to strings already, which are not changed
+		if len(patterns) > 0 && !slices.Contains(s.Type, "string") {
+			s.Type = append(s.Type, "string")
+			if s.Pattern == "" {
+				s.Pattern = fmt.Sprintf("^(?:%s)$", strings.Join(patterns, "|"))
+			}
+		}
+		if unsettable && !slices.Contains(s.Type, "null") {
+			s.Type = append(s.Type, "null")
+		}
+	}
+
+	if s.Const != nil {
+		if coerced, ok := helmSetString(s.Const); ok {
+			s.Enum = []interface{}{s.Const, coerced}
+			s.constWasSet = false
+			s.Const = nil
+		}
+	}
+
+	for _, value := range s.Enum {
+		if coerced, ok := helmSetString(value); ok && !slices.Contains(s.Enum, coerced) {
+			s.Enum = append(s.Enum, coerced)
+		}
+	}
+	if unsettable && s.Enum != nil && !slices.Contains(s.Enum, nil) {
+		s.Enum = append(s.Enum, nil)
+	}
+}
+
+// helmSetString returns the string which helm's --set parsing coerces into the value,
+// if the value is a boolean or a number
+func helmSetString(value interface{}) (interface{}, bool) {
+	switch value.(type) {
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(value), true
+	}
+	return nil, false
+}
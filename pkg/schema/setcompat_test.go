@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAllowHelmSetCoercions(t *testing.T) {
+	yamlContent := `replicas: 1
+enabled: true
+ratio: 0.5
+name: app
+# @schema
+# type: string
+# pattern: ^v
+# @schema
+version: v1
+# @schema
+# enum: [1, 2, three]
+# @schema
+level: 1
+# @schema
+# const: 8080
+# @schema
+port: 8080
+nested:
+  debug: false
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	schema.AllowHelmSetCoercions()
+
+	replicas := schema.Properties["replicas"]
+	assert.Equal(t, StringOrArrayOfString{"integer", "string", "null"}, replicas.Type)
+	pattern := regexp.MustCompile(replicas.Pattern)
+	assert.True(t, pattern.MatchString("3"))
+	assert.False(t, pattern.MatchString("three"))
+
+	assert.Equal(t, StringOrArrayOfString{"boolean", "string", "null"}, schema.Properties["enabled"].Type)
+	assert.Equal(t, "^(?:true|false)$", schema.Properties["enabled"].Pattern)
+	assert.True(t, regexp.MustCompile(schema.Properties["ratio"].Pattern).MatchString("1.5e3"))
+
+	// strings are left as they are, but can be unset
+	assert.Equal(t, StringOrArrayOfString{"string", "null"}, schema.Properties["name"].Type)
+	assert.Equal(t, "", schema.Properties["name"].Pattern)
+	assert.Equal(t, "^v", schema.Properties["version"].Pattern)
+
+	assert.Equal(t, []interface{}{1, 2, "three", "1", "2", nil}, schema.Properties["level"].Enum)
+	assert.Nil(t, schema.Properties["port"].Const)
+	assert.Equal(t, []interface{}{8080, "8080", nil}, schema.Properties["port"].Enum)
+
+	assert.Equal(t, StringOrArrayOfString{"boolean", "string", "null"}, schema.Properties["nested"].Properties["debug"].Type)
+	assert.Equal(t, StringOrArrayOfString{"object", "null"}, schema.Properties["nested"].Type)
+	assert.NotContains(t, schema.Type, "null")
+}
+
+func TestAllowHelmSetCoercionsUnsetKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	values := "image:\n  repository: nginx\n  tag: latest\nreplicas: 1\n"
+	valuesPath := write("values.yaml", values)
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	schema := YamlToSchema(valuesPath, &node, false, false, false, true, &SkipAutoGenerationConfig{}, nil, nil)
+	schema.Required.Strings = append(schema.Required.Strings, "extra")
+	schema.AllowHelmSetCoercions()
+	assert.Equal(t, []string{"extra"}, schema.Required.Strings)
+	assert.Empty(t, schema.Properties["image"].Required.Strings)
+
+	schema.Required.Strings = nil
+	jsonStr, err := schema.ToJson()
+	assert.NoError(t, err)
+	schemaPath := write("values.schema.json", string(jsonStr))
+
+	// --set replicas=null,image.tag=null removes the keys from the coalesced values
+	overrides := write("overrides.yaml", "replicas: null\nimage:\n  tag: null\n")
+	findings, err := schema.CheckValues(schemaPath, []string{overrides})
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
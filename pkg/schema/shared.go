@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Modes of ShareDefinitions
+const (
+	// SharedDefinitionsRef references the shared definitions in the common schema file
+	SharedDefinitionsRef = "ref"
+	// SharedDefinitionsInline copies the shared definitions into the schemas of the charts,
+	// which keeps them self-contained like helm needs it for packaged charts
+	SharedDefinitionsInline = "inline"
+)
+
+// SharedDefinitionsModes are all modes of ShareDefinitions
+var SharedDefinitionsModes = []string{SharedDefinitionsRef, SharedDefinitionsInline}
+
+// minSharedProperties is the number of properties an object needs to be shared,
+// references to smaller ones don't reduce the duplication
+const minSharedProperties = 2
+
+// sharedCandidate is a subschema, which is identical in several schemas
+type sharedCandidate struct {
+	schema  *Schema
+	name    string
+	schemas map[int]bool
+}
+
+// ShareDefinitions replaces the objects, which are identical in several of the schemas, with
+// references to definitions and returns the common schema with all of these definitions.
+// With the ref mode, the references point to the common schema, commonRefs[i] is the path
+// of its file relative to the file of schemas[i]. With the inline mode, the used definitions
+// are copied into the definitions of each schema and referenced from there.
+// Objects containing references are not shared, their references could break by moving them.
+func ShareDefinitions(schemas []*Schema, commonRefs []string, mode string) (*Schema, error) {
+	if !slices.Contains(SharedDefinitionsModes, mode) {
+		return nil, fmt.Errorf("unsupported mode %s for shared definitions (possible: %s)", mode, strings.Join(SharedDefinitionsModes, ", "))
+	}
+	if mode == SharedDefinitionsRef && len(commonRefs) != len(schemas) {
+		return nil, fmt.Errorf("got %d references to the common schema for %d schemas", len(commonRefs), len(schemas))
+	}
+
+	candidates := map[string]*sharedCandidate{}
+	// the names of existing definitions are not reused, they'd be overwritten in the inline mode
+	usedNames := map[string]bool{}
+	for i, s := range schemas {
+		for name := range s.Definitions {
+			usedNames[name] = true
+		}
+		_ = s.Walk(func(path string, v *Schema) error {
+			key, ok := sharedKey(path, v)
+			if !ok {
+				return nil
+			}
+			if _, ok := candidates[key]; !ok {
+				candidates[key] = &sharedCandidate{schema: v.Clone(), schemas: map[int]bool{}}
+			}
+			candidates[key].schemas[i] = true
+			return nil
+		})
+	}
+
+	common := &Schema{Schema: Draft07URI, Definitions: map[string]*Schema{}}
+	for i, s := range schemas {
+		used := map[string]*Schema{}
+		_ = s.Walk(func(path string, v *Schema) error {
+			key, ok := sharedKey(path, v)
+			if !ok || len(candidates[key].schemas) < 2 {
+				return nil
+			}
+
+			candidate := candidates[key]
+			if candidate.name == "" {
+				candidate.name = uniqueName(definitionName(path), usedNames)
+				usedNames[candidate.name] = true
+				common.Definitions[candidate.name] = candidate.schema
+			}
+			used[candidate.name] = candidate.schema
+
+			ref := "#/definitions/" + escapePointerSegment(candidate.name)
+			if mode == SharedDefinitionsRef {
+				ref = commonRefs[i] + ref
+			}
+			*v = Schema{Ref: ref}
+			return SkipSchema
+		})
+
+		if mode == SharedDefinitionsInline && len(used) > 0 {
+			if s.Definitions == nil {
+				s.Definitions = make(map[string]*Schema)
+			}
+			for name, definition := range used {
+				s.Definitions[name] = definition.Clone()
+			}
+		}
+	}
+
+	return common, nil
+}
+
+// sharedKey returns the json of the subschema, if it can be shared
+func sharedKey(path string, s *Schema) (string, bool) {
+	// the root and the definitions stay where they are
+	if path == "" || strings.Contains(path, "/definitions/") || strings.Contains(path, "/$defs/") {
+		return "", false
+	}
+	if !slices.Contains(s.Type, "object") || len(s.Properties) < minSharedProperties {
+		return "", false
+	}
+
+	hasRef := false
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if v.Ref != "" {
+			hasRef = true
+		}
+		return nil
+	})
+	if hasRef {
+		return "", false
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// definitionName returns the name of the property at the json pointer, or "schema"
+// for subschemas which aren't properties
+func definitionName(path string) string {
+	segments := strings.Split(path, "/")
+	for i := len(segments) - 1; i > 0; i-- {
+		if segments[i-1] == "properties" || segments[i-1] == "patternProperties" {
+			return strings.ReplaceAll(strings.ReplaceAll(segments[i], "~1", "/"), "~0", "~")
+		}
+	}
+	return "schema"
+}
+
+// uniqueName returns the name or the name with the first free number appended to it
+func uniqueName(name string, used map[string]bool) string {
+	if !used[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		if candidate := name + strconv.Itoa(i); !used[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sharedTestSchema returns a chart schema with an image object and the given extra properties
+func sharedTestSchema(tag string, properties map[string]*Schema) *Schema {
+	s := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"image": {
+				Type: StringOrArrayOfString{"object"},
+				Properties: map[string]*Schema{
+					"repository": {Type: StringOrArrayOfString{"string"}},
+					"tag":        {Type: StringOrArrayOfString{"string"}, Default: tag},
+					"pullSecret": {
+						Type: StringOrArrayOfString{"object"},
+						Properties: map[string]*Schema{
+							"name": {Type: StringOrArrayOfString{"string"}},
+							"key":  {Type: StringOrArrayOfString{"string"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	for name, property := range properties {
+		s.Properties[name] = property
+	}
+	return s
+}
+
+func TestShareDefinitionsRef(t *testing.T) {
+	small := &Schema{
+		Type:       StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{"enabled": {Type: StringOrArrayOfString{"boolean"}}},
+	}
+	withRef := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"port": {Ref: "#/definitions/port"},
+			"host": {Type: StringOrArrayOfString{"string"}},
+		},
+	}
+	a := sharedTestSchema("1.0", map[string]*Schema{"metrics": small.Clone(), "service": withRef.Clone()})
+	b := sharedTestSchema("1.0", map[string]*Schema{"metrics": small.Clone(), "service": withRef.Clone()})
+	c := sharedTestSchema("2.0", nil)
+
+	common, err := ShareDefinitions([]*Schema{a, b, c}, []string{"../common.json", "../common.json", "common.json"}, SharedDefinitionsRef)
+	assert.NoError(t, err)
+
+	// the whole image is shared by a and b, only the pull secret is shared by all of them
+	assert.Equal(t, []string{"image", "pullSecret"}, sortedSchemaKeys(common.Definitions))
+	assert.Equal(t, Draft07URI, common.Schema)
+	assert.Equal(t, "../common.json#/definitions/image", a.Properties["image"].Ref)
+	assert.Equal(t, "../common.json#/definitions/image", b.Properties["image"].Ref)
+	assert.Empty(t, c.Properties["image"].Ref)
+	assert.Equal(t, "common.json#/definitions/pullSecret", c.Properties["image"].Properties["pullSecret"].Ref)
+	assert.Equal(t, "2.0", c.Properties["image"].Properties["tag"].Default)
+
+	// the shared definitions are copies of the original objects
+	assert.Equal(t, "1.0", common.Definitions["image"].Properties["tag"].Default)
+	assert.Empty(t, common.Definitions["image"].Properties["pullSecret"].Ref)
+
+	// too small or containing references
+	assert.Empty(t, a.Properties["metrics"].Ref)
+	assert.Empty(t, a.Properties["service"].Ref)
+	assert.Nil(t, a.Definitions)
+}
+
+func TestShareDefinitionsInline(t *testing.T) {
+	a := sharedTestSchema("1.0", nil)
+	a.Definitions = map[string]*Schema{"image": {Type: StringOrArrayOfString{"string"}}}
+	b := sharedTestSchema("1.0", nil)
+
+	common, err := ShareDefinitions([]*Schema{a, b}, nil, SharedDefinitionsInline)
+	assert.NoError(t, err)
+
+	// the name of the existing definition isn't reused
+	assert.Equal(t, []string{"image2"}, sortedSchemaKeys(common.Definitions))
+	for _, s := range []*Schema{a, b} {
+		assert.Equal(t, "#/definitions/image2", s.Properties["image"].Ref)
+		assert.True(t, s.Definitions["image2"].EqualsOpt(common.Definitions["image2"], EqualsFull))
+		assert.NotSame(t, common.Definitions["image2"], s.Definitions["image2"])
+		assert.NoError(t, s.ValidateInternalRefs())
+	}
+	assert.Equal(t, StringOrArrayOfString{"string"}, a.Definitions["image"].Type)
+}
+
+func TestShareDefinitionsErrors(t *testing.T) {
+	_, err := ShareDefinitions([]*Schema{{}}, nil, "copy")
+	assert.ErrorContains(t, err, "unsupported mode")
+	_, err = ShareDefinitions([]*Schema{{}}, nil, SharedDefinitionsRef)
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultSyntheticRefURL is referenced by the synthetic values, if no other url is configured
+const DefaultSyntheticRefURL = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/master/v1.29.0/_definitions.json"
+
+// syntheticGroupSize is the number of leaf keys, which share the same parent
+const syntheticGroupSize = 10
+
+// SyntheticOptions configures the values file created by SyntheticValues
+type SyntheticOptions struct {
+	// Keys is the number of leaf keys
+	Keys int
+	// Depth is the number of maps between the root and the leaf keys
+	Depth int
+	// Refs is the number of leaf keys, which are annotated with a $ref to RefURL
+	Refs int
+	// RefURL is the url of the referenced schema (DefaultSyntheticRefURL if empty)
+	RefURL string
+}
+
+// SyntheticValues creates a values file to measure the generation performance.
+// The leaf keys are grouped below nested maps and use all kinds of values,
+// every fifth key is annotated and the first Refs keys reference RefURL.
+func SyntheticValues(opts SyntheticOptions) []byte {
+	refURL := opts.RefURL
+	if refURL == "" {
+		refURL = DefaultSyntheticRefURL
+	}
+	depth := max(opts.Depth, 1)
+
+	var b strings.Builder
+	for group := 0; group*syntheticGroupSize < opts.Keys; group++ {
+		for level := 0; level < depth; level++ {
+			indent := strings.Repeat("  ", level)
+			if level == 0 {
+				fmt.Fprintf(&b, "%s# -- Group %d\n%sgroup%d:\n", indent, group, indent, group)
+			} else {
+				fmt.Fprintf(&b, "%slevel%d:\n", indent, level)
+			}
+		}
+
+		indent := strings.Repeat("  ", depth)
+		for i := group * syntheticGroupSize; i < min((group+1)*syntheticGroupSize, opts.Keys); i++ {
+			switch {
+			case i < opts.Refs:
+				fmt.Fprintf(&b, "%s# @schema\n%s# $ref: %s#/definitions/io.k8s.api.core.v1.Container\n%s# @schema\n%skey%d: {}\n",
+					indent, indent, refURL, indent, indent, i)
+				continue
+			case i%5 == 0:
+				fmt.Fprintf(&b, "%s# @schema\n%s# description: Key %d\n%s# @schema\n", indent, indent, i, indent)
+			}
+
+			switch i % 4 {
+			case 0:
+				fmt.Fprintf(&b, "%skey%d: value%d\n", indent, i, i)
+			case 1:
+				fmt.Fprintf(&b, "%skey%d: %d\n", indent, i, i)
+			case 2:
+				fmt.Fprintf(&b, "%skey%d: %t\n", indent, i, i%3 == 0)
+			case 3:
+				fmt.Fprintf(&b, "%skey%d:\n%s  - item%d\n", indent, i, indent, i)
+			}
+		}
+	}
+	return []byte(b.String())
+}
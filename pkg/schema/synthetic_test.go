@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSyntheticValues(t *testing.T) {
+	values := SyntheticValues(SyntheticOptions{Keys: 25, Depth: 3, Refs: 2, RefURL: "https://example.com/schema.json"})
+
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal(values, &node))
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	assert.Len(t, schema.Properties, 3)
+	group := schema.Properties["group0"].Properties["level1"].Properties["level2"]
+	assert.Len(t, group.Properties, 10)
+	assert.Len(t, schema.Properties["group2"].Properties["level1"].Properties["level2"].Properties, 5)
+
+	assert.Equal(t, "https://example.com/schema.json#/definitions/io.k8s.api.core.v1.Container", group.Properties["key1"].Ref)
+	assert.Empty(t, group.Properties["key2"].Ref)
+	assert.Equal(t, "Key 5", group.Properties["key5"].Description)
+	assert.Equal(t, StringOrArrayOfString{"integer"}, group.Properties["key9"].Type)
+	assert.Equal(t, StringOrArrayOfString{"array"}, group.Properties["key7"].Type)
+}
+
+func benchmarkYamlToSchema(b *testing.B, opts SyntheticOptions) {
+	values := SyntheticValues(opts)
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(values)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var node yaml.Node
+		if err := yaml.Unmarshal(values, &node); err != nil {
+			b.Fatal(err)
+		}
+		schema := YamlToSchema("", &node, false, false, false, false, config, nil, nil)
+		if _, err := schema.ToJson(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkYamlToSchemaLargeValues(b *testing.B) {
+	benchmarkYamlToSchema(b, SyntheticOptions{Keys: 10000, Depth: 2})
+}
+
+func BenchmarkYamlToSchemaDeepNesting(b *testing.B) {
+	benchmarkYamlToSchema(b, SyntheticOptions{Keys: 1000, Depth: 50})
+}
+
+// BenchmarkGenerationURLRefs resolves the url references of the values with a new fetcher per
+// generation, so each generation downloads the referenced schema from the server
+func BenchmarkGenerationURLRefs(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"definitions": {"io.k8s.api.core.v1.Container": {"type": "object", "properties": {"name": {"type": "string"}, "image": {"type": "string"}}, "required": ["name"]}}}`))
+	}))
+	defer server.Close()
+	defer UseURLFetcher(urlref.NewFetcher())
+
+	values := SyntheticValues(SyntheticOptions{Keys: 2000, Depth: 2, Refs: 2000, RefURL: server.URL + "/definitions.json"})
+	valuesPath := filepath.Join(b.TempDir(), "values.yaml")
+	policy := GenerationPolicy{RefMode: RefModeInlineAll}
+
+	b.SetBytes(int64(len(values)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		UseURLFetcher(urlref.NewFetcher())
+		var node yaml.Node
+		if err := yaml.Unmarshal(values, &node); err != nil {
+			b.Fatal(err)
+		}
+		schema, err := policy.ToSchema(valuesPath, &node)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(schema.UnresolvedRefs()) > 0 {
+			b.Fatalf("Was expecting the url references to be resolved, but got %v", schema.UnresolvedRefs())
+		}
+		if _, err := schema.ToJson(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
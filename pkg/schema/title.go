@@ -0,0 +1,175 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Styles of the generated titles
+const (
+	TitleStyleKey   = "key"
+	TitleStyleHuman = "human"
+)
+
+// TitleConfig defines how the titles, which are generated from the keys, are transformed
+type TitleConfig struct {
+	Style       string
+	IncludePath bool
+	Template    *template.Template
+}
+
+// TitleTemplateData is passed to the title template
+type TitleTemplateData struct {
+	// Key is the raw key, e.g. "pullPolicy"
+	Key string
+	// Path is the dotted path of the key, e.g. "image.pullPolicy"
+	Path string
+	// Parent is the dotted path of the parent, e.g. "image"
+	Parent string
+	// Title is the title after applying the style (and path), e.g. "Image Pull Policy"
+	Title string
+}
+
+// NewTitleConfig creates a TitleConfig. An empty titleTemplate disables the template.
+func NewTitleConfig(style string, includePath bool, titleTemplate string) (*TitleConfig, error) {
+	if style == "" {
+		style = TitleStyleKey
+	}
+	if style != TitleStyleKey && style != TitleStyleHuman {
+		return nil, fmt.Errorf("unsupported title style: %s (possible: %s, %s)", style, TitleStyleKey, TitleStyleHuman)
+	}
+
+	config := &TitleConfig{Style: style, IncludePath: includePath}
+	if titleTemplate != "" {
+		tmpl, err := template.New("title").Parse(titleTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid title template: %w", err)
+		}
+		config.Template = tmpl
+	}
+
+	return config, nil
+}
+
+// Enabled returns true if the titles have to be transformed at all
+func (c *TitleConfig) Enabled() bool {
+	return c != nil && (c.Style == TitleStyleHuman || c.IncludePath || c.Template != nil)
+}
+
+// title returns the transformed title of the key at the given path
+func (c *TitleConfig) title(path []string) (string, error) {
+	key := path[len(path)-1]
+
+	segments := []string{key}
+	if c.IncludePath {
+		segments = path
+	}
+
+	var title string
+	if c.Style == TitleStyleHuman {
+		words := []string{}
+		for _, segment := range segments {
+			words = append(words, HumanizeKey(segment))
+		}
+		title = strings.Join(words, " ")
+	} else {
+		title = strings.Join(segments, ".")
+	}
+
+	if c.Template == nil {
+		return title, nil
+	}
+
+	var buf bytes.Buffer
+	err := c.Template.Execute(&buf, TitleTemplateData{
+		Key:    key,
+		Path:   strings.Join(path, "."),
+		Parent: strings.Join(path[:len(path)-1], "."),
+		Title:  title,
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// HumanizeKey converts a key in camel, snake or kebab case to separate capitalized words,
+// e.g. "fullnameOverride" becomes "Fullname Override". Acronyms like "URL" are kept.
+func HumanizeKey(key string) string {
+	var words []string
+	var current []rune
+
+	runes := []rune(key)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			if len(current) > 0 {
+				words = append(words, string(current))
+				current = nil
+			}
+			continue
+		}
+
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextIsLower {
+				words = append(words, string(current))
+				current = nil
+			}
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+
+	for i, word := range words {
+		wordRunes := []rune(word)
+		wordRunes[0] = unicode.ToUpper(wordRunes[0])
+		words[i] = string(wordRunes)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// TransformTitles transforms all titles, which were generated from their key, as configured.
+// A title is considered generated if it equals its key.
+func (s *Schema) TransformTitles(config *TitleConfig) error {
+	if !config.Enabled() {
+		return nil
+	}
+	return s.transformTitles(config, []string{})
+}
+
+func (s *Schema) transformTitles(config *TitleConfig, path []string) error {
+	if s == nil {
+		return nil
+	}
+
+	for name, v := range s.Properties {
+		propPath := append(append([]string{}, path...), name)
+		if v.Title == name {
+			title, err := config.title(propPath)
+			if err != nil {
+				return fmt.Errorf("could not create title of %s: %w", strings.Join(propPath, "."), err)
+			}
+			v.Title = title
+		}
+		if err := v.transformTitles(config, propPath); err != nil {
+			return err
+		}
+	}
+
+	// keys within list items and compositions use the path of their parent
+	for _, schemas := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, v := range schemas {
+			if err := v.transformTitles(config, path); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Items.transformTitles(config, path)
+}
@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanizeKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{key: "fullnameOverride", expected: "Fullname Override"},
+		{key: "replicas", expected: "Replicas"},
+		{key: "image_pull_secrets", expected: "Image Pull Secrets"},
+		{key: "node-selector", expected: "Node Selector"},
+		{key: "apiURL", expected: "Api URL"},
+		{key: "URLPath", expected: "URL Path"},
+		{key: "TLS", expected: "TLS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.expected, HumanizeKey(tt.key))
+		})
+	}
+}
+
+func TestNewTitleConfig(t *testing.T) {
+	_, err := NewTitleConfig("shouting", false, "")
+	assert.Error(t, err)
+
+	_, err = NewTitleConfig("", false, "{{ .Key")
+	assert.Error(t, err)
+
+	config, err := NewTitleConfig("", false, "")
+	assert.NoError(t, err)
+	assert.False(t, config.Enabled())
+}
+
+func TestTransformTitles(t *testing.T) {
+	tests := []struct {
+		name          string
+		style         string
+		includePath   bool
+		template      string
+		expectedImage string
+		expectedTag   string
+		expectedItem  string
+	}{
+		{
+			name:          "human",
+			style:         TitleStyleHuman,
+			expectedImage: "Image",
+			expectedTag:   "Pull Policy",
+			expectedItem:  "Secret Name",
+		},
+		{
+			name:          "key with path",
+			style:         TitleStyleKey,
+			includePath:   true,
+			expectedImage: "image",
+			expectedTag:   "image.pullPolicy",
+			expectedItem:  "pullSecrets.secretName",
+		},
+		{
+			name:          "human with path",
+			style:         TitleStyleHuman,
+			includePath:   true,
+			expectedImage: "Image",
+			expectedTag:   "Image Pull Policy",
+			expectedItem:  "Pull Secrets Secret Name",
+		},
+		{
+			name:          "template",
+			style:         TitleStyleHuman,
+			template:      "{{ .Title }} ({{ .Path }})",
+			expectedImage: "Image (image)",
+			expectedTag:   "Pull Policy (image.pullPolicy)",
+			expectedItem:  "Secret Name (pullSecrets.secretName)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &Schema{
+				Properties: map[string]*Schema{
+					"image": {
+						Title: "image",
+						Properties: map[string]*Schema{
+							"pullPolicy": {Title: "pullPolicy"},
+							"custom":     {Title: "My custom title"},
+						},
+					},
+					"pullSecrets": {
+						Title: "pullSecrets",
+						Items: &Schema{
+							AnyOf: []*Schema{
+								{Properties: map[string]*Schema{"secretName": {Title: "secretName"}}},
+							},
+						},
+					},
+				},
+			}
+
+			config, err := NewTitleConfig(tt.style, tt.includePath, tt.template)
+			assert.NoError(t, err)
+			assert.NoError(t, schema.TransformTitles(config))
+
+			assert.Equal(t, tt.expectedImage, schema.Properties["image"].Title)
+			assert.Equal(t, tt.expectedTag, schema.Properties["image"].Properties["pullPolicy"].Title)
+			assert.Equal(t, "My custom title", schema.Properties["image"].Properties["custom"].Title)
+			assert.Equal(t, tt.expectedItem, schema.Properties["pullSecrets"].Items.AnyOf[0].Properties["secretName"].Title)
+		})
+	}
+}
@@ -9,10 +9,17 @@ import (
 func TopoSort(results []*Result, allowCircular bool) ([]*Result, error) {
 	// Map chart names to their Result objects for easy lookup
 	chartMap := make(map[string]*Result)
+	// the schemas of the other values files of charts with the separate-schemas strategy
+	secondaryResults := make(map[string][]*Result)
 	for _, r := range results {
-		if r.Chart != nil {
-			chartMap[r.Chart.Name] = r
+		if r.Chart == nil {
+			continue
+		}
+		if r.Secondary {
+			secondaryResults[r.Chart.Name] = append(secondaryResults[r.Chart.Name], r)
+			continue
 		}
+		chartMap[r.Chart.Name] = r
 	}
 
 	// Build dependency graph as adjacency list
@@ -68,6 +75,7 @@ func TopoSort(results []*Result, allowCircular bool) ([]*Result, error) {
 		if result, exists := chartMap[chart]; exists {
 			sorted = append(sorted, result)
 		}
+		sorted = append(sorted, secondaryResults[chart]...)
 
 		// Remove from recursion stack
 		inStack[chart] = false
@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"regexp"
+	"slices"
+)
+
+// RuleTypeWidening is the lint rule reporting inferred types, which are likely narrower
+// than the values users set (see LintTypeWidening)
+const RuleTypeWidening = "type-widening"
+
+// versionKeyRegex matches the keys of values which are versions, e.g. tag or appVersion
+var versionKeyRegex = regexp.MustCompile(`(?i)(tag|version)$`)
+
+// LintTypeWidening reports the properties without annotations, whose inferred type is likely
+// too narrow for the values users set. These are the classic typing traps of helm values:
+//
+//   - versions or tags written as numbers (tag: 1.25), which users set as strings (and 1.10 becomes 1.1)
+//   - empty strings (storageClass: ""), which users unset with null
+//   - null values, which only allow null, because the type of the values users set can't be inferred
+//
+// The issues are warnings with a suggestion of the annotation to use.
+func (s *Schema) LintTypeWidening() []LintIssue {
+	var issues []LintIssue
+	// the properties of several items with the same path are reported once
+	reported := make(map[string]bool)
+
+	var lint func(v *Schema, path []string)
+	lint = func(v *Schema, path []string) {
+		if v == nil {
+			return
+		}
+		names := make([]string, 0, len(v.Properties))
+		for name := range v.Properties {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if len(path) == 0 && name == "global" {
+				continue
+			}
+			property := v.Properties[name]
+			propertyPath := append(slices.Clone(path), name)
+			if message := typeWideningMessage(name, property); message != "" && !reported[dottedPath(propertyPath)] {
+				reported[dottedPath(propertyPath)] = true
+				issues = append(issues, LintIssue{
+					Rule:     RuleTypeWidening,
+					Severity: SeverityWarning,
+					Path:     propertyPath,
+					Message:  message,
+				})
+			}
+			lint(property, propertyPath)
+		}
+		if v.Items != nil {
+			itemsPath := append(slices.Clone(path), itemsSegment)
+			lint(v.Items, itemsPath)
+			for _, variant := range v.Items.AnyOf {
+				lint(variant, itemsPath)
+			}
+		}
+	}
+	lint(s, nil)
+	return issues
+}
+
+// typeWideningMessage returns the suggestion for the generated schema of the key,
+// empty if the type is fine or was annotated
+func typeWideningMessage(key string, s *Schema) string {
+	if s == nil || s.HasData || s.Ref != "" || s.Enum != nil || s.Const != nil {
+		return ""
+	}
+
+	switch {
+	case versionKeyRegex.MatchString(key) && (slices.Contains(s.Type, "number") || slices.Contains(s.Type, "integer")):
+		return "the version is inferred as number, but versions are usually set as strings (and 1.10 becomes 1.1), quote the value or annotate type: string"
+	case slices.Equal(s.Type, StringOrArrayOfString{"string"}) && s.Default == "":
+		return "the default is an empty string, but such values are often unset with null, annotate nullable: true if null is allowed"
+	case slices.Equal(s.Type, StringOrArrayOfString{"null"}):
+		return "the value is null, so null is the only allowed value, annotate the type with nullable: true"
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLintTypeWidening(t *testing.T) {
+	values := `image:
+  repository: nginx
+  tag: 1.25
+appVersion: 2
+storageClass: ""
+existingSecret: ~
+# @schema
+# type: string
+# @schema
+name: ""
+# @schema
+# type: number
+# @schema
+version: 1.0
+replicas: 1
+sidecars:
+  - name: proxy
+    tag: 1.2
+    storageClass: ""
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(values), &node))
+	s, err := GenerationPolicy{}.ToSchema("values.yaml", &node)
+	assert.NoError(t, err)
+
+	var paths []string
+	for _, issue := range s.LintTypeWidening() {
+		assert.Equal(t, RuleTypeWidening, issue.Rule)
+		assert.Equal(t, SeverityWarning, issue.Severity)
+		paths = append(paths, dottedPath(issue.Path))
+	}
+	assert.Equal(t, []string{
+		"appVersion",
+		"existingSecret",
+		"image.tag",
+		"sidecars[].storageClass",
+		"sidecars[].tag",
+		"storageClass",
+	}, paths)
+}
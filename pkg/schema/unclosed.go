@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unclosedBlockError reports the block of the marker (@schema or @schema.root), which was opened
+// in the line of the comment and not closed before its end
+func unclosedBlockError(marker string, line int) error {
+	return &AnnotationLineError{
+		Line: line,
+		Message: fmt.Sprintf(
+			"the %s block opened in this line is not closed before the key (add a line with %s after the annotation or use --fix)",
+			strings.TrimPrefix(marker, "# "),
+			marker,
+		),
+	}
+}
+
+// CloseAnnotationBlocks closes the @schema and @schema.root blocks of the values file, which are
+// trivially unclosed: the comment with the block ends right above a key and the lines after the
+// opening marker start with an annotation. The closing marker is added after the longest part of
+// the lines, which is a mapping of annotation keywords, so the rest of the comment stays the
+// description. It returns the fixed content and the lines (starting with 1) of the opening markers
+// of the closed blocks, which are empty if nothing was fixed.
+func CloseAnnotationBlocks(content []byte) ([]byte, []int) {
+	return closeAnnotationBlocks(content, false)
+}
+
+// closeAnnotationBlocks is CloseAnnotationBlocks, relaxed accepts the relaxed indentation of
+// the annotations (see GenerationPolicy.RelaxedAnnotationIndentation)
+func closeAnnotationBlocks(content []byte, relaxed bool) ([]byte, []int) {
+	eol := "\n"
+	if strings.Contains(string(content), "\r\n") {
+		eol = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+
+	// the closing markers, which are inserted before the lines
+	closers := make(map[int]string)
+	var closed []int
+	opened, marker := -1, ""
+	// closeBlock closes the open block, which ends before the line
+	closeBlock := func(i int) {
+		if end := annotationEnd(lines[opened+1:i], relaxed); end > 0 {
+			indentation := lines[opened][:len(lines[opened])-len(strings.TrimLeft(lines[opened], " \t"))]
+			closers[opened+1+end] = indentation + marker
+			closed = append(closed, opened+1)
+		}
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == SchemaPrefix || trimmed == SchemaRootPrefix {
+			switch {
+			case opened < 0:
+				opened, marker = i, trimmed
+			case trimmed == marker:
+				opened = -1
+			default:
+				// a @schema.root block followed by the @schema block of the first key
+				closeBlock(i)
+				opened, marker = i, trimmed
+			}
+			continue
+		}
+		if opened < 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		// the comment ends before the block is closed, which is fixed if a key follows it
+		if trimmed != "" {
+			closeBlock(i)
+		}
+		opened = -1
+	}
+	if len(closed) == 0 {
+		return content, nil
+	}
+
+	fixed := make([]string, 0, len(lines)+len(closers))
+	for i, line := range lines {
+		if closer, ok := closers[i]; ok {
+			fixed = append(fixed, closer)
+		}
+		fixed = append(fixed, line)
+	}
+	return []byte(strings.Join(fixed, eol)), closed
+}
+
+// annotationEnd returns the number of the leading comment lines, which form an annotation: the
+// longest part which is a mapping of annotation keywords. It returns 0 if there is none.
+func annotationEnd(commentLines []string, relaxed bool) int {
+	keywords := annotationKeywords(reflect.TypeOf(Schema{}))
+	for end := len(commentLines); end > 0; end-- {
+		lines := make([]string, end)
+		for i, line := range commentLines[:end] {
+			content := strings.TrimPrefix(strings.TrimSpace(line), CommentPrefix)
+			lines[i] = strings.TrimPrefix(content, " ")
+		}
+		rawYaml, err := annotationYaml(lines, make([]int, end), relaxed)
+		if err != nil {
+			continue
+		}
+		var annotation map[string]interface{}
+		if err := yaml.Unmarshal(rawYaml, &annotation); err != nil || len(annotation) == 0 {
+			continue
+		}
+		isAnnotation := true
+		for key := range annotation {
+			if _, ok := keywords[key]; !ok && !strings.HasPrefix(key, CustomAnnotationPrefix) {
+				isAnnotation = false
+				break
+			}
+		}
+		if isAnnotation {
+			return end
+		}
+	}
+	return 0
+}
+
+// closeAnnotationBlocksOfFile closes the trivially unclosed blocks of the values file (see
+// CloseAnnotationBlocks) and writes it, if any block was closed
+func closeAnnotationBlocksOfFile(valuesPath string, gen *generation) error {
+	info, err := os.Stat(valuesPath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(valuesPath)
+	if err != nil {
+		return err
+	}
+
+	fixed, closed := closeAnnotationBlocks(content, gen.RelaxedAnnotationIndentation)
+	if len(closed) == 0 {
+		return nil
+	}
+	for _, line := range closed {
+		gen.log().Infof("%s:%d: closing the annotation block opened in this line", valuesPath, line)
+	}
+	return os.WriteFile(valuesPath, fixed, info.Mode().Perm())
+}
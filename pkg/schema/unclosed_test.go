@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnclosedBlockError(t *testing.T) {
+	comment := "# the replicas\n# @schema\n# type: integer\n# minimum: 1"
+	_, _, err := GetSchemaFromComment(comment)
+	var annotationErr *AnnotationLineError
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Equal(t, 2, annotationErr.Line)
+	assert.Contains(t, err.Error(), "@schema block opened in this line is not closed")
+	// the line of the opening marker in the values file
+	assert.Equal(t, 7, annotationErrorLine(err, comment, 10))
+
+	_, _, err = GetRootSchemaFromComment("# @schema.root\n# title: Values")
+	assert.True(t, errors.As(err, &annotationErr))
+	assert.Equal(t, 1, annotationErr.Line)
+	assert.Contains(t, err.Error(), "@schema.root block")
+}
+
+func TestCloseAnnotationBlocks(t *testing.T) {
+	values := `# @schema.root
+# title: Values
+# @schema
+# type: integer
+# minimum: 1
+# The replicas
+replicas: 1
+image:
+  # @schema
+  # type: string
+  # Note: the tag
+  tag: latest
+  # @schema
+  # The pull policy
+  pullPolicy: Always
+  # @schema
+  # type: string
+  # @schema
+  name: nginx
+# @schema
+# type: object
+
+orphan: {}
+`
+	fixed, closed := CloseAnnotationBlocks([]byte(values))
+	assert.Equal(t, []int{1, 3, 9}, closed)
+	assert.Equal(t, `# @schema.root
+# title: Values
+# @schema.root
+# @schema
+# type: integer
+# minimum: 1
+# @schema
+# The replicas
+replicas: 1
+image:
+  # @schema
+  # type: string
+  # @schema
+  # Note: the tag
+  tag: latest
+  # @schema
+  # The pull policy
+  pullPolicy: Always
+  # @schema
+  # type: string
+  # @schema
+  name: nginx
+# @schema
+# type: object
+
+orphan: {}
+`, string(fixed))
+
+	// the closed blocks are parsed
+	schema, description, err := GetSchemaFromComment("# @schema\n# type: integer\n# minimum: 1\n# @schema\n# The replicas")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *schema.Minimum)
+	assert.Equal(t, "The replicas", description)
+
+	fixed, closed = CloseAnnotationBlocks([]byte("# @schema\r\n# type: string\r\nkey: value\r\n"))
+	assert.Equal(t, []int{1}, closed)
+	assert.Equal(t, "# @schema\r\n# type: string\r\n# @schema\r\nkey: value\r\n", string(fixed))
+
+	unchanged := []byte("# @schema\n# type: string\n# @schema\nkey: value\n")
+	fixed, closed = CloseAnnotationBlocks(unchanged)
+	assert.Empty(t, closed)
+	assert.Equal(t, unchanged, fixed)
+}
+
+func TestReadValuesFix(t *testing.T) {
+	valuesPath := filepath.Join(t.TempDir(), "values.yaml")
+	assert.NoError(t, os.WriteFile(valuesPath, []byte("# @schema\n# type: string\nkey: value\n"), 0o600))
+
+	_, _, err := readValues(valuesPath, false, "values.schema.json", testGeneration(t, GenerationPolicy{Fix: true}))
+	assert.NoError(t, err)
+	content, err := os.ReadFile(valuesPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "# @schema\n# type: string\n# @schema\nkey: value\n", string(content))
+	info, err := os.Stat(valuesPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
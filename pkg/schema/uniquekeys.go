@@ -0,0 +1,131 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// UniqueKeysAnnotation contains the keys of the items of an array, which must be unique
+// across the items (`uniqueKeys: [name]`). JSON schema can't express this, so the array gets
+// uniqueItems instead and ValidateValues checks the keys.
+const UniqueKeysAnnotation = CustomAnnotationPrefix + "unique-keys"
+
+// applyUniqueKeys adds the closest JSON schema keyword and the annotation for the uniqueKeys
+// of the schema and its subschemas
+func (s *Schema) applyUniqueKeys() {
+	_ = s.Walk(func(_ string, v *Schema) error {
+		if len(v.UniqueKeys) > 0 {
+			// identical items have identical keys as well
+			v.UniqueItems = true
+			v.setCustomAnnotationIfMissing(UniqueKeysAnnotation, slices.Clone(v.UniqueKeys))
+		}
+		return nil
+	})
+}
+
+func (s Schema) validateUniqueKeys(profile ValidationProfile) error {
+	if len(s.UniqueKeys) == 0 {
+		return nil
+	}
+
+	if err := s.validateConstraintTypes(profile, "uniqueKeys", "array"); err != nil {
+		return err
+	}
+
+	for i, key := range s.UniqueKeys {
+		if key == "" {
+			return errors.New("uniqueKeys cannot contain empty keys")
+		}
+		if slices.Contains(s.UniqueKeys[:i], key) {
+			return fmt.Errorf("uniqueKeys contains %s twice", key)
+		}
+	}
+
+	return nil
+}
+
+// uniqueKeys returns the keys of UniqueKeysAnnotation, which are read from the schema file
+// as list of interface{}
+func (s *Schema) uniqueKeys() []string {
+	switch keys := s.CustomAnnotations[UniqueKeysAnnotation].(type) {
+	case []string:
+		return keys
+	case []interface{}:
+		names := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if name, ok := key.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// validateUniqueKeyValues checks that the items of the arrays of the values don't share the
+// values of the unique keys (see UniqueKeysAnnotation). The values are followed along the
+// properties and items of the schema. Items without any of the keys are ignored.
+func (s *Schema) validateUniqueKeyValues(value interface{}, path []string, errs *[]error) {
+	if s == nil {
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			s.Properties[key].validateUniqueKeyValues(v[key], append(slices.Clone(path), key), errs)
+		}
+	case []interface{}:
+		if uniqueKeys := s.uniqueKeys(); len(uniqueKeys) > 0 {
+			seen := make(map[string]int)
+			for i, item := range v {
+				fields, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				id, ok := uniqueKeyValues(fields, uniqueKeys)
+				if !ok {
+					continue
+				}
+				if first, ok := seen[id]; ok {
+					*errs = append(*errs, ValueError{
+						Path:    append(slices.Clone(path), strconv.Itoa(i)),
+						Message: fmt.Sprintf("%s must be unique, but item %d has the same value (%s)", strings.Join(uniqueKeys, ", "), first, id),
+					})
+					continue
+				}
+				seen[id] = i
+			}
+		}
+		for i, item := range v {
+			s.Items.validateUniqueKeyValues(item, append(slices.Clone(path), strconv.Itoa(i)), errs)
+		}
+	}
+}
+
+// uniqueKeyValues returns the values of the keys of the item as json, false if the item has none of the keys
+func uniqueKeyValues(item map[string]interface{}, keys []string) (string, bool) {
+	values := make([]string, len(keys))
+	found := false
+	for i, key := range keys {
+		value, ok := item[key]
+		if ok {
+			found = true
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			data = []byte(fmt.Sprint(value))
+		}
+		values[i] = string(data)
+	}
+	return strings.Join(values, ", "), found
+}
@@ -0,0 +1,110 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueKeys(t *testing.T) {
+	s := selfCheckSchema(t, `# @schema
+# uniqueKeys: [name]
+# @schema
+containers:
+  - name: app
+    image: nginx
+  - name: sidecar
+    image: envoy
+# @schema
+# uniqueKeys: [containerPort, protocol]
+# @schema
+ports:
+  - containerPort: 80
+    protocol: TCP
+`)
+
+	containers := s.Properties["containers"]
+	assert.True(t, containers.UniqueItems)
+	assert.Equal(t, []string{"name"}, containers.CustomAnnotations[UniqueKeysAnnotation])
+	assert.NoError(t, containers.Validate())
+
+	jsonStr, err := s.ToJson()
+	assert.NoError(t, err)
+	assert.Contains(t, string(jsonStr), `"x-unique-keys"`)
+	assert.NotContains(t, string(jsonStr), `"uniqueKeys"`)
+
+	assert.NoError(t, s.ValidateValues([]byte(`containers:
+  - name: app
+    image: nginx
+  - name: sidecar
+    image: nginx
+ports:
+  - containerPort: 80
+    protocol: TCP
+  - containerPort: 80
+    protocol: UDP
+`), "values.schema.json"))
+
+	err = s.ValidateValues([]byte(`containers:
+  - name: app
+    image: nginx
+  - name: app
+    image: envoy
+ports:
+  - containerPort: 80
+    protocol: TCP
+  - containerPort: 80
+    protocol: TCP
+`), "values.schema.json")
+	assert.Error(t, err)
+	valueErrors := ValueErrors(err)
+	paths := [][]string{}
+	for _, valueErr := range valueErrors {
+		paths = append(paths, valueErr.Path)
+	}
+	assert.Contains(t, paths, []string{"containers", "1"})
+	// combinations of the keys must be unique
+	assert.Contains(t, paths, []string{"ports", "1"})
+
+	// the keys are read from the annotation of schema files
+	schemaPath := filepath.Join(t.TempDir(), "values.schema.json")
+	assert.NoError(t, os.WriteFile(schemaPath, jsonStr, 0o644))
+	parsed, err := ReadSchemaFile(schemaPath)
+	assert.NoError(t, err)
+	err = parsed.ValidateValues([]byte(`containers:
+  - name: app
+    image: nginx
+  - name: app
+    image: envoy
+ports:
+  - containerPort: 80
+    protocol: TCP
+`), schemaPath)
+	assert.Equal(t, []ValueError{{Path: []string{"containers", "1"}, Message: `name must be unique, but item 0 has the same value ("app")`}}, ValueErrors(err))
+}
+
+func TestValidateUniqueKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		valid  bool
+	}{
+		{name: "array", schema: Schema{Type: StringOrArrayOfString{"array"}, UniqueKeys: []string{"name"}}, valid: true},
+		{name: "object", schema: Schema{Type: StringOrArrayOfString{"object"}, UniqueKeys: []string{"name"}}, valid: false},
+		{name: "empty key", schema: Schema{Type: StringOrArrayOfString{"array"}, UniqueKeys: []string{""}}, valid: false},
+		{name: "duplicate key", schema: Schema{Type: StringOrArrayOfString{"array"}, UniqueKeys: []string{"name", "name"}}, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// RenamedFromAnnotation maps the enum values of a schema to their previous values, e.g.
+// {"Recreate": ["recreate"]}, so values of previous versions can be upgraded (see UpgradeValues)
+const RenamedFromAnnotation = CustomAnnotationPrefix + "renamed-from"
+
+// UpgradeIssue is a value, which was valid for the previous schema, but isn't valid for the new one
+type UpgradeIssue struct {
+	// Path is the dotted path of the value, items of lists are addressed by their index
+	Path string
+	// Message describes the issue
+	Message string
+	// Converted is set if the value was upgraded
+	Converted bool
+}
+
+func (i UpgradeIssue) String() string {
+	path := i.Path
+	if path == "" {
+		path = "root"
+	}
+	return fmt.Sprintf("%s: %s", path, i.Message)
+}
+
+// UpgradeValues checks the values of the editor (e.g. the overrides of a user), which were written for
+// the previous schema, against the schema. Renamed keys (x-renamed-to) and renamed string enum values
+// (x-renamed-from) are converted in the editor. Keys which were removed and enum values which aren't
+// allowed anymore are reported, they have to be changed by hand.
+func (s *Schema) UpgradeValues(previous *Schema, editor *util.YamlEditor) []UpgradeIssue {
+	u := valuesUpgrader{root: s, previousRoot: previous, editor: editor}
+	u.upgrade(nil, editor.Root(), s, previous)
+	return u.issues
+}
+
+// valuesUpgrader collects the issues while walking the values and both schemas in parallel
+type valuesUpgrader struct {
+	root, previousRoot *Schema
+	editor             *util.YamlEditor
+	issues             []UpgradeIssue
+}
+
+func (u *valuesUpgrader) report(path []string, converted bool, format string, args ...interface{}) {
+	u.issues = append(u.issues, UpgradeIssue{
+		Path:      strings.Join(path, "."),
+		Message:   fmt.Sprintf(format, args...),
+		Converted: converted,
+	})
+}
+
+func (u *valuesUpgrader) upgrade(path []string, node *yaml.Node, current, previous *Schema) {
+	if current == nil || node.Kind == yaml.AliasNode {
+		return
+	}
+	current = u.root.followRef(current)
+	if previous != nil && u.previousRoot != nil {
+		previous = u.previousRoot.followRef(previous)
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		u.upgradeMapping(path, node, current, previous)
+	case yaml.SequenceNode:
+		var previousItems *Schema
+		if previous != nil {
+			previousItems = previous.Items
+		}
+		for i, item := range node.Content {
+			u.upgrade(append(slices.Clone(path), strconv.Itoa(i)), item, current.Items, previousItems)
+		}
+	case yaml.ScalarNode:
+		u.upgradeEnumValue(path, node, current, previous)
+	}
+}
+
+// upgradeMapping renames the keys, which were renamed, and reports the keys, which are not allowed anymore
+func (u *valuesUpgrader) upgradeMapping(path []string, node *yaml.Node, current, previous *Schema) {
+	keys := make(map[string]bool, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys[node.Content[i].Value] = true
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		keyPath := append(slices.Clone(path), name)
+
+		var previousProperty *Schema
+		if previous != nil && u.previousRoot != nil {
+			previousProperty = u.previousRoot.findProperty(previous, name, map[*Schema]bool{})
+		}
+
+		property := u.root.findProperty(current, name, map[*Schema]bool{})
+		if renamedTo, ok := renamedTo(property); ok {
+			if keys[renamedTo] {
+				u.report(keyPath, false, "the key was renamed to %s, which is set as well", renamedTo)
+			} else if err := u.editor.RenameKey(keyPath, renamedTo); err != nil {
+				u.report(keyPath, false, "the key was renamed to %s, but could not be renamed: %s", renamedTo, err)
+			} else {
+				u.report(keyPath, true, "renamed the key to %s", renamedTo)
+				property = u.root.findProperty(current, renamedTo, map[*Schema]bool{})
+			}
+		}
+
+		if property == nil {
+			for _, pattern := range sortedSchemaKeys(current.PatternProperties) {
+				if matched, err := regexp.MatchString(pattern, name); err == nil && matched {
+					property = current.PatternProperties[pattern]
+					break
+				}
+			}
+		}
+		if property == nil {
+			if additional, ok := current.AdditionalProperties.(Schema); ok {
+				property = &additional
+			} else if isClosed(current.AdditionalProperties) {
+				if previousProperty != nil {
+					u.report(keyPath, false, "the key was removed")
+				} else {
+					u.report(keyPath, false, "the key is not allowed")
+				}
+				continue
+			}
+		}
+
+		u.upgrade(keyPath, node.Content[i+1], property, previousProperty)
+	}
+}
+
+// upgradeEnumValue replaces a renamed enum value with its new value and reports values,
+// which are not in the enum anymore
+func (u *valuesUpgrader) upgradeEnumValue(path []string, node *yaml.Node, current, previous *Schema) {
+	if len(current.Enum) == 0 {
+		return
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil || containsValue(current.Enum, value) {
+		return
+	}
+
+	if newValue, ok := renamedEnumValue(current, value); ok {
+		if err := u.editor.SetScalar(path, newValue); err != nil {
+			u.report(path, false, "the value %s was renamed to %s, but could not be replaced: %s", jsonString(value), newValue, err)
+		} else {
+			u.report(path, true, "replaced the value %s with %s", jsonString(value), newValue)
+		}
+		return
+	}
+
+	if previous != nil && containsValue(previous.Enum, value) {
+		u.report(path, false, "the value %s was removed, allowed values are %s", jsonString(value), jsonString(current.Enum))
+	} else {
+		u.report(path, false, "the value %s is not allowed, allowed values are %s", jsonString(value), jsonString(current.Enum))
+	}
+}
+
+// renamedTo returns the new name of a key, which is only accepted for compatibility (see addRenamedKeys)
+func renamedTo(property *Schema) (string, bool) {
+	if property == nil {
+		return "", false
+	}
+	name, ok := property.CustomAnnotations[RenamedToAnnotation].(string)
+	return name, ok && name != ""
+}
+
+// renamedEnumValue returns the enum value, which replaces the given previous value (see RenamedFromAnnotation)
+func renamedEnumValue(s *Schema, value interface{}) (string, bool) {
+	previousValue, ok := value.(string)
+	if !ok {
+		return "", false
+	}
+	renamed, ok := s.CustomAnnotations[RenamedFromAnnotation].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	newValues := make([]string, 0, len(renamed))
+	for newValue := range renamed {
+		newValues = append(newValues, newValue)
+	}
+	slices.Sort(newValues)
+
+	for _, newValue := range newValues {
+		if !containsValue(s.Enum, newValue) {
+			continue
+		}
+		switch previousValues := renamed[newValue].(type) {
+		case string:
+			if previousValues == previousValue {
+				return newValue, true
+			}
+		case []interface{}:
+			if slices.Contains(previousValues, interface{}(previousValue)) {
+				return newValue, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeValues(t *testing.T) {
+	previous := selfCheckSchema(t, `replicas: 1
+legacy: false
+# @schema
+# enum: [recreate, rolling, blue-green]
+# @schema
+strategy: rolling
+`)
+	current := selfCheckSchema(t, `# @schema
+# renamedFrom: [replicas]
+# @schema
+replicaCount: 1
+# @schema
+# enum: [Recreate, RollingUpdate]
+# x-renamed-from:
+#   Recreate: recreate
+#   RollingUpdate: [rolling, rolling-update]
+# @schema
+strategy: RollingUpdate
+`)
+
+	editor, err := util.NewYamlEditor([]byte(`# my overrides
+replicas: 3 # more
+legacy: true
+strategy: rolling
+unknown: 1
+`))
+	assert.NoError(t, err)
+
+	issues := current.UpgradeValues(previous, editor)
+	assert.Equal(t, []UpgradeIssue{
+		{Path: "replicas", Message: "renamed the key to replicaCount", Converted: true},
+		{Path: "legacy", Message: "the key was removed"},
+		{Path: "strategy", Message: `replaced the value "rolling" with RollingUpdate`, Converted: true},
+		{Path: "unknown", Message: "the key is not allowed"},
+	}, issues)
+
+	upgraded, err := editor.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, `# my overrides
+replicaCount: 3 # more
+legacy: true
+strategy: RollingUpdate
+unknown: 1
+`, string(upgraded))
+
+	editor, err = util.NewYamlEditor([]byte(`strategy: blue-green
+replicas: 2
+replicaCount: 2
+`))
+	assert.NoError(t, err)
+	issues = current.UpgradeValues(previous, editor)
+	assert.Equal(t, []UpgradeIssue{
+		{Path: "strategy", Message: `the value "blue-green" was removed, allowed values are ["Recreate","RollingUpdate"]`},
+		{Path: "replicas", Message: "the key was renamed to replicaCount, which is set as well"},
+	}, issues)
+}
+
+func TestUpgradeValuesItems(t *testing.T) {
+	previous := &Schema{
+		Type: StringOrArrayOfString{"object"},
+		Properties: map[string]*Schema{
+			"hosts": {
+				Type: StringOrArrayOfString{"array"},
+				Items: &Schema{
+					Type:       StringOrArrayOfString{"object"},
+					Properties: map[string]*Schema{"scheme": {Enum: []interface{}{"http", "https"}}},
+				},
+			},
+		},
+	}
+	current := previous.Clone()
+	current.Properties["hosts"].Items.Properties["scheme"].Enum = []interface{}{"https"}
+
+	editor, err := util.NewYamlEditor([]byte("hosts:\n  - scheme: https\n  - scheme: http\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []UpgradeIssue{
+		{Path: "hosts.1.scheme", Message: `the value "http" was removed, allowed values are ["https"]`},
+	}, current.UpgradeValues(previous, editor))
+}
@@ -0,0 +1,306 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Strategies to generate the schema of a chart with several values files
+const (
+	// ValuesStrategyMerge merges the values files in order like helm does with multiple
+	// --values flags and generates one schema from the result (default)
+	ValuesStrategyMerge = "merge"
+	// ValuesStrategySeparateSchemas generates a schema per values file, the schemas of all but
+	// the first values file are written next to the schema file and named after their values file
+	ValuesStrategySeparateSchemas = "separate-schemas"
+	// ValuesStrategyUnion generates one schema, which accepts the values of every values file
+	ValuesStrategyUnion = "union"
+)
+
+// ValuesStrategies returns the names of all strategies for charts with several values files
+func ValuesStrategies() []string {
+	return []string{ValuesStrategyMerge, ValuesStrategySeparateSchemas, ValuesStrategyUnion}
+}
+
+// ValuesFilesConfig configures the values files of charts, which don't only use
+// one of the default value files (e.g. values-*.yaml or linux-values.yaml)
+type ValuesFilesConfig struct {
+	// Chart is the name of the chart, an empty name or "*" matches all charts
+	Chart string `yaml:"chart" mapstructure:"chart"`
+	// Files are glob patterns (see filepath.Match) relative to the chart directory,
+	// the matches are used in the order of the patterns
+	Files []string `yaml:"files" mapstructure:"files"`
+	// Strategy is one of ValuesStrategies (ValuesStrategyMerge if empty)
+	Strategy string `yaml:"strategy" mapstructure:"strategy"`
+}
+
+// validateValuesFiles checks the configs of GenerationPolicy.ValuesFiles and sets the default strategy
+func validateValuesFiles(configs []ValuesFilesConfig) ([]ValuesFilesConfig, error) {
+	validated := make([]ValuesFilesConfig, 0, len(configs))
+	for _, config := range configs {
+		if config.Strategy == "" {
+			config.Strategy = ValuesStrategyMerge
+		}
+		if !slices.Contains(ValuesStrategies(), config.Strategy) {
+			return nil, fmt.Errorf("invalid values files strategy %q of chart %q, must be one of (%s)", config.Strategy, config.Chart, strings.Join(ValuesStrategies(), ", "))
+		}
+		if len(config.Files) == 0 {
+			return nil, fmt.Errorf("no values files configured for chart %q", config.Chart)
+		}
+		for _, pattern := range config.Files {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid values file pattern %q of chart %q: %w", pattern, config.Chart, err)
+			}
+		}
+		validated = append(validated, config)
+	}
+	return validated, nil
+}
+
+// valuesFilesConfigFor returns the first config of the ValuesFiles matching the chart name or nil
+func (g *generation) valuesFilesConfigFor(chartName string) *ValuesFilesConfig {
+	for i, config := range g.ValuesFiles {
+		if config.Chart == "" || config.Chart == "*" || config.Chart == chartName {
+			return &g.ValuesFiles[i]
+		}
+	}
+	return nil
+}
+
+// findValuesFiles returns the paths of all values files of the chart matching the patterns
+func findValuesFiles(chartBasePath string, patterns []string) ([]string, []error) {
+	valuesPaths := []string{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(chartBasePath, pattern))
+		if err != nil {
+			return nil, []error{err}
+		}
+		slices.Sort(matches)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() || slices.Contains(valuesPaths, match) {
+				continue
+			}
+			valuesPaths = append(valuesPaths, match)
+		}
+	}
+
+	if len(valuesPaths) == 0 {
+		return nil, []error{errNoValuesFile}
+	}
+	return valuesPaths, nil
+}
+
+// SeparateSchemaName returns the name of the schema file of an additional values
+// file with the separate-schemas strategy, e.g. values-prod.schema.json
+func SeparateSchemaName(valuesPath string) string {
+	name := filepath.Base(valuesPath)
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".schema.json"
+}
+
+// mergeValuesDocuments merges the parsed values files in order, empty documents are skipped
+func mergeValuesDocuments(documents []*yaml.Node) *yaml.Node {
+	var merged *yaml.Node
+	for _, document := range documents {
+		if isEmptyDocument(document) {
+			continue
+		}
+		if merged == nil {
+			merged = document
+			continue
+		}
+		mergeValuesNodes(merged.Content[0], document.Content[0])
+	}
+
+	if merged == nil {
+		return documents[0]
+	}
+	return merged
+}
+
+// mergeValuesNodes merges override into base like helm merges values files: maps are merged
+// recursively, all other values replace the ones of base. Keys without a comment keep the
+// comment (and annotation) of the replaced key.
+func mergeValuesNodes(base, override *yaml.Node) {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		*base = *override
+		return
+	}
+
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+
+		index := -1
+		for j := 0; j+1 < len(base.Content); j += 2 {
+			if base.Content[j].Value == key.Value {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			base.Content = append(base.Content, key, value)
+			continue
+		}
+
+		baseKey, baseValue := base.Content[index], base.Content[index+1]
+		if baseValue.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			if key.HeadComment != "" {
+				baseKey.HeadComment = key.HeadComment
+			}
+			mergeValuesNodes(baseValue, value)
+			continue
+		}
+		if key.HeadComment == "" {
+			key.HeadComment = baseKey.HeadComment
+		}
+		base.Content[index], base.Content[index+1] = key, value
+	}
+}
+
+// unionSchemas extends schema, so it also accepts the values other was generated from.
+// Properties of both are kept, only properties required by both stay required and
+// differing types are combined. Other keywords are kept from schema.
+func unionSchemas(schema, other *Schema) {
+	if schema == nil || other == nil {
+		return
+	}
+	wasArray := slices.Contains(schema.Type, "array")
+
+	if len(schema.Type) > 0 && len(other.Type) > 0 {
+		for _, t := range other.Type {
+			if !slices.Contains(schema.Type, t) {
+				schema.Type = append(schema.Type, t)
+			}
+		}
+	} else {
+		schema.Type = nil
+	}
+
+	for name, property := range other.Properties {
+		if existing, ok := schema.Properties[name]; ok {
+			unionSchemas(existing, property)
+			continue
+		}
+		if schema.Properties == nil {
+			schema.Properties = make(map[string]*Schema)
+		}
+		schema.Properties[name] = property.Clone()
+	}
+
+	required := []string{}
+	for _, name := range schema.Required.Strings {
+		if slices.Contains(other.Required.Strings, name) {
+			required = append(required, name)
+		}
+	}
+	schema.Required.Strings = required
+
+	if schema.Items != nil && other.Items != nil {
+		unionSchemas(schema.Items, other.Items)
+	} else if schema.Items == nil && !wasArray && len(schema.Type) > 0 {
+		// the items of an array without items are unrestricted already
+		schema.Items = other.Items.Clone()
+	}
+}
+
+// ValuesDocuments returns the values the schema of the result must accept: the merged values
+// files with the merge strategy, every values file with the union strategy and otherwise the
+// only values file. Charts without a values file have no documents.
+func (r *Result) ValuesDocuments() ([][]byte, error) {
+	if r.ValuesPath == "" {
+		return nil, nil
+	}
+	if len(r.ValuesFiles) == 0 {
+		content, err := os.ReadFile(r.ValuesPath)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{content}, nil
+	}
+
+	documents := [][]byte{}
+	nodes := []*yaml.Node{}
+	for _, path := range r.ValuesFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if r.ValuesStrategy != ValuesStrategyMerge {
+			documents = append(documents, content)
+			continue
+		}
+
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		nodes = append(nodes, &node)
+	}
+	if r.ValuesStrategy != ValuesStrategyMerge {
+		return documents, nil
+	}
+
+	merged := mergeValuesDocuments(nodes)
+	if isEmptyDocument(merged) {
+		return [][]byte{{}}, nil
+	}
+	content, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{content}, nil
+}
+
+// readValues reads and parses the values file and returns it with the content it was parsed from.
+// If the policy of the generation fixes the values files, its unclosed @schema blocks are closed.
+// If addSchemaReference is set, a modeline for the yaml language server referencing schemaName is
+// added to the file.
+func readValues(valuesPath string, addSchemaReference bool, schemaName string, gen *generation) (*yaml.Node, []byte, error) {
+	if gen.Fix {
+		if err := closeAnnotationBlocksOfFile(valuesPath, gen); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	valuesFile, err := os.Open(valuesPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer valuesFile.Close()
+
+	content, err := util.ReadFileAndFixNewline(valuesFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Check if we need to add a schema reference
+	if addSchemaReference {
+		schemaRef := `# yaml-language-server: $schema=` + schemaName
+		if !strings.Contains(string(content), schemaRef) {
+			if err := util.PrefixFirstYamlDocument(schemaRef, valuesPath); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// Optional preprocessing
+	if gen.Uncomment {
+		// Remove comments from valid yaml
+		content, err = util.RemoveCommentsFromYaml(bytes.NewReader(content))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var values yaml.Node
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, nil, err
+	}
+	return &values, content, nil
+}
@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/chart"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuesFilesPolicy(t *testing.T) {
+	gen := testGeneration(t, GenerationPolicy{ValuesFiles: []ValuesFilesConfig{{Chart: "app", Files: []string{"values*.yaml"}}}})
+	assert.Equal(t, ValuesStrategyMerge, gen.valuesFilesConfigFor("app").Strategy)
+	assert.Nil(t, gen.valuesFilesConfigFor("other"))
+
+	gen = testGeneration(t, GenerationPolicy{ValuesFiles: []ValuesFilesConfig{
+		{Chart: "app", Files: []string{"values.yaml"}, Strategy: ValuesStrategyUnion},
+		{Chart: "*", Files: []string{"values.yaml"}, Strategy: ValuesStrategySeparateSchemas},
+	}})
+	assert.Equal(t, ValuesStrategyUnion, gen.valuesFilesConfigFor("app").Strategy)
+	assert.Equal(t, ValuesStrategySeparateSchemas, gen.valuesFilesConfigFor("other").Strategy)
+
+	assert.Error(t, GenerationPolicy{ValuesFiles: []ValuesFilesConfig{{Files: []string{"values.yaml"}, Strategy: "concat"}}}.Validate())
+	assert.Error(t, GenerationPolicy{ValuesFiles: []ValuesFilesConfig{{Chart: "app"}}}.Validate())
+	assert.Error(t, GenerationPolicy{ValuesFiles: []ValuesFilesConfig{{Files: []string{"values-[.yaml"}}}}.Validate())
+}
+
+// valuesFilesChart creates a chart with the given values files and returns it
+func valuesFilesChart(t *testing.T, files map[string]string) chart.Chart {
+	t.Helper()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\nname: app\nversion: 1.0.0\n"), 0o644))
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	c, err := chart.LoadChart(filepath.Join(dir, "Chart.yaml"))
+	assert.NoError(t, err)
+	return c
+}
+
+func TestGenerateChartResultsValuesStrategies(t *testing.T) {
+	files := map[string]string{
+		"values.yaml": `# -- The image
+image:
+  repository: nginx
+  tag: latest
+# -- The replicas
+replicas: 1
+`,
+		"values-linux.yaml": `image:
+  tag: "1.0"
+nodeSelector:
+  kubernetes.io/os: linux
+`,
+		"windows-values.yaml": `replicas: "auto"
+`,
+	}
+	generate := func(strategy string) []Result {
+		t.Helper()
+		c := valuesFilesChart(t, files)
+		results := generateChartResults(context.Background(), c, DefaultOutputFile, testGeneration(t, GenerationPolicy{
+			DontAddGlobal: true,
+			ValuesFiles: []ValuesFilesConfig{{
+				Chart:    "app",
+				Files:    []string{"values.yaml", "values-*.yaml", "*-values.yaml"},
+				Strategy: strategy,
+			}},
+		}))
+		for _, result := range results {
+			assert.Empty(t, result.Errors)
+		}
+		return results
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		results := generate(ValuesStrategyMerge)
+		assert.Len(t, results, 1)
+		s := results[0].Schema
+
+		assert.Len(t, results[0].ValuesFiles, 3)
+		assert.Equal(t, "values.yaml", filepath.Base(results[0].ValuesPath))
+		assert.Equal(t, "The image", s.Properties["image"].Description)
+		assert.Equal(t, "1.0", s.Properties["image"].Properties["tag"].Default)
+		assert.Contains(t, s.Properties, "nodeSelector")
+		// the comment of the first values file is kept for the overridden value
+		assert.Equal(t, "The replicas", s.Properties["replicas"].Description)
+		assert.Equal(t, StringOrArrayOfString{"string"}, s.Properties["replicas"].Type)
+	})
+
+	t.Run("union", func(t *testing.T) {
+		results := generate(ValuesStrategyUnion)
+		assert.Len(t, results, 1)
+		s := results[0].Schema
+
+		assert.Equal(t, StringOrArrayOfString{"integer", "string"}, s.Properties["replicas"].Type)
+		assert.Contains(t, s.Properties, "nodeSelector")
+		assert.Contains(t, s.Properties["image"].Properties, "repository")
+		assert.NotContains(t, s.Required.Strings, "nodeSelector")
+		assert.NotContains(t, s.Required.Strings, "image")
+		assert.Equal(t, []string{"tag"}, s.Properties["image"].Required.Strings)
+
+		documents, err := results[0].ValuesDocuments()
+		assert.NoError(t, err)
+		assert.Len(t, documents, 3)
+		for _, values := range documents {
+			assert.NoError(t, s.ValidateValues(values, "values.schema.json"))
+		}
+	})
+
+	t.Run("separate schemas", func(t *testing.T) {
+		results := generate(ValuesStrategySeparateSchemas)
+		assert.Len(t, results, 3)
+
+		assert.False(t, results[0].Secondary)
+		assert.Empty(t, results[0].ValuesFiles)
+		assert.NotContains(t, results[0].Schema.Properties, "nodeSelector")
+		assert.True(t, results[1].Secondary)
+		assert.Equal(t, "values-linux.yaml", filepath.Base(results[1].ValuesPath))
+		assert.Contains(t, results[1].Schema.Properties, "nodeSelector")
+
+		outPath, err := OutputPath(&results[1], "values.schema.json")
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(filepath.Dir(results[1].ChartPath), "values-linux.schema.json"), outPath)
+		outPath, err = OutputPath(&results[0], "values.schema.json")
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(filepath.Dir(results[0].ChartPath), "values.schema.json"), outPath)
+	})
+}
+
+func TestMergedValuesDocuments(t *testing.T) {
+	c := valuesFilesChart(t, map[string]string{
+		"values.yaml":      "image:\n  tag: latest\n",
+		"values-prod.yaml": "image:\n  digest: sha256:abc\n",
+	})
+	result := generateResult(context.Background(), c.Path, DefaultOutputFile, testGeneration(t, GenerationPolicy{
+		DontAddGlobal: true,
+		ValuesFiles:   []ValuesFilesConfig{{Files: []string{"values.yaml", "values-prod.yaml", "missing.yaml"}}},
+	}))
+	assert.Empty(t, result.Errors)
+	assert.ElementsMatch(t, []string{"tag", "digest"}, result.Schema.Properties["image"].Required.Strings)
+
+	documents, err := result.ValuesDocuments()
+	assert.NoError(t, err)
+	assert.Len(t, documents, 1)
+	assert.NoError(t, result.Schema.ValidateValues(documents[0], "values.schema.json"))
+	// the values files on their own are incomplete
+	values, err := os.ReadFile(result.ValuesPath)
+	assert.NoError(t, err)
+	assert.Error(t, result.Schema.ValidateValues(values, "values.schema.json"))
+}
+
+func TestTopoSortKeepsSeparateSchemas(t *testing.T) {
+	parent := &chart.ChartFile{Name: "parent", Dependencies: []*chart.Dependency{{Name: "child"}}}
+	child := &chart.ChartFile{Name: "child"}
+	results := []*Result{
+		{Chart: parent},
+		{Chart: parent, Secondary: true, ValuesPath: "values-prod.yaml"},
+		{Chart: child},
+	}
+
+	sorted, err := TopoSort(results, false)
+	assert.NoError(t, err)
+	assert.Len(t, sorted, 3)
+	assert.Equal(t, "child", sorted[0].Chart.Name)
+	assert.False(t, sorted[1].Secondary)
+	assert.True(t, sorted[2].Secondary)
+}
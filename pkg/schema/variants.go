@@ -0,0 +1,179 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expandVariants converts the variants shorthand into anyOf. Titles and descriptions
+// which are the same in all variants are hoisted into the schema itself.
+// Variants which make another variant redundant are rejected, because they are
+// most likely a mistake.
+func (s *Schema) expandVariants() error {
+	if len(s.Variants) == 0 {
+		return nil
+	}
+
+	if len(s.AnyOf) > 0 {
+		return errors.New("cannot use both variants and anyOf")
+	}
+
+	if len(s.Variants) < 2 {
+		return errors.New("variants needs at least two schemas")
+	}
+
+	for i, v := range s.Variants {
+		if v == nil {
+			return fmt.Errorf("variant %d is empty", i)
+		}
+	}
+
+	if err := checkVariantsOverlap(s.Variants); err != nil {
+		return err
+	}
+
+	if title := s.Variants[0].Title; title != "" && allVariants(s.Variants, func(v *Schema) bool { return v.Title == title }) {
+		if s.Title == "" {
+			s.Title = title
+		}
+		for _, v := range s.Variants {
+			v.Title = ""
+		}
+	}
+
+	if description := s.Variants[0].Description; description != "" && allVariants(s.Variants, func(v *Schema) bool { return v.Description == description }) {
+		if s.Description == "" {
+			s.Description = description
+		}
+		for _, v := range s.Variants {
+			v.Description = ""
+		}
+	}
+
+	s.AnyOf = s.Variants
+	s.Variants = nil
+	return nil
+}
+
+func allVariants(variants []*Schema, fn func(*Schema) bool) bool {
+	for _, v := range variants {
+		if !fn(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkVariantsOverlap returns an error if a variant accepts every value of another variant
+func checkVariantsOverlap(variants []*Schema) error {
+	for i, a := range variants {
+		for j, b := range variants {
+			if i >= j {
+				continue
+			}
+
+			if a.EqualsOpt(b, EqualsStructural) {
+				return fmt.Errorf("variants %d and %d are identical", i, j)
+			}
+			if isTypeOnly(a) && typesIncluded(b.Type, a.Type) {
+				return fmt.Errorf("variant %d accepts all values of variant %d, use a single schema or add constraints", i, j)
+			}
+			if isTypeOnly(b) && typesIncluded(a.Type, b.Type) {
+				return fmt.Errorf("variant %d accepts all values of variant %d, use a single schema or add constraints", j, i)
+			}
+		}
+	}
+	return nil
+}
+
+// isTypeOnly checks if the schema has no constraints besides its type
+func isTypeOnly(s *Schema) bool {
+	withoutType := s.Clone()
+	withoutType.Type = nil
+	return withoutType.EqualsOpt(&Schema{}, EqualsStructural)
+}
+
+// typesIncluded checks if all values of the types are also values of the other types.
+// An empty list of types allows all values.
+func typesIncluded(types, other StringOrArrayOfString) bool {
+	if len(other) == 0 {
+		return true
+	}
+	if len(types) == 0 {
+		return false
+	}
+
+	for _, t := range types {
+		if slices.Contains(other, t) {
+			continue
+		}
+		if t == "integer" && slices.Contains(other, "number") {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// checkNestedShorthands returns an error located at the line of the first shorthand below the
+// top level of the annotation of the comment (e.g. variants or nullable in items), which is only
+// expanded for the annotated key itself and would be dropped. It must run after expandVariants.
+func (s *Schema) checkNestedShorthands(comment string, gen *generation) error {
+	return s.Walk(func(path string, v *Schema) error {
+		if path == "" {
+			return nil
+		}
+		for _, shorthand := range []struct {
+			keyword string
+			used    bool
+		}{{"variants", len(v.Variants) > 0}, {"nullable", v.Nullable}} {
+			if !shorthand.used {
+				continue
+			}
+			message := fmt.Sprintf("%s can only be used at the top level of an annotation, not in %s", shorthand.keyword, path)
+			if line := shorthandLine(comment, path, shorthand.keyword, gen); line > 0 {
+				return &AnnotationLineError{Line: line, Message: message}
+			}
+			return errors.New(message)
+		}
+		return nil
+	})
+}
+
+// shorthandLine returns the line of the comment the keyword of the subschema at the json pointer
+// is annotated in, 0 if it's unknown (e.g. for keywords of presets)
+func shorthandLine(comment, pointer, keyword string, gen *generation) int {
+	annotation, err := scanComment(comment)
+	if err != nil {
+		return 0
+	}
+	rawYaml, err := annotation.yaml(gen.RelaxedAnnotationIndentation)
+	if err != nil {
+		return 0
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(rawYaml, &node); err != nil {
+		return 0
+	}
+	if line := ValueLine(&node, annotationPath(&node, pointer, keyword)); line > 0 && line <= len(annotation.commentLines) {
+		return annotation.commentLines[line-1]
+	}
+	return 0
+}
+
+// annotationPath returns the keys of the keyword of the subschema at the json pointer in the yaml
+// of the annotation. The top-level anyOf was written as variants, if the annotation has no anyOf.
+func annotationPath(node *yaml.Node, pointer, keyword string) []string {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = unescapePointerSegment(segment)
+	}
+	if segments[0] == "anyOf" && len(node.Content) > 0 && mappingValue(node.Content[0], "anyOf") == nil {
+		segments[0] = "variants"
+	}
+	return append(segments, keyword)
+}
@@ -0,0 +1,219 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		name        string
+		comment     string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name: "expands into anyOf",
+			comment: `# @schema
+# variants:
+#   - type: string
+#     pattern: ^[0-9]+m$
+#   - type: integer
+#     minimum: 1
+# @schema`,
+			expected: `{"anyOf":[{"pattern":"^[0-9]+m$","type":"string"},{"minimum":1,"type":"integer"}],"required":[]}`,
+		},
+		{
+			name: "hoists shared title and description",
+			comment: `# @schema
+# variants:
+#   - type: string
+#     title: cpu
+#     description: The cpu limit
+#     pattern: ^[0-9]+m$
+#   - type: integer
+#     title: cpu
+#     description: The cpu limit in cores
+# @schema`,
+			expected: `{"anyOf":[{"description":"The cpu limit","pattern":"^[0-9]+m$","type":"string"},{"description":"The cpu limit in cores","type":"integer"}],"required":[],"title":"cpu"}`,
+		},
+		{
+			name: "identical variants",
+			comment: `# @schema
+# variants:
+#   - type: string
+#     title: a
+#   - type: string
+#     title: b
+# @schema`,
+			expectedErr: "variants 0 and 1 are identical",
+		},
+		{
+			name: "unconstrained variant includes another",
+			comment: `# @schema
+# variants:
+#   - type: string
+#     pattern: ^foo
+#   - type: [string, "null"]
+# @schema`,
+			expectedErr: "variant 1 accepts all values of variant 0",
+		},
+		{
+			name: "number includes integer",
+			comment: `# @schema
+# variants:
+#   - type: number
+#   - type: integer
+#     minimum: 1
+# @schema`,
+			expectedErr: "variant 0 accepts all values of variant 1",
+		},
+		{
+			name: "empty variant includes everything",
+			comment: `# @schema
+# variants:
+#   - {}
+#   - type: integer
+#     minimum: 1
+# @schema`,
+			expectedErr: "variant 0 accepts all values of variant 1",
+		},
+		{
+			name: "single variant",
+			comment: `# @schema
+# variants:
+#   - type: integer
+# @schema`,
+			expectedErr: "variants needs at least two schemas",
+		},
+		{
+			name: "variants and anyOf",
+			comment: `# @schema
+# anyOf:
+#   - type: string
+# variants:
+#   - type: integer
+#   - type: string
+# @schema`,
+			expectedErr: "cannot use both variants and anyOf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, _, err := GetSchemaFromComment(tt.comment)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, schema.Variants)
+
+			data, err := schema.ToJson()
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(data))
+		})
+	}
+}
+
+func TestNestedShorthands(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		comment string
+		line    int
+		message string
+	}{
+		{
+			name: "in items",
+			comment: `# @schema
+# type: array
+# items:
+#   variants:
+#     - type: string
+#     - type: integer
+# @schema`,
+			line:    4,
+			message: "variants can only be used at the top level of an annotation, not in /items",
+		},
+		{
+			name: "in a variant",
+			comment: `# @schema
+# variants:
+#   - type: string
+#   - type: object
+#     properties:
+#       limit:
+#         variants:
+#           - type: string
+#           - type: integer
+# @schema`,
+			line:    7,
+			message: "not in /anyOf/1/properties/limit",
+		},
+		{
+			name: "nullable in items",
+			comment: `# @schema
+# type: array
+# items:
+#   type: string
+#   nullable: true
+# @schema`,
+			line:    5,
+			message: "nullable can only be used at the top level of an annotation, not in /items",
+		},
+		{
+			name: "nullable in a variant",
+			comment: `# @schema
+# variants:
+#   - type: string
+#     nullable: true
+#   - type: integer
+# @schema`,
+			line:    4,
+			message: "not in /anyOf/0",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, _, err := GetSchemaFromComment(tt.comment)
+			assert.NoError(t, err)
+			err = schema.checkNestedShorthands(tt.comment, &generation{})
+			var lineErr *AnnotationLineError
+			if assert.ErrorAs(t, err, &lineErr) {
+				assert.Equal(t, tt.line, lineErr.Line)
+				assert.Contains(t, lineErr.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestVariantsDefault(t *testing.T) {
+	yamlContent := `# @schema
+# variants:
+#   - type: string
+#     pattern: ^[0-9]+m$
+#   - type: integer
+#     minimum: 1
+# @schema
+cpu: 3
+# @schema
+# variants:
+#   - type: string
+#     pattern: ^[0-9]+m$
+#   - type: integer
+#     minimum: 1
+# @schema
+quotedCpu: "3"
+`
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(yamlContent), &node))
+	config, err := NewSkipAutoGenerationConfig([]string{})
+	assert.NoError(t, err)
+	schema := YamlToSchema("", &node, false, false, false, true, config, nil, nil)
+
+	// the defaults are typed like the yaml values, because there is no type to cast them to
+
+	assert.Equal(t, 3, schema.Properties["cpu"].Default)
+	assert.Equal(t, "3", schema.Properties["quotedCpu"].Default)
+}
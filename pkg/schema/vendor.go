@@ -0,0 +1,248 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dadav/helm-schema/pkg/gitref"
+	"github.com/dadav/helm-schema/pkg/registry"
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"gopkg.in/yaml.v3"
+)
+
+// VendorDir is the directory (relative to the chart directory) the referenced schemas are vendored to
+const VendorDir = "schemas/vendor"
+
+// VendorMapFile is the file of the vendor directory, which maps the vendored references to their files
+const VendorMapFile = "refs.yaml"
+
+// annotationRefPattern matches the $ref keywords of the lines of annotations, e.g. # $ref: https://...
+var annotationRefPattern = regexp.MustCompile(`("?\$ref"?\s*:\s*["']?)([^\s"',}\]]+)`)
+
+// IsExternalRef checks if the schema of the reference (without json pointer) is downloaded,
+// i.e. if it's a url, git or registry reference
+func IsExternalRef(ref string) bool {
+	return urlref.IsRef(ref) || gitref.IsRef(ref) || registry.IsRef(ref)
+}
+
+// Vendor downloads the schemas of external references, so they can be used without network access
+// (e.g. in air-gapped builds). The references of the downloaded schemas are vendored as well and
+// rewritten to the vendored files.
+type Vendor struct {
+	dir string
+	// files maps the references to the names of their files in dir
+	files map[string]string
+	// documents contains the rewritten schemas of the files vendored by Add
+	documents map[string][]byte
+}
+
+// NewVendor returns a vendor writing to the directory. The references vendored to the directory
+// before keep their files (see VendorMapFile).
+func NewVendor(dir string) (*Vendor, error) {
+	v := &Vendor{
+		dir:       dir,
+		files:     make(map[string]string),
+		documents: make(map[string][]byte),
+	}
+	content, err := os.ReadFile(filepath.Join(dir, VendorMapFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, &v.files); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", filepath.Join(dir, VendorMapFile), err)
+	}
+	return v, nil
+}
+
+// Dir returns the directory the schemas are vendored to
+func (v *Vendor) Dir() string {
+	return v.dir
+}
+
+// Files returns the vendored references (without json pointer) and the names of their files
+func (v *Vendor) Files() map[string]string {
+	return v.files
+}
+
+// Add downloads the schema of the external reference (without json pointer) and the schemas it
+// references, and returns the name of its file in the vendor directory
+func (v *Vendor) Add(ctx context.Context, ref string) (string, error) {
+	name := v.fileName(ref)
+	if _, ok := v.documents[name]; ok {
+		return name, nil
+	}
+
+	var content []byte
+	var err error
+	switch {
+	case registry.IsRef(ref):
+		content, err = pullRegistryRef(ctx, ref, logger)
+	case gitref.IsRef(ref):
+		content, err = fetchGitRef(ctx, ref, logger)
+	case urlref.IsRef(ref):
+		content, err = fetchURLRef(ctx, ref, logger)
+	default:
+		return "", fmt.Errorf("%s is not an external reference", ref)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return "", fmt.Errorf("could not parse %s: %w", ref, err)
+	}
+	// reserved before the references are rewritten, so cyclic references end here
+	v.documents[name] = nil
+
+	// the $id would change the base uri of the rewritten references
+	base, _, err := schemaBase(ref, document)
+	if err != nil {
+		return "", err
+	}
+	delete(document, "$id")
+	if err := v.rewriteRefs(ctx, base, document, false); err != nil {
+		return "", fmt.Errorf("could not vendor the references of %s: %w", ref, err)
+	}
+
+	v.documents[name], err = json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Write writes the vendored schemas and the VendorMapFile to the vendor directory
+func (v *Vendor) Write() error {
+	if err := os.MkdirAll(v.dir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(v.documents)) {
+		if err := os.WriteFile(filepath.Join(v.dir, name), append(v.documents[name], '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	content, err := yaml.Marshal(v.files)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(v.dir, VendorMapFile), content, 0o644)
+}
+
+// fileName returns the name of the file of the reference, which is derived from the name of the
+// referenced document and unique within the vendor directory
+func (v *Vendor) fileName(ref string) string {
+	if name, ok := v.files[ref]; ok {
+		return name
+	}
+	base := documentName(ref)
+	if base == "" || base == "." || base == "/" {
+		base = "schema"
+	}
+	used := make(map[string]bool, len(v.files))
+	for _, name := range v.files {
+		used[name] = true
+	}
+	name := base + ".json"
+	for i := 2; used[name] || name == VendorMapFile; i++ {
+		name = fmt.Sprintf("%s-%d.json", base, i)
+	}
+	v.files[ref] = name
+	return name
+}
+
+// rewriteRefs vendors the external references of the schema, which has the base uri, and rewrites
+// them to the vendored files. The values of data keywords (e.g. default) are kept as they are.
+func (v *Vendor) rewriteRefs(ctx context.Context, base string, value interface{}, data bool) error {
+	switch s := value.(type) {
+	case map[string]interface{}:
+		if !data {
+			var err error
+			if base, _, err = schemaBase(base, s); err != nil {
+				return err
+			}
+			if ref, ok := s["$ref"].(string); ok && !strings.HasPrefix(ref, "#") {
+				target, err := resolveRefURI(base, ref)
+				if err != nil {
+					return fmt.Errorf("could not resolve $ref %s: %w", ref, err)
+				}
+				uri, fragment, found := strings.Cut(target, "#")
+				if IsExternalRef(uri) {
+					name, err := v.Add(ctx, uri)
+					if err != nil {
+						return err
+					}
+					if found {
+						name += "#" + fragment
+					}
+					s["$ref"] = name
+				}
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(s)) {
+			sub := s[key]
+			if schemas, ok := sub.(map[string]interface{}); ok && !data && isSchemaMapKeyword(key) {
+				for _, name := range slices.Sorted(maps.Keys(schemas)) {
+					if err := v.rewriteRefs(ctx, base, schemas[name], false); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := v.rewriteRefs(ctx, base, sub, data || isDataKeyword(key)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range s {
+			if err := v.rewriteRefs(ctx, base, item, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RewriteAnnotationRefs replaces the references (without json pointer) of the $refs in the
+// annotations of the values file content with their rewrites, e.g. with the vendored files.
+// Everything else is kept as it is.
+func RewriteAnnotationRefs(content []byte, rewrites map[string]string) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	inAnnotation := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(string(line))
+		// like the annotations of keys, the root annotations start with SchemaPrefix
+		if strings.HasPrefix(trimmed, SchemaPrefix) {
+			inAnnotation = !inAnnotation
+			continue
+		}
+		if !inAnnotation || !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lines[i] = annotationRefPattern.ReplaceAllFunc(line, func(match []byte) []byte {
+			parts := annotationRefPattern.FindSubmatch(match)
+			ref, fragment, found := strings.Cut(string(parts[2]), "#")
+			rewrite, ok := rewrites[ref]
+			if !ok {
+				return match
+			}
+			if found {
+				rewrite += "#" + fragment
+			}
+			return append(slices.Clone(parts[1]), rewrite...)
+		})
+	}
+	return bytes.Join(lines, nil)
+}
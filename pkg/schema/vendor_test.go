@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/urlref"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestVendor(t *testing.T) {
+	documents := map[string]string{
+		"/schemas/types/port.json": `{"$defs": {"port": {"type": "integer", "minimum": 1}}, "default": {"$ref": "not-a-ref.json"}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(content))
+	}))
+	UseURLFetcher(urlref.NewFetcher())
+	defer UseURLFetcher(urlref.NewFetcher())
+	// the references of common.json are relative to its $id
+	documents["/schemas/common.json"] = `{"$id": "` + server.URL + `/schemas/common.json", "properties": {"port": {"$ref": "types/port.json#/$defs/port"}, "self": {"$ref": "#/properties/port"}}}`
+
+	chartDir := t.TempDir()
+	vendor, err := NewVendor(filepath.Join(chartDir, VendorDir))
+	assert.NoError(t, err)
+	name, err := vendor.Add(context.Background(), server.URL+"/schemas/common.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "common.json", name)
+	_, err = vendor.Add(context.Background(), server.URL+"/schemas/missing.json")
+	assert.Error(t, err)
+	assert.NoError(t, vendor.Write())
+
+	content, err := os.ReadFile(filepath.Join(chartDir, VendorDir, "common.json"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"properties": {"port": {"$ref": "port.json#/$defs/port"}, "self": {"$ref": "#/properties/port"}}}`, string(content))
+	content, err = os.ReadFile(filepath.Join(chartDir, VendorDir, "port.json"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, documents["/schemas/types/port.json"], string(content))
+
+	// vendoring again keeps the files
+	vendor, err = NewVendor(filepath.Join(chartDir, VendorDir))
+	assert.NoError(t, err)
+	assert.Equal(t, "port.json", vendor.Files()[server.URL+"/schemas/types/port.json"])
+	assert.Equal(t, "common-2.json", vendor.fileName("https://other.example.com/common.json"))
+
+	values := []byte(`# @schema
+# $ref: ` + server.URL + `/schemas/common.json#/properties/port
+# @schema
+port: 80
+# $ref: ` + server.URL + `/schemas/common.json is only a comment
+# @schema
+# anyOf:
+#   - {$ref: "` + server.URL + `/schemas/common.json#/properties/self"}
+#   - type: "null"
+# @schema
+listener: 8080
+`)
+	rewritten := RewriteAnnotationRefs(values, map[string]string{server.URL + "/schemas/common.json": VendorDir + "/common.json"})
+	assert.Equal(t, `# @schema
+# $ref: schemas/vendor/common.json#/properties/port
+# @schema
+port: 80
+# $ref: `+server.URL+`/schemas/common.json is only a comment
+# @schema
+# anyOf:
+#   - {$ref: "schemas/vendor/common.json#/properties/self"}
+#   - type: "null"
+# @schema
+listener: 8080
+`, string(rewritten))
+
+	// the vendored schemas are used without the server
+	server.Close()
+	valuesPath := filepath.Join(chartDir, "values.yaml")
+	assert.NoError(t, os.WriteFile(valuesPath, rewritten, 0o644))
+	var node yaml.Node
+	assert.NoError(t, yaml.Unmarshal(rewritten, &node))
+	s, err := GenerationPolicy{}.ToSchema(valuesPath, &node)
+	assert.NoError(t, err)
+	assert.Empty(t, s.UnresolvedRefs())
+	assert.NoError(t, s.ValidateInternalRefs())
+	assert.NoError(t, s.ValidateValues([]byte("port: 80\nlistener: 8080\n"), filepath.Join(chartDir, "values.schema.json")))
+	assert.Error(t, s.ValidateValues([]byte("port: 0\nlistener: 8080\n"), filepath.Join(chartDir, "values.schema.json")))
+}
@@ -0,0 +1,91 @@
+package schema
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SkipSchema can be returned by a WalkFunc to skip the subschemas of the current schema
+var SkipSchema = errors.New("skip this schema")
+
+// WalkFunc is called for every schema visited by Walk. The path is the JSON pointer
+// of the schema relative to the walked schema ("" for the schema itself).
+type WalkFunc func(path string, s *Schema) error
+
+// Walk calls fn for the schema and all its subschemas (properties, patternProperties,
+// items, additionalProperties, propertyNames, allOf, anyOf, oneOf, not, if, then, else, contentSchema, $defs and definitions).
+// Parents are visited before their children and keys of maps are visited in sorted order.
+// Walk stops at the first error returned by fn, except for SkipSchema.
+func (s *Schema) Walk(fn WalkFunc) error {
+	err := s.walk("", fn)
+	if errors.Is(err, SkipSchema) {
+		return nil
+	}
+	return err
+}
+
+func (s *Schema) walk(path string, fn WalkFunc) error {
+	if s == nil {
+		return nil
+	}
+
+	if err := fn(path, s); err != nil {
+		if errors.Is(err, SkipSchema) {
+			return nil
+		}
+		return err
+	}
+
+	for _, keyword := range []string{"properties", "patternProperties", "$defs", "definitions"} {
+		subSchemas := schemaMap(s, keyword)
+		names := make([]string, 0, len(subSchemas))
+		for name := range subSchemas {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if err := subSchemas[name].walk(path+"/"+keyword+"/"+escapePointerSegment(name), fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.Items.walk(path+"/items", fn); err != nil {
+		return err
+	}
+
+	if subSchema, ok := s.AdditionalProperties.(Schema); ok {
+		err := subSchema.walk(path+"/additionalProperties", fn)
+		s.AdditionalProperties = subSchema
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		for i, v := range schemaArray(s, keyword) {
+			if err := v.walk(path+"/"+keyword+"/"+strconv.Itoa(i), fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sub := range []struct {
+		keyword string
+		schema  *Schema
+	}{{"not", s.Not}, {"if", s.If}, {"then", s.Then}, {"else", s.Else}, {"contentSchema", s.ContentSchema}, {"propertyNames", s.PropertyNames}} {
+		if err := sub.schema.walk(path+"/"+sub.keyword, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// escapePointerSegment escapes a key to be used in a JSON pointer
+func escapePointerSegment(segment string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(segment, "~", "~0"), "/", "~1")
+}
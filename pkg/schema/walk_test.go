@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalk(t *testing.T) {
+	root := &Schema{
+		Properties: map[string]*Schema{
+			"b":   {Items: &Schema{AnyOf: []*Schema{{}, {}}}},
+			"a/b": {Not: &Schema{}},
+		},
+		PatternProperties:    map[string]*Schema{"^x-": {}},
+		AdditionalProperties: Schema{Title: "additional"},
+		If:                   &Schema{},
+		Then:                 &Schema{},
+		Else:                 &Schema{},
+		AllOf:                []*Schema{{}},
+		OneOf:                []*Schema{{}},
+		Defs:                 map[string]*Schema{"port": {}},
+		Definitions:          map[string]*Schema{"old": {}},
+	}
+
+	var paths []string
+	err := root.Walk(func(path string, s *Schema) error {
+		paths = append(paths, path)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"",
+		"/properties/a~1b",
+		"/properties/a~1b/not",
+		"/properties/b",
+		"/properties/b/items",
+		"/properties/b/items/anyOf/0",
+		"/properties/b/items/anyOf/1",
+		"/patternProperties/^x-",
+		"/$defs/port",
+		"/definitions/old",
+		"/additionalProperties",
+		"/allOf/0",
+		"/oneOf/0",
+		"/if",
+		"/then",
+		"/else",
+	}, paths)
+
+	// every path can be queried
+	for _, path := range paths[1:] {
+		_, err := root.Query(path)
+		assert.NoError(t, err, path)
+	}
+}
+
+func TestWalkModifyAdditionalProperties(t *testing.T) {
+	root := &Schema{AdditionalProperties: Schema{Title: "before"}}
+
+	err := root.Walk(func(path string, s *Schema) error {
+		s.Title = "after"
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "after", root.AdditionalProperties.(Schema).Title)
+}
+
+func TestWalkSkipAndStop(t *testing.T) {
+	root := &Schema{
+		Properties: map[string]*Schema{
+			"a": {Properties: map[string]*Schema{"nested": {}}},
+			"b": {},
+		},
+	}
+
+	var paths []string
+	err := root.Walk(func(path string, s *Schema) error {
+		paths = append(paths, path)
+		if path == "/properties/a" {
+			return SkipSchema
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "/properties/a", "/properties/b"}, paths)
+
+	stop := errors.New("stop")
+	paths = nil
+	err = root.Walk(func(path string, s *Schema) error {
+		paths = append(paths, path)
+		if path == "/properties/a" {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{"", "/properties/a"}, paths)
+}
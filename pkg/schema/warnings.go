@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Categories of the warnings, each can be promoted to errors (see WarningPolicy)
+const (
+	// WarningUnresolvedRef reports $refs the published schema keeps (see UnresolvedRefs)
+	WarningUnresolvedRef = "unresolved-ref"
+	// WarningOrphanAnnotation reports @schema blocks which aren't attached to any key
+	WarningOrphanAnnotation = "orphan-annotation"
+	// WarningTypeWidening reports inferred types which are likely too narrow (see LintTypeWidening)
+	WarningTypeWidening = RuleTypeWidening
+	// WarningDeprecatedUsage reports values which set deprecated keys (see LintDeprecatedUsage)
+	WarningDeprecatedUsage = "deprecated-usage"
+	// WarningOversizedSchema reports schemas which are larger than the maximum size
+	WarningOversizedSchema = "oversized-schema"
+	// WarningPatternMatch reports keys which match patternProperties instead of being added to the properties (see PatternMatches)
+	WarningPatternMatch = "pattern-match"
+	// WarningDefinitionConflict reports definitions which differ from those of dependencies or of the existing schema
+	WarningDefinitionConflict = "definition-conflict"
+	// WarningHelmCompat reports constructs of the schema, which helm ignores (see CheckHelmCompatibility)
+	WarningHelmCompat = "helm-compat"
+)
+
+// WarningCategories are all categories of warnings
+var WarningCategories = []string{
+	WarningUnresolvedRef,
+	WarningOrphanAnnotation,
+	WarningTypeWidening,
+	WarningDeprecatedUsage,
+	WarningOversizedSchema,
+	WarningPatternMatch,
+	WarningDefinitionConflict,
+	WarningHelmCompat,
+}
+
+// Warning is a finding of the generation, which doesn't fail it (unless its category is promoted to errors)
+type Warning struct {
+	// Category is one of WarningCategories
+	Category string
+	// File and Line locate the warning, Line is 0 if it's unknown
+	File string
+	Line int
+	// Message describes the warning
+	Message string
+}
+
+func (w Warning) String() string {
+	if w.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s (%s)", w.File, w.Line, w.Message, w.Category)
+	}
+	return fmt.Sprintf("%s (%s)", w.Message, w.Category)
+}
+
+// WarningPolicy decides which categories of warnings fail the generation
+type WarningPolicy struct {
+	// FailOnWarn promotes all categories to errors
+	FailOnWarn bool
+	// Errors are the categories which are promoted to errors
+	Errors []string
+}
+
+// Validate checks that the promoted categories exist
+func (p WarningPolicy) Validate() error {
+	for _, category := range p.Errors {
+		if !slices.Contains(WarningCategories, category) {
+			return fmt.Errorf("unknown warning category %s, one of (%s)", category, strings.Join(WarningCategories, ", "))
+		}
+	}
+	return nil
+}
+
+// IsError checks if the warnings of the category are promoted to errors
+func (p WarningPolicy) IsError(category string) bool {
+	return p.FailOnWarn || slices.Contains(p.Errors, category)
+}
+
+// Warning returns the issue as warning of the category of its rule, located at the line of its key
+func (i LintIssue) Warning(file string, line int) Warning {
+	return Warning{Category: i.Rule, File: file, Line: line, Message: dottedPath(i.Path) + ": " + i.Message}
+}
@@ -0,0 +1,28 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarningPolicy(t *testing.T) {
+	assert.NoError(t, WarningPolicy{}.Validate())
+	assert.NoError(t, WarningPolicy{Errors: WarningCategories}.Validate())
+	assert.ErrorContains(t, WarningPolicy{Errors: []string{"typo"}}.Validate(), "unknown warning category typo")
+
+	policy := WarningPolicy{Errors: []string{WarningOrphanAnnotation}}
+	assert.True(t, policy.IsError(WarningOrphanAnnotation))
+	assert.False(t, policy.IsError(WarningTypeWidening))
+
+	policy.FailOnWarn = true
+	for _, category := range WarningCategories {
+		assert.True(t, policy.IsError(category))
+	}
+}
+
+func TestWarningString(t *testing.T) {
+	issue := LintIssue{Rule: RuleTypeWidening, Severity: SeverityWarning, Path: []string{"image", "tag"}, Message: "quote the value"}
+	assert.Equal(t, "values.yaml:3: image.tag: quote the value (type-widening)", issue.Warning("values.yaml", 3).String())
+	assert.Equal(t, "image.tag: quote the value (type-widening)", issue.Warning("", 0).String())
+}
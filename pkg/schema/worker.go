@@ -1,124 +1,311 @@
 package schema
 
 import (
-	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
-	"strings"
+	"slices"
+	"strconv"
 
 	"github.com/dadav/helm-schema/pkg/chart"
-	"github.com/dadav/helm-schema/pkg/util"
+	"github.com/dadav/helm-schema/pkg/logging"
 	"gopkg.in/yaml.v3"
 )
 
+// StripHelmDocsPrefixAnnotation is the annotation of the Chart.yaml which overrides
+// the removal of the helm-docs prefix (--dont-strip-helm-docs-prefix) for the chart
+const StripHelmDocsPrefixAnnotation = "helm-schema/strip-helm-docs-prefix"
+
+// errNoValuesFile is returned by findValuesFile, if the chart has none of the values files
+var errNoValuesFile = errors.New("no values file found")
+
 type Result struct {
 	ChartPath  string
 	ValuesPath string
 	Chart      *chart.ChartFile
 	Schema     Schema
+	Refs       []string
 	Errors     []error
+
+	// ValuesFiles are all values files the schema was generated from, if there are several (see GenerationPolicy.ValuesFiles)
+	ValuesFiles []string
+	// ValuesStrategy is the strategy the ValuesFiles were combined with
+	ValuesStrategy string
+	// Secondary is set for the schemas of the other values files of a chart with the separate-schemas strategy
+	Secondary bool
+	// Warnings are the findings of the generation, which don't fail it (e.g. orphan annotations)
+	Warnings []Warning
+	// ArchiveDir is the chart.Chart.ArchiveDir of a chart extracted from an archive
+	ArchiveDir string
+	// RefFiles are all local files loaded while resolving the references, including the ones
+	// referenced by other referenced schemas
+	RefFiles []string
 }
 
+// Worker generates the results of the charts of the queue until the queue is closed or the
+// context is done. Unless the context is done, there is a result for every chart.
+// If the policy is invalid, the results contain its error.
 func Worker(
-	dryRun, uncomment, addSchemaReference, keepFullComment, helmDocsCompatibilityMode, dontRemoveHelmDocsPrefix, dontAddGlobal bool,
-	valueFileNames []string,
-	skipAutoGenerationConfig *SkipAutoGenerationConfig,
+	ctx context.Context,
+	dryRun bool,
+	policy GenerationPolicy,
 	outFile string,
-	queue <-chan string,
+	queue <-chan chart.Chart,
 	results chan<- Result,
 ) {
-	for chartPath := range queue {
-		result := Result{ChartPath: chartPath}
+	gen, err := policy.compile()
 
-		chartBasePath := filepath.Dir(chartPath)
-		file, err := os.Open(chartPath)
+	for {
+		var c chart.Chart
+		select {
+		case <-ctx.Done():
+			return
+		case next, ok := <-queue:
+			if !ok {
+				return
+			}
+			c = next
+		}
+
+		chartResults := []Result{{ChartPath: c.Path, Errors: []error{err}}}
+		if err == nil {
+			chartResults = generateChartResults(ctx, c, outFile, gen)
+		}
+
+		for _, result := range chartResults {
+			select {
+			case <-ctx.Done():
+				return
+			case results <- result:
+			}
+		}
+	}
+}
+
+// findValuesFile returns the path of the first existing values file of the chart
+func findValuesFile(chartBasePath string, valueFileNames []string) (string, []error) {
+	errorsWeMaybeCanIgnore := []error{}
+
+	for _, possibleValueFileName := range valueFileNames {
+		valuesPath := filepath.Join(chartBasePath, possibleValueFileName)
+		_, err := os.Stat(valuesPath)
 		if err != nil {
-			result.Errors = append(result.Errors, err)
-			results <- result
+			if !os.IsNotExist(err) {
+				errorsWeMaybeCanIgnore = append(errorsWeMaybeCanIgnore, err)
+			}
 			continue
 		}
+		return valuesPath, nil
+	}
 
-		chart, err := chart.ReadChart(file)
+	return "", append(errorsWeMaybeCanIgnore, errNoValuesFile)
+}
+
+// generateResult reads the chart and its values files and creates the schema, which is written to outFile.
+// With the separate-schemas strategy, it's the schema of the first values file.
+func generateResult(ctx context.Context, chartPath string, outFile string, gen *generation) Result {
+	c, err := chart.LoadChart(chartPath)
+	if err != nil {
+		return Result{ChartPath: chartPath, Errors: []error{err}}
+	}
+	return generateChartResults(ctx, c, outFile, gen)[0]
+}
+
+// generateChartResults reads the values files of the already loaded chart and creates the schemas.
+// There is only one result, unless the chart uses the separate-schemas strategy.
+// outFile is the output file (see OutputPath) the modelines of AddSchemaReference reference.
+func generateChartResults(ctx context.Context, c chart.Chart, outFile string, gen *generation) []Result {
+	chart := c.File
+	result := Result{ChartPath: c.Path, Chart: &chart, ArchiveDir: c.ArchiveDir}
+	chartBasePath := c.Dir()
+
+	if err := ctx.Err(); err != nil {
+		result.Errors = append(result.Errors, err)
+		return []Result{result}
+	}
+
+	// the referenced schemas are downloaded with the context of the chart and the warnings
+	// contain the chart (e.g. in the diagnostics of the JSONLinesHook)
+	chartGen := *gen
+	chartGen.ctx = ctx
+	chartGen.Logger = logging.ForChart(gen.log(), chart.Name)
+	chartGen.refFiles = make(map[string]bool)
+	gen = &chartGen
+
+	if value, ok := chart.Annotations[StripHelmDocsPrefixAnnotation]; ok {
+		strip, err := strconv.ParseBool(value)
 		if err != nil {
-			result.Errors = append(result.Errors, err)
-			results <- result
-			continue
+			result.Errors = append(result.Errors, fmt.Errorf("invalid value %q of the annotation %s: %w", value, StripHelmDocsPrefixAnnotation, err))
+			return []Result{result}
 		}
-		result.Chart = &chart
+		gen.DontStripHelmDocsPrefix = !strip
+	}
 
+	strategy := ValuesStrategyMerge
+	var valuesPaths []string
+	var errs []error
+	if config := gen.valuesFilesConfigFor(chart.Name); config != nil {
+		strategy = config.Strategy
+		valuesPaths, errs = findValuesFiles(chartBasePath, config.Files)
+	} else {
 		var valuesPath string
-		var valuesFound bool
-		errorsWeMaybeCanIgnore := []error{}
+		valuesPath, errs = findValuesFile(chartBasePath, gen.ValueFiles)
+		valuesPaths = []string{valuesPath}
+	}
+	if gen.AllowMissingValues && len(errs) == 1 && errors.Is(errs[0], errNoValuesFile) {
+		gen.log().Debugf("Generating a minimal schema for chart %s, because it has no values file", chart.Name)
+		schema, err := minimalSchema("", gen)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("could not generate the schema of chart %s: %w", chart.Name, err))
+			return []Result{result}
+		}
+		result.Schema = *schema
+		return []Result{result}
+	}
+	if len(errs) > 0 {
+		result.Errors = append(result.Errors, errs...)
+		return []Result{result}
+	}
 
-		for _, possibleValueFileName := range valueFileNames {
-			valuesPath = filepath.Join(chartBasePath, possibleValueFileName)
-			_, err := os.Stat(valuesPath)
+	documents := make([]*yaml.Node, 0, len(valuesPaths))
+	for i, valuesPath := range valuesPaths {
+		// merged values files are partial, only the first one is complete
+		addReference := gen.AddSchemaReference && (i == 0 || strategy != ValuesStrategyMerge)
+		schemaName := ""
+		if addReference {
+			// the schema of the merged or united values files is written for the first one
+			output := Result{ChartPath: c.Path, Chart: &chart, ValuesPath: valuesPaths[0]}
+			if i > 0 && strategy == ValuesStrategySeparateSchemas {
+				output.ValuesPath = valuesPath
+				output.Secondary = true
+			}
+			var err error
+			schemaName, err = schemaReference(&output, outFile, valuesPath)
 			if err != nil {
-				if !os.IsNotExist(err) {
-					errorsWeMaybeCanIgnore = append(errorsWeMaybeCanIgnore, err)
-				}
-				continue
+				result.Errors = append(result.Errors, fmt.Errorf("could not reference the schema of chart %s in %s: %w", chart.Name, valuesPath, err))
+				return []Result{result}
 			}
-			valuesFound = true
-			break
 		}
 
-		if !valuesFound {
-			result.Errors = append(result.Errors, errorsWeMaybeCanIgnore...)
-			result.Errors = append(result.Errors, errors.New("no values file found"))
-			results <- result
-			continue
+		values, content, err := readValues(valuesPath, addReference, schemaName, gen)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("could not read the values file %s of chart %s: %w", valuesPath, chart.Name, err))
+			return []Result{result}
+		}
+		if !gen.AllowMissingValues || !isEmptyDocument(values) {
+			for _, orphan := range findOrphanAnnotations(values, content, gen.leadingComment) {
+				result.Warnings = append(result.Warnings, Warning{
+					Category: WarningOrphanAnnotation,
+					File:     valuesPath,
+					Line:     orphan.Line,
+					Message:  "ignoring @schema annotation: " + orphan.Reason,
+				})
+			}
 		}
-		result.ValuesPath = valuesPath
+		documents = append(documents, values)
+	}
 
-		valuesFile, err := os.Open(valuesPath)
-		if err != nil {
-			result.Errors = append(result.Errors, err)
-			results <- result
-			continue
+	for _, values := range documents {
+		if err := prefetchRefs(ctx, gen.RefMode, collectRefs(values, gen), gen.log()); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("could not download the references of chart %s: %w", chart.Name, err))
+			return []Result{result}
+		}
+	}
+
+	generate := func(valuesPath string, values *yaml.Node) (*Schema, error) {
+		var schema *Schema
+		var err error
+		if gen.AllowMissingValues && isEmptyDocument(values) {
+			gen.log().Debugf("Generating a minimal schema for chart %s, because its values file %s is empty", chart.Name, valuesPath)
+			schema, err = minimalSchema(valuesPath, gen)
+		} else {
+			schema, err = yamlToSchema(valuesPath, values, gen, gen.skip, nil, nil)
 		}
-		content, err := util.ReadFileAndFixNewline(valuesFile)
 		if err != nil {
-			result.Errors = append(result.Errors, err)
-			results <- result
-			continue
+			return nil, fmt.Errorf("could not generate the schema of chart %s: %w", chart.Name, err)
 		}
+		return schema, nil
+	}
+
+	result.ValuesPath = valuesPaths[0]
+	if len(valuesPaths) > 1 && strategy != ValuesStrategySeparateSchemas {
+		result.ValuesFiles = valuesPaths
+		result.ValuesStrategy = strategy
+	}
 
-		// Check if we need to add a schema reference
-		if addSchemaReference {
-			schemaRef := `# yaml-language-server: $schema=values.schema.json`
-			if !strings.Contains(string(content), schemaRef) {
-				err = util.PrefixFirstYamlDocument(schemaRef, valuesPath)
-				if err != nil {
-					result.Errors = append(result.Errors, err)
-					results <- result
-					continue
+	switch strategy {
+	case ValuesStrategySeparateSchemas:
+		results := make([]Result, 0, len(valuesPaths))
+		for i, valuesPath := range valuesPaths {
+			separate := result
+			separate.ValuesPath = valuesPath
+			separate.Secondary = i > 0
+			separate.Warnings = nil
+			for _, warning := range result.Warnings {
+				if warning.File == valuesPath {
+					separate.Warnings = append(separate.Warnings, warning)
 				}
 			}
+			separate.Refs = collectRefs(documents[i], gen)
+			schema, err := generate(valuesPath, documents[i])
+			if err != nil {
+				separate.Errors = append(separate.Errors, err)
+			} else {
+				separate.Schema = *schema
+			}
+			results = append(results, separate)
 		}
-
-		// Optional preprocessing
-		if uncomment {
-			// Remove comments from valid yaml
-			content, err = util.RemoveCommentsFromYaml(bytes.NewReader(content))
+		for i := range results {
+			results[i].RefFiles = slices.Sorted(maps.Keys(gen.refFiles))
+		}
+		return results
+	case ValuesStrategyUnion:
+		for i, values := range documents {
+			result.Refs = append(result.Refs, collectRefs(values, gen)...)
+			schema, err := generate(valuesPaths[i], values)
 			if err != nil {
 				result.Errors = append(result.Errors, err)
-				results <- result
+				return []Result{result}
+			}
+			if i == 0 {
+				result.Schema = *schema
 				continue
 			}
+			unionSchemas(&result.Schema, schema)
 		}
-
-		var values yaml.Node
-		err = yaml.Unmarshal(content, &values)
+	default:
+		// relative references of all values files are resolved from the first one
+		values := mergeValuesDocuments(documents)
+		result.Refs = collectRefs(values, gen)
+		schema, err := generate(valuesPaths[0], values)
 		if err != nil {
 			result.Errors = append(result.Errors, err)
-			results <- result
-			continue
+			return []Result{result}
 		}
+		result.Schema = *schema
+	}
 
-		result.Schema = *YamlToSchema(valuesPath, &values, keepFullComment, helmDocsCompatibilityMode, dontRemoveHelmDocsPrefix, dontAddGlobal, skipAutoGenerationConfig, nil, nil)
+	result.RefFiles = slices.Sorted(maps.Keys(gen.refFiles))
+	return []Result{result}
+}
+
+// minimalSchema returns the schema of a values file without any keys
+func minimalSchema(valuesPath string, gen *generation) (*Schema, error) {
+	document := &yaml.Node{
+		Kind:    yaml.DocumentNode,
+		Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+	}
+	return yamlToSchema(valuesPath, document, gen, gen.skip, nil, nil)
+}
 
-		results <- result
+// isEmptyDocument checks if the parsed values file has no content (e.g. only comments) or is null
+func isEmptyDocument(node *yaml.Node) bool {
+	if node.Kind == 0 {
+		return true
 	}
+	return node.Kind == yaml.DocumentNode && len(node.Content) == 1 &&
+		node.Content[0].Kind == yaml.ScalarNode && node.Content[0].ShortTag() == nullTag
 }
@@ -1,10 +1,13 @@
 package schema
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/dadav/helm-schema/pkg/chart"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,7 +24,7 @@ func TestWorker(t *testing.T) {
 		helmDocsCompatibilityMode bool
 		dontRemoveHelmDocsPrefix  bool
 		dontAddGlobal             bool
-		skipAutoGenerationConfig  *SkipAutoGenerationConfig
+		skipAutoGeneration        []string
 		outFile                   string
 		expectedErrors            bool
 	}{
@@ -46,13 +49,8 @@ key2: value2
 			addSchemaReference:        true,
 			keepFullComment:           true,
 			helmDocsCompatibilityMode: true,
-			skipAutoGenerationConfig: &SkipAutoGenerationConfig{
-				Title:                false,
-				Description:          false,
-				Required:             false,
-				Default:              false,
-				AdditionalProperties: false,
-			},
+			skipAutoGeneration:        []string{"title", "arrays.items"},
+			outFile:                   DefaultOutputFile,
 		},
 		{
 			name: "missing values file",
@@ -66,13 +64,6 @@ version: 1.0.0
 			chartPath:      "Chart.yaml",
 			valueFileNames: []string{"values.yaml"},
 			expectedErrors: true,
-			skipAutoGenerationConfig: &SkipAutoGenerationConfig{
-				Title:                false,
-				Description:          false,
-				Required:             false,
-				Default:              false,
-				AdditionalProperties: false,
-			},
 		},
 		{
 			name: "invalid chart file",
@@ -88,13 +79,6 @@ key1: value1
 			chartPath:      "Chart.yaml",
 			valueFileNames: []string{"values.yaml"},
 			expectedErrors: true,
-			skipAutoGenerationConfig: &SkipAutoGenerationConfig{
-				Title:                false,
-				Description:          false,
-				Required:             false,
-				Default:              false,
-				AdditionalProperties: false,
-			},
 		},
 	}
 
@@ -112,26 +96,34 @@ key1: value1
 				assert.NoError(t, err)
 			}
 
-			// Setup channels
-			queue := make(chan string, 1)
-			results := make(chan Result, 1)
-
 			// Update chart path to use temp directory
 			fullChartPath := filepath.Join(tmpDir, tt.chartPath)
-			queue <- fullChartPath
+			c, err := chart.LoadChart(fullChartPath)
+			if err != nil {
+				assert.True(t, tt.expectedErrors, err)
+				return
+			}
+
+			// Setup channels
+			queue := make(chan chart.Chart, 1)
+			results := make(chan Result, 1)
+			queue <- c
 			close(queue)
 
 			// Run worker
 			Worker(
+				context.Background(),
 				tt.dryRun,
-				tt.uncomment,
-				tt.addSchemaReference,
-				tt.keepFullComment,
-				tt.helmDocsCompatibilityMode,
-				tt.dontRemoveHelmDocsPrefix,
-				tt.dontAddGlobal,
-				tt.valueFileNames,
-				tt.skipAutoGenerationConfig,
+				GenerationPolicy{
+					Uncomment:                 tt.uncomment,
+					AddSchemaReference:        tt.addSchemaReference,
+					KeepFullComment:           tt.keepFullComment,
+					HelmDocsCompatibilityMode: tt.helmDocsCompatibilityMode,
+					DontStripHelmDocsPrefix:   tt.dontRemoveHelmDocsPrefix,
+					DontAddGlobal:             tt.dontAddGlobal,
+					ValueFiles:                tt.valueFileNames,
+					SkipAutoGeneration:        tt.skipAutoGeneration,
+				},
 				tt.outFile,
 				queue,
 				results,
@@ -151,3 +143,198 @@ key1: value1
 		})
 	}
 }
+
+func TestWorkerSchemaReferenceOfOutputFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		outFile  string
+		expected string
+	}{
+		{name: "default", outFile: DefaultOutputFile, expected: "values.schema.json"},
+		{name: "custom file", outFile: "schemas/values.json", expected: "schemas/values.json"},
+		{name: "template", outFile: "{{ .ChartDir }}/../{{ .ChartName }}-{{ .ChartVersion }}.json", expected: "../test-chart-1.0.0.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			chartDir := filepath.Join(tmpDir, "chart")
+			assert.NoError(t, os.MkdirAll(chartDir, 0o755))
+			assert.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"), 0o644))
+			assert.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("key: value\n"), 0o644))
+
+			c, err := chart.LoadChart(filepath.Join(chartDir, "Chart.yaml"))
+			assert.NoError(t, err)
+			queue := make(chan chart.Chart, 1)
+			results := make(chan Result, 1)
+			queue <- c
+			close(queue)
+
+			Worker(context.Background(), false, GenerationPolicy{AddSchemaReference: true, ValueFiles: []string{"values.yaml"}}, tt.outFile, queue, results)
+			result := <-results
+			assert.Empty(t, result.Errors)
+
+			content, err := os.ReadFile(filepath.Join(chartDir, "values.yaml"))
+			assert.NoError(t, err)
+			assert.Contains(t, string(content), "# yaml-language-server: $schema="+tt.expected+"\n")
+		})
+	}
+}
+
+func TestWorkerStopsWhenCancelled(t *testing.T) {
+	tmpDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "Chart.yaml"), []byte("apiVersion: v2\nname: test\nversion: 1.0.0\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("key: value\n"), 0o644))
+	c, err := chart.LoadChart(filepath.Join(tmpDir, "Chart.yaml"))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the queue is never closed, the worker has to return because of the context
+	queue := make(chan chart.Chart)
+	results := make(chan Result)
+	Worker(ctx, false, GenerationPolicy{}, "", queue, results)
+
+	result := generateResult(ctx, c.Path, DefaultOutputFile, testGeneration(t, GenerationPolicy{}))
+	assert.ErrorIs(t, errors.Join(result.Errors...), context.Canceled)
+}
+
+func TestWorkerSharedRefsAreIndependent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sharedSchema := `{
+  "$defs": {
+    "port": {"type": "integer", "minimum": 1}
+  },
+  "type": "object",
+  "properties": {
+    "host": {"type": "string"}
+  }
+}`
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "shared.json"), []byte(sharedSchema), 0o644))
+
+	charts := []string{"a", "b", "c", "d"}
+	queue := make(chan chart.Chart, len(charts))
+	results := make(chan Result, len(charts))
+
+	for _, name := range charts {
+		chartDir := filepath.Join(tmpDir, name)
+		assert.NoError(t, os.MkdirAll(chartDir, 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("apiVersion: v2\nname: "+name+"\nversion: 1.0.0\n"), 0o644))
+		assert.NoError(t, os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(`# @schema
+# $ref: ../shared.json
+# @schema
+server: {}
+# @schema
+# $ref: ../shared.json#/$defs/port
+# @schema
+port: 80
+`), 0o644))
+		c, err := chart.LoadChart(filepath.Join(chartDir, "Chart.yaml"))
+		assert.NoError(t, err)
+		queue <- c
+	}
+	close(queue)
+
+	done := make(chan struct{})
+	for range charts {
+		go func() {
+			Worker(context.Background(), false, GenerationPolicy{}, "", queue, results)
+			done <- struct{}{}
+		}()
+	}
+	for range charts {
+		<-done
+	}
+	close(results)
+
+	var collected []Result
+	for result := range results {
+		assert.Empty(t, result.Errors)
+		collected = append(collected, result)
+	}
+	assert.Len(t, collected, len(charts))
+
+	// modifying the resolved schemas of one chart must not affect the others
+	collected[0].Schema.Properties["server"].Properties["host"].Type = StringOrArrayOfString{"integer"}
+	collected[0].Schema.Defs["port"].Type = StringOrArrayOfString{"string"}
+
+	for _, result := range collected[1:] {
+		assert.Equal(t, StringOrArrayOfString{"string"}, result.Schema.Properties["server"].Properties["host"].Type)
+		assert.Equal(t, StringOrArrayOfString{"integer"}, result.Schema.Defs["port"].Type)
+	}
+}
+
+func TestWorkerStripHelmDocsPrefixAnnotation(t *testing.T) {
+	tests := []struct {
+		name                string
+		annotation          string
+		expectedDescription string
+		expectedErrors      bool
+	}{
+		{name: "no annotation", expectedDescription: "The value"},
+		{name: "strip", annotation: "true", expectedDescription: "The value"},
+		{name: "dont strip", annotation: "false", expectedDescription: "-- The value"},
+		{name: "invalid", annotation: "sometimes", expectedErrors: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			chartFile := "apiVersion: v2\nname: test-chart\nversion: 1.0.0\n"
+			if tt.annotation != "" {
+				chartFile += "annotations:\n  " + StripHelmDocsPrefixAnnotation + ": \"" + tt.annotation + "\"\n"
+			}
+			assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "Chart.yaml"), []byte(chartFile), 0o644))
+			assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte("# -- The value\nkey: value\n"), 0o644))
+
+			result := generateResult(context.Background(), filepath.Join(tmpDir, "Chart.yaml"), DefaultOutputFile, testGeneration(t, GenerationPolicy{}))
+			if tt.expectedErrors {
+				assert.NotEmpty(t, result.Errors)
+				return
+			}
+			assert.Empty(t, result.Errors)
+			assert.Equal(t, tt.expectedDescription, result.Schema.Properties["key"].Description)
+		})
+	}
+}
+
+func TestGenerateResultWithoutValues(t *testing.T) {
+	tests := []struct {
+		name           string
+		values         string
+		missing        bool
+		allowMissing   bool
+		dontAddGlobal  bool
+		expectedErrors bool
+	}{
+		{name: "missing values file", missing: true, expectedErrors: true},
+		{name: "missing values file allowed", missing: true, allowMissing: true},
+		{name: "missing values file without global", missing: true, allowMissing: true, dontAddGlobal: true},
+		{name: "empty values file", allowMissing: true},
+		{name: "values file with comments only", values: "# nothing to configure\n", allowMissing: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "Chart.yaml"), []byte("apiVersion: v2\nname: library\nversion: 1.0.0\ntype: library\n"), 0o644))
+			if !tt.missing {
+				assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, "values.yaml"), []byte(tt.values), 0o644))
+			}
+
+			result := generateResult(context.Background(), filepath.Join(tmpDir, "Chart.yaml"), DefaultOutputFile, testGeneration(t, GenerationPolicy{DontAddGlobal: tt.dontAddGlobal, AllowMissingValues: tt.allowMissing}))
+			if tt.expectedErrors {
+				assert.NotEmpty(t, result.Errors)
+				return
+			}
+			assert.Empty(t, result.Errors)
+			assert.Equal(t, tt.missing, result.ValuesPath == "")
+			assert.Equal(t, StringOrArrayOfString{"object"}, result.Schema.Type)
+			assert.Equal(t, false, *result.Schema.AdditionalProperties.(*bool))
+			_, hasGlobal := result.Schema.Properties["global"]
+			assert.Equal(t, !tt.dontAddGlobal, hasGlobal)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Package urlpolicy decides which urls referenced schemas may be downloaded from. Values
+// files may come from untrusted contributors, so a $ref must not make helm-schema request
+// internal services, e.g. the metadata endpoints of cloud providers.
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultAllowedSchemes are the schemes references may use, if no others are configured
+var DefaultAllowedSchemes = []string{"https", "http", "ssh"}
+
+// metadataHosts are the hostnames of the metadata endpoints of cloud providers
+var metadataHosts = []string{"metadata", "metadata.google.internal", "metadata.azure.internal"}
+
+// metadataAddrs are the metadata endpoints of cloud providers, which aren't link-local
+var metadataAddrs = []netip.Addr{
+	netip.MustParseAddr("fd00:ec2::254"),
+	netip.MustParseAddr("100.100.100.200"),
+}
+
+// lookupIPAddr resolves the hostnames, it is replaced in the tests
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Policy restricts the urls of references. The zero value allows the default schemes and
+// all hosts, except link-local addresses and metadata endpoints.
+type Policy struct {
+	// AllowedSchemes are the allowed schemes (DefaultAllowedSchemes if empty)
+	AllowedSchemes []string
+	// AllowedHosts are the allowed hosts (all if empty). Entries are hostnames, which can
+	// contain wildcards (e.g. *.example.com), or ip addresses and networks (e.g. 10.0.0.0/8).
+	AllowedHosts []string
+	// DeniedHosts are the denied hosts, they take precedence over the allowed hosts.
+	// The entries are like the ones of AllowedHosts.
+	DeniedHosts []string
+	// AllowLinkLocal allows link-local addresses and the metadata endpoints of cloud providers
+	AllowLinkLocal bool
+}
+
+// Validate checks the host patterns of the policy
+func (p *Policy) Validate() error {
+	errs := []error{}
+	for _, pattern := range slices.Concat(p.AllowedHosts, p.DeniedHosts) {
+		if strings.Contains(pattern, "/") {
+			if _, err := netip.ParsePrefix(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("invalid network %s: %w", pattern, err))
+			}
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid host pattern %s: %w", pattern, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Schemes returns the allowed schemes
+func (p *Policy) Schemes() []string {
+	if len(p.AllowedSchemes) == 0 {
+		return DefaultAllowedSchemes
+	}
+	return p.AllowedSchemes
+}
+
+// Check returns an error if the url is not allowed. The hostname is resolved,
+// so a hostname pointing to a denied address is denied as well.
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return p.CheckURL(ctx, u)
+}
+
+// CheckURL is Check for a parsed url
+func (p *Policy) CheckURL(ctx context.Context, u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(p.Schemes(), scheme) {
+		return fmt.Errorf("the scheme %s of %s is not allowed (allowed: %s)", scheme, u.Redacted(), strings.Join(p.Schemes(), ", "))
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		// e.g. file:///path, there is no host which could be requested
+		return nil
+	}
+
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr.Unmap())
+	} else if resolved, err := lookupIPAddr(ctx, host); err == nil {
+		// unresolvable hosts can't be requested, the download reports that
+		for _, ip := range resolved {
+			if addr, ok := netip.AddrFromSlice(ip.IP); ok {
+				addrs = append(addrs, addr.Unmap())
+			}
+		}
+	}
+
+	return p.checkHost(host, u.Redacted(), addrs)
+}
+
+// checkHost returns an error if the host, which target (a url or a dialed address) requests,
+// or one of its addresses is not allowed
+func (p *Policy) checkHost(host, target string, addrs []netip.Addr) error {
+	if !p.AllowLinkLocal {
+		if slices.Contains(metadataHosts, host) {
+			return fmt.Errorf("the host %s of %s is a metadata endpoint", host, target)
+		}
+		for _, addr := range addrs {
+			if addr.IsLinkLocalUnicast() || addr.IsUnspecified() || slices.Contains(metadataAddrs, addr) {
+				return fmt.Errorf("the host %s of %s resolves to the link-local or metadata address %s", host, target, addr)
+			}
+		}
+	}
+
+	if matchHost(p.DeniedHosts, host, addrs) {
+		return fmt.Errorf("the host %s of %s is denied", host, target)
+	}
+	if len(p.AllowedHosts) > 0 && !matchHost(p.AllowedHosts, host, addrs) {
+		return fmt.Errorf("the host %s of %s is not allowed", host, target)
+	}
+	return nil
+}
+
+// Transport returns a transport, which checks the address of each connection when it is dialed.
+// CheckURL resolves the host before the request, the transport resolves it again, so a host
+// changing its address in between (dns rebinding) is refused here. Connections to the trusted
+// hosts (e.g. the configured url of a registry) and to proxies aren't checked, a proxy resolves
+// the hosts itself.
+func (p *Policy) Transport(trustedHosts ...string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	trusted := make([]string, 0, len(trustedHosts))
+	for _, host := range trustedHosts {
+		trusted = append(trusted, strings.ToLower(strings.TrimSuffix(host, ".")))
+	}
+
+	var proxies sync.Map
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := http.ProxyFromEnvironment(req)
+		if proxyURL != nil {
+			proxies.Store(proxyAddr(proxyURL), true)
+		}
+		return proxyURL, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if _, ok := proxies.Load(address); ok || slices.Contains(trusted, host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		checked := *dialer
+		checked.Control = func(_, dialed string, _ syscall.RawConn) error {
+			ip, _, err := net.SplitHostPort(dialed)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return err
+			}
+			return p.checkHost(host, dialed, []netip.Addr{addr.Unmap()})
+		}
+		return checked.DialContext(ctx, network, address)
+	}
+	return transport
+}
+
+// proxyAddr returns the address the transport dials to connect to the proxy
+func proxyAddr(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// CheckRedirect can be used as http.Client.CheckRedirect, it refuses redirects to urls
+// which aren't allowed
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if err := p.CheckURL(req.Context(), req.URL); err != nil {
+		return fmt.Errorf("refused redirect: %w", err)
+	}
+	return nil
+}
+
+// matchHost checks if the hostname or one of its addresses matches one of the patterns
+func matchHost(patterns []string, host string, addrs []netip.Addr) bool {
+	for _, pattern := range patterns {
+		if prefix, err := netip.ParsePrefix(pattern); err == nil {
+			for _, addr := range addrs {
+				if prefix.Contains(addr) {
+					return true
+				}
+			}
+			continue
+		}
+
+		pattern = strings.ToLower(pattern)
+		if addr, err := netip.ParseAddr(pattern); err == nil {
+			if slices.Contains(addrs, addr.Unmap()) {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
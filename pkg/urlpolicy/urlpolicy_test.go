@@ -0,0 +1,124 @@
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	defer func(lookup func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddr = lookup }(lookupIPAddr)
+	lookupIPAddr = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "github.com":
+			return []net.IPAddr{{IP: net.ParseIP("140.82.121.3")}}, nil
+		case "internal.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("10.1.2.3")}}, nil
+		case "rebind.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("169.254.169.254")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		url     string
+		allowed bool
+	}{
+		{"https", Policy{}, "https://github.com/org/repo.git", true},
+		{"ssh", Policy{}, "ssh://git@github.com/org/repo.git", true},
+		{"file", Policy{}, "file:///tmp/repo", false},
+		{"allowed file", Policy{AllowedSchemes: []string{"file"}}, "file:///tmp/repo", true},
+		{"metadata address", Policy{}, "http://169.254.169.254/latest", false},
+		{"link-local ipv6", Policy{}, "http://[fe80::1]/repo.git", false},
+		{"aws ipv6 metadata", Policy{}, "http://[fd00:ec2::254]/repo.git", false},
+		{"metadata host", Policy{}, "http://metadata.google.internal/repo.git", false},
+		{"resolves to metadata", Policy{}, "https://rebind.example.com/repo.git", false},
+		{"allowed link-local", Policy{AllowLinkLocal: true}, "http://169.254.169.254/latest", true},
+		{"allowed host", Policy{AllowedHosts: []string{"*.example.com"}}, "https://internal.example.com/repo.git", true},
+		{"not allowed host", Policy{AllowedHosts: []string{"*.example.com"}}, "https://github.com/org/repo.git", false},
+		{"denied host", Policy{DeniedHosts: []string{"github.com"}}, "https://GitHub.com/org/repo.git", false},
+		{"denied network", Policy{DeniedHosts: []string{"10.0.0.0/8"}}, "https://internal.example.com/repo.git", false},
+		{"denied address", Policy{DeniedHosts: []string{"10.1.2.3"}}, "https://10.1.2.3/repo.git", false},
+		{"denied before allowed", Policy{AllowedHosts: []string{"*.example.com"}, DeniedHosts: []string{"10.0.0.0/8"}}, "https://internal.example.com/repo.git", false},
+		{"unresolvable host", Policy{}, "https://unknown.example.org/repo.git", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(context.Background(), tt.url)
+			if tt.allowed && err != nil {
+				t.Errorf("Was expecting %s to be allowed, but got %v", tt.url, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("Was expecting %s to be refused", tt.url)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	if err := (&Policy{AllowedHosts: []string{"*.example.com", "10.0.0.0/8"}}).Validate(); err != nil {
+		t.Errorf("Was expecting a valid policy, but got %v", err)
+	}
+	for _, pattern := range []string{"10.0.0.0/33", "[example.com"} {
+		if err := (&Policy{DeniedHosts: []string{pattern}}).Validate(); err == nil {
+			t.Errorf("Was expecting an error for %s", pattern)
+		}
+	}
+}
+
+func TestPolicyCheckRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer server.Close()
+
+	policy := &Policy{}
+	client := &http.Client{CheckRedirect: policy.CheckRedirect}
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Was expecting the redirect to be refused")
+	}
+}
+
+func TestPolicyTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebindURL := "http://localhost:" + serverURL.Port()
+
+	// the host resolves to a public address when it is checked and to loopback when it is dialed
+	defer func(lookup func(context.Context, string) ([]net.IPAddr, error)) { lookupIPAddr = lookup }(lookupIPAddr)
+	lookupIPAddr = func(context.Context, string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("140.82.121.3")}}, nil
+	}
+	policy := &Policy{DeniedHosts: []string{"127.0.0.0/8", "::1/128"}}
+	if err := policy.Check(context.Background(), rebindURL); err != nil {
+		t.Fatalf("Was expecting the url to pass the check, but got %v", err)
+	}
+
+	client := &http.Client{Transport: policy.Transport()}
+	resp, err := client.Get(rebindURL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Was expecting the connection to be refused")
+	}
+
+	client = &http.Client{Transport: policy.Transport("LOCALHOST")}
+	resp, err = client.Get(rebindURL)
+	if err != nil {
+		t.Fatalf("Was expecting the trusted host to be dialed, but got %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,111 @@
+// Package urlref downloads schemas referenced by http(s) urls, e.g.
+// https://example.com/schemas/common.json, so they can be inlined into the generated schemas.
+// The downloads are checked with a urlpolicy.Policy and cached in memory.
+package urlref
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dadav/helm-schema/pkg/refmetrics"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+	"github.com/dadav/helm-schema/pkg/util"
+)
+
+// IsRef checks if the reference (without json pointer) is a http or https url
+func IsRef(ref string) bool {
+	lower := strings.ToLower(ref)
+	return strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")
+}
+
+// Fetcher downloads the referenced schemas. Each url is only downloaded once.
+// It is safe to use a fetcher concurrently.
+type Fetcher struct {
+	httpClient *http.Client
+	policy     *urlpolicy.Policy
+	maxSize    int64
+	metrics    *refmetrics.Metrics
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// NewFetcher returns a fetcher, the urls are restricted by the zero urlpolicy.Policy (see SetPolicy)
+func NewFetcher() *Fetcher {
+	f := &Fetcher{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxSize:    util.DefaultMaxDownloadSize,
+		cache:      make(map[string][]byte),
+	}
+	f.SetPolicy(&urlpolicy.Policy{})
+	return f
+}
+
+// SetPolicy sets the policy the urls (and their redirects) are checked with, the addresses are
+// checked again when they are dialed. It must not be called while fetching.
+func (f *Fetcher) SetPolicy(policy *urlpolicy.Policy) {
+	f.policy = policy
+	f.httpClient.CheckRedirect = policy.CheckRedirect
+	f.httpClient.Transport = policy.Transport()
+}
+
+// SetMaxSize sets the maximum size in bytes of downloaded schemas (util.DefaultMaxDownloadSize if
+// size is not positive). It must not be called while fetching.
+func (f *Fetcher) SetMaxSize(size int64) {
+	if size <= 0 {
+		size = util.DefaultMaxDownloadSize
+	}
+	f.maxSize = size
+}
+
+// SetMetrics sets the metrics the cache hits and the downloads are counted in (none if nil).
+// It must not be called while fetching.
+func (f *Fetcher) SetMetrics(metrics *refmetrics.Metrics) {
+	f.metrics = metrics
+}
+
+// FetchContext returns the schema at the url (without json pointer)
+func (f *Fetcher) FetchContext(ctx context.Context, schemaURL string) ([]byte, error) {
+	f.mu.Lock()
+	cached, ok := f.cache[schemaURL]
+	f.mu.Unlock()
+	if ok {
+		f.metrics.Hit(refmetrics.SourceURL)
+		return cached, nil
+	}
+
+	if err := f.policy.Check(ctx, schemaURL); err != nil {
+		return nil, fmt.Errorf("could not download %s: %w", schemaURL, err)
+	}
+	start := time.Now()
+	data, err := f.download(ctx, schemaURL)
+	f.metrics.Download(refmetrics.SourceURL, schemaURL, len(data), time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.cache[schemaURL] = data
+	f.mu.Unlock()
+	return data, nil
+}
+
+// download downloads the schema at the url and checks it
+func (f *Fetcher) download(ctx context.Context, schemaURL string) ([]byte, error) {
+	data, header, err := util.Download(ctx, f.httpClient, schemaURL, "", util.Credentials{}, f.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mediaType == "text/html" {
+		return nil, fmt.Errorf("downloaded a HTML error page from %s instead of a schema", schemaURL)
+	}
+	if err := util.CheckDownloadedSchema(schemaURL, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
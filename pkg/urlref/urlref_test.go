@@ -0,0 +1,79 @@
+package urlref
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dadav/helm-schema/pkg/refmetrics"
+	"github.com/dadav/helm-schema/pkg/urlpolicy"
+)
+
+func TestIsRef(t *testing.T) {
+	for ref, expected := range map[string]bool{
+		"https://example.com/schema.json":                 true,
+		"HTTP://example.com/schema.json":                  true,
+		"file:///schema.json":                             false,
+		"schema.json":                                     false,
+		"git+https://example.com/repo.git@v1/schema.json": false,
+	} {
+		if IsRef(ref) != expected {
+			t.Errorf("Was expecting IsRef(%s) to be %t", ref, expected)
+		}
+	}
+}
+
+func TestFetchContext(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/schema.json":
+			_, _ = w.Write([]byte(`{"type": "string"}`))
+		case "/large.json":
+			_, _ = w.Write([]byte(`{"description": "` + strings.Repeat("x", 100) + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := NewFetcher()
+	metrics := refmetrics.New()
+	f.SetMetrics(metrics)
+	for i := 0; i < 2; i++ {
+		data, err := f.FetchContext(context.Background(), server.URL+"/schema.json")
+		if err != nil {
+			t.Fatalf("Wasn't expecting an error, but got this: %v", err)
+		}
+		if string(data) != `{"type": "string"}` {
+			t.Errorf("Was expecting the schema, but got %s", data)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Was expecting the schema to be cached, but it was requested %d times", requests)
+	}
+
+	if _, err := f.FetchContext(context.Background(), server.URL+"/missing.json"); err == nil {
+		t.Error("Was expecting an error for a missing schema")
+	}
+	snapshot := metrics.Snapshot()
+	if stats := snapshot.Sources[refmetrics.SourceURL]; stats.Hits != 1 || stats.Misses != 2 || stats.BytesDownloaded != 18 {
+		t.Errorf("Was expecting a hit and two downloads, but got %+v", stats)
+	}
+	if stats := snapshot.Hosts[refmetrics.Host(server.URL)]; stats.Downloads != 2 || stats.Errors != 1 {
+		t.Errorf("Was expecting the failed download of the host, but got %+v", stats)
+	}
+
+	f.SetMaxSize(10)
+	if _, err := f.FetchContext(context.Background(), server.URL+"/large.json"); err == nil {
+		t.Error("Was expecting an error for a schema exceeding the size limit")
+	}
+
+	f.SetPolicy(&urlpolicy.Policy{DeniedHosts: []string{"127.0.0.1"}})
+	if _, err := f.FetchContext(context.Background(), server.URL+"/other.json"); err == nil {
+		t.Error("Was expecting an error for a denied host")
+	}
+}
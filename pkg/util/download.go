@@ -0,0 +1,101 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxDownloadSize is the default limit of the size of downloaded schemas (10 MiB)
+const DefaultMaxDownloadSize = 10 << 20
+
+// Credentials authenticate a download with a bearer token or, if there is none,
+// with the username and password (basic auth). Empty credentials send nothing.
+type Credentials struct {
+	Username, Password string
+	Token              string
+}
+
+// Apply sets the authorization header of the request
+func (c Credentials) Apply(req *http.Request) {
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// StatusError is returned by Download if the response doesn't have the status 200
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// Header is the header of the response, e.g. with the authentication challenge
+	Header http.Header
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("could not download %s: %s", e.URL, e.Status)
+}
+
+// Download gets the url with the client and returns the body and header of the response.
+// The request is authenticated with the credentials and accepts the media type, if it isn't
+// empty. Responses with another status than 200 are returned as *StatusError and bodies with
+// more than maxSize bytes are refused without reading them completely.
+func Download(ctx context.Context, client *http.Client, url, accept string, credentials Credentials, maxSize int64) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	credentials.Apply(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.Header, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+	}
+	if resp.ContentLength > maxSize {
+		return nil, resp.Header, fmt.Errorf("%s has %d bytes, more than the limit of %d bytes", url, resp.ContentLength, maxSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, resp.Header, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, resp.Header, fmt.Errorf("%s has more than the limit of %d bytes", url, maxSize)
+	}
+	return data, resp.Header, nil
+}
+
+// CheckDownloadedSchema returns an error if the data downloaded from source is not a json
+// schema. Error and login pages (e.g. of proxies) are reported as such instead of as
+// failure to parse them.
+func CheckDownloadedSchema(source string, data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return fmt.Errorf("downloaded an empty file from %s instead of a schema", source)
+	case trimmed[0] == '<':
+		return fmt.Errorf("downloaded a HTML error page from %s instead of a schema", source)
+	}
+
+	var schema any
+	if err := json.Unmarshal(trimmed, &schema); err != nil {
+		return fmt.Errorf("downloaded invalid JSON from %s: %w", source, err)
+	}
+	if _, ok := schema.(map[string]any); !ok {
+		return fmt.Errorf("downloaded JSON from %s is not a schema object", source)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckDownloadedSchema(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{data: `{"type": "object"}`},
+		{data: "\n  {}\n"},
+		{data: "", expected: "empty file"},
+		{data: "<!DOCTYPE html><html><body>Sign in</body></html>", expected: "HTML error page"},
+		{data: `{"type": `, expected: "invalid JSON"},
+		{data: `["object"]`, expected: "not a schema object"},
+	}
+	for _, test := range tests {
+		err := CheckDownloadedSchema("https://example.com/schema.json", []byte(test.data))
+		if test.expected == "" {
+			if err != nil {
+				t.Errorf("Wasn't expecting an error for %q, but got this: %v", test.data, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.expected) || !strings.Contains(err.Error(), "https://example.com/schema.json") {
+			t.Errorf("Was expecting an error about %s for %q, but got %v", test.expected, test.data, err)
+		}
+	}
+}
+
+func TestDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="test"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/basic":
+			if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "/accept":
+			if r.Header.Get("Accept") != "application/json" {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+		case "/large":
+			_, _ = w.Write([]byte(strings.Repeat("x", 20)))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		path        string
+		accept      string
+		credentials Credentials
+		status      int
+		expected    string
+	}{
+		{path: "/token", credentials: Credentials{Token: "secret"}},
+		{path: "/token", status: http.StatusUnauthorized},
+		{path: "/basic", credentials: Credentials{Username: "user", Password: "pass"}},
+		{path: "/accept", accept: "application/json"},
+		{path: "/accept", status: http.StatusNotAcceptable},
+		{path: "/large", expected: "more than the limit of 10 bytes"},
+	}
+	for _, test := range tests {
+		data, header, err := Download(ctx, server.Client(), server.URL+test.path, test.accept, test.credentials, 10)
+		var statusErr *StatusError
+		switch {
+		case test.status != 0:
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != test.status {
+				t.Errorf("Was expecting the status %d from %s, but got %v", test.status, test.path, err)
+			}
+			if test.status == http.StatusUnauthorized && test.path == "/token" && statusErr != nil && statusErr.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("Was expecting the challenge of %s in the error", test.path)
+			}
+		case test.expected != "":
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Was expecting an error about %s from %s, but got %v", test.expected, test.path, err)
+			}
+		default:
+			if err != nil || string(data) != "ok" || header == nil {
+				t.Errorf("Was expecting ok from %s, but got %q: %v", test.path, data, err)
+			}
+		}
+	}
+}
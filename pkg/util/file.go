@@ -3,9 +3,11 @@ package util
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
-	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -148,12 +150,107 @@ func RemoveCommentsFromYaml(reader io.Reader) ([]byte, error) {
 	return result, nil
 }
 
-// IsRelativeFile checks if the given string is a relative path to a file
+// windowsDriveMatcher matches paths starting with a windows drive letter, e.g. C:\ or C:/
+var windowsDriveMatcher = regexp.MustCompile(`^[A-Za-z]:([\\/]|$)`)
+
+// IsAbsolutePath checks if the path is absolute on any platform.
+// Besides the native absolute paths, this covers posix paths, windows drive paths and UNC paths.
+func IsAbsolutePath(p string) bool {
+	return filepath.IsAbs(p) ||
+		strings.HasPrefix(p, "/") ||
+		strings.HasPrefix(p, `\\`) ||
+		windowsDriveMatcher.MatchString(p)
+}
+
+// IsRelativeFile checks if the given string is a relative path to a file.
+// Both slashes and backslashes are accepted as separator.
 func IsRelativeFile(root, relPath string) (string, error) {
-	if !path.IsAbs(relPath) {
-		foo := path.Join(path.Dir(root), relPath)
+	if !IsAbsolutePath(relPath) {
+		foo := filepath.Join(filepath.Dir(root), filepath.FromSlash(strings.ReplaceAll(relPath, `\`, "/")))
 		_, err := os.Stat(foo)
 		return foo, err
 	}
 	return "", errors.New("Is absolute file")
 }
+
+// IsFileURL checks if the given string is a file:// URL
+func IsFileURL(ref string) bool {
+	return strings.HasPrefix(strings.ToLower(ref), "file://")
+}
+
+// FileURLToPath converts a file:// URL to a local path. Windows drive letters
+// (file:///C:/schemas/foo.json) and UNC paths (file://server/share/foo.json) are supported.
+func FileURLToPath(fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(u.Scheme, "file") {
+		return "", fmt.Errorf("not a file url: %s", fileURL)
+	}
+
+	p := u.Path
+	switch {
+	case u.Host == "" || strings.EqualFold(u.Host, "localhost"):
+		// file:///C:/foo has the path /C:/foo
+		if windowsDriveMatcher.MatchString(strings.TrimPrefix(p, "/")) {
+			p = strings.TrimPrefix(p, "/")
+		}
+	case windowsDriveMatcher.MatchString(u.Host):
+		// file://C:/foo is invalid, but common
+		p = u.Host + p
+	default:
+		// file://server/share/foo is the UNC path \\server\share\foo
+		p = "//" + u.Host + p
+	}
+
+	return filepath.FromSlash(p), nil
+}
+
+// PathToFileURL converts a local path to an absolute file:// URL (see FileURLToPath)
+func PathToFileURL(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	abs = filepath.ToSlash(abs)
+	// UNC paths (//server/share/foo) have a host
+	if host, rest, ok := strings.Cut(strings.TrimPrefix(abs, "//"), "/"); ok && strings.HasPrefix(abs, "//") {
+		return (&url.URL{Scheme: "file", Host: host, Path: "/" + rest}).String(), nil
+	}
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+	return (&url.URL{Scheme: "file", Path: abs}).String(), nil
+}
+
+// ResolveFileRef returns the path of the local file referenced by ref. The reference
+// is either a path relative to root or a file:// URL.
+func ResolveFileRef(root, ref string) (string, error) {
+	if ref == "" {
+		return "", errors.New("empty file reference")
+	}
+
+	var p string
+	var err error
+	if IsFileURL(ref) {
+		p, err = FileURLToPath(ref)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		p, err = IsRelativeFile(root, ref)
+		if err != nil {
+			return p, err
+		}
+	}
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return p, err
+	}
+	if info.IsDir() {
+		return p, fmt.Errorf("%s is a directory", p)
+	}
+	return p, nil
+}
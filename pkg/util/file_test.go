@@ -2,6 +2,10 @@ package util
 
 import (
 	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -35,3 +39,114 @@ func TestReadFileAndFixNewline(t *testing.T) {
 		}
 	}
 }
+
+func TestIsAbsolutePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		absolute bool
+	}{
+		{input: "schema.json", absolute: false},
+		{input: "schemas/schema.json", absolute: false},
+		{input: `schemas\schema.json`, absolute: false},
+		{input: "../schema.json", absolute: false},
+		{input: "/schemas/schema.json", absolute: true},
+		{input: `C:\schemas\schema.json`, absolute: true},
+		{input: "c:/schemas/schema.json", absolute: true},
+		{input: `\\server\share\schema.json`, absolute: true},
+		{input: "//server/share/schema.json", absolute: true},
+	}
+	for _, test := range tests {
+		if IsAbsolutePath(test.input) != test.absolute {
+			t.Errorf("Was expecting IsAbsolutePath(%s) to be %t", test.input, test.absolute)
+		}
+	}
+}
+
+func TestFileURLToPath(t *testing.T) {
+	tests := []struct {
+		input     string
+		output    string
+		expectErr bool
+	}{
+		{input: "file:///schemas/schema.json", output: "/schemas/schema.json"},
+		{input: "file://localhost/schemas/schema.json", output: "/schemas/schema.json"},
+		{input: "file:///C:/schemas/schema.json", output: "C:/schemas/schema.json"},
+		{input: "file://C:/schemas/schema.json", output: "C:/schemas/schema.json"},
+		{input: "file://server/share/schema.json", output: "//server/share/schema.json"},
+		{input: "file:///schemas/my%20schema.json", output: "/schemas/my schema.json"},
+		{input: "https://example.org/schema.json", expectErr: true},
+	}
+	for _, test := range tests {
+		output, err := FileURLToPath(test.input)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("Was expecting an error for %s", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Wasn't expecting an error, but got this: %v", err)
+		}
+		if output != filepath.FromSlash(test.output) {
+			t.Errorf("Was expecting %s, but got %s", filepath.FromSlash(test.output), output)
+		}
+	}
+}
+
+func TestPathToFileURL(t *testing.T) {
+	for _, input := range []string{"/schemas/schema.json", "/schemas/my schema.json", "schemas/schema.json"} {
+		fileURL, err := PathToFileURL(filepath.FromSlash(input))
+		if err != nil {
+			t.Errorf("Wasn't expecting an error, but got this: %v", err)
+			continue
+		}
+		if !strings.HasPrefix(fileURL, "file:///") {
+			t.Errorf("Was expecting an absolute file url, but got %s", fileURL)
+		}
+		expected, _ := filepath.Abs(filepath.FromSlash(input))
+		if output, err := FileURLToPath(fileURL); err != nil || output != expected {
+			t.Errorf("Was expecting %s to convert back to %s, but got %s (%v)", fileURL, expected, output, err)
+		}
+	}
+}
+
+func TestResolveFileRef(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "schemas"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	schemaPath := filepath.Join(tmpDir, "schemas", "schema.json")
+	if err := os.WriteFile(schemaPath, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	valuesPath := filepath.Join(tmpDir, "values.yaml")
+
+	tests := []struct {
+		ref       string
+		expectErr bool
+	}{
+		{ref: "schemas/schema.json"},
+		{ref: `schemas\schema.json`},
+		{ref: "./schemas/../schemas/schema.json"},
+		{ref: (&url.URL{Scheme: "file", Path: filepath.ToSlash(schemaPath)}).String()},
+		{ref: "schemas/missing.json", expectErr: true},
+		{ref: schemaPath, expectErr: true},
+		{ref: "", expectErr: true},
+		{ref: "schemas", expectErr: true},
+	}
+	for _, test := range tests {
+		output, err := ResolveFileRef(valuesPath, test.ref)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("Was expecting an error for %s", test.ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Wasn't expecting an error for %s, but got this: %v", test.ref, err)
+		}
+		if output != schemaPath {
+			t.Errorf("Was expecting %s, but got %s", schemaPath, output)
+		}
+	}
+}
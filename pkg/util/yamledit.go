@@ -0,0 +1,364 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YamlEditor modifies a yaml file without re-encoding it. The edits are applied to the
+// original text at the positions of the parsed nodes, so indentation, quoting styles,
+// blank lines and comments of everything that isn't edited survive unchanged.
+// Paths are the keys of the nested maps, sequence items are addressed by their index.
+type YamlEditor struct {
+	source     []byte
+	crlf       bool
+	lineStarts []int
+	root       *yaml.Node
+	edits      []textEdit
+}
+
+// textEdit replaces source[start:end] with text
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// NewYamlEditor parses the yaml content. Only the first document is editable.
+func NewYamlEditor(content []byte) (*YamlEditor, error) {
+	e := &YamlEditor{
+		source: bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")),
+		crlf:   bytes.Contains(content, []byte("\r\n")),
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(e.source, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, errors.New("empty yaml document")
+	}
+	e.root = doc.Content[0]
+
+	e.lineStarts = []int{0}
+	for i, c := range e.source {
+		if c == '\n' {
+			e.lineStarts = append(e.lineStarts, i+1)
+		}
+	}
+
+	return e, nil
+}
+
+// ReadYamlEditor reads the yaml file at the given path into a YamlEditor
+func ReadYamlEditor(path string) (*YamlEditor, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewYamlEditor(content)
+}
+
+// Root returns the root node of the document. It must not be modified,
+// use the methods of the editor instead.
+func (e *YamlEditor) Root() *yaml.Node {
+	return e.root
+}
+
+// Lookup returns the key and value node at the given path.
+// The key is nil for sequence items and the root.
+func (e *YamlEditor) Lookup(path ...string) (*yaml.Node, *yaml.Node, error) {
+	var key *yaml.Node
+	current := e.root
+
+	for i, segment := range path {
+		switch current.Kind {
+		case yaml.MappingNode:
+			found := false
+			for j := 0; j+1 < len(current.Content); j += 2 {
+				if current.Content[j].Value == segment {
+					key, current = current.Content[j], current.Content[j+1]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, nil, fmt.Errorf("could not find %s", strings.Join(path[:i+1], "."))
+			}
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(current.Content) {
+				return nil, nil, fmt.Errorf("invalid index %s in %s", segment, strings.Join(path[:i+1], "."))
+			}
+			key, current = nil, current.Content[index]
+		default:
+			return nil, nil, fmt.Errorf("%s is neither a map nor a list", strings.Join(path[:i], "."))
+		}
+	}
+
+	return key, current, nil
+}
+
+// RenameKey renames the last key of the path. The quoting style of the key is kept if possible.
+func (e *YamlEditor) RenameKey(path []string, newName string) error {
+	if len(path) == 0 {
+		return errors.New("cannot rename the root")
+	}
+	key, _, err := e.Lookup(path...)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("%s is not a key", strings.Join(path, "."))
+	}
+
+	_, parent, _ := e.Lookup(path[:len(path)-1]...)
+	for i := 0; i < len(parent.Content); i += 2 {
+		if parent.Content[i] != key && parent.Content[i].Value == newName {
+			return fmt.Errorf("key %s already exists", newName)
+		}
+	}
+
+	return e.replaceScalar(key, newName)
+}
+
+// SetScalar replaces the scalar value at the given path. The quoting style of the value is
+// kept if possible. A plain value which would change its type (e.g. "true") is quoted.
+func (e *YamlEditor) SetScalar(path []string, value string) error {
+	_, node, err := e.Lookup(path...)
+	if err != nil {
+		return err
+	}
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("%s is not a scalar", strings.Join(path, "."))
+	}
+	return e.replaceScalar(node, value)
+}
+
+// HeadComment returns the comment lines directly above the key of the path (the lines
+// replaced by SetHeadComment) without the leading "#" and the space following it.
+func (e *YamlEditor) HeadComment(path []string) (string, error) {
+	first, keyLine, _, err := e.headComment(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, keyLine-first)
+	for i := first; i < keyLine; i++ {
+		line := strings.TrimPrefix(strings.TrimSpace(string(e.line(i))), "#")
+		lines = append(lines, strings.TrimPrefix(line, " "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// SetHeadComment replaces the comment lines directly above the key of the path with the
+// given comment. Every line of the comment is prefixed with "# " and indented like the key.
+// An empty comment removes the existing comment lines.
+func (e *YamlEditor) SetHeadComment(path []string, comment string) error {
+	first, keyLine, indent, err := e.headComment(path)
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			if line == "" {
+				text.WriteString(indent + "#\n")
+			} else {
+				text.WriteString(indent + "# " + line + "\n")
+			}
+		}
+	}
+
+	e.edits = append(e.edits, textEdit{start: e.lineStarts[first], end: e.lineStarts[keyLine], text: text.String()})
+	return nil
+}
+
+// Bytes returns the edited content. The result is parsed again to make sure the edits
+// produced valid yaml.
+func (e *YamlEditor) Bytes() ([]byte, error) {
+	edits := make([]textEdit, len(e.edits))
+	copy(edits, e.edits)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		if edit.start < last {
+			return nil, errors.New("overlapping yaml edits")
+		}
+		out.Write(e.source[last:edit.start])
+		out.WriteString(edit.text)
+		last = edit.end
+	}
+	out.Write(e.source[last:])
+
+	var check yaml.Node
+	if err := yaml.Unmarshal(out.Bytes(), &check); err != nil {
+		return nil, fmt.Errorf("edits produced invalid yaml: %w", err)
+	}
+
+	if e.crlf {
+		return bytes.ReplaceAll(out.Bytes(), []byte("\n"), []byte("\r\n")), nil
+	}
+	return out.Bytes(), nil
+}
+
+// WriteFile writes the edited content to the given file, keeping its permissions
+func (e *YamlEditor) WriteFile(path string) error {
+	content, err := e.Bytes()
+	if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return os.WriteFile(path, content, perm)
+}
+
+// headComment returns the zero based lines of the comment directly above the key of the
+// path (first up to keyLine, exclusive) and the indentation of the key
+func (e *YamlEditor) headComment(path []string) (first, keyLine int, indent string, err error) {
+	key, _, err := e.Lookup(path...)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if key == nil {
+		return 0, 0, "", fmt.Errorf("%s is not a key", strings.Join(path, "."))
+	}
+
+	keyLine = key.Line - 1
+	indent = string(e.line(keyLine)[:e.offset(key.Line, key.Column)-e.lineStarts[keyLine]])
+	if strings.TrimSpace(indent) != "" {
+		// e.g. the first key of a map in a list ("- key: value")
+		return 0, 0, "", fmt.Errorf("cannot edit the comment of %s, it doesn't start its line", strings.Join(path, "."))
+	}
+
+	first = keyLine
+	for first > 0 && strings.HasPrefix(strings.TrimSpace(string(e.line(first-1))), "#") {
+		first--
+	}
+	return first, keyLine, indent, nil
+}
+
+// line returns the content of the zero based line without the newline
+func (e *YamlEditor) line(index int) []byte {
+	end := len(e.source)
+	if index+1 < len(e.lineStarts) {
+		end = e.lineStarts[index+1] - 1
+	}
+	return e.source[e.lineStarts[index]:end]
+}
+
+// offset converts the one based line and column (in characters) of a node to a byte offset
+func (e *YamlEditor) offset(line, column int) int {
+	start := e.lineStarts[line-1]
+	text := e.line(line - 1)
+	pos := 0
+	for i := 1; i < column && pos < len(text); i++ {
+		_, size := utf8.DecodeRune(text[pos:])
+		pos += size
+	}
+	return start + pos
+}
+
+// replaceScalar replaces the text of a single line scalar node
+func (e *YamlEditor) replaceScalar(node *yaml.Node, value string) error {
+	if node.Kind != yaml.ScalarNode {
+		return errors.New("node is not a scalar")
+	}
+
+	start := e.offset(node.Line, node.Column)
+	end, err := e.scalarEnd(node, start)
+	if err != nil {
+		return err
+	}
+
+	e.edits = append(e.edits, textEdit{start: start, end: end, text: renderScalar(node, value)})
+	return nil
+}
+
+// scalarEnd returns the end offset of the source text of a single line scalar
+func (e *YamlEditor) scalarEnd(node *yaml.Node, start int) (int, error) {
+	lineEnd := bytes.IndexByte(e.source[start:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(e.source)
+	} else {
+		lineEnd += start
+	}
+	text := e.source[start:lineEnd]
+
+	if node.Style&yaml.TaggedStyle != 0 {
+		return 0, fmt.Errorf("cannot edit the tagged value in line %d", node.Line)
+	}
+
+	switch node.Style {
+	case 0:
+		if !bytes.HasPrefix(text, []byte(node.Value)) {
+			return 0, fmt.Errorf("cannot edit the multiline value in line %d", node.Line)
+		}
+		return start + len(node.Value), nil
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(text); i++ {
+			if text[i] != '\'' {
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start + i + 1, nil
+		}
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case '"':
+				return start + i + 1, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("cannot edit the multiline value in line %d", node.Line)
+}
+
+// renderScalar returns the yaml text of value in the style of node
+func renderScalar(node *yaml.Node, value string) string {
+	switch {
+	case node.Style == yaml.SingleQuotedStyle && !strings.Contains(value, "\n"):
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case node.Style == yaml.DoubleQuotedStyle:
+		return strconv.Quote(value)
+	case isPlainScalar(value, node.ShortTag()):
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// isPlainScalar checks if value can be written without quotes and is still resolved to the given tag
+func isPlainScalar(value, tag string) bool {
+	if value == "" || strings.ContainsAny(value, "\n\t") || strings.TrimSpace(value) != value {
+		return false
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("key: "+value), &doc); err != nil {
+		return false
+	}
+	if len(doc.Content) != 1 || len(doc.Content[0].Content) != 2 {
+		return false
+	}
+	parsed := doc.Content[0].Content[1]
+	return parsed.Kind == yaml.ScalarNode && parsed.Style == 0 && parsed.Value == value && parsed.ShortTag() == tag
+}
@@ -0,0 +1,308 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlEditorInput = `# yaml-language-server: $schema=values.schema.json
+
+# The image
+image:
+    repository: "nginx"   # the repository
+    tag: '1.25'
+    pullPolicy: IfNotPresent
+
+# Replicas
+replicas: 1 # line comment
+
+list:
+  - name: first
+  - name: second
+`
+
+func TestYamlEditorNoEdits(t *testing.T) {
+	e, err := NewYamlEditor([]byte(yamlEditorInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != yamlEditorInput {
+		t.Errorf("Was expecting the unchanged input, but got:\n%s", out)
+	}
+}
+
+func TestYamlEditor(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(e *YamlEditor) error
+		expected string
+	}{
+		{
+			name: "rename plain key",
+			edit: func(e *YamlEditor) error { return e.RenameKey([]string{"replicas"}, "replicaCount") },
+			expected: `# yaml-language-server: $schema=values.schema.json
+
+# The image
+image:
+    repository: "nginx"   # the repository
+    tag: '1.25'
+    pullPolicy: IfNotPresent
+
+# Replicas
+replicaCount: 1 # line comment
+
+list:
+  - name: first
+  - name: second
+`,
+		},
+		{
+			name: "rename nested key in list",
+			edit: func(e *YamlEditor) error { return e.RenameKey([]string{"list", "1", "name"}, "id") },
+			expected: `# yaml-language-server: $schema=values.schema.json
+
+# The image
+image:
+    repository: "nginx"   # the repository
+    tag: '1.25'
+    pullPolicy: IfNotPresent
+
+# Replicas
+replicas: 1 # line comment
+
+list:
+  - name: first
+  - id: second
+`,
+		},
+		{
+			name: "set values keeps quoting style",
+			edit: func(e *YamlEditor) error {
+				if err := e.SetScalar([]string{"image", "repository"}, `my "nginx"`); err != nil {
+					return err
+				}
+				if err := e.SetScalar([]string{"image", "tag"}, "it's"); err != nil {
+					return err
+				}
+				return e.SetScalar([]string{"image", "pullPolicy"}, "Always")
+			},
+			expected: `# yaml-language-server: $schema=values.schema.json
+
+# The image
+image:
+    repository: "my \"nginx\""   # the repository
+    tag: 'it''s'
+    pullPolicy: Always
+
+# Replicas
+replicas: 1 # line comment
+
+list:
+  - name: first
+  - name: second
+`,
+		},
+		{
+			name: "plain value which would change its type is quoted",
+			edit: func(e *YamlEditor) error { return e.SetScalar([]string{"image", "pullPolicy"}, "true") },
+			expected: `# yaml-language-server: $schema=values.schema.json
+
+# The image
+image:
+    repository: "nginx"   # the repository
+    tag: '1.25'
+    pullPolicy: "true"
+
+# Replicas
+replicas: 1 # line comment
+
+list:
+  - name: first
+  - name: second
+`,
+		},
+		{
+			name: "replace head comment",
+			edit: func(e *YamlEditor) error {
+				return e.SetHeadComment([]string{"replicas"}, "@schema\nminimum: 1\n@schema\nReplicas")
+			},
+			expected: `# yaml-language-server: $schema=values.schema.json
+
+# The image
+image:
+    repository: "nginx"   # the repository
+    tag: '1.25'
+    pullPolicy: IfNotPresent
+
+# @schema
+# minimum: 1
+# @schema
+# Replicas
+replicas: 1 # line comment
+
+list:
+  - name: first
+  - name: second
+`,
+		},
+		{
+			name: "add and remove head comments",
+			edit: func(e *YamlEditor) error {
+				if err := e.SetHeadComment([]string{"image", "tag"}, "The tag"); err != nil {
+					return err
+				}
+				return e.SetHeadComment([]string{"image"}, "")
+			},
+			expected: `# yaml-language-server: $schema=values.schema.json
+
+image:
+    repository: "nginx"   # the repository
+    # The tag
+    tag: '1.25'
+    pullPolicy: IfNotPresent
+
+# Replicas
+replicas: 1 # line comment
+
+list:
+  - name: first
+  - name: second
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, err := NewYamlEditor([]byte(yamlEditorInput))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := test.edit(e); err != nil {
+				t.Fatal(err)
+			}
+			out, err := e.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != test.expected {
+				t.Errorf("Was expecting:\n%s\nbut got:\n%s", test.expected, out)
+			}
+		})
+	}
+}
+
+func TestYamlEditorHeadComment(t *testing.T) {
+	e, err := NewYamlEditor([]byte(yamlEditorInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     []string
+		expected string
+	}{
+		{path: []string{"image"}, expected: "The image"},
+		{path: []string{"image", "tag"}, expected: ""},
+		{path: []string{"replicas"}, expected: "Replicas"},
+		{path: []string{"list"}, expected: ""},
+	}
+	for _, test := range tests {
+		comment, err := e.HeadComment(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if comment != test.expected {
+			t.Errorf("Was expecting the comment %q of %v, but got %q", test.expected, test.path, comment)
+		}
+	}
+
+	if _, err := e.HeadComment([]string{"list", "0", "name"}); err == nil {
+		t.Error("Was expecting an error for a key in a list item")
+	}
+}
+
+func TestYamlEditorErrors(t *testing.T) {
+	input := `image:
+  tag: 1.0
+script: |
+  echo hello
+multi: foo
+  bar
+duplicate: a
+- : b
+`
+	if _, err := NewYamlEditor([]byte(input)); err == nil {
+		t.Error("Was expecting an error for invalid yaml")
+	}
+
+	input = `image:
+  tag: 1.0
+  other: 2.0
+script: |
+  echo hello
+multi: foo
+  bar
+list:
+  - name: first
+`
+	e, err := NewYamlEditor([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		edit func() error
+	}{
+		{name: "missing key", edit: func() error { return e.RenameKey([]string{"missing"}, "x") }},
+		{name: "existing key", edit: func() error { return e.RenameKey([]string{"image", "tag"}, "other") }},
+		{name: "invalid index", edit: func() error { return e.SetScalar([]string{"list", "1", "name"}, "x") }},
+		{name: "not a scalar", edit: func() error { return e.SetScalar([]string{"image"}, "x") }},
+		{name: "block scalar", edit: func() error { return e.SetScalar([]string{"script"}, "x") }},
+		{name: "multiline plain scalar", edit: func() error { return e.SetScalar([]string{"multi"}, "x") }},
+		{name: "comment of key in list item", edit: func() error { return e.SetHeadComment([]string{"list", "0", "name"}, "x") }},
+	}
+	for _, test := range tests {
+		if err := test.edit(); err == nil {
+			t.Errorf("Was expecting an error for %s", test.name)
+		}
+	}
+}
+
+func TestYamlEditorWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(path, []byte("# comment\r\nfoo: bar\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := ReadYamlEditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RenameKey([]string{"foo"}, "baz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# comment\r\nbaz: bar\r\n" {
+		t.Errorf("Was expecting crlf line endings to be kept, but got %q", content)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Was expecting the permissions to be kept, but got %v", info.Mode().Perm())
+	}
+}